@@ -3,6 +3,7 @@ package cmd
 import (
 	"fmt"
 	"os"
+	"time"
 
 	walk "github.com/TFMV/stride/internal/walk"
 	"github.com/spf13/cobra"
@@ -11,7 +12,7 @@ import (
 var (
 	// Analyze command options
 	analyzeOutputFormat   string
-	analyzeOutputFile     string
+	analyzeOutput         outputFlags
 	analyzeDuplicates     bool
 	analyzeCodeStats      bool
 	analyzeStorageReport  bool
@@ -22,6 +23,13 @@ var (
 	analyzeMinSize        string
 	analyzeMaxSize        string
 	analyzeIncludeHidden  bool
+	analyzeMinDupSize     string
+	analyzeInclude        []string
+	analyzeExclude        []string
+	analyzeProgress       bool
+	analyzeDirStatsDepth  int
+	analyzeDirStatsTop    int
+	analyzeAgeBuckets     []string
 )
 
 // analyzeCmd represents the analyze command
@@ -34,9 +42,11 @@ Examples:
   stride analyze /path/to/directory
   stride analyze --duplicates /path/to/directory
   stride analyze --storage-report --output=html --output-file=report.html /path/to/directory
+  stride analyze --storage-report --age-buckets=7d,30d,180d,1y /srv/archive
   stride analyze --code-stats --languages=go,js,py /path/to/repos
   stride analyze --security-scan /path/to/directory
-  stride analyze --content-pattern --max-depth=3 /path/to/directory`,
+  stride analyze --content-pattern --max-depth=3 /path/to/directory
+  stride analyze --code-stats --include=src/ --include=pkg/ --exclude="**/testdata/**" --progress /path/to/repo`,
 	Run: func(cmd *cobra.Command, args []string) {
 		// Get the directory to analyze
 		var analyzeDir string
@@ -56,10 +66,18 @@ Examples:
 
 		// Configure the analyzer
 		analyzer.SetOutputFormat(analyzeOutputFormat)
-		analyzer.SetOutputFile(analyzeOutputFile)
+		analyzer.SetOutputFile(analyzeOutput.path)
 
 		if analyzeDuplicates {
 			analyzer.EnableDuplicateDetection()
+			if analyzeMinDupSize != "" {
+				size, err := parseSize(analyzeMinDupSize)
+				if err != nil {
+					fmt.Fprintf(os.Stderr, "Error parsing --min-duplicate-size: %v\n", err)
+					os.Exit(1)
+				}
+				analyzer.SetMinDuplicateSize(size)
+			}
 		}
 
 		if analyzeCodeStats {
@@ -69,6 +87,21 @@ Examples:
 
 		if analyzeStorageReport {
 			analyzer.EnableStorageReport()
+			if len(analyzeAgeBuckets) > 0 {
+				var boundaries []time.Duration
+				for _, s := range analyzeAgeBuckets {
+					d, err := parseDuration(s)
+					if err == nil && d <= 0 {
+						err = fmt.Errorf("%q is not a positive duration", s)
+					}
+					if err != nil {
+						fmt.Fprintf(os.Stderr, "Error parsing --age-buckets: %v\n", err)
+						os.Exit(1)
+					}
+					boundaries = append(boundaries, d)
+				}
+				analyzer.SetAgeBuckets(boundaries)
+			}
 		}
 
 		if analyzeSecurityScan {
@@ -83,6 +116,13 @@ Examples:
 		analyzer.SetMaxDepth(analyzeMaxDepth)
 		analyzer.SetSizeRange(analyzeMinSize, analyzeMaxSize)
 		analyzer.SetIncludeHidden(analyzeIncludeHidden)
+		analyzer.SetIncludePatterns(analyzeInclude)
+		analyzer.SetExcludePatterns(analyzeExclude)
+		analyzer.SetDirStatsDepth(analyzeDirStatsDepth)
+		analyzer.SetDirStatsTopK(analyzeDirStatsTop)
+		if analyzeProgress {
+			analyzer.SetProgressCallback(printAnalyzeProgress)
+		}
 
 		// Run the analysis
 		result, err := analyzer.Analyze(analyzeDir)
@@ -92,26 +132,46 @@ Examples:
 		}
 
 		// Output the results
-		if analyzeOutputFile != "" {
-			err = result.SaveToFile(analyzeOutputFile)
-			if err != nil {
-				fmt.Fprintf(os.Stderr, "Error saving results to file: %v\n", err)
-				os.Exit(1)
-			}
-			fmt.Printf("Analysis results saved to %s\n", analyzeOutputFile)
-		} else {
-			// Print to stdout
-			fmt.Println(result.String())
+		if err := writeAnalyzeResult(analyzer.Meta(analyzeDir), result); err != nil {
+			fmt.Fprintf(os.Stderr, "Error writing results: %v\n", err)
+			os.Exit(1)
 		}
 	},
 }
 
+// writeAnalyzeResult writes result to the output file, or to stdout if none
+// is set: as a JSON report in the json format, and as text otherwise. The
+// output file is only replaced once the whole result has been written.
+func writeAnalyzeResult(meta walk.AnalyzeMeta, result *walk.AnalyzeResult) error {
+	out, aw, err := analyzeOutput.open()
+	if err != nil {
+		return err
+	}
+	if aw != nil {
+		defer aw.Close()
+	}
+
+	if analyzeOutputFormat == "json" {
+		err = walk.WriteAnalyzeReport(out, meta, result)
+	} else {
+		_, err = fmt.Fprintln(out, result.String())
+	}
+	if err != nil || aw == nil {
+		return err
+	}
+	if err := aw.Commit(); err != nil {
+		return err
+	}
+	fmt.Printf("Analysis results saved to %s\n", analyzeOutput.path)
+	return nil
+}
+
 func init() {
 	rootCmd.AddCommand(analyzeCmd)
 
 	// Define flags for the analyze command
 	analyzeCmd.Flags().StringVar(&analyzeOutputFormat, "output", "text", "Output format (text, json, csv, html)")
-	analyzeCmd.Flags().StringVar(&analyzeOutputFile, "output-file", "", "File to write output to")
+	analyzeOutput.add(analyzeCmd, "output-file")
 	analyzeCmd.Flags().BoolVar(&analyzeDuplicates, "duplicates", false, "Find duplicate files")
 	analyzeCmd.Flags().BoolVar(&analyzeCodeStats, "code-stats", false, "Analyze code statistics")
 	analyzeCmd.Flags().BoolVar(&analyzeStorageReport, "storage-report", false, "Generate storage usage report")
@@ -122,4 +182,29 @@ func init() {
 	analyzeCmd.Flags().StringVar(&analyzeMinSize, "min-size", "", "Minimum file size to analyze")
 	analyzeCmd.Flags().StringVar(&analyzeMaxSize, "max-size", "", "Maximum file size to analyze")
 	analyzeCmd.Flags().BoolVar(&analyzeIncludeHidden, "include-hidden", false, "Include hidden files and directories")
+	analyzeCmd.Flags().StringVar(&analyzeMinDupSize, "min-duplicate-size", "", "Ignore files smaller than this in duplicate detection")
+	analyzeCmd.Flags().StringSliceVar(&analyzeInclude, "include", []string{}, "Only analyze root-relative paths matching these patterns (e.g. src/, **/*.go)")
+	analyzeCmd.Flags().StringSliceVar(&analyzeExclude, "exclude", []string{}, "Skip root-relative paths matching these patterns")
+	analyzeCmd.Flags().BoolVar(&analyzeProgress, "progress", false, "Show phase progress on stderr")
+	analyzeCmd.Flags().IntVar(&analyzeDirStatsDepth, "dir-stats-depth", walk.DefaultDirStatsDepth, "Directory levels the storage report breaks sizes down by")
+	analyzeCmd.Flags().IntVar(&analyzeDirStatsTop, "dir-stats-top", walk.DefaultDirStatsTopK, "Number of largest directories in the storage report (0 for all)")
+	analyzeCmd.Flags().StringSliceVar(&analyzeAgeBuckets, "age-buckets", []string{}, "Boundaries between the age buckets of the storage report (e.g. 7d,30d,180d,1y; default 30d,90d,1y)")
+}
+
+// printAnalyzeProgress shows analysis progress on a single stderr line,
+// ending the line when a phase finishes.
+func printAnalyzeProgress(p walk.AnalyzeProgress) {
+	fmt.Fprintf(os.Stderr, "\r\033[K[%s] %d files, %d dirs, %.2f MB read",
+		p.Phase, p.FilesScanned, p.DirsScanned, float64(p.BytesRead)/(1024*1024))
+	switch p.Phase {
+	case walk.PhaseHashing:
+		fmt.Fprintf(os.Stderr, ", %d hashed", p.FilesHashed)
+	case walk.PhaseNearDuplicates:
+		fmt.Fprintf(os.Stderr, ", %d groups", p.NearDupGroups)
+	case walk.PhaseDependencies:
+		fmt.Fprintf(os.Stderr, ", %d parsed", p.DepFiles)
+	}
+	if p.PhaseDone {
+		fmt.Fprintf(os.Stderr, " in %v\n", p.Elapsed.Round(time.Millisecond))
+	}
 }
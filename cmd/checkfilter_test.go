@@ -0,0 +1,42 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCheckFilter(t *testing.T) {
+	root := t.TempDir()
+	small, large := filepath.Join(root, "small.log"), filepath.Join(root, "large.log")
+	if err := os.WriteFile(small, []byte("x"), 0644); err != nil {
+		t.Fatalf("Failed to create file: %v", err)
+	}
+	if err := os.WriteFile(large, []byte(strings.Repeat("x", 2048)), 0644); err != nil {
+		t.Fatalf("Failed to create file: %v", err)
+	}
+
+	out, code := runStrideOutput(t, "check-filter", "--pattern=*.log", "--min-size=1024", large, small)
+	if code != ExitNoMatch {
+		t.Errorf("Expected exit status %d, got %d", ExitNoMatch, code)
+	}
+	for _, want := range []string{"include " + large + "\n", "exclude " + small + ": min_size\n"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("Expected %q in the output, got:\n%s", want, out)
+		}
+	}
+
+	// Every failed criterion is named
+	out, _ = runStrideOutput(t, "check-filter", "--pattern=*.txt", "--min-size=1024", small)
+	if want := "exclude " + small + ": min_size, pattern\n"; !strings.Contains(out, want) {
+		t.Errorf("Expected %q in the output, got:\n%s", want, out)
+	}
+
+	if code := runStride(t, "check-filter", "--pattern=*.log", large); code != ExitOK {
+		t.Errorf("Expected exit status %d, got %d", ExitOK, code)
+	}
+	if code := runStride(t, "check-filter", filepath.Join(root, "missing")); code != ExitPathError {
+		t.Errorf("Expected exit status %d, got %d", ExitPathError, code)
+	}
+}
@@ -0,0 +1,159 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+
+	stride "github.com/TFMV/stride/internal/walk"
+	"github.com/spf13/cobra"
+)
+
+var (
+	// Clean command options
+	cleanDryRun     bool
+	cleanForce      bool
+	cleanQuarantine string
+	cleanJournal    string
+	cleanMaxDelete  int
+	cleanUndo       string
+)
+
+// cleanCmd represents the clean command
+var cleanCmd = &cobra.Command{
+	Use:   "clean [options] <path>",
+	Short: "Delete or quarantine the files selected by the filter flags",
+	Long: `Delete the files beneath a path that the filter flags select, or with
+--quarantine, move them to a directory, keeping their path relative to the
+root. Directories are never removed.
+
+Nothing is changed without --force: review the files with --dry-run first. At
+least one filter flag is required, and if more than --max-delete files match,
+nothing is changed either. A file that cannot be removed is reported and the
+others are still processed.
+
+Every action is appended to a journal, by default ` + stride.DefaultCleanJournalName + ` in the
+quarantine directory, recording the path, size, modification time, action and
+time; deleting requires --journal. Each action is recorded before it is taken
+and again with its outcome. --undo moves the quarantined files recorded in a
+journal back.
+
+Examples:
+  stride clean --pattern="*.tmp" --dry-run /srv/data
+  stride clean --pattern="*.tmp" --journal=/var/log/stride-clean.jsonl --force /srv/data
+  stride clean --pattern="*.log" --modified-before=2024-01-01 --quarantine=/var/quarantine --force /var/log/app
+  stride clean --undo=/var/quarantine/` + stride.DefaultCleanJournalName,
+	Args: func(cmd *cobra.Command, args []string) error {
+		if cleanUndo != "" {
+			return cobra.NoArgs(cmd, args)
+		}
+		return cobra.ExactArgs(1)(cmd, args)
+	},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		bindFilterFlags(cmd)
+		if cleanUndo != "" {
+			return commandResult(cmd, runCleanUndo(cleanUndo))
+		}
+		return commandResult(cmd, runClean(cmd, args[0]))
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(cleanCmd)
+
+	addFilterFlags(cleanCmd)
+	cleanCmd.Flags().BoolVar(&cleanDryRun, "dry-run", false, "List the files that would be removed without changing anything")
+	cleanCmd.Flags().BoolVar(&cleanForce, "force", false, "Remove the files; without it, nothing is changed")
+	cleanCmd.Flags().StringVar(&cleanQuarantine, "quarantine", "", "Move the files to this directory, outside the path, instead of deleting them")
+	cleanCmd.Flags().StringVar(&cleanJournal, "journal", "", "Append every action to this file (default "+stride.DefaultCleanJournalName+" in the quarantine directory, required to delete)")
+	cleanCmd.Flags().IntVar(&cleanMaxDelete, "max-delete", stride.DefaultCleanMaxDelete, "Change nothing if more files than this match (negative for no limit)")
+	cleanCmd.Flags().StringVar(&cleanUndo, "undo", "", "Move the files quarantined according to this journal back")
+	cleanCmd.MarkFlagsMutuallyExclusive("dry-run", "force")
+}
+
+func runClean(cmd *cobra.Command, root string) error {
+	if !cleanDryRun && !cleanForce {
+		return errors.New("refusing to remove files without --force; review them with --dry-run first")
+	}
+	selected := false
+	for _, name := range filterFlagNames {
+		if f := cmd.Flags().Lookup(name); f != nil && f.Changed {
+			selected = true
+		}
+	}
+	if !selected {
+		return errors.New("refusing to remove every file: select them with at least one filter flag")
+	}
+	if cleanForce && cleanQuarantine == "" && cleanJournal == "" {
+		return errors.New("refusing to delete files without a record: pass --journal, or --quarantine to keep them")
+	}
+	if cleanMaxDelete == 0 {
+		return errors.New("--max-delete must be positive, or negative for no limit")
+	}
+	filter, err := filterOptionsFromConfig()
+	if err != nil {
+		return err
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), shutdownSignals...)
+	defer stop()
+	opts := stride.CleanOptions{
+		DryRun:        cleanDryRun,
+		QuarantineDir: cleanQuarantine,
+		Journal:       cleanJournal,
+		MaxDelete:     cleanMaxDelete,
+		OnAction:      printCleanAction,
+	}
+	report, err := stride.CleanFiles(ctx, root, filter, opts)
+	if errors.Is(err, stride.ErrCleanLimit) {
+		return &exitStatus{code: ExitFatal, msg: fmt.Sprintf("%v; nothing was changed, raise --max-delete to go ahead", err)}
+	}
+	if err != nil {
+		return err
+	}
+	return cleanStatus(report)
+}
+
+func runCleanUndo(journal string) error {
+	ctx, stop := signal.NotifyContext(context.Background(), shutdownSignals...)
+	defer stop()
+	report, err := stride.UndoClean(ctx, journal, printCleanAction)
+	if err != nil {
+		return err
+	}
+	return cleanStatus(report)
+}
+
+// printCleanAction prints an action of clean as it is taken, and failures
+// to standard error.
+func printCleanAction(a stride.CleanAction) {
+	if a.Error != "" {
+		fmt.Fprintf(os.Stderr, "Error: %s %s: %s\n", a.Action, a.Path, a.Error)
+		return
+	}
+	switch a.Action {
+	case stride.CleanActionDryRun:
+		fmt.Printf("would remove: %s\n", a.Path)
+	case stride.CleanActionDelete:
+		fmt.Printf("deleted: %s\n", a.Path)
+	case stride.CleanActionQuarantine:
+		fmt.Printf("quarantined: %s -> %s\n", a.Path, a.Target)
+	case stride.CleanActionRestore:
+		fmt.Printf("restored: %s -> %s\n", a.Target, a.Path)
+	}
+}
+
+// cleanStatus summarizes report and returns the status of the command.
+func cleanStatus(report stride.CleanReport) error {
+	if report.DryRun {
+		fmt.Printf("%d files, %d bytes would be removed\n", report.Matched, report.Bytes)
+	} else {
+		fmt.Printf("%d of %d files done, %d failed\n", report.Done, report.Matched, len(report.Failed))
+	}
+	if len(report.Failed) > 0 {
+		return &exitStatus{code: ExitPathError, msg: fmt.Sprintf("%d files could not be processed", len(report.Failed))}
+	}
+	return walkStatus(report.WalkErrors)
+}
@@ -0,0 +1,131 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/TFMV/stride/internal/color"
+	stride "github.com/TFMV/stride/internal/walk"
+	"github.com/spf13/viper"
+)
+
+// newColorizer builds the colorizer for output written to f, nil for a
+// file that is never a terminal, from the --color flag, LS_COLORS and the
+// "colors" config key, in increasing precedence.
+func newColorizer(f *os.File) (*color.Colorizer, error) {
+	mode, err := color.ParseMode(viper.GetString("color"))
+	if err != nil {
+		return nil, err
+	}
+	c := color.New(color.Enabled(mode, f))
+	c.Apply(os.Getenv("LS_COLORS"))
+	c.Apply(viper.GetString("colors"))
+	return c, nil
+}
+
+// colorFindHandler prints each match like the default find handler, coloring
+// it by type and highlighting the parts matched by the name or regex pattern.
+func colorFindHandler(c *color.Colorizer, opts stride.FindOptions) stride.FindHandler {
+	var w io.Writer = os.Stdout
+	if opts.Output != nil {
+		w = opts.Output
+	}
+	var mu sync.Mutex
+	return func(ctx context.Context, result stride.FindResult) error {
+		if result.Error != nil {
+			return result.Error
+		}
+		path := result.Message.Path
+
+		var mode fs.FileMode
+		if info, err := os.Lstat(path); err == nil {
+			mode = info.Mode()
+		} else if result.Message.IsDir {
+			mode = fs.ModeDir
+		}
+
+		var ranges [][2]int
+		switch {
+		case opts.RegexPattern != nil:
+			for _, loc := range opts.RegexPattern.FindAllStringIndex(path, -1) {
+				ranges = append(ranges, [2]int{loc[0], loc[1]})
+			}
+		case strings.HasSuffix(path, result.Message.Name):
+			offset := len(path) - len(result.Message.Name)
+			for _, r := range globLiteralRanges(matchingNamePattern(opts, result.Message.Name), result.Message.Name) {
+				ranges = append(ranges, [2]int{offset + r[0], offset + r[1]})
+			}
+		}
+
+		line := c.Match(path, mode, ranges)
+		if acl := result.Message.Metadata["acl"]; acl != "" {
+			line += "\t" + acl
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		_, err := fmt.Fprintln(w, line)
+		return err
+	}
+}
+
+// matchingNamePattern returns the first name pattern in opts that globs
+// name, or "" if none does.
+func matchingNamePattern(opts stride.FindOptions, name string) string {
+	for _, pattern := range append([]string{opts.NamePattern}, opts.NamePatterns...) {
+		if matched, err := filepath.Match(pattern, name); err == nil && matched && pattern != "" {
+			return pattern
+		}
+	}
+	return ""
+}
+
+// globLiteralRanges returns the byte ranges of name matched by the literal
+// parts of a glob pattern, located left to right.
+func globLiteralRanges(pattern, name string) [][2]int {
+	var literals []string
+	var cur strings.Builder
+	flush := func() {
+		if cur.Len() > 0 {
+			literals = append(literals, cur.String())
+			cur.Reset()
+		}
+	}
+	for i := 0; i < len(pattern); i++ {
+		switch ch := pattern[i]; ch {
+		case '*', '?':
+			flush()
+		case '[':
+			flush()
+			if end := strings.IndexByte(pattern[i+1:], ']'); end >= 0 {
+				i += end + 1
+			}
+		case '\\':
+			if i+1 < len(pattern) {
+				i++
+				cur.WriteByte(pattern[i])
+			}
+		default:
+			cur.WriteByte(ch)
+		}
+	}
+	flush()
+
+	var ranges [][2]int
+	pos := 0
+	for _, lit := range literals {
+		idx := strings.Index(name[pos:], lit)
+		if idx < 0 {
+			return nil
+		}
+		start := pos + idx
+		ranges = append(ranges, [2]int{start, start + len(lit)})
+		pos = start + len(lit)
+	}
+	return ranges
+}
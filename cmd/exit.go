@@ -0,0 +1,67 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+
+	stride "github.com/TFMV/stride/internal/walk"
+	"github.com/spf13/cobra"
+)
+
+// Exit statuses returned by ExitCode. Like grep, a search that completes
+// without matching is distinguished from one that fails.
+const (
+	ExitOK        = 0 // The walk completed; for find, something matched
+	ExitNoMatch   = 1 // find --exit-nonzero-on-empty matched nothing, manifest verify found changes, or check-filter excluded a path
+	ExitPathError = 2 // The walk completed, but some paths could not be processed
+	ExitFatal     = 3 // The command failed or the walk was aborted
+	ExitTruncated = 4 // --max-duration, --max-files or a memory limit stopped the walk early
+)
+
+// exitStatus is returned by a command that completed but must exit with a
+// non-zero status.
+type exitStatus struct {
+	code int
+	msg  string // Printed to standard error unless empty
+}
+
+func (e *exitStatus) Error() string {
+	return e.msg
+}
+
+// ExitCode returns the exit status for an error returned by Execute.
+func ExitCode(err error) int {
+	var status *exitStatus
+	switch {
+	case err == nil:
+		return ExitOK
+	case errors.As(err, &status):
+		return status.code
+	case errors.Is(err, stride.ErrBudgetExceeded), errors.Is(err, stride.ErrMemoryLimit):
+		return ExitTruncated
+	}
+	return ExitFatal
+}
+
+// walkStatus returns the result of a walk that ended without error but may
+// have failed to process some paths.
+func walkStatus(errorCount int64) error {
+	if errorCount == 0 {
+		return nil
+	}
+	return &exitStatus{code: ExitPathError, msg: fmt.Sprintf("%d paths could not be processed", errorCount)}
+}
+
+// commandResult prepares cmd to report err. Exit statuses and truncation are
+// not usage errors, and exit statuses are printed by the caller of Execute.
+func commandResult(cmd *cobra.Command, err error) error {
+	var status *exitStatus
+	switch {
+	case errors.As(err, &status):
+		cmd.SilenceUsage = true
+		cmd.SilenceErrors = true
+	case stride.StopReason(err) != stride.TerminationCompleted:
+		cmd.SilenceUsage = true
+	}
+	return err
+}
@@ -0,0 +1,98 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestMain runs the CLI instead of the tests when STRIDE_TEST_ARGS is set,
+// so that tests can check the exit status of a real process.
+func TestMain(m *testing.M) {
+	if args, ok := os.LookupEnv("STRIDE_TEST_ARGS"); ok {
+		rootCmd.SetArgs(strings.Split(args, "\n"))
+		err := Execute()
+		if err != nil && err.Error() != "" {
+			fmt.Fprintln(os.Stderr, err)
+		}
+		os.Exit(ExitCode(err))
+	}
+	os.Exit(m.Run())
+}
+
+// runStride runs the CLI with args in a child process and returns its exit status.
+func runStride(t *testing.T, args ...string) int {
+	t.Helper()
+	_, code := runStrideOutput(t, args...)
+	return code
+}
+
+// runStrideOutput runs the CLI with args in a child process and returns its
+// combined output and exit status.
+func runStrideOutput(t *testing.T, args ...string) (string, int) {
+	t.Helper()
+	cmd := exec.Command(os.Args[0])
+	cmd.Env = append(os.Environ(), "STRIDE_TEST_ARGS="+strings.Join(args, "\n"))
+	out, err := cmd.CombinedOutput()
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return string(out), exitErr.ExitCode()
+	}
+	if err != nil {
+		t.Fatalf("Failed to run %v: %v\n%s", args, err, out)
+	}
+	return string(out), 0
+}
+
+func TestExitCodes(t *testing.T) {
+	clean := t.TempDir()
+	for _, name := range []string{"a.txt", "b.txt", "c.go"} {
+		if err := os.WriteFile(filepath.Join(clean, name), []byte(name), 0644); err != nil {
+			t.Fatalf("Failed to create file: %v", err)
+		}
+	}
+
+	// A dangling link cannot be followed
+	broken := t.TempDir()
+	if err := os.WriteFile(filepath.Join(broken, "a.txt"), []byte("a"), 0644); err != nil {
+		t.Fatalf("Failed to create file: %v", err)
+	}
+	if err := os.Symlink(filepath.Join(broken, "missing"), filepath.Join(broken, "dangling")); err != nil {
+		t.Skipf("Symlinks not supported: %v", err)
+	}
+
+	tests := []struct {
+		name string
+		args []string
+		want int
+	}{
+		{"walk", []string{"--silent", clean}, ExitOK},
+		{"walk with path errors", []string{"--silent", "--follow-symlinks", broken}, ExitPathError},
+		{"walk stopped by a path error", []string{"--silent", "--follow-symlinks", "--error-mode=stop", broken}, ExitFatal},
+		{"walk with invalid flags", []string{"--silent", "--max-duration=soon", clean}, ExitFatal},
+		{"walk truncated", []string{"--silent", "--max-files=1", clean}, ExitTruncated},
+		{"walk of a root that never appears", []string{"--silent", "--wait-for-root=200ms", filepath.Join(clean, "missing")}, ExitFatal},
+
+		{"find matches", []string{"find", clean, "--name=*.txt"}, ExitOK},
+		{"find no matches", []string{"find", clean, "--name=*.md"}, ExitOK},
+		{"find no matches with flag", []string{"find", clean, "--name=*.md", "--exit-nonzero-on-empty"}, ExitNoMatch},
+		{"find matches with flag", []string{"find", clean, "--name=*.txt", "--exit-nonzero-on-empty"}, ExitOK},
+		{"find with path errors", []string{"find", broken, "--follow-symlinks", "--name=*.txt"}, ExitPathError},
+		{"find with path errors and no matches", []string{"find", broken, "--follow-symlinks", "--name=*.md", "--exit-nonzero-on-empty"}, ExitPathError},
+		{"find with invalid regex", []string{"find", clean, "--regex=("}, ExitFatal},
+		{"find truncated", []string{"find", clean, "--max-files=1"}, ExitTruncated},
+		{"find in a root that never appears", []string{"find", filepath.Join(clean, "missing"), "--wait-for-root=200ms"}, ExitFatal},
+		{"find truncated without matches", []string{"find", clean, "--max-files=1", "--name=*.md", "--exit-nonzero-on-empty"}, ExitTruncated},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := runStride(t, tt.args...); got != tt.want {
+				t.Errorf("stride %s exited with %d, want %d", strings.Join(tt.args, " "), got, tt.want)
+			}
+		})
+	}
+}
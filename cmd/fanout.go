@@ -0,0 +1,46 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+
+	stride "github.com/TFMV/stride/internal/walk"
+	"github.com/spf13/cobra"
+)
+
+var (
+	// Fanout command options
+	fanoutThreshold int
+)
+
+// fanoutCmd represents the fanout command
+var fanoutCmd = &cobra.Command{
+	Use:   "fanout [options] <path>",
+	Short: "Find directories with a very large number of direct entries",
+	Long: `List the directories holding more than --threshold direct entries, those
+with the most entries first, with how many of them are files and directories.
+Directories with millions of children slow down most tools that read them.
+
+Examples:
+  stride fanout /data
+  stride fanout --threshold=1000 /var/spool`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runFanout(args[0])
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(fanoutCmd)
+
+	fanoutCmd.Flags().IntVar(&fanoutThreshold, "threshold", 10000, "Report directories with more direct entries than this")
+}
+
+func runFanout(root string) error {
+	opts := stride.WalkOptions{ErrorHandling: stride.ErrorHandlingContinue}
+	dirs, err := stride.DirFanout(context.Background(), root, fanoutThreshold, opts)
+	for _, d := range dirs {
+		fmt.Printf("%10d  %s (%d files, %d dirs)\n", d.Entries(), d.Path, d.DirectFiles, d.DirectDirs)
+	}
+	return err
+}
@@ -10,9 +10,13 @@ package cmd
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"io"
+	"os"
 	"regexp"
 	"strings"
+	"sync"
 	"time"
 
 	stride "github.com/TFMV/stride/internal/walk"
@@ -20,8 +24,11 @@ import (
 	"github.com/spf13/viper"
 )
 
+// findOutput is where find writes its matches
+var findOutput outputFlags
+
 var findCmd = &cobra.Command{
-	Use:   "find [options] <path>",
+	Use:   "find [options] <path>...",
 	Short: "Find files with advanced filtering",
 	Long: `Find files with advanced filtering capabilities.
 Supports pattern matching, time-based filtering, size constraints, and more.
@@ -29,14 +36,24 @@ Can execute commands for each matched file or format output using templates.
 
 Examples:
   stride find /path/to/search --name="*.go"
+  stride find /path/to/search --name="*.go" --name="*.proto" --ignore="*_test.go" --ignore="*.pb.go"
   stride find /path/to/search --regex=".*\\.txt$" --larger-than=1MB
   stride find /path/to/search --exec="echo Processing: {}"
+  stride find /path/to/search --exec-route='*.png:convert {} {}.jpg' --exec-route='*.log:gzip {}'
   stride find /path/to/search --format="{base} ({size} bytes)"
-  stride find /path/to/search --older-than=7d --watch`,
-	Args: cobra.ExactArgs(1),
+  stride find /path/to/search --name="*.go" --json | jq -r .path
+  stride find /path/to/search --name="*.go" --content="TODO|FIXME" --format="{}:\n{matches}"
+  stride find /path/to/search --older-than=7d --watch
+  stride find /path/to/search --hash-list=bad.sha256 --format="{sha256} {}"
+  stride find /path/to/search --name="*.log" --older-than=36h --explain
+  stride find /path/to/search --require-flags=immutable --exclude-flags=nodump
+  stride find /path/to/search --has-acl --show-acl
+  stride find /path/to/search --newer-than-file=.last-build --touch-reference
+  stride find /path/to/search --name="*.log" --output=logs.txt
+  stride find /data/a /data/b --name="*.log"`,
+	Args: cobra.MinimumNArgs(1),
 	RunE: func(cmd *cobra.Command, args []string) error {
-		path := args[0]
-		return runFind(path)
+		return commandResult(cmd, runFind(args))
 	},
 }
 
@@ -44,32 +61,68 @@ func init() {
 	rootCmd.AddCommand(findCmd)
 
 	// Pattern matching options
-	findCmd.Flags().StringP("name", "n", "", "Match by file name (supports wildcards)")
+	findCmd.Flags().StringArrayP("name", "n", []string{}, "Match by file name (supports wildcards; repeat to match any)")
 	findCmd.Flags().StringP("path", "p", "", "Match by path (supports wildcards)")
-	findCmd.Flags().String("ignore", "", "Skip paths matching this pattern")
+	findCmd.Flags().StringArray("ignore", []string{}, "Skip paths matching this pattern (repeatable)")
+	findCmd.Flags().Bool("skip-junk", false, "Skip editor swap, backup and lock files, .DS_Store and Thumbs.db")
 	findCmd.Flags().StringP("regex", "r", "", "Match by regular expression")
 
 	// Time-based filtering
 	findCmd.Flags().String("older-than", "", "Files older than this duration (e.g. 7d, 24h, 30m)")
 	findCmd.Flags().String("newer-than", "", "Files newer than this duration (e.g. 7d, 24h, 30m)")
+	findCmd.Flags().String("older-than-file", "", "Files modified before this file")
+	findCmd.Flags().String("newer-than-file", "", "Files modified after this file, like find -newer")
+	findCmd.Flags().Bool("touch-reference", false, "Set the modification time of the --newer-than-file file to the start of the search once it succeeds")
 
 	// Size-based filtering
 	findCmd.Flags().String("larger-than", "", "Files larger than this size (e.g. 1MB, 500KB)")
 	findCmd.Flags().String("smaller-than", "", "Files smaller than this size (e.g. 1MB, 500KB)")
+	findCmd.Flags().Bool("empty", false, "Match empty files and empty directories")
+	findCmd.Flags().StringSlice("type", []string{}, "Match only these file types, like find -type (comma-separated or repeatable: file,dir,symlink,pipe,socket,device,char)")
+	findCmd.Flags().Bool("broken-links", false, "Match only symbolic links whose target does not exist; links are not followed")
+	findCmd.Flags().String("require-flags", "", "Files with all of these flags (immutable,appendonly,nodump,readonly,hidden,system)")
+	findCmd.Flags().String("exclude-flags", "", "Skip files with any of these flags (e.g. nodump)")
+	findCmd.Flags().Bool("has-acl", false, "Match only files whose ACL grants more than their permission bits show")
+	findCmd.Flags().Bool("show-acl", false, "Print the extended ACL of each match after a tab, such as u:alice:rw-,m::rw-")
 
 	// Metadata and tag filtering
 	findCmd.Flags().StringSlice("meta", []string{}, "Metadata key-value patterns to match (key=regex)")
 	findCmd.Flags().StringSlice("tag", []string{}, "Tag key-value patterns to match (key=regex)")
 
+	// Content hash filtering
+	findCmd.Flags().String("hash-list", "", "File of SHA-256 digests to check matches against (plain or gzip)")
+	findCmd.Flags().String("hash-list-mode", "match", "How to treat files in the hash list (match|exclude)")
+
+	// Content filtering
+	findCmd.Flags().String("content", "", "Match files with a line matching this regular expression")
+	findCmd.Flags().String("content-max-bytes", "", "Read at most this much of each file for --content (e.g. 1MB); all of it by default")
+	findCmd.Flags().Int("content-max-lines", stride.DefaultContentMaxLines, "Matching lines kept for the {matches} placeholder and --json")
+	findCmd.Flags().Bool("include-binary", false, "Search files that look binary for --content too")
+
 	// Execution options
-	findCmd.Flags().String("exec", "", "Command to execute for each match")
-	findCmd.Flags().String("format", "", "Format string for output")
+	findCmd.Flags().String("exec", "", "Command to execute for each match, run through the shell with placeholders such as {} quoted for it")
+	findCmd.Flags().StringArray("exec-route", []string{}, "Run COMMAND for matches whose base name matches GLOB, given as GLOB:COMMAND (repeatable; first match wins; \\: is a colon in GLOB); --exec then runs for the rest")
+	findCmd.Flags().String("format", "", "Format string for output, or proto for length-prefixed protobuf entries (see proto/entry.proto)")
+	findCmd.Flags().Bool("json", false, "Write each match as a line of JSON, and errors as objects with an error field")
+	findCmd.Flags().Bool("exec-env", true, "Describe the match to --exec commands in STRIDE_* environment variables")
+	findCmd.Flags().Bool("exec-prefix", false, "Prefix each line of --exec output with the path of the match")
 
 	// Traversal options
-	findCmd.Flags().UintP("max-depth", "d", 0, "Maximum directory depth to traverse")
+	findCmd.Flags().UintP("max-depth", "d", 0, "Deepest entries to report; 1 (or 0) for the root's entries only")
 	findCmd.Flags().Bool("follow-symlinks", false, "Follow symbolic links")
 	findCmd.Flags().Bool("include-hidden", false, "Include hidden files")
+	findCmd.Flags().Bool("include-root", false, "Report the root itself first, without applying the match criteria")
 	findCmd.Flags().Bool("with-versions", false, "Include file versions")
+	findCmd.Flags().String("max-duration", "", "Stop searching after this long (e.g. 30s, 5m)")
+	findCmd.Flags().Int64("max-files", 0, "Stop searching after walking this many files")
+	findCmd.Flags().String("wait-for-root", "", "Wait up to this long for a root that does not exist yet to appear (e.g. 30s, 5m)")
+	findCmd.Flags().Int("max-per-dir", 0, "Report at most this many matches from each directory, for a quick preview")
+	findCmd.Flags().String("prune-unmodified-since", "", "Skip the entries of directories unchanged since a date (YYYY-MM-DD or RFC 3339) or for a duration (e.g. 24h, 7d); deeper changes are still found")
+	findCmd.Flags().Bool("recursive-mtime-propagation", false, "With --prune-unmodified-since, skip unchanged directories without reading them; only safe where changes update every directory above them")
+	findCmd.Flags().String("include-from", "", "Search only below the path prefixes in this file, one per line, relative to the root or absolute (# starts a comment)")
+	findCmd.Flags().Bool("exit-nonzero-on-empty", false, "Exit with status 1 when nothing matches")
+	findCmd.Flags().Bool("explain", false, "Print to stderr which criteria each evaluated entry passed or failed")
+	findOutput.add(findCmd, "output")
 
 	// Watch options
 	findCmd.Flags().BoolP("watch", "w", false, "Watch for changes")
@@ -79,45 +132,157 @@ func init() {
 	viper.BindPFlag("find.name", findCmd.Flags().Lookup("name"))
 	viper.BindPFlag("find.path", findCmd.Flags().Lookup("path"))
 	viper.BindPFlag("find.ignore", findCmd.Flags().Lookup("ignore"))
+	viper.BindPFlag("find.skip-junk", findCmd.Flags().Lookup("skip-junk"))
 	viper.BindPFlag("find.regex", findCmd.Flags().Lookup("regex"))
 	viper.BindPFlag("find.older-than", findCmd.Flags().Lookup("older-than"))
 	viper.BindPFlag("find.newer-than", findCmd.Flags().Lookup("newer-than"))
+	viper.BindPFlag("find.older-than-file", findCmd.Flags().Lookup("older-than-file"))
+	viper.BindPFlag("find.newer-than-file", findCmd.Flags().Lookup("newer-than-file"))
+	viper.BindPFlag("find.touch-reference", findCmd.Flags().Lookup("touch-reference"))
 	viper.BindPFlag("find.larger-than", findCmd.Flags().Lookup("larger-than"))
 	viper.BindPFlag("find.smaller-than", findCmd.Flags().Lookup("smaller-than"))
+	viper.BindPFlag("find.empty", findCmd.Flags().Lookup("empty"))
+	viper.BindPFlag("find.type", findCmd.Flags().Lookup("type"))
+	viper.BindPFlag("find.broken-links", findCmd.Flags().Lookup("broken-links"))
+	viper.BindPFlag("find.require-flags", findCmd.Flags().Lookup("require-flags"))
+	viper.BindPFlag("find.exclude-flags", findCmd.Flags().Lookup("exclude-flags"))
+	viper.BindPFlag("find.has-acl", findCmd.Flags().Lookup("has-acl"))
+	viper.BindPFlag("find.show-acl", findCmd.Flags().Lookup("show-acl"))
 	viper.BindPFlag("find.meta", findCmd.Flags().Lookup("meta"))
 	viper.BindPFlag("find.tag", findCmd.Flags().Lookup("tag"))
+	viper.BindPFlag("find.hash-list", findCmd.Flags().Lookup("hash-list"))
+	viper.BindPFlag("find.hash-list-mode", findCmd.Flags().Lookup("hash-list-mode"))
+	viper.BindPFlag("find.content", findCmd.Flags().Lookup("content"))
+	viper.BindPFlag("find.content-max-bytes", findCmd.Flags().Lookup("content-max-bytes"))
+	viper.BindPFlag("find.content-max-lines", findCmd.Flags().Lookup("content-max-lines"))
+	viper.BindPFlag("find.include-binary", findCmd.Flags().Lookup("include-binary"))
 	viper.BindPFlag("find.exec", findCmd.Flags().Lookup("exec"))
+	viper.BindPFlag("find.exec-route", findCmd.Flags().Lookup("exec-route"))
 	viper.BindPFlag("find.format", findCmd.Flags().Lookup("format"))
+	viper.BindPFlag("find.json", findCmd.Flags().Lookup("json"))
+	viper.BindPFlag("find.exec-env", findCmd.Flags().Lookup("exec-env"))
+	viper.BindPFlag("find.exec-prefix", findCmd.Flags().Lookup("exec-prefix"))
 	viper.BindPFlag("find.max-depth", findCmd.Flags().Lookup("max-depth"))
 	viper.BindPFlag("find.follow-symlinks", findCmd.Flags().Lookup("follow-symlinks"))
 	viper.BindPFlag("find.include-hidden", findCmd.Flags().Lookup("include-hidden"))
+	viper.BindPFlag("find.include-root", findCmd.Flags().Lookup("include-root"))
 	viper.BindPFlag("find.with-versions", findCmd.Flags().Lookup("with-versions"))
+	viper.BindPFlag("find.max-duration", findCmd.Flags().Lookup("max-duration"))
+	viper.BindPFlag("find.max-files", findCmd.Flags().Lookup("max-files"))
+	viper.BindPFlag("find.wait-for-root", findCmd.Flags().Lookup("wait-for-root"))
+	viper.BindPFlag("find.max-per-dir", findCmd.Flags().Lookup("max-per-dir"))
+	viper.BindPFlag("find.include-from", findCmd.Flags().Lookup("include-from"))
+	viper.BindPFlag("find.prune-unmodified-since", findCmd.Flags().Lookup("prune-unmodified-since"))
+	viper.BindPFlag("find.recursive-mtime-propagation", findCmd.Flags().Lookup("recursive-mtime-propagation"))
+	viper.BindPFlag("find.exit-nonzero-on-empty", findCmd.Flags().Lookup("exit-nonzero-on-empty"))
+	viper.BindPFlag("find.explain", findCmd.Flags().Lookup("explain"))
 	viper.BindPFlag("find.watch", findCmd.Flags().Lookup("watch"))
 	viper.BindPFlag("find.watch-events", findCmd.Flags().Lookup("watch-events"))
 }
 
-func runFind(root string) error {
+// runFind searches roots, each once, and reports one combined result.
+func runFind(roots []string) error {
 	// Create find options
 	opts := stride.FindOptions{
-		NamePattern:    viper.GetString("find.name"),
+		NamePatterns:   viper.GetStringSlice("find.name"),
 		PathPattern:    viper.GetString("find.path"),
-		IgnorePattern:  viper.GetString("find.ignore"),
+		IgnorePatterns: viper.GetStringSlice("find.ignore"),
+		SkipJunkFiles:  viper.GetBool("find.skip-junk"),
 		MaxDepth:       viper.GetUint("find.max-depth"),
 		FollowSymlinks: viper.GetBool("find.follow-symlinks"),
 		IncludeHidden:  viper.GetBool("find.include-hidden"),
+		IncludeRoot:    viper.GetBool("find.include-root"),
 		WithVersions:   viper.GetBool("find.with-versions"),
 		Watch:          viper.GetBool("find.watch"),
 		WatchEvents:    viper.GetStringSlice("find.watch-events"),
+		Empty:          viper.GetBool("find.empty"),
+		Types:          viper.GetStringSlice("find.type"),
+		HashList:       viper.GetString("find.hash-list"),
+		MaxFiles:       viper.GetInt64("find.max-files"),
+		MaxFilesPerDir: viper.GetInt("find.max-per-dir"),
+		OlderThanFile:  viper.GetString("find.older-than-file"),
+		NewerThanFile:  viper.GetString("find.newer-than-file"),
+
+		ContentMaxLines:    viper.GetInt("find.content-max-lines"),
+		IncludeBinary:      viper.GetBool("find.include-binary"),
+		OnlyBrokenSymlinks: viper.GetBool("find.broken-links"),
+		HasExtendedACL:     viper.GetBool("find.has-acl"),
+		LoadACLs:           viper.GetBool("find.show-acl"),
+
+		RecursiveMtimePropagation: viper.GetBool("find.recursive-mtime-propagation"),
+	}
+
+	if opts.Watch && len(roots) > 1 {
+		return errors.New("--watch takes a single path")
+	}
+	if opts.Watch && findOutput.path != "" {
+		return errors.New("--output cannot be used with --watch, which never completes")
+	}
+	protoFormat := viper.GetString("find.format") == "proto"
+	if protoFormat {
+		switch {
+		case viper.GetString("find.exec") != "" || len(viper.GetStringSlice("find.exec-route")) > 0:
+			return errors.New("--format proto cannot be used with --exec or --exec-route")
+		case opts.Watch:
+			return errors.New("--format proto cannot be used with --watch")
+		case findOutput.append:
+			return errors.New("--format proto cannot be used with --output-append")
+		}
+	}
+	if viper.GetBool("find.json") {
+		switch {
+		case viper.GetString("find.exec") != "" || len(viper.GetStringSlice("find.exec-route")) > 0:
+			return errors.New("--json cannot be used with --exec or --exec-route")
+		case viper.GetString("find.format") != "":
+			return errors.New("--json cannot be used with --format")
+		}
+	}
+	roots, err := stride.CanonicalRoots(roots, func(inner, outer string) {
+		fmt.Fprintf(os.Stderr, "%s is searched as part of %s\n", inner, outer)
+	})
+	if err != nil {
+		return err
+	}
+
+	if includeFrom := viper.GetString("find.include-from"); includeFrom != "" {
+		opts.IncludePaths, err = stride.LoadIncludePaths(includeFrom)
+		if err != nil {
+			return err
+		}
+	}
+
+	touchReference := viper.GetBool("find.touch-reference")
+	if touchReference && opts.NewerThanFile == "" {
+		return errors.New("--touch-reference requires --newer-than-file")
+	}
+
+	for _, fileType := range opts.Types {
+		switch fileType {
+		case "file", "dir", "symlink", "pipe", "socket", "device", "char":
+		default:
+			return fmt.Errorf("invalid type value: %q (want file, dir, symlink, pipe, socket, device or char)", fileType)
+		}
 	}
 
 	// Parse regex pattern
 	if regexStr := viper.GetString("find.regex"); regexStr != "" {
-		var err error
 		opts.RegexPattern, err = regexp.Compile(regexStr)
 		if err != nil {
 			return fmt.Errorf("invalid regex pattern: %w", err)
 		}
 	}
+	if contentStr := viper.GetString("find.content"); contentStr != "" {
+		opts.ContentPattern, err = regexp.Compile(contentStr)
+		if err != nil {
+			return fmt.Errorf("invalid content pattern: %w", err)
+		}
+	}
+	if maxBytesStr := viper.GetString("find.content-max-bytes"); maxBytesStr != "" {
+		opts.ContentMaxBytes, err = parseSize(maxBytesStr)
+		if err != nil {
+			return fmt.Errorf("invalid content-max-bytes value: %w", err)
+		}
+	}
 
 	// Parse time durations
 	if olderThanStr := viper.GetString("find.older-than"); olderThanStr != "" {
@@ -136,6 +301,28 @@ func runFind(root string) error {
 		opts.NewerThan = duration
 	}
 
+	if since := viper.GetString("find.prune-unmodified-since"); since != "" {
+		opts.PruneDirsUnmodifiedSince, err = parseSince(since, time.Now())
+		if err != nil {
+			return fmt.Errorf("invalid prune-unmodified-since value: %w", err)
+		}
+	}
+
+	if maxDurationStr := viper.GetString("find.max-duration"); maxDurationStr != "" {
+		duration, err := parseDuration(maxDurationStr)
+		if err != nil {
+			return fmt.Errorf("invalid max-duration value: %w", err)
+		}
+		opts.MaxDuration = duration
+	}
+	if wait := viper.GetString("find.wait-for-root"); wait != "" {
+		duration, err := parseDuration(wait)
+		if err != nil {
+			return fmt.Errorf("invalid wait-for-root value: %w", err)
+		}
+		opts.WaitForRoot = duration
+	}
+
 	// Parse size constraints
 	if largerThanStr := viper.GetString("find.larger-than"); largerThanStr != "" {
 		size, err := parseSize(largerThanStr)
@@ -153,6 +340,14 @@ func runFind(root string) error {
 		opts.SmallerSize = size
 	}
 
+	// Parse file flags
+	if opts.RequireFlags, err = stride.ParseFileFlags(viper.GetString("find.require-flags")); err != nil {
+		return fmt.Errorf("invalid require-flags value: %w", err)
+	}
+	if opts.ExcludeFlags, err = stride.ParseFileFlags(viper.GetString("find.exclude-flags")); err != nil {
+		return fmt.Errorf("invalid exclude-flags value: %w", err)
+	}
+
 	// Parse metadata and tag patterns
 	if metaPatterns := viper.GetStringSlice("find.meta"); len(metaPatterns) > 0 {
 		metaMap, err := parseKeyValuePatterns(metaPatterns)
@@ -176,32 +371,191 @@ func runFind(root string) error {
 		}
 	}
 
-	// Execute the find operation
-	ctx := context.Background()
+	// Parse hash list mode
+	switch hashListMode := viper.GetString("find.hash-list-mode"); hashListMode {
+	case "", "match":
+		opts.HashListMode = stride.HashListMatch
+	case "exclude":
+		opts.HashListMode = stride.HashListExclude
+	default:
+		return fmt.Errorf("invalid hash-list-mode: %s (expected match or exclude)", hashListMode)
+	}
+
+	execEnv := viper.GetBool("find.exec-env")
+	opts.ExecEnv = &execEnv
+	opts.ExecPrefixOutput = viper.GetBool("find.exec-prefix")
+	for _, route := range viper.GetStringSlice("find.exec-route") {
+		r, err := parseExecRoute(route)
+		if err != nil {
+			return err
+		}
+		opts.ExecRoutes = append(opts.ExecRoutes, r)
+	}
+
+	// Trace the evaluation of every entry
+	if viper.GetBool("find.explain") {
+		opts.Explain = true
+		opts.OnEvaluated = explainEvaluated(os.Stderr)
+	}
+
+	// Matches go to the output file, which is only replaced once every
+	// root has been searched
+	out, aw, err := findOutput.open()
+	if err != nil {
+		return err
+	}
+	if aw != nil {
+		defer aw.Close()
+	}
+	opts.Output = out
+	var protoWriter *stride.ProtoStreamWriter
+	if protoFormat {
+		protoWriter = stride.NewProtoStreamWriter(out)
+	}
+
+	// Execute the find operation on each root, adding up the summaries
+	var summary stride.FindSummary
+	opts.Summary = func(s stride.FindSummary) {
+		summary.Matches += s.Matches
+		summary.Stats.FilesProcessed += s.Stats.FilesProcessed
+		summary.Stats.DirsProcessed += s.Stats.DirsProcessed
+		summary.Stats.BytesProcessed += s.Stats.BytesProcessed
+		summary.Stats.ErrorCount += s.Stats.ErrorCount
+	}
+	started := time.Now()
+	for _, root := range roots {
+		if err = executeFind(context.Background(), root, opts, protoWriter); err != nil {
+			break
+		}
+	}
+	if protoWriter != nil {
+		if flushErr := protoWriter.Flush(); err == nil {
+			err = flushErr
+		}
+	}
+	if errors.Is(err, stride.ErrBudgetExceeded) {
+		// Report how far the search got, as the walk does
+		fmt.Fprintf(os.Stderr, "Stopped early; results are partial. Processed: %d files, %d dirs, %.2f MB, %d errors, %d matches\n",
+			summary.Stats.FilesProcessed,
+			summary.Stats.DirsProcessed,
+			float64(summary.Stats.BytesProcessed)/(1024*1024),
+			summary.Stats.ErrorCount,
+			summary.Matches)
+	}
+	if err != nil {
+		return err
+	}
+	if aw != nil {
+		if err := aw.Commit(); err != nil {
+			return fmt.Errorf("writing output: %w", err)
+		}
+	}
+	if err := walkStatus(summary.Stats.ErrorCount); err != nil {
+		return err
+	}
+
+	// Files modified while searching are found again by the next run
+	if touchReference {
+		if err := os.Chtimes(opts.NewerThanFile, started, started); err != nil {
+			return fmt.Errorf("touching reference file: %w", err)
+		}
+	}
+	if summary.Matches == 0 && viper.GetBool("find.exit-nonzero-on-empty") {
+		return &exitStatus{code: ExitNoMatch}
+	}
+	return nil
+}
+
+// explainEvaluated returns an OnEvaluated callback printing each decision to w.
+func explainEvaluated(w io.Writer) func(stride.FindMessage, stride.FindDecision) {
+	var mu sync.Mutex
+	return func(msg stride.FindMessage, decision stride.FindDecision) {
+		verdict := "rejected"
+		if decision.Matched {
+			verdict = "matched"
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		fmt.Fprintf(w, "%s %s: %s\n", verdict, msg.Path, decision)
+	}
+}
+
+// executeFind runs the search with the handler selected by the output flags,
+// writing matches to protoWriter if it is set.
+func executeFind(ctx context.Context, root string, opts stride.FindOptions, protoWriter *stride.ProtoStreamWriter) error {
+	// Routed commands take matches first, then the exec command, if any
+	if len(opts.ExecRoutes) > 0 {
+		opts.DefaultCmd = viper.GetString("find.exec")
+		return stride.FindWithExecRoutes(ctx, root, opts)
+	}
 
 	// If exec command is specified, use it
 	if execCmd := viper.GetString("find.exec"); execCmd != "" {
 		return stride.FindWithExec(ctx, root, opts, execCmd)
 	}
 
+	// Binary entries go straight to the output
+	if protoWriter != nil {
+		return stride.Find(ctx, root, opts, protoWriter.FindHandler(root))
+	}
+
+	// Each match as a line of JSON
+	if viper.GetBool("find.json") {
+		return stride.FindWithJSON(ctx, root, opts, opts.Output)
+	}
+
 	// If format is specified, use it
 	if format := viper.GetString("find.format"); format != "" {
 		return stride.FindWithFormat(ctx, root, opts, format)
 	}
 
-	// Otherwise, use default handler
+	// Otherwise, use the default handler, colored when enabled
+	c, err := newColorizer(findOutput.terminal())
+	if err != nil {
+		return err
+	}
+	if c.Enabled() {
+		return stride.Find(ctx, root, opts, colorFindHandler(c, opts))
+	}
 	return stride.Find(ctx, root, opts, nil)
 }
 
-// parseDuration parses a duration string with support for days (d)
+// parseExecRoute parses an --exec-route value, GLOB:COMMAND. The glob ends
+// at the first colon not escaped as \:; other backslashes are kept for
+// the glob, and the command is taken as written.
+func parseExecRoute(s string) (stride.ExecRoute, error) {
+	var glob strings.Builder
+	for i := 0; i < len(s); i++ {
+		switch {
+		case s[i] == '\\' && i+1 < len(s) && s[i+1] == ':':
+			glob.WriteByte(':')
+			i++
+		case s[i] == ':':
+			route := stride.ExecRoute{Match: glob.String(), CmdTemplate: s[i+1:]}
+			if route.Match == "" || strings.TrimSpace(route.CmdTemplate) == "" {
+				return stride.ExecRoute{}, fmt.Errorf("invalid exec route %q (expected GLOB:COMMAND)", s)
+			}
+			return route, nil
+		default:
+			glob.WriteByte(s[i])
+		}
+	}
+	return stride.ExecRoute{}, fmt.Errorf("invalid exec route %q (expected GLOB:COMMAND)", s)
+}
+
+// parseDuration parses a duration string with support for days (d) and
+// 365-day years (y)
 func parseDuration(s string) (time.Duration, error) {
-	// Handle days specially
-	if strings.HasSuffix(s, "d") {
-		days, err := parseFloat(s[:len(s)-1])
+	// Handle days and years specially
+	if strings.HasSuffix(s, "d") || strings.HasSuffix(s, "y") {
+		n, err := parseFloat(s[:len(s)-1])
 		if err != nil {
 			return 0, err
 		}
-		return time.Duration(days * 24 * float64(time.Hour)), nil
+		if strings.HasSuffix(s, "y") {
+			n *= 365
+		}
+		return time.Duration(n * 24 * float64(time.Hour)), nil
 	}
 
 	// Use standard duration parsing for other units
@@ -0,0 +1,422 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	stride "github.com/TFMV/stride/internal/walk"
+	"github.com/TFMV/stride/proto/stridepb"
+)
+
+func TestFindTouchReference(t *testing.T) {
+	root := t.TempDir()
+	for _, name := range []string{"a.txt", "b.txt"} {
+		if err := os.WriteFile(filepath.Join(root, name), []byte(name), 0644); err != nil {
+			t.Fatalf("Failed to create file: %v", err)
+		}
+	}
+	marker := filepath.Join(t.TempDir(), "marker")
+	if err := os.WriteFile(marker, nil, 0644); err != nil {
+		t.Fatalf("Failed to create marker: %v", err)
+	}
+	old := time.Now().Add(-time.Hour).Truncate(time.Second)
+	setMarker := func() {
+		if err := os.Chtimes(marker, old, old); err != nil {
+			t.Fatalf("Failed to set times: %v", err)
+		}
+	}
+	markerTouched := func() bool {
+		info, err := os.Stat(marker)
+		if err != nil {
+			t.Fatalf("Failed to stat marker: %v", err)
+		}
+		return !info.ModTime().Equal(old)
+	}
+
+	// A dangling link cannot be followed, so the search fails part way
+	broken := t.TempDir()
+	if err := os.Symlink(filepath.Join(broken, "missing"), filepath.Join(broken, "dangling")); err != nil {
+		t.Skipf("Symlinks not supported: %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		args    []string
+		code    int
+		touched bool
+	}{
+		{"success", []string{"find", root, "--newer-than-file=" + marker, "--touch-reference"}, ExitOK, true},
+		{"no matches", []string{"find", root, "--name=*.md", "--newer-than-file=" + marker, "--touch-reference"}, ExitOK, true},
+		{"path errors", []string{"find", broken, "--follow-symlinks", "--newer-than-file=" + marker, "--touch-reference"}, ExitPathError, false},
+		{"truncated", []string{"find", root, "--max-files=1", "--newer-than-file=" + marker, "--touch-reference"}, ExitTruncated, false},
+		{"without reference", []string{"find", root, "--touch-reference"}, ExitFatal, false},
+		{"missing reference", []string{"find", root, "--newer-than-file=" + marker + ".missing", "--touch-reference"}, ExitFatal, false},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			setMarker()
+			if code := runStride(t, tc.args...); code != tc.code {
+				t.Errorf("Expected exit status %d, got %d", tc.code, code)
+			}
+			if got := markerTouched(); got != tc.touched {
+				t.Errorf("Expected the marker touched to be %v, got %v", tc.touched, got)
+			}
+		})
+	}
+}
+
+func TestBrokenLinks(t *testing.T) {
+	root := t.TempDir()
+	if err := os.WriteFile(filepath.Join(root, "data.txt"), []byte("data"), 0644); err != nil {
+		t.Fatalf("Failed to create file: %v", err)
+	}
+	links := []struct{ link, target string }{
+		{"valid", "data.txt"},
+		{"dangling", "missing.txt"},
+		{"chain", "hop1"}, // Its second hop is missing
+		{"hop1", "hop2"},
+	}
+	for _, l := range links {
+		if err := os.Symlink(l.target, filepath.Join(root, l.link)); err != nil {
+			t.Skipf("Symlinks not supported: %v", err)
+		}
+	}
+
+	tests := []struct {
+		name     string
+		args     []string
+		expected []string
+	}{
+		{"find template", []string{"find", root, "--broken-links", "--format={base} {target}"}, []string{"chain hop1", "dangling missing.txt", "hop1 hop2"}},
+		{"long output", []string{root, "--broken-links", "--format=long"}, []string{"chain -> hop1", "dangling -> missing.txt", "hop1 -> hop2"}},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			out, code := runStrideOutput(t, tc.args...)
+			if code != ExitOK {
+				t.Fatalf("Expected exit status %d, got %d:\n%s", ExitOK, code, out)
+			}
+			var got []string
+			for _, line := range strings.Split(strings.TrimSpace(out), "\n") {
+				for _, want := range tc.expected {
+					if strings.HasSuffix(line, want) {
+						got = append(got, want)
+					}
+				}
+				if strings.Contains(line, "valid") || strings.Contains(line, "data.txt") {
+					t.Errorf("Expected only broken links, got %q", line)
+				}
+			}
+			sort.Strings(got)
+			if strings.Join(got, ",") != strings.Join(tc.expected, ",") {
+				t.Errorf("Expected lines ending in %v, got:\n%s", tc.expected, out)
+			}
+		})
+	}
+}
+
+func TestFormatProto(t *testing.T) {
+	root := t.TempDir()
+	for _, name := range []string{"a.txt", "b.go", filepath.Join("sub", "c.txt")} {
+		if err := os.MkdirAll(filepath.Dir(filepath.Join(root, name)), 0755); err != nil {
+			t.Fatalf("Failed to create directory: %v", err)
+		}
+		if err := os.WriteFile(filepath.Join(root, name), []byte(name), 0644); err != nil {
+			t.Fatalf("Failed to create file: %v", err)
+		}
+	}
+
+	tests := []struct {
+		name     string
+		args     []string
+		expected []string
+	}{
+		{"walk", []string{root, "--format=proto"}, []string{"a.txt 1", "b.go 1", "sub/c.txt 2"}},
+		{"find", []string{"find", root, "--name=*.txt", "--max-depth=2", "--format=proto"}, []string{"a.txt 1", "sub/c.txt 2"}},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			output := filepath.Join(t.TempDir(), "entries.bin")
+			out, code := runStrideOutput(t, append(tc.args, "--output="+output)...)
+			if code != ExitOK {
+				t.Fatalf("Expected exit status %d, got %d:\n%s", ExitOK, code, out)
+			}
+			f, err := os.Open(output)
+			if err != nil {
+				t.Fatalf("Failed to open output: %v", err)
+			}
+			defer f.Close()
+			var got []string
+			err = stride.ReadProtoStream(f, func(entry *stridepb.Entry) error {
+				if entry.Mode&0o170000 == 0o100000 {
+					rel, _ := filepath.Rel(root, entry.Path)
+					got = append(got, fmt.Sprintf("%s %d", filepath.ToSlash(rel), entry.Depth))
+				}
+				return nil
+			})
+			if err != nil {
+				t.Fatalf("Failed to read output: %v", err)
+			}
+			sort.Strings(got)
+			if strings.Join(got, ",") != strings.Join(tc.expected, ",") {
+				t.Errorf("Expected files %v, got %v", tc.expected, got)
+			}
+		})
+	}
+
+	// Binary output cannot be mixed with text
+	if code := runStride(t, root, "--format=proto", "--progress"); code == ExitOK {
+		t.Error("Expected --format=proto with --progress to fail")
+	}
+}
+
+func TestParseExecRoute(t *testing.T) {
+	tests := []struct {
+		in      string
+		match   string
+		command string
+		wantErr bool
+	}{
+		{in: "*.png:convert {} {}.jpg", match: "*.png", command: "convert {} {}.jpg"},
+		{in: "*.log:echo a:b", match: "*.log", command: "echo a:b"},
+		{in: `c\:*.txt:type {}`, match: "c:*.txt", command: "type {}"},
+		{in: `\[x\]*:echo {}`, match: `\[x\]*`, command: "echo {}"},
+		{in: "*.png", wantErr: true},
+		{in: ":echo {}", wantErr: true},
+		{in: "*.png: ", wantErr: true},
+		{in: `*.png\:echo`, wantErr: true},
+	}
+	for _, tc := range tests {
+		route, err := parseExecRoute(tc.in)
+		if tc.wantErr {
+			if err == nil {
+				t.Errorf("Expected an error for %q, got %+v", tc.in, route)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Failed to parse %q: %v", tc.in, err)
+			continue
+		}
+		if route.Match != tc.match || route.CmdTemplate != tc.command {
+			t.Errorf("Expected %q and %q for %q, got %q and %q", tc.match, tc.command, tc.in, route.Match, route.CmdTemplate)
+		}
+	}
+}
+
+func TestExecRoutes(t *testing.T) {
+	root := t.TempDir()
+	for _, name := range []string{"a.png", "app.log", "notes.txt"} {
+		if err := os.WriteFile(filepath.Join(root, name), []byte(name), 0644); err != nil {
+			t.Fatalf("Failed to create file: %v", err)
+		}
+	}
+	out := t.TempDir()
+	touch := func(suffix string) string { return "touch " + filepath.Join(out, "{base}."+suffix) }
+
+	args := []string{"find", root, "--exec-route=*.png:" + touch("converted"), "--exec-route=*.log:" + touch("gzipped")}
+	if output, code := runStrideOutput(t, args...); code != ExitOK {
+		t.Fatalf("Expected exit status %d, got %d:\n%s", ExitOK, code, output)
+	}
+	entries, err := os.ReadDir(out)
+	if err != nil {
+		t.Fatalf("Failed to read output: %v", err)
+	}
+	var got []string
+	for _, e := range entries {
+		got = append(got, e.Name())
+	}
+	sort.Strings(got)
+	if want := "a.png.converted,app.log.gzipped"; strings.Join(got, ",") != want {
+		t.Errorf("Expected %s, got %v", want, got)
+	}
+}
+
+func TestFindWaitForRoot(t *testing.T) {
+	staging := filepath.Join(t.TempDir(), "staging")
+	if err := os.Mkdir(staging, 0755); err != nil {
+		t.Fatalf("Failed to create directory: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(staging, "a.txt"), []byte("a"), 0644); err != nil {
+		t.Fatalf("Failed to create file: %v", err)
+	}
+	root := filepath.Join(t.TempDir(), "later")
+	created := make(chan error, 1)
+	time.AfterFunc(200*time.Millisecond, func() {
+		created <- os.Rename(staging, root)
+	})
+
+	output, code := runStrideOutput(t, "find", root, "--name=*.txt", "--wait-for-root=10s")
+	if err := <-created; err != nil {
+		t.Fatalf("Failed to create the root: %v", err)
+	}
+	if code != ExitOK {
+		t.Fatalf("Expected exit status %d, got %d:\n%s", ExitOK, code, output)
+	}
+	if !strings.Contains(output, filepath.Join(root, "a.txt")) {
+		t.Errorf("Expected %s in the output, got:\n%s", filepath.Join(root, "a.txt"), output)
+	}
+}
+
+func TestFindType(t *testing.T) {
+	root := t.TempDir()
+	for _, dir := range []string{"src/pkg", "docs"} {
+		if err := os.MkdirAll(filepath.Join(root, dir), 0755); err != nil {
+			t.Fatalf("Failed to create directory: %v", err)
+		}
+	}
+	for _, file := range []string{"main.go", "src/util.go", "docs/guide.md"} {
+		if err := os.WriteFile(filepath.Join(root, file), []byte("x"), 0644); err != nil {
+			t.Fatalf("Failed to create file: %v", err)
+		}
+	}
+	if err := os.Symlink("main.go", filepath.Join(root, "link.go")); err != nil {
+		t.Skipf("Symlinks not supported: %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		args     []string
+		expected []string
+	}{
+		{"directories", []string{"--type=dir", "--max-depth=2"}, []string{"docs", "pkg", "src"}},
+		{"directories not ignored", []string{"--type=dir", "--max-depth=2", "--ignore=*/docs"}, []string{"pkg", "src"}},
+		{"symlinks", []string{"--type=symlink"}, []string{"link.go"}},
+		{"files and symlinks", []string{"--type=file,symlink", "--name=*.go"}, []string{"link.go", "main.go"}},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			args := append([]string{"find", root, "--format={base}"}, tc.args...)
+			out, code := runStrideOutput(t, args...)
+			if code != ExitOK {
+				t.Fatalf("Expected exit status %d, got %d:\n%s", ExitOK, code, out)
+			}
+			got := strings.Fields(out)
+			sort.Strings(got)
+			if strings.Join(got, ",") != strings.Join(tc.expected, ",") {
+				t.Errorf("Expected %v, got:\n%s", tc.expected, out)
+			}
+		})
+	}
+
+	if out, code := runStrideOutput(t, "find", root, "--type=folder"); code != ExitFatal || !strings.Contains(out, "invalid type value") {
+		t.Errorf("Expected exit status %d for an invalid type, got %d:\n%s", ExitFatal, code, out)
+	}
+}
+
+func TestFindNamePatternsWithCommas(t *testing.T) {
+	root := t.TempDir()
+	for _, file := range []string{"a,b.txt", "b.txt", "c.md", "skip,me.md"} {
+		if err := os.WriteFile(filepath.Join(root, file), []byte("x"), 0644); err != nil {
+			t.Fatalf("Failed to create file: %v", err)
+		}
+	}
+
+	// Each --name and --ignore is one whole pattern, commas included
+	tests := []struct {
+		name     string
+		args     []string
+		expected []string
+	}{
+		{"name with a comma", []string{"--name=a,b.txt"}, []string{"a,b.txt"}},
+		{"repeated names", []string{"--name=*,*.txt", "--name=c.md"}, []string{"a,b.txt", "c.md"}},
+		{"ignore with a comma", []string{"--name=*.md", "--ignore=*/skip,me.md"}, []string{"c.md"}},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			args := append([]string{"find", root, "--format={base}"}, tc.args...)
+			out, code := runStrideOutput(t, args...)
+			if code != ExitOK {
+				t.Fatalf("Expected exit status %d, got %d:\n%s", ExitOK, code, out)
+			}
+			got := strings.Fields(out)
+			sort.Strings(got)
+			if strings.Join(got, " ") != strings.Join(tc.expected, " ") {
+				t.Errorf("Expected %v, got:\n%s", tc.expected, out)
+			}
+		})
+	}
+}
+
+func TestFindJSON(t *testing.T) {
+	root := t.TempDir()
+	for _, name := range []string{"a.go", "b.go", "c.txt"} {
+		if err := os.WriteFile(filepath.Join(root, name), []byte(name), 0644); err != nil {
+			t.Fatalf("Failed to create file: %v", err)
+		}
+	}
+
+	out, code := runStrideOutput(t, "find", root, "--name=*.go", "--json")
+	if code != ExitOK {
+		t.Fatalf("Expected exit status %d, got %d:\n%s", ExitOK, code, out)
+	}
+	var got []string
+	for _, line := range strings.Split(strings.TrimSpace(out), "\n") {
+		var match struct {
+			Path string `json:"path"`
+			Name string `json:"name"`
+			Size int64  `json:"size"`
+		}
+		if err := json.Unmarshal([]byte(line), &match); err != nil {
+			t.Fatalf("Failed to parse %q: %v", line, err)
+		}
+		if match.Path != filepath.Join(root, match.Name) || match.Size != 4 {
+			t.Errorf("Unexpected match: %+v", match)
+		}
+		got = append(got, match.Name)
+	}
+	sort.Strings(got)
+	if strings.Join(got, ",") != "a.go,b.go" {
+		t.Errorf("Expected a.go and b.go, got %v", got)
+	}
+
+	if out, code := runStrideOutput(t, "find", root, "--json", "--format={base}"); code != ExitFatal || !strings.Contains(out, "--json cannot be used with --format") {
+		t.Errorf("Expected exit status %d for --json with --format, got %d:\n%s", ExitFatal, code, out)
+	}
+}
+
+func TestFindContent(t *testing.T) {
+	root := t.TempDir()
+	files := map[string]string{
+		"a.go":  "package a\n// TODO: tidy\n",
+		"b.go":  "package b\n",
+		"c.bin": "\x00TODO\n",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(root, name), []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to create file: %v", err)
+		}
+	}
+
+	tests := []struct {
+		name     string
+		args     []string
+		expected string
+	}{
+		{"matching lines", []string{"--content=TODO", "--format={base}: {matches}"}, "a.go: // TODO: tidy"},
+		{"binary included", []string{"--content=TODO", "--include-binary", "--name=*.bin", "--format={base}"}, "c.bin"},
+		{"content capped", []string{"--content=TODO", "--content-max-bytes=10", "--format={base}"}, ""},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			args := append([]string{"find", root}, tc.args...)
+			out, code := runStrideOutput(t, args...)
+			if code != ExitOK {
+				t.Fatalf("Expected exit status %d, got %d:\n%s", ExitOK, code, out)
+			}
+			if got := strings.TrimSpace(out); got != tc.expected {
+				t.Errorf("Expected %q, got %q", tc.expected, got)
+			}
+		})
+	}
+
+	if out, code := runStrideOutput(t, "find", root, "--content=("); code != ExitFatal || !strings.Contains(out, "invalid content pattern") {
+		t.Errorf("Expected exit status %d for an invalid pattern, got %d:\n%s", ExitFatal, code, out)
+	}
+}
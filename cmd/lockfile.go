@@ -0,0 +1,34 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"syscall"
+
+	"github.com/TFMV/stride/internal/lockfile"
+)
+
+// shutdownSignals stop long-running commands such as watch and serve
+// gracefully, as sent by Ctrl+C or a service manager.
+var shutdownSignals = []os.Signal{os.Interrupt, syscall.SIGTERM}
+
+// lockInstance takes the lock file at path for a long-running command, so
+// that a second instance on the same tree refuses to start. It returns a nil
+// lock, which releases as a no-op, if path is empty.
+func lockInstance(path string) (*lockfile.Lock, error) {
+	if path == "" {
+		return nil, nil
+	}
+	lock, err := lockfile.Acquire(path)
+	if errors.Is(err, lockfile.ErrLocked) {
+		return nil, fmt.Errorf("another instance is running: %w", err)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if lock.StalePID != 0 {
+		fmt.Fprintf(os.Stderr, "Taking over %s from process %d, which is no longer running\n", path, lock.StalePID)
+	}
+	return lock, nil
+}
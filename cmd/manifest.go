@@ -0,0 +1,121 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	stride "github.com/TFMV/stride/internal/walk"
+	"github.com/spf13/cobra"
+)
+
+var (
+	// Manifest command options
+	manifestOutput      outputFlags
+	manifestJobs        int
+	manifestIgnoreMtime bool
+)
+
+// manifestCmd groups the manifest subcommands
+var manifestCmd = &cobra.Command{
+	Use:   "manifest",
+	Short: "Create and verify checksum manifests of a directory tree",
+	Long: `Snapshot the structure and file hashes of a directory tree into a manifest,
+and later verify the tree against it, in the spirit of mtree(8).
+
+A manifest lists every entry beneath the root, sorted by path, with its type,
+mode, size, modification time and the SHA-256 digest of each file. Symbolic
+links are recorded with their targets rather than followed.
+
+Examples:
+  stride manifest create /archive > archive.manifest
+  stride manifest verify /archive archive.manifest`,
+}
+
+// manifestCreateCmd writes a manifest
+var manifestCreateCmd = &cobra.Command{
+	Use:   "create [options] <path>",
+	Short: "Write a manifest of a directory tree",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runManifestCreate(args[0])
+	},
+}
+
+// manifestVerifyCmd checks a tree against a manifest
+var manifestVerifyCmd = &cobra.Command{
+	Use:   "verify [options] <path> <manifest>",
+	Short: "Verify a directory tree against a manifest",
+	Long: `Verify a directory tree against a manifest written by "stride manifest create".
+Every entry that differs is listed as modified (with the fields that differ),
+missing or extra. The manifest is read from standard input if it is "-".
+
+The command exits with status 1 if the tree does not match the manifest.`,
+	Args: cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return commandResult(cmd, runManifestVerify(args[0], args[1]))
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(manifestCmd)
+	manifestCmd.AddCommand(manifestCreateCmd, manifestVerifyCmd)
+
+	manifestCmd.PersistentFlags().IntVar(&manifestJobs, "jobs", 0, "Number of files hashed concurrently (0 for the number of CPUs)")
+	manifestCreateCmd.Flags().StringVarP(&manifestOutput.path, "output", "o", "", "Write the manifest to a file instead of standard output, replacing it only once complete")
+	manifestVerifyCmd.Flags().BoolVar(&manifestIgnoreMtime, "ignore-mtime", false, "Do not compare modification times")
+}
+
+func manifestOptions() stride.ManifestOptions {
+	return stride.ManifestOptions{
+		IgnoreMtime: manifestIgnoreMtime,
+		Jobs:        manifestJobs,
+	}
+}
+
+func runManifestCreate(root string) error {
+	out, aw, err := manifestOutput.open()
+	if err != nil {
+		return err
+	}
+	if aw == nil {
+		return stride.WriteManifest(context.Background(), root, out, manifestOptions())
+	}
+	defer aw.Close()
+	if err := stride.WriteManifest(context.Background(), root, out, manifestOptions()); err != nil {
+		return err
+	}
+	return aw.Commit()
+}
+
+func runManifestVerify(root, manifest string) error {
+	var r io.Reader = os.Stdin
+	if manifest != "-" {
+		f, err := os.Open(manifest)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		r = f
+	}
+
+	report, err := stride.VerifyManifest(context.Background(), root, r, manifestOptions())
+	if err != nil {
+		return err
+	}
+	for _, change := range report.Changes {
+		if len(change.Reasons) > 0 {
+			fmt.Printf("%s: %s (%s)\n", change.Status, change.Path, strings.Join(change.Reasons, ", "))
+		} else {
+			fmt.Printf("%s: %s\n", change.Status, change.Path)
+		}
+	}
+	fmt.Fprintf(os.Stderr, "%d ok, %d changed\n", report.OK, len(report.Changes))
+
+	if !report.Clean() {
+		return &exitStatus{code: ExitNoMatch}
+	}
+	return nil
+}
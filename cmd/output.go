@@ -0,0 +1,56 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/TFMV/stride/internal/output"
+	"github.com/spf13/cobra"
+)
+
+// outputFlags holds the flags of a command that can write its results to a
+// file instead of standard output.
+type outputFlags struct {
+	path   string
+	append bool
+	sync   string
+}
+
+// add registers the output flags on cmd, the file flag under name.
+func (o *outputFlags) add(cmd *cobra.Command, name string) {
+	cmd.Flags().StringVar(&o.path, name, "", "Write results to this file, replacing it only once the command succeeds")
+	cmd.Flags().BoolVar(&o.append, "output-append", false, "Append results to the output file, ending a successful run with a {\"complete\":true} record")
+	cmd.Flags().StringVar(&o.sync, "output-sync", "", "Sync the output file to disk this often while writing (e.g. 10s, 1m)")
+}
+
+// open returns the writer for results: standard output if no file is set,
+// and otherwise an AtomicWriter the caller must commit once it succeeds and
+// close in any case.
+func (o *outputFlags) open() (io.Writer, *output.AtomicWriter, error) {
+	if o.path == "" {
+		return os.Stdout, nil, nil
+	}
+	opts := output.Options{Append: o.append}
+	if o.sync != "" {
+		interval, err := parseDuration(o.sync)
+		if err != nil {
+			return nil, nil, fmt.Errorf("invalid output-sync value: %w", err)
+		}
+		opts.SyncInterval = interval
+	}
+	w, err := output.Create(o.path, opts)
+	if err != nil {
+		return nil, nil, err
+	}
+	return w, w, nil
+}
+
+// terminal returns the file results go to if it may be a terminal, for
+// deciding whether to color them, or nil if they go to an output file.
+func (o *outputFlags) terminal() *os.File {
+	if o.path != "" {
+		return nil
+	}
+	return os.Stdout
+}
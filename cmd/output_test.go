@@ -0,0 +1,77 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestOutputFile(t *testing.T) {
+	root := t.TempDir()
+	for _, name := range []string{"a.txt", "b.txt", "c.txt"} {
+		if err := os.WriteFile(filepath.Join(root, name), []byte(name), 0644); err != nil {
+			t.Fatalf("Failed to create file: %v", err)
+		}
+	}
+	out := filepath.Join(t.TempDir(), "out.txt")
+
+	tests := []struct {
+		name     string
+		args     []string
+		code     int
+		contains []string // Lines expected in the file; nil if unchanged
+		footer   bool
+	}{
+		{"find truncated", []string{"find", root, "--name=*.txt", "--max-files=1", "--output=" + out}, ExitTruncated, nil, false},
+		{"find", []string{"find", root, "--name=*.txt", "--output=" + out}, ExitOK, []string{"a.txt", "b.txt", "c.txt"}, false},
+		{"find append", []string{"find", root, "--name=a.txt", "--output=" + out, "--output-append", "--output-sync=1s"}, ExitOK, []string{"a.txt"}, true},
+		{"root truncated", []string{root, "--max-files=1", "--output=" + out}, ExitTruncated, nil, false},
+		{"root", []string{root, "--output=" + out, "--format=json"}, ExitOK, []string{`a.txt"`, `b.txt"`, `c.txt"`}, false},
+		{"bad sync interval", []string{"find", root, "--output=" + out, "--output-sync=soon"}, ExitFatal, nil, false},
+		{"watch", []string{"find", root, "--output=" + out, "--watch"}, ExitFatal, nil, false},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			const previous = "previous\n"
+			if err := os.WriteFile(out, []byte(previous), 0644); err != nil {
+				t.Fatalf("Failed to create output file: %v", err)
+			}
+			if code := runStride(t, tc.args...); code != tc.code {
+				t.Errorf("Expected exit status %d, got %d", tc.code, code)
+			}
+
+			data, err := os.ReadFile(out)
+			if err != nil {
+				t.Fatalf("Failed to read output file: %v", err)
+			}
+			got := string(data)
+			if tc.contains == nil {
+				if got != previous {
+					t.Errorf("Expected the output file unchanged, got %q", got)
+				}
+				return
+			}
+			for _, s := range tc.contains {
+				if !strings.Contains(got, s) {
+					t.Errorf("Expected %q in the output file, got %q", s, got)
+				}
+			}
+			if strings.HasPrefix(got, previous) != tc.footer {
+				t.Errorf("Expected the previous contents kept only in append mode, got %q", got)
+			}
+			if hasFooter := strings.HasSuffix(got, "{\"complete\":true,\"records\":1}\n"); hasFooter != tc.footer {
+				t.Errorf("Expected a footer to be %v, got %q", tc.footer, got)
+			}
+		})
+	}
+
+	// No temporary files are left behind
+	entries, err := os.ReadDir(filepath.Dir(out))
+	if err != nil {
+		t.Fatalf("Failed to read directory: %v", err)
+	}
+	if len(entries) != 1 {
+		t.Errorf("Expected only the output file, found %d entries", len(entries))
+	}
+}
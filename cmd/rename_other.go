@@ -0,0 +1,9 @@
+//go:build !darwin && !linux
+
+package cmd
+
+// renameNoReplace renames oldpath to newpath with linkRename, as this
+// platform has no rename that refuses to replace its target.
+func renameNoReplace(oldpath, newpath string) error {
+	return linkRename(oldpath, newpath)
+}
@@ -0,0 +1,91 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+
+	stride "github.com/TFMV/stride/internal/walk"
+	"github.com/spf13/cobra"
+)
+
+var (
+	// Retain command options
+	retainPattern   string
+	retainOlderThan string
+	retainKeep      int
+	retainMinKeep   int
+	retainDelete    bool
+	retainDryRun    bool
+	retainHidden    bool
+)
+
+// retainCmd represents the retain command
+var retainCmd = &cobra.Command{
+	Use:   "retain [options] <path>",
+	Short: "Apply a retention policy to matching files",
+	Long: `Apply a retention policy to files matching a pattern. Matches older than
+--older-than are removed, except the newest --keep files in each directory and
+at least --min-keep files overall. Without --delete, the files that would be
+removed are only listed.
+
+Examples:
+  stride retain --pattern="*.log" --older-than=30d --keep=5 /var/log/app
+  stride retain --pattern="*.tar.gz" --older-than=7d --min-keep=3 --delete /backups`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runRetain(args[0])
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(retainCmd)
+
+	retainCmd.Flags().StringVar(&retainPattern, "pattern", "", "File name pattern to apply the policy to (e.g. \"*.log\")")
+	retainCmd.Flags().StringVar(&retainOlderThan, "older-than", "", "Only remove files older than this duration (e.g. 30d, 12h)")
+	retainCmd.Flags().IntVar(&retainKeep, "keep", 0, "Newest matching files to keep in each directory")
+	retainCmd.Flags().IntVar(&retainMinKeep, "min-keep", 0, "Minimum matching files to keep overall")
+	retainCmd.Flags().BoolVar(&retainDelete, "delete", false, "Delete the files not kept by the policy")
+	retainCmd.Flags().BoolVar(&retainDryRun, "dry-run", false, "List the files that would be deleted (default)")
+	retainCmd.Flags().BoolVar(&retainHidden, "include-hidden", false, "Include hidden files")
+}
+
+func runRetain(root string) error {
+	if retainPattern == "" {
+		return errors.New("--pattern is required")
+	}
+	if retainDelete && retainDryRun {
+		return errors.New("--delete and --dry-run are mutually exclusive")
+	}
+
+	policy := stride.RetentionPolicy{
+		Pattern:          retainPattern,
+		KeepNewestPerDir: retainKeep,
+		MinTotalKeep:     retainMinKeep,
+		IncludeHidden:    retainHidden,
+	}
+	if retainOlderThan != "" {
+		duration, err := parseDuration(retainOlderThan)
+		if err != nil {
+			return fmt.Errorf("invalid older-than value: %w", err)
+		}
+		policy.OlderThan = duration
+	}
+
+	action := func(ctx context.Context, path string, info os.FileInfo) error {
+		fmt.Printf("would delete: %s\n", path)
+		return nil
+	}
+	if retainDelete {
+		action = func(ctx context.Context, path string, info os.FileInfo) error {
+			if err := os.Remove(path); err != nil {
+				return err
+			}
+			fmt.Printf("deleted: %s\n", path)
+			return nil
+		}
+	}
+
+	return stride.ApplyRetention(context.Background(), root, policy, action)
+}
@@ -12,9 +12,12 @@ package cmd
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
+	"regexp"
 	"strconv"
 	"strings"
 	"time"
@@ -27,11 +30,14 @@ import (
 var (
 	cfgFile string
 	version = "0.1.0"
+
+	// rootOutput is where the root command writes the files it walks
+	rootOutput outputFlags
 )
 
 // rootCmd represents the base command when called without any subcommands
 var rootCmd = &cobra.Command{
-	Use:   "stride [options] <path>",
+	Use:   "stride [options] <path>...",
 	Short: "A high-performance file walking utility",
 	Long: `stride is a command line utility for high-performance filesystem traversal.
 It supports concurrent processing, filtering, and real-time progress monitoring.
@@ -39,14 +45,20 @@ It supports concurrent processing, filtering, and real-time progress monitoring.
 Example:
   stride /path/to/directory                    # Basic usage
   stride --pattern="*.go" --workers=8 /src     # Find Go files using 8 workers
-  stride --follow-symlinks --progress /data    # Follow symlinks with progress`,
+  stride --follow-symlinks --progress /data    # Follow symlinks with progress
+  stride --pattern="*.log" /data/a /data/b     # Walk several roots as one
+
+Exit status:
+  0  the walk completed (for find, something matched)
+  1  find --exit-nonzero-on-empty matched nothing, manifest verify
+     found changes, or check-filter excluded a path
+  2  the walk completed, but some paths could not be processed
+  3  the command failed or the walk was aborted
+  4  --max-duration or --max-files stopped the walk early`,
 	Version: version,
 	Args: func(cmd *cobra.Command, args []string) error {
 		if len(args) < 1 {
-			return fmt.Errorf("missing required argument: path\n\nUsage: stride <path>\nExample: stride /path/to/directory")
-		}
-		if len(args) > 1 {
-			return fmt.Errorf("too many arguments: expected 1, got %d\n\nUsage: stride <path>", len(args))
+			return fmt.Errorf("missing required argument: path\n\nUsage: stride <path>...\nExample: stride /path/to/directory")
 		}
 		return nil
 	},
@@ -68,8 +80,7 @@ Example:
 		}
 	},
 	RunE: func(cmd *cobra.Command, args []string) error {
-		path := args[0]
-		return runFileWalker(path)
+		return commandResult(cmd, runFileWalker(args))
 	},
 }
 
@@ -83,67 +94,138 @@ func init() {
 
 	// Flags
 	rootCmd.Flags().StringP("workers", "w", "4", "Number of concurrent workers")
-	rootCmd.Flags().BoolP("verbose", "v", false, "Enable verbose logging")
+	rootCmd.Flags().BoolP("verbose", "v", false, "Enable verbose logging and list the largest, oldest and newest files and deepest and longest paths")
 	rootCmd.Flags().Bool("silent", false, "Disable all output except errors")
-	rootCmd.Flags().String("format", "text", "Output format (text|json)")
-	rootCmd.Flags().String("min-size", "", "Minimum file size to process")
-	rootCmd.Flags().String("max-size", "", "Maximum file size to process")
-	rootCmd.Flags().String("pattern", "", "File pattern to match")
+	rootCmd.Flags().String("format", "text", "Output format (text|json|long|proto); proto writes length-prefixed protobuf entries (see proto/entry.proto)")
+	rootCmd.Flags().String("template", "", "Output each file with a template, with {} placeholders or find -printf directives (e.g. '%M %u %s %p\\n')")
 	rootCmd.PersistentFlags().String("exclude-dir", "", "Directories to exclude (comma-separated)")
-	rootCmd.Flags().String("exclude-pattern", "", "Patterns to exclude files (comma-separated)")
-	rootCmd.Flags().String("file-types", "", "File types to include (comma-separated: file,dir,symlink,pipe,socket,device,char)")
+	rootCmd.PersistentFlags().StringArray("exclude-dir-regex", nil, "Regex matched against root-relative directory paths to exclude (repeatable)")
+	rootCmd.PersistentFlags().String("color", "auto", "Color output by file type (auto|always|never)")
 	rootCmd.Flags().Bool("follow-symlinks", false, "Follow symbolic links")
+	rootCmd.Flags().Bool("follow-internal-symlinks", false, "Follow only symbolic links whose targets stay inside the root")
 	rootCmd.Flags().Bool("progress", false, "Show progress updates")
+	rootCmd.Flags().Bool("include-root", true, "Report the root itself, regardless of --min-depth")
 	rootCmd.Flags().String("error-mode", "continue", "Error handling mode (continue|stop|skip)")
-	rootCmd.Flags().String("min-permissions", "", "Minimum file permissions (octal, e.g. 0644)")
-	rootCmd.Flags().String("max-permissions", "", "Maximum file permissions (octal, e.g. 0755)")
-	rootCmd.Flags().String("exact-permissions", "", "Exact file permissions to match (octal, e.g. 0644)")
-	rootCmd.Flags().String("owner", "", "Filter by owner username")
-	rootCmd.Flags().String("group", "", "Filter by group name")
-	rootCmd.Flags().Int("owner-uid", 0, "Filter by owner UID")
-	rootCmd.Flags().Int("owner-gid", 0, "Filter by group GID")
-	rootCmd.Flags().Int("min-depth", 0, "Minimum directory depth to process")
-	rootCmd.Flags().Int("max-depth", 0, "Maximum directory depth to process")
-	rootCmd.Flags().Bool("empty-files", false, "Include only empty files")
-	rootCmd.Flags().Bool("empty-dirs", false, "Include only empty directories")
-	rootCmd.Flags().String("modified-after", "", "Include files modified after (format: YYYY-MM-DD)")
-	rootCmd.Flags().String("modified-before", "", "Include files modified before (format: YYYY-MM-DD)")
-	rootCmd.Flags().String("accessed-after", "", "Include files accessed after (format: YYYY-MM-DD)")
-	rootCmd.Flags().String("accessed-before", "", "Include files accessed before (format: YYYY-MM-DD)")
-	rootCmd.Flags().String("created-after", "", "Include files created after (format: YYYY-MM-DD)")
-	rootCmd.Flags().String("created-before", "", "Include files created before (format: YYYY-MM-DD)")
+	rootCmd.Flags().String("max-duration", "", "Stop after this long with partial results (e.g. 30s, 5m)")
+	rootCmd.Flags().Int64("max-files", 0, "Stop after processing this many files with partial results")
+	rootCmd.Flags().String("wait-for-root", "", "Wait up to this long for a root that does not exist yet to appear (e.g. 30s, 5m)")
+	rootCmd.Flags().Bool("fs-info", false, "Report the size, free space and inodes of the root's filesystem")
+	rootCmd.Flags().Int("root-parallelism", 1, "Number of roots walked at once")
+	rootCmd.Flags().Bool("dir-configs", false, "Apply the per-directory config files found in the tree to their subtrees")
+	rootCmd.Flags().String("dir-config-name", stride.DefaultDirConfigName, "Name of the per-directory config files read with --dir-configs")
+	rootOutput.add(rootCmd, "output")
+	addFilterFlags(rootCmd)
 
 	// Bind flags to viper
 	viper.BindPFlag("workers", rootCmd.Flags().Lookup("workers"))
 	viper.BindPFlag("verbose", rootCmd.Flags().Lookup("verbose"))
 	viper.BindPFlag("silent", rootCmd.Flags().Lookup("silent"))
 	viper.BindPFlag("format", rootCmd.Flags().Lookup("format"))
-	viper.BindPFlag("min-size", rootCmd.Flags().Lookup("min-size"))
-	viper.BindPFlag("max-size", rootCmd.Flags().Lookup("max-size"))
-	viper.BindPFlag("pattern", rootCmd.Flags().Lookup("pattern"))
+	viper.BindPFlag("template", rootCmd.Flags().Lookup("template"))
 	viper.BindPFlag("exclude-dir", rootCmd.PersistentFlags().Lookup("exclude-dir"))
-	viper.BindPFlag("exclude-pattern", rootCmd.Flags().Lookup("exclude-pattern"))
-	viper.BindPFlag("file-types", rootCmd.Flags().Lookup("file-types"))
+	viper.BindPFlag("exclude-dir-regex", rootCmd.PersistentFlags().Lookup("exclude-dir-regex"))
+	viper.BindPFlag("color", rootCmd.PersistentFlags().Lookup("color"))
 	viper.BindPFlag("follow-symlinks", rootCmd.Flags().Lookup("follow-symlinks"))
+	viper.BindPFlag("follow-internal-symlinks", rootCmd.Flags().Lookup("follow-internal-symlinks"))
 	viper.BindPFlag("progress", rootCmd.Flags().Lookup("progress"))
+	viper.BindPFlag("include-root", rootCmd.Flags().Lookup("include-root"))
 	viper.BindPFlag("error-mode", rootCmd.Flags().Lookup("error-mode"))
-	viper.BindPFlag("min-permissions", rootCmd.Flags().Lookup("min-permissions"))
-	viper.BindPFlag("max-permissions", rootCmd.Flags().Lookup("max-permissions"))
-	viper.BindPFlag("exact-permissions", rootCmd.Flags().Lookup("exact-permissions"))
-	viper.BindPFlag("owner", rootCmd.Flags().Lookup("owner"))
-	viper.BindPFlag("group", rootCmd.Flags().Lookup("group"))
-	viper.BindPFlag("owner-uid", rootCmd.Flags().Lookup("owner-uid"))
-	viper.BindPFlag("owner-gid", rootCmd.Flags().Lookup("owner-gid"))
-	viper.BindPFlag("min-depth", rootCmd.Flags().Lookup("min-depth"))
-	viper.BindPFlag("max-depth", rootCmd.Flags().Lookup("max-depth"))
-	viper.BindPFlag("empty-files", rootCmd.Flags().Lookup("empty-files"))
-	viper.BindPFlag("empty-dirs", rootCmd.Flags().Lookup("empty-dirs"))
-	viper.BindPFlag("modified-after", rootCmd.Flags().Lookup("modified-after"))
-	viper.BindPFlag("modified-before", rootCmd.Flags().Lookup("modified-before"))
-	viper.BindPFlag("accessed-after", rootCmd.Flags().Lookup("accessed-after"))
-	viper.BindPFlag("accessed-before", rootCmd.Flags().Lookup("accessed-before"))
-	viper.BindPFlag("created-after", rootCmd.Flags().Lookup("created-after"))
-	viper.BindPFlag("created-before", rootCmd.Flags().Lookup("created-before"))
+	viper.BindPFlag("max-duration", rootCmd.Flags().Lookup("max-duration"))
+	viper.BindPFlag("max-files", rootCmd.Flags().Lookup("max-files"))
+	viper.BindPFlag("wait-for-root", rootCmd.Flags().Lookup("wait-for-root"))
+	viper.BindPFlag("fs-info", rootCmd.Flags().Lookup("fs-info"))
+	viper.BindPFlag("root-parallelism", rootCmd.Flags().Lookup("root-parallelism"))
+	viper.BindPFlag("dir-configs", rootCmd.Flags().Lookup("dir-configs"))
+	viper.BindPFlag("dir-config-name", rootCmd.Flags().Lookup("dir-config-name"))
+	bindFilterFlags(rootCmd)
+}
+
+// filterFlagNames lists the flags registered by addFilterFlags.
+var filterFlagNames = []string{
+	"min-size",
+	"max-size",
+	"pattern",
+	"exclude-pattern",
+	"skip-junk",
+	"file-types",
+	"min-permissions",
+	"max-permissions",
+	"exact-permissions",
+	"perm",
+	"readable-by-other",
+	"writable-by-other",
+	"executable-by-other",
+	"owner",
+	"group",
+	"owner-uid",
+	"owner-gid",
+	"min-depth",
+	"max-depth",
+	"empty-files",
+	"empty-dirs",
+	"broken-links",
+	"max-per-dir",
+	"include-from",
+	"gitignore",
+	"ignore-file",
+	"prune-unmodified-since",
+	"recursive-mtime-propagation",
+	"modified-after",
+	"modified-before",
+	"newer-than-file",
+	"older-than-file",
+	"accessed-after",
+	"accessed-before",
+	"created-after",
+	"created-before",
+}
+
+// addFilterFlags registers the file filtering flags shared by commands that
+// walk with FilterOptions.
+func addFilterFlags(cmd *cobra.Command) {
+	cmd.Flags().String("min-size", "", "Minimum file size to process")
+	cmd.Flags().String("max-size", "", "Maximum file size to process")
+	cmd.Flags().String("pattern", "", "File pattern to match (e.g. *.go, or src/**/*.go to match the relative path)")
+	cmd.Flags().String("exclude-pattern", "", "Patterns to exclude files (comma-separated)")
+	cmd.Flags().Bool("skip-junk", false, "Exclude editor swap, backup and lock files, .DS_Store and Thumbs.db")
+	cmd.Flags().String("file-types", "", "File types to include (comma-separated: file,dir,symlink,pipe,socket,device,char)")
+	cmd.Flags().String("min-permissions", "", "Permission bits that must all be set (octal or symbolic, e.g. 0444 or a=r)")
+	cmd.Flags().String("max-permissions", "", "Permission bits files may have, no others (octal or symbolic, e.g. 0755 or u=rwx,go=rx)")
+	cmd.Flags().String("exact-permissions", "", "Exact file permissions to match (octal or symbolic, e.g. 0644 or u=rw,go=r)")
+	cmd.Flags().StringArray("perm", nil, "Permission test like find -perm: MODE (exact, repeat for any of), -MODE (all bits set) or /MODE (any bit set)")
+	cmd.Flags().Bool("readable-by-other", false, "Include only files readable by other users")
+	cmd.Flags().Bool("writable-by-other", false, "Include only files writable by other users")
+	cmd.Flags().Bool("executable-by-other", false, "Include only files executable by other users")
+	cmd.Flags().String("owner", "", "Filter by owner username")
+	cmd.Flags().String("group", "", "Filter by group name")
+	cmd.Flags().Int("owner-uid", 0, "Filter by owner UID")
+	cmd.Flags().Int("owner-gid", 0, "Filter by group GID")
+	cmd.Flags().Int("min-depth", 0, "Minimum directory depth to process")
+	cmd.Flags().Int("max-depth", 0, "Maximum directory depth to process")
+	cmd.Flags().Bool("empty-files", false, "Include only empty files")
+	cmd.Flags().Bool("empty-dirs", false, "Include only empty directories")
+	cmd.Flags().Bool("broken-links", false, "Include only symbolic links whose target does not exist; links are not followed")
+	cmd.Flags().Int("max-per-dir", 0, "Take at most this many files from each directory, for a quick preview")
+	cmd.Flags().String("include-from", "", "Walk only below the path prefixes in this file, one per line, relative to the root or absolute (# starts a comment)")
+	cmd.Flags().Bool("gitignore", false, "Skip what the .gitignore files in the tree exclude, as git does")
+	cmd.Flags().String("ignore-file", "", "Skip what the rules in this file exclude, in .gitignore syntax relative to the root")
+	cmd.Flags().String("prune-unmodified-since", "", "Skip the entries of directories unchanged since a date (YYYY-MM-DD or RFC 3339) or for a duration (e.g. 24h, 7d); deeper changes are still found")
+	cmd.Flags().Bool("recursive-mtime-propagation", false, "With --prune-unmodified-since, skip unchanged directories without reading them; only safe where changes update every directory above them")
+	cmd.Flags().String("modified-after", "", "Include files modified after (format: YYYY-MM-DD)")
+	cmd.Flags().String("modified-before", "", "Include files modified before (format: YYYY-MM-DD)")
+	cmd.Flags().String("newer-than-file", "", "Include files modified after this file, like find -newer")
+	cmd.Flags().String("older-than-file", "", "Include files modified before this file")
+	cmd.Flags().String("accessed-after", "", "Include files accessed after (format: YYYY-MM-DD)")
+	cmd.Flags().String("accessed-before", "", "Include files accessed before (format: YYYY-MM-DD)")
+	cmd.Flags().String("created-after", "", "Include files created after (format: YYYY-MM-DD)")
+	cmd.Flags().String("created-before", "", "Include files created before (format: YYYY-MM-DD)")
+}
+
+// bindFilterFlags binds the filter flags of cmd to their viper keys.
+func bindFilterFlags(cmd *cobra.Command) {
+	for _, name := range filterFlagNames {
+		viper.BindPFlag(name, cmd.Flags().Lookup(name))
+	}
 }
 
 // initConfig reads in config file and ENV variables if set.
@@ -173,7 +255,8 @@ func initConfig() {
 	}
 }
 
-func runFileWalker(root string) error {
+// runFileWalker walks roots as one walk and prints one combined summary.
+func runFileWalker(roots []string) error {
 	// Parse workers
 	workersStr := viper.GetString("workers")
 	workers, err := strconv.Atoi(workersStr)
@@ -181,6 +264,334 @@ func runFileWalker(root string) error {
 		return fmt.Errorf("invalid workers value: %s", workersStr)
 	}
 
+	filter, err := filterOptionsFromConfig()
+	if err != nil {
+		return err
+	}
+
+	colors, err := newColorizer(rootOutput.terminal())
+	if err != nil {
+		return err
+	}
+
+	// A template replaces the output format
+	var tmpl *stride.Template
+	if text := viper.GetString("template"); text != "" {
+		if tmpl, err = stride.ParseTemplate(text); err != nil {
+			return err
+		}
+	}
+
+	// Binary entries leave no room for anything else in the output
+	protoFormat := viper.GetString("format") == "proto"
+	if protoFormat {
+		switch {
+		case tmpl != nil:
+			return errors.New("--format proto cannot be used with --template")
+		case viper.GetBool("progress"):
+			return errors.New("--format proto cannot be used with --progress")
+		case rootOutput.append:
+			return errors.New("--format proto cannot be used with --output-append")
+		}
+	}
+
+	// Create walk options
+	includeRoot := viper.GetBool("include-root")
+	opts := stride.WalkOptions{
+		Filter:        filter,
+		IncludeRoot:   &includeRoot,
+		MaxFiles:      viper.GetInt64("max-files"),
+		CollectFSInfo: viper.GetBool("fs-info"),
+
+		RootParallelism: viper.GetInt("root-parallelism"),
+
+		EnableDirConfigs: viper.GetBool("dir-configs"),
+		DirConfigName:    viper.GetString("dir-config-name"),
+	}
+
+	// Parse the time budget
+	if maxDuration := viper.GetString("max-duration"); maxDuration != "" {
+		duration, err := parseDuration(maxDuration)
+		if err != nil {
+			return fmt.Errorf("invalid max-duration value: %w", err)
+		}
+		opts.MaxDuration = duration
+	}
+	if wait := viper.GetString("wait-for-root"); wait != "" {
+		duration, err := parseDuration(wait)
+		if err != nil {
+			return fmt.Errorf("invalid wait-for-root value: %w", err)
+		}
+		opts.WaitForRoot = duration
+	}
+
+	// Set error handling mode
+	errorMode := viper.GetString("error-mode")
+	switch errorMode {
+	case "continue":
+		opts.ErrorHandling = stride.ErrorHandlingContinue
+	case "stop":
+		opts.ErrorHandling = stride.ErrorHandlingStop
+	case "skip":
+		opts.ErrorHandling = stride.ErrorHandlingSkip
+	default:
+		return fmt.Errorf("invalid error-mode: %s", errorMode)
+	}
+
+	// Set symlink handling; broken links are only seen unfollowed
+	if filter.OnlyBrokenSymlinks {
+		opts.SymlinkHandling = stride.SymlinkReport
+	} else if viper.GetBool("follow-internal-symlinks") {
+		opts.SymlinkHandling = stride.SymlinkFollowInternal
+	} else if viper.GetBool("follow-symlinks") {
+		opts.SymlinkHandling = stride.SymlinkFollow
+	} else {
+		opts.SymlinkHandling = stride.SymlinkIgnore
+	}
+
+	// Set log level and logger; verbose walks also report standout entries
+	if viper.GetBool("verbose") {
+		opts.LogLevel = stride.LogLevelDebug
+		opts.CollectExtremes = true
+	} else if viper.GetBool("silent") {
+		opts.LogLevel = stride.LogLevelError
+	} else {
+		opts.LogLevel = stride.LogLevelInfo
+	}
+
+	// Ensure logger is initialized
+	if opts.Logger == nil {
+		// We can't directly call createLogger as it's not exported
+		// Let the stride package handle logger creation
+		// The logger will be created in WalkLimitWithOptions if it's nil
+	}
+
+	// Set progress function if requested
+	if viper.GetBool("progress") {
+		opts.Progress = func(stats stride.Stats) {
+			if viper.GetString("format") == "json" {
+				jsonStats, _ := json.Marshal(stats)
+				fmt.Println(string(jsonStats))
+			} else {
+				fmt.Printf("\rProcessed: %d files, %d dirs, %.2f MB (%.2f MB/s)    ",
+					stats.FilesProcessed,
+					stats.DirsProcessed,
+					float64(stats.BytesProcessed)/(1024*1024),
+					stats.SpeedMBPerSec)
+			}
+		}
+	}
+
+	// Results go to standard output or the output file, which is only
+	// replaced once the walk completes; progress stays on standard output
+	out, aw, err := rootOutput.open()
+	if err != nil {
+		return err
+	}
+	if aw != nil {
+		defer aw.Close()
+	}
+	var protoWriter *stride.ProtoStreamWriter
+	if protoFormat {
+		protoWriter = stride.NewProtoStreamWriter(out)
+	}
+
+	// Create a context
+	ctx := context.Background()
+
+	// Set buffer size based on workers
+	opts.BufferSize = workers
+
+	// Paths are shown relative to a single root, and in full for several
+	display := func(path string) string {
+		if len(roots) > 1 {
+			return path
+		}
+		rel, _ := filepath.Rel(roots[0], path)
+		return rel
+	}
+
+	// Process files
+	stats, err := stride.WalkRootsStats(ctx, roots, func(path string, info os.FileInfo, err error) error {
+		if errors.Is(err, stride.ErrOutsideRoot) {
+			fmt.Fprintf(os.Stderr, "skipped: %v\n", err)
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		// Check if info is nil to avoid nil pointer dereference
+		if info == nil {
+			return nil
+		}
+
+		// Skip directories as they are handled by the walker
+		if info.IsDir() {
+			return nil
+		}
+
+		// Output file information based on format
+		format := viper.GetString("format")
+		switch {
+		case tmpl != nil:
+			if !viper.GetBool("silent") {
+				fmt.Fprint(out, tmpl.RenderFile(path, info))
+			}
+		case protoWriter != nil:
+			return protoWriter.WriteFile(rootOf(roots, path), path, info)
+		case format == "json":
+			owner, group := stride.OwnerNames(info)
+			fileInfo := map[string]interface{}{
+				"path":          path,
+				"size":          info.Size(),
+				"mode":          info.Mode().String(),
+				"owner":         owner,
+				"group":         group,
+				"last_modified": info.ModTime().Format(time.RFC3339),
+			}
+			jsonInfo, _ := json.Marshal(fileInfo)
+			fmt.Fprintln(out, string(jsonInfo))
+		case format == "long" && !viper.GetBool("silent"):
+			owner, group := stride.OwnerNames(info)
+			name := colors.Path(display(path), info.Mode())
+			if info.Mode()&os.ModeSymlink != 0 {
+				if target, err := os.Readlink(path); err == nil {
+					name += " -> " + target
+				}
+			}
+			fmt.Fprintf(out, "%s %-8s %-8s %10d %s %s\n",
+				info.Mode().String(), owner, group, info.Size(),
+				info.ModTime().Format("2006-01-02 15:04"), name)
+		case !viper.GetBool("silent") && !viper.GetBool("progress"):
+			relPath := display(path)
+			fmt.Fprintf(out, "%s (%d bytes)\n", colors.Path(relPath, info.Mode()), info.Size())
+		}
+
+		return nil
+	}, opts)
+	if protoWriter != nil {
+		if flushErr := protoWriter.Flush(); err == nil {
+			err = flushErr
+		}
+	}
+
+	summary := fmt.Sprintf("Processed: %d files, %d dirs, %.2f MB in %s (%.2f MB/s), %d errors",
+		stats.FilesProcessed,
+		stats.DirsProcessed,
+		float64(stats.BytesProcessed)/(1024*1024),
+		stats.ElapsedTime.Round(time.Millisecond),
+		stats.SpeedMBPerSec,
+		stats.ErrorCount)
+	if stats.FilesSampledOut > 0 {
+		summary += fmt.Sprintf(", %d files sampled out", stats.FilesSampledOut)
+	}
+	if stats.DirsPrunedByMtime > 0 {
+		summary += fmt.Sprintf(", %d unchanged dirs pruned", stats.DirsPrunedByMtime)
+	}
+	if stats.FilesFiltered > 0 {
+		summary += fmt.Sprintf(", %d entries filtered", stats.FilesFiltered)
+	}
+
+	// Replace the progress line with the final summary; in JSON mode the
+	// last progress record already holds the final stats. A truncated walk
+	// always reports how far it got.
+	if viper.GetBool("progress") && viper.GetString("format") != "json" {
+		fmt.Printf("\r%s    \n", summary)
+	} else if errors.Is(err, stride.ErrBudgetExceeded) {
+		fmt.Fprintf(os.Stderr, "Stopped early. %s\n", summary)
+	}
+	// Reports follow the results, unless they are binary
+	report := out
+	if protoWriter != nil {
+		report = os.Stderr
+	}
+	if stats.FSInfo != nil {
+		if viper.GetString("format") == "json" {
+			jsonInfo, _ := json.Marshal(map[string]interface{}{"filesystem": stats.FSInfo})
+			fmt.Fprintln(report, string(jsonInfo))
+		} else {
+			fmt.Fprintf(report, "Filesystem: %s\n", stats.FSInfo)
+		}
+	}
+	if stats.Extremes != nil {
+		if viper.GetString("format") == "json" {
+			jsonInfo, _ := json.Marshal(map[string]interface{}{"extremes": stats.Extremes})
+			fmt.Fprintln(report, string(jsonInfo))
+		} else {
+			printExtremes(report, stats.Extremes, display)
+		}
+	}
+	if err != nil {
+		return err
+	}
+	if aw != nil {
+		if err := aw.Commit(); err != nil {
+			return fmt.Errorf("writing output: %w", err)
+		}
+	}
+	return walkStatus(stats.ErrorCount)
+}
+
+// rootOf returns the outermost of roots containing path, which is the root
+// it was walked from, or the first root if none does.
+func rootOf(roots []string, path string) string {
+	best, bestDepth := roots[0], -1
+	for _, root := range roots {
+		rel, err := filepath.Rel(root, path)
+		if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			continue
+		}
+		if depth := strings.Count(rel, string(filepath.Separator)); depth > bestDepth {
+			best, bestDepth = root, depth
+		}
+	}
+	return best
+}
+
+// printExtremes lists the standout entries of a walk under a heading for
+// each kind, showing paths with display.
+func printExtremes(w io.Writer, e *stride.Extremes, display func(string) string) {
+	lists := []struct {
+		title   string
+		entries []stride.ExtremeEntry
+		measure func(stride.ExtremeEntry) string
+	}{
+		{"Largest files", e.LargestFiles, func(x stride.ExtremeEntry) string { return fmt.Sprintf("%d bytes", x.Size) }},
+		{"Deepest paths", e.DeepestPaths, func(x stride.ExtremeEntry) string { return fmt.Sprintf("depth %d", x.Depth) }},
+		{"Longest paths", e.LongestPaths, func(x stride.ExtremeEntry) string { return fmt.Sprintf("%d bytes", len(x.Path)) }},
+		{"Oldest files", e.OldestFiles, func(x stride.ExtremeEntry) string { return x.ModTime.Format("2006-01-02 15:04") }},
+		{"Newest files", e.NewestFiles, func(x stride.ExtremeEntry) string { return x.ModTime.Format("2006-01-02 15:04") }},
+	}
+	for _, l := range lists {
+		if len(l.entries) == 0 {
+			continue
+		}
+		fmt.Fprintf(w, "%s:\n", l.title)
+		for _, x := range l.entries {
+			fmt.Fprintf(w, "  %16s  %s\n", l.measure(x), display(x.Path))
+		}
+	}
+}
+
+// parseSince parses a point in time given as a date, an RFC 3339 time or a
+// duration before now, such as 7d.
+func parseSince(s string, now time.Time) (time.Time, error) {
+	if t, err := time.Parse("2006-01-02", s); err == nil {
+		return t, nil
+	}
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+	d, err := parseDuration(s)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%q is neither a date nor a duration", s)
+	}
+	return now.Add(-d), nil
+}
+
+// filterOptionsFromConfig builds FilterOptions from the bound filter flags.
+func filterOptionsFromConfig() (stride.FilterOptions, error) {
 	// Create filter options
 	filter := stride.FilterOptions{
 		ExcludeDir: []string{},
@@ -190,7 +601,7 @@ func runFileWalker(root string) error {
 	if minSizeStr := viper.GetString("min-size"); minSizeStr != "" {
 		minSize, err := strconv.ParseInt(minSizeStr, 10, 64)
 		if err != nil {
-			return fmt.Errorf("invalid min-size value: %s", minSizeStr)
+			return stride.FilterOptions{}, fmt.Errorf("invalid min-size value: %s", minSizeStr)
 		}
 		filter.MinSize = minSize
 	}
@@ -199,7 +610,7 @@ func runFileWalker(root string) error {
 	if maxSizeStr := viper.GetString("max-size"); maxSizeStr != "" {
 		maxSize, err := strconv.ParseInt(maxSizeStr, 10, 64)
 		if err != nil {
-			return fmt.Errorf("invalid max-size value: %s", maxSizeStr)
+			return stride.FilterOptions{}, fmt.Errorf("invalid max-size value: %s", maxSizeStr)
 		}
 		filter.MaxSize = maxSize
 	}
@@ -214,7 +625,17 @@ func runFileWalker(root string) error {
 		filter.ExcludeDir = strings.Split(excludeDirs, ",")
 	}
 
+	// Compile directory exclusion regexes
+	for _, expr := range viper.GetStringSlice("exclude-dir-regex") {
+		re, err := regexp.Compile(expr)
+		if err != nil {
+			return stride.FilterOptions{}, fmt.Errorf("invalid exclude-dir-regex value: %s: %w", expr, err)
+		}
+		filter.ExcludeDirRegex = append(filter.ExcludeDirRegex, re)
+	}
+
 	// Set exclude patterns
+	filter.SkipJunkFiles = viper.GetBool("skip-junk")
 	if excludePatterns := viper.GetString("exclude-pattern"); excludePatterns != "" {
 		filter.ExcludePattern = strings.Split(excludePatterns, ",")
 	}
@@ -226,33 +647,54 @@ func runFileWalker(root string) error {
 
 	// Parse permission filters
 	if minPermStr := viper.GetString("min-permissions"); minPermStr != "" {
-		// Parse octal string to int64
-		minPerm, err := strconv.ParseInt(minPermStr, 8, 32)
+		minPerm, err := stride.ParseSymbolicMode(minPermStr)
 		if err != nil {
-			return fmt.Errorf("invalid min-permissions value: %s (should be octal, e.g. 0644)", minPermStr)
+			return stride.FilterOptions{}, fmt.Errorf("invalid min-permissions value: %w", err)
 		}
-		filter.MinPermissions = os.FileMode(minPerm)
+		filter.MinPermissions = minPerm
 	}
 
 	if maxPermStr := viper.GetString("max-permissions"); maxPermStr != "" {
-		// Parse octal string to int64
-		maxPerm, err := strconv.ParseInt(maxPermStr, 8, 32)
+		maxPerm, err := stride.ParseSymbolicMode(maxPermStr)
 		if err != nil {
-			return fmt.Errorf("invalid max-permissions value: %s (should be octal, e.g. 0755)", maxPermStr)
+			return stride.FilterOptions{}, fmt.Errorf("invalid max-permissions value: %w", err)
 		}
-		filter.MaxPermissions = os.FileMode(maxPerm)
+		filter.MaxPermissions = maxPerm
 	}
 
 	if exactPermStr := viper.GetString("exact-permissions"); exactPermStr != "" {
-		// Parse octal string to int64
-		exactPerm, err := strconv.ParseInt(exactPermStr, 8, 32)
+		exactPerm, err := stride.ParseSymbolicMode(exactPermStr)
 		if err != nil {
-			return fmt.Errorf("invalid exact-permissions value: %s (should be octal, e.g. 0644)", exactPermStr)
+			return stride.FilterOptions{}, fmt.Errorf("invalid exact-permissions value: %w", err)
 		}
-		filter.ExactPermissions = os.FileMode(exactPerm)
+		filter.ExactPermissions = exactPerm
 		filter.UseExactPermissions = true
 	}
 
+	// Parse find-style permission tests
+	for _, permStr := range viper.GetStringSlice("perm") {
+		prefix, modeStr := "", permStr
+		if strings.HasPrefix(permStr, "-") || strings.HasPrefix(permStr, "/") {
+			prefix, modeStr = permStr[:1], permStr[1:]
+		}
+		mode, err := stride.ParseSymbolicMode(modeStr)
+		if err != nil {
+			return stride.FilterOptions{}, fmt.Errorf("invalid perm value: %w", err)
+		}
+		switch prefix {
+		case "-":
+			filter.MinPermissions |= mode
+		case "/":
+			filter.AnyPermissions |= mode
+		default:
+			filter.PermissionsAnyOf = append(filter.PermissionsAnyOf, mode)
+		}
+	}
+
+	filter.ReadableByOther = viper.GetBool("readable-by-other")
+	filter.WritableByOther = viper.GetBool("writable-by-other")
+	filter.ExecutableByOther = viper.GetBool("executable-by-other")
+
 	// Parse owner filter
 	if owner := viper.GetString("owner"); owner != "" {
 		filter.OwnerName = owner
@@ -292,11 +734,35 @@ func runFileWalker(root string) error {
 		filter.IncludeEmptyDirs = true
 	}
 
+	filter.OnlyBrokenSymlinks = viper.GetBool("broken-links")
+
+	filter.MaxFilesPerDir = viper.GetInt("max-per-dir")
+
+	// Load the allowlist of path prefixes
+	if includeFrom := viper.GetString("include-from"); includeFrom != "" {
+		paths, err := stride.LoadIncludePaths(includeFrom)
+		if err != nil {
+			return stride.FilterOptions{}, err
+		}
+		filter.IncludePaths = paths
+	}
+	filter.RespectGitignore = viper.GetBool("gitignore")
+	filter.IgnoreFile = viper.GetString("ignore-file")
+
+	if since := viper.GetString("prune-unmodified-since"); since != "" {
+		sinceTime, err := parseSince(since, time.Now())
+		if err != nil {
+			return stride.FilterOptions{}, fmt.Errorf("invalid prune-unmodified-since value: %w", err)
+		}
+		filter.PruneDirsUnmodifiedSince = sinceTime
+	}
+	filter.RecursiveMtimePropagation = viper.GetBool("recursive-mtime-propagation")
+
 	// Parse modified time filters
 	if modifiedAfter := viper.GetString("modified-after"); modifiedAfter != "" {
 		modifiedAfterTime, err := time.Parse("2006-01-02", modifiedAfter)
 		if err != nil {
-			return fmt.Errorf("invalid modified-after format: %s", modifiedAfter)
+			return stride.FilterOptions{}, fmt.Errorf("invalid modified-after format: %s", modifiedAfter)
 		}
 		filter.ModifiedAfter = modifiedAfterTime
 	}
@@ -304,16 +770,20 @@ func runFileWalker(root string) error {
 	if modifiedBefore := viper.GetString("modified-before"); modifiedBefore != "" {
 		modifiedBeforeTime, err := time.Parse("2006-01-02", modifiedBefore)
 		if err != nil {
-			return fmt.Errorf("invalid modified-before format: %s", modifiedBefore)
+			return stride.FilterOptions{}, fmt.Errorf("invalid modified-before format: %s", modifiedBefore)
 		}
 		filter.ModifiedBefore = modifiedBeforeTime
 	}
 
+	// Reference files are read when the walk starts
+	filter.NewerThanFile = viper.GetString("newer-than-file")
+	filter.OlderThanFile = viper.GetString("older-than-file")
+
 	// Parse accessed time filters
 	if accessedAfter := viper.GetString("accessed-after"); accessedAfter != "" {
 		accessedAfterTime, err := time.Parse("2006-01-02", accessedAfter)
 		if err != nil {
-			return fmt.Errorf("invalid accessed-after format: %s", accessedAfter)
+			return stride.FilterOptions{}, fmt.Errorf("invalid accessed-after format: %s", accessedAfter)
 		}
 		filter.AccessedAfter = accessedAfterTime
 	}
@@ -321,7 +791,7 @@ func runFileWalker(root string) error {
 	if accessedBefore := viper.GetString("accessed-before"); accessedBefore != "" {
 		accessedBeforeTime, err := time.Parse("2006-01-02", accessedBefore)
 		if err != nil {
-			return fmt.Errorf("invalid accessed-before format: %s", accessedBefore)
+			return stride.FilterOptions{}, fmt.Errorf("invalid accessed-before format: %s", accessedBefore)
 		}
 		filter.AccessedBefore = accessedBeforeTime
 	}
@@ -330,7 +800,7 @@ func runFileWalker(root string) error {
 	if createdAfter := viper.GetString("created-after"); createdAfter != "" {
 		createdAfterTime, err := time.Parse("2006-01-02", createdAfter)
 		if err != nil {
-			return fmt.Errorf("invalid created-after format: %s", createdAfter)
+			return stride.FilterOptions{}, fmt.Errorf("invalid created-after format: %s", createdAfter)
 		}
 		filter.CreatedAfter = createdAfterTime
 	}
@@ -338,108 +808,10 @@ func runFileWalker(root string) error {
 	if createdBefore := viper.GetString("created-before"); createdBefore != "" {
 		createdBeforeTime, err := time.Parse("2006-01-02", createdBefore)
 		if err != nil {
-			return fmt.Errorf("invalid created-before format: %s", createdBefore)
+			return stride.FilterOptions{}, fmt.Errorf("invalid created-before format: %s", createdBefore)
 		}
 		filter.CreatedBefore = createdBeforeTime
 	}
 
-	// Create walk options
-	opts := stride.WalkOptions{
-		Filter: filter,
-	}
-
-	// Set error handling mode
-	errorMode := viper.GetString("error-mode")
-	switch errorMode {
-	case "continue":
-		opts.ErrorHandling = stride.ErrorHandlingContinue
-	case "stop":
-		opts.ErrorHandling = stride.ErrorHandlingStop
-	case "skip":
-		opts.ErrorHandling = stride.ErrorHandlingSkip
-	default:
-		return fmt.Errorf("invalid error-mode: %s", errorMode)
-	}
-
-	// Set symlink handling
-	if viper.GetBool("follow-symlinks") {
-		opts.SymlinkHandling = stride.SymlinkFollow
-	} else {
-		opts.SymlinkHandling = stride.SymlinkIgnore
-	}
-
-	// Set log level and logger
-	if viper.GetBool("verbose") {
-		opts.LogLevel = stride.LogLevelDebug
-	} else if viper.GetBool("silent") {
-		opts.LogLevel = stride.LogLevelError
-	} else {
-		opts.LogLevel = stride.LogLevelInfo
-	}
-
-	// Ensure logger is initialized
-	if opts.Logger == nil {
-		// We can't directly call createLogger as it's not exported
-		// Let the stride package handle logger creation
-		// The logger will be created in WalkLimitWithOptions if it's nil
-	}
-
-	// Set progress function if requested
-	if viper.GetBool("progress") {
-		// Print a final newline when done
-		defer fmt.Println()
-
-		opts.Progress = func(stats stride.Stats) {
-			if viper.GetString("format") == "json" {
-				jsonStats, _ := json.Marshal(stats)
-				fmt.Println(string(jsonStats))
-			} else {
-				fmt.Printf("\rProcessed: %d files, %d dirs, %.2f MB (%.2f MB/s)    ",
-					stats.FilesProcessed,
-					stats.DirsProcessed,
-					float64(stats.BytesProcessed)/(1024*1024),
-					stats.SpeedMBPerSec)
-			}
-		}
-	}
-
-	// Create a context
-	ctx := context.Background()
-
-	// Set buffer size based on workers
-	opts.BufferSize = workers
-
-	// Process files
-	return stride.WalkLimitWithOptions(ctx, root, func(path string, info os.FileInfo, err error) error {
-		if err != nil {
-			return err
-		}
-
-		// Check if info is nil to avoid nil pointer dereference
-		if info == nil {
-			return nil
-		}
-
-		// Skip directories as they are handled by the walker
-		if info.IsDir() {
-			return nil
-		}
-
-		// Output file information based on format
-		if viper.GetString("format") == "json" {
-			fileInfo := map[string]interface{}{
-				"path":          path,
-				"size":          info.Size(),
-				"mode":          info.Mode().String(),
-				"last_modified": info.ModTime().Format(time.RFC3339),
-			}
-			jsonInfo, _ := json.Marshal(fileInfo)
-			fmt.Println(string(jsonInfo))
-		} else if !viper.GetBool("silent") && !viper.GetBool("progress") {
-			relPath, _ := filepath.Rel(root, path)
-			fmt.Printf("%s (%d bytes)\n", relPath, info.Size())
-		}
-
-		return nil
-	}, opts)
+	return filter, nil
 }
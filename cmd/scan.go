@@ -0,0 +1,86 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+
+	stride "github.com/TFMV/stride/internal/walk"
+	"github.com/spf13/cobra"
+)
+
+var (
+	// Scan command options
+	scanRules        []string
+	scanExcludeRules []string
+	scanJSON         bool
+	scanWorkers      int
+)
+
+// scanCmd represents the scan command
+var scanCmd = &cobra.Command{
+	Use:   "scan [options] <path>",
+	Short: "Scan files for security issues as they are found",
+	Long: `Apply the security rules of "stride analyze --security" to the files selected
+by the filter flags, printing each finding as soon as it is found. With --json,
+each finding is a JSON object on its own line, ready to be forwarded.
+
+Rules are named by rule or by severity: world-writable, setuid, setgid and
+suspicious-extension, or high and medium.
+
+Examples:
+  stride scan /srv
+  stride scan --rules=high,medium --json /srv | ship-to-siem
+  stride scan --exclude-rules=suspicious-extension --exclude-dir=node_modules /project`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		bindFilterFlags(cmd)
+		return runScan(args[0])
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(scanCmd)
+
+	addFilterFlags(scanCmd)
+	scanCmd.Flags().StringSliceVar(&scanRules, "rules", nil, "Rules or severities to apply (comma-separated; default all)")
+	scanCmd.Flags().StringSliceVar(&scanExcludeRules, "exclude-rules", nil, "Rules or severities not to apply (comma-separated)")
+	scanCmd.Flags().BoolVar(&scanJSON, "json", false, "Write each finding as a line of JSON")
+	scanCmd.Flags().IntVar(&scanWorkers, "workers", 0, "Number of concurrent workers (0 for the number of CPUs)")
+}
+
+// scanFinding is the JSON form of a finding written by scan --json.
+type scanFinding struct {
+	Rule     string `json:"rule"`
+	Severity string `json:"severity"`
+	Path     string `json:"path"`
+	Details  string `json:"details"`
+}
+
+func runScan(root string) error {
+	filter, err := filterOptionsFromConfig()
+	if err != nil {
+		return err
+	}
+	opts := stride.SecurityScanOptions{
+		Filter:       filter,
+		Workers:      scanWorkers,
+		Rules:        scanRules,
+		ExcludeRules: scanExcludeRules,
+	}
+
+	enc := json.NewEncoder(os.Stdout)
+	return stride.SecurityScan(context.Background(), root, opts, func(issue stride.SecurityIssue) error {
+		if scanJSON {
+			return enc.Encode(scanFinding{
+				Rule:     issue.Rule,
+				Severity: issue.Severity,
+				Path:     issue.Path,
+				Details:  issue.Description,
+			})
+		}
+		_, err := fmt.Printf("[%s] %s: %s (%s)\n", issue.Severity, issue.Path, issue.Description, issue.Rule)
+		return err
+	})
+}
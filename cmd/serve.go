@@ -0,0 +1,75 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+
+	"github.com/TFMV/stride/internal/server"
+	"github.com/spf13/cobra"
+)
+
+var (
+	// Serve command options
+	serveListen  string
+	serveRoot    string
+	serveWorkers int
+	serveLock    string
+)
+
+// serveCmd represents the serve command
+var serveCmd = &cobra.Command{
+	Use:   "serve [options]",
+	Short: "Serve find queries over HTTP from an in-memory index",
+	Long: `Build an in-memory index of a directory, keep it up to date by watching for
+changes, and answer queries over a small HTTP+JSON API. Intended for editor
+integrations that query repeatedly and cannot afford a full walk per query.
+
+Endpoints:
+  POST /find    JSON body with name, path, ignore, regex, older_than, newer_than,
+                larger_than, smaller_than, empty, max_depth and include_hidden;
+                responds with one JSON object per match (NDJSON)
+  GET  /stats   index size, walk statistics and watch event counters
+  POST /rescan  rebuild the index from disk
+
+Examples:
+  stride serve --root ~/src/project
+  stride serve --root ~/src/project --lock-file=/run/stride-serve.lock
+  curl -d '{"name":"*.go"}' http://127.0.0.1:7777/find`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runServe()
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(serveCmd)
+
+	serveCmd.Flags().StringVar(&serveListen, "listen", "127.0.0.1:7777", "Address to listen on")
+	serveCmd.Flags().StringVar(&serveRoot, "root", ".", "Directory to index")
+	serveCmd.Flags().IntVar(&serveWorkers, "workers", 4, "Number of concurrent workers for indexing")
+	serveCmd.Flags().StringVar(&serveLock, "lock-file", "", "Refuse to start while another instance holds this lock file, which records the PID")
+}
+
+func runServe() error {
+	info, err := os.Stat(serveRoot)
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("root %s is not a directory", serveRoot)
+	}
+
+	lock, err := lockInstance(serveLock)
+	if err != nil {
+		return err
+	}
+	defer lock.Release()
+
+	ctx, stop := signal.NotifyContext(context.Background(), shutdownSignals...)
+	defer stop()
+
+	fmt.Fprintf(os.Stderr, "Serving %s on http://%s\n", serveRoot, serveListen)
+	return server.Serve(ctx, serveListen, serveRoot, serveWorkers)
+}
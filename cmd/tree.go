@@ -0,0 +1,84 @@
+package cmd
+
+import (
+	"context"
+	"os"
+
+	stride "github.com/TFMV/stride/internal/walk"
+	"github.com/spf13/cobra"
+)
+
+var (
+	// Tree command options
+	treeDepth    int
+	treeDirsOnly bool
+	treeASCII    bool
+	treeFollow   bool
+)
+
+// treeCmd represents the tree command
+var treeCmd = &cobra.Command{
+	Use:   "tree [options] <path>",
+	Short: "Print a tree view of the files selected by the filters",
+	Long: `Print a tree(1)-style view of a directory, applying the same filter flags
+as the root command. Use it to preview exactly which files a filter set selects.
+
+Examples:
+  stride tree /path/to/directory
+  stride tree --pattern="*.go" --depth=2 /src
+  stride tree --dirs-only --exclude-dir=node_modules /project`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		bindFilterFlags(cmd)
+		return runTree(args[0])
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(treeCmd)
+
+	addFilterFlags(treeCmd)
+	treeCmd.Flags().IntVar(&treeDepth, "depth", 0, "Maximum depth to display (0 for unlimited)")
+	treeCmd.Flags().BoolVar(&treeDirsOnly, "dirs-only", false, "List directories only")
+	treeCmd.Flags().BoolVar(&treeASCII, "ascii", false, "Use ASCII instead of Unicode line drawing")
+	treeCmd.Flags().BoolVar(&treeFollow, "follow-symlinks", false, "Follow symbolic links")
+}
+
+func runTree(root string) error {
+	filter, err := filterOptionsFromConfig()
+	if err != nil {
+		return err
+	}
+	if treeDepth > 0 && (filter.MaxDepth == 0 || treeDepth < filter.MaxDepth) {
+		filter.MaxDepth = treeDepth
+	}
+
+	opts := stride.WalkOptions{
+		Filter:          filter,
+		ErrorHandling:   stride.ErrorHandlingContinue,
+		SymlinkHandling: stride.SymlinkIgnore,
+		LogLevel:        stride.LogLevelError,
+	}
+	if treeFollow {
+		opts.SymlinkHandling = stride.SymlinkFollow
+	}
+
+	colors, err := newColorizer(os.Stdout)
+	if err != nil {
+		return err
+	}
+
+	tree, err := stride.BuildTree(context.Background(), root, opts)
+	if err != nil {
+		return err
+	}
+
+	renderOpts := stride.TreeRenderOptions{
+		DirsOnly: treeDirsOnly,
+		ASCII:    treeASCII,
+	}
+	if colors.Enabled() {
+		renderOpts.Paint = colors.Name
+	}
+	return tree.Render(os.Stdout, renderOpts)
+}
@@ -0,0 +1,163 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+
+	stride "github.com/TFMV/stride/internal/walk"
+	"github.com/spf13/cobra"
+)
+
+var (
+	// Unicode command options
+	unicodeFix    bool
+	unicodeDryRun bool
+)
+
+// unicodeCmd represents the unicode command
+var unicodeCmd = &cobra.Command{
+	Use:   "unicode [options] <path>",
+	Short: "Find names that are not in Unicode NFC form",
+	Long: `List the files and directories whose names are not in Unicode NFC form, as
+names written on macOS often are, and those whose names are the same as a
+sibling's once normalized. Such names can look identical yet break syncs
+between systems. Each line gives the issue, "nfd" or "collision:<other name>",
+and the path.
+
+With --fix, names that are not in NFC form are renamed to it, except where
+the normalized name is already taken; those are reported and left alone.
+
+Examples:
+  stride unicode ~/Music
+  stride unicode --fix --dry-run /srv/share
+  stride unicode --fix /srv/share`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return commandResult(cmd, runUnicode(args[0]))
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(unicodeCmd)
+
+	unicodeCmd.Flags().BoolVar(&unicodeFix, "fix", false, "Rename names that are not in NFC form to it, unless the new name is taken")
+	unicodeCmd.Flags().BoolVar(&unicodeDryRun, "dry-run", false, "With --fix, list the renames without making them")
+}
+
+func runUnicode(root string) error {
+	if unicodeDryRun && !unicodeFix {
+		return errors.New("--dry-run requires --fix")
+	}
+
+	var mu sync.Mutex
+	var issues []stride.UnicodeIssue
+	// Links are reported, never followed, so no rename goes through one to
+	// outside the root
+	opts := stride.WalkOptions{
+		Filter:          stride.FilterOptions{DetectUnicodeIssues: true},
+		SymlinkHandling: stride.SymlinkReport,
+		OnUnicodeIssue: func(issue stride.UnicodeIssue) {
+			mu.Lock()
+			issues = append(issues, issue)
+			mu.Unlock()
+		},
+	}
+	stats, err := stride.WalkLimitWithOptionsStats(context.Background(), root, func(path string, info os.FileInfo, err error) error {
+		return nil
+	}, opts)
+	if err != nil {
+		return err
+	}
+
+	sort.Slice(issues, func(i, j int) bool { return issues[i].Path < issues[j].Path })
+	for _, issue := range issues {
+		fmt.Printf("%s\t%s\n", issue.Marker(), issue.Path)
+	}
+	if !unicodeFix {
+		return walkStatus(stats.ErrorCount)
+	}
+
+	// Rename the deepest entries first, so their paths still hold
+	sort.Slice(issues, func(i, j int) bool {
+		di, dj := strings.Count(issues[i].Path, string(os.PathSeparator)), strings.Count(issues[j].Path, string(os.PathSeparator))
+		if di != dj {
+			return di > dj
+		}
+		return issues[i].Path < issues[j].Path
+	})
+	refused := 0
+	for _, issue := range issues {
+		if err := renameToNFC(issue); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			refused++
+		}
+	}
+	if refused > 0 {
+		return &exitStatus{code: ExitPathError, msg: fmt.Sprintf("%d names could not be renamed", refused)}
+	}
+	return walkStatus(stats.ErrorCount)
+}
+
+// renameToNFC renames the entry of issue to its NFC name, unless another
+// entry has that name, even one created just before the rename.
+func renameToNFC(issue stride.UnicodeIssue) error {
+	target := issue.NFCPath()
+	if issue.Kind == stride.UnicodeCollision {
+		return fmt.Errorf("refusing to rename %s: it collides with %s", issue.Path, issue.Other)
+	}
+
+	// Filesystems that ignore normalization find the entry itself, which
+	// only a plain rename can give its new name
+	rename := renameNoReplace
+	if existing, err := os.Lstat(target); err == nil {
+		self, err := os.Lstat(issue.Path)
+		if err != nil {
+			return err
+		}
+		if !os.SameFile(self, existing) {
+			return fmt.Errorf("refusing to rename %s: %s already exists", issue.Path, target)
+		}
+		rename = os.Rename
+	}
+	if unicodeDryRun {
+		fmt.Printf("would rename: %s -> %s\n", issue.Path, target)
+		return nil
+	}
+	if err := rename(issue.Path, target); err != nil {
+		if errors.Is(err, fs.ErrExist) {
+			return fmt.Errorf("refusing to rename %s: %s already exists", issue.Path, target)
+		}
+		return err
+	}
+	fmt.Printf("renamed: %s -> %s\n", issue.Path, target)
+	return nil
+}
+
+// linkRename renames oldpath to newpath without replacing newpath, where
+// the system cannot: a file is hard linked to its new name, which fails if
+// the name is taken, and then unlinked from the old one. Directories cannot
+// be linked, and are renamed once newpath is found free.
+func linkRename(oldpath, newpath string) error {
+	info, err := os.Lstat(oldpath)
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		if err := os.Link(oldpath, newpath); err != nil {
+			return err
+		}
+		return os.Remove(oldpath)
+	}
+	if _, err := os.Lstat(newpath); err == nil {
+		return &os.LinkError{Op: "rename", Old: oldpath, New: newpath, Err: fs.ErrExist}
+	} else if !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	return os.Rename(oldpath, newpath)
+}
@@ -0,0 +1,139 @@
+package cmd
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestUnicodeFix(t *testing.T) {
+	const nfc, nfd = "caf\xc3\xa9.txt", "cafe\xcc\x81.txt"
+	root := t.TempDir()
+	mustWrite := func(path string) {
+		t.Helper()
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("Failed to create directory: %v", err)
+		}
+		if err := os.WriteFile(path, []byte("x"), 0644); err != nil {
+			t.Fatalf("Failed to create file: %v", err)
+		}
+	}
+	mustWrite(filepath.Join(root, "a", nfd))
+	names := func(dir string) []string {
+		t.Helper()
+		entries, err := os.ReadDir(filepath.Join(root, dir))
+		if err != nil {
+			t.Fatalf("Failed to read directory: %v", err)
+		}
+		var names []string
+		for _, e := range entries {
+			names = append(names, e.Name())
+		}
+		return names
+	}
+
+	// Filesystems that ignore normalization cannot hold a collision
+	mustWrite(filepath.Join(root, "b", nfc))
+	mustWrite(filepath.Join(root, "b", nfd))
+	collisions := len(names("b")) == 2
+	if !collisions {
+		if err := os.RemoveAll(filepath.Join(root, "b")); err != nil {
+			t.Fatalf("Failed to remove directory: %v", err)
+		}
+	}
+
+	if code := runStride(t, "unicode", "--dry-run", root); code != ExitFatal {
+		t.Errorf("Expected exit status %d for --dry-run without --fix, got %d", ExitFatal, code)
+	}
+
+	out, code := runStrideOutput(t, "unicode", root)
+	if code != ExitOK {
+		t.Errorf("Expected exit status %d, got %d:\n%s", ExitOK, code, out)
+	}
+	if want := "nfd\t" + filepath.Join(root, "a", nfd) + "\n"; !strings.Contains(out, want) {
+		t.Errorf("Expected %q in the output, got:\n%s", want, out)
+	}
+
+	// A dry run changes nothing
+	out, _ = runStrideOutput(t, "unicode", "--fix", "--dry-run", root)
+	if !strings.Contains(out, "would rename: "+filepath.Join(root, "a", nfd)) {
+		t.Errorf("Expected the rename of a/%s in the output, got:\n%s", nfd, out)
+	}
+	if got := names("a"); len(got) != 1 || got[0] != nfd {
+		t.Errorf("Expected a/%s to be left alone, got %q", nfd, got)
+	}
+
+	out, code = runStrideOutput(t, "unicode", "--fix", root)
+	if got := names("a"); len(got) != 1 || got[0] != nfc {
+		t.Errorf("Expected a/%s to be renamed to %s, got %q", nfd, nfc, got)
+	}
+	if !collisions {
+		if code != ExitOK {
+			t.Errorf("Expected exit status %d, got %d:\n%s", ExitOK, code, out)
+		}
+		return
+	}
+	if code != ExitPathError {
+		t.Errorf("Expected exit status %d for the collision, got %d:\n%s", ExitPathError, code, out)
+	}
+	if got := names("b"); len(got) != 2 {
+		t.Errorf("Expected both names in b to be left alone, got %q", got)
+	}
+}
+
+func TestUnicodeFixSkipsLinks(t *testing.T) {
+	const nfd = "cafe\xcc\x81.txt"
+	root, outside := t.TempDir(), t.TempDir()
+	if err := os.WriteFile(filepath.Join(outside, nfd), []byte("x"), 0644); err != nil {
+		t.Fatalf("Failed to create file: %v", err)
+	}
+	if err := os.Symlink(outside, filepath.Join(root, "link")); err != nil {
+		t.Skipf("Cannot create symlinks: %v", err)
+	}
+
+	// The link is not followed, so nothing outside the root is renamed
+	out, code := runStrideOutput(t, "unicode", "--fix", root)
+	if code != ExitOK {
+		t.Errorf("Expected exit status %d, got %d:\n%s", ExitOK, code, out)
+	}
+	if _, err := os.Lstat(filepath.Join(outside, nfd)); err != nil {
+		t.Errorf("Expected %s outside the root to be left alone: %v", nfd, err)
+	}
+}
+
+func TestRenameNoReplace(t *testing.T) {
+	dir := t.TempDir()
+	oldpath, newpath := filepath.Join(dir, "old"), filepath.Join(dir, "new")
+	for _, rename := range []func(string, string) error{renameNoReplace, linkRename} {
+		for path, content := range map[string]string{oldpath: "old", newpath: "new"} {
+			if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+				t.Fatalf("Failed to create file: %v", err)
+			}
+		}
+
+		// A taken name is refused and left as it was
+		if err := rename(oldpath, newpath); !errors.Is(err, fs.ErrExist) {
+			t.Errorf("Expected an error matching fs.ErrExist, got %v", err)
+		}
+		if b, err := os.ReadFile(newpath); err != nil || string(b) != "new" {
+			t.Errorf("Expected the target to keep its content, got %q: %v", b, err)
+		}
+
+		if err := os.Remove(newpath); err != nil {
+			t.Fatalf("Failed to remove file: %v", err)
+		}
+		if err := rename(oldpath, newpath); err != nil {
+			t.Fatalf("Failed to rename: %v", err)
+		}
+		if b, err := os.ReadFile(newpath); err != nil || string(b) != "old" {
+			t.Errorf("Expected the file under its new name, got %q: %v", b, err)
+		}
+		if _, err := os.Lstat(oldpath); !errors.Is(err, fs.ErrNotExist) {
+			t.Errorf("Expected the old name to be gone, got %v", err)
+		}
+		os.Remove(newpath)
+	}
+}
@@ -2,8 +2,10 @@ package cmd
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"os"
+	"os/signal"
 	"strings"
 	"time"
 
@@ -20,7 +22,23 @@ var (
 	watchPattern       string
 	watchIgnore        string
 	watchTimeout       time.Duration
+	watchIdleTimeout   time.Duration
 	watchIncludeHidden bool
+	watchExecEnv       bool
+	watchJSON          bool
+	watchExecPrefix    bool
+	watchContentOnly   bool
+	watchContentHash   bool
+	watchSkipJunk      bool
+	watchLockFile      string
+	watchExecGrace     time.Duration
+	watchBackend       string
+	watchPollInterval  time.Duration
+	watchHash          string
+	watchMaxHashSize   string
+	watchWaitForRoot   time.Duration
+	watchExisting      bool
+	watchDebounce      time.Duration
 )
 
 // watchCmd represents the watch command
@@ -32,8 +50,16 @@ var watchCmd = &cobra.Command{
 Examples:
   stride watch /path/to/watch
   stride watch --events=create,modify --exec="echo Changed: {}" /path/to/watch
+  stride watch --exec='echo "$STRIDE_EVENT: $STRIDE_PATH"' /path/to/watch
   stride watch --pattern="*.go" --format="{base} was {event} at {time}" /path/to/watch
-  stride watch --recursive /path/to/watch`,
+  stride watch --recursive /path/to/watch
+  stride watch --events=modify --content-only --exec="./backup.sh {}" /path/to/watch
+  stride watch --debounce=200ms --exec="make -C {dir}" /path/to/watch
+  stride watch --json /path/to/watch | jq -r .path
+  stride watch --json --hash=sha256 --events=create,modify /path/to/watch
+  stride watch --backend=poll --poll-interval=10s --recursive /mnt/nfs/share
+  stride watch --lock-file=/run/stride-watch.lock --exec="./sync.sh {}" /srv/data
+  stride watch --wait-for-root=5m --process-existing --recursive /srv/incoming`,
 	Run: func(cmd *cobra.Command, args []string) {
 		// Get the directory to watch
 		var watchDir string
@@ -48,9 +74,17 @@ Examples:
 			}
 		}
 
-		// Create a context
-		ctx, cancel := context.WithCancel(context.Background())
-		defer cancel()
+		// Refuse to run next to another instance holding the lock file
+		lock, err := lockInstance(watchLockFile)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		defer lock.Release()
+
+		// Stop watching on SIGINT or SIGTERM
+		ctx, stop := signal.NotifyContext(context.Background(), shutdownSignals...)
+		defer stop()
 
 		// Convert string events to WatchEvent types
 		var events []stride.WatchEvent
@@ -71,23 +105,65 @@ Examples:
 			}
 		}
 
+		var backend stride.WatchBackend
+		switch strings.ToLower(watchBackend) {
+		case "auto":
+			backend = stride.WatchBackendAuto
+		case "notify":
+			backend = stride.WatchBackendNotify
+		case "poll":
+			backend = stride.WatchBackendPoll
+		default:
+			lock.Release()
+			fmt.Fprintf(os.Stderr, "Error: invalid backend %q (must be auto, notify or poll)\n", watchBackend)
+			os.Exit(1)
+		}
+
+		maxHashSize, err := parseSize(watchMaxHashSize)
+		if err != nil {
+			lock.Release()
+			fmt.Fprintf(os.Stderr, "Error: invalid --max-hash-size: %v\n", err)
+			os.Exit(1)
+		}
+
 		// Create watch options
 		opts := stride.WatchOptions{
-			Context:       ctx,
-			Events:        events,
-			Recursive:     watchRecursive,
-			Pattern:       watchPattern,
-			IgnorePattern: watchIgnore,
-			IncludeHidden: watchIncludeHidden,
-			Timeout:       watchTimeout,
+			Context:           ctx,
+			Events:            events,
+			Recursive:         watchRecursive,
+			Pattern:           watchPattern,
+			IgnorePattern:     watchIgnore,
+			SkipJunkFiles:     watchSkipJunk,
+			IncludeHidden:     watchIncludeHidden,
+			Timeout:           watchTimeout,
+			IdleTimeout:       watchIdleTimeout,
+			ExecEnv:           &watchExecEnv,
+			ExecPrefixOutput:  watchExecPrefix,
+			ExecGracePeriod:   watchExecGrace,
+			ContentChangeOnly: watchContentOnly || watchContentHash,
+			ContentHashCheck:  watchContentHash,
+			Backend:           backend,
+			PollInterval:      watchPollInterval,
+			HashAlgorithm:     stride.HashAlgorithm(strings.ToLower(watchHash)),
+			MaxHashSize:       maxHashSize,
+			WaitForRoot:       watchWaitForRoot,
+			ProcessExisting:   watchExisting,
+			Debounce:          watchDebounce,
 		}
 
-		// Start watching
-		fmt.Printf("Watching %s for changes...\n", watchDir)
-		fmt.Println("Press Ctrl+C to exit.")
+		// Start watching, keeping standard output to the events themselves
+		// when it is meant for another program
+		banner := os.Stdout
+		if watchJSON {
+			banner = os.Stderr
+		}
+		fmt.Fprintf(banner, "Watching %s for changes...\n", watchDir)
+		fmt.Fprintln(banner, "Press Ctrl+C to exit.")
 
-		var err error
-		if watchExec != "" {
+		if watchJSON {
+			// Write each event as a line of JSON
+			err = stride.WatchWithJSON(ctx, watchDir, opts, os.Stdout)
+		} else if watchExec != "" {
 			// Execute command for each event
 			err = stride.WatchWithExec(ctx, watchDir, opts, watchExec)
 		} else if watchFormat != "" {
@@ -98,7 +174,12 @@ Examples:
 			err = stride.Watch(ctx, watchDir, opts, nil)
 		}
 
+		if errors.Is(err, stride.ErrWatchIdle) {
+			fmt.Fprintf(banner, "No changes for %s, exiting.\n", watchIdleTimeout)
+			return
+		}
 		if err != nil {
+			lock.Release()
 			fmt.Fprintf(os.Stderr, "Error watching directory: %v\n", err)
 			os.Exit(1)
 		}
@@ -111,10 +192,27 @@ func init() {
 	// Define flags for the watch command
 	watchCmd.Flags().StringSliceVar(&watchEvents, "events", []string{}, "Events to watch for (create, modify, delete, rename, chmod)")
 	watchCmd.Flags().BoolVar(&watchRecursive, "recursive", false, "Watch subdirectories recursively")
-	watchCmd.Flags().StringVar(&watchExec, "exec", "", "Command to execute when an event occurs")
+	watchCmd.Flags().StringVar(&watchExec, "exec", "", "Command to execute when an event occurs, run through the shell with placeholders such as {} quoted for it")
+	watchCmd.Flags().BoolVar(&watchExecEnv, "exec-env", true, "Describe the event to --exec commands in STRIDE_* environment variables")
+	watchCmd.Flags().BoolVar(&watchExecPrefix, "exec-prefix", false, "Prefix each line of --exec output with the path of the event")
 	watchCmd.Flags().StringVar(&watchFormat, "format", "", "Format string for output")
-	watchCmd.Flags().StringVar(&watchPattern, "pattern", "", "File pattern to match (e.g., *.go)")
-	watchCmd.Flags().StringVar(&watchIgnore, "ignore", "", "File pattern to ignore")
+	watchCmd.Flags().BoolVar(&watchJSON, "json", false, "Write each event as a line of JSON")
+	watchCmd.Flags().StringVar(&watchPattern, "pattern", "", "File pattern to match (e.g., *.go, or src/**/*.go to match the relative path)")
+	watchCmd.Flags().StringVar(&watchIgnore, "ignore", "", "File pattern to ignore (e.g., *.tmp or vendor/**)")
 	watchCmd.Flags().DurationVar(&watchTimeout, "timeout", 0, "Duration to watch before exiting (e.g., 1h, 30m)")
+	watchCmd.Flags().DurationVar(&watchDebounce, "debounce", 0, "Report the events of a file once no other has followed for this long, coalesced (e.g., 200ms)")
+	watchCmd.Flags().DurationVar(&watchWaitForRoot, "wait-for-root", 0, "Wait up to this long for a directory that does not exist yet to appear (e.g., 5m)")
+	watchCmd.Flags().BoolVar(&watchExisting, "process-existing", false, "Report the files already present when watching starts as create events")
+	watchCmd.Flags().DurationVar(&watchIdleTimeout, "idle-timeout", 0, "Exit once no event has been reported for this long (e.g., 10m)")
+	watchCmd.Flags().BoolVar(&watchSkipJunk, "skip-junk", false, "Ignore editor swap, backup and lock files, .DS_Store and Thumbs.db")
 	watchCmd.Flags().BoolVar(&watchIncludeHidden, "include-hidden", false, "Include hidden files and directories")
+	watchCmd.Flags().BoolVar(&watchContentOnly, "content-only", false, "Skip modify events that leave the file size unchanged, such as touch")
+	watchCmd.Flags().BoolVar(&watchContentHash, "content-hash", false, "Like --content-only, but also compare the first 64 KiB of same-size files")
+	watchCmd.Flags().StringVar(&watchLockFile, "lock-file", "", "Refuse to start while another instance holds this lock file, which records the PID")
+	watchCmd.Flags().StringVar(&watchBackend, "backend", "auto", "How changes are detected (auto|notify|poll); auto polls on network filesystems and where notifications are unavailable")
+	watchCmd.Flags().DurationVar(&watchPollInterval, "poll-interval", stride.DefaultPollInterval, "How often the poll backend walks the tree")
+	watchCmd.Flags().StringVar(&watchHash, "hash", "none", "Attach a digest of created and modified files to their events (none|sha256|xxhash64), shown by --json")
+	watchCmd.Flags().StringVar(&watchMaxHashSize, "max-hash-size", "64MB", "Size above which files are not hashed (e.g., 10MB)")
+	watchCmd.Flags().DurationVar(&watchExecGrace, "exec-grace", 10*time.Second, "Time --exec commands still running on SIGINT or SIGTERM get to exit before they are killed")
+	watchCmd.MarkFlagsMutuallyExclusive("json", "exec", "format")
 }
@@ -38,7 +38,7 @@ func main() {
 	// Example 3: Depth filtering
 	fmt.Println("\n--- Depth Filtering ---")
 	filter3 := stride.FilterOptions{
-		MinDepth: 1, // Skip the root directory
+		MinDepth: 1, // Start at the root's entries
 		MaxDepth: 2, // Don't go deeper than 2 levels
 	}
 	walkWithFilter(dir, filter3, "Files at depth 1-2")
@@ -13,55 +13,6 @@ import (
 	"go.uber.org/zap"
 )
 
-// Simple logging middleware that logs files being processed
-func LoggingMiddleware(logger *zap.Logger) stride.MiddlewareFunc {
-	return func(next stride.WalkFunc) stride.WalkFunc {
-		return func(ctx context.Context, path string, info os.FileInfo) error {
-			// Skip logging directories to reduce noise
-			if !info.IsDir() {
-				logger.Debug("Processing file",
-					zap.String("path", path),
-					zap.Int64("size", info.Size()),
-					zap.Time("modified", info.ModTime()),
-				)
-			}
-			// Call the next handler in the chain
-			err := next(ctx, path, info)
-			if err != nil {
-				logger.Error("Error processing file",
-					zap.String("path", path),
-					zap.Error(err),
-				)
-			}
-			return err
-		}
-	}
-}
-
-// Timing middleware that measures how long processing takes for each file
-func TimingMiddleware() stride.MiddlewareFunc {
-	return func(next stride.WalkFunc) stride.WalkFunc {
-		return func(ctx context.Context, path string, info os.FileInfo) error {
-			// Skip timing directories
-			if info.IsDir() {
-				return next(ctx, path, info)
-			}
-
-			start := time.Now()
-			// Call the next handler in the chain
-			err := next(ctx, path, info)
-			duration := time.Since(start)
-
-			// Only log files that take longer than 10ms to process
-			if duration > 10*time.Millisecond {
-				fmt.Printf("Processing %s took %v\n", path, duration)
-			}
-
-			return err
-		}
-	}
-}
-
 func main() {
 	// Set up cancellable context for graceful shutdown
 	ctx, cancel := context.WithCancel(context.Background())
@@ -99,7 +50,7 @@ func main() {
 		Filter: stride.FilterOptions{
 			MinSize:   0,
 			MaxSize:   1024 * 1024 * 10, // Only process files up to 10MB
-			MinDepth:  1,                // Skip the root directory itself
+			MinDepth:  1,                // Start at the root's entries; see IncludeRoot
 			FileTypes: []string{"file"}, // Only process regular files
 		},
 		ErrorHandlingMode: stride.ContinueOnError,
@@ -113,8 +64,15 @@ func main() {
 		},
 		Logger: logger,
 		Middleware: []stride.MiddlewareFunc{
-			LoggingMiddleware(logger),
-			TimingMiddleware(),
+			// Skip logging directories to reduce noise
+			stride.LoggingMiddleware(logger, stride.LogConfig{Level: zap.DebugLevel}),
+			// Only report files that take longer than 10ms to process
+			stride.TimingMiddleware(stride.TimingConfig{
+				Threshold: 10 * time.Millisecond,
+				OnSlow: func(path string, d time.Duration) {
+					fmt.Printf("Processing %s took %v\n", path, d)
+				},
+			}),
 		},
 	}
 
@@ -13,9 +13,7 @@ import (
 	"io"
 	"os"
 	"path/filepath"
-	"sort"
 	"strings"
-	"sync"
 	"time"
 
 	stride "github.com/TFMV/stride/walk"
@@ -40,13 +38,10 @@ type HashOptions struct {
 	SHA256 bool
 }
 
-// Global variables to store results and provide synchronization
+// Global variables for the run
 var (
-	results      []HashResult
-	resultsMutex sync.Mutex
-	startTime    time.Time
-	totalBytes   int64
-	hashOpts     HashOptions
+	startTime time.Time
+	hashOpts  HashOptions
 )
 
 func main() {
@@ -92,8 +87,6 @@ func main() {
 	fmt.Printf("Computing hashes for files in: %s\n", absPath)
 	fmt.Printf("Using algorithms: %s\n", selectedAlgorithms(hashOpts))
 
-	// Initialize results slice
-	results = make([]HashResult, 0, 100)
 	startTime = time.Now()
 
 	// Create context for cancellation
@@ -117,32 +110,14 @@ func main() {
 				stats.SpeedMBPerSec,
 			)
 		},
-		Logger: logger,
+		Logger:      logger,
+		SortResults: true, // Sort results by path for consistent output
 	}
 
-	// Our processing function
-	walkFn := func(ctx context.Context, path string, info os.FileInfo) error {
-		// Skip directories (should be filtered out already, but just in case)
-		if info.IsDir() {
-			return nil
-		}
-
-		// Compute hashes
-		result, err := computeFileHashes(path, info, hashOpts)
-		if err != nil {
-			return err
-		}
-
-		// Store result
-		resultsMutex.Lock()
-		results = append(results, result)
-		resultsMutex.Unlock()
-
-		return nil
-	}
-
-	// Start the traversal
-	err := stride.WalkWithOptions(*rootDir, walkFn, opts)
+	// Hash each file on the worker pool, collecting the results
+	results, err := stride.Map(ctx, *rootDir, func(ctx context.Context, path string, info os.FileInfo) (HashResult, error) {
+		return computeFileHashes(path, info, hashOpts)
+	}, opts)
 
 	// Print final newline after progress updates
 	fmt.Println()
@@ -152,11 +127,6 @@ func main() {
 		os.Exit(1)
 	}
 
-	// Sort results by path for consistent output
-	sort.Slice(results, func(i, j int) bool {
-		return results[i].Path < results[j].Path
-	})
-
 	// Output results
 	switch strings.ToLower(*outputFormat) {
 	case "csv":
@@ -166,6 +136,10 @@ func main() {
 	}
 
 	// Print summary
+	var totalBytes int64
+	for _, result := range results {
+		totalBytes += result.Size
+	}
 	duration := time.Since(startTime)
 	fmt.Printf("\nSummary:\n")
 	fmt.Printf("  Directory: %s\n", absPath)
@@ -224,11 +198,6 @@ func computeFileHashes(path string, info os.FileInfo, opts HashOptions) (HashRes
 		}, err
 	}
 
-	// Update total bytes processed
-	resultsMutex.Lock()
-	totalBytes += bytesRead
-	resultsMutex.Unlock()
-
 	// Create result
 	result := HashResult{
 		Path:     path,
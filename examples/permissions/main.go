@@ -41,13 +41,20 @@ func main() {
 	}
 	walkWithFilter(dir, filter3)
 
-	// Example 4: Combining permission filters with other filters
-	fmt.Println("\n--- Go files with read permissions for all ---")
+	// Example 4: Find world-writable files
+	fmt.Println("\n--- World-writable files ---")
 	filter4 := stride.FilterOptions{
+		WritableByOther: true,
+	}
+	walkWithFilter(dir, filter4)
+
+	// Example 5: Combining permission filters with other filters
+	fmt.Println("\n--- Go files with read permissions for all ---")
+	filter5 := stride.FilterOptions{
 		MinPermissions: 0444,
 		IncludeTypes:   []string{".go"},
 	}
-	walkWithFilter(dir, filter4)
+	walkWithFilter(dir, filter5)
 }
 
 func walkWithFilter(root string, filter stride.FilterOptions) {
@@ -0,0 +1,210 @@
+// Package color maps file types and names to ANSI terminal colors for the
+// stride CLI, in the style of ls --color.
+//
+// Colors are configured with a simplified LS_COLORS syntax: colon-separated
+// key=SGR entries such as "di=01;34:ln=01;36:*.go=33". The supported keys are
+// di (directory), ln (symlink), ex (executable file), fi (regular file),
+// pi (named pipe), so (socket), bd (block device), cd (character device),
+// mt (highlighted match) and *.ext for file extensions.
+package color
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Mode selects when output is colored.
+type Mode string
+
+const (
+	Auto   Mode = "auto"   // Color only when writing to a terminal and NO_COLOR is unset
+	Always Mode = "always" // Always color
+	Never  Mode = "never"  // Never color
+)
+
+// ParseMode parses a --color flag value.
+func ParseMode(s string) (Mode, error) {
+	switch m := Mode(strings.ToLower(s)); m {
+	case Auto, Always, Never:
+		return m, nil
+	case "":
+		return Auto, nil
+	}
+	return "", fmt.Errorf("invalid color mode: %s (expected auto, always or never)", s)
+}
+
+// Enabled reports whether output written to f should be colored under mode.
+func Enabled(mode Mode, f *os.File) bool {
+	switch mode {
+	case Always:
+		return true
+	case Never:
+		return false
+	}
+	if _, set := os.LookupEnv("NO_COLOR"); set {
+		return false
+	}
+	return isTerminal(f)
+}
+
+// isTerminal reports whether f is a character device such as a TTY.
+func isTerminal(f *os.File) bool {
+	if f == nil {
+		return false
+	}
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// defaultCodes are the built-in colors, matching common ls defaults.
+var defaultCodes = map[string]string{
+	"di": "01;34",
+	"ln": "01;36",
+	"ex": "01;32",
+	"pi": "33",
+	"so": "01;35",
+	"bd": "01;33",
+	"cd": "01;33",
+	"mt": "01;31",
+}
+
+// Colorizer decorates names with ANSI escape sequences. A disabled or nil
+// Colorizer returns text unchanged.
+type Colorizer struct {
+	enabled bool
+	codes   map[string]string
+}
+
+// New creates a Colorizer with the default colors.
+func New(enabled bool) *Colorizer {
+	codes := make(map[string]string, len(defaultCodes))
+	for k, v := range defaultCodes {
+		codes[k] = v
+	}
+	return &Colorizer{enabled: enabled, codes: codes}
+}
+
+// Enabled reports whether c emits escape sequences.
+func (c *Colorizer) Enabled() bool {
+	return c != nil && c.enabled
+}
+
+// Apply overrides colors from an LS_COLORS-style spec. Malformed entries are
+// ignored, as ls does; an empty SGR value removes the color for that key.
+func (c *Colorizer) Apply(spec string) {
+	for _, entry := range strings.Split(spec, ":") {
+		key, code, ok := strings.Cut(entry, "=")
+		if !ok || key == "" || strings.Trim(code, "0123456789;") != "" {
+			continue
+		}
+		if strings.HasPrefix(key, "*") {
+			key = strings.ToLower(key)
+		}
+		c.codes[key] = code
+	}
+}
+
+// Code returns the SGR code for an entry with the given mode and name, or ""
+// for no color.
+func (c *Colorizer) Code(mode fs.FileMode, name string) string {
+	switch {
+	case mode&fs.ModeSymlink != 0:
+		return c.codes["ln"]
+	case mode.IsDir():
+		return c.codes["di"]
+	case mode&fs.ModeNamedPipe != 0:
+		return c.codes["pi"]
+	case mode&fs.ModeSocket != 0:
+		return c.codes["so"]
+	case mode&fs.ModeCharDevice != 0:
+		return c.codes["cd"]
+	case mode&fs.ModeDevice != 0:
+		return c.codes["bd"]
+	case mode&0111 != 0:
+		if code, ok := c.codes["ex"]; ok {
+			return code
+		}
+	}
+	if ext := filepath.Ext(name); ext != "" {
+		if code, ok := c.codes["*"+strings.ToLower(ext)]; ok {
+			return code
+		}
+	}
+	return c.codes["fi"]
+}
+
+// Paint wraps text in the escape sequence for code.
+func (c *Colorizer) Paint(code, text string) string {
+	if !c.Enabled() || code == "" || text == "" {
+		return text
+	}
+	return "\x1b[" + code + "m" + text + "\x1b[0m"
+}
+
+// Name colors a file name according to its mode and extension.
+func (c *Colorizer) Name(name string, mode fs.FileMode) string {
+	if !c.Enabled() {
+		return name
+	}
+	return c.Paint(c.Code(mode, name), name)
+}
+
+// Path colors the final element of path according to its mode, leaving the
+// directory part uncolored.
+func (c *Colorizer) Path(path string, mode fs.FileMode) string {
+	if !c.Enabled() {
+		return path
+	}
+	dir, base := splitPath(path)
+	return dir + c.Name(base, mode)
+}
+
+// Match colors path like Path and additionally highlights the byte ranges
+// of path given as [start, end) pairs with the match color. Ranges must be
+// ordered and non-overlapping; invalid ranges are ignored.
+func (c *Colorizer) Match(path string, mode fs.FileMode, ranges [][2]int) string {
+	if !c.Enabled() {
+		return path
+	}
+	dir, base := splitPath(path)
+	baseCode := c.Code(mode, base)
+
+	// paint writes path[from:to], coloring the part within the base name
+	// with the type color
+	var b strings.Builder
+	paint := func(from, to int) {
+		for from < to {
+			end := to
+			if from < len(dir) && end > len(dir) {
+				end = len(dir)
+			}
+			if from < len(dir) {
+				b.WriteString(path[from:end])
+			} else {
+				b.WriteString(c.Paint(baseCode, path[from:end]))
+			}
+			from = end
+		}
+	}
+
+	last := 0
+	for _, r := range ranges {
+		if r[0] < last || r[1] > len(path) || r[0] >= r[1] {
+			continue
+		}
+		paint(last, r[0])
+		b.WriteString(c.Paint(c.codes["mt"], path[r[0]:r[1]]))
+		last = r[1]
+	}
+	paint(last, len(path))
+	return b.String()
+}
+
+// splitPath splits path after its last separator.
+func splitPath(path string) (dir, base string) {
+	i := strings.LastIndexAny(path, `/`+string(os.PathSeparator))
+	return path[:i+1], path[i+1:]
+}
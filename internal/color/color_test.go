@@ -0,0 +1,130 @@
+package color
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// renderListing renders the entries of dir one per line, as the CLI does.
+func renderListing(t *testing.T, c *Colorizer, dir string) map[string]string {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("Failed to read fixture: %v", err)
+	}
+	lines := make(map[string]string)
+	for _, e := range entries {
+		info, err := os.Lstat(filepath.Join(dir, e.Name()))
+		if err != nil {
+			t.Fatalf("Failed to stat %s: %v", e.Name(), err)
+		}
+		lines[e.Name()] = c.Path(filepath.Join("fixture", e.Name()), info.Mode())
+	}
+	return lines
+}
+
+func TestColorListing(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.Mkdir(filepath.Join(dir, "subdir"), 0755); err != nil {
+		t.Fatalf("Failed to create directory: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "notes.txt"), []byte("x"), 0644); err != nil {
+		t.Fatalf("Failed to create file: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "run.sh"), []byte("x"), 0755); err != nil {
+		t.Fatalf("Failed to create file: %v", err)
+	}
+	if err := os.Symlink("notes.txt", filepath.Join(dir, "link")); err != nil {
+		t.Skipf("Symlinks not supported: %v", err)
+	}
+
+	t.Run("Always", func(t *testing.T) {
+		lines := renderListing(t, New(true), dir)
+		expected := map[string]string{
+			"subdir":    "fixture/\x1b[01;34msubdir\x1b[0m",
+			"link":      "fixture/\x1b[01;36mlink\x1b[0m",
+			"run.sh":    "fixture/\x1b[01;32mrun.sh\x1b[0m",
+			"notes.txt": "fixture/notes.txt",
+		}
+		for name, want := range expected {
+			if lines[name] != want {
+				t.Errorf("%s: expected %q, got %q", name, want, lines[name])
+			}
+		}
+	})
+
+	t.Run("Never", func(t *testing.T) {
+		lines := renderListing(t, New(false), dir)
+		var names []string
+		for name, line := range lines {
+			if strings.ContainsRune(line, '\x1b') {
+				t.Errorf("%s: unexpected escape sequence in %q", name, line)
+			}
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		if strings.Join(names, ",") != "link,notes.txt,run.sh,subdir" {
+			t.Errorf("Unexpected listing: %v", names)
+		}
+	})
+
+	t.Run("Overrides", func(t *testing.T) {
+		c := New(true)
+		c.Apply("di=00;33:fi=37:*.TXT=35:ex=:bogus")
+		lines := renderListing(t, c, dir)
+		expected := map[string]string{
+			"subdir":    "fixture/\x1b[00;33msubdir\x1b[0m",
+			"notes.txt": "fixture/\x1b[35mnotes.txt\x1b[0m",
+			"run.sh":    "fixture/run.sh",
+		}
+		for name, want := range expected {
+			if lines[name] != want {
+				t.Errorf("%s: expected %q, got %q", name, want, lines[name])
+			}
+		}
+	})
+}
+
+func TestColorMatch(t *testing.T) {
+	c := New(true)
+	got := c.Match("src/main.go", os.ModeDir, [][2]int{{0, 3}, {8, 11}})
+	want := "\x1b[01;31msrc\x1b[0m/\x1b[01;34mmain\x1b[0m\x1b[01;31m.go\x1b[0m"
+	if got != want {
+		t.Errorf("Expected %q, got %q", want, got)
+	}
+
+	if got := New(false).Match("src/main.go", 0, [][2]int{{0, 3}}); got != "src/main.go" {
+		t.Errorf("Expected plain path when disabled, got %q", got)
+	}
+}
+
+func TestParseModeAndEnabled(t *testing.T) {
+	for _, s := range []string{"auto", "ALWAYS", "never", ""} {
+		if _, err := ParseMode(s); err != nil {
+			t.Errorf("ParseMode(%q) failed: %v", s, err)
+		}
+	}
+	if _, err := ParseMode("sometimes"); err == nil {
+		t.Error("Expected error for invalid mode")
+	}
+
+	// Files and pipes are not terminals, so auto never colors them
+	f, err := os.CreateTemp(t.TempDir(), "out")
+	if err != nil {
+		t.Fatalf("Failed to create file: %v", err)
+	}
+	defer f.Close()
+	if Enabled(Auto, f) {
+		t.Error("Expected auto to disable color for a regular file")
+	}
+	if !Enabled(Always, f) || Enabled(Never, f) {
+		t.Error("Expected always and never to ignore the output type")
+	}
+
+	t.Setenv("NO_COLOR", "1")
+	if Enabled(Auto, os.Stdout) {
+		t.Error("Expected NO_COLOR to disable auto color")
+	}
+}
@@ -0,0 +1,165 @@
+// Package lockfile keeps a single instance of a long-running stride command,
+// such as watch or serve, working on the same tree.
+//
+// A lock file is held with an exclusive flock, or LockFileEx on Windows, and
+// records the PID of its holder. The operating system drops the lock when
+// the holder exits, however it exits, so a file left behind by a process
+// that died is taken over rather than blocking the next start.
+package lockfile
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// ErrLocked is matched by the *HeldError returned by Acquire when another
+// process holds the lock.
+var ErrLocked = errors.New("lockfile: locked by another process")
+
+// HeldError reports a lock file held by another process.
+type HeldError struct {
+	Path string
+	PID  int // PID recorded by the holder, 0 if it could not be read
+}
+
+func (e *HeldError) Error() string {
+	if e.PID == 0 {
+		return fmt.Sprintf("%s is locked by another process", e.Path)
+	}
+	return fmt.Sprintf("%s is locked by process %d", e.Path, e.PID)
+}
+
+// Is reports whether target is ErrLocked.
+func (e *HeldError) Is(target error) bool {
+	return target == ErrLocked
+}
+
+// Lock is a held lock file.
+type Lock struct {
+	path string
+	f    *os.File
+
+	// StalePID is the PID left in the file by a previous holder that
+	// exited without removing it, or 0 if the file was new or empty.
+	StalePID int
+}
+
+// Acquire creates or opens the lock file at path, takes the lock without
+// waiting and writes the PID of this process to it. If another process
+// holds the lock, it returns a *HeldError naming that process.
+func Acquire(path string) (*Lock, error) {
+	for {
+		f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0644)
+		if err != nil {
+			return nil, err
+		}
+		if err := lockFile(f); err != nil {
+			f.Close()
+			if errors.Is(err, errWouldBlock) {
+				pid, _ := ReadPID(path)
+				return nil, &HeldError{Path: path, PID: pid}
+			}
+			return nil, fmt.Errorf("lock %s: %w", path, err)
+		}
+
+		// The previous holder removes the file on release, possibly after
+		// it was opened here; locking the removed file would lock nothing
+		if !samePath(f, path) {
+			unlockFile(f)
+			f.Close()
+			continue
+		}
+
+		l := &Lock{path: path, f: f}
+		l.StalePID, _ = readPID(f)
+		if err := writePID(f); err != nil {
+			l.Release()
+			return nil, fmt.Errorf("write %s: %w", path, err)
+		}
+		return l, nil
+	}
+}
+
+// Release removes the lock file and releases the lock. It does nothing for
+// a nil Lock, so a lock that may not have been taken can be released with
+// a plain defer.
+func (l *Lock) Release() error {
+	if l == nil || l.f == nil {
+		return nil
+	}
+
+	// Remove the file before unlocking where open files can be removed, so
+	// that no other process locks it only to find it gone
+	var err error
+	if removeWhileOpen {
+		err = os.Remove(l.path)
+	}
+	unlockFile(l.f)
+	if cerr := l.f.Close(); err == nil {
+		err = cerr
+	}
+	if !removeWhileOpen && err == nil {
+		err = os.Remove(l.path)
+	}
+	l.f = nil
+	return err
+}
+
+// ReadPID returns the PID recorded in the lock file at path.
+func ReadPID(path string) (int, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0, err
+	}
+	return parsePID(data)
+}
+
+// readPID returns the PID recorded in f.
+func readPID(f *os.File) (int, error) {
+	buf := make([]byte, 32)
+	n, err := f.ReadAt(buf, 0)
+	if n == 0 && err != nil {
+		return 0, err
+	}
+	return parsePID(buf[:n])
+}
+
+// parsePID parses the contents of a lock file.
+func parsePID(data []byte) (int, error) {
+	s := strings.TrimSpace(string(data))
+	if s == "" {
+		return 0, nil
+	}
+	pid, err := strconv.Atoi(s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid lock file contents %q", s)
+	}
+	return pid, nil
+}
+
+// writePID replaces the contents of f with the PID of this process.
+func writePID(f *os.File) error {
+	if err := f.Truncate(0); err != nil {
+		return err
+	}
+	if _, err := f.WriteAt([]byte(strconv.Itoa(os.Getpid())+"\n"), 0); err != nil {
+		return err
+	}
+	return f.Sync()
+}
+
+// samePath reports whether path still names the open file f.
+func samePath(f *os.File, path string) bool {
+	fi, err := f.Stat()
+	if err != nil {
+		return false
+	}
+	pi, err := os.Stat(path)
+	if err != nil {
+		return false
+	}
+	return os.SameFile(fi, pi)
+}
@@ -0,0 +1,25 @@
+//go:build !darwin && !linux && !windows
+
+package lockfile
+
+import (
+	"errors"
+	"os"
+)
+
+// errWouldBlock is never returned on this platform.
+var errWouldBlock = errors.New("lockfile: would block")
+
+// removeWhileOpen is set where a locked file can be removed before it is
+// closed.
+const removeWhileOpen = false
+
+// lockFile fails: lock files are not supported on this platform.
+func lockFile(f *os.File) error {
+	return errors.New("lock files are not supported on this platform")
+}
+
+// unlockFile does nothing on this platform.
+func unlockFile(f *os.File) error {
+	return nil
+}
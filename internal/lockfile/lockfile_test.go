@@ -0,0 +1,161 @@
+package lockfile
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// TestMain takes the lock file named by LOCKFILE_TEST_HOLD instead of
+// running the tests, so that tests can contend with other processes. The
+// child prints "locked" once it holds the lock and releases it when its
+// standard input is closed.
+func TestMain(m *testing.M) {
+	if path, ok := os.LookupEnv("LOCKFILE_TEST_HOLD"); ok {
+		l, err := Acquire(path)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		fmt.Println("locked")
+		bufio.NewReader(os.Stdin).ReadString('\n')
+		l.Release()
+		os.Exit(0)
+	}
+	os.Exit(m.Run())
+}
+
+// holder is a child process holding or trying to take a lock file.
+type holder struct {
+	cmd    *exec.Cmd
+	stdin  interface{ Close() error }
+	stdout *bufio.Reader
+}
+
+// startHolder starts a child process taking the lock file at path.
+func startHolder(t *testing.T, path string) *holder {
+	t.Helper()
+	cmd := exec.Command(os.Args[0])
+	cmd.Env = append(os.Environ(), "LOCKFILE_TEST_HOLD="+path)
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		t.Fatalf("Failed to create pipe: %v", err)
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		t.Fatalf("Failed to create pipe: %v", err)
+	}
+	if err := cmd.Start(); err != nil {
+		t.Fatalf("Failed to start child: %v", err)
+	}
+	h := &holder{cmd: cmd, stdin: stdin, stdout: bufio.NewReader(stdout)}
+	t.Cleanup(func() {
+		stdin.Close()
+		cmd.Wait()
+	})
+	return h
+}
+
+// locked waits for the child to report whether it took the lock.
+func (h *holder) locked() bool {
+	line, _ := h.stdout.ReadString('\n')
+	return strings.TrimSpace(line) == "locked"
+}
+
+// release makes the child release the lock and waits for it to exit.
+func (h *holder) release(t *testing.T) {
+	t.Helper()
+	h.stdin.Close()
+	if err := h.cmd.Wait(); err != nil {
+		t.Fatalf("Child failed: %v", err)
+	}
+}
+
+func TestAcquireRelease(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "stride.lock")
+	l, err := Acquire(path)
+	if err != nil {
+		t.Fatalf("Acquire failed: %v", err)
+	}
+	if pid, err := ReadPID(path); err != nil || pid != os.Getpid() {
+		t.Errorf("Expected the lock file to hold PID %d, got %d (%v)", os.Getpid(), pid, err)
+	}
+	if l.StalePID != 0 {
+		t.Errorf("Expected no stale PID for a new lock file, got %d", l.StalePID)
+	}
+
+	if err := l.Release(); err != nil {
+		t.Fatalf("Release failed: %v", err)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("Expected the lock file to be removed, got %v", err)
+	}
+	if err := l.Release(); err != nil {
+		t.Errorf("Expected a second release to do nothing, got %v", err)
+	}
+	var none *Lock
+	if err := none.Release(); err != nil {
+		t.Errorf("Expected releasing a nil lock to do nothing, got %v", err)
+	}
+}
+
+func TestStaleLock(t *testing.T) {
+	// A process that has exited
+	dead := exec.Command(os.Args[0], "-test.run=^$")
+	if err := dead.Run(); err != nil {
+		t.Fatalf("Failed to run child: %v", err)
+	}
+	deadPID := dead.Process.Pid
+
+	path := filepath.Join(t.TempDir(), "stride.lock")
+	if err := os.WriteFile(path, []byte(strconv.Itoa(deadPID)+"\n"), 0644); err != nil {
+		t.Fatalf("Failed to create lock file: %v", err)
+	}
+	l, err := Acquire(path)
+	if err != nil {
+		t.Fatalf("Expected the stale lock to be taken over, got %v", err)
+	}
+	defer l.Release()
+	if l.StalePID != deadPID {
+		t.Errorf("Expected stale PID %d, got %d", deadPID, l.StalePID)
+	}
+	if pid, _ := ReadPID(path); pid != os.Getpid() {
+		t.Errorf("Expected the lock file to hold PID %d, got %d", os.Getpid(), pid)
+	}
+}
+
+func TestContention(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "stride.lock")
+	first := startHolder(t, path)
+	if !first.locked() {
+		t.Fatal("Expected the first process to take the lock")
+	}
+
+	// Neither another process nor this one can take it
+	second := startHolder(t, path)
+	if second.locked() {
+		t.Fatal("Expected the second process to be refused")
+	}
+	if err := second.cmd.Wait(); err == nil {
+		t.Error("Expected the second process to fail")
+	}
+	_, err := Acquire(path)
+	var held *HeldError
+	if !errors.Is(err, ErrLocked) || !errors.As(err, &held) || held.PID != first.cmd.Process.Pid {
+		t.Errorf("Expected the lock to be held by process %d, got %v", first.cmd.Process.Pid, err)
+	}
+
+	// Once released, the lock can be taken again
+	first.release(t)
+	third := startHolder(t, path)
+	if !third.locked() {
+		t.Fatal("Expected the lock to be free once released")
+	}
+	third.release(t)
+}
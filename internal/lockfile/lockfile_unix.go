@@ -0,0 +1,26 @@
+//go:build darwin || linux
+
+package lockfile
+
+import (
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// errWouldBlock is returned by lockFile when another process holds the lock.
+var errWouldBlock = unix.EWOULDBLOCK
+
+// removeWhileOpen is set where a locked file can be removed before it is
+// closed.
+const removeWhileOpen = true
+
+// lockFile takes an exclusive flock on f without waiting.
+func lockFile(f *os.File) error {
+	return unix.Flock(int(f.Fd()), unix.LOCK_EX|unix.LOCK_NB)
+}
+
+// unlockFile releases the flock on f.
+func unlockFile(f *os.File) error {
+	return unix.Flock(int(f.Fd()), unix.LOCK_UN)
+}
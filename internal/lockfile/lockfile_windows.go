@@ -0,0 +1,33 @@
+package lockfile
+
+import (
+	"os"
+
+	"golang.org/x/sys/windows"
+)
+
+// errWouldBlock is returned by lockFile when another process holds the lock.
+var errWouldBlock = windows.ERROR_LOCK_VIOLATION
+
+// removeWhileOpen is set where a locked file can be removed before it is
+// closed.
+const removeWhileOpen = false
+
+// The locked byte range lies far beyond the recorded PID, which other
+// processes have to be able to read.
+const (
+	lockOffsetHigh = 0x7fffffff
+	lockLength     = 1
+)
+
+// lockFile takes an exclusive lock on f with LockFileEx without waiting.
+func lockFile(f *os.File) error {
+	ol := windows.Overlapped{OffsetHigh: lockOffsetHigh}
+	return windows.LockFileEx(windows.Handle(f.Fd()), windows.LOCKFILE_EXCLUSIVE_LOCK|windows.LOCKFILE_FAIL_IMMEDIATELY, 0, lockLength, 0, &ol)
+}
+
+// unlockFile releases the lock on f.
+func unlockFile(f *os.File) error {
+	ol := windows.Overlapped{OffsetHigh: lockOffsetHigh}
+	return windows.UnlockFileEx(windows.Handle(f.Fd()), 0, lockLength, 0, &ol)
+}
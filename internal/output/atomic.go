@@ -0,0 +1,213 @@
+// Package output writes the results of long-running commands to files that
+// readers can trust: a file either holds the complete output of a run that
+// succeeded, or says that it does not.
+package output
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// ErrClosed is returned by writes to an AtomicWriter after Commit or Close.
+var ErrClosed = errors.New("output: writer is closed")
+
+// Options configures an AtomicWriter.
+type Options struct {
+	// Append appends the output to the file as it is written, instead of
+	// replacing the file once the output is complete. Commit then ends it
+	// with the Footer record, whose absence marks a run that failed.
+	Append bool
+
+	// SyncInterval flushes the output and syncs it to disk at most this
+	// often while it is written, so that little is lost if the machine
+	// fails. Output is always synced by Commit; 0 syncs only then.
+	SyncInterval time.Duration
+
+	// Footer returns the record that Commit writes in append mode, given
+	// the number of lines written. DefaultFooter is used if it is nil.
+	Footer func(records int64) []byte
+}
+
+// DefaultFooter returns a JSON line recording a complete run and the number
+// of lines it wrote: {"complete":true,"records":N}.
+func DefaultFooter(records int64) []byte {
+	return []byte(fmt.Sprintf("{\"complete\":true,\"records\":%d}\n", records))
+}
+
+// AtomicWriter writes a file that readers only ever see complete. By
+// default the output goes to a temporary file beside the target, which
+// Commit syncs and renames into place, so the target keeps its previous
+// contents, or stays absent, until the run succeeds. In append mode the
+// output goes straight to the target and Commit ends it with a footer.
+//
+// Writes are buffered and safe for concurrent use. Close discards the
+// output of a writer that was not committed, removing its temporary file;
+// after Commit it does nothing, so a writer can be closed with a plain
+// defer and committed once the run succeeds.
+type AtomicWriter struct {
+	mu       sync.Mutex
+	path     string // Target file
+	tmp      string // Temporary file renamed to path, empty in append mode
+	f        *os.File
+	buf      *bufio.Writer
+	opts     Options
+	records  int64
+	lastSync time.Time
+	err      error // First write error, returned by every later call
+}
+
+// Create starts writing the file at path.
+func Create(path string, opts Options) (*AtomicWriter, error) {
+	w := &AtomicWriter{path: path, opts: opts, lastSync: time.Now()}
+	if opts.Append {
+		f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+		if err != nil {
+			return nil, err
+		}
+		w.f = f
+	} else {
+		f, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".tmp-*")
+		if err != nil {
+			return nil, err
+		}
+		// The file keeps the mode of the one it replaces, where the
+		// filesystem allows it
+		mode := os.FileMode(0644)
+		if info, err := os.Stat(path); err == nil {
+			mode = info.Mode().Perm()
+		}
+		f.Chmod(mode)
+		w.f, w.tmp = f, f.Name()
+	}
+	w.buf = bufio.NewWriterSize(w.f, 64*1024)
+	return w, nil
+}
+
+// Write buffers p, flushing and syncing the output if SyncInterval has
+// passed since it was last synced.
+func (w *AtomicWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.err != nil {
+		return 0, w.err
+	}
+	if w.f == nil {
+		return 0, ErrClosed
+	}
+	n, err := w.buf.Write(p)
+	w.records += int64(bytes.Count(p[:n], []byte{'\n'}))
+	if err != nil {
+		w.err = err
+		return n, err
+	}
+	if w.opts.SyncInterval > 0 && time.Since(w.lastSync) >= w.opts.SyncInterval {
+		if err := w.sync(); err != nil {
+			w.err = err
+			return n, err
+		}
+	}
+	return n, nil
+}
+
+// Records returns the number of lines written so far.
+func (w *AtomicWriter) Records() int64 {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.records
+}
+
+// sync flushes the buffer and syncs the file to disk.
+func (w *AtomicWriter) sync() error {
+	if err := w.buf.Flush(); err != nil {
+		return err
+	}
+	w.lastSync = time.Now()
+	return w.f.Sync()
+}
+
+// Commit completes the output: it writes the footer in append mode, syncs
+// the file and renames it into place. If any write or this completion
+// failed, it discards the output like Close and returns the error.
+func (w *AtomicWriter) Commit() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.f == nil {
+		return ErrClosed
+	}
+	err := w.err
+	if err == nil && w.opts.Append {
+		footer := w.opts.Footer
+		if footer == nil {
+			footer = DefaultFooter
+		}
+		_, err = w.buf.Write(footer(w.records))
+	}
+	if err == nil {
+		err = w.sync()
+	}
+	if err != nil {
+		w.discard()
+		return err
+	}
+
+	f := w.f
+	w.f = nil
+	if err := f.Close(); err != nil {
+		if w.tmp != "" {
+			os.Remove(w.tmp)
+		}
+		return err
+	}
+	if w.tmp == "" {
+		return nil
+	}
+	if err := os.Rename(w.tmp, w.path); err != nil {
+		os.Remove(w.tmp)
+		return err
+	}
+	syncDir(filepath.Dir(w.path))
+	return nil
+}
+
+// Close discards the output of a writer that was not committed, and does
+// nothing otherwise. An appended file is left without its footer.
+func (w *AtomicWriter) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.f == nil {
+		return nil
+	}
+	return w.discard()
+}
+
+// discard closes the file, removing it unless appending to the target.
+func (w *AtomicWriter) discard() error {
+	if w.opts.Append {
+		w.buf.Flush()
+	}
+	err := w.f.Close()
+	w.f = nil
+	if w.tmp != "" {
+		if rerr := os.Remove(w.tmp); err == nil {
+			err = rerr
+		}
+	}
+	return err
+}
+
+// syncDir syncs the directory holding a renamed file, so the rename itself
+// survives a crash, where the platform allows it.
+func syncDir(dir string) {
+	d, err := os.Open(dir)
+	if err != nil {
+		return
+	}
+	d.Sync()
+	d.Close()
+}
@@ -0,0 +1,259 @@
+package output
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+var errInjected = errors.New("injected failure")
+
+// run writes records numbered lines to path through an AtomicWriter the
+// way commands do, failing after failAt of them if failAt is positive.
+func run(path string, opts Options, records, failAt int) error {
+	w, err := Create(path, opts)
+	if err != nil {
+		return err
+	}
+	defer w.Close()
+	for i := 1; i <= records; i++ {
+		if failAt > 0 && i > failAt {
+			return errInjected
+		}
+		if _, err := fmt.Fprintf(w, "{\"n\":%d}\n", i); err != nil {
+			return err
+		}
+	}
+	return w.Commit()
+}
+
+// readFile returns the contents of path, or "<absent>" if it does not exist.
+func readFile(t *testing.T, path string) string {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return "<absent>"
+	}
+	if err != nil {
+		t.Fatalf("Failed to read %s: %v", path, err)
+	}
+	return string(data)
+}
+
+// records returns the lines run writes for n records.
+func records(n int) string {
+	var sb strings.Builder
+	for i := 1; i <= n; i++ {
+		fmt.Fprintf(&sb, "{\"n\":%d}\n", i)
+	}
+	return sb.String()
+}
+
+// leftovers returns the names of the files in dir other than keep.
+func leftovers(t *testing.T, dir, keep string) []string {
+	t.Helper()
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("Failed to read %s: %v", dir, err)
+	}
+	var names []string
+	for _, e := range entries {
+		if e.Name() != keep {
+			names = append(names, e.Name())
+		}
+	}
+	return names
+}
+
+func TestAtomicWriterReplace(t *testing.T) {
+	tests := []struct {
+		name     string
+		existing string // Contents of the target before the run, "" for none
+		failAt   int
+		want     string
+	}{
+		{"Failure leaves no file", "", 50, "<absent>"},
+		{"Failure leaves the file unchanged", "previous\n", 50, "previous\n"},
+		{"Success creates the file", "", 0, records(100)},
+		{"Success replaces the file", "previous\n", 0, records(100)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			path := filepath.Join(dir, "out.ndjson")
+			if tt.existing != "" {
+				if err := os.WriteFile(path, []byte(tt.existing), 0600); err != nil {
+					t.Fatalf("Failed to create file: %v", err)
+				}
+			}
+
+			err := run(path, Options{}, 100, tt.failAt)
+			if tt.failAt > 0 && !errors.Is(err, errInjected) {
+				t.Errorf("Expected the injected failure, got %v", err)
+			} else if tt.failAt == 0 && err != nil {
+				t.Fatalf("Run failed: %v", err)
+			}
+			if got := readFile(t, path); got != tt.want {
+				t.Errorf("Expected %q, got %q", tt.want, got)
+			}
+			if names := leftovers(t, dir, "out.ndjson"); len(names) != 0 {
+				t.Errorf("Expected the temporary file to be removed, found %v", names)
+			}
+
+			// A replaced file keeps its mode
+			if tt.existing != "" && tt.failAt == 0 && runtime.GOOS != "windows" {
+				info, err := os.Stat(path)
+				if err != nil {
+					t.Fatalf("Failed to stat %s: %v", path, err)
+				}
+				if info.Mode().Perm() != 0600 {
+					t.Errorf("Expected the replaced file to keep mode 0600, got %v", info.Mode().Perm())
+				}
+			}
+		})
+	}
+}
+
+func TestAtomicWriterAppend(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "out.ndjson")
+	if err := os.WriteFile(path, []byte("previous\n"), 0644); err != nil {
+		t.Fatalf("Failed to create file: %v", err)
+	}
+
+	// A failed run leaves what it wrote, without the footer
+	if err := run(path, Options{Append: true}, 10, 4); !errors.Is(err, errInjected) {
+		t.Errorf("Expected the injected failure, got %v", err)
+	}
+	want := "previous\n" + records(4)
+	if got := readFile(t, path); got != want {
+		t.Errorf("Expected %q, got %q", want, got)
+	}
+
+	// A successful run ends with the footer
+	if err := run(path, Options{Append: true}, 3, 0); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	want += records(3) + "{\"complete\":true,\"records\":3}\n"
+	if got := readFile(t, path); got != want {
+		t.Errorf("Expected %q, got %q", want, got)
+	}
+
+	// The footer can be replaced
+	footer := func(n int64) []byte { return []byte(fmt.Sprintf("# %d records\n", n)) }
+	if err := run(path, Options{Append: true, Footer: footer}, 2, 0); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	want += records(2) + "# 2 records\n"
+	if got := readFile(t, path); got != want {
+		t.Errorf("Expected %q, got %q", want, got)
+	}
+	if names := leftovers(t, dir, "out.ndjson"); len(names) != 0 {
+		t.Errorf("Expected no other files, found %v", names)
+	}
+}
+
+func TestAtomicWriterSyncInterval(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "out.ndjson")
+	w, err := Create(path, Options{Append: true, SyncInterval: time.Nanosecond})
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	defer w.Close()
+
+	// Each write reaches the file before the run completes
+	for i := 1; i <= 3; i++ {
+		fmt.Fprintf(w, "{\"n\":%d}\n", i)
+		time.Sleep(time.Millisecond)
+		if got := readFile(t, path); got != records(i) {
+			t.Errorf("Expected %q on disk, got %q", records(i), got)
+		}
+	}
+
+	// Without an interval, output stays buffered until Commit
+	other := filepath.Join(t.TempDir(), "out.ndjson")
+	w2, err := Create(other, Options{Append: true})
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	defer w2.Close()
+	fmt.Fprintf(w2, "{\"n\":1}\n")
+	if got := readFile(t, other); got != "" {
+		t.Errorf("Expected nothing on disk before Commit, got %q", got)
+	}
+}
+
+func TestAtomicWriterConcurrent(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "out.txt")
+	w, err := Create(path, Options{})
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	defer w.Close()
+
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < 100; i++ {
+				fmt.Fprintf(w, "goroutine %d line %d\n", g, i)
+			}
+		}(g)
+	}
+	wg.Wait()
+	if n := w.Records(); n != 800 {
+		t.Errorf("Expected 800 records, got %d", n)
+	}
+	if err := w.Commit(); err != nil {
+		t.Fatalf("Commit failed: %v", err)
+	}
+
+	// Every line arrives intact
+	lines := strings.Split(strings.TrimSuffix(readFile(t, path), "\n"), "\n")
+	if len(lines) != 800 {
+		t.Fatalf("Expected 800 lines, got %d", len(lines))
+	}
+	for _, line := range lines {
+		var g, i int
+		if _, err := fmt.Sscanf(line, "goroutine %d line %d", &g, &i); err != nil {
+			t.Errorf("Garbled line %q", line)
+		}
+	}
+}
+
+func TestAtomicWriterClosed(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "out.txt")
+	w, err := Create(path, Options{})
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	if err := w.Commit(); err != nil {
+		t.Fatalf("Commit failed: %v", err)
+	}
+	if err := w.Close(); err != nil {
+		t.Errorf("Expected Close after Commit to do nothing, got %v", err)
+	}
+	if _, err := io.WriteString(w, "late\n"); !errors.Is(err, ErrClosed) {
+		t.Errorf("Expected ErrClosed, got %v", err)
+	}
+	if err := w.Commit(); !errors.Is(err, ErrClosed) {
+		t.Errorf("Expected ErrClosed from a second Commit, got %v", err)
+	}
+	if got := readFile(t, path); got != "" {
+		t.Errorf("Expected an empty file, got %q", got)
+	}
+
+	// A directory that does not exist cannot be written
+	if _, err := Create(filepath.Join(path+".d", "out.txt"), Options{}); err == nil {
+		t.Error("Expected an error for a missing directory")
+	}
+}
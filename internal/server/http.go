@@ -0,0 +1,192 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"regexp"
+	"time"
+
+	stride "github.com/TFMV/stride/internal/walk"
+)
+
+// FindRequest is the JSON body of POST /find. It mirrors the matching fields
+// of stride.FindOptions; durations use time.ParseDuration syntax.
+type FindRequest struct {
+	Name          string `json:"name"`           // Match by file name (supports wildcards)
+	Path          string `json:"path"`           // Match by path (supports wildcards)
+	Ignore        string `json:"ignore"`         // Skip paths matching this pattern
+	Regex         string `json:"regex"`          // Match paths by regular expression
+	OlderThan     string `json:"older_than"`     // Files older than this duration
+	NewerThan     string `json:"newer_than"`     // Files newer than this duration
+	LargerThan    int64  `json:"larger_than"`    // Files larger than this size in bytes
+	SmallerThan   int64  `json:"smaller_than"`   // Files smaller than this size in bytes
+	Empty         bool   `json:"empty"`          // Match empty files and empty directories
+	MaxDepth      uint   `json:"max_depth"`      // Maximum depth below the root (0 for no limit)
+	IncludeHidden bool   `json:"include_hidden"` // Include hidden files
+}
+
+// options converts the request to FindOptions.
+func (r FindRequest) options() (stride.FindOptions, error) {
+	opts := stride.FindOptions{
+		NamePattern:   r.Name,
+		PathPattern:   r.Path,
+		IgnorePattern: r.Ignore,
+		LargerSize:    r.LargerThan,
+		SmallerSize:   r.SmallerThan,
+		Empty:         r.Empty,
+		MaxDepth:      r.MaxDepth,
+		IncludeHidden: r.IncludeHidden,
+	}
+	if r.Regex != "" {
+		re, err := regexp.Compile(r.Regex)
+		if err != nil {
+			return opts, fmt.Errorf("invalid regex: %w", err)
+		}
+		opts.RegexPattern = re
+	}
+	if r.OlderThan != "" {
+		d, err := time.ParseDuration(r.OlderThan)
+		if err != nil {
+			return opts, fmt.Errorf("invalid older_than: %w", err)
+		}
+		opts.OlderThan = d
+	}
+	if r.NewerThan != "" {
+		d, err := time.ParseDuration(r.NewerThan)
+		if err != nil {
+			return opts, fmt.Errorf("invalid newer_than: %w", err)
+		}
+		opts.NewerThan = d
+	}
+	return opts, nil
+}
+
+// maxFindRequestSize bounds the JSON body of POST /find.
+const maxFindRequestSize = 1 << 20
+
+// FindMatch is one line of the NDJSON response to POST /find.
+type FindMatch struct {
+	Path  string    `json:"path"`
+	Name  string    `json:"name"`
+	Dir   string    `json:"dir"`
+	Size  int64     `json:"size"`
+	Time  time.Time `json:"time"`
+	IsDir bool      `json:"is_dir"`
+}
+
+// StatsResponse is the JSON response to GET /stats and POST /rescan.
+type StatsResponse struct {
+	Root    string       `json:"root"`
+	Entries int          `json:"entries"`
+	BuiltAt time.Time    `json:"built_at"`
+	Stats   stride.Stats `json:"stats"`
+	Watch   WatchStats   `json:"watch"`
+}
+
+// NewHandler returns the HTTP API for idx:
+//
+//	POST /find    query the index; the body is a FindRequest and the response NDJSON FindMatch lines
+//	GET  /stats   report the index statistics as a StatsResponse
+//	POST /rescan  rebuild the index from disk and report the new statistics
+func NewHandler(idx *Index) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /find", func(w http.ResponseWriter, r *http.Request) {
+		var req FindRequest
+		if r.ContentLength != 0 {
+			body := http.MaxBytesReader(w, r.Body, maxFindRequestSize)
+			if err := json.NewDecoder(body).Decode(&req); err != nil {
+				status := http.StatusBadRequest
+				var tooLarge *http.MaxBytesError
+				if errors.As(err, &tooLarge) {
+					status = http.StatusRequestEntityTooLarge
+				}
+				http.Error(w, fmt.Sprintf("invalid request: %v", err), status)
+				return
+			}
+		}
+		opts, err := req.options()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		enc := json.NewEncoder(w)
+		for _, msg := range idx.Query(opts) {
+			match := FindMatch{Path: msg.Path, Name: msg.Name, Dir: msg.Dir, Size: msg.Size, Time: msg.Time, IsDir: msg.IsDir}
+			if err := enc.Encode(match); err != nil {
+				return
+			}
+		}
+	})
+	mux.HandleFunc("GET /stats", func(w http.ResponseWriter, r *http.Request) {
+		writeStats(w, idx)
+	})
+	mux.HandleFunc("POST /rescan", func(w http.ResponseWriter, r *http.Request) {
+		if err := idx.Rebuild(r.Context()); err != nil {
+			http.Error(w, fmt.Sprintf("rescan failed: %v", err), http.StatusInternalServerError)
+			return
+		}
+		writeStats(w, idx)
+	})
+	return mux
+}
+
+// writeStats writes the index statistics as JSON.
+func writeStats(w http.ResponseWriter, idx *Index) {
+	stats, watch, entries, builtAt := idx.Snapshot()
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(StatsResponse{
+		Root:    idx.Root(),
+		Entries: entries,
+		BuiltAt: builtAt,
+		Stats:   stats,
+		Watch:   watch,
+	})
+}
+
+// Serve builds the index for root, keeps it fresh with a watcher and serves
+// the HTTP API on addr until ctx is canceled.
+func Serve(ctx context.Context, addr, root string, workers int) error {
+	idx := NewIndex(root, workers)
+	if err := idx.Rebuild(ctx); err != nil {
+		return fmt.Errorf("building index: %w", err)
+	}
+
+	// Listen before watching, so a failure leaves nothing running
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	watchErr := make(chan error, 1)
+	go func() {
+		watchErr <- idx.Watch(ctx)
+	}()
+
+	srv := &http.Server{Handler: NewHandler(idx)}
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		srv.Shutdown(shutdownCtx)
+	}()
+
+	// The watcher is stopped and waited for however serving ends
+	serveErr := srv.Serve(ln)
+	cancel()
+	err = <-watchErr
+	if serveErr != nil && !errors.Is(serveErr, http.ErrServerClosed) {
+		return serveErr
+	}
+	if err != nil && !errors.Is(err, context.Canceled) {
+		return fmt.Errorf("watching %s: %w", root, err)
+	}
+	return nil
+}
@@ -0,0 +1,237 @@
+// Package server keeps an in-memory index of a directory tree, built with the
+// concurrent walker and kept fresh with the watch machinery, and serves find
+// queries against it over HTTP.
+package server
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	stride "github.com/TFMV/stride/internal/walk"
+)
+
+// WatchStats counts the filesystem events applied to an index.
+type WatchStats struct {
+	Events    int64     `json:"events"`     // Events applied to the index
+	Creates   int64     `json:"creates"`    // Create events
+	Modifies  int64     `json:"modifies"`   // Modify and chmod events
+	Deletes   int64     `json:"deletes"`    // Delete and rename events
+	Errors    int64     `json:"errors"`     // Watcher errors
+	LastEvent time.Time `json:"last_event"` // Time the last event was applied
+}
+
+// Index is an in-memory snapshot of the entries below a root directory.
+type Index struct {
+	root    string
+	workers int
+
+	mu      sync.RWMutex
+	entries map[string]stride.FindMessage
+	stats   stride.Stats
+	watch   WatchStats
+	builtAt time.Time
+}
+
+// NewIndex creates an empty index for root. Call Rebuild to populate it.
+func NewIndex(root string, workers int) *Index {
+	if workers <= 0 {
+		workers = 4
+	}
+	return &Index{
+		root:    filepath.Clean(root),
+		workers: workers,
+		entries: make(map[string]stride.FindMessage),
+	}
+}
+
+// Root returns the indexed directory.
+func (idx *Index) Root() string {
+	return idx.root
+}
+
+// Rebuild walks the root concurrently and replaces the index contents.
+func (idx *Index) Rebuild(ctx context.Context) error {
+	entries, stats, err := idx.scan(ctx, idx.root)
+	if err != nil {
+		return err
+	}
+
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	idx.entries = entries
+	idx.stats = stats
+	idx.builtAt = time.Now()
+	return nil
+}
+
+// scan walks dir and returns its entries, excluding the index root itself.
+func (idx *Index) scan(ctx context.Context, dir string) (map[string]stride.FindMessage, stride.Stats, error) {
+	var mu sync.Mutex
+	entries := make(map[string]stride.FindMessage)
+	stats, err := stride.WalkLimitWithOptionsStats(ctx, dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil || info == nil {
+			return nil
+		}
+		path = filepath.Clean(path)
+		if path == idx.root {
+			return nil
+		}
+		msg := newMessage(path, info)
+		mu.Lock()
+		entries[path] = msg
+		mu.Unlock()
+		return nil
+	}, stride.WalkOptions{
+		NumWorkers:      idx.workers,
+		ErrorHandling:   stride.ErrorHandlingContinue,
+		SymlinkHandling: stride.SymlinkReport,
+		LogLevel:        stride.LogLevelError,
+	})
+	return entries, stats, err
+}
+
+// newMessage describes an indexed entry.
+func newMessage(path string, info os.FileInfo) stride.FindMessage {
+	return stride.FindMessage{
+		Path:  path,
+		Name:  filepath.Base(path),
+		Dir:   filepath.Dir(path),
+		Size:  info.Size(),
+		Time:  info.ModTime(),
+		IsDir: info.IsDir(),
+		Mode:  info.Mode(),
+	}
+}
+
+// Watch keeps the index up to date until ctx is canceled.
+func (idx *Index) Watch(ctx context.Context) error {
+	opts := stride.WatchOptions{Recursive: true, IncludeHidden: true}
+	return stride.Watch(ctx, idx.root, opts, func(ctx context.Context, result stride.WatchResult) error {
+		if result.Error != nil {
+			idx.mu.Lock()
+			idx.watch.Errors++
+			idx.mu.Unlock()
+			return nil
+		}
+		idx.apply(ctx, result.Message)
+		return nil
+	})
+}
+
+// apply updates the index for a single watch event.
+func (idx *Index) apply(ctx context.Context, msg stride.WatchMessage) {
+	path := filepath.Clean(msg.Path)
+
+	// Scan created directories, whose contents may predate their watch
+	var sub map[string]stride.FindMessage
+	if msg.Event == stride.EventCreate && msg.IsDir {
+		sub, _, _ = idx.scan(ctx, path)
+	}
+	info, statErr := os.Lstat(path)
+
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	idx.watch.Events++
+	idx.watch.LastEvent = time.Now()
+
+	switch msg.Event {
+	case stride.EventCreate:
+		idx.watch.Creates++
+	case stride.EventModify, stride.EventChmod:
+		idx.watch.Modifies++
+	case stride.EventDelete, stride.EventRename:
+		idx.watch.Deletes++
+	}
+
+	if statErr != nil {
+		// The entry is gone: drop it and anything below it
+		delete(idx.entries, path)
+		prefix := path + string(os.PathSeparator)
+		for p := range idx.entries {
+			if strings.HasPrefix(p, prefix) {
+				delete(idx.entries, p)
+			}
+		}
+		return
+	}
+	if path != idx.root {
+		idx.entries[path] = newMessage(path, info)
+	}
+	for p, m := range sub {
+		idx.entries[p] = m
+	}
+}
+
+// Query returns the entries matching opts, sorted by path. Matching follows
+// stride.MatchFind, with these differences: MaxDepth limits the depth of
+// reported entries below the root (root's children are at depth 1) and 0
+// means no limit, hidden entries are excluded unless IncludeHidden is set,
+// and directories are only reported when Empty is set, like Find.
+func (idx *Index) Query(opts stride.FindOptions) []stride.FindMessage {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+
+	// Emptiness is answered from the index instead of the disk
+	empty := opts.Empty
+	opts.Empty = false
+	var nonEmpty map[string]bool
+	if empty {
+		nonEmpty = make(map[string]bool)
+		for _, msg := range idx.entries {
+			nonEmpty[msg.Dir] = true
+		}
+	}
+
+	var matches []stride.FindMessage
+	for path, msg := range idx.entries {
+		rel, err := filepath.Rel(idx.root, path)
+		if err != nil {
+			continue
+		}
+		parts := strings.Split(rel, string(os.PathSeparator))
+		if opts.MaxDepth > 0 && uint(len(parts)) > opts.MaxDepth {
+			continue
+		}
+		if !opts.IncludeHidden && hasHiddenPart(parts) {
+			continue
+		}
+		if msg.IsDir && !empty {
+			continue
+		}
+		if empty && (msg.IsDir && nonEmpty[path] || !msg.IsDir && msg.Size != 0) {
+			continue
+		}
+		if !stride.MatchFind(opts, msg) {
+			continue
+		}
+		matches = append(matches, msg)
+	}
+
+	sort.Slice(matches, func(i, j int) bool {
+		return matches[i].Path < matches[j].Path
+	})
+	return matches
+}
+
+// hasHiddenPart reports whether any path component is a dotfile.
+func hasHiddenPart(parts []string) bool {
+	for _, p := range parts {
+		if strings.HasPrefix(p, ".") && p != "." && p != ".." {
+			return true
+		}
+	}
+	return false
+}
+
+// Snapshot returns the statistics of the last rebuild, the watch counters,
+// the number of indexed entries and when the index was last rebuilt.
+func (idx *Index) Snapshot() (stride.Stats, WatchStats, int, time.Time) {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+	return idx.stats, idx.watch, len(idx.entries), idx.builtAt
+}
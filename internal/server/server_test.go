@@ -0,0 +1,202 @@
+package server
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+// postFind queries the server and returns the matched paths relative to root.
+func postFind(t *testing.T, srv *httptest.Server, root, body string) []string {
+	t.Helper()
+	resp, err := http.Post(srv.URL+"/find", "application/json", strings.NewReader(body))
+	if err != nil {
+		t.Fatalf("POST /find failed: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("POST /find returned %s", resp.Status)
+	}
+
+	var paths []string
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		var match FindMatch
+		if err := json.Unmarshal(scanner.Bytes(), &match); err != nil {
+			t.Fatalf("Invalid NDJSON line %q: %v", scanner.Text(), err)
+		}
+		rel, _ := filepath.Rel(root, match.Path)
+		paths = append(paths, filepath.ToSlash(rel))
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+func writeFiles(t *testing.T, root string, names ...string) {
+	t.Helper()
+	for _, name := range names {
+		path := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("Failed to create directory: %v", err)
+		}
+		if err := os.WriteFile(path, []byte("data"), 0644); err != nil {
+			t.Fatalf("Failed to create file: %v", err)
+		}
+	}
+}
+
+func TestServerFindAfterCreate(t *testing.T) {
+	root := t.TempDir()
+	writeFiles(t, root, "a.txt", "src/b.go", "src/deep/c.txt", "src/.d.txt")
+
+	idx := NewIndex(root, 4)
+	if err := idx.Rebuild(context.Background()); err != nil {
+		t.Fatalf("Rebuild failed: %v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	watchDone := make(chan struct{})
+	go func() {
+		defer close(watchDone)
+		idx.Watch(ctx)
+	}()
+	defer func() {
+		cancel()
+		<-watchDone
+	}()
+
+	srv := httptest.NewServer(NewHandler(idx))
+	defer srv.Close()
+
+	got := postFind(t, srv, root, `{"name":"*.txt"}`)
+	if strings.Join(got, ",") != "a.txt,src/deep/c.txt" {
+		t.Errorf("Unexpected matches before create: %v", got)
+	}
+	got = postFind(t, srv, root, `{"name":"*.txt","max_depth":1,"include_hidden":true}`)
+	if strings.Join(got, ",") != "a.txt" {
+		t.Errorf("Unexpected matches with max_depth: %v", got)
+	}
+
+	// Give the watcher a moment to initialize
+	time.Sleep(200 * time.Millisecond)
+	writeFiles(t, root, "src/new.txt")
+
+	// The watcher adds the new file without a rescan
+	deadline := time.Now().Add(3 * time.Second)
+	for {
+		got = postFind(t, srv, root, `{"name":"*.txt"}`)
+		if strings.Join(got, ",") == "a.txt,src/deep/c.txt,src/new.txt" {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("New file not found after create: %v", got)
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+
+	resp, err := http.Get(srv.URL + "/stats")
+	if err != nil {
+		t.Fatalf("GET /stats failed: %v", err)
+	}
+	defer resp.Body.Close()
+	var stats StatsResponse
+	if err := json.NewDecoder(resp.Body).Decode(&stats); err != nil {
+		t.Fatalf("Invalid stats response: %v", err)
+	}
+	if stats.Stats.FilesProcessed != 4 || stats.Watch.Creates == 0 || stats.Entries != 7 {
+		t.Errorf("Unexpected stats: %+v", stats)
+	}
+}
+
+func TestServerRescan(t *testing.T) {
+	root := t.TempDir()
+	writeFiles(t, root, "a.log", "empty/.keep")
+
+	idx := NewIndex(root, 2)
+	if err := idx.Rebuild(context.Background()); err != nil {
+		t.Fatalf("Rebuild failed: %v", err)
+	}
+	srv := httptest.NewServer(NewHandler(idx))
+	defer srv.Close()
+
+	// Without a watcher, changes only appear after a rescan
+	writeFiles(t, root, "b.log")
+	if got := postFind(t, srv, root, `{"name":"*.log"}`); strings.Join(got, ",") != "a.log" {
+		t.Errorf("Unexpected matches before rescan: %v", got)
+	}
+	resp, err := http.Post(srv.URL+"/rescan", "application/json", nil)
+	if err != nil {
+		t.Fatalf("POST /rescan failed: %v", err)
+	}
+	resp.Body.Close()
+	if got := postFind(t, srv, root, `{"name":"*.log"}`); strings.Join(got, ",") != "a.log,b.log" {
+		t.Errorf("Unexpected matches after rescan: %v", got)
+	}
+
+	// Emptiness is answered from the index
+	if err := os.Remove(filepath.Join(root, "empty", ".keep")); err != nil {
+		t.Fatalf("Failed to remove file: %v", err)
+	}
+	if got := postFind(t, srv, root, `{"empty":true}`); len(got) != 0 {
+		t.Errorf("Expected no empty entries before rescan, got %v", got)
+	}
+	resp, err = http.Post(srv.URL+"/rescan", "application/json", nil)
+	if err != nil {
+		t.Fatalf("POST /rescan failed: %v", err)
+	}
+	resp.Body.Close()
+	if got := postFind(t, srv, root, `{"empty":true}`); strings.Join(got, ",") != "empty" {
+		t.Errorf("Unexpected empty entries: %v", got)
+	}
+
+	resp, err = http.Post(srv.URL+"/find", "application/json", strings.NewReader(`{"regex":"("}`))
+	if err != nil {
+		t.Fatalf("POST /find failed: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("Expected 400 for invalid regex, got %s", resp.Status)
+	}
+
+	// Bodies are capped
+	body := `{"name":"` + strings.Repeat("x", maxFindRequestSize) + `"}`
+	resp, err = http.Post(srv.URL+"/find", "application/json", strings.NewReader(body))
+	if err != nil {
+		t.Fatalf("POST /find failed: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusRequestEntityTooLarge {
+		t.Errorf("Expected 413 for an oversized body, got %s", resp.Status)
+	}
+}
+
+func TestServeListenError(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	defer ln.Close()
+
+	// A taken address fails before anything is left running
+	done := make(chan error, 1)
+	go func() {
+		done <- Serve(context.Background(), ln.Addr().String(), t.TempDir(), 1)
+	}()
+	select {
+	case err := <-done:
+		if err == nil {
+			t.Error("Expected an error for an address in use")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected Serve to return")
+	}
+}
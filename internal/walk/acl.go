@@ -0,0 +1,151 @@
+package stride
+
+import (
+	"encoding/binary"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// Tags of POSIX ACL entries, from linux/posix_acl.h
+const (
+	aclUserObj  = 0x01
+	aclUser     = 0x02
+	aclGroupObj = 0x04
+	aclGroup    = 0x08
+	aclMask     = 0x10
+	aclOther    = 0x20
+)
+
+// posixACLXattrVersion is the only version of the system.posix_acl_access
+// format.
+const posixACLXattrVersion = 2
+
+// aclEntry is an entry of a POSIX access ACL.
+type aclEntry struct {
+	tag  uint16
+	perm uint16 // Read, write and execute as 4, 2 and 1
+	id   uint32 // The uid or gid of aclUser and aclGroup entries
+}
+
+// parsePosixACLXattr decodes the value of the system.posix_acl_access
+// xattr: a little-endian uint32 version followed by 8-byte entries, each a
+// uint16 tag, uint16 permissions and uint32 id.
+func parsePosixACLXattr(data []byte) ([]aclEntry, error) {
+	if len(data) < 4 || (len(data)-4)%8 != 0 {
+		return nil, fmt.Errorf("invalid ACL of %d bytes", len(data))
+	}
+	if version := binary.LittleEndian.Uint32(data); version != posixACLXattrVersion {
+		return nil, fmt.Errorf("unsupported ACL version %d", version)
+	}
+	entries := make([]aclEntry, 0, (len(data)-4)/8)
+	for b := data[4:]; len(b) > 0; b = b[8:] {
+		entries = append(entries, aclEntry{
+			tag:  binary.LittleEndian.Uint16(b),
+			perm: binary.LittleEndian.Uint16(b[2:]),
+			id:   binary.LittleEndian.Uint32(b[4:]),
+		})
+	}
+	return entries, nil
+}
+
+// summarizeACL returns the entries of acl beyond the owner, group and other
+// classes the permission bits already show, such as
+// "u:alice:rw-,g:devs:r--,m::rw-", or "" if it has none. Ids are shown as
+// names where names resolves them.
+func summarizeACL(acl []aclEntry, names *nameCache) string {
+	var parts []string
+	for _, e := range acl {
+		switch e.tag {
+		case aclUser:
+			parts = append(parts, "u:"+names.userName(e.id)+":"+aclPerm(e.perm))
+		case aclGroup:
+			parts = append(parts, "g:"+names.groupName(e.id)+":"+aclPerm(e.perm))
+		case aclMask:
+			parts = append(parts, "m::"+aclPerm(e.perm))
+		}
+	}
+	return strings.Join(parts, ",")
+}
+
+// aclPerm returns permissions as getfacl(1) shows them, such as "r-x".
+func aclPerm(perm uint16) string {
+	b := []byte("---")
+	if perm&4 != 0 {
+		b[0] = 'r'
+	}
+	if perm&2 != 0 {
+		b[1] = 'w'
+	}
+	if perm&1 != 0 {
+		b[2] = 'x'
+	}
+	return string(b)
+}
+
+// summarizeACLText summarizes an ACL in the long text form of POSIX.1e
+// acl_to_text(3), one entry per line such as "user:alice:rw-", like
+// summarizeACL. Comments, such as the effective permissions, are dropped.
+func summarizeACLText(text string) string {
+	var parts []string
+	for _, line := range strings.Split(text, "\n") {
+		if i := strings.IndexByte(line, '#'); i >= 0 {
+			line = line[:i]
+		}
+		fields := strings.Split(strings.TrimSpace(line), ":")
+		if len(fields) != 3 {
+			continue
+		}
+		switch {
+		case fields[0] == "user" && fields[1] != "":
+			parts = append(parts, "u:"+fields[1]+":"+fields[2])
+		case fields[0] == "group" && fields[1] != "":
+			parts = append(parts, "g:"+fields[1]+":"+fields[2])
+		case fields[0] == "mask":
+			parts = append(parts, "m::"+fields[2])
+		}
+	}
+	return strings.Join(parts, ",")
+}
+
+// summarizeExtendedACLText summarizes an ACL in the text form of macOS
+// acl_to_text(3), whose entries look like
+// "user:FFFFEEEE-DDDD-CCCC-BBBB-AAAA000001F5:alice:501:allow:read,write",
+// as "u:alice:allow:read/write". Every entry is extended there.
+func summarizeExtendedACLText(text string) string {
+	var parts []string
+	for _, line := range strings.Split(text, "\n") {
+		fields := strings.Split(strings.TrimSpace(line), ":")
+		if len(fields) < 6 || strings.HasPrefix(fields[0], "!#") {
+			continue
+		}
+		tag := "u"
+		if fields[0] == "group" {
+			tag = "g"
+		}
+		name := fields[2]
+		if name == "" {
+			name = fields[3]
+		}
+		parts = append(parts, tag+":"+name+":"+fields[4]+":"+strings.ReplaceAll(fields[5], ",", "/"))
+	}
+	return strings.Join(parts, ",")
+}
+
+// readACL returns the summary of the extended ACL of the file at path. It
+// is platformACL, replaceable in tests.
+var readACL = platformACL
+
+// aclOf returns the summary of the extended ACL of the file at path, or ""
+// if it has none or it cannot be read, as on platforms without ACLs.
+// Symbolic links have no ACL of their own.
+func aclOf(path string, info os.FileInfo) string {
+	if info.Mode()&os.ModeSymlink != 0 {
+		return ""
+	}
+	acl, err := readACL(path)
+	if err != nil {
+		return ""
+	}
+	return acl
+}
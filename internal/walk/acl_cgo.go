@@ -0,0 +1,55 @@
+//go:build (darwin || freebsd) && cgo
+
+package stride
+
+/*
+#include <stdlib.h>
+#include <sys/types.h>
+#include <sys/acl.h>
+
+// stride_acl_get reads the ACL that holds more than the permission bits:
+// the extended ACL on macOS, the POSIX.1e access ACL on FreeBSD.
+static acl_t stride_acl_get(const char *path) {
+#ifdef __APPLE__
+	return acl_get_link_np(path, ACL_TYPE_EXTENDED);
+#else
+	return acl_get_link_np(path, ACL_TYPE_ACCESS);
+#endif
+}
+*/
+import "C"
+
+import (
+	"errors"
+	"os"
+	"runtime"
+	"syscall"
+	"unsafe"
+)
+
+// platformACL reads the ACL of path with acl_get_link_np(3) and summarizes
+// its text form. Files without one, and filesystems whose ACLs are of
+// another kind, such as NFSv4 ACLs on ZFS, have none.
+func platformACL(path string) (string, error) {
+	cpath := C.CString(path)
+	defer C.free(unsafe.Pointer(cpath))
+	acl, err := C.stride_acl_get(cpath)
+	if acl == nil {
+		if errors.Is(err, syscall.ENOENT) || errors.Is(err, syscall.EINVAL) || errors.Is(err, syscall.EOPNOTSUPP) {
+			return "", nil
+		}
+		return "", &os.PathError{Op: "acl_get_link_np", Path: path, Err: err}
+	}
+	defer C.acl_free(unsafe.Pointer(acl))
+
+	var n C.ssize_t
+	text, err := C.acl_to_text(acl, &n)
+	if text == nil {
+		return "", &os.PathError{Op: "acl_to_text", Path: path, Err: err}
+	}
+	defer C.acl_free(unsafe.Pointer(text))
+	if runtime.GOOS == "darwin" {
+		return summarizeExtendedACLText(C.GoStringN(text, C.int(n))), nil
+	}
+	return summarizeACLText(C.GoStringN(text, C.int(n))), nil
+}
@@ -0,0 +1,38 @@
+package stride
+
+import (
+	"errors"
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// posixACLAccessXattr holds the access ACL of a file on Linux.
+const posixACLAccessXattr = "system.posix_acl_access"
+
+// platformACL reads the access ACL of path from its xattr, following
+// symbolic links. Files without one, and filesystems without ACLs, have
+// none.
+func platformACL(path string) (string, error) {
+	buf := make([]byte, 256)
+	for {
+		n, err := unix.Getxattr(path, posixACLAccessXattr, buf)
+		switch {
+		case errors.Is(err, unix.ERANGE):
+			if n, err = unix.Getxattr(path, posixACLAccessXattr, nil); err != nil {
+				return "", &os.PathError{Op: "getxattr", Path: path, Err: err}
+			}
+			buf = make([]byte, n)
+			continue
+		case errors.Is(err, unix.ENODATA), errors.Is(err, unix.ENOTSUP):
+			return "", nil
+		case err != nil:
+			return "", &os.PathError{Op: "getxattr", Path: path, Err: err}
+		}
+		acl, err := parsePosixACLXattr(buf[:n])
+		if err != nil {
+			return "", &os.PathError{Op: "getxattr", Path: path, Err: err}
+		}
+		return summarizeACL(acl, defaultNameCache), nil
+	}
+}
@@ -0,0 +1,33 @@
+package stride
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"testing"
+)
+
+func TestPlatformACLLinux(t *testing.T) {
+	setfacl, err := exec.LookPath("setfacl")
+	if err != nil {
+		t.Skip("setfacl is not installed")
+	}
+	dir := t.TempDir()
+	path := filepath.Join(dir, "shared.txt")
+	if err := os.WriteFile(path, []byte("x"), 0640); err != nil {
+		t.Fatalf("Failed to create file: %v", err)
+	}
+
+	if acl, err := platformACL(path); err != nil || acl != "" {
+		t.Errorf("Expected no ACL before setfacl, got %q (%v)", acl, err)
+	}
+	uid := strconv.Itoa(os.Getuid())
+	if out, err := exec.Command(setfacl, "-m", "u:"+uid+":rw-", path).CombinedOutput(); err != nil {
+		t.Skipf("Cannot set ACLs here: %v: %s", err, out)
+	}
+	want := "u:" + defaultNameCache.userName(uint32(os.Getuid())) + ":rw-,m::rw-"
+	if acl, err := platformACL(path); err != nil || acl != want {
+		t.Errorf("Expected %q, got %q (%v)", want, acl, err)
+	}
+}
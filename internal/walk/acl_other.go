@@ -0,0 +1,8 @@
+//go:build !linux && !((darwin || freebsd) && cgo)
+
+package stride
+
+// platformACL reports no ACL on platforms where it cannot be read.
+func platformACL(path string) (string, error) {
+	return "", nil
+}
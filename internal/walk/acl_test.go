@@ -0,0 +1,177 @@
+package stride
+
+import (
+	"context"
+	"encoding/hex"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/TFMV/stride/walk/walktest"
+)
+
+// Values of system.posix_acl_access captured with getfattr -e hex
+const (
+	// setfacl -m u:1000:rw-,g:100:r-- on a file of mode 0644
+	aclFixtureExtended = "02000000" +
+		"01000600ffffffff" + // user::rw-
+		"02000600e8030000" + // user:1000:rw-
+		"04000400ffffffff" + // group::r--
+		"0800040064000000" + // group:100:r--
+		"10000600ffffffff" + // mask::rw-
+		"20000400ffffffff" // other::r--
+
+	// setfacl -m u:1000:r-x,g:100:rwx with the mask narrowed to r--
+	aclFixtureMasked = "02000000" +
+		"01000700ffffffff" + // user::rwx
+		"02000500e8030000" + // user:1000:r-x
+		"04000500ffffffff" + // group::r-x
+		"0800070064000000" + // group:100:rwx
+		"10000400ffffffff" + // mask::r--
+		"20000000ffffffff" // other::---
+
+	// The minimal ACL some tools write, equivalent to the permission bits
+	aclFixtureBase = "02000000" +
+		"01000600ffffffff" + // user::rw-
+		"04000400ffffffff" + // group::r--
+		"20000400ffffffff" // other::r--
+)
+
+func TestParsePosixACLXattr(t *testing.T) {
+	names := newNameCache(&fakeResolver{names: map[string]string{"1000": "alice", "100": "devs"}})
+
+	tests := []struct {
+		name     string
+		hex      string
+		entries  int
+		expected string
+		wantErr  bool
+	}{
+		{name: "extended", hex: aclFixtureExtended, entries: 6, expected: "u:alice:rw-,g:devs:r--,m::rw-"},
+		{name: "masked", hex: aclFixtureMasked, entries: 6, expected: "u:alice:r-x,g:devs:rwx,m::r--"},
+		{name: "base entries only", hex: aclFixtureBase, entries: 3, expected: ""},
+		{name: "no entries", hex: "02000000", expected: ""},
+		{name: "truncated entry", hex: aclFixtureBase[:len(aclFixtureBase)-2], wantErr: true},
+		{name: "wrong version", hex: "01000000" + aclFixtureBase[8:], wantErr: true},
+		{name: "empty", hex: "", wantErr: true},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			data, err := hex.DecodeString(tc.hex)
+			if err != nil {
+				t.Fatalf("Failed to decode fixture: %v", err)
+			}
+			acl, err := parsePosixACLXattr(data)
+			if tc.wantErr {
+				if err == nil {
+					t.Errorf("Expected an error, got %v", acl)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Failed to parse ACL: %v", err)
+			}
+			if len(acl) != tc.entries {
+				t.Errorf("Expected %d entries, got %d", tc.entries, len(acl))
+			}
+			if got := summarizeACL(acl, names); got != tc.expected {
+				t.Errorf("Expected %q, got %q", tc.expected, got)
+			}
+		})
+	}
+}
+
+func TestSummarizeACLText(t *testing.T) {
+	// acl_to_text(3) output on FreeBSD
+	posix := "user::rw-\nuser:alice:rw-\ngroup::r--\ngroup:devs:rwx\t\t# effective: r--\nmask::r--\nother::r--\n"
+	if got, want := summarizeACLText(posix), "u:alice:rw-,g:devs:rwx,m::r--"; got != want {
+		t.Errorf("Expected %q, got %q", want, got)
+	}
+	if got := summarizeACLText("user::rw-\ngroup::r--\nother::r--\n"); got != "" {
+		t.Errorf("Expected no extended entries, got %q", got)
+	}
+
+	// acl_to_text(3) output on macOS
+	extended := "!#acl 1\n" +
+		"user:FFFFEEEE-DDDD-CCCC-BBBB-AAAA000001F5:alice:501:allow:read,write\n" +
+		"group:ABCDEFAB-CDEF-ABCD-EFAB-CDEF0000000C:everyone:12:deny:delete\n"
+	if got, want := summarizeExtendedACLText(extended), "u:alice:allow:read/write,g:everyone:deny:delete"; got != want {
+		t.Errorf("Expected %q, got %q", want, got)
+	}
+}
+
+func TestFindACLs(t *testing.T) {
+	root := walktest.Tree{
+		"plain.txt":       walktest.File{Content: "plain"},
+		"shared.txt":      walktest.File{Content: "shared"},
+		"sub/granted.txt": walktest.File{Content: "granted"},
+	}.Build(t)
+	acls := map[string]string{
+		"shared.txt":      "u:alice:rw-,m::rw-",
+		"sub/granted.txt": "g:devs:r--,m::r--",
+	}
+	orig := readACL
+	readACL = func(path string) (string, error) {
+		return acls[relSlashPath(root, path)], nil
+	}
+	t.Cleanup(func() { readACL = orig })
+
+	tests := []struct {
+		name     string
+		opts     FindOptions
+		expected map[string]string // Matches and their Metadata["acl"]
+	}{
+		{
+			name:     "load",
+			opts:     FindOptions{LoadACLs: true},
+			expected: map[string]string{"plain.txt": "", "shared.txt": acls["shared.txt"], "sub/granted.txt": acls["sub/granted.txt"]},
+		},
+		{
+			name:     "filter",
+			opts:     FindOptions{HasExtendedACL: true},
+			expected: map[string]string{"shared.txt": "", "sub/granted.txt": ""},
+		},
+		{
+			name:     "filter and load",
+			opts:     FindOptions{HasExtendedACL: true, LoadACLs: true},
+			expected: map[string]string{"shared.txt": acls["shared.txt"], "sub/granted.txt": acls["sub/granted.txt"]},
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			var mu sync.Mutex
+			found := make(map[string]string)
+			tc.opts.MaxDepth = 2
+			err := Find(context.Background(), root, tc.opts, func(ctx context.Context, result FindResult) error {
+				if result.Error != nil {
+					return result.Error
+				}
+				mu.Lock()
+				defer mu.Unlock()
+				found[relSlashPath(root, result.Message.Path)] = result.Message.Metadata["acl"]
+				return nil
+			})
+			if err != nil {
+				t.Fatalf("Failed to find files: %v", err)
+			}
+			if len(found) != len(tc.expected) {
+				t.Errorf("Expected %d matches, got %v", len(tc.expected), found)
+			}
+			for path, acl := range tc.expected {
+				if got, ok := found[path]; !ok || got != acl {
+					t.Errorf("Expected %s with ACL %q, got %q (found %v)", path, acl, got, ok)
+				}
+			}
+		})
+	}
+
+	// The template field loads ACLs by itself
+	var out strings.Builder
+	opts := FindOptions{NamePattern: "shared.txt", Output: &out}
+	if err := FindWithFormat(context.Background(), root, opts, "{base} {acl}"); err != nil {
+		t.Fatalf("Failed to find files: %v", err)
+	}
+	if got, want := strings.TrimSpace(out.String()), "shared.txt "+acls["shared.txt"]; got != want {
+		t.Errorf("Expected %q, got %q", want, got)
+	}
+}
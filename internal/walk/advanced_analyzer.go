@@ -11,33 +11,33 @@ import (
 
 // DuplicateGroup represents a group of similar files
 type DuplicateGroup struct {
-	Files      []string // List of file paths
-	Similarity float64  // Similarity score (0-1)
-	Resolution string   // Suggested resolution action
-	CommonPath string   // Common parent directory
+	Files      []string `json:"files"`       // List of file paths
+	Similarity float64  `json:"similarity"`  // Similarity score (0-1)
+	Resolution string   `json:"resolution"`  // Suggested resolution action
+	CommonPath string   `json:"common_path"` // Common parent directory
 }
 
 // DependencyInfo represents dependency information for a file
 type DependencyInfo struct {
-	Path         string
-	Imports      []string          // Direct imports
-	ImportedBy   []string          // Files that import this file
-	IsOrphan     bool              // True if not imported by any other file
-	IsUnused     bool              // True if file contains unused exports
-	Dependencies map[string]string // Map of symbol to file path
+	Path         string            `json:"path"`
+	Imports      []string          `json:"imports"`      // Direct imports
+	ImportedBy   []string          `json:"imported_by"`  // Files that import this file
+	IsOrphan     bool              `json:"is_orphan"`    // True if not imported by any other file
+	IsUnused     bool              `json:"is_unused"`    // True if file contains unused exports
+	Dependencies map[string]string `json:"dependencies"` // Map of symbol to file path
 }
 
 // CodebaseGraph represents the dependency structure of a codebase
 type CodebaseGraph struct {
-	Files       map[string]*DependencyInfo
-	Orphans     []string
-	UnusedFiles []string
+	Files       map[string]*DependencyInfo `json:"files"`
+	Orphans     []string                   `json:"orphans"`
+	UnusedFiles []string                   `json:"unused_files"`
 }
 
 // AdvancedAnalysis contains results from advanced analysis features
 type AdvancedAnalysis struct {
-	NearDuplicates []DuplicateGroup
-	Dependencies   *CodebaseGraph
+	NearDuplicates []DuplicateGroup `json:"near_duplicates"`
+	Dependencies   *CodebaseGraph   `json:"dependencies,omitempty"`
 }
 
 // detectNearDuplicates identifies files with similar content
@@ -131,8 +131,10 @@ func rollingHash(content []byte, windowSize int) map[uint64]bool {
 	return hashes
 }
 
-// analyzeDependencies analyzes code dependencies in a directory
-func (a *Analyzer) analyzeDependencies(root string) (*CodebaseGraph, error) {
+// analyzeDependencies analyzes code dependencies in a directory, honoring
+// the include and exclude patterns of filter and counting parsed files in
+// progress
+func (a *Analyzer) analyzeDependencies(root string, filter pathFilter, progress *analyzeReporter) (*CodebaseGraph, error) {
 	graph := &CodebaseGraph{
 		Files: make(map[string]*DependencyInfo),
 	}
@@ -143,13 +145,22 @@ func (a *Analyzer) analyzeDependencies(root string) (*CodebaseGraph, error) {
 			return err
 		}
 
+		relPath, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		if skip, skipDir := filter.skip(filepath.ToSlash(relPath), info.IsDir()); skipDir {
+			return filepath.SkipDir
+		} else if skip {
+			return nil
+		}
+
 		if !info.IsDir() && strings.HasSuffix(path, ".go") {
-			relPath, err := filepath.Rel(root, path)
-			if err != nil {
-				return err
-			}
 
 			imports, err := parseFileImports(path)
+			progress.p.DepFiles++
+			progress.p.BytesRead += info.Size()
+			progress.tick()
 			if err != nil {
 				return nil // Skip files with parse errors
 			}
@@ -257,39 +268,15 @@ func isEntryPoint(file string) bool {
 	return strings.HasSuffix(file, "main.go") || strings.Contains(file, "/cmd/") || strings.Contains(file, "/cli/")
 }
 
-// PerformDeduplication executes the suggested deduplication actions
+// PerformDeduplication executes the suggested deduplication actions,
+// replacing duplicates with symbolic links; see
+// PerformDeduplicationWithStrategy.
 func (a *Analyzer) PerformDeduplication(group DuplicateGroup, dryRun bool) error {
-	if len(group.Files) < 2 {
-		return fmt.Errorf("not enough files to deduplicate")
-	}
-
-	primaryFile := group.Files[0]
-	for i := 1; i < len(group.Files); i++ {
-		duplicateFile := group.Files[i]
-
-		if dryRun {
-			fmt.Printf("Would delete: %s and create symlink to %s\n", duplicateFile, primaryFile)
-			continue
+	report, err := a.PerformDeduplicationWithStrategy(group, DedupSymlink, dryRun)
+	if dryRun {
+		for _, duplicateFile := range report.Replaced {
+			fmt.Printf("Would delete: %s and create symlink to %s\n", duplicateFile, group.Files[0])
 		}
-
-		// Create backup
-		backupPath := duplicateFile + ".bak"
-		err := os.Rename(duplicateFile, backupPath)
-		if err != nil {
-			return fmt.Errorf("failed to create backup of %s: %v", duplicateFile, err)
-		}
-
-		// Create symlink
-		err = os.Symlink(primaryFile, duplicateFile)
-		if err != nil {
-			// Restore from backup on failure
-			os.Rename(backupPath, duplicateFile)
-			return fmt.Errorf("failed to create symlink from %s to %s: %v", duplicateFile, primaryFile, err)
-		}
-
-		// Remove backup
-		os.Remove(backupPath)
 	}
-
-	return nil
+	return err
 }
@@ -0,0 +1,132 @@
+package stride
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// DefaultAgeBuckets are the boundaries between the age buckets of a storage
+// report: under 30 days, 30 to 90 days, 90 days to a year, and older.
+var DefaultAgeBuckets = []time.Duration{30 * 24 * time.Hour, 90 * 24 * time.Hour, 365 * 24 * time.Hour}
+
+// AgeBucket holds the files last modified within an age range.
+type AgeBucket struct {
+	Label  string        `json:"label"`             // The range, e.g. "<30d", "30d-90d" or ">1y"
+	MinAge time.Duration `json:"min_age"`           // Lower bound of the range, inclusive
+	MaxAge time.Duration `json:"max_age,omitempty"` // Upper bound of the range, exclusive; 0 for the oldest bucket
+	Files  int           `json:"files"`             // Files in the range
+	Bytes  int64         `json:"bytes"`             // Bytes in those files
+}
+
+// AgeBuckets breaks the files of a storage report down by the age of their
+// last modification, for planning lifecycle policies.
+type AgeBuckets struct {
+	Overall []AgeBucket `json:"overall"` // Buckets of all files, youngest first
+
+	// ByDirectory holds the buckets of the files beneath each top-level
+	// directory, files directly in the root being under "."
+	ByDirectory map[string][]AgeBucket `json:"by_directory"`
+}
+
+// ageBucketCollector sorts file sizes into age buckets while the tree is
+// walked.
+type ageBucketCollector struct {
+	boundaries []time.Duration
+	now        time.Time
+	overall    []AgeBucket
+	byDir      map[string][]AgeBucket
+}
+
+// newAgeBucketCollector creates a collector for the buckets between
+// boundaries, which must be increasing, measuring ages from now.
+func newAgeBucketCollector(boundaries []time.Duration, now time.Time) *ageBucketCollector {
+	c := &ageBucketCollector{boundaries: boundaries, now: now, byDir: make(map[string][]AgeBucket)}
+	c.overall = c.newBuckets()
+	return c
+}
+
+// newBuckets returns an empty bucket for each range.
+func (c *ageBucketCollector) newBuckets() []AgeBucket {
+	buckets := make([]AgeBucket, len(c.boundaries)+1)
+	for i := range buckets {
+		b := &buckets[i]
+		switch {
+		case len(c.boundaries) == 0:
+			b.Label = "all"
+		case i == 0:
+			b.MaxAge = c.boundaries[0]
+			b.Label = "<" + formatAge(b.MaxAge)
+		case i == len(c.boundaries):
+			b.MinAge = c.boundaries[i-1]
+			b.Label = ">" + formatAge(b.MinAge)
+		default:
+			b.MinAge, b.MaxAge = c.boundaries[i-1], c.boundaries[i]
+			b.Label = formatAge(b.MinAge) + "-" + formatAge(b.MaxAge)
+		}
+	}
+	return buckets
+}
+
+// addFile counts a file of size bytes at the slash-separated path rel, last
+// modified at mtime. Files modified in the future count as new.
+func (c *ageBucketCollector) addFile(rel string, mtime time.Time, size int64) {
+	age := c.now.Sub(mtime)
+	i := sort.Search(len(c.boundaries), func(i int) bool { return age < c.boundaries[i] })
+
+	top := "."
+	if j := strings.IndexByte(rel, '/'); j >= 0 {
+		top = rel[:j]
+	}
+	dir, ok := c.byDir[top]
+	if !ok {
+		dir = c.newBuckets()
+		c.byDir[top] = dir
+	}
+	for _, b := range []*AgeBucket{&c.overall[i], &dir[i]} {
+		b.Files++
+		b.Bytes += size
+	}
+}
+
+// report returns the buckets collected.
+func (c *ageBucketCollector) report() *AgeBuckets {
+	return &AgeBuckets{Overall: c.overall, ByDirectory: c.byDir}
+}
+
+// formatAge writes d in the largest whole unit of years, days or hours,
+// falling back to time.Duration's own format.
+func formatAge(d time.Duration) string {
+	const day = 24 * time.Hour
+	switch {
+	case d%(365*day) == 0:
+		return fmt.Sprintf("%dy", d/(365*day))
+	case d%day == 0:
+		return fmt.Sprintf("%dd", d/day)
+	case d%time.Hour == 0:
+		return fmt.Sprintf("%dh", d/time.Hour)
+	default:
+		return d.String()
+	}
+}
+
+// normalizeAgeBuckets returns boundaries sorted, without duplicates or
+// values that are not positive.
+func normalizeAgeBuckets(boundaries []time.Duration) []time.Duration {
+	var out []time.Duration
+	for _, b := range boundaries {
+		if b > 0 {
+			out = append(out, b)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i] < out[j] })
+	n := 0
+	for i, b := range out {
+		if i == 0 || b != out[n-1] {
+			out[n] = b
+			n++
+		}
+	}
+	return out[:n]
+}
@@ -0,0 +1,109 @@
+package stride
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/TFMV/stride/walk/walktest"
+)
+
+func TestAgeBuckets(t *testing.T) {
+	const day = 24 * time.Hour
+	now := time.Now()
+	root := walktest.Tree{
+		"new.txt":         walktest.File{Size: 10, ModTime: now.Add(-day)},
+		"a/mid.txt":       walktest.File{Size: 100, ModTime: now.Add(-45 * day)},
+		"a/old.txt":       walktest.File{Size: 1000, ModTime: now.Add(-200 * day)},
+		"b/c/ancient.txt": walktest.File{Size: 10000, ModTime: now.Add(-400 * day)},
+		"b/future.txt":    walktest.File{Size: 1, ModTime: now.Add(day)},
+	}.Build(t)
+
+	tests := []struct {
+		name       string
+		boundaries []time.Duration
+		overall    []AgeBucket
+		b          []AgeBucket
+	}{
+		{
+			name: "defaults",
+			overall: []AgeBucket{
+				{Label: "<30d", MaxAge: 30 * day, Files: 2, Bytes: 11},
+				{Label: "30d-90d", MinAge: 30 * day, MaxAge: 90 * day, Files: 1, Bytes: 100},
+				{Label: "90d-1y", MinAge: 90 * day, MaxAge: 365 * day, Files: 1, Bytes: 1000},
+				{Label: ">1y", MinAge: 365 * day, Files: 1, Bytes: 10000},
+			},
+			b: []AgeBucket{
+				{Label: "<30d", MaxAge: 30 * day, Files: 1, Bytes: 1},
+				{Label: "30d-90d", MinAge: 30 * day, MaxAge: 90 * day},
+				{Label: "90d-1y", MinAge: 90 * day, MaxAge: 365 * day},
+				{Label: ">1y", MinAge: 365 * day, Files: 1, Bytes: 10000},
+			},
+		},
+		{
+			name:       "custom",
+			boundaries: []time.Duration{180 * day, 7 * day, 0, 7 * day},
+			overall: []AgeBucket{
+				{Label: "<7d", MaxAge: 7 * day, Files: 2, Bytes: 11},
+				{Label: "7d-180d", MinAge: 7 * day, MaxAge: 180 * day, Files: 1, Bytes: 100},
+				{Label: ">180d", MinAge: 180 * day, Files: 2, Bytes: 11000},
+			},
+			b: []AgeBucket{
+				{Label: "<7d", MaxAge: 7 * day, Files: 1, Bytes: 1},
+				{Label: "7d-180d", MinAge: 7 * day, MaxAge: 180 * day},
+				{Label: ">180d", MinAge: 180 * day, Files: 1, Bytes: 10000},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			analyzer := NewAnalyzer()
+			analyzer.EnableStorageReport()
+			analyzer.SetAgeBuckets(tt.boundaries)
+			result, err := analyzer.Analyze(root)
+			if err != nil {
+				t.Fatalf("Failed to analyze: %v", err)
+			}
+			ages := result.StorageReport.AgeBuckets
+			if ages == nil {
+				t.Fatal("Expected age buckets in the storage report")
+			}
+			if !reflect.DeepEqual(ages.Overall, tt.overall) {
+				t.Errorf("Expected overall buckets %+v, got %+v", tt.overall, ages.Overall)
+			}
+			if !reflect.DeepEqual(ages.ByDirectory["b"], tt.b) {
+				t.Errorf("Expected buckets %+v for b, got %+v", tt.b, ages.ByDirectory["b"])
+			}
+			if len(ages.ByDirectory) != 3 {
+				t.Errorf("Expected buckets for ., a and b, got %v", ages.ByDirectory)
+			}
+
+			// Every byte lands in exactly one bucket
+			var total int64
+			for _, b := range ages.Overall {
+				total += b.Bytes
+			}
+			if total != result.StorageReport.TotalSize {
+				t.Errorf("Expected buckets to sum to %d bytes, got %d", result.StorageReport.TotalSize, total)
+			}
+		})
+	}
+}
+
+func TestFormatAge(t *testing.T) {
+	tests := []struct {
+		d        time.Duration
+		expected string
+	}{
+		{730 * 24 * time.Hour, "2y"},
+		{30 * 24 * time.Hour, "30d"},
+		{36 * time.Hour, "36h"},
+		{90 * time.Minute, "1h30m0s"},
+	}
+	for _, tt := range tests {
+		if got := formatAge(tt.d); got != tt.expected {
+			t.Errorf("Expected %v formatted as %q, got %q", tt.d, tt.expected, got)
+		}
+	}
+}
@@ -1,82 +1,127 @@
 package stride
 
 import (
-	"crypto/sha256"
+	"bufio"
+	"bytes"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
 	"regexp"
+	"sort"
 	"strings"
+	"time"
 )
 
 // AnalyzeResult represents the results of filesystem analysis
 type AnalyzeResult struct {
-	Duplicates      map[string][]string       // Map of content hash to file paths
-	CodeStats       map[string]LanguageStats  // Map of language to stats
-	StorageReport   StorageReport             // Storage usage information
-	SecurityIssues  []SecurityIssue           // List of security issues found
-	ContentPatterns map[string]ContentPattern // Map of pattern name to pattern info
-	Advanced        *AdvancedAnalysis         // Results from advanced analysis
+	Duplicates      map[string][]string       `json:"duplicates"`         // Map of content hash to paths, for groups of 2+ files
+	DuplicateGroups []DuplicateSet            `json:"duplicate_groups"`   // Duplicate groups sorted by wasted bytes, largest first
+	CodeStats       map[string]LanguageStats  `json:"code_stats"`         // Map of language to stats
+	StorageReport   StorageReport             `json:"storage_report"`     // Storage usage information
+	SecurityIssues  []SecurityIssue           `json:"security_issues"`    // List of security issues found
+	ContentPatterns map[string]ContentPattern `json:"content_patterns"`   // Map of pattern name to pattern info
+	Advanced        *AdvancedAnalysis         `json:"advanced,omitempty"` // Results from advanced analysis
+
+	TotalWastedBytes   int64 `json:"total_wasted_bytes"`   // Bytes that could be reclaimed by removing duplicates
+	SkippedLargeFiles  int   `json:"skipped_large_files"`  // Files too large for code and pattern analysis
+	SkippedBinaryFiles int   `json:"skipped_binary_files"` // Binary files skipped by code and pattern analysis
+}
+
+// DuplicateSet is a group of files with identical content
+type DuplicateSet struct {
+	Hash        string   `json:"hash"`         // SHA-256 of the shared content
+	Paths       []string `json:"paths"`        // Paths of the identical files
+	FileSize    int64    `json:"file_size"`    // Size of each file in bytes
+	WastedBytes int64    `json:"wasted_bytes"` // Bytes used by the redundant copies
 }
 
 // LanguageStats holds statistics for a programming language
 type LanguageStats struct {
-	Files      int      // Number of files
-	Lines      int      // Total lines of code
-	Blanks     int      // Blank lines
-	Comments   int      // Comment lines
-	Size       int64    // Total size in bytes
-	Extensions []string // File extensions
+	Files      int      `json:"files"`      // Number of files
+	Lines      int      `json:"lines"`      // Total lines of code
+	Blanks     int      `json:"blanks"`     // Blank lines
+	Comments   int      `json:"comments"`   // Comment lines
+	Size       int64    `json:"size"`       // Total size in bytes
+	Extensions []string `json:"extensions"` // File extensions
 }
 
 // StorageReport contains information about storage usage
 type StorageReport struct {
-	TotalSize    int64                // Total size in bytes
-	FileCount    int                  // Total number of files
-	DirCount     int                  // Total number of directories
-	TypeStats    map[string]TypeStats // Statistics by file type
-	LargestFiles []FileInfo           // List of largest files
-	OldestFiles  []FileInfo           // List of oldest files
-	NewestFiles  []FileInfo           // List of newest files
+	TotalSize    int64                `json:"total_size"`        // Total size in bytes
+	FileCount    int                  `json:"file_count"`        // Total number of files
+	DirCount     int                  `json:"dir_count"`         // Total number of directories
+	TypeStats    map[string]TypeStats `json:"type_stats"`        // Statistics by file type
+	LargestFiles []FileInfo           `json:"largest_files"`     // List of largest files
+	OldestFiles  []FileInfo           `json:"oldest_files"`      // List of oldest files
+	NewestFiles  []FileInfo           `json:"newest_files"`      // List of newest files
+	FSInfo       *FSInfo              `json:"fs_info,omitempty"` // Filesystem holding the root, if available
+
+	// DirectoryStats lists the directories holding the most bytes, down to
+	// the depth set with SetDirStatsDepth, largest first
+	DirectoryStats []DirStat `json:"directory_stats"`
+
+	// AgeBuckets breaks files down by the age of their last modification,
+	// in the buckets set with SetAgeBuckets
+	AgeBuckets *AgeBuckets `json:"age_buckets,omitempty"`
 }
 
 // TypeStats holds statistics for a file type
 type TypeStats struct {
-	Count int   // Number of files
-	Size  int64 // Total size in bytes
+	Count int   `json:"count"` // Number of files
+	Size  int64 `json:"size"`  // Total size in bytes
 }
 
 // FileInfo holds information about a file
 type FileInfo struct {
-	Path      string // File path
-	Size      int64  // File size in bytes
-	Modified  string // Last modified time
-	Extension string // File extension
+	Path      string `json:"path"`      // File path
+	Size      int64  `json:"size"`      // File size in bytes
+	Modified  string `json:"modified"`  // Last modified time
+	Extension string `json:"extension"` // File extension
 }
 
 // SecurityIssue represents a security concern found during analysis
 type SecurityIssue struct {
-	Path        string // File path
-	Description string // Description of the issue
-	Severity    string // High, Medium, Low
+	Path        string `json:"path"`        // File path
+	Rule        string `json:"rule"`        // Rule that found the issue, e.g. RuleSetuid
+	Description string `json:"description"` // Description of the issue
+	Severity    string `json:"severity"`    // High, Medium, Low
 }
 
 // ContentPattern holds information about content patterns
 type ContentPattern struct {
-	Count    int      // Number of occurrences
-	Files    []string // Files containing the pattern
-	Examples []string // Example matches
+	Count    int      `json:"count"`    // Number of occurrences
+	Files    []string `json:"files"`    // Files containing the pattern
+	Examples []string `json:"examples"` // Example matches
 }
 
+// DefaultMaxAnalyzedFileSize is the default size above which files are
+// skipped by code and pattern analysis.
+const DefaultMaxAnalyzedFileSize int64 = 10 * 1024 * 1024
+
+// binarySniffLen is the number of leading bytes inspected for binary detection.
+const binarySniffLen = 8 * 1024
+
+// maxLineLength bounds the length of a single line during line counting.
+const maxLineLength = 16 * 1024 * 1024
+
 // Analyzer provides filesystem analysis functionality
 type Analyzer struct {
-	outputFormat  string
-	outputFile    string
-	maxDepth      int
-	minSize       int64
-	maxSize       int64
-	includeHidden bool
-	languages     []string
+	outputFormat        string
+	outputFile          string
+	maxDepth            int
+	minSize             int64
+	maxSize             int64
+	maxAnalyzedFileSize int64
+	minDuplicateSize    int64
+	includeHidden       bool
+	languages           []string
+	includePatterns     []string
+	excludePatterns     []string
+	progressFn          func(AnalyzeProgress)
+	dirStatsDepth       int
+	dirStatsTopK        int
+	ageBuckets          []time.Duration
 
 	// Feature flags
 	detectDuplicates bool
@@ -93,9 +138,13 @@ type Analyzer struct {
 // NewAnalyzer creates a new Analyzer instance
 func NewAnalyzer() *Analyzer {
 	return &Analyzer{
-		outputFormat: "text",
-		maxDepth:     0, // unlimited
-		languages:    []string{},
+		outputFormat:        "text",
+		maxDepth:            0, // unlimited
+		maxAnalyzedFileSize: DefaultMaxAnalyzedFileSize,
+		dirStatsDepth:       DefaultDirStatsDepth,
+		dirStatsTopK:        DefaultDirStatsTopK,
+		ageBuckets:          DefaultAgeBuckets,
+		languages:           []string{},
 	}
 }
 
@@ -120,11 +169,70 @@ func (a *Analyzer) SetSizeRange(min, max string) {
 	a.maxSize = parseSize(max)
 }
 
+// SetMaxAnalyzedFileSize sets the size above which files are skipped by code
+// and pattern analysis. Skipped files are still counted in the storage report.
+// A size of 0 disables the limit.
+func (a *Analyzer) SetMaxAnalyzedFileSize(size int64) {
+	a.maxAnalyzedFileSize = size
+}
+
+// SetMinDuplicateSize sets the size below which files are ignored by
+// duplicate detection.
+func (a *Analyzer) SetMinDuplicateSize(size int64) {
+	a.minDuplicateSize = size
+}
+
 // SetIncludeHidden sets whether to include hidden files
 func (a *Analyzer) SetIncludeHidden(include bool) {
 	a.includeHidden = include
 }
 
+// SetIncludePatterns restricts the analysis to files whose root-relative
+// paths match any of the patterns. Patterns use MatchPattern syntax, and a
+// trailing slash selects a whole subtree: "src/" is the same as "src/**".
+// Directories that cannot contain matches are not walked, and only
+// directories matching a pattern are counted.
+func (a *Analyzer) SetIncludePatterns(patterns []string) {
+	a.includePatterns = patterns
+}
+
+// SetExcludePatterns skips files and directories whose root-relative paths
+// match any of the patterns, in the same syntax as SetIncludePatterns.
+func (a *Analyzer) SetExcludePatterns(patterns []string) {
+	a.excludePatterns = patterns
+}
+
+// SetDirStatsDepth sets how many levels below the root the storage report
+// breaks sizes down by directory (default 3). The root alone is depth 0.
+func (a *Analyzer) SetDirStatsDepth(depth int) {
+	a.dirStatsDepth = depth
+}
+
+// SetDirStatsTopK sets how many directories the storage report lists
+// (default 25), or all of them if k is not positive.
+func (a *Analyzer) SetDirStatsTopK(k int) {
+	a.dirStatsTopK = k
+}
+
+// SetAgeBuckets sets the boundaries between the age buckets of the storage
+// report, e.g. 30 and 90 days for buckets under 30 days, 30 to 90 days and
+// older. Boundaries are sorted, and those that are not positive are ignored.
+// A nil slice restores DefaultAgeBuckets.
+func (a *Analyzer) SetAgeBuckets(boundaries []time.Duration) {
+	if boundaries == nil {
+		a.ageBuckets = DefaultAgeBuckets
+		return
+	}
+	a.ageBuckets = normalizeAgeBuckets(boundaries)
+}
+
+// SetProgressCallback sets a function called as the analysis progresses:
+// when each phase starts and ends, and periodically in between. It is called
+// from the goroutine running Analyze.
+func (a *Analyzer) SetProgressCallback(fn func(AnalyzeProgress)) {
+	a.progressFn = fn
+}
+
 // SetLanguages sets the programming languages to analyze
 func (a *Analyzer) SetLanguages(langs []string) {
 	a.languages = langs
@@ -167,6 +275,11 @@ func (a *Analyzer) EnableDependencyAnalysis() {
 
 // Analyze performs the filesystem analysis
 func (a *Analyzer) Analyze(root string) (*AnalyzeResult, error) {
+	root, err := normalizeRoot(root)
+	if err != nil {
+		return nil, err
+	}
+
 	result := &AnalyzeResult{
 		Duplicates: make(map[string][]string),
 		CodeStats:  make(map[string]LanguageStats),
@@ -177,6 +290,29 @@ func (a *Analyzer) Analyze(root string) (*AnalyzeResult, error) {
 		ContentPatterns: make(map[string]ContentPattern),
 	}
 
+	// Put the tree in the context of its filesystem
+	var dirStats *dirStatsCollector
+	var ages *ageBucketCollector
+	if a.doStorage {
+		result.StorageReport.FSInfo = collectFSInfo(root)
+		dirStats = newDirStatsCollector(a.dirStatsDepth)
+		ages = newAgeBucketCollector(a.ageBuckets, time.Now())
+	}
+
+	// File sizes by content hash, for duplicate accounting
+	duplicateSizes := make(map[string]int64)
+
+	// Files to hash once the walk is done
+	type dupCandidate struct {
+		path string
+		size int64
+	}
+	var dupCandidates []dupCandidate
+
+	filter := newPathFilter(a.includePatterns, a.excludePatterns)
+	progress := newAnalyzeReporter(a.progressFn)
+	progress.begin(PhaseWalking)
+
 	// For near-duplicate detection, we need to collect all file contents
 	var fileContents map[string][]byte
 	if a.detectNearDups {
@@ -184,18 +320,19 @@ func (a *Analyzer) Analyze(root string) (*AnalyzeResult, error) {
 	}
 
 	// Walk the filesystem
-	err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+	err = filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		relPath, err := filepath.Rel(root, path)
 		if err != nil {
 			return err
 		}
 
 		// Check max depth
 		if a.maxDepth > 0 {
-			relPath, err := filepath.Rel(root, path)
-			if err != nil {
-				return err
-			}
-			if strings.Count(relPath, string(os.PathSeparator)) >= a.maxDepth {
+			if depthOf(root, path) > a.maxDepth {
 				if info.IsDir() {
 					return filepath.SkipDir
 				}
@@ -214,9 +351,20 @@ func (a *Analyzer) Analyze(root string) (*AnalyzeResult, error) {
 			}
 		}
 
+		// Apply include and exclude patterns
+		if skip, skipDir := filter.skip(filepath.ToSlash(relPath), info.IsDir()); skipDir {
+			return filepath.SkipDir
+		} else if skip {
+			return nil
+		}
+
 		// Skip directories in file-specific analysis
 		if info.IsDir() {
 			result.StorageReport.DirCount++
+			progress.p.DirsScanned++
+			if dirStats != nil {
+				dirStats.addDir(filepath.ToSlash(relPath))
+			}
 			return nil
 		}
 
@@ -228,30 +376,52 @@ func (a *Analyzer) Analyze(root string) (*AnalyzeResult, error) {
 
 		result.StorageReport.FileCount++
 		result.StorageReport.TotalSize += size
+		progress.p.FilesScanned++
+		defer progress.tick()
 
 		// For near-duplicate detection, collect file contents
 		if a.detectNearDups {
 			content, err := os.ReadFile(path)
 			if err == nil {
 				fileContents[path] = content
+				progress.p.BytesRead += int64(len(content))
 			}
 		}
 
 		// Analyze based on enabled features
-		if a.detectDuplicates {
-			a.analyzeDuplicates(path, result)
-		}
-		if a.analyzeCode {
-			a.analyzeCodeFile(path, info, result)
+		if a.detectDuplicates && size >= a.minDuplicateSize {
+			dupCandidates = append(dupCandidates, dupCandidate{path, size})
 		}
 		if a.doStorage {
 			a.analyzeStorage(path, info, result)
+			dirStats.addFile(filepath.ToSlash(relPath), size)
+			ages.addFile(filepath.ToSlash(relPath), info.ModTime(), size)
 		}
 		if a.doSecurity {
 			a.analyzeSecurity(path, info, result)
+			progress.p.SecurityIssues = len(result.SecurityIssues)
 		}
-		if a.doPatterns {
-			a.analyzePatterns(path, result)
+
+		// Content analysis skips oversized and binary files
+		if a.analyzeCode || a.doPatterns {
+			switch {
+			case a.maxAnalyzedFileSize > 0 && size > a.maxAnalyzedFileSize:
+				result.SkippedLargeFiles++
+			case !a.doPatterns && a.codeLanguage(path) == "":
+				// Only code is analyzed, and this is not a language wanted
+			case isBinaryFile(path, size):
+				result.SkippedBinaryFiles++
+			default:
+				if a.analyzeCode && a.analyzeCodeFile(path, info, result) {
+					progress.p.CodeFiles++
+					progress.p.BytesRead += size
+				}
+				if a.doPatterns {
+					a.analyzePatterns(path, result)
+					progress.p.PatternFiles++
+					progress.p.BytesRead += size
+				}
+			}
 		}
 
 		return nil
@@ -260,21 +430,47 @@ func (a *Analyzer) Analyze(root string) (*AnalyzeResult, error) {
 	if err != nil {
 		return nil, err
 	}
+	progress.end()
+
+	if dirStats != nil {
+		result.StorageReport.DirectoryStats = dirStats.top(a.dirStatsTopK)
+		result.StorageReport.AgeBuckets = ages.report()
+	}
+
+	if a.detectDuplicates {
+		progress.begin(PhaseHashing)
+		for _, c := range dupCandidates {
+			a.analyzeDuplicates(c.path, c.size, result, duplicateSizes)
+			progress.p.FilesHashed++
+			progress.p.BytesRead += c.size
+			progress.tick()
+		}
+		groupDuplicates(result, duplicateSizes)
+		progress.end()
+	}
 
 	// Perform advanced analysis if enabled
 	if a.detectNearDups || a.analyzeDeps {
 		result.Advanced = &AdvancedAnalysis{}
 
-		if a.detectNearDups && len(fileContents) > 0 {
-			result.Advanced.NearDuplicates = a.detectNearDuplicates(fileContents)
+		if a.detectNearDups {
+			progress.begin(PhaseNearDuplicates)
+			if len(fileContents) > 0 {
+				result.Advanced.NearDuplicates = a.detectNearDuplicates(fileContents)
+			}
+			progress.p.NearDupFiles = len(fileContents)
+			progress.p.NearDupGroups = len(result.Advanced.NearDuplicates)
+			progress.end()
 		}
 
 		if a.analyzeDeps {
-			graph, err := a.analyzeDependencies(root)
+			progress.begin(PhaseDependencies)
+			graph, err := a.analyzeDependencies(root, filter, progress)
 			if err != nil {
 				return nil, fmt.Errorf("dependency analysis failed: %v", err)
 			}
 			result.Advanced.Dependencies = graph
+			progress.end()
 		}
 	}
 
@@ -329,6 +525,48 @@ func (r *AnalyzeResult) String() string {
 	sb.WriteString(fmt.Sprintf("Total Size: %d bytes\n", r.StorageReport.TotalSize))
 	sb.WriteString(fmt.Sprintf("Files: %d\n", r.StorageReport.FileCount))
 	sb.WriteString(fmt.Sprintf("Directories: %d\n", r.StorageReport.DirCount))
+	if fs := r.StorageReport.FSInfo; fs != nil {
+		sb.WriteString(fmt.Sprintf("Filesystem: %s\n", fs))
+	}
+	if r.SkippedLargeFiles > 0 || r.SkippedBinaryFiles > 0 {
+		sb.WriteString(fmt.Sprintf("Skipped for content analysis: %d large, %d binary\n", r.SkippedLargeFiles, r.SkippedBinaryFiles))
+	}
+
+	// Add the directories holding the most data
+	if len(r.StorageReport.DirectoryStats) > 0 {
+		sb.WriteString("\nLargest Directories:\n")
+		for _, d := range r.StorageReport.DirectoryStats {
+			sb.WriteString(fmt.Sprintf("  %s: %d bytes in %d files (%d bytes in %d files directly)\n",
+				d.Path, d.RecursiveBytes, d.RecursiveFiles, d.DirectBytes, d.DirectFiles))
+		}
+	}
+
+	// Add the distribution of data by age
+	if ages := r.StorageReport.AgeBuckets; ages != nil {
+		sb.WriteString("\nAge Buckets (by last modification):\n")
+		writeAgeBuckets(&sb, "  ", ages.Overall)
+		dirs := make([]string, 0, len(ages.ByDirectory))
+		for dir := range ages.ByDirectory {
+			dirs = append(dirs, dir)
+		}
+		sort.Strings(dirs)
+		for _, dir := range dirs {
+			sb.WriteString(fmt.Sprintf("  %s:\n", dir))
+			writeAgeBuckets(&sb, "    ", ages.ByDirectory[dir])
+		}
+	}
+
+	// Add duplicate groups
+	if len(r.DuplicateGroups) > 0 {
+		sb.WriteString("\nDuplicate Files:\n")
+		for _, group := range r.DuplicateGroups {
+			sb.WriteString(fmt.Sprintf("\n%d files of %d bytes, %d bytes wasted:\n", len(group.Paths), group.FileSize, group.WastedBytes))
+			for _, path := range group.Paths {
+				sb.WriteString(fmt.Sprintf("  %s\n", path))
+			}
+		}
+		sb.WriteString(fmt.Sprintf("\nTotal Wasted: %d bytes\n", r.TotalWastedBytes))
+	}
 
 	// Add code stats
 	if len(r.CodeStats) > 0 {
@@ -381,109 +619,102 @@ func (r *AnalyzeResult) String() string {
 }
 
 // analyzeDuplicates detects duplicate files by comparing their content hashes
-func (a *Analyzer) analyzeDuplicates(path string, result *AnalyzeResult) {
-	// Read file content
-	content, err := os.ReadFile(path)
+func (a *Analyzer) analyzeDuplicates(path string, size int64, result *AnalyzeResult, sizes map[string]int64) {
+	// Calculate SHA-256 hash of file content
+	hash, err := hashFile(path)
 	if err != nil {
 		// Skip files that can't be read
 		return
 	}
 
-	// Calculate SHA-256 hash of file content
-	hash := fmt.Sprintf("%x", sha256.Sum256(content))
-
 	// Add file path to the list of files with this hash
 	result.Duplicates[hash] = append(result.Duplicates[hash], path)
+	sizes[hash] = size
 }
 
-// analyzeCodeFile analyzes a source code file for statistics
-func (a *Analyzer) analyzeCodeFile(path string, info os.FileInfo, result *AnalyzeResult) {
-	// Get file extension
-	ext := strings.ToLower(filepath.Ext(path))
-	if ext == "" {
-		return
+// groupDuplicates drops unique files from the duplicate map and builds the
+// duplicate groups, sorted by wasted bytes.
+func groupDuplicates(result *AnalyzeResult, sizes map[string]int64) {
+	for hash, paths := range result.Duplicates {
+		if len(paths) < 2 {
+			delete(result.Duplicates, hash)
+			continue
+		}
+		sort.Strings(paths)
+		group := DuplicateSet{
+			Hash:        hash,
+			Paths:       paths,
+			FileSize:    sizes[hash],
+			WastedBytes: int64(len(paths)-1) * sizes[hash],
+		}
+		result.DuplicateGroups = append(result.DuplicateGroups, group)
+		result.TotalWastedBytes += group.WastedBytes
 	}
 
+	sort.Slice(result.DuplicateGroups, func(i, j int) bool {
+		gi, gj := result.DuplicateGroups[i], result.DuplicateGroups[j]
+		if gi.WastedBytes != gj.WastedBytes {
+			return gi.WastedBytes > gj.WastedBytes
+		}
+		return gi.Hash < gj.Hash
+	})
+}
+
+// analyzeCodeFile analyzes a source code file for statistics, reporting
+// whether the file was counted
+func (a *Analyzer) analyzeCodeFile(path string, info os.FileInfo, result *AnalyzeResult) bool {
 	// Check if this is a language we're interested in
-	lang := getLanguageFromExt(ext)
-	if lang == "" || (len(a.languages) > 0 && !contains(a.languages, lang)) {
-		return
+	lang := a.codeLanguage(path)
+	if lang == "" {
+		return false
 	}
 
-	// Read file content
-	content, err := os.ReadFile(path)
+	f, err := os.Open(path)
 	if err != nil {
-		return
-	}
-
-	// Get or create language stats
-	stats := result.CodeStats[lang]
-	stats.Files++
-	stats.Size += info.Size()
-	if !contains(stats.Extensions, ext) {
-		stats.Extensions = append(stats.Extensions, ext)
+		return false
 	}
+	defer f.Close()
 
-	// Count lines
-	lines := strings.Split(string(content), "\n")
-	stats.Lines += len(lines)
-
-	// Count blank lines and comments
-	for _, line := range lines {
-		trimmed := strings.TrimSpace(line)
+	// Count lines, blanks and comments without loading the whole file
+	var lines, blanks, comments int
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), maxLineLength)
+	for scanner.Scan() {
+		lines++
+		trimmed := strings.TrimSpace(scanner.Text())
 		if trimmed == "" {
-			stats.Blanks++
+			blanks++
 			continue
 		}
 
 		// Check for comments based on language
 		if isComment(trimmed, lang) {
-			stats.Comments++
+			comments++
 		}
 	}
+	if scanner.Err() != nil {
+		return false
+	}
+
+	// Get or create language stats
+	ext := strings.ToLower(filepath.Ext(path))
+	stats := result.CodeStats[lang]
+	stats.Files++
+	stats.Size += info.Size()
+	if !contains(stats.Extensions, ext) {
+		stats.Extensions = append(stats.Extensions, ext)
+	}
+	stats.Lines += lines
+	stats.Blanks += blanks
+	stats.Comments += comments
 
 	result.CodeStats[lang] = stats
+	return true
 }
 
 // analyzeSecurity checks for security issues in files and directories
 func (a *Analyzer) analyzeSecurity(path string, info os.FileInfo, result *AnalyzeResult) {
-	// Check file permissions
-	mode := info.Mode()
-
-	// Check for world-writable files
-	if mode&0002 != 0 {
-		result.SecurityIssues = append(result.SecurityIssues, SecurityIssue{
-			Path:        path,
-			Description: "File is world-writable",
-			Severity:    "High",
-		})
-	}
-
-	// Check for setuid/setgid bits
-	if mode&os.ModeSetuid != 0 {
-		result.SecurityIssues = append(result.SecurityIssues, SecurityIssue{
-			Path:        path,
-			Description: "File has setuid bit set",
-			Severity:    "High",
-		})
-	}
-	if mode&os.ModeSetgid != 0 {
-		result.SecurityIssues = append(result.SecurityIssues, SecurityIssue{
-			Path:        path,
-			Description: "File has setgid bit set",
-			Severity:    "High",
-		})
-	}
-
-	// Check for suspicious file extensions
-	ext := strings.ToLower(filepath.Ext(path))
-	if isSuspiciousExt(ext) {
-		result.SecurityIssues = append(result.SecurityIssues, SecurityIssue{
-			Path:        path,
-			Description: "File has suspicious extension",
-			Severity:    "Medium",
-		})
-	}
+	result.SecurityIssues = checkSecurity(securityRules, path, info, result.SecurityIssues)
 }
 
 // analyzePatterns looks for specific content patterns in files
@@ -571,6 +802,75 @@ func isComment(line, lang string) bool {
 	return false
 }
 
+// codeLanguage returns the language of the file at path, by its
+// extension, if code stats are collected for it, and "" otherwise.
+func (a *Analyzer) codeLanguage(path string) string {
+	lang := getLanguageFromExt(strings.ToLower(filepath.Ext(path)))
+	if lang == "" || (len(a.languages) > 0 && !contains(a.languages, lang)) {
+		return ""
+	}
+	return lang
+}
+
+// isBinaryExt reports whether ext is that of a format that is binary
+// whatever its first bytes hold.
+func isBinaryExt(ext string) bool {
+	binary := map[string]bool{
+		".png":   true,
+		".jpg":   true,
+		".jpeg":  true,
+		".gif":   true,
+		".ico":   true,
+		".zip":   true,
+		".gz":    true,
+		".tgz":   true,
+		".xz":    true,
+		".7z":    true,
+		".exe":   true,
+		".dll":   true,
+		".so":    true,
+		".dylib": true,
+		".o":     true,
+		".a":     true,
+		".class": true,
+		".jar":   true,
+		".wasm":  true,
+		".pdf":   true,
+		".mp3":   true,
+		".mp4":   true,
+		".woff":  true,
+		".woff2": true,
+		".ttf":   true,
+	}
+	return binary[ext]
+}
+
+// isBinaryFile reports whether a file of size bytes looks binary. Empty
+// files are not, and files with a binary extension are; only the rest are
+// read, and are binary if they contain a null byte within their first few
+// kilobytes.
+func isBinaryFile(path string, size int64) bool {
+	if size == 0 {
+		return false
+	}
+	if isBinaryExt(strings.ToLower(filepath.Ext(path))) {
+		return true
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+
+	buf := make([]byte, binarySniffLen)
+	n, err := io.ReadFull(f, buf)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return false
+	}
+	return bytes.IndexByte(buf[:n], 0) != -1
+}
+
 func isSuspiciousExt(ext string) bool {
 	suspicious := map[string]bool{
 		".exe":   true,
@@ -630,3 +930,10 @@ func (a *Analyzer) analyzeStorage(path string, info os.FileInfo, result *Analyze
 	stats.Size += info.Size()
 	result.StorageReport.TypeStats[ext] = stats
 }
+
+// writeAgeBuckets writes a line for each of buckets, indented by indent.
+func writeAgeBuckets(sb *strings.Builder, indent string, buckets []AgeBucket) {
+	for _, b := range buckets {
+		sb.WriteString(fmt.Sprintf("%s%s: %d bytes in %d files\n", indent, b.Label, b.Bytes, b.Files))
+	}
+}
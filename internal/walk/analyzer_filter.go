@@ -0,0 +1,110 @@
+package stride
+
+import (
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// pathFilter applies the Analyzer's include and exclude patterns to
+// root-relative, slash-separated paths. Patterns follow MatchPattern; a
+// trailing slash selects a directory's whole subtree, so "src/" is "src/**".
+type pathFilter struct {
+	include []string
+	exclude []string
+}
+
+// newPathFilter normalizes include and exclude patterns.
+func newPathFilter(include, exclude []string) pathFilter {
+	norm := func(patterns []string) []string {
+		var out []string
+		for _, p := range patterns {
+			p = filepath.ToSlash(strings.TrimPrefix(p, "./"))
+			if p == "" {
+				continue
+			}
+			if strings.HasSuffix(p, "/") {
+				p += "**"
+			}
+			out = append(out, p)
+		}
+		return out
+	}
+	return pathFilter{include: norm(include), exclude: norm(exclude)}
+}
+
+// excluded reports whether rel matches an exclude pattern.
+func (f pathFilter) excluded(rel string) bool {
+	for _, p := range f.exclude {
+		if ok, err := MatchPattern(p, rel); err == nil && ok {
+			return true
+		}
+	}
+	return false
+}
+
+// included reports whether rel matches an include pattern, or whether no
+// include patterns are set.
+func (f pathFilter) included(rel string) bool {
+	if len(f.include) == 0 {
+		return true
+	}
+	for _, p := range f.include {
+		if ok, err := MatchPattern(p, rel); err == nil && ok {
+			return true
+		}
+	}
+	return false
+}
+
+// mayContain reports whether entries below the directory rel could match an
+// include pattern, so directories that cannot are not walked.
+func (f pathFilter) mayContain(rel string) bool {
+	if len(f.include) == 0 || rel == "." {
+		return true
+	}
+	dirSegs := strings.Split(rel, "/")
+	for _, p := range f.include {
+		if !isPathPattern(p) || prefixMayMatch(strings.Split(p, "/"), dirSegs) {
+			return true
+		}
+	}
+	return false
+}
+
+// prefixMayMatch reports whether a path starting with the directory
+// segments dir could match the pattern segments pat.
+func prefixMayMatch(pat, dir []string) bool {
+	for i, seg := range dir {
+		if i >= len(pat) {
+			return false
+		}
+		if pat[i] == "**" {
+			return true
+		}
+		if ok, err := path.Match(pat[i], seg); err != nil || !ok {
+			return false
+		}
+	}
+	return len(pat) > len(dir)
+}
+
+// skip decides whether the walk skips the entry at rel. For directories,
+// skipDir reports whether to skip the whole subtree, and counted whether
+// the directory itself is part of the analysis.
+func (f pathFilter) skip(rel string, isDir bool) (skip, skipDir bool) {
+	if rel == "." {
+		// The root is walked, but only counted without include patterns
+		return len(f.include) > 0, false
+	}
+	if f.excluded(rel) {
+		return true, isDir
+	}
+	if isDir {
+		if !f.mayContain(rel) {
+			return true, true
+		}
+		return !f.included(rel), false
+	}
+	return !f.included(rel), false
+}
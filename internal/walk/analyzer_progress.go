@@ -0,0 +1,82 @@
+package stride
+
+import "time"
+
+// AnalyzePhase identifies a stage of Analyzer.Analyze.
+type AnalyzePhase string
+
+const (
+	PhaseWalking        AnalyzePhase = "walking"  // Walking the tree and running per-file features
+	PhaseHashing        AnalyzePhase = "hashing"  // Hashing candidates for duplicate detection
+	PhaseNearDuplicates AnalyzePhase = "near-dup" // Comparing file contents for near-duplicates
+	PhaseDependencies   AnalyzePhase = "deps"     // Parsing imports for dependency analysis
+)
+
+// AnalyzeProgress is a snapshot of a running analysis, passed to the
+// callback set with Analyzer.SetProgressCallback.
+type AnalyzeProgress struct {
+	Phase     AnalyzePhase  // Current phase
+	PhaseDone bool          // Whether this report ends the phase
+	Elapsed   time.Duration // Time since the analysis started
+
+	FilesScanned int   // Files accepted by the walk
+	DirsScanned  int   // Directories accepted by the walk
+	BytesRead    int64 // Bytes of file content read by the enabled features
+
+	// Per-feature counts
+	FilesHashed    int // Files hashed for duplicate detection
+	CodeFiles      int // Files counted by code statistics
+	PatternFiles   int // Files scanned for content patterns
+	SecurityIssues int // Security issues found
+	NearDupFiles   int // Files compared for near-duplicates
+	NearDupGroups  int // Near-duplicate groups found
+	DepFiles       int // Go files parsed for dependency analysis
+}
+
+// progressInterval throttles progress reports within a phase.
+const progressInterval = 100 * time.Millisecond
+
+// analyzeReporter accumulates AnalyzeProgress and reports it. A reporter
+// without a callback only counts.
+type analyzeReporter struct {
+	fn    func(AnalyzeProgress)
+	p     AnalyzeProgress
+	start time.Time
+	last  time.Time
+}
+
+// newAnalyzeReporter creates a reporter calling fn, which may be nil.
+func newAnalyzeReporter(fn func(AnalyzeProgress)) *analyzeReporter {
+	now := time.Now()
+	return &analyzeReporter{fn: fn, start: now, last: now}
+}
+
+// begin starts a phase and reports it.
+func (r *analyzeReporter) begin(phase AnalyzePhase) {
+	r.p.Phase = phase
+	r.p.PhaseDone = false
+	r.report()
+}
+
+// end reports the current phase as finished.
+func (r *analyzeReporter) end() {
+	r.p.PhaseDone = true
+	r.report()
+}
+
+// tick reports the counters if enough time has passed since the last report.
+func (r *analyzeReporter) tick() {
+	if r.fn != nil && time.Since(r.last) >= progressInterval {
+		r.report()
+	}
+}
+
+// report passes the current counters to the callback.
+func (r *analyzeReporter) report() {
+	if r.fn == nil {
+		return
+	}
+	r.last = time.Now()
+	r.p.Elapsed = r.last.Sub(r.start)
+	r.fn(r.p)
+}
@@ -1,8 +1,10 @@
 package stride
 
 import (
+	"bytes"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 )
 
@@ -72,30 +74,18 @@ func TestAnalyzer(t *testing.T) {
 
 	// Test duplicate detection
 	t.Run("DuplicateDetection", func(t *testing.T) {
-		foundDuplicate := false
-		for _, paths := range result.Duplicates {
-			if len(paths) > 1 {
-				foundDuplicate = true
-				// Check if our known duplicates are found
-				file1Found := false
-				file2Found := false
-				for _, path := range paths {
-					base := filepath.Base(path)
-					if base == "file1.txt" {
-						file1Found = true
-					}
-					if base == "file2.txt" {
-						file2Found = true
-					}
-				}
-				if !file1Found || !file2Found {
-					t.Errorf("Expected to find file1.txt and file2.txt as duplicates")
-				}
-				break
-			}
+		if len(result.Duplicates) != 1 || len(result.DuplicateGroups) != 1 {
+			t.Fatalf("Expected exactly 1 duplicate group, got %d map entries and %d groups",
+				len(result.Duplicates), len(result.DuplicateGroups))
+		}
+		group := result.DuplicateGroups[0]
+		if len(group.Paths) != 2 ||
+			filepath.Base(group.Paths[0]) != "file1.txt" ||
+			filepath.Base(group.Paths[1]) != "file2.txt" {
+			t.Errorf("Expected file1.txt and file2.txt as duplicates, got %v", group.Paths)
 		}
-		if !foundDuplicate {
-			t.Error("No duplicates found when duplicates exist")
+		if group.WastedBytes != int64(len("Hello, World!")) {
+			t.Errorf("Expected %d wasted bytes, got %d", len("Hello, World!"), group.WastedBytes)
 		}
 	})
 
@@ -287,3 +277,285 @@ func TestAnalyzerOptions(t *testing.T) {
 		}
 	})
 }
+
+func TestAnalyzerSkipsLargeAndBinaryFiles(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	// A 20MB source file exceeds the default analysis limit
+	large := bytes.Repeat([]byte("var x = 1;\n"), 20*1024*1024/11+1)
+	if err := os.WriteFile(filepath.Join(tmpDir, "bundle.js"), large, 0644); err != nil {
+		t.Fatalf("Failed to create large file: %v", err)
+	}
+
+	// A binary blob with a code extension
+	blob := append([]byte("package main\n"), 0, 1, 2, 3)
+	if err := os.WriteFile(filepath.Join(tmpDir, "blob.go"), blob, 0644); err != nil {
+		t.Fatalf("Failed to create binary file: %v", err)
+	}
+
+	// An image is binary by its extension alone
+	if err := os.WriteFile(filepath.Join(tmpDir, "logo.png"), []byte("not really an image"), 0644); err != nil {
+		t.Fatalf("Failed to create image file: %v", err)
+	}
+
+	// A regular source file with a long single line
+	long := "// " + strings.Repeat("x", 128*1024) + "\npackage main\n"
+	if err := os.WriteFile(filepath.Join(tmpDir, "main.py"), []byte(long), 0644); err != nil {
+		t.Fatalf("Failed to create source file: %v", err)
+	}
+
+	analyzer := NewAnalyzer()
+	analyzer.EnableCodeStats()
+	analyzer.EnableStorageReport()
+	analyzer.EnableContentPatternAnalysis()
+
+	result, err := analyzer.Analyze(tmpDir)
+	if err != nil {
+		t.Fatalf("Analysis failed: %v", err)
+	}
+
+	if _, ok := result.CodeStats["JavaScript"]; ok {
+		t.Error("Expected oversized JavaScript file to be skipped")
+	}
+	if _, ok := result.CodeStats["Go"]; ok {
+		t.Error("Expected binary Go file to be skipped")
+	}
+	if result.SkippedLargeFiles != 1 {
+		t.Errorf("Expected 1 skipped large file, got %d", result.SkippedLargeFiles)
+	}
+	if result.SkippedBinaryFiles != 2 {
+		t.Errorf("Expected 2 skipped binary files, got %d", result.SkippedBinaryFiles)
+	}
+
+	// Skipped files are still part of the storage report
+	if result.StorageReport.FileCount != 4 {
+		t.Errorf("Expected 4 files in storage report, got %d", result.StorageReport.FileCount)
+	}
+
+	pyStats := result.CodeStats["Python"]
+	if pyStats.Files != 1 || pyStats.Lines != 2 {
+		t.Errorf("Expected 1 Python file with 2 lines, got %d files with %d lines", pyStats.Files, pyStats.Lines)
+	}
+}
+
+func TestAnalyzerDuplicateGroups(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	small := strings.Repeat("s", 100)
+	large := strings.Repeat("L", 1000)
+	files := map[string]string{
+		"small1.txt": small,
+		"small2.txt": small,
+		"small3.txt": small,
+		"small4.txt": small,
+		"large1.txt": large,
+		"large2.txt": large,
+		"tiny1.txt":  "x",
+		"tiny2.txt":  "x",
+		"unique.txt": "only one of these",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(tmpDir, name), []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to create test file %s: %v", name, err)
+		}
+	}
+
+	analyzer := NewAnalyzer()
+	analyzer.EnableDuplicateDetection()
+	analyzer.SetMinDuplicateSize(10)
+	result, err := analyzer.Analyze(tmpDir)
+	if err != nil {
+		t.Fatalf("Analysis failed: %v", err)
+	}
+
+	// Tiny and unique files are not reported
+	if len(result.DuplicateGroups) != 2 {
+		t.Fatalf("Expected 2 duplicate groups, got %d", len(result.DuplicateGroups))
+	}
+
+	// The large pair wastes more (1 × 1000) than the small quad (3 × 100)
+	expected := []struct {
+		copies   int
+		size     int64
+		wasted   int64
+		basename string
+	}{
+		{2, 1000, 1000, "large1.txt"},
+		{4, 100, 300, "small1.txt"},
+	}
+	for i, want := range expected {
+		group := result.DuplicateGroups[i]
+		if len(group.Paths) != want.copies {
+			t.Errorf("Group %d: expected %d paths, got %d", i, want.copies, len(group.Paths))
+		}
+		if group.FileSize != want.size {
+			t.Errorf("Group %d: expected file size %d, got %d", i, want.size, group.FileSize)
+		}
+		if group.WastedBytes != want.wasted {
+			t.Errorf("Group %d: expected %d wasted bytes, got %d", i, want.wasted, group.WastedBytes)
+		}
+		if filepath.Base(group.Paths[0]) != want.basename {
+			t.Errorf("Group %d: expected first path %s, got %s", i, want.basename, group.Paths[0])
+		}
+	}
+
+	if result.TotalWastedBytes != 1300 {
+		t.Errorf("Expected 1300 total wasted bytes, got %d", result.TotalWastedBytes)
+	}
+	if !strings.Contains(result.String(), "Total Wasted: 1300 bytes") {
+		t.Errorf("Expected total wasted bytes in report, got:\n%s", result.String())
+	}
+}
+
+// writeAnalyzerFixture creates files with the given contents below root.
+func writeAnalyzerFixture(t *testing.T, root string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		path := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("Failed to create directory: %v", err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to create test file %s: %v", name, err)
+		}
+	}
+}
+
+func TestAnalyzerIncludeExcludePatterns(t *testing.T) {
+	tmpDir := t.TempDir()
+	writeAnalyzerFixture(t, tmpDir, map[string]string{
+		"src/main.go":       "package main\n\nfunc main() {}\n",
+		"src/lib/lib.go":    "package lib\n\n// Lib does nothing\nfunc Lib() {}\n",
+		"src/lib/notes.txt": "notes",
+		"pkg/util/util.go":  "package util\n",
+		"docs/guide.md":     "# Guide\n",
+		"top.txt":           "top",
+	})
+
+	analyze := func(root string, include, exclude []string) *AnalyzeResult {
+		t.Helper()
+		analyzer := NewAnalyzer()
+		analyzer.EnableCodeStats()
+		analyzer.SetIncludePatterns(include)
+		analyzer.SetExcludePatterns(exclude)
+		result, err := analyzer.Analyze(root)
+		if err != nil {
+			t.Fatalf("Analysis failed: %v", err)
+		}
+		return result
+	}
+
+	// Including a subdirectory matches analyzing that subtree directly
+	subtree := analyze(filepath.Join(tmpDir, "src"), nil, nil)
+	for _, include := range []string{"src/", "src/**"} {
+		got := analyze(tmpDir, []string{include}, nil)
+		if got.StorageReport.FileCount != subtree.StorageReport.FileCount ||
+			got.StorageReport.DirCount != subtree.StorageReport.DirCount ||
+			got.StorageReport.TotalSize != subtree.StorageReport.TotalSize {
+			t.Errorf("Include %q: got %+v, want %+v", include, got.StorageReport, subtree.StorageReport)
+		}
+		if got.CodeStats["Go"].Files != 2 || got.CodeStats["Go"].Lines != subtree.CodeStats["Go"].Lines {
+			t.Errorf("Include %q: unexpected code stats %+v", include, got.CodeStats["Go"])
+		}
+	}
+
+	tests := []struct {
+		name    string
+		include []string
+		exclude []string
+		files   int
+		goFiles int
+	}{
+		{"No patterns", nil, nil, 6, 3},
+		{"Two subtrees", []string{"src/", "pkg/"}, nil, 4, 3},
+		{"Base name pattern", []string{"*.go"}, nil, 3, 3},
+		{"Nested path pattern", []string{"src/**/*.go"}, nil, 2, 2},
+		{"Exclude subtree", nil, []string{"src/lib/"}, 4, 2},
+		{"Include and exclude", []string{"src/"}, []string{"*.txt"}, 2, 2},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			result := analyze(tmpDir, tc.include, tc.exclude)
+			if result.StorageReport.FileCount != tc.files {
+				t.Errorf("Expected %d files, got %d", tc.files, result.StorageReport.FileCount)
+			}
+			if result.CodeStats["Go"].Files != tc.goFiles {
+				t.Errorf("Expected %d Go files, got %d", tc.goFiles, result.CodeStats["Go"].Files)
+			}
+		})
+	}
+}
+
+func TestAnalyzerProgress(t *testing.T) {
+	tmpDir := t.TempDir()
+	body := strings.Repeat("shared content for near-duplicate detection, long enough to hash\n", 4)
+	writeAnalyzerFixture(t, tmpDir, map[string]string{
+		"a.txt":      body,
+		"b.txt":      body,
+		"main.go":    "package main\n\nimport \"fmt\"\n\nfunc main() { fmt.Println() }\n",
+		"lib/lib.go": "package lib\n",
+	})
+
+	analyzer := NewAnalyzer()
+	analyzer.EnableDuplicateDetection()
+	analyzer.EnableCodeStats()
+	analyzer.EnableNearDuplicateDetection()
+	analyzer.EnableDependencyAnalysis()
+
+	var reports []AnalyzeProgress
+	analyzer.SetProgressCallback(func(p AnalyzeProgress) {
+		reports = append(reports, p)
+	})
+	if _, err := analyzer.Analyze(tmpDir); err != nil {
+		t.Fatalf("Analysis failed: %v", err)
+	}
+
+	// Every enabled phase starts and ends, in order
+	var ended []AnalyzePhase
+	started := make(map[AnalyzePhase]bool)
+	for _, p := range reports {
+		if !p.PhaseDone {
+			started[p.Phase] = true
+			continue
+		}
+		if !started[p.Phase] {
+			t.Errorf("Phase %s ended without starting", p.Phase)
+		}
+		ended = append(ended, p.Phase)
+	}
+	want := []AnalyzePhase{PhaseWalking, PhaseHashing, PhaseNearDuplicates, PhaseDependencies}
+	if len(ended) != len(want) {
+		t.Fatalf("Expected phases %v to end, got %v", want, ended)
+	}
+	for i := range want {
+		if ended[i] != want[i] {
+			t.Errorf("Expected phases %v to end, got %v", want, ended)
+			break
+		}
+	}
+
+	last := reports[len(reports)-1]
+	if last.FilesScanned != 4 || last.DirsScanned != 2 || last.FilesHashed != 4 ||
+		last.CodeFiles != 2 || last.NearDupFiles != 4 || last.NearDupGroups != 1 || last.DepFiles != 2 {
+		t.Errorf("Unexpected final progress: %+v", last)
+	}
+	if last.BytesRead == 0 {
+		t.Error("Expected bytes read to be reported")
+	}
+
+	// Only enabled phases are reported
+	analyzer = NewAnalyzer()
+	analyzer.EnableStorageReport()
+	var phases []AnalyzePhase
+	analyzer.SetProgressCallback(func(p AnalyzeProgress) {
+		if p.PhaseDone {
+			phases = append(phases, p.Phase)
+		}
+	})
+	if _, err := analyzer.Analyze(tmpDir); err != nil {
+		t.Fatalf("Analysis failed: %v", err)
+	}
+	if len(phases) != 1 || phases[0] != PhaseWalking {
+		t.Errorf("Expected only the walking phase, got %v", phases)
+	}
+}
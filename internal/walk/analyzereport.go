@@ -0,0 +1,103 @@
+package stride
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strconv"
+	"time"
+)
+
+// AnalyzeSchemaVersion is the schema version of analyzer reports written by
+// WriteAnalyzeReport. It is bumped whenever a field is renamed, removed or
+// changes meaning; fields are added without bumping it, so readers must
+// ignore fields they do not know.
+const AnalyzeSchemaVersion = "1"
+
+// AnalyzeMeta describes the analysis an analyzer report holds.
+type AnalyzeMeta struct {
+	SchemaVersion string    `json:"schema_version"` // Schema version of the report
+	GeneratedAt   time.Time `json:"generated_at"`   // When the report was generated
+	Root          string    `json:"root"`           // Analyzed directory
+	Features      []string  `json:"features"`       // Enabled analyses, e.g. "duplicates"
+}
+
+// analyzeReport is the top-level JSON object of an analyzer report.
+type analyzeReport struct {
+	AnalyzeMeta
+	Result *AnalyzeResult `json:"result"`
+}
+
+// Features returns the names of the enabled analyses, in a fixed order.
+func (a *Analyzer) Features() []string {
+	features := []string{}
+	for _, f := range []struct {
+		name    string
+		enabled bool
+	}{
+		{"duplicates", a.detectDuplicates},
+		{"code_stats", a.analyzeCode},
+		{"storage_report", a.doStorage},
+		{"security_scan", a.doSecurity},
+		{"content_patterns", a.doPatterns},
+		{"near_duplicates", a.detectNearDups},
+		{"dependencies", a.analyzeDeps},
+	} {
+		if f.enabled {
+			features = append(features, f.name)
+		}
+	}
+	return features
+}
+
+// Meta describes an analysis of root by a, generated now.
+func (a *Analyzer) Meta(root string) AnalyzeMeta {
+	if abs, err := filepath.Abs(root); err == nil {
+		root = abs
+	}
+	return AnalyzeMeta{
+		SchemaVersion: AnalyzeSchemaVersion,
+		GeneratedAt:   time.Now().UTC().Truncate(time.Second),
+		Root:          root,
+		Features:      a.Features(),
+	}
+}
+
+// WriteAnalyzeReport writes result to w as an indented JSON report: an
+// object holding the fields of meta, with the result under "result".
+func WriteAnalyzeReport(w io.Writer, meta AnalyzeMeta, result *AnalyzeResult) error {
+	if meta.SchemaVersion == "" {
+		meta.SchemaVersion = AnalyzeSchemaVersion
+	}
+	data, err := json.MarshalIndent(analyzeReport{AnalyzeMeta: meta, Result: result}, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(append(data, '\n'))
+	return err
+}
+
+// ParseAnalyzeReport reads a report written by WriteAnalyzeReport. Reports
+// of any schema version up to AnalyzeSchemaVersion are accepted, and fields
+// added since are ignored; newer versions are rejected because their fields
+// may mean something else.
+func ParseAnalyzeReport(r io.Reader) (*AnalyzeResult, AnalyzeMeta, error) {
+	var report analyzeReport
+	if err := json.NewDecoder(r).Decode(&report); err != nil {
+		return nil, AnalyzeMeta{}, fmt.Errorf("invalid analyzer report: %w", err)
+	}
+	meta := report.AnalyzeMeta
+
+	version, err := strconv.Atoi(meta.SchemaVersion)
+	if err != nil || version < 1 {
+		return nil, meta, fmt.Errorf("invalid analyzer report: schema_version %q", meta.SchemaVersion)
+	}
+	if current, _ := strconv.Atoi(AnalyzeSchemaVersion); version > current {
+		return nil, meta, fmt.Errorf("analyzer report schema_version %d is newer than the supported %d", version, current)
+	}
+	if report.Result == nil {
+		return nil, meta, fmt.Errorf("invalid analyzer report: no result")
+	}
+	return report.Result, meta, nil
+}
@@ -0,0 +1,142 @@
+package stride
+
+import (
+	"bytes"
+	"flag"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+var updateGolden = flag.Bool("update", false, "rewrite golden files")
+
+// sampleAnalyzeReport returns a report exercising every result type.
+func sampleAnalyzeReport() (AnalyzeMeta, *AnalyzeResult) {
+	meta := AnalyzeMeta{
+		SchemaVersion: AnalyzeSchemaVersion,
+		GeneratedAt:   time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC),
+		Root:          "/data",
+		Features:      []string{"duplicates", "code_stats", "storage_report", "security_scan", "content_patterns", "dependencies"},
+	}
+	file := FileInfo{Path: "a.go", Size: 120, Modified: "2024-04-01T00:00:00Z", Extension: ".go"}
+	result := &AnalyzeResult{
+		Duplicates:      map[string][]string{"abc": {"x.txt", "y.txt"}},
+		DuplicateGroups: []DuplicateSet{{Hash: "abc", Paths: []string{"x.txt", "y.txt"}, FileSize: 10, WastedBytes: 10}},
+		CodeStats:       map[string]LanguageStats{"Go": {Files: 1, Lines: 10, Blanks: 2, Comments: 1, Size: 120, Extensions: []string{".go"}}},
+		StorageReport: StorageReport{
+			TotalSize:      140,
+			FileCount:      3,
+			DirCount:       1,
+			TypeStats:      map[string]TypeStats{".go": {Count: 1, Size: 120}},
+			LargestFiles:   []FileInfo{file},
+			OldestFiles:    []FileInfo{file},
+			NewestFiles:    []FileInfo{file},
+			FSInfo:         &FSInfo{TotalBytes: 1000, FreeBytes: 500, AvailBytes: 400, TotalInodes: 100, FreeInodes: 50, FSType: "ext4"},
+			DirectoryStats: []DirStat{{Path: ".", RecursiveBytes: 140, RecursiveFiles: 3, DirectBytes: 140, DirectFiles: 3}},
+		},
+		SecurityIssues:  []SecurityIssue{{Path: "run.sh", Rule: RuleWorldWritable, Description: "World-writable file", Severity: "Medium"}},
+		ContentPatterns: map[string]ContentPattern{"TODO": {Count: 1, Files: []string{"a.go"}, Examples: []string{"TODO: fix"}}},
+		Advanced: &AdvancedAnalysis{
+			NearDuplicates: []DuplicateGroup{{Files: []string{"p.txt", "q.txt"}, Similarity: 0.9, Resolution: "Review", CommonPath: "."}},
+			Dependencies: &CodebaseGraph{
+				Files: map[string]*DependencyInfo{"a.go": {
+					Path: "a.go", Imports: []string{"fmt"}, ImportedBy: []string{}, IsOrphan: true,
+					Dependencies: map[string]string{},
+				}},
+				Orphans:     []string{"a.go"},
+				UnusedFiles: []string{},
+			},
+		},
+		TotalWastedBytes:   10,
+		SkippedLargeFiles:  1,
+		SkippedBinaryFiles: 2,
+	}
+	return meta, result
+}
+
+func TestAnalyzeReportGolden(t *testing.T) {
+	meta, result := sampleAnalyzeReport()
+	var buf bytes.Buffer
+	if err := WriteAnalyzeReport(&buf, meta, result); err != nil {
+		t.Fatalf("WriteAnalyzeReport failed: %v", err)
+	}
+
+	// Kept out of testdata, whose tree the walk tests count entry by entry
+	golden := filepath.Join("golden", "analyze_report_v1.json")
+	if *updateGolden {
+		if err := os.WriteFile(golden, buf.Bytes(), 0644); err != nil {
+			t.Fatalf("Failed to update golden file: %v", err)
+		}
+	}
+	want, err := os.ReadFile(golden)
+	if err != nil {
+		t.Fatalf("Failed to read golden file: %v", err)
+	}
+	if buf.String() != string(want) {
+		t.Errorf("Report layout changed; bump AnalyzeSchemaVersion if a field changed meaning, then rerun with -update.\nExpected:\n%s\nGot:\n%s", want, buf.String())
+	}
+
+	// The report reads back to what was written
+	gotResult, gotMeta, err := ParseAnalyzeReport(&buf)
+	if err != nil {
+		t.Fatalf("ParseAnalyzeReport failed: %v", err)
+	}
+	if !reflect.DeepEqual(gotMeta, meta) || !reflect.DeepEqual(gotResult, result) {
+		t.Errorf("Expected the report to round-trip, got %+v and %+v", gotMeta, gotResult)
+	}
+}
+
+func TestParseAnalyzeReportCompatibility(t *testing.T) {
+	// A v1 document with fields added by a later release still parses
+	doc := `{
+  "schema_version": "1",
+  "generated_at": "2024-05-01T12:00:00Z",
+  "root": "/data",
+  "features": ["code_stats"],
+  "producer": "stride 9.9",
+  "result": {
+    "code_stats": {"Go": {"files": 2, "lines": 30, "median_line_length": 42}},
+    "storage_report": {"total_size": 7, "compressed_size": 3},
+    "total_wasted_bytes": 0
+  }
+}`
+	result, meta, err := ParseAnalyzeReport(strings.NewReader(doc))
+	if err != nil {
+		t.Fatalf("ParseAnalyzeReport failed: %v", err)
+	}
+	if meta.Root != "/data" || !reflect.DeepEqual(meta.Features, []string{"code_stats"}) {
+		t.Errorf("Unexpected meta %+v", meta)
+	}
+	if result.CodeStats["Go"].Lines != 30 || result.StorageReport.TotalSize != 7 {
+		t.Errorf("Unexpected result %+v", result)
+	}
+
+	for name, doc := range map[string]string{
+		"newer version":  `{"schema_version": "2", "result": {}}`,
+		"no version":     `{"result": {}}`,
+		"no result":      `{"schema_version": "1"}`,
+		"not a report":   `[1, 2]`,
+		"invalid syntax": `{"schema_version": `,
+	} {
+		if _, _, err := ParseAnalyzeReport(strings.NewReader(doc)); err == nil {
+			t.Errorf("Expected %s to be rejected", name)
+		}
+	}
+}
+
+func TestAnalyzerMeta(t *testing.T) {
+	a := NewAnalyzer()
+	a.EnableStorageReport()
+	a.EnableDuplicateDetection()
+
+	meta := a.Meta(".")
+	if !reflect.DeepEqual(meta.Features, []string{"duplicates", "storage_report"}) {
+		t.Errorf("Expected duplicates and storage_report, got %v", meta.Features)
+	}
+	if meta.SchemaVersion != AnalyzeSchemaVersion || !filepath.IsAbs(meta.Root) || meta.GeneratedAt.IsZero() {
+		t.Errorf("Unexpected meta %+v", meta)
+	}
+}
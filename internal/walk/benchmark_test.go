@@ -12,7 +12,7 @@ import (
 )
 
 // setupLargeTestDir creates a larger test directory structure for benchmarking
-func setupLargeTestDir(b *testing.B) string {
+func setupLargeTestDir(b testing.TB) string {
 	// Create a temporary directory
 	tempDir := b.TempDir()
 
@@ -0,0 +1,124 @@
+package stride
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+)
+
+// walkBreadthFirst is walkDirTree for WalkOptions.TraversalOrder
+// BreadthFirst: every entry of a directory is passed to fn before any entry
+// of its subdirectories, which are read in the order they were enumerated.
+// They wait in a queue rather than on the stack, so SkipDir returned for a
+// directory keeps it from being queued, while SkipDir returned for anything
+// else skips the rest of its directory, as with filepath.WalkDir. listed,
+// unless nil, is called with each directory once all of its entries have
+// been passed to fn, or once it is skipped.
+func walkBreadthFirst(root string, fn fs.WalkDirFunc, listed func(dir string)) error {
+	info, err := os.Lstat(root)
+	if err != nil {
+		return skipToNil(fn(root, nil, err))
+	}
+	return walkLevels(root, fs.FileInfoToDirEntry(info), os.ReadDir, reparseVisit(root, fn), listed)
+}
+
+// walkBreadthFirst is walkDir visiting the directory at dir breadth first,
+// as the function of the same name does.
+func (c *confinedRoot) walkBreadthFirst(dir string, fn fs.WalkDirFunc, listed func(dir string)) error {
+	rel, err := filepath.Rel(c.name, dir)
+	if err != nil || !filepath.IsLocal(rel) {
+		return fn(dir, nil, &os.PathError{Op: "walk", Path: dir, Err: errors.New("path escapes from root")})
+	}
+	fsys := c.root.FS()
+	info, err := fs.Stat(fsys, filepath.ToSlash(rel))
+	if err != nil {
+		return skipToNil(fn(dir, nil, err))
+	}
+	readDir := func(dir string) ([]fs.DirEntry, error) {
+		rel, err := filepath.Rel(c.name, dir)
+		if err != nil {
+			return nil, err
+		}
+		name := filepath.ToSlash(rel)
+		entries, err := fs.ReadDir(fsys, name)
+		for i, d := range entries {
+			entries[i] = confinedEntry{DirEntry: d, root: c.root, name: path.Join(name, d.Name())}
+		}
+		return entries, err
+	}
+	root := confinedEntry{DirEntry: fs.FileInfoToDirEntry(info), root: c.root, name: filepath.ToSlash(rel)}
+	return walkLevels(dir, root, readDir, fn, listed)
+}
+
+// walkLevels walks the tree at root, whose entry is d, for walkBreadthFirst,
+// reading directories with readDir.
+func walkLevels(root string, d fs.DirEntry, readDir func(dir string) ([]fs.DirEntry, error), fn fs.WalkDirFunc, listed func(dir string)) error {
+	if listed == nil {
+		listed = func(string) {}
+	}
+	type queued struct {
+		path string
+		d    fs.DirEntry
+	}
+	var queue []queued
+
+	// visit passes an entry to fn, and queues it if it is a directory to read
+	visit := func(path string, d fs.DirEntry) error {
+		err := fn(path, d, nil)
+		if !d.IsDir() {
+			return err
+		}
+		if err == nil {
+			queue = append(queue, queued{path: path, d: d})
+			return nil
+		}
+		if errors.Is(err, filepath.SkipDir) {
+			listed(path)
+			return nil
+		}
+		return err
+	}
+
+	if err := visit(root, d); err != nil {
+		return skipToNil(err)
+	}
+	for len(queue) > 0 {
+		dir := queue[0]
+		queue[0] = queued{}
+		queue = queue[1:]
+
+		// As with filepath.WalkDir, fn sees a failed read a second time and
+		// may go on with the entries read before the error
+		entries, err := readDir(dir.path)
+		if err != nil {
+			if err := fn(dir.path, dir.d, err); err != nil {
+				if errors.Is(err, filepath.SkipDir) {
+					listed(dir.path)
+					continue
+				}
+				return skipToNil(err)
+			}
+		}
+		for _, e := range entries {
+			if err := visit(filepath.Join(dir.path, e.Name()), e); err != nil {
+				if errors.Is(err, filepath.SkipDir) {
+					break
+				}
+				return skipToNil(err)
+			}
+		}
+		listed(dir.path)
+	}
+	return nil
+}
+
+// skipToNil returns nil for SkipDir and SkipAll, which end a walk without
+// error when they reach its top, and err otherwise.
+func skipToNil(err error) error {
+	if errors.Is(err, filepath.SkipDir) || errors.Is(err, filepath.SkipAll) {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,190 @@
+package stride
+
+import (
+	"context"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/TFMV/stride/walk/walktest"
+)
+
+// createLevelFixture builds a tree three directories deep:
+//
+//	root/top.txt
+//	root/a/one.txt
+//	root/a/b/c/deep.txt
+//	root/x/y/two.txt
+//	root/x/skip/hidden.txt
+//	root/x/skip/more/z.txt
+func createLevelFixture(t testing.TB) string {
+	return walktest.Tree{
+		"top.txt":           walktest.File{Content: "top"},
+		"a/one.txt":         walktest.File{Content: "one"},
+		"a/b/c/deep.txt":    walktest.File{Content: "deep"},
+		"x/y/two.txt":       walktest.File{Content: "two"},
+		"x/skip/hidden.txt": walktest.File{Content: "hidden"},
+		"x/skip/more/z.txt": walktest.File{Content: "z"},
+	}.Build(t)
+}
+
+func TestWalkBreadthFirst(t *testing.T) {
+	root := createLevelFixture(t)
+
+	var visited, listed []string
+	err := walkBreadthFirst(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel := relSlashPath(root, path)
+		visited = append(visited, rel)
+		if rel == "x/skip" {
+			return filepath.SkipDir
+		}
+		return nil
+	}, func(dir string) {
+		listed = append(listed, relSlashPath(root, dir))
+	})
+	if err != nil {
+		t.Fatalf("Walk failed: %v", err)
+	}
+
+	want := []string{
+		".",
+		"a", "top.txt", "x",
+		"a/b", "a/one.txt", "x/skip", "x/y",
+		"a/b/c", "x/y/two.txt",
+		"a/b/c/deep.txt",
+	}
+	if !reflect.DeepEqual(visited, want) {
+		t.Errorf("Expected visits %v, got %v", want, visited)
+	}
+
+	// Each directory is listed once its entries were visited, and the
+	// skipped one as soon as it was visited
+	wantListed := []string{".", "a", "x/skip", "x", "a/b", "x/y", "a/b/c"}
+	if !reflect.DeepEqual(listed, wantListed) {
+		t.Errorf("Expected listed directories %v, got %v", wantListed, listed)
+	}
+}
+
+func TestTraversalOrder(t *testing.T) {
+	root := createLevelFixture(t)
+
+	walks := []struct {
+		name string
+		walk func(opts WalkOptions, visit func(path string) error) error
+	}{
+		{"WalkLimitWithOptions", func(opts WalkOptions, visit func(path string) error) error {
+			return WalkLimitWithOptions(context.Background(), root, func(path string, info os.FileInfo, err error) error {
+				if err != nil || !info.IsDir() {
+					return err
+				}
+				return visit(path)
+			}, opts)
+		}},
+		{"Confined", func(opts WalkOptions, visit func(path string) error) error {
+			opts.ConfineToRoot = true
+			return WalkLimitWithOptions(context.Background(), root, func(path string, info os.FileInfo, err error) error {
+				if err != nil || !info.IsDir() {
+					return err
+				}
+				return visit(path)
+			}, opts)
+		}},
+		{"WalkDir", func(opts WalkOptions, visit func(path string) error) error {
+			return WalkDir(root, func(ctx context.Context, path string, d fs.DirEntry) error {
+				if !d.IsDir() {
+					return nil
+				}
+				return visit(path)
+			}, opts)
+		}},
+	}
+	for _, w := range walks {
+		t.Run(w.name, func(t *testing.T) {
+			// Directory callbacks run on the walking goroutine, in the order
+			// the directories are enumerated
+			dirs := func(order TraversalOrder) []string {
+				var mu sync.Mutex
+				var dirs []string
+				err := w.walk(WalkOptions{NumWorkers: 2, TraversalOrder: order}, func(path string) error {
+					rel := relSlashPath(root, path)
+					mu.Lock()
+					dirs = append(dirs, rel)
+					mu.Unlock()
+					if rel == "x/skip" {
+						return filepath.SkipDir
+					}
+					return nil
+				})
+				if err != nil {
+					t.Fatalf("Walk failed: %v", err)
+				}
+				return dirs
+			}
+
+			got := dirs(BreadthFirst)
+			want := []string{".", "a", "x", "a/b", "x/skip", "x/y", "a/b/c"}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("Expected breadth-first directories %v, got %v", want, got)
+			}
+			for i := 1; i < len(got); i++ {
+				if strings.Count(got[i], "/") < strings.Count(got[i-1], "/") {
+					t.Errorf("Directory %s visited after the deeper %s", got[i], got[i-1])
+				}
+			}
+
+			got = dirs(DepthFirst)
+			want = []string{".", "a", "a/b", "a/b/c", "x", "x/skip", "x/y"}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("Expected depth-first directories %v, got %v", want, got)
+			}
+		})
+	}
+}
+
+func TestBreadthFirstSkipDir(t *testing.T) {
+	root := createLevelFixture(t)
+
+	var mu sync.Mutex
+	var files []string
+	stats, err := WalkLimitWithOptionsStats(context.Background(), root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			if info.Name() == "skip" {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		mu.Lock()
+		files = append(files, relSlashPath(root, path))
+		mu.Unlock()
+		return nil
+	}, WalkOptions{NumWorkers: 2, TraversalOrder: BreadthFirst})
+	if err != nil {
+		t.Fatalf("Walk failed: %v", err)
+	}
+
+	for _, file := range files {
+		if strings.HasPrefix(file, "x/skip/") {
+			t.Errorf("Expected nothing below x/skip, got %s", file)
+		}
+	}
+	if len(files) != 4 {
+		t.Errorf("Expected 4 files, got %v", files)
+	}
+	// The skipped x/skip counts as neither empty nor file-less
+	if stats.EmptyDirs != 0 {
+		t.Errorf("Expected 0 empty directories, got %d", stats.EmptyDirs)
+	}
+	if stats.FilelessDirs != 0 {
+		t.Errorf("Expected 0 file-less directories, got %d", stats.FilelessDirs)
+	}
+}
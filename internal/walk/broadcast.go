@@ -0,0 +1,149 @@
+package stride
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+)
+
+// SlowConsumerPolicy determines what a broadcaster does when a subscriber's
+// buffer is full.
+type SlowConsumerPolicy int
+
+const (
+	// SlowConsumerBlock waits for the subscriber to make room, which also
+	// holds back delivery to every other subscriber.
+	SlowConsumerBlock SlowConsumerPolicy = iota
+	// SlowConsumerDropOldest discards the oldest buffered result to make room
+	// and counts the drop.
+	SlowConsumerDropOldest
+)
+
+// WatchBroadcaster fans a single watch event stream out to several
+// independent subscribers. Pass Handler() to Watch and consume each
+// subscription's channel from its own goroutine.
+//
+// Every subscriber receives every result, errors included, in the order the
+// watcher produced them. Under SlowConsumerDropOldest a subscriber may miss
+// results, but those it does receive remain in order.
+type WatchBroadcaster struct {
+	mu   sync.RWMutex
+	subs map[*WatchSubscription]struct{}
+}
+
+// WatchSubscription is one consumer's view of a broadcast watch stream.
+type WatchSubscription struct {
+	ch          chan WatchResult
+	done        chan struct{}
+	policy      SlowConsumerPolicy
+	dropped     uint64
+	broadcaster *WatchBroadcaster
+	closeOnce   sync.Once
+}
+
+// NewWatchBroadcaster creates a broadcaster with no subscribers.
+func NewWatchBroadcaster() *WatchBroadcaster {
+	return &WatchBroadcaster{subs: make(map[*WatchSubscription]struct{})}
+}
+
+// Subscribe registers a new subscriber with its own buffer of bufferSize
+// results and the given slow-consumer policy.
+func (b *WatchBroadcaster) Subscribe(bufferSize int, policy SlowConsumerPolicy) *WatchSubscription {
+	if bufferSize < 1 {
+		bufferSize = 1
+	}
+	s := &WatchSubscription{
+		ch:          make(chan WatchResult, bufferSize),
+		done:        make(chan struct{}),
+		policy:      policy,
+		broadcaster: b,
+	}
+
+	b.mu.Lock()
+	b.subs[s] = struct{}{}
+	b.mu.Unlock()
+	return s
+}
+
+// Handler returns a WatchHandler that delivers each result to all current
+// subscribers. It never returns an error, so one subscriber's failures
+// cannot affect the others.
+func (b *WatchBroadcaster) Handler() WatchHandler {
+	return func(ctx context.Context, result WatchResult) error {
+		b.mu.RLock()
+		defer b.mu.RUnlock()
+		for s := range b.subs {
+			s.deliver(ctx, result)
+		}
+		return nil
+	}
+}
+
+// Close unsubscribes all subscribers, closing their channels.
+func (b *WatchBroadcaster) Close() {
+	b.mu.RLock()
+	subs := make([]*WatchSubscription, 0, len(b.subs))
+	for s := range b.subs {
+		subs = append(subs, s)
+	}
+	b.mu.RUnlock()
+
+	for _, s := range subs {
+		s.Unsubscribe()
+	}
+}
+
+// Results returns the channel of results for this subscriber. It is closed
+// by Unsubscribe or by closing the broadcaster.
+func (s *WatchSubscription) Results() <-chan WatchResult {
+	return s.ch
+}
+
+// Dropped returns the number of results discarded for this subscriber under
+// SlowConsumerDropOldest.
+func (s *WatchSubscription) Dropped() uint64 {
+	return atomic.LoadUint64(&s.dropped)
+}
+
+// Unsubscribe stops delivery to this subscriber and closes its channel.
+func (s *WatchSubscription) Unsubscribe() {
+	s.closeOnce.Do(func() {
+		// Release a delivery blocked on this subscriber before taking the
+		// write lock, then close the channel once no delivery is in flight
+		close(s.done)
+		b := s.broadcaster
+		b.mu.Lock()
+		delete(b.subs, s)
+		close(s.ch)
+		b.mu.Unlock()
+	})
+}
+
+// deliver sends result to the subscriber according to its policy.
+func (s *WatchSubscription) deliver(ctx context.Context, result WatchResult) {
+	if s.policy == SlowConsumerBlock {
+		select {
+		case s.ch <- result:
+		case <-s.done:
+		case <-ctx.Done():
+		}
+		return
+	}
+
+	for {
+		select {
+		case s.ch <- result:
+			return
+		case <-s.done:
+			return
+		default:
+		}
+
+		// Buffer is full: discard the oldest result and try again
+		select {
+		case <-s.ch:
+			atomic.AddUint64(&s.dropped, 1)
+		default:
+		}
+	}
+}
@@ -0,0 +1,134 @@
+package stride
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestWatchBroadcasterDelivery(t *testing.T) {
+	b := NewWatchBroadcaster()
+	fast := b.Subscribe(10, SlowConsumerBlock)
+	slow := b.Subscribe(2, SlowConsumerDropOldest)
+	handler := b.Handler()
+
+	for i := 0; i < 5; i++ {
+		msg := WatchMessage{Path: fmt.Sprintf("file%d", i), Event: EventCreate}
+		if err := handler(context.Background(), WatchResult{Message: msg}); err != nil {
+			t.Fatalf("Handler returned error: %v", err)
+		}
+	}
+	b.Close()
+
+	// The fast subscriber sees every result in order
+	var got []string
+	for result := range fast.Results() {
+		got = append(got, result.Message.Path)
+	}
+	if fmt.Sprint(got) != "[file0 file1 file2 file3 file4]" {
+		t.Errorf("Unexpected fast subscriber results: %v", got)
+	}
+
+	// The slow subscriber keeps only the newest results, still in order
+	got = nil
+	for result := range slow.Results() {
+		got = append(got, result.Message.Path)
+	}
+	if fmt.Sprint(got) != "[file3 file4]" {
+		t.Errorf("Unexpected slow subscriber results: %v", got)
+	}
+	if slow.Dropped() != 3 {
+		t.Errorf("Expected 3 dropped results, got %d", slow.Dropped())
+	}
+	if fast.Dropped() != 0 {
+		t.Errorf("Expected no dropped results for blocking subscriber, got %d", fast.Dropped())
+	}
+}
+
+func TestWatchBroadcasterUnsubscribeUnblocks(t *testing.T) {
+	b := NewWatchBroadcaster()
+	blocked := b.Subscribe(1, SlowConsumerBlock)
+	handler := b.Handler()
+
+	handler(context.Background(), WatchResult{Message: WatchMessage{Path: "first"}})
+
+	done := make(chan struct{})
+	go func() {
+		handler(context.Background(), WatchResult{Message: WatchMessage{Path: "second"}})
+		close(done)
+	}()
+
+	time.Sleep(50 * time.Millisecond)
+	blocked.Unsubscribe()
+
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("Delivery stayed blocked after Unsubscribe")
+	}
+}
+
+func TestWatchBroadcastSubscribers(t *testing.T) {
+	tmpDir := t.TempDir()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	b := NewWatchBroadcaster()
+	fast := b.Subscribe(100, SlowConsumerBlock)
+	slow := b.Subscribe(1, SlowConsumerDropOldest)
+
+	watchDone := make(chan struct{})
+	go func() {
+		defer close(watchDone)
+		opts := WatchOptions{Events: []WatchEvent{EventCreate}}
+		if err := Watch(ctx, tmpDir, opts, b.Handler()); err != nil {
+			t.Errorf("Watch error: %v", err)
+		}
+	}()
+
+	// Give the watcher a moment to initialize
+	time.Sleep(200 * time.Millisecond)
+
+	const numFiles = 5
+	var last string
+	for i := 0; i < numFiles; i++ {
+		last = filepath.Join(tmpDir, fmt.Sprintf("file%d.txt", i))
+		if err := os.WriteFile(last, []byte("data"), 0644); err != nil {
+			t.Fatalf("Failed to create test file: %v", err)
+		}
+	}
+
+	// The fast subscriber receives a create event for every file
+	received := make(map[string]bool)
+	for len(received) < numFiles {
+		select {
+		case result := <-fast.Results():
+			if result.Error == nil && result.Message.Event == EventCreate {
+				received[result.Message.Path] = true
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatalf("Fast subscriber received only %d of %d create events", len(received), numFiles)
+		}
+	}
+
+	// The slow subscriber only starts reading now; its single-slot buffer
+	// holds the newest create event and the rest were dropped
+	select {
+	case result := <-slow.Results():
+		if result.Message.Event != EventCreate || result.Message.Path != last {
+			t.Errorf("Expected create event for %s, got %s for %s", last, result.Message.Event, result.Message.Path)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Slow subscriber received no event")
+	}
+	if slow.Dropped() == 0 {
+		t.Error("Expected slow subscriber to drop events")
+	}
+
+	cancel()
+	<-watchDone
+	b.Close()
+}
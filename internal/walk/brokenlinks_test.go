@@ -0,0 +1,137 @@
+package stride
+
+import (
+	"context"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"sync"
+	"testing"
+
+	"github.com/TFMV/stride/walk/walktest"
+)
+
+// brokenLinkFixture builds a tree with a valid link, a dangling link and a
+// chain of links whose second hop is missing.
+func brokenLinkFixture(t *testing.T) string {
+	t.Helper()
+	root := t.TempDir()
+	tree := walktest.Tree{
+		"data.txt":   walktest.File{Content: "data"},
+		"valid":      walktest.Symlink{Target: "data.txt"},
+		"good-chain": walktest.Symlink{Target: "valid"},
+		"dangling":   walktest.Symlink{Target: "missing.txt"},
+		"chain":      walktest.Symlink{Target: "hop1"},
+		"hop1":       walktest.Symlink{Target: "hop2"},
+	}
+	if _, err := tree.Create(root); err != nil {
+		t.Skipf("Symlinks not supported: %v", err)
+	}
+	return root
+}
+
+func TestFindBrokenSymlinks(t *testing.T) {
+	root := brokenLinkFixture(t)
+	tmpl, err := ParseTemplate("{base} -> {target}")
+	if err != nil {
+		t.Fatalf("Failed to parse template: %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		opts     FindOptions
+		expected []string
+	}{
+		{
+			name:     "only broken",
+			opts:     FindOptions{OnlyBrokenSymlinks: true},
+			expected: []string{"chain -> hop1", "dangling -> missing.txt", "hop1 -> hop2"},
+		},
+		{
+			name:     "broken links are not followed",
+			opts:     FindOptions{OnlyBrokenSymlinks: true, FollowSymlinks: true},
+			expected: []string{"chain -> hop1", "dangling -> missing.txt", "hop1 -> hop2"},
+		},
+		{
+			name:     "files have no target",
+			opts:     FindOptions{NamePattern: "*.txt"},
+			expected: []string{"data.txt -> {target}"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var mu sync.Mutex
+			var lines []string
+			err := Find(context.Background(), root, tt.opts, func(ctx context.Context, result FindResult) error {
+				if result.Error != nil {
+					t.Errorf("Unexpected error: %v", result.Error)
+					return nil
+				}
+				mu.Lock()
+				lines = append(lines, tmpl.Render(result.Message))
+				mu.Unlock()
+				return nil
+			})
+			if err != nil {
+				t.Fatalf("Failed to find: %v", err)
+			}
+			sort.Strings(lines)
+			if !reflect.DeepEqual(lines, tt.expected) {
+				t.Errorf("Expected %v, got %v", tt.expected, lines)
+			}
+		})
+	}
+}
+
+func TestFilterBrokenSymlinks(t *testing.T) {
+	root := brokenLinkFixture(t)
+	filter := FilterOptions{OnlyBrokenSymlinks: true}
+	expected := []string{"chain", "dangling", "hop1"}
+
+	var mu sync.Mutex
+	var walked []string
+	err := WalkLimitWithOptions(context.Background(), root, func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return err
+		}
+		mu.Lock()
+		walked = append(walked, relSlashPath(root, path))
+		mu.Unlock()
+		return nil
+	}, WalkOptions{Filter: filter, SymlinkHandling: SymlinkReport})
+	if err != nil {
+		t.Fatalf("Failed to walk: %v", err)
+	}
+	sort.Strings(walked)
+	if !reflect.DeepEqual(walked, expected) {
+		t.Errorf("Expected %v from WalkLimitWithOptions, got %v", expected, walked)
+	}
+
+	var listed []string
+	err = WalkDir(root, func(ctx context.Context, path string, d fs.DirEntry) error {
+		if !d.IsDir() {
+			mu.Lock()
+			listed = append(listed, relSlashPath(root, path))
+			mu.Unlock()
+		}
+		return nil
+	}, WalkOptions{Filter: filter, SymlinkHandling: SymlinkReport})
+	if err != nil {
+		t.Fatalf("Failed to walk: %v", err)
+	}
+	sort.Strings(listed)
+	if !reflect.DeepEqual(listed, expected) {
+		t.Errorf("Expected %v from WalkDir, got %v", expected, listed)
+	}
+
+	// The criterion is named when explaining
+	info, err := os.Lstat(filepath.Join(root, "valid"))
+	if err != nil {
+		t.Fatalf("Failed to stat link: %v", err)
+	}
+	if included, failed := EvaluateFilter(filepath.Join(root, "valid"), info, filter); included || !reflect.DeepEqual(failed, []string{"broken_symlinks"}) {
+		t.Errorf("Expected valid to fail broken_symlinks, got %v %v", included, failed)
+	}
+}
@@ -0,0 +1,134 @@
+package stride
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"runtime/metrics"
+	"sync/atomic"
+	"time"
+)
+
+// ErrBudgetExceeded is reported, wrapped in a *BudgetError, when a walk stops
+// early because WalkOptions.MaxDuration or WalkOptions.MaxFiles ran out.
+var ErrBudgetExceeded = errors.New("stride: walk budget exceeded")
+
+// Budget identifies a walk budget.
+type Budget string
+
+const (
+	BudgetDuration Budget = "duration" // WalkOptions.MaxDuration
+	BudgetFiles    Budget = "files"    // WalkOptions.MaxFiles
+)
+
+// BudgetError reports which budget stopped a walk. The statistics returned
+// with it describe the entries processed before the walk stopped.
+type BudgetError struct {
+	Budget   Budget
+	Duration time.Duration // The MaxDuration that elapsed, for BudgetDuration
+	Files    int64         // The MaxFiles that were processed, for BudgetFiles
+}
+
+func (e *BudgetError) Error() string {
+	if e.Budget == BudgetFiles {
+		return fmt.Sprintf("%v: processed %d files", ErrBudgetExceeded, e.Files)
+	}
+	return fmt.Sprintf("%v: ran for %s", ErrBudgetExceeded, e.Duration)
+}
+
+func (e *BudgetError) Unwrap() error {
+	return ErrBudgetExceeded
+}
+
+// memoryCheckInterval is how often the heap is measured against
+// WalkOptions.MemoryLimits.HardLimit.
+const memoryCheckInterval = 50 * time.Millisecond
+
+// walkBudget enforces the budgets of a single walk by canceling its context
+// with a *BudgetError as the cause. It also stops the walk, with another
+// cause, when the heap outgrows the hard memory limit or the callback
+// returns filepath.SkipAll.
+type walkBudget struct {
+	ctx      context.Context
+	cancel   context.CancelCauseFunc
+	timer    *time.Timer
+	maxFiles int64
+	files    int64
+}
+
+// newWalkBudget derives the walk context from ctx. The duration budget starts
+// immediately; call stop once the walk is done.
+func newWalkBudget(ctx context.Context, opts WalkOptions) *walkBudget {
+	b := &walkBudget{maxFiles: opts.MaxFiles}
+	b.ctx, b.cancel = context.WithCancelCause(ctx)
+	if opts.MaxDuration > 0 {
+		b.timer = time.AfterFunc(opts.MaxDuration, func() {
+			b.cancel(&BudgetError{Budget: BudgetDuration, Duration: opts.MaxDuration})
+		})
+	}
+	if opts.MemoryLimits.HardLimit > 0 {
+		go b.watchMemory(uint64(opts.MemoryLimits.HardLimit))
+	}
+	return b
+}
+
+// watchMemory stops the walk once the live heap exceeds limit bytes.
+func (b *walkBudget) watchMemory(limit uint64) {
+	ticker := time.NewTicker(memoryCheckInterval)
+	defer ticker.Stop()
+	sample := []metrics.Sample{{Name: "/memory/classes/heap/objects:bytes"}}
+	for {
+		select {
+		case <-ticker.C:
+			metrics.Read(sample)
+			if heap := sample[0].Value.Uint64(); heap > limit {
+				b.cancel(fmt.Errorf("%w: heap of %d bytes over %d", ErrMemoryLimit, heap, limit))
+				return
+			}
+		case <-b.ctx.Done():
+			return
+		}
+	}
+}
+
+// exceeded reports whether the walk was stopped, by a budget or otherwise.
+// Entries are no longer passed to the callback once it returns true.
+func (b *walkBudget) exceeded() bool {
+	return b.ctx.Err() != nil
+}
+
+// takeFile claims one file from the file budget and reports whether the
+// file may be processed. Claiming the file past the last stops the walk.
+func (b *walkBudget) takeFile() bool {
+	if b.maxFiles <= 0 {
+		return true
+	}
+	if atomic.AddInt64(&b.files, 1) <= b.maxFiles {
+		return true
+	}
+	b.cancel(&BudgetError{Budget: BudgetFiles, Files: b.maxFiles})
+	return false
+}
+
+// skipAll stops the walk at the callback's request.
+func (b *walkBudget) skipAll() {
+	b.cancel(ErrSkipAll)
+}
+
+// stop releases the budget and returns what stopped the walk: a
+// *BudgetError, ErrMemoryLimit or ErrSkipAll, or nil if none of them did.
+func (b *walkBudget) stop() error {
+	if b.timer != nil {
+		b.timer.Stop()
+	}
+	var budgetErr *BudgetError
+	cause := context.Cause(b.ctx)
+	b.cancel(nil)
+	switch {
+	case errors.As(cause, &budgetErr):
+		return budgetErr
+	case errors.Is(cause, ErrMemoryLimit), errors.Is(cause, ErrSkipAll):
+		return cause
+	}
+	return nil
+}
@@ -0,0 +1,98 @@
+package stride
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// createBudgetFixture creates n small files spread over a few directories.
+func createBudgetFixture(t *testing.T, n int) string {
+	root := t.TempDir()
+	for i := 0; i < n; i++ {
+		dir := filepath.Join(root, fmt.Sprintf("dir%d", i%5))
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatalf("Failed to create directory: %v", err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, fmt.Sprintf("file%d.txt", i)), []byte("x"), 0644); err != nil {
+			t.Fatalf("Failed to create file: %v", err)
+		}
+	}
+	return root
+}
+
+func TestWalkMaxFiles(t *testing.T) {
+	root := createBudgetFixture(t, 200)
+	const limit, workers = 50, 4
+
+	var calls int64
+	stats, err := WalkLimitWithOptionsStats(context.Background(), root, func(path string, info os.FileInfo, err error) error {
+		if err == nil && !info.IsDir() {
+			atomic.AddInt64(&calls, 1)
+		}
+		return nil
+	}, WalkOptions{NumWorkers: workers, MaxFiles: limit})
+
+	var budgetErr *BudgetError
+	if !errors.Is(err, ErrBudgetExceeded) || !errors.As(err, &budgetErr) {
+		t.Fatalf("Expected a budget error, got %v", err)
+	}
+	if budgetErr.Budget != BudgetFiles || budgetErr.Files != limit {
+		t.Errorf("Unexpected budget error: %+v", budgetErr)
+	}
+	if calls < limit || calls > limit+workers {
+		t.Errorf("Callback saw %d files, want between %d and %d", calls, limit, limit+workers)
+	}
+	if stats.FilesProcessed != calls {
+		t.Errorf("FilesProcessed = %d, callback saw %d files", stats.FilesProcessed, calls)
+	}
+
+	// A budget that is not reached does not change the result
+	_, err = WalkLimitWithOptionsStats(context.Background(), root, func(path string, info os.FileInfo, err error) error {
+		return nil
+	}, WalkOptions{NumWorkers: workers, MaxFiles: 200})
+	if err != nil {
+		t.Errorf("Expected no error within budget, got %v", err)
+	}
+}
+
+func TestWalkMaxDuration(t *testing.T) {
+	root := createBudgetFixture(t, 200)
+	const budget = 100 * time.Millisecond
+
+	// Walking everything would take about two seconds
+	start := time.Now()
+	stats, err := WalkLimitWithOptionsStats(context.Background(), root, func(path string, info os.FileInfo, err error) error {
+		if err == nil && !info.IsDir() {
+			time.Sleep(20 * time.Millisecond)
+		}
+		return nil
+	}, WalkOptions{NumWorkers: 2, MaxDuration: budget})
+	elapsed := time.Since(start)
+
+	var budgetErr *BudgetError
+	if !errors.As(err, &budgetErr) || budgetErr.Budget != BudgetDuration {
+		t.Fatalf("Expected a duration budget error, got %v", err)
+	}
+	if elapsed > budget+500*time.Millisecond {
+		t.Errorf("Walk stopped after %s, budget was %s", elapsed, budget)
+	}
+	if stats.FilesProcessed == 0 || stats.FilesProcessed >= 200 {
+		t.Errorf("Expected partial stats, got %d files", stats.FilesProcessed)
+	}
+
+	// Canceling the caller's context is not a budget error
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = WalkLimitWithOptionsStats(ctx, root, func(path string, info os.FileInfo, err error) error {
+		return nil
+	}, WalkOptions{NumWorkers: 2, MaxDuration: time.Hour})
+	if errors.Is(err, ErrBudgetExceeded) {
+		t.Errorf("Cancellation reported as a budget error: %v", err)
+	}
+}
@@ -0,0 +1,408 @@
+package stride
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// DefaultCleanMaxDelete is the number of matches above which CleanFiles
+// refuses to act when CleanOptions.MaxDelete is zero.
+const DefaultCleanMaxDelete = 1000
+
+// DefaultCleanJournalName is the name of the journal CleanFiles keeps in
+// CleanOptions.QuarantineDir when CleanOptions.Journal is empty.
+const DefaultCleanJournalName = ".stride-clean.jsonl"
+
+// Actions recorded by CleanFiles and UndoClean
+const (
+	CleanActionDryRun     = "dry-run"    // The file would be removed
+	CleanActionDelete     = "delete"     // The file was deleted
+	CleanActionQuarantine = "quarantine" // The file was moved to CleanAction.Target
+	CleanActionRestore    = "restore"    // The file was moved back from CleanAction.Target
+)
+
+// ErrCleanLimit is returned, wrapped, by CleanFiles when the filter matches
+// more files than CleanOptions.MaxDelete allows. Nothing is changed.
+var ErrCleanLimit = errors.New("stride: too many files to clean")
+
+// ErrCleanJournalRequired is returned by CleanFiles when it is to delete
+// files without CleanOptions.Journal to record them in. Nothing is changed.
+var ErrCleanJournalRequired = errors.New("stride: deleting files requires a journal")
+
+// CleanOptions controls CleanFiles.
+type CleanOptions struct {
+	// Whether to only report the files that would be removed
+	DryRun bool
+
+	// Directory files are moved to, keeping their path relative to the
+	// root, instead of being deleted. It must not be inside the root
+	QuarantineDir string
+
+	// File every action is appended to as a line of JSON, for auditing
+	// and for UndoClean (default DefaultCleanJournalName in QuarantineDir
+	// when quarantining). Deleting files requires one. Dry runs write none
+	Journal string
+
+	// Most files that may be removed: CleanFiles fails with ErrCleanLimit
+	// before acting if the filter matches more (default
+	// DefaultCleanMaxDelete, negative for no limit)
+	MaxDelete int
+
+	// Number of concurrent workers for the walk (0 for the default)
+	Workers int
+
+	// Called for each action once it is done, or has failed
+	OnAction func(CleanAction)
+}
+
+// CleanAction is an action of CleanFiles or UndoClean, as recorded in the
+// journal.
+type CleanAction struct {
+	Path    string    `json:"path"`             // Path of the file in the tree
+	Size    int64     `json:"size"`             // Size of the file in bytes
+	ModTime time.Time `json:"mtime"`            // Modification time of the file
+	Action  string    `json:"action"`           // One of the CleanAction* constants
+	Target  string    `json:"target,omitempty"` // Path of the file in quarantine
+	Time    time.Time `json:"timestamp"`        // When the action was taken
+	Error   string    `json:"error,omitempty"`  // Why the action failed, if it did
+
+	// Pending marks the line written, and synced, before the action is
+	// taken; the line recording its outcome follows. A pending line with
+	// no outcome after it is an action interrupted by a crash.
+	Pending bool `json:"pending,omitempty"`
+}
+
+// CleanReport summarizes CleanFiles or UndoClean.
+type CleanReport struct {
+	DryRun     bool
+	Matched    int           // Files selected by the filter, or recorded in the journal for UndoClean
+	Bytes      int64         // Bytes in those files
+	Done       int           // Files removed, quarantined or restored
+	Failed     []CleanAction // Actions that failed, with their Error
+	WalkErrors int64         // Entries the walk could not read
+}
+
+// CleanFiles removes the files beneath root selected by filter, or with
+// opts.QuarantineDir, moves them there. Directories are never removed.
+//
+// Every match is found before anything is changed, so that the
+// opts.MaxDelete guard can refuse the whole run. A file that cannot be
+// removed is recorded and the others are still processed; the report lists
+// the failures. Files already removed no longer match, so an interrupted
+// run is resumed by running it again. Each action is recorded in the
+// journal before it is taken, and its outcome after.
+func CleanFiles(ctx context.Context, root string, filter FilterOptions, opts CleanOptions) (CleanReport, error) {
+	report := CleanReport{DryRun: opts.DryRun}
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	root, err := normalizeRoot(root)
+	if err != nil {
+		return report, err
+	}
+	quarantine := opts.QuarantineDir
+	if quarantine != "" {
+		if quarantine, err = filepath.Abs(quarantine); err != nil {
+			return report, err
+		}
+		if absRoot, err := filepath.Abs(root); err == nil && withinRoot(absRoot, quarantine) {
+			return report, fmt.Errorf("quarantine directory %s is inside %s", quarantine, root)
+		}
+	} else if !opts.DryRun && opts.Journal == "" {
+		return report, ErrCleanJournalRequired
+	}
+
+	// Find every match first
+	var mu sync.Mutex
+	var matches []CleanAction
+	walkOpts := WalkOptions{
+		Filter:          filter,
+		NumWorkers:      opts.Workers,
+		SymlinkHandling: SymlinkReport,
+	}
+	stats, err := WalkLimitWithOptionsStats(ctx, root, func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return nil
+		}
+		mu.Lock()
+		matches = append(matches, CleanAction{Path: path, Size: info.Size(), ModTime: info.ModTime()})
+		mu.Unlock()
+		return nil
+	}, walkOpts)
+	report.WalkErrors = stats.ErrorCount
+	if err != nil {
+		return report, err
+	}
+	sort.Slice(matches, func(i, j int) bool { return matches[i].Path < matches[j].Path })
+	report.Matched = len(matches)
+	for _, m := range matches {
+		report.Bytes += m.Size
+	}
+
+	maxDelete := opts.MaxDelete
+	if maxDelete == 0 {
+		maxDelete = DefaultCleanMaxDelete
+	}
+	if maxDelete > 0 && len(matches) > maxDelete {
+		return report, fmt.Errorf("%w: %d files match, more than the limit of %d", ErrCleanLimit, len(matches), maxDelete)
+	}
+
+	journal, err := openCleanJournal(opts, quarantine)
+	if err != nil {
+		return report, err
+	}
+	if journal != nil {
+		defer journal.Close()
+	}
+
+	for _, action := range matches {
+		if err := ctx.Err(); err != nil {
+			return report, err
+		}
+		var act func() error
+		switch {
+		case opts.DryRun:
+			action.Action = CleanActionDryRun
+		case quarantine != "":
+			action.Action = CleanActionQuarantine
+			rel, err := filepath.Rel(root, action.Path)
+			if err != nil {
+				rel = filepath.Base(action.Path)
+			}
+			action.Target = filepath.Join(quarantine, rel)
+			act = func() error { return moveFile(action.Path, action.Target) }
+		default:
+			action.Action = CleanActionDelete
+			act = func() error { return os.Remove(action.Path) }
+		}
+		if err := report.take(action, act, journal, opts.OnAction); err != nil {
+			return report, err
+		}
+	}
+	return report, nil
+}
+
+// UndoClean moves the files quarantined by CleanFiles, as recorded in the
+// journal at path, back to where they were, most recent first. Files
+// already restored, or whose original path is taken again, are left in
+// quarantine; the latter are reported as failures. The restores are
+// appended to the journal, so that an interrupted undo can be run again.
+// Actions a crash interrupted are undone if their file made it to
+// quarantine.
+func UndoClean(ctx context.Context, path string, onAction func(CleanAction)) (CleanReport, error) {
+	var report CleanReport
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	actions, err := readCleanJournal(path)
+	if err != nil {
+		return report, err
+	}
+
+	// Replay the journal to find what is still in quarantine, keeping
+	// moves whose outcome is missing in case the file was moved
+	pending := make(map[string]CleanAction)
+	var order []string
+	for _, a := range actions {
+		switch a.Action {
+		case CleanActionQuarantine:
+			prev, ok := pending[a.Target]
+			switch {
+			case ok && !prev.Pending:
+				// The file is in quarantine, so later moves there fail
+			case a.Error != "":
+				delete(pending, a.Target)
+			default:
+				if !ok {
+					order = append(order, a.Target)
+				}
+				pending[a.Target] = a
+			}
+		case CleanActionRestore:
+			if a.Error == "" && !a.Pending {
+				delete(pending, a.Target)
+			}
+		}
+	}
+
+	journal, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0)
+	if err != nil {
+		return report, err
+	}
+	defer journal.Close()
+
+	for i := len(order) - 1; i >= 0; i-- {
+		action, ok := pending[order[i]]
+		if !ok {
+			continue
+		}
+		delete(pending, order[i])
+		if err := ctx.Err(); err != nil {
+			return report, err
+		}
+
+		// A file that is not in quarantine was never moved there, or was
+		// restored by an undo that was interrupted before recording it
+		if _, err := os.Lstat(action.Target); err != nil {
+			continue
+		}
+		report.Matched++
+		report.Bytes += action.Size
+		action.Action, action.Error, action.Pending = CleanActionRestore, "", false
+		if err := report.take(action, func() error {
+			if _, err := os.Lstat(action.Path); err == nil {
+				return fmt.Errorf("%s already exists", action.Path)
+			}
+			return moveFile(action.Target, action.Path)
+		}, journal, onAction); err != nil {
+			return report, err
+		}
+	}
+	return report, nil
+}
+
+// setError records err, if any, as the reason the action failed.
+func (a *CleanAction) setError(err error) {
+	if err != nil {
+		a.Error = err.Error()
+	}
+}
+
+// take records in journal, and syncs, that action is about to be taken,
+// calls act, unless it is nil as for dry runs, and records the outcome.
+// The action is then counted in the report and passed to onAction. Only
+// failures to write the journal are returned, since acting without a
+// record is not safe.
+func (r *CleanReport) take(action CleanAction, act func() error, journal *os.File, onAction func(CleanAction)) error {
+	action.Time = time.Now()
+	if act != nil {
+		intent := action
+		intent.Pending = true
+		if err := writeJSONLine(journal, intent); err != nil {
+			return fmt.Errorf("error writing clean journal: %w", err)
+		}
+		if err := journal.Sync(); err != nil {
+			return fmt.Errorf("error writing clean journal: %w", err)
+		}
+		action.setError(act())
+		action.Time = time.Now()
+		if err := writeJSONLine(journal, action); err != nil {
+			return fmt.Errorf("error writing clean journal: %w", err)
+		}
+	}
+	if action.Error != "" {
+		r.Failed = append(r.Failed, action)
+	} else if action.Action != CleanActionDryRun {
+		r.Done++
+	}
+	if onAction != nil {
+		onAction(action)
+	}
+	return nil
+}
+
+// openCleanJournal opens the journal of a clean for appending, or returns
+// nil for a dry run, which keeps none.
+func openCleanJournal(opts CleanOptions, quarantine string) (*os.File, error) {
+	path := opts.Journal
+	if path == "" && quarantine != "" {
+		path = filepath.Join(quarantine, DefaultCleanJournalName)
+	}
+	if path == "" || opts.DryRun {
+		return nil, nil
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, err
+	}
+	return os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+}
+
+// readCleanJournal reads the actions recorded in the journal at path.
+func readCleanJournal(path string) ([]CleanAction, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var actions []CleanAction
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), maxLineLength)
+	for line := 1; scanner.Scan(); line++ {
+		if strings.TrimSpace(scanner.Text()) == "" {
+			continue
+		}
+		var a CleanAction
+		if err := json.Unmarshal(scanner.Bytes(), &a); err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", path, line, err)
+		}
+		actions = append(actions, a)
+	}
+	return actions, scanner.Err()
+}
+
+// moveFile moves the file at src to dst, creating the directories of dst.
+// Across filesystems, the file is copied with its mode and times, then
+// removed.
+func moveFile(src, dst string) error {
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return err
+	}
+	if _, err := os.Lstat(dst); err == nil {
+		return fmt.Errorf("%s already exists", dst)
+	}
+	err := os.Rename(src, dst)
+	if !errors.Is(err, syscall.EXDEV) {
+		return err
+	}
+
+	info, err := os.Lstat(src)
+	if err != nil {
+		return err
+	}
+	if !info.Mode().IsRegular() {
+		return fmt.Errorf("cannot move %s across filesystems: not a regular file", src)
+	}
+	if err := copyFileContents(src, dst, info); err != nil {
+		os.Remove(dst)
+		return err
+	}
+	return os.Remove(src)
+}
+
+// copyFileContents copies the regular file src, described by info, to the
+// new file dst.
+func copyFileContents(src, dst string, info os.FileInfo) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_EXCL, info.Mode().Perm())
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	if err := out.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(dst, info.Mode().Perm()); err != nil {
+		return err
+	}
+	return os.Chtimes(dst, info.ModTime(), info.ModTime())
+}
@@ -0,0 +1,233 @@
+package stride
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/TFMV/stride/walk/walktest"
+)
+
+// snapshotTree returns the content, mode and modification time of every
+// file beneath root, by slash-separated relative path.
+func snapshotTree(t *testing.T, root string) map[string]string {
+	t.Helper()
+	files := make(map[string]string)
+	err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return err
+		}
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		files[relSlashPath(root, path)] = info.Mode().String() + " " + info.ModTime().UTC().String() + " " + string(content)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Failed to read tree: %v", err)
+	}
+	return files
+}
+
+func cleanFixture(t *testing.T) string {
+	t.Helper()
+	old := time.Now().Add(-48 * time.Hour).Truncate(time.Second)
+	return walktest.Tree{
+		"keep.txt":        walktest.File{Content: "keep"},
+		"a.log":           walktest.File{Content: "first log", ModTime: old},
+		"sub/b.log":       walktest.File{Content: "second log", Mode: 0600, ModTime: old},
+		"sub/deep/c.log":  walktest.File{Content: "third log", ModTime: old},
+		"sub/deep/d.json": walktest.File{Content: "{}"},
+	}.Build(t)
+}
+
+func TestCleanDryRun(t *testing.T) {
+	root := cleanFixture(t)
+	before := snapshotTree(t, root)
+
+	var actions []string
+	report, err := CleanFiles(context.Background(), root, FilterOptions{Pattern: "*.log"}, CleanOptions{
+		DryRun:   true,
+		Journal:  filepath.Join(t.TempDir(), "journal.jsonl"),
+		OnAction: func(a CleanAction) { actions = append(actions, a.Action+" "+relSlashPath(root, a.Path)) },
+	})
+	if err != nil {
+		t.Fatalf("Failed to clean: %v", err)
+	}
+	if report.Matched != 3 || report.Done != 0 || report.Bytes != int64(len("first log")+len("second log")+len("third log")) {
+		t.Errorf("Expected 3 matches and nothing done, got %+v", report)
+	}
+	expected := []string{"dry-run a.log", "dry-run sub/b.log", "dry-run sub/deep/c.log"}
+	if !reflect.DeepEqual(actions, expected) {
+		t.Errorf("Expected actions %v, got %v", expected, actions)
+	}
+	if after := snapshotTree(t, root); !reflect.DeepEqual(after, before) {
+		t.Errorf("Expected no changes, got %v", after)
+	}
+}
+
+func TestCleanQuarantineUndo(t *testing.T) {
+	root := cleanFixture(t)
+	before := snapshotTree(t, root)
+	quarantine := filepath.Join(t.TempDir(), "quarantine")
+
+	report, err := CleanFiles(context.Background(), root, FilterOptions{Pattern: "*.log"}, CleanOptions{QuarantineDir: quarantine})
+	if err != nil {
+		t.Fatalf("Failed to clean: %v", err)
+	}
+	if report.Done != 3 || len(report.Failed) != 0 {
+		t.Errorf("Expected 3 files quarantined, got %+v", report)
+	}
+
+	// The files keep their place relative to the root
+	var moved []string
+	for rel := range snapshotTree(t, quarantine) {
+		moved = append(moved, rel)
+	}
+	sort.Strings(moved)
+	expected := []string{DefaultCleanJournalName, "a.log", "sub/b.log", "sub/deep/c.log"}
+	if !reflect.DeepEqual(moved, expected) {
+		t.Errorf("Expected quarantine to hold %v, got %v", expected, moved)
+	}
+	if _, err := os.Stat(filepath.Join(root, "sub", "b.log")); !os.IsNotExist(err) {
+		t.Errorf("Expected sub/b.log to be gone, got %v", err)
+	}
+
+	// Undoing restores the tree exactly, and undoing again does nothing
+	journal := filepath.Join(quarantine, DefaultCleanJournalName)
+	report, err = UndoClean(context.Background(), journal, nil)
+	if err != nil {
+		t.Fatalf("Failed to undo: %v", err)
+	}
+	if report.Done != 3 || len(report.Failed) != 0 {
+		t.Errorf("Expected 3 files restored, got %+v", report)
+	}
+	if after := snapshotTree(t, root); !reflect.DeepEqual(after, before) {
+		t.Errorf("Expected the tree to be restored to %v, got %v", before, after)
+	}
+	report, err = UndoClean(context.Background(), journal, nil)
+	if err != nil || report.Matched != 0 {
+		t.Errorf("Expected nothing left to undo, got %+v, %v", report, err)
+	}
+
+	// A quarantine inside the root would be walked
+	_, err = CleanFiles(context.Background(), root, FilterOptions{Pattern: "*.log"}, CleanOptions{QuarantineDir: filepath.Join(root, "q")})
+	if err == nil {
+		t.Error("Expected an error for a quarantine inside the root")
+	}
+}
+
+func TestCleanMaxDelete(t *testing.T) {
+	root := cleanFixture(t)
+	before := snapshotTree(t, root)
+	journal := filepath.Join(t.TempDir(), "journal.jsonl")
+
+	report, err := CleanFiles(context.Background(), root, FilterOptions{Pattern: "*.log"}, CleanOptions{MaxDelete: 2, Journal: journal})
+	if !errors.Is(err, ErrCleanLimit) {
+		t.Fatalf("Expected ErrCleanLimit, got %v", err)
+	}
+	if report.Matched != 3 || report.Done != 0 {
+		t.Errorf("Expected 3 matches and nothing done, got %+v", report)
+	}
+	if after := snapshotTree(t, root); !reflect.DeepEqual(after, before) {
+		t.Errorf("Expected no changes, got %v", after)
+	}
+	if _, err := os.Stat(journal); !os.IsNotExist(err) {
+		t.Errorf("Expected no journal, got %v", err)
+	}
+
+	// Raising the limit lets the files be deleted, each recorded
+	report, err = CleanFiles(context.Background(), root, FilterOptions{Pattern: "*.log"}, CleanOptions{MaxDelete: 3, Journal: journal})
+	if err != nil {
+		t.Fatalf("Failed to clean: %v", err)
+	}
+	if report.Done != 3 {
+		t.Errorf("Expected 3 files deleted, got %+v", report)
+	}
+	actions, err := readCleanJournal(journal)
+	if err != nil {
+		t.Fatalf("Failed to read journal: %v", err)
+	}
+	// Each delete is recorded as pending before it is taken, then with its
+	// outcome
+	if len(actions) != 6 {
+		t.Fatalf("Expected 6 lines in the journal, got %+v", actions)
+	}
+	for i, a := range actions {
+		if a.Action != CleanActionDelete || a.Pending != (i%2 == 0) || a.Path != actions[i/2*2].Path {
+			t.Errorf("Expected line %d to record the delete of %s, pending %v, got %+v", i, actions[i/2*2].Path, i%2 == 0, a)
+		}
+	}
+	if actions[1].Size != int64(len("first log")) {
+		t.Errorf("Expected the size of a.log recorded, got %d", actions[1].Size)
+	}
+	if got := snapshotTree(t, root); len(got) != 2 {
+		t.Errorf("Expected keep.txt and sub/deep/d.json left, got %v", got)
+	}
+}
+
+func TestCleanDeleteRequiresJournal(t *testing.T) {
+	root := cleanFixture(t)
+	before := snapshotTree(t, root)
+
+	_, err := CleanFiles(context.Background(), root, FilterOptions{Pattern: "*.log"}, CleanOptions{})
+	if !errors.Is(err, ErrCleanJournalRequired) {
+		t.Fatalf("Expected ErrCleanJournalRequired, got %v", err)
+	}
+	if after := snapshotTree(t, root); !reflect.DeepEqual(after, before) {
+		t.Errorf("Expected no changes, got %v", after)
+	}
+
+	// Dry runs record nothing, so they need none
+	if _, err := CleanFiles(context.Background(), root, FilterOptions{Pattern: "*.log"}, CleanOptions{DryRun: true}); err != nil {
+		t.Errorf("Failed to clean: %v", err)
+	}
+}
+
+func TestUndoCleanInterrupted(t *testing.T) {
+	root := cleanFixture(t)
+	quarantine := filepath.Join(t.TempDir(), "quarantine")
+	journal := filepath.Join(quarantine, DefaultCleanJournalName)
+
+	// A crash after a.log was moved but before the outcome was recorded,
+	// and one before sub/b.log was moved at all
+	lines := []CleanAction{
+		{Path: filepath.Join(root, "a.log"), Target: filepath.Join(quarantine, "a.log"), Action: CleanActionQuarantine, Pending: true},
+		{Path: filepath.Join(root, "sub", "b.log"), Target: filepath.Join(quarantine, "sub", "b.log"), Action: CleanActionQuarantine, Pending: true},
+	}
+	if err := moveFile(lines[0].Path, lines[0].Target); err != nil {
+		t.Fatalf("Failed to move a.log: %v", err)
+	}
+	f, err := os.Create(journal)
+	if err != nil {
+		t.Fatalf("Failed to create journal: %v", err)
+	}
+	for _, a := range lines {
+		if err := writeJSONLine(f, a); err != nil {
+			t.Fatalf("Failed to write journal: %v", err)
+		}
+	}
+	f.Close()
+
+	var restored []string
+	report, err := UndoClean(context.Background(), journal, func(a CleanAction) {
+		restored = append(restored, relSlashPath(root, a.Path))
+	})
+	if err != nil {
+		t.Fatalf("Failed to undo: %v", err)
+	}
+	if report.Done != 1 || len(report.Failed) != 0 || !reflect.DeepEqual(restored, []string{"a.log"}) {
+		t.Errorf("Expected a.log alone restored, got %v and %+v", restored, report)
+	}
+	for _, rel := range []string{"a.log", "sub/b.log"} {
+		if _, err := os.Stat(filepath.Join(root, filepath.FromSlash(rel))); err != nil {
+			t.Errorf("Expected %s in the tree, got %v", rel, err)
+		}
+	}
+}
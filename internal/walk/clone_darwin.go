@@ -0,0 +1,18 @@
+package stride
+
+import (
+	"errors"
+	"fmt"
+
+	"golang.org/x/sys/unix"
+)
+
+// platformCloneFile clones src to the new file dst with clonefile(2),
+// supported by APFS.
+func platformCloneFile(src, dst string) error {
+	err := unix.Clonefile(src, dst, unix.CLONE_NOFOLLOW)
+	if errors.Is(err, unix.ENOTSUP) || errors.Is(err, unix.EXDEV) {
+		return fmt.Errorf("%w by the filesystem of %s: %v", ErrReflinkUnsupported, src, err)
+	}
+	return err
+}
@@ -0,0 +1,10 @@
+//go:build !darwin && !linux
+
+package stride
+
+import "fmt"
+
+// platformCloneFile cannot clone files on this platform.
+func platformCloneFile(src, dst string) error {
+	return fmt.Errorf("%w on this platform", ErrReflinkUnsupported)
+}
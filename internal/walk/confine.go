@@ -0,0 +1,158 @@
+package stride
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// errNotConfined is returned by OpenInRoot for a context no confined walk
+// passed to its callbacks.
+var errNotConfined = errors.New("stride: the context is not that of a walk confined to its root")
+
+// confinedRoot is the root of a walk with WalkOptions.ConfineToRoot,
+// opened once, through which the tree is enumerated and stat'ed.
+type confinedRoot struct {
+	root  *os.Root
+	name  string // The walk's root, as passed to the callback
+	owned bool   // Whether the walk opened root, and closes it
+}
+
+// openConfinedRoot returns the confined root of a walk of the directory
+// name, going through handle, WalkOptions.ConfinedRoot, if set, and
+// opening the directory otherwise.
+func openConfinedRoot(name string, handle *os.Root) (*confinedRoot, error) {
+	if handle != nil {
+		return &confinedRoot{root: handle, name: name}, nil
+	}
+	root, err := os.OpenRoot(name)
+	if err != nil {
+		return nil, err
+	}
+	return &confinedRoot{root: root, name: name, owned: true}, nil
+}
+
+// close closes c's root if the walk opened it.
+func (c *confinedRoot) close() error {
+	if !c.owned {
+		return nil
+	}
+	return c.root.Close()
+}
+
+// walkDir is walkDirTree for the directory at path, the root or a
+// directory below it, enumerated through the root: every directory is
+// opened and every entry stat'ed relative to it, so a component swapped
+// for a symbolic link leading outside it fails instead of being followed.
+func (c *confinedRoot) walkDir(path string, fn fs.WalkDirFunc) error {
+	rel, err := filepath.Rel(c.name, path)
+	if err != nil || !filepath.IsLocal(rel) {
+		return fn(path, nil, &os.PathError{Op: "walk", Path: path, Err: errors.New("path escapes from root")})
+	}
+	return fs.WalkDir(c.root.FS(), filepath.ToSlash(rel), func(name string, d fs.DirEntry, err error) error {
+		if d != nil {
+			d = confinedEntry{DirEntry: d, root: c.root, name: name}
+		}
+		return fn(filepath.Join(c.name, filepath.FromSlash(name)), d, err)
+	})
+}
+
+// confinedEntry is an entry of a confined walk, stat'ed through the root
+// rather than by path.
+type confinedEntry struct {
+	fs.DirEntry
+	root *os.Root
+	name string // Slash-separated path relative to the root
+}
+
+// Info returns the FileInfo of the entry itself, not of a link's target.
+func (e confinedEntry) Info() (fs.FileInfo, error) {
+	return e.root.Lstat(filepath.FromSlash(e.name))
+}
+
+// confinedRootKey is the context key of the root OpenInRoot opens through.
+type confinedRootKey struct{}
+
+// withConfinedRoot returns a copy of ctx under which OpenInRoot opens
+// through root.
+func withConfinedRoot(ctx context.Context, root *os.Root) context.Context {
+	return context.WithValue(ctx, confinedRootKey{}, root)
+}
+
+// openRootFor waits for root as a walk with wait as WalkOptions.WaitForRoot
+// would, then opens it for a confined walk whose callbacks get a context.
+func openRootFor(ctx context.Context, root string, wait time.Duration) (*os.Root, error) {
+	if err := waitForRoot(ctx, root, wait); err != nil {
+		return nil, err
+	}
+	return os.OpenRoot(root)
+}
+
+// confineOptions prepares options, of a walk of root passing its callbacks
+// options.Context, for WalkOptions.ConfineToRoot: unless the caller set
+// ConfinedRoot, the root is opened, and the context callbacks get carries
+// it for OpenInRoot. The returned function closes what was opened, once
+// the walk is done.
+func confineOptions(root string, options WalkOptions) (WalkOptions, func(), error) {
+	if !options.ConfineToRoot {
+		return options, func() {}, nil
+	}
+	ctx := options.Context
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	release := func() {}
+	if options.ConfinedRoot == nil {
+		handle, err := openRootFor(ctx, root, options.WaitForRoot)
+		if err != nil {
+			return options, nil, err
+		}
+		options.ConfinedRoot = handle
+		release = func() { handle.Close() }
+	}
+	options.Context = withConfinedRoot(ctx, options.ConfinedRoot)
+	return options, release, nil
+}
+
+// OpenInRoot opens name, relative to the root of the walk with
+// WalkOptions.ConfineToRoot that passed ctx to the callback, for reading.
+// It is for the callbacks of WalkWithOptions and the walks built on it,
+// such as Map and Reduce, and for Find's handlers; others open entries
+// through WalkOptions.ConfinedRoot. Like the walk, it cannot be led outside
+// the root: a symbolic link, or a component swapped for one, whose target
+// lies outside fails with an error. Once the walk has returned, the root is
+// closed and opening fails.
+func OpenInRoot(ctx context.Context, name string) (*os.File, error) {
+	var root *os.Root
+	if ctx != nil {
+		root, _ = ctx.Value(confinedRootKey{}).(*os.Root)
+	}
+	if root == nil {
+		return nil, &os.PathError{Op: "openinroot", Path: name, Err: errNotConfined}
+	}
+	return root.Open(name)
+}
+
+// hashInRoot is hashFile for path, an entry of the walk of the directory
+// name confined to root, opened through root.
+func hashInRoot(root *os.Root, name, path string) (string, error) {
+	f, err := openInRoot(root, name, path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	return hashContent(f)
+}
+
+// openInRoot opens path, an entry of the walk of the directory name
+// confined to root, through root.
+func openInRoot(root *os.Root, name, path string) (*os.File, error) {
+	rel, err := filepath.Rel(name, path)
+	if err != nil {
+		return nil, err
+	}
+	return root.Open(rel)
+}
@@ -0,0 +1,253 @@
+package stride
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/TFMV/stride/walk/walktest"
+)
+
+// swapForLink returns a callback that, on reaching the directory sub,
+// replaces it with a symbolic link to target before it is read, recording
+// the entries delivered. With confined, the walk's root handle, it then
+// tries to open a file through the link.
+func swapForLink(t *testing.T, root, target string, confined *os.Root) (filepath.WalkFunc, func() (entries []string, opened error)) {
+	var mu sync.Mutex
+	var entries []string
+	var opened error
+	sub := filepath.Join(root, "sub")
+	return func(path string, info os.FileInfo, err error) error {
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				return nil
+			}
+			entries = append(entries, relSlashPath(root, path))
+			if path == sub {
+				if err := os.Rename(sub, sub+".moved"); err != nil {
+					t.Errorf("Failed to move directory: %v", err)
+				}
+				if err := os.Symlink(target, sub); err != nil {
+					t.Errorf("Failed to create symlink: %v", err)
+				}
+				if confined != nil {
+					f, err := confined.Open("sub/passwd")
+					if err == nil {
+						f.Close()
+					}
+					opened = err
+				}
+			}
+			return nil
+		}, func() ([]string, error) {
+			mu.Lock()
+			defer mu.Unlock()
+			return entries, opened
+		}
+}
+
+func TestConfineToRootSwappedDirectory(t *testing.T) {
+	if _, err := os.Stat("/etc/passwd"); err != nil {
+		t.Skip("No /etc/passwd to lead the walk to")
+	}
+	tree := walktest.Tree{
+		"a.txt":         walktest.File{Content: "a"},
+		"sub/inner.txt": walktest.File{Content: "inner"},
+		"zz.txt":        walktest.File{Content: "zz"},
+	}
+
+	// Unconfined, the swap leads the walk into /etc
+	root := tree.Build(t)
+	walkFn, result := swapForLink(t, root, "/etc", nil)
+	if _, err := WalkLimitWithOptionsStats(context.Background(), root, walkFn, WalkOptions{NumWorkers: 1, SymlinkHandling: SymlinkReport}); err != nil {
+		t.Fatalf("Failed to walk: %v", err)
+	}
+	if entries, _ := result(); !containsPath(entries, "sub/passwd") {
+		t.Fatalf("Expected the unconfined walk to read /etc through the swapped directory, got %v", entries)
+	}
+
+	// Confined, reading the swapped directory fails, and so does opening
+	// anything through it
+	root = tree.Build(t)
+	confined, err := os.OpenRoot(root)
+	if err != nil {
+		t.Fatalf("Failed to open root: %v", err)
+	}
+	defer confined.Close()
+	walkFn, result = swapForLink(t, root, "/etc", confined)
+	stats, err := WalkLimitWithOptionsStats(context.Background(), root, walkFn, WalkOptions{NumWorkers: 1, ConfineToRoot: true, ConfinedRoot: confined})
+	if err != nil {
+		t.Fatalf("Failed to walk: %v", err)
+	}
+	entries, opened := result()
+	for _, entry := range entries {
+		if strings.HasPrefix(entry, "sub/") {
+			t.Errorf("Expected nothing beneath the swapped directory, got %s", entry)
+		}
+	}
+	if !containsPath(entries, "a.txt") || !containsPath(entries, "zz.txt") {
+		t.Errorf("Expected the rest of the tree to be walked, got %v", entries)
+	}
+	if stats.ErrorCount != 1 {
+		t.Errorf("Expected an error reading the swapped directory, got %d errors", stats.ErrorCount)
+	}
+	if opened == nil {
+		t.Error("Expected opening through the swapped directory to fail")
+	}
+}
+
+func TestConfineToRoot(t *testing.T) {
+	root := walktest.Tree{
+		"a.txt":         walktest.File{Content: "a"},
+		"sub/inner.txt": walktest.File{Content: "inner"},
+		"inside":        walktest.Symlink{Target: "sub"},
+		"outside":       walktest.Symlink{Target: os.TempDir()},
+	}.Build(t)
+
+	confined, err := os.OpenRoot(root)
+	if err != nil {
+		t.Fatalf("Failed to open root: %v", err)
+	}
+	defer confined.Close()
+	var mu sync.Mutex
+	contents := make(map[string]string)
+	var entries []string
+	walkFn := func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		rel := relSlashPath(root, path)
+		entries = append(entries, rel)
+		if info.Mode().IsRegular() {
+			f, err := confined.Open(rel)
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+			b, err := io.ReadAll(f)
+			if err != nil {
+				return err
+			}
+			contents[rel] = string(b)
+		}
+		return nil
+	}
+	if _, err := WalkLimitWithOptionsStats(context.Background(), root, walkFn, WalkOptions{NumWorkers: 2, ConfineToRoot: true, ConfinedRoot: confined}); err != nil {
+		t.Fatalf("Failed to walk: %v", err)
+	}
+
+	// Links are reported, never followed
+	for _, want := range []string{"a.txt", "sub/inner.txt", "inside", "outside"} {
+		if !containsPath(entries, want) {
+			t.Errorf("Expected %s to be delivered, got %v", want, entries)
+		}
+	}
+	if containsPath(entries, "inside/inner.txt") {
+		t.Error("Expected the link to sub not to be followed")
+	}
+	if contents["a.txt"] != "a" || contents["sub/inner.txt"] != "inner" {
+		t.Errorf("Expected the files to be read through the root, got %v", contents)
+	}
+
+	// OpenInRoot only works with the context a confined walk passes
+	if _, err := OpenInRoot(context.Background(), "a.txt"); !errors.Is(err, errNotConfined) {
+		t.Errorf("Expected errNotConfined, got %v", err)
+	}
+
+	// Matches of a confined search are opened with the handler's context
+	var found []string
+	err = Find(context.Background(), root, FindOptions{ConfineToRoot: true, MaxDepth: 2, IncludeHidden: true}, func(ctx context.Context, result FindResult) error {
+		if result.Error != nil {
+			return result.Error
+		}
+		rel := relSlashPath(root, result.Message.Path)
+		if result.Message.Mode.IsRegular() {
+			f, err := OpenInRoot(ctx, rel)
+			if err != nil {
+				return err
+			}
+			f.Close()
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		found = append(found, rel)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Failed to find files: %v", err)
+	}
+	if !containsPath(found, "sub/inner.txt") {
+		t.Errorf("Expected sub/inner.txt to be found, got %v", found)
+	}
+}
+
+func TestConfineToRootSharedContext(t *testing.T) {
+	roots := []string{
+		walktest.Tree{"a.txt": walktest.File{Content: "first"}}.Build(t),
+		walktest.Tree{"a.txt": walktest.File{Content: "second"}}.Build(t),
+	}
+
+	// Confined walks running with the same context each open through
+	// their own root
+	ctx := context.Background()
+	contents := make([]string, len(roots))
+	var wg sync.WaitGroup
+	for i, root := range roots {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			err := WalkWithOptions(root, func(ctx context.Context, path string, info os.FileInfo) error {
+				if !info.Mode().IsRegular() {
+					return nil
+				}
+				f, err := OpenInRoot(ctx, relSlashPath(root, path))
+				if err != nil {
+					return err
+				}
+				defer f.Close()
+				b, err := io.ReadAll(f)
+				if err != nil {
+					return err
+				}
+				contents[i] = string(b)
+				return nil
+			}, WalkOptions{Context: ctx, ConfineToRoot: true, ErrorHandling: ErrorHandlingStop})
+			if err != nil {
+				t.Errorf("Failed to walk: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+	if contents[0] != "first" || contents[1] != "second" {
+		t.Errorf("Expected each walk to read its own root, got %v", contents)
+	}
+
+	// The pre-scan counts through the root as well
+	stats, err := WalkWithOptionsAndStats(roots[0], func(context.Context, string, os.FileInfo) error {
+		return nil
+	}, WalkOptions{Context: ctx, ConfineToRoot: true, EstimateTotals: true})
+	if err != nil {
+		t.Fatalf("Failed to walk: %v", err)
+	}
+	if stats.TotalFilesEstimate != 1 {
+		t.Errorf("Expected an estimate of 1 file, got %d", stats.TotalFilesEstimate)
+	}
+}
+
+// containsPath reports whether paths contains path.
+func containsPath(paths []string, path string) bool {
+	for _, p := range paths {
+		if p == path {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,64 @@
+package stride
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"regexp"
+)
+
+// DefaultContentMaxLines is the number of matching lines Find keeps in
+// FindMessage.MatchedLines unless FindOptions.ContentMaxLines says
+// otherwise.
+const DefaultContentMaxLines = 10
+
+// contentMatch is the outcome of searching the content of a file.
+type contentMatch struct {
+	lines  []string // Matching lines, without their line endings
+	binary bool     // Whether the content looked binary
+}
+
+// searchContent reads r line by line, up to maxBytes of it or all of it if
+// maxBytes is not positive, and returns the first maxLines lines matching
+// pattern. Content with a NUL byte among its first binarySniffLen bytes is
+// binary and not searched unless includeBinary is set. Reading stops once
+// maxLines lines have matched.
+func searchContent(r io.Reader, pattern *regexp.Regexp, maxBytes int64, maxLines int, includeBinary bool) (contentMatch, error) {
+	var found contentMatch
+	if maxBytes > 0 {
+		r = io.LimitReader(r, maxBytes)
+	}
+	if maxLines <= 0 {
+		maxLines = DefaultContentMaxLines
+	}
+	br := bufio.NewReaderSize(r, 64*1024)
+	head, err := br.Peek(binarySniffLen)
+	if err != nil && err != io.EOF {
+		return found, err
+	}
+	if bytes.IndexByte(head, 0) >= 0 {
+		found.binary = true
+		if !includeBinary {
+			return found, nil
+		}
+	}
+
+	for {
+		line, err := br.ReadBytes('\n')
+		if len(line) > 0 {
+			line = bytes.TrimSuffix(bytes.TrimSuffix(line, []byte("\n")), []byte("\r"))
+			if pattern.Match(line) {
+				found.lines = append(found.lines, string(line))
+				if len(found.lines) >= maxLines {
+					return found, nil
+				}
+			}
+		}
+		if err == io.EOF {
+			return found, nil
+		}
+		if err != nil {
+			return found, err
+		}
+	}
+}
@@ -0,0 +1,140 @@
+package stride
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/TFMV/stride/walk/walktest"
+)
+
+func TestSearchContent(t *testing.T) {
+	todo := regexp.MustCompile(`TODO`)
+	tests := []struct {
+		name          string
+		content       string
+		maxBytes      int64
+		maxLines      int
+		includeBinary bool
+		want          []string
+		binary        bool
+	}{
+		{"matching lines", "a TODO\nb\nc TODO\n", 0, 0, false, []string{"a TODO", "c TODO"}, false},
+		{"no match", "a\nb\n", 0, 0, false, nil, false},
+		{"last line unterminated", "a\r\nb TODO", 0, 0, false, []string{"b TODO"}, false},
+		{"line endings dropped", "TODO\r\n", 0, 0, false, []string{"TODO"}, false},
+		{"lines capped", "TODO 1\nTODO 2\nTODO 3\n", 0, 2, false, []string{"TODO 1", "TODO 2"}, false},
+		{"bytes capped", "a\nb\nTODO\n", 4, 0, false, nil, false},
+		{"binary skipped", "TODO\x00\n", 0, 0, false, nil, true},
+		{"binary included", "x\x00\nTODO\n", 0, 0, true, []string{"TODO"}, true},
+		{"empty", "", 0, 0, false, nil, false},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			found, err := searchContent(strings.NewReader(tc.content), todo, tc.maxBytes, tc.maxLines, tc.includeBinary)
+			if err != nil {
+				t.Fatalf("Failed to search: %v", err)
+			}
+			if !reflect.DeepEqual(found.lines, tc.want) || found.binary != tc.binary {
+				t.Errorf("Expected %q (binary %v), got %q (binary %v)", tc.want, tc.binary, found.lines, found.binary)
+			}
+		})
+	}
+
+	// NUL bytes beyond the sniffed prefix do not make content binary
+	late := strings.Repeat("x\n", binarySniffLen) + "TODO\x00\n"
+	if found, _ := searchContent(strings.NewReader(late), todo, 0, 0, false); len(found.lines) != 1 || found.binary {
+		t.Errorf("Expected a late NUL byte to be searched, got %q (binary %v)", found.lines, found.binary)
+	}
+}
+
+func TestFindContent(t *testing.T) {
+	root := walktest.Tree{
+		"a.go":       walktest.File{Content: "package a\n// TODO: tidy\nfunc A() {}\n"},
+		"b.go":       walktest.File{Content: "package b\n"},
+		"c.bin":      walktest.File{Content: "\x00\x01TODO"},
+		"sub/d.go":   walktest.File{Content: "// TODO one\n// TODO two\n"},
+		"sub/TODO":   walktest.Dir{},
+		"notes.todo": walktest.Symlink{Target: "a.go"},
+	}.Build(t)
+
+	find := func(opts FindOptions) map[string][]string {
+		t.Helper()
+		var mu sync.Mutex
+		got := make(map[string][]string)
+		opts.Workers = 4
+		opts.Types = []string{"file", "dir", "symlink"}
+		err := Find(context.Background(), root, opts, func(ctx context.Context, result FindResult) error {
+			if result.Error != nil {
+				return result.Error
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			got[relSlashPath(root, result.Message.Path)] = result.Message.MatchedLines
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("Failed to find: %v", err)
+		}
+		return got
+	}
+
+	// Directories and unfollowed links have no content
+	want := map[string][]string{
+		"a.go":     {"// TODO: tidy"},
+		"sub/d.go": {"// TODO one", "// TODO two"},
+	}
+	if got := find(FindOptions{ContentPattern: regexp.MustCompile(`TODO`), MaxDepth: 2}); !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected %v, got %v", want, got)
+	}
+
+	want["c.bin"] = []string{"\x00\x01TODO"}
+	want["sub/d.go"] = want["sub/d.go"][:1]
+	if got := find(FindOptions{ContentPattern: regexp.MustCompile(`TODO`), MaxDepth: 2, IncludeBinary: true, ContentMaxLines: 1}); !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected %v, got %v", want, got)
+	}
+
+	// Explanations tell why a file's content did not match
+	var mu sync.Mutex
+	var rejected []string
+	opts := FindOptions{
+		ContentPattern: regexp.MustCompile(`TODO`),
+		Explain:        true,
+		OnEvaluated: func(msg FindMessage, decision FindDecision) {
+			mu.Lock()
+			defer mu.Unlock()
+			if !decision.Matched {
+				rejected = append(rejected, msg.Name+" "+decision.String())
+			}
+		},
+		Output: &strings.Builder{},
+	}
+	if err := Find(context.Background(), root, opts, nil); err != nil {
+		t.Fatalf("Failed to find: %v", err)
+	}
+	sort.Strings(rejected)
+	if want := []string{"b.go content:FAIL", "c.bin content:FAIL(binary)"}; !reflect.DeepEqual(rejected, want) {
+		t.Errorf("Expected %q, got %q", want, rejected)
+	}
+}
+
+func TestFindContentTemplate(t *testing.T) {
+	tmpDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(tmpDir, "a.txt"), []byte("one\nneedle 1\ntwo\nneedle 2\n"), 0644); err != nil {
+		t.Fatalf("Failed to create file: %v", err)
+	}
+	var out strings.Builder
+	opts := FindOptions{ContentPattern: regexp.MustCompile(`needle`), Output: &out}
+	if err := FindWithFormat(context.Background(), tmpDir, opts, "{base}:\n{matches}"); err != nil {
+		t.Fatalf("Failed to find: %v", err)
+	}
+	if want := "a.txt:\nneedle 1\nneedle 2\n"; out.String() != want {
+		t.Errorf("Expected %q, got %q", want, out.String())
+	}
+}
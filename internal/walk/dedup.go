@@ -0,0 +1,189 @@
+package stride
+
+import (
+	"errors"
+	"fmt"
+	"os"
+)
+
+// DedupStrategy selects how PerformDeduplicationWithStrategy replaces the
+// duplicates of a group's first file.
+type DedupStrategy int
+
+const (
+	// DedupSymlink replaces duplicates with symbolic links to the first file.
+	DedupSymlink DedupStrategy = iota
+
+	// DedupHardlink replaces duplicates with hard links to the first file,
+	// so that tools not following links still see regular files. Writes
+	// through one name show through every other. Duplicates on another
+	// filesystem fall back to symbolic links, with a warning.
+	DedupHardlink
+
+	// DedupReflink replaces duplicates with copy-on-write clones of the
+	// first file, which share its blocks until either is written, on
+	// filesystems that support them: btrfs and xfs on Linux, APFS on macOS.
+	// Groups spanning filesystems are skipped.
+	DedupReflink
+)
+
+// String returns the name of the strategy.
+func (s DedupStrategy) String() string {
+	switch s {
+	case DedupSymlink:
+		return "symlink"
+	case DedupHardlink:
+		return "hardlink"
+	case DedupReflink:
+		return "reflink"
+	}
+	return fmt.Sprintf("DedupStrategy(%d)", int(s))
+}
+
+// ErrReflinkUnsupported is returned by DedupReflink deduplication where the
+// platform or filesystem cannot clone files. Nothing is changed.
+var ErrReflinkUnsupported = errors.New("stride: reflinks are not supported")
+
+// cloneFile creates dst as a copy-on-write clone of src. Tests replace it.
+var cloneFile = platformCloneFile
+
+// DedupReport describes a deduplication, or with DryRun, what it would do.
+type DedupReport struct {
+	Strategy   DedupStrategy
+	DryRun     bool
+	Replaced   []string // Duplicates replaced, or that would be
+	BytesSaved int64    // Size of the replaced duplicates
+	Skipped    []string // Duplicates left alone; Warnings says why
+	Warnings   []string
+}
+
+// dedupAction is the planned replacement of one duplicate.
+type dedupAction struct {
+	path     string
+	info     os.FileInfo
+	strategy DedupStrategy // The group's, unless it had to fall back
+}
+
+// PerformDeduplicationWithStrategy replaces the duplicates of the first
+// file of group, group.Files[0], according to strategy, and reports what
+// was replaced and the bytes saved. With dryRun, nothing is changed and
+// the report tells what would be.
+//
+// Every file is checked before anything is changed: duplicates that are
+// already the first file, or are not regular files, are skipped, and so is
+// the whole group under DedupReflink if it spans filesystems. Each
+// duplicate is renamed to a backup first, restored if it cannot be
+// replaced, and removed once it is.
+func (a *Analyzer) PerformDeduplicationWithStrategy(group DuplicateGroup, strategy DedupStrategy, dryRun bool) (DedupReport, error) {
+	report := DedupReport{Strategy: strategy, DryRun: dryRun}
+	if len(group.Files) < 2 {
+		return report, fmt.Errorf("not enough files to deduplicate")
+	}
+
+	actions, err := planDedup(group, strategy, &report)
+	if err != nil || dryRun {
+		return report, err
+	}
+
+	primaryFile := group.Files[0]
+	for _, action := range actions {
+		if err := replaceDuplicate(primaryFile, action); err != nil {
+			return report, err
+		}
+		report.Replaced = append(report.Replaced, action.path)
+		report.BytesSaved += action.info.Size()
+	}
+	return report, nil
+}
+
+// planDedup checks the files of group and returns the duplicates to
+// replace. With a dry run, they are recorded in report as replaced.
+func planDedup(group DuplicateGroup, strategy DedupStrategy, report *DedupReport) ([]dedupAction, error) {
+	primaryFile := group.Files[0]
+	primary, err := os.Stat(primaryFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to stat %s: %v", primaryFile, err)
+	}
+	primaryKey, primaryKnown := dirKeyOf(primary)
+
+	var actions []dedupAction
+	for _, duplicateFile := range group.Files[1:] {
+		info, err := os.Lstat(duplicateFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to stat %s: %v", duplicateFile, err)
+		}
+		if !info.Mode().IsRegular() {
+			report.skip(duplicateFile, "not a regular file")
+			continue
+		}
+		if os.SameFile(primary, info) {
+			report.skip(duplicateFile, "already the same file as "+primaryFile)
+			continue
+		}
+
+		action := dedupAction{path: duplicateFile, info: info, strategy: strategy}
+		key, known := dirKeyOf(info)
+		if crossDevice := primaryKnown && known && key.dev != primaryKey.dev; crossDevice {
+			switch strategy {
+			case DedupHardlink:
+				report.Warnings = append(report.Warnings, fmt.Sprintf("%s: on another filesystem than %s, using a symlink instead", duplicateFile, primaryFile))
+				action.strategy = DedupSymlink
+			case DedupReflink:
+				// Clones cannot cross filesystems; leave the group alone
+				report.Skipped = append([]string(nil), group.Files[1:]...)
+				report.Warnings = append(report.Warnings, fmt.Sprintf("%s: on another filesystem than %s, group skipped", duplicateFile, primaryFile))
+				return nil, nil
+			}
+		}
+		actions = append(actions, action)
+	}
+
+	if report.DryRun {
+		for _, action := range actions {
+			report.Replaced = append(report.Replaced, action.path)
+			report.BytesSaved += action.info.Size()
+		}
+	}
+	return actions, nil
+}
+
+// skip records a duplicate left alone and why.
+func (r *DedupReport) skip(path, reason string) {
+	r.Skipped = append(r.Skipped, path)
+	r.Warnings = append(r.Warnings, path+": "+reason)
+}
+
+// replaceDuplicate replaces a duplicate of primaryFile, keeping a backup
+// until the replacement exists.
+func replaceDuplicate(primaryFile string, action dedupAction) error {
+	duplicateFile := action.path
+
+	// Create backup
+	backupPath := duplicateFile + ".bak"
+	if err := os.Rename(duplicateFile, backupPath); err != nil {
+		return fmt.Errorf("failed to create backup of %s: %v", duplicateFile, err)
+	}
+
+	var err error
+	switch action.strategy {
+	case DedupHardlink:
+		err = os.Link(primaryFile, duplicateFile)
+	case DedupReflink:
+		if err = cloneFile(primaryFile, duplicateFile); err == nil {
+			// Clones take the permissions of the file they replace
+			err = os.Chmod(duplicateFile, action.info.Mode().Perm())
+		}
+	default:
+		err = os.Symlink(primaryFile, duplicateFile)
+	}
+	if err != nil {
+		// Restore from backup on failure
+		os.Remove(duplicateFile)
+		os.Rename(backupPath, duplicateFile)
+		return fmt.Errorf("failed to create %s from %s to %s: %w", action.strategy, duplicateFile, primaryFile, err)
+	}
+
+	// Remove backup
+	os.Remove(backupPath)
+	return nil
+}
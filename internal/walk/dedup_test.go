@@ -0,0 +1,210 @@
+package stride
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/TFMV/stride/walk/walktest"
+)
+
+// dedupFixture builds a tree holding three copies of one file and returns
+// its root and the group of copies.
+func dedupFixture(t *testing.T) (string, DuplicateGroup) {
+	root := walktest.Tree{
+		"orig.txt":     walktest.File{Content: "same content"},
+		"a/copy.txt":   walktest.File{Content: "same content", Mode: 0600},
+		"b/copy.txt":   walktest.File{Content: "same content"},
+		"linked.txt":   walktest.Hardlink{Target: "orig.txt"},
+		"symlink.txt":  walktest.Symlink{Target: "orig.txt"},
+		"other/x.data": walktest.File{Content: "x"},
+	}.Build(t)
+	group := DuplicateGroup{Files: []string{
+		filepath.Join(root, "orig.txt"),
+		filepath.Join(root, "a", "copy.txt"),
+		filepath.Join(root, "b", "copy.txt"),
+	}}
+	return root, group
+}
+
+func TestDeduplicateHardlink(t *testing.T) {
+	root, group := dedupFixture(t)
+	a := NewAnalyzer()
+
+	report, err := a.PerformDeduplicationWithStrategy(group, DedupHardlink, false)
+	if err != nil {
+		t.Fatalf("Deduplication failed: %v", err)
+	}
+	if !reflect.DeepEqual(report.Replaced, group.Files[1:]) || report.BytesSaved != 24 {
+		t.Errorf("Expected both copies replaced, saving 24 bytes, got %+v", report)
+	}
+
+	orig, err := os.Stat(group.Files[0])
+	if err != nil {
+		t.Fatalf("Failed to stat: %v", err)
+	}
+	for _, path := range group.Files[1:] {
+		info, err := os.Lstat(path)
+		if err != nil {
+			t.Fatalf("Failed to stat: %v", err)
+		}
+		if !info.Mode().IsRegular() || !os.SameFile(orig, info) {
+			t.Errorf("Expected %s to be a hard link to orig.txt, got mode %v", path, info.Mode())
+		}
+		if _, err := os.Lstat(path + ".bak"); !os.IsNotExist(err) {
+			t.Errorf("Expected the backup of %s to be removed, got %v", path, err)
+		}
+	}
+
+	// Linking again finds nothing to do
+	report, err = a.PerformDeduplicationWithStrategy(group, DedupHardlink, false)
+	if err != nil || len(report.Replaced) != 0 || len(report.Skipped) != 2 {
+		t.Errorf("Expected both copies to be skipped, got %+v (%v)", report, err)
+	}
+
+	// Links and hard links to the first file are never replaced
+	report, err = a.PerformDeduplicationWithStrategy(DuplicateGroup{Files: []string{
+		group.Files[0], filepath.Join(root, "linked.txt"), filepath.Join(root, "symlink.txt"),
+	}}, DedupSymlink, false)
+	if err != nil || len(report.Replaced) != 0 || len(report.Skipped) != 2 {
+		t.Errorf("Expected linked.txt and symlink.txt to be skipped, got %+v (%v)", report, err)
+	}
+}
+
+func TestDeduplicateDryRun(t *testing.T) {
+	_, group := dedupFixture(t)
+	a := NewAnalyzer()
+
+	for _, strategy := range []DedupStrategy{DedupSymlink, DedupHardlink, DedupReflink} {
+		report, err := a.PerformDeduplicationWithStrategy(group, strategy, true)
+		if err != nil {
+			t.Fatalf("%s: dry run failed: %v", strategy, err)
+		}
+		if !report.DryRun || len(report.Replaced) != 2 || report.BytesSaved != 24 {
+			t.Errorf("%s: expected 24 bytes to be saved, got %+v", strategy, report)
+		}
+	}
+	for _, path := range group.Files[1:] {
+		if info, err := os.Lstat(path); err != nil || !info.Mode().IsRegular() {
+			t.Errorf("Expected the dry run to leave %s alone", path)
+		}
+	}
+}
+
+func TestDeduplicateSymlink(t *testing.T) {
+	_, group := dedupFixture(t)
+	if err := NewAnalyzer().PerformDeduplication(group, false); err != nil {
+		t.Fatalf("Deduplication failed: %v", err)
+	}
+	for _, path := range group.Files[1:] {
+		if target, err := os.Readlink(path); err != nil || target != group.Files[0] {
+			t.Errorf("Expected %s to link to orig.txt, got %q (%v)", path, target, err)
+		}
+	}
+}
+
+func TestDeduplicateReflink(t *testing.T) {
+	var cloned []string
+	orig := cloneFile
+	t.Cleanup(func() { cloneFile = orig })
+
+	// A fake clone copies the file
+	cloneFile = func(src, dst string) error {
+		data, err := os.ReadFile(src)
+		if err != nil {
+			return err
+		}
+		cloned = append(cloned, dst)
+		return os.WriteFile(dst, data, 0644)
+	}
+	_, group := dedupFixture(t)
+	report, err := NewAnalyzer().PerformDeduplicationWithStrategy(group, DedupReflink, false)
+	if err != nil {
+		t.Fatalf("Deduplication failed: %v", err)
+	}
+	if !reflect.DeepEqual(cloned, group.Files[1:]) || report.BytesSaved != 24 {
+		t.Errorf("Expected both copies cloned, got %q and %+v", cloned, report)
+	}
+	info, err := os.Stat(group.Files[1])
+	if err != nil || info.Mode().Perm() != 0600 {
+		t.Errorf("Expected the clone to keep mode 0600, got %v (%v)", info, err)
+	}
+
+	// A failed clone restores the duplicate and stops
+	cloneFile = func(src, dst string) error {
+		if err := os.WriteFile(dst, []byte("partial"), 0644); err != nil {
+			return err
+		}
+		return ErrReflinkUnsupported
+	}
+	_, group = dedupFixture(t)
+	report, err = NewAnalyzer().PerformDeduplicationWithStrategy(group, DedupReflink, false)
+	if !errors.Is(err, ErrReflinkUnsupported) || len(report.Replaced) != 0 {
+		t.Errorf("Expected ErrReflinkUnsupported with nothing replaced, got %+v (%v)", report, err)
+	}
+	for _, path := range group.Files[1:] {
+		if data, err := os.ReadFile(path); err != nil || string(data) != "same content" {
+			t.Errorf("Expected %s to be restored, got %q (%v)", path, data, err)
+		}
+		if _, err := os.Lstat(path + ".bak"); !os.IsNotExist(err) {
+			t.Errorf("Expected no backup of %s to remain, got %v", path, err)
+		}
+	}
+}
+
+func TestDeduplicateErrors(t *testing.T) {
+	root, group := dedupFixture(t)
+	a := NewAnalyzer()
+	if _, err := a.PerformDeduplicationWithStrategy(DuplicateGroup{Files: group.Files[:1]}, DedupHardlink, false); err == nil {
+		t.Error("Expected an error for a single file")
+	}
+
+	// A missing duplicate is reported before anything is changed
+	group.Files = append(group.Files, filepath.Join(root, "missing.txt"))
+	if _, err := a.PerformDeduplicationWithStrategy(group, DedupHardlink, false); err == nil {
+		t.Error("Expected an error for a missing duplicate")
+	}
+	for _, path := range group.Files[1:3] {
+		if info, err := os.Lstat(path); err != nil || info.Mode()&os.ModeSymlink != 0 || info.Size() != 12 {
+			t.Errorf("Expected %s to be left alone", path)
+		}
+	}
+}
+
+func TestDeduplicateCrossDevice(t *testing.T) {
+	_, group := dedupFixture(t)
+	other, err := os.MkdirTemp("/dev/shm", "dedup")
+	if err != nil {
+		t.Skipf("No second filesystem: %v", err)
+	}
+	t.Cleanup(func() { os.RemoveAll(other) })
+	far := filepath.Join(other, "copy.txt")
+	if err := os.WriteFile(far, []byte("same content"), 0644); err != nil {
+		t.Fatalf("Failed to create file: %v", err)
+	}
+	a, _ := os.Stat(group.Files[0])
+	b, _ := os.Stat(far)
+	ka, _ := dirKeyOf(a)
+	kb, _ := dirKeyOf(b)
+	if ka.dev == kb.dev {
+		t.Skip("/dev/shm is on the same filesystem")
+	}
+	group.Files = append(group.Files, far)
+
+	// Reflinks skip the whole group before changing anything
+	report, err := NewAnalyzer().PerformDeduplicationWithStrategy(group, DedupReflink, false)
+	if err != nil || len(report.Replaced) != 0 || len(report.Skipped) != 3 {
+		t.Errorf("Expected the group to be skipped, got %+v (%v)", report, err)
+	}
+
+	// Hard links fall back to a symlink for the far copy
+	report, err = NewAnalyzer().PerformDeduplicationWithStrategy(group, DedupHardlink, false)
+	if err != nil || len(report.Replaced) != 3 || len(report.Warnings) != 1 {
+		t.Fatalf("Expected all copies replaced with one warning, got %+v (%v)", report, err)
+	}
+	if target, err := os.Readlink(far); err != nil || target != group.Files[0] {
+		t.Errorf("Expected the far copy to link to orig.txt, got %q (%v)", target, err)
+	}
+}
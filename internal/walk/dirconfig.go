@@ -0,0 +1,228 @@
+package stride
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"gopkg.in/yaml.v3"
+)
+
+// DefaultDirConfigName is the file holding a directory's DirConfig when
+// WalkOptions.DirConfigName is not set.
+const DefaultDirConfigName = ".strideconfig"
+
+// DirConfig narrows the options of a walk for the subtree of the directory
+// holding it, when WalkOptions.EnableDirConfigs is set. It is read from a
+// YAML file in that directory, such as:
+//
+//	exclude: ["*.log", "*.tmp"]
+//	exclude_dirs: [cache]
+//	symlinks: report
+//	max_depth: 2
+//
+// Configs only ever narrow a walk, and those of nested directories add to
+// those above them:
+//
+//   - Exclude and ExcludeDirs add to the walk's own exclusions and those of
+//     every config above: an entry is skipped if any of them matches it.
+//   - MaxDepth counts levels below the config's directory, and the tightest
+//     of it, the limits of the configs above and Filter.MaxDepth applies.
+//   - Symlinks is taken from the nearest config setting it, but a subtree
+//     can only follow links if the walk does: "follow" restores the walk's
+//     own handling beneath a config that stopped following.
+//
+// A config that cannot be read or parsed is an error for its directory,
+// handled according to the walk's error handling: ErrorHandlingStop stops
+// the walk, ErrorHandlingSkip skips the directory, and ErrorHandlingContinue
+// walks it with the configs above it alone.
+type DirConfig struct {
+	Exclude     []string `yaml:"exclude"`      // Globs for names of files to skip, like Filter.ExcludePattern
+	ExcludeDirs []string `yaml:"exclude_dirs"` // Globs for names of directories to skip with their contents
+	Symlinks    string   `yaml:"symlinks"`     // "follow", "report" or "ignore"; empty to inherit
+	MaxDepth    int      `yaml:"max_depth"`    // Levels below this directory to walk; 0 for no limit
+}
+
+// dirConfigFile returns the name of the files holding directory configs, or
+// "" if they are disabled.
+func (o WalkOptions) dirConfigFile() string {
+	if !o.EnableDirConfigs {
+		return ""
+	}
+	if o.DirConfigName != "" {
+		return o.DirConfigName
+	}
+	return DefaultDirConfigName
+}
+
+// readDirConfig reads the config of dir from the file name in it. It returns
+// nil, and no error, if there is none.
+func readDirConfig(dir, name string) (*DirConfig, error) {
+	file := filepath.Join(dir, name)
+	f, err := os.Open(file)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("directory config: %w", err)
+	}
+	defer f.Close()
+
+	cfg := &DirConfig{}
+	dec := yaml.NewDecoder(f)
+	dec.KnownFields(true)
+	if err := dec.Decode(cfg); err != nil && err != io.EOF {
+		return nil, fmt.Errorf("directory config %s: %w", file, err)
+	}
+	if err := cfg.validate(); err != nil {
+		return nil, fmt.Errorf("directory config %s: %w", file, err)
+	}
+	return cfg, nil
+}
+
+// validate checks the patterns and values of c.
+func (c *DirConfig) validate() error {
+	for _, pattern := range append(append([]string(nil), c.Exclude...), c.ExcludeDirs...) {
+		if _, err := filepath.Match(pattern, ""); err != nil {
+			return fmt.Errorf("invalid pattern %q: %w", pattern, err)
+		}
+	}
+	switch c.Symlinks {
+	case "", "follow", "report", "ignore":
+	default:
+		return fmt.Errorf("invalid symlinks %q: must be follow, report or ignore", c.Symlinks)
+	}
+	if c.MaxDepth < 0 {
+		return fmt.Errorf("invalid max_depth %d", c.MaxDepth)
+	}
+	return nil
+}
+
+// dirScope is what the configs of a directory and those above it make of
+// the options for the entries in it. A nil *dirScope changes nothing.
+type dirScope struct {
+	exclude     []string
+	excludeDirs []string
+	symlinks    string // Of the nearest config setting it
+	maxDepth    int    // Deepest level walked, counted from the root; 0 for no limit
+}
+
+// merge returns the scope beneath a directory at depth whose config is cfg,
+// within the scope s of the directory holding it.
+func (s *dirScope) merge(cfg *DirConfig, depth int) *dirScope {
+	merged := &dirScope{}
+	if s != nil {
+		*merged = *s
+	}
+	// Appending to a full slice copies it, so sibling scopes never share
+	merged.exclude = append(merged.exclude[:len(merged.exclude):len(merged.exclude)], cfg.Exclude...)
+	merged.excludeDirs = append(merged.excludeDirs[:len(merged.excludeDirs):len(merged.excludeDirs)], cfg.ExcludeDirs...)
+	if cfg.Symlinks != "" {
+		merged.symlinks = cfg.Symlinks
+	}
+	if cfg.MaxDepth > 0 {
+		if limit := depth + cfg.MaxDepth; merged.maxDepth == 0 || limit < merged.maxDepth {
+			merged.maxDepth = limit
+		}
+	}
+	return merged
+}
+
+// skips reports whether the scope excludes an entry at depth.
+func (s *dirScope) skips(info os.FileInfo, depth int) bool {
+	if s == nil {
+		return false
+	}
+	if s.maxDepth > 0 && depth > s.maxDepth {
+		return true
+	}
+	patterns := s.exclude
+	if info.IsDir() {
+		patterns = s.excludeDirs
+	}
+	for _, pattern := range patterns {
+		if matched, _ := filepath.Match(pattern, info.Name()); matched {
+			return true
+		}
+	}
+	return false
+}
+
+// symlinkHandling returns how the scope handles links in a walk handling
+// them as walkLinks.
+func (s *dirScope) symlinkHandling(walkLinks SymlinkHandling) SymlinkHandling {
+	if s == nil {
+		return walkLinks
+	}
+	switch s.symlinks {
+	case "ignore":
+		return SymlinkIgnore
+	case "report":
+		if walkLinks != SymlinkIgnore {
+			return SymlinkReport
+		}
+	}
+	return walkLinks
+}
+
+// link returns the link's own info if path is a symbolic link the scope
+// handles differently from the walk, along with the scope's handling, or
+// nil. Links the walk followed are delivered with their target's info, so
+// only then is path lstat'ed.
+func (s *dirScope) link(path string, info os.FileInfo, walkLinks SymlinkHandling) (os.FileInfo, SymlinkHandling) {
+	links := s.symlinkHandling(walkLinks)
+	if links == walkLinks {
+		return nil, walkLinks
+	}
+	if info.Mode()&os.ModeSymlink != 0 {
+		return info, links
+	}
+	if walkLinks == SymlinkFollow || walkLinks == SymlinkFollowInternal {
+		if li, err := os.Lstat(path); err == nil && li.Mode()&os.ModeSymlink != 0 {
+			return li, links
+		}
+	}
+	return nil, walkLinks
+}
+
+// dirConfigs tracks the scopes of the directories of a walk, keyed by path,
+// as the walk reaches them. A directory's scope is recorded before any of
+// its entries are delivered, so workers can look it up for their files.
+type dirConfigs struct {
+	name   string
+	scopes sync.Map // Directory path -> *dirScope
+}
+
+// newDirConfigs returns the tracker for a walk with opts, or nil if
+// directory configs are disabled.
+func newDirConfigs(opts WalkOptions) *dirConfigs {
+	name := opts.dirConfigFile()
+	if name == "" {
+		return nil
+	}
+	return &dirConfigs{name: name}
+}
+
+// scopeOf returns the scope of the entries in dir.
+func (c *dirConfigs) scopeOf(dir string) *dirScope {
+	if v, ok := c.scopes.Load(dir); ok {
+		return v.(*dirScope)
+	}
+	return nil
+}
+
+// enter reads the config of dir, at depth, and records the scope of its
+// entries within parent, the scope of dir itself. If the config is invalid,
+// dir keeps parent and the error is returned.
+func (c *dirConfigs) enter(dir string, parent *dirScope, depth int) error {
+	cfg, err := readDirConfig(dir, c.name)
+	scope := parent
+	if cfg != nil {
+		scope = parent.merge(cfg, depth)
+	}
+	c.scopes.Store(dir, scope)
+	return err
+}
@@ -0,0 +1,163 @@
+package stride
+
+import (
+	"context"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/TFMV/stride/walk/walktest"
+)
+
+// dirConfigPaths walks root with opts and returns the entries delivered,
+// directories ending in "/".
+func dirConfigPaths(t *testing.T, root string, opts WalkOptions) ([]string, Stats, error) {
+	t.Helper()
+	rec := &pathRecorder{root: root}
+	stats, err := WalkLimitWithOptionsStats(context.Background(), root, func(path string, info os.FileInfo, err error) error {
+		if err == nil {
+			rec.add(path, info.IsDir())
+		}
+		return err
+	}, opts)
+	return rec.sorted(), stats, err
+}
+
+func TestDirConfigExclude(t *testing.T) {
+	root := walktest.Tree{
+		"top.log":              walktest.File{},
+		"a/.strideconfig":      walktest.File{Content: "exclude: [\"*.log\"]\n"},
+		"a/keep.txt":           walktest.File{},
+		"a/x.log":              walktest.File{},
+		"a/sub/y.log":          walktest.File{},
+		"b/z.log":              walktest.File{},
+		"c/.strideconfig":      walktest.File{Content: "exclude_dirs: [cache]\n"},
+		"c/cache/hidden.txt":   walktest.File{},
+		"c/d/cache/hidden.txt": walktest.File{},
+		"c/d/shown.txt":        walktest.File{},
+	}.Build(t)
+
+	got, _, err := dirConfigPaths(t, root, WalkOptions{NumWorkers: 4, EnableDirConfigs: true})
+	if err != nil {
+		t.Fatalf("Walk failed: %v", err)
+	}
+	// Logs are hidden only beneath a, cache directories only beneath c
+	expected := []string{
+		"./", "a/", "a/.strideconfig", "a/keep.txt", "a/sub/",
+		"b/", "b/z.log",
+		"c/", "c/.strideconfig", "c/d/", "c/d/shown.txt",
+		"top.log",
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("Expected %v, got %v", expected, got)
+	}
+
+	// Configs are read only when enabled
+	got, _, err = dirConfigPaths(t, root, WalkOptions{NumWorkers: 4})
+	if err != nil {
+		t.Fatalf("Walk failed: %v", err)
+	}
+	if len(got) != 18 {
+		t.Errorf("Expected every entry without configs, got %v", got)
+	}
+}
+
+func TestDirConfigPrecedence(t *testing.T) {
+	root := walktest.Tree{
+		".stride.yaml":          walktest.File{Content: "exclude: [\"*.tmp\"]\nmax_depth: 3\n"},
+		"a.tmp":                 walktest.File{},
+		"x/.stride.yaml":        walktest.File{Content: "exclude: [\"*.bak\"]\nmax_depth: 1\n"},
+		"x/1.bak":               walktest.File{},
+		"x/1.tmp":               walktest.File{},
+		"x/1.txt":               walktest.File{},
+		"x/y/2.txt":             walktest.File{},
+		"z/.stride.yaml":        walktest.File{Content: "max_depth: 5\n"},
+		"z/1/2/3.txt":           walktest.File{},
+		"z/1/2/3/4.txt":         walktest.File{},
+		"links/.stride.yaml":    walktest.File{Content: "symlinks: report\n"},
+		"links/dir":             walktest.Symlink{Target: "../z"},
+		"links/in/.stride.yaml": walktest.File{Content: "symlinks: follow\n"},
+		"links/in/dir":          walktest.Symlink{Target: "../../z/1"},
+	}.Build(t)
+
+	// Revisits keep the links from hiding the directories they lead to
+	opts := WalkOptions{NumWorkers: 4, EnableDirConfigs: true, DirConfigName: ".stride.yaml", AllowRevisit: true}
+	got, _, err := dirConfigPaths(t, root, opts)
+	if err != nil {
+		t.Fatalf("Walk failed: %v", err)
+	}
+	// Exclusions add up, the tightest depth limit applies, and the nearest
+	// symlinks setting wins
+	expected := []string{
+		"./", ".stride.yaml",
+		"links/", "links/.stride.yaml", "links/dir",
+		"links/in/", "links/in/.stride.yaml", "links/in/dir/",
+		"x/", "x/.stride.yaml", "x/1.txt", "x/y/",
+		"z/", "z/.stride.yaml", "z/1/", "z/1/2/",
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("Expected %v, got %v", expected, got)
+	}
+
+	// A config cannot make a walk follow links it would not
+	opts.SymlinkHandling = SymlinkReport
+	got, _, err = dirConfigPaths(t, root, opts)
+	if err != nil {
+		t.Fatalf("Walk failed: %v", err)
+	}
+	if !contains(got, "links/in/dir") || contains(got, "links/in/dir/") {
+		t.Errorf("Expected links/in/dir reported, not followed, got %v", got)
+	}
+}
+
+func TestDirConfigErrors(t *testing.T) {
+	root := walktest.Tree{
+		".strideconfig":         walktest.File{Content: "exclude: [\"*.log\"]\n"},
+		"bad/.strideconfig":     walktest.File{Content: "exclude: *.log\nunknown: true\n"},
+		"bad/a.log":             walktest.File{},
+		"bad/a.txt":             walktest.File{},
+		"good/.strideconfig":    walktest.File{Content: "max_depth: 0\n"},
+		"good/b.log":            walktest.File{},
+		"good/b.txt":            walktest.File{},
+		"invalid/.strideconfig": walktest.File{Content: "symlinks: sometimes\n"},
+	}.Build(t)
+
+	tests := []struct {
+		name     string
+		mode     ErrorHandling
+		contains []string
+		missing  []string
+		errs     int64
+		fails    bool
+	}{
+		// The directory keeps the configs above it
+		{"continue", ErrorHandlingContinue, []string{"bad/a.txt", "good/b.txt", "invalid/"}, []string{"bad/a.log", "good/b.log"}, 2, false},
+		{"skip", ErrorHandlingSkip, []string{"good/b.txt"}, []string{"bad/", "invalid/"}, 2, false},
+		{"stop", ErrorHandlingStop, nil, nil, 1, true},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got, stats, err := dirConfigPaths(t, root, WalkOptions{NumWorkers: 1, EnableDirConfigs: true, ErrorHandling: tc.mode})
+			if (err != nil) != tc.fails {
+				t.Fatalf("Expected the walk to fail: %v, got %v", tc.fails, err)
+			}
+			if tc.fails && !strings.Contains(err.Error(), ".strideconfig") {
+				t.Errorf("Expected the error to name the config, got %v", err)
+			}
+			if tc.fails && stats.ErrorCount < 1 || !tc.fails && stats.ErrorCount != tc.errs {
+				t.Errorf("Expected %d errors, got %d", tc.errs, stats.ErrorCount)
+			}
+			for _, p := range tc.contains {
+				if !contains(got, p) {
+					t.Errorf("Expected %s walked, got %v", p, got)
+				}
+			}
+			for _, p := range tc.missing {
+				if contains(got, p) {
+					t.Errorf("Expected %s not walked, got %v", p, got)
+				}
+			}
+		})
+	}
+}
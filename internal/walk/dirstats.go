@@ -0,0 +1,109 @@
+package stride
+
+import (
+	"sort"
+	"strings"
+)
+
+// Defaults for the directory statistics of a storage report
+const (
+	DefaultDirStatsDepth = 3
+	DefaultDirStatsTopK  = 25
+)
+
+// DirStat holds the bytes and files beneath a directory.
+type DirStat struct {
+	Path           string `json:"path"`            // Slash-separated path relative to the root, "." for the root
+	RecursiveBytes int64  `json:"recursive_bytes"` // Bytes in files at any depth beneath the directory
+	RecursiveFiles int    `json:"recursive_files"` // Files at any depth beneath the directory
+	DirectBytes    int64  `json:"direct_bytes"`    // Bytes in files directly in the directory
+	DirectFiles    int    `json:"direct_files"`    // Files directly in the directory
+}
+
+// dirStatsCollector rolls file sizes up into the directories at most depth
+// levels below the root while the tree is walked.
+type dirStatsCollector struct {
+	depth int
+	dirs  map[string]*DirStat
+}
+
+// newDirStatsCollector creates a collector for directories down to depth.
+func newDirStatsCollector(depth int) *dirStatsCollector {
+	return &dirStatsCollector{depth: depth, dirs: make(map[string]*DirStat)}
+}
+
+// get returns the stats of the directory rel, creating them if needed.
+func (c *dirStatsCollector) get(rel string) *DirStat {
+	d, ok := c.dirs[rel]
+	if !ok {
+		d = &DirStat{Path: rel}
+		c.dirs[rel] = d
+	}
+	return d
+}
+
+// within reports whether the directory rel is within the depth bound.
+func (c *dirStatsCollector) within(rel string) bool {
+	return rel == "." || strings.Count(rel, "/") < c.depth
+}
+
+// addDir records the directory rel, so that it is listed even if it holds
+// no files.
+func (c *dirStatsCollector) addDir(rel string) {
+	if c.within(rel) {
+		c.get(rel)
+	}
+}
+
+// addFile attributes a file of size bytes at the slash-separated path rel
+// to its directory and to each of its ancestors within the depth bound.
+func (c *dirStatsCollector) addFile(rel string, size int64) {
+	dir := "."
+	if i := strings.LastIndexByte(rel, '/'); i >= 0 {
+		dir = rel[:i]
+	}
+
+	// The root and each ancestor within the depth bound, outermost first
+	c.get(".").addRecursive(size)
+	for depth, end := 1, 0; dir != "." && depth <= c.depth; depth++ {
+		next := strings.IndexByte(dir[end:], '/')
+		if next < 0 {
+			c.get(dir).addRecursive(size)
+			break
+		}
+		end += next
+		c.get(dir[:end]).addRecursive(size)
+		end++
+	}
+
+	if c.within(dir) {
+		d := c.get(dir)
+		d.DirectBytes += size
+		d.DirectFiles++
+	}
+}
+
+// addRecursive counts a file of size bytes beneath d.
+func (d *DirStat) addRecursive(size int64) {
+	d.RecursiveBytes += size
+	d.RecursiveFiles++
+}
+
+// top returns the k directories holding the most bytes, or all of them if k
+// is not positive. Ties are broken by path.
+func (c *dirStatsCollector) top(k int) []DirStat {
+	stats := make([]DirStat, 0, len(c.dirs))
+	for _, d := range c.dirs {
+		stats = append(stats, *d)
+	}
+	sort.Slice(stats, func(i, j int) bool {
+		if stats[i].RecursiveBytes != stats[j].RecursiveBytes {
+			return stats[i].RecursiveBytes > stats[j].RecursiveBytes
+		}
+		return stats[i].Path < stats[j].Path
+	})
+	if k > 0 && len(stats) > k {
+		stats = stats[:k]
+	}
+	return stats
+}
@@ -0,0 +1,92 @@
+package stride
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestDirectoryStats(t *testing.T) {
+	root := t.TempDir()
+	sizes := map[string]int{
+		"a.txt":     10,
+		"x/b":       100,
+		"x/y/c":     1000,
+		"x/y/z/d":   10000,
+		"w/e":       5,
+		"empty/sub": -1, // A directory
+	}
+	for name, size := range sizes {
+		path := filepath.Join(root, filepath.FromSlash(name))
+		if size < 0 {
+			if err := os.MkdirAll(path, 0755); err != nil {
+				t.Fatalf("Failed to create directory: %v", err)
+			}
+			continue
+		}
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("Failed to create directory: %v", err)
+		}
+		if err := os.WriteFile(path, make([]byte, size), 0644); err != nil {
+			t.Fatalf("Failed to create file: %v", err)
+		}
+	}
+
+	tests := []struct {
+		depth, topK int
+		want        []DirStat
+	}{
+		{1, 0, []DirStat{
+			{Path: ".", RecursiveBytes: 11115, RecursiveFiles: 5, DirectBytes: 10, DirectFiles: 1},
+			{Path: "x", RecursiveBytes: 11100, RecursiveFiles: 3, DirectBytes: 100, DirectFiles: 1},
+			{Path: "w", RecursiveBytes: 5, RecursiveFiles: 1, DirectBytes: 5, DirectFiles: 1},
+			{Path: "empty"},
+		}},
+		{2, 0, []DirStat{
+			{Path: ".", RecursiveBytes: 11115, RecursiveFiles: 5, DirectBytes: 10, DirectFiles: 1},
+			{Path: "x", RecursiveBytes: 11100, RecursiveFiles: 3, DirectBytes: 100, DirectFiles: 1},
+			{Path: "x/y", RecursiveBytes: 11000, RecursiveFiles: 2, DirectBytes: 1000, DirectFiles: 1},
+			{Path: "w", RecursiveBytes: 5, RecursiveFiles: 1, DirectBytes: 5, DirectFiles: 1},
+			{Path: "empty"},
+			{Path: "empty/sub"},
+		}},
+		{2, 2, []DirStat{
+			{Path: ".", RecursiveBytes: 11115, RecursiveFiles: 5, DirectBytes: 10, DirectFiles: 1},
+			{Path: "x", RecursiveBytes: 11100, RecursiveFiles: 3, DirectBytes: 100, DirectFiles: 1},
+		}},
+	}
+	for _, tc := range tests {
+		analyzer := NewAnalyzer()
+		analyzer.EnableStorageReport()
+		analyzer.SetDirStatsDepth(tc.depth)
+		analyzer.SetDirStatsTopK(tc.topK)
+		result, err := analyzer.Analyze(root)
+		if err != nil {
+			t.Fatalf("Analysis failed: %v", err)
+		}
+		if got := result.StorageReport.DirectoryStats; !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("Depth %d, top %d: expected %+v, got %+v", tc.depth, tc.topK, tc.want, got)
+		}
+	}
+
+	// Both outputs include the section
+	analyzer := NewAnalyzer()
+	analyzer.EnableStorageReport()
+	result, err := analyzer.Analyze(root)
+	if err != nil {
+		t.Fatalf("Analysis failed: %v", err)
+	}
+	if text := result.String(); !strings.Contains(text, "Largest Directories:\n  .: 11115 bytes in 5 files (10 bytes in 1 files directly)\n") {
+		t.Errorf("Expected the largest directories in the text report, got:\n%s", text)
+	}
+	data, err := json.Marshal(result)
+	if err != nil {
+		t.Fatalf("Failed to marshal result: %v", err)
+	}
+	if !strings.Contains(string(data), `"directory_stats":[{"path":".","recursive_bytes":11115`) {
+		t.Errorf("Expected the directory stats in JSON, got %s", data)
+	}
+}
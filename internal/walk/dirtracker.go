@@ -0,0 +1,293 @@
+package stride
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// dirTracker maintains the EmptyDirs and FilelessDirs counters from the order
+// in which a depth-first walk enumerates entries, so no directory is read
+// twice. A directory is finalized as soon as the walk leaves it.
+//
+// A tracker is only used from the goroutine driving the walk; the counters
+// themselves are updated atomically for concurrent progress readers. If
+// fanout is set, the direct entries of each directory are reported to it as
+// the directory is finalized.
+//
+// If detectChanges is set, each directory entered with enterEntry is stat'ed
+// again as it is finalized, and listed in modified if its modification time
+// moved while the walk was inside it.
+//
+// If breadthFirst is set, the walk is a breadth-first one, which reports each
+// directory whose entries it has enumerated to listed. Directories are then
+// kept open by path instead of on the stack, and finalized once they are
+// listed and all of their subdirectories are finalized.
+type dirTracker struct {
+	stats  *Stats
+	stack  []dirFrame
+	fanout *fanoutCollector
+
+	breadthFirst bool
+	open         map[string]*dirFrame // Directories not finalized, when breadthFirst
+
+	detectChanges bool
+	modified      []string
+}
+
+// dirFrame holds the counts for a directory the walk is still inside.
+type dirFrame struct {
+	path    string
+	entries int  // Direct entries enumerated
+	dirs    int  // Direct entries that are directories
+	hasFile bool // Whether a non-directory exists at any depth
+	skipped bool // Whether the contents were not enumerated
+
+	modTime time.Time // Modification time when entered, if it is checked
+
+	// Breadth first only
+	parent  *dirFrame
+	listed  bool // Whether all direct entries were enumerated
+	pending int  // Listing and subdirectories not finalized yet
+}
+
+// newDirTracker creates a tracker that updates stats.
+func newDirTracker(stats *Stats) *dirTracker {
+	return &dirTracker{stats: stats}
+}
+
+// enter records an entry enumerated by the walk. A nil tracker ignores it.
+func (t *dirTracker) enter(path string, isDir bool) {
+	if t == nil {
+		return
+	}
+	path = filepath.Clean(path)
+	if t.breadthFirst {
+		t.enterLevel(path, isDir)
+		return
+	}
+	for len(t.stack) > 0 && !isWithinDir(t.stack[len(t.stack)-1].path, path) {
+		t.pop()
+	}
+	if n := len(t.stack); n > 0 {
+		t.stack[n-1].entries++
+		if isDir {
+			t.stack[n-1].dirs++
+		} else {
+			t.stack[n-1].hasFile = true
+		}
+	}
+	if isDir {
+		t.stack = append(t.stack, dirFrame{path: path})
+	}
+}
+
+// enterEntry records an entry enumerated by the walk, described by info. A
+// nil tracker ignores it.
+func (t *dirTracker) enterEntry(path string, info os.FileInfo) {
+	if t == nil {
+		return
+	}
+	t.enter(path, info.IsDir())
+	if t.detectChanges && info.IsDir() {
+		if t.breadthFirst {
+			t.open[filepath.Clean(path)].modTime = info.ModTime()
+		} else {
+			t.stack[len(t.stack)-1].modTime = info.ModTime()
+		}
+	}
+}
+
+// enterLevel is enter for a breadth-first walk.
+func (t *dirTracker) enterLevel(path string, isDir bool) {
+	parent := t.open[filepath.Dir(path)]
+	if parent != nil {
+		parent.entries++
+		if isDir {
+			parent.dirs++
+		} else {
+			parent.hasFile = true
+		}
+	}
+	if !isDir {
+		return
+	}
+
+	// A directory entered again is finalized first, as it is depth first
+	t.listed(path)
+	f := &dirFrame{path: path, parent: parent, pending: 1}
+	if parent != nil {
+		parent.pending++
+	}
+	if t.open == nil {
+		t.open = make(map[string]*dirFrame)
+	}
+	t.open[path] = f
+}
+
+// listed records that a breadth-first walk has enumerated every entry of the
+// directory at path, or skipped it. A nil tracker ignores it, as does a
+// depth-first one.
+func (t *dirTracker) listed(path string) {
+	if t == nil || !t.breadthFirst {
+		return
+	}
+	f := t.open[filepath.Clean(path)]
+	if f == nil || f.listed {
+		return
+	}
+	f.listed = true
+	for f != nil {
+		if f.pending--; f.pending > 0 {
+			return
+		}
+		if t.open[f.path] == f {
+			delete(t.open, f.path)
+		}
+		t.finalize(f, f.parent)
+		f = f.parent
+	}
+}
+
+// skip marks the directory at path as not enumerated, e.g. after SkipDir or
+// a read error. Skipped directories are never counted as empty.
+func (t *dirTracker) skip(path string) {
+	if t == nil {
+		return
+	}
+	if t.breadthFirst {
+		if f := t.open[filepath.Clean(path)]; f != nil {
+			f.skipped = true
+		}
+		return
+	}
+	if len(t.stack) == 0 {
+		return
+	}
+	if top := &t.stack[len(t.stack)-1]; top.path == filepath.Clean(path) {
+		top.skipped = true
+	}
+}
+
+// link records a symbolic link the walk followed, or skipped. A nil
+// tracker ignores it.
+func (t *dirTracker) link(followed bool) {
+	if t == nil {
+		return
+	}
+	if followed {
+		atomic.AddInt64(&t.stats.SymlinksFollowed, 1)
+	} else {
+		atomic.AddInt64(&t.stats.SymlinksSkipped, 1)
+	}
+}
+
+// finish finalizes every directory still open.
+func (t *dirTracker) finish() {
+	if t == nil {
+		return
+	}
+	for len(t.stack) > 0 {
+		t.pop()
+	}
+	if len(t.open) > 0 {
+		paths := make([]string, 0, len(t.open))
+		for path := range t.open {
+			paths = append(paths, path)
+		}
+		sort.Strings(paths)
+		for _, path := range paths {
+			t.listed(path)
+		}
+	}
+}
+
+// pop finalizes the innermost open directory.
+func (t *dirTracker) pop() {
+	n := len(t.stack)
+	f := t.stack[n-1]
+	t.stack = t.stack[:n-1]
+	var parent *dirFrame
+	if n > 1 {
+		parent = &t.stack[n-2]
+	}
+	t.finalize(&f, parent)
+}
+
+// finalize counts the directory f, whose enclosing directory is parent,
+// unless it has none.
+func (t *dirTracker) finalize(f, parent *dirFrame) {
+
+	// Contents of a skipped directory are unknown, so assume it holds files
+	// rather than report its ancestors as file-less.
+	if f.skipped {
+		f.hasFile = true
+	} else {
+		if f.entries == 0 {
+			atomic.AddInt64(&t.stats.EmptyDirs, 1)
+		}
+		if !f.hasFile {
+			atomic.AddInt64(&t.stats.FilelessDirs, 1)
+		}
+		t.fanout.record(f.path, f.entries-f.dirs, f.dirs)
+	}
+	if f.hasFile && parent != nil {
+		parent.hasFile = true
+	}
+	if !f.modTime.IsZero() && !f.skipped {
+		if info, err := os.Stat(f.path); err != nil || !info.ModTime().Equal(f.modTime) {
+			t.modified = append(t.modified, f.path)
+			atomic.AddInt64(&t.stats.DirsModifiedDuringWalk, 1)
+		}
+	}
+}
+
+// modifiedDirs returns the directories found modified, sorted.
+func (t *dirTracker) modifiedDirs() []string {
+	if t == nil || len(t.modified) == 0 {
+		return nil
+	}
+	dirs := append([]string(nil), t.modified...)
+	sort.Strings(dirs)
+	return dirs
+}
+
+// retryModified walks each directory found modified once more with walk,
+// passing a tracker of its own, and keeps only the directories modified
+// again. A directory beneath another one retried is not walked twice. If a
+// walk fails, the directories found modified are left as they are.
+func (t *dirTracker) retryModified(walk func(dir string, tracker *dirTracker) error) error {
+	if t == nil {
+		return nil
+	}
+	var still []string
+	var retried string
+	for _, dir := range t.modifiedDirs() {
+		if retried != "" && isWithinDir(retried, dir) {
+			continue
+		}
+		retried = dir
+		retry := &dirTracker{stats: &Stats{}, detectChanges: true, breadthFirst: t.breadthFirst}
+		if err := walk(dir, retry); err != nil {
+			return err
+		}
+		still = append(still, retry.modified...)
+	}
+	t.modified = still
+	atomic.StoreInt64(&t.stats.DirsModifiedDuringWalk, int64(len(still)))
+	return nil
+}
+
+// isWithinDir reports whether the cleaned path lies below the cleaned dir.
+func isWithinDir(dir, path string) bool {
+	if dir == "." {
+		return path != "." && !filepath.IsAbs(path) && path != ".." && !strings.HasPrefix(path, ".."+string(os.PathSeparator))
+	}
+	if !strings.HasSuffix(dir, string(os.PathSeparator)) {
+		dir += string(os.PathSeparator)
+	}
+	return len(path) > len(dir) && strings.HasPrefix(path, dir)
+}
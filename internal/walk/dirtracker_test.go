@@ -0,0 +1,288 @@
+package stride
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/TFMV/stride/walk/walktest"
+)
+
+// createEmptyDirFixture builds empty, directory-only and file-bearing directories:
+//
+//	root/empty/
+//	root/dironly/inner/
+//	root/files/a.txt
+//	root/files/sub/
+func createEmptyDirFixture(t testing.TB) string {
+	root := t.TempDir()
+	for _, dir := range []string{"empty", "dironly/inner", "files/sub"} {
+		if err := os.MkdirAll(filepath.Join(root, filepath.FromSlash(dir)), 0755); err != nil {
+			t.Fatalf("Failed to create directory: %v", err)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(root, "files", "a.txt"), []byte("a"), 0644); err != nil {
+		t.Fatalf("Failed to create file: %v", err)
+	}
+	return root
+}
+
+func TestEmptyDirAccounting(t *testing.T) {
+	root := createEmptyDirFixture(t)
+	noop := func(path string, info os.FileInfo, err error) error { return err }
+
+	check := func(t *testing.T, stats Stats) {
+		t.Helper()
+		if stats.DirsProcessed != 6 {
+			t.Errorf("Expected 6 directories, got %d", stats.DirsProcessed)
+		}
+		// empty, dironly/inner and files/sub have no entries
+		if stats.EmptyDirs != 3 {
+			t.Errorf("Expected 3 empty directories, got %d", stats.EmptyDirs)
+		}
+		// dironly has entries but no files at any depth
+		if stats.FilelessDirs != 4 {
+			t.Errorf("Expected 4 file-less directories, got %d", stats.FilelessDirs)
+		}
+	}
+
+	t.Run("WalkLimitWithOptions", func(t *testing.T) {
+		var mu sync.Mutex
+		var last Stats
+		opts := WalkOptions{Progress: func(stats Stats) {
+			mu.Lock()
+			last = stats
+			mu.Unlock()
+		}}
+		if err := WalkLimitWithOptions(context.Background(), root, noop, opts); err != nil {
+			t.Fatalf("Walk failed: %v", err)
+		}
+		check(t, last)
+	})
+
+	t.Run("Root with trailing separator", func(t *testing.T) {
+		var mu sync.Mutex
+		var last Stats
+		opts := WalkOptions{Progress: func(stats Stats) {
+			mu.Lock()
+			last = stats
+			mu.Unlock()
+		}}
+		if err := WalkLimitWithOptions(context.Background(), root+string(os.PathSeparator), noop, opts); err != nil {
+			t.Fatalf("Walk failed: %v", err)
+		}
+		check(t, last)
+	})
+
+	t.Run("Breadth first", func(t *testing.T) {
+		var mu sync.Mutex
+		var last Stats
+		opts := WalkOptions{
+			TraversalOrder: BreadthFirst,
+			Progress: func(stats Stats) {
+				mu.Lock()
+				last = stats
+				mu.Unlock()
+			},
+		}
+		if err := WalkLimitWithOptions(context.Background(), root, noop, opts); err != nil {
+			t.Fatalf("Walk failed: %v", err)
+		}
+		check(t, last)
+	})
+
+	t.Run("Skipped directories are not empty", func(t *testing.T) {
+		var mu sync.Mutex
+		var last Stats
+		opts := WalkOptions{
+			Filter: FilterOptions{ExcludeDir: []string{"dironly"}},
+			Progress: func(stats Stats) {
+				mu.Lock()
+				last = stats
+				mu.Unlock()
+			},
+		}
+		if err := WalkLimitWithOptions(context.Background(), root, noop, opts); err != nil {
+			t.Fatalf("Walk failed: %v", err)
+		}
+		if last.EmptyDirs != 2 {
+			t.Errorf("Expected 2 empty directories, got %d", last.EmptyDirs)
+		}
+	})
+}
+
+func TestDirTracker(t *testing.T) {
+	stats := &Stats{}
+	tracker := newDirTracker(stats)
+	for _, entry := range []struct {
+		path  string
+		isDir bool
+	}{
+		{"root", true},
+		{"root/a", true},
+		{"root/a/b", true},
+		{"root/ab", true},
+		{"root/ab/file", false},
+		{"root/c", true},
+	} {
+		tracker.enter(entry.path, entry.isDir)
+	}
+	tracker.finish()
+
+	// "root/ab" must not be treated as inside "root/a"
+	if stats.EmptyDirs != 2 {
+		t.Errorf("Expected 2 empty directories, got %d", stats.EmptyDirs)
+	}
+	if stats.FilelessDirs != 3 {
+		t.Errorf("Expected 3 file-less directories, got %d", stats.FilelessDirs)
+	}
+}
+
+func TestDirTrackerBreadthFirst(t *testing.T) {
+	stats := &Stats{}
+	tracker := newDirTracker(stats)
+	tracker.breadthFirst = true
+	for _, step := range []struct {
+		path   string
+		isDir  bool
+		listed bool
+	}{
+		{"root", true, false},
+		{"root/a", true, false},
+		{"root/ab", true, false},
+		{"root/c", true, false},
+		{"root", false, true},
+		{"root/a/b", true, false},
+		{"root/a", false, true},
+		{"root/ab/file", false, false},
+		{"root/ab", false, true},
+		{"root/c", false, true},
+	} {
+		if step.listed {
+			tracker.listed(step.path)
+		} else {
+			tracker.enter(step.path, step.isDir)
+		}
+	}
+	tracker.finish()
+
+	// Same tree as TestDirTracker; root/a/b is only finalized by finish
+	if stats.EmptyDirs != 2 {
+		t.Errorf("Expected 2 empty directories, got %d", stats.EmptyDirs)
+	}
+	if stats.FilelessDirs != 3 {
+		t.Errorf("Expected 3 file-less directories, got %d", stats.FilelessDirs)
+	}
+}
+
+// BenchmarkEmptyDirAccounting measures a progress-enabled walk, which used to
+// re-read every directory to maintain the EmptyDirs counter.
+func TestDetectConcurrentModification(t *testing.T) {
+	// Directories are dated in the past, so that a change is always seen
+	old := time.Now().Add(-time.Hour).Truncate(time.Second)
+	tree := walktest.Tree{
+		"busy/":         walktest.Dir{ModTime: old},
+		"busy/a.txt":    walktest.File{Content: "a"},
+		"busy/sub/":     walktest.Dir{ModTime: old},
+		"busy/sub/b.go": walktest.File{Content: "b"},
+		"quiet/":        walktest.Dir{ModTime: old},
+		"quiet/c.txt":   walktest.File{Content: "c"},
+	}
+
+	tests := []struct {
+		name     string
+		mutate   bool
+		retry    bool
+		modified []string
+		files    int
+	}{
+		{name: "untouched", files: 3},
+		{name: "mutated", mutate: true, modified: []string{"busy"}, files: 4},
+		{name: "retried", mutate: true, retry: true, files: 7},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			root := tree.Build(t)
+			busy := filepath.Join(root, "busy")
+
+			// Entering busy adds a file to it from another goroutine, once
+			var once sync.Once
+			var mu sync.Mutex
+			files := 0
+			stats, err := WalkLimitWithOptionsStats(context.Background(), root, func(path string, info os.FileInfo, err error) error {
+				if err != nil {
+					return err
+				}
+				if info.IsDir() {
+					if tt.mutate && path == busy {
+						once.Do(func() {
+							done := make(chan error)
+							go func() { done <- os.WriteFile(filepath.Join(busy, "new.txt"), nil, 0644) }()
+							if err := <-done; err != nil {
+								t.Errorf("Failed to create file: %v", err)
+							}
+						})
+					}
+					return nil
+				}
+				mu.Lock()
+				files++
+				mu.Unlock()
+				return nil
+			}, WalkOptions{DetectConcurrentModification: true, ReEnumerateOnChange: tt.retry})
+			if err != nil {
+				t.Fatalf("Failed to walk: %v", err)
+			}
+
+			var modified []string
+			for _, dir := range stats.ModifiedDuringWalk {
+				modified = append(modified, relSlashPath(root, dir))
+			}
+			if !reflect.DeepEqual(modified, tt.modified) {
+				t.Errorf("Expected %v modified during the walk, got %v", tt.modified, modified)
+			}
+			if stats.DirsModifiedDuringWalk != int64(len(tt.modified)) {
+				t.Errorf("Expected %d directories counted, got %d", len(tt.modified), stats.DirsModifiedDuringWalk)
+			}
+			// A retried directory delivers its files again, including the new one
+			if files != tt.files {
+				t.Errorf("Expected %d files delivered, got %d", tt.files, files)
+			}
+		})
+	}
+
+	// Detection is off by default
+	root := tree.Build(t)
+	stats, err := WalkLimitWithOptionsStats(context.Background(), root, func(path string, info os.FileInfo, err error) error {
+		if info != nil && info.IsDir() && filepath.Base(path) == "quiet" {
+			return os.WriteFile(filepath.Join(path, "new.txt"), nil, 0644)
+		}
+		return err
+	}, WalkOptions{})
+	if err != nil {
+		t.Fatalf("Failed to walk: %v", err)
+	}
+	if stats.DirsModifiedDuringWalk != 0 || stats.ModifiedDuringWalk != nil {
+		t.Errorf("Expected no detection without the option, got %+v", stats)
+	}
+}
+
+func BenchmarkEmptyDirAccounting(b *testing.B) {
+	tempDir := setupLargeTestDir(b)
+	opts := WalkOptions{
+		Progress:        func(stats Stats) {},
+		SymlinkHandling: SymlinkIgnore,
+	}
+	noop := func(path string, info os.FileInfo, err error) error { return err }
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if err := WalkLimitWithOptions(context.Background(), tempDir, noop, opts); err != nil {
+			b.Fatalf("Walk failed: %v", err)
+		}
+	}
+}
@@ -266,7 +266,7 @@ func TestCancelledContext(t *testing.T) {
 
 	elapsed := time.Since(start)
 
-	if err == nil || (err != context.Canceled && err.Error() != "context canceled") {
+	if !errors.Is(err, context.Canceled) || !errors.Is(err, ErrCanceledByUser) {
 		t.Errorf("Expected context.Canceled error, got %v", err)
 	}
 
@@ -0,0 +1,75 @@
+package stride
+
+import (
+	"context"
+	"iter"
+	"os"
+	"path/filepath"
+)
+
+// Entry is a file or directory produced by Entries.
+type Entry struct {
+	Path    string      // Path of the entry, beneath the root as given
+	RelPath string      // Path relative to the root, "." for the root itself
+	Info    os.FileInfo // Information about the entry
+	Depth   int         // Directory levels below the root, 0 for the root
+}
+
+// Entries returns an iterator over the tree rooted at root. The entries,
+// filters, symlink handling, statistics and progress reporting are those of
+// WalkLimitWithOptions with opts, and entries arrive in the order the walk's
+// callbacks run. If the walk fails, the last pair carries its error.
+//
+// Breaking out of a range loop over the iterator cancels the walk and
+// waits for its workers to stop before the loop statement completes.
+func Entries(ctx context.Context, root string, opts WalkOptions) iter.Seq2[Entry, error] {
+	return func(yield func(Entry, error) bool) {
+		if ctx == nil {
+			ctx = context.Background()
+		}
+		root, err := normalizeRoot(root)
+		if err != nil {
+			yield(Entry{}, err)
+			return
+		}
+
+		walkCtx, cancel := context.WithCancel(ctx)
+		defer cancel()
+
+		// The walk hands each entry to the loop body, one at a time
+		entries := make(chan Entry)
+		done := make(chan error, 1)
+		go func() {
+			defer close(entries)
+			done <- WalkLimitWithOptions(walkCtx, root, func(path string, info os.FileInfo, err error) error {
+				if err != nil {
+					return err
+				}
+				rel, err := filepath.Rel(root, path)
+				if err != nil {
+					rel = path
+				}
+				select {
+				case entries <- Entry{Path: path, RelPath: rel, Info: info, Depth: depthOf(root, path)}:
+					return nil
+				case <-walkCtx.Done():
+					return walkCtx.Err()
+				}
+			}, opts)
+		}()
+
+		for e := range entries {
+			if !yield(e, nil) {
+				// Stop the walk and let its callbacks return
+				cancel()
+				for range entries {
+				}
+				<-done
+				return
+			}
+		}
+		if err := <-done; err != nil {
+			yield(Entry{}, err)
+		}
+	}
+}
@@ -0,0 +1,184 @@
+package stride
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+
+	"go.uber.org/goleak"
+)
+
+// createEntriesFixture creates a tree of files across nested directories.
+func createEntriesFixture(t testing.TB) string {
+	t.Helper()
+	root := t.TempDir()
+	for _, name := range []string{"a.txt", "b.go", "dir/c.txt", "dir/sub/d.go", "dir/sub/e.txt", ".hidden/f.txt"} {
+		path := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("Failed to create directory: %v", err)
+		}
+		if err := os.WriteFile(path, []byte(name), 0644); err != nil {
+			t.Fatalf("Failed to create file: %v", err)
+		}
+	}
+	return root
+}
+
+func TestEntries(t *testing.T) {
+	defer goleak.VerifyNone(t)
+	root := createEntriesFixture(t)
+	opts := WalkOptions{
+		NumWorkers: 4,
+		Filter:     FilterOptions{Pattern: "*.txt", ExcludeDir: []string{".hidden"}},
+	}
+
+	// The callback API visits the same entries
+	var mu sync.Mutex
+	var want []string
+	err := WalkLimitWithOptions(context.Background(), root, func(path string, info os.FileInfo, err error) error {
+		mu.Lock()
+		defer mu.Unlock()
+		want = append(want, path)
+		return err
+	}, opts)
+	if err != nil {
+		t.Fatalf("WalkLimitWithOptions failed: %v", err)
+	}
+
+	var got []string
+	for entry, err := range Entries(context.Background(), root, opts) {
+		if err != nil {
+			t.Fatalf("Entries failed: %v", err)
+		}
+		got = append(got, entry.Path)
+		if rel, _ := filepath.Rel(root, entry.Path); entry.RelPath != rel {
+			t.Errorf("Expected RelPath %s for %s, got %s", rel, entry.Path, entry.RelPath)
+		}
+		if want := strings.Count(filepath.ToSlash(entry.RelPath), "/") + 1; entry.RelPath != "." && entry.Depth != want {
+			t.Errorf("Expected depth %d for %s, got %d", want, entry.RelPath, entry.Depth)
+		}
+	}
+	sort.Strings(want)
+	sort.Strings(got)
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected %v, got %v", want, got)
+	}
+}
+
+func TestEntriesBreak(t *testing.T) {
+	defer goleak.VerifyNone(t)
+	root := createEntriesFixture(t)
+	for i := 0; i < 200; i++ {
+		if err := os.WriteFile(filepath.Join(root, "dir", fmt.Sprintf("%03d.dat", i)), nil, 0644); err != nil {
+			t.Fatalf("Failed to create file: %v", err)
+		}
+	}
+
+	// Stopping early cancels the walk and leaves nothing running
+	var mu sync.Mutex
+	var last Stats
+	opts := WalkOptions{NumWorkers: 4, Progress: func(s Stats) {
+		mu.Lock()
+		defer mu.Unlock()
+		last = s
+	}}
+	seen := 0
+	for _, err := range Entries(context.Background(), root, opts) {
+		if err != nil {
+			t.Fatalf("Entries failed: %v", err)
+		}
+		if seen++; seen == 3 {
+			break
+		}
+	}
+	if seen != 3 {
+		t.Errorf("Expected 3 entries before breaking, got %d", seen)
+	}
+	if last.FilesProcessed >= 200 {
+		t.Errorf("Expected the walk to stop early, got %+v", last)
+	}
+}
+
+func TestEntriesError(t *testing.T) {
+	defer goleak.VerifyNone(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	var last error
+	for _, err := range Entries(ctx, createEntriesFixture(t), WalkOptions{}) {
+		last = err
+	}
+	if !errors.Is(last, context.Canceled) {
+		t.Errorf("Expected the cancellation to end the iteration, got %v", last)
+	}
+
+	for _, err := range Entries(context.Background(), "", WalkOptions{}) {
+		if !errors.Is(err, errEmptyRoot) {
+			t.Errorf("Expected errEmptyRoot, got %v", err)
+		}
+	}
+}
+
+func ExampleEntries() {
+	root, err := os.MkdirTemp("", "entries")
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	defer os.RemoveAll(root)
+	for _, name := range []string{"notes.txt", "main.go", "todo.txt"} {
+		os.WriteFile(filepath.Join(root, name), nil, 0644)
+	}
+
+	// Stop at the first Go file
+	opts := WalkOptions{Filter: FilterOptions{Pattern: "*.go"}}
+	for entry, err := range Entries(context.Background(), root, opts) {
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+		if !entry.Info.IsDir() {
+			fmt.Println("found", entry.RelPath)
+			break
+		}
+	}
+	// Output: found main.go
+}
+
+func ExampleEntries_all() {
+	root, err := os.MkdirTemp("", "entries")
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	defer os.RemoveAll(root)
+	os.Mkdir(filepath.Join(root, "src"), 0755)
+	for _, name := range []string{"README.md", "src/main.go", "src/util.go"} {
+		os.WriteFile(filepath.Join(root, filepath.FromSlash(name)), nil, 0644)
+	}
+
+	// Entries arrive as workers finish with them, so sort them for display
+	var paths []string
+	for entry, err := range Entries(context.Background(), root, WalkOptions{}) {
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+		paths = append(paths, filepath.ToSlash(entry.RelPath))
+	}
+	sort.Strings(paths)
+	fmt.Println(strings.Join(paths, "\n"))
+	// Output:
+	// .
+	// README.md
+	// src
+	// src/main.go
+	// src/util.go
+}
@@ -0,0 +1,60 @@
+package stride
+
+import (
+	"io/fs"
+	"os"
+)
+
+// walkDirTree enumerates a tree for the walkers, like filepath.WalkDir but
+// with Windows junctions presented as symlinks. Tests replace it to simulate
+// filesystems whose directory listings lack or misreport the types of
+// entries.
+var walkDirTree = walkDirReparse
+
+// unknownTypeThreshold is the number of entries of unknown type after which
+// WalkDir stops trusting the types in directory listings for the rest of the
+// walk.
+const unknownTypeThreshold = 64
+
+// typeUnknown reports whether the listing that produced d did not know its
+// type, as with DT_UNKNOWN from some FUSE and network filesystems. The os
+// package resolves such entries with an lstat of its own; other listings
+// report them as irregular files.
+func typeUnknown(d fs.DirEntry) bool {
+	return d.Type()&fs.ModeType == fs.ModeIrregular
+}
+
+// statFallback decides which entries WalkDir stats before trusting their
+// type: those of unknown type, and every entry once it is forced, either by
+// WalkOptions.ForceStatFallback or by seeing more than unknownTypeThreshold
+// entries of unknown type.
+type statFallback struct {
+	forced  bool
+	unknown int
+}
+
+// resolve returns d with the type found by an lstat of path, if d needs it.
+// moved reports whether d turned out to be a directory although the listing
+// did not say so, in which case the caller has to walk it itself.
+func (s *statFallback) resolve(path string, d fs.DirEntry) (resolved fs.DirEntry, moved bool, err error) {
+	if typeUnknown(d) {
+		if s.unknown++; s.unknown > unknownTypeThreshold {
+			s.forced = true
+		}
+	} else if !s.forced {
+		return d, false, nil
+	}
+	info, err := os.Lstat(path)
+	if err != nil {
+		return d, false, err
+	}
+	return infoDirEntry{DirEntry: fs.FileInfoToDirEntry(info), info: info}, info.IsDir() && !d.IsDir(), nil
+}
+
+// walkListedDir walks a directory that its parent's listing reported as
+// something else, so the walk that listed it did not descend. visit sees the
+// directory's own entry first, typed correctly this time, then its contents.
+// walkTree is the walk's walkDirTree.
+func walkListedDir(walkTree func(string, fs.WalkDirFunc) error, path string, visit fs.WalkDirFunc) error {
+	return walkTree(path, visit)
+}
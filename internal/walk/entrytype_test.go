@@ -0,0 +1,204 @@
+package stride
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"sync"
+	"testing"
+)
+
+// listedDirEntry is a directory entry whose listing reported type typ.
+type listedDirEntry struct {
+	fs.DirEntry
+	typ fs.FileMode
+}
+
+func (e listedDirEntry) Type() fs.FileMode { return e.typ }
+func (e listedDirEntry) IsDir() bool       { return e.typ.IsDir() }
+
+// fakeWalkDir returns a replacement for walkDirTree that walks like
+// filepath.WalkDir, except that entries below the root are reported with the
+// type listed(d) and only descended into when that type is a directory.
+func fakeWalkDir(listed func(d fs.DirEntry) fs.FileMode) func(string, fs.WalkDirFunc) error {
+	var walk func(path string, d fs.DirEntry, fn fs.WalkDirFunc) error
+	walk = func(path string, d fs.DirEntry, fn fs.WalkDirFunc) error {
+		if err := fn(path, d, nil); err != nil || !d.IsDir() {
+			if err == filepath.SkipDir && d.IsDir() {
+				return nil
+			}
+			return err
+		}
+		entries, err := os.ReadDir(path)
+		if err != nil {
+			return fn(path, d, err)
+		}
+		for _, e := range entries {
+			if err := walk(filepath.Join(path, e.Name()), listedDirEntry{e, listed(e)}, fn); err != nil {
+				if err == filepath.SkipDir {
+					return nil
+				}
+				return err
+			}
+		}
+		return nil
+	}
+	return func(root string, fn fs.WalkDirFunc) error {
+		info, err := os.Lstat(root)
+		if err != nil {
+			return fn(root, nil, err)
+		}
+		err = walk(root, fs.FileInfoToDirEntry(info), fn)
+		if err == filepath.SkipDir || err == filepath.SkipAll {
+			return nil
+		}
+		return err
+	}
+}
+
+// useWalkDirTree replaces walkDirTree for the duration of the test.
+func useWalkDirTree(t *testing.T, fn func(string, fs.WalkDirFunc) error) {
+	orig := walkDirTree
+	walkDirTree = fn
+	t.Cleanup(func() { walkDirTree = orig })
+}
+
+// unknownType reports every entry as being of unknown type.
+func unknownType(fs.DirEntry) fs.FileMode { return fs.ModeIrregular }
+
+// dirsAsFiles reports directories as regular files and other entries as
+// being of unknown type.
+func dirsAsFiles(d fs.DirEntry) fs.FileMode {
+	if d.IsDir() {
+		return 0
+	}
+	return fs.ModeIrregular
+}
+
+// pathRecorder collects root-relative paths from concurrent callbacks.
+type pathRecorder struct {
+	mu    sync.Mutex
+	root  string
+	paths []string
+}
+
+func (r *pathRecorder) add(path string, isDir bool) {
+	rel, _ := filepath.Rel(r.root, path)
+	rel = filepath.ToSlash(rel)
+	if isDir {
+		rel += "/"
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.paths = append(r.paths, rel)
+}
+
+func (r *pathRecorder) sorted() []string {
+	sort.Strings(r.paths)
+	return r.paths
+}
+
+// walkDirPaths runs WalkDir and returns the paths it visited.
+func walkDirPaths(t *testing.T, root string, opts WalkOptions) []string {
+	t.Helper()
+	rec := &pathRecorder{root: root}
+	err := WalkDir(root, func(ctx context.Context, path string, d fs.DirEntry) error {
+		rec.add(path, d.IsDir())
+		return nil
+	}, opts)
+	if err != nil {
+		t.Fatalf("WalkDir failed: %v", err)
+	}
+	return rec.sorted()
+}
+
+func TestUnknownEntryTypes(t *testing.T) {
+	root := createIncrementalFixture(t)
+	want := []string{"./", "a/", "a/1.txt", "a/2.txt", "b/", "b/3.txt", "b/c/", "b/c/4.txt", "top.txt"}
+
+	infoWalks := []struct {
+		name string
+		walk func(root string, fn filepath.WalkFunc) error
+	}{
+		{"WalkLimit", func(root string, fn filepath.WalkFunc) error {
+			return WalkLimit(context.Background(), root, fn, 2)
+		}},
+		{"WalkLimitWithOptions", func(root string, fn filepath.WalkFunc) error {
+			return WalkLimitWithOptions(context.Background(), root, fn, WalkOptions{NumWorkers: 2})
+		}},
+	}
+	for _, tt := range infoWalks {
+		t.Run(tt.name, func(t *testing.T) {
+			useWalkDirTree(t, fakeWalkDir(unknownType))
+			rec := &pathRecorder{root: root}
+			err := tt.walk(root, func(path string, info os.FileInfo, err error) error {
+				if err != nil {
+					return err
+				}
+				rec.add(path, info.IsDir())
+				return nil
+			})
+			if err != nil {
+				t.Fatalf("Walk failed: %v", err)
+			}
+			if got := rec.sorted(); !reflect.DeepEqual(got, want) {
+				t.Errorf("Expected %v, got %v", want, got)
+			}
+		})
+	}
+
+	t.Run("WalkDir", func(t *testing.T) {
+		useWalkDirTree(t, fakeWalkDir(unknownType))
+		if got := walkDirPaths(t, root, WalkOptions{NumWorkers: 2}); !reflect.DeepEqual(got, want) {
+			t.Errorf("Expected %v, got %v", want, got)
+		}
+	})
+
+	t.Run("incremental", func(t *testing.T) {
+		orig := readDirFn
+		readDirFn = func(name string) ([]os.DirEntry, error) {
+			entries, err := orig(name)
+			for i, e := range entries {
+				entries[i] = listedDirEntry{e, fs.ModeIrregular}
+			}
+			return entries, err
+		}
+		t.Cleanup(func() { readDirFn = orig })
+
+		got, _ := incrementalWalk(t, root, filepath.Join(t.TempDir(), "cache"), IncrementalReplay, CacheStrictCount)
+		if want := []string{".", "a", "a/1.txt", "a/2.txt", "b", "b/3.txt", "b/c", "b/c/4.txt", "top.txt"}; !reflect.DeepEqual(got, want) {
+			t.Errorf("Expected %v, got %v", want, got)
+		}
+	})
+}
+
+func TestForceStatFallback(t *testing.T) {
+	root := createIncrementalFixture(t)
+	useWalkDirTree(t, fakeWalkDir(dirsAsFiles))
+
+	// Without the fallback, the listed types are trusted for known types
+	got := walkDirPaths(t, root, WalkOptions{})
+	if want := []string{"./", "a", "b", "top.txt"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected %v, got %v", want, got)
+	}
+
+	all := []string{"./", "a/", "a/1.txt", "a/2.txt", "b/", "b/3.txt", "b/c/", "b/c/4.txt", "top.txt"}
+	if got := walkDirPaths(t, root, WalkOptions{ForceStatFallback: true}); !reflect.DeepEqual(got, all) {
+		t.Errorf("Expected %v, got %v", all, got)
+	}
+
+	// Enough entries of unknown type force the fallback for the rest of the walk
+	for i := 0; i <= unknownTypeThreshold; i++ {
+		if err := os.WriteFile(filepath.Join(root, fmt.Sprintf("%03d.txt", i)), nil, 0644); err != nil {
+			t.Fatalf("Failed to create file: %v", err)
+		}
+	}
+	got = walkDirPaths(t, root, WalkOptions{Filter: FilterOptions{ExcludePattern: []string{"0*.txt"}}})
+	if !reflect.DeepEqual(got, all) {
+		t.Errorf("Expected %v, got %v", all, got)
+	}
+}
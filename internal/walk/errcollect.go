@@ -0,0 +1,177 @@
+package stride
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+)
+
+// DefaultMaxRetainedErrors is the number of errors a walk keeps for its
+// final error unless WalkOptions.MaxRetainedErrors says otherwise.
+const DefaultMaxRetainedErrors = 1000
+
+// errnoDir identifies repeats of the same system error in one directory.
+type errnoDir struct {
+	errno syscall.Errno
+	dir   string
+}
+
+// WalkError is an error a walk met at one path.
+type WalkError struct {
+	Path    string // Path of the entry, empty for errors of the walk as a whole
+	Err     error
+	Skipped bool // Whether the entry, and the subtree of a directory, was left out of the walk
+}
+
+// Error describes the error with its path.
+func (e WalkError) Error() string {
+	if e.Path == "" {
+		return e.Err.Error()
+	}
+	return fmt.Sprintf("path %q: %v", e.Path, e.Err)
+}
+
+// Unwrap returns the underlying error.
+func (e WalkError) Unwrap() error {
+	return e.Err
+}
+
+// WalkErrors is the error a walk ends with when it met several distinct
+// errors. It lists those it kept, and errors.Is and errors.As see each, so
+// errors.Is(err, fs.ErrPermission) reports whether any was a permission
+// error.
+type WalkErrors struct {
+	Errors []WalkError // The kept errors, in the order they occurred
+	Total  int         // Number of errors in all, including those not kept
+}
+
+// Error lists the kept errors, with how many there were in all.
+func (e *WalkErrors) Error() string {
+	var errMsg strings.Builder
+	if len(e.Errors) < e.Total {
+		errMsg.WriteString(fmt.Sprintf("%d errors occurred during walk (showing first %d):\n", e.Total, len(e.Errors)))
+	} else {
+		errMsg.WriteString(fmt.Sprintf("%d errors occurred during walk:\n", e.Total))
+	}
+	for i, err := range e.Errors {
+		errMsg.WriteString(fmt.Sprintf("  %d: %v\n", i+1, err))
+	}
+	return errMsg.String()
+}
+
+// Unwrap returns the kept errors.
+func (e *WalkErrors) Unwrap() []error {
+	errs := make([]error, len(e.Errors))
+	for i, err := range e.Errors {
+		errs[i] = err
+	}
+	return errs
+}
+
+// HasPermissionError reports whether any of the kept errors is a
+// permission error.
+func (e *WalkErrors) HasPermissionError() bool {
+	return errors.Is(e, fs.ErrPermission)
+}
+
+// errorCollector gathers the errors of a walk. Every error is counted, but
+// only the first limit distinct ones are kept, so a walk over a failing
+// mount does not run out of memory describing its failures. Unless
+// keepRepeats is set, an error with the same errno in the same directory
+// as one already kept is not kept again. It is safe for concurrent use.
+type errorCollector struct {
+	mu       sync.Mutex
+	limit    int
+	retained []WalkError
+	seen     map[errnoDir]struct{} // Nil when repeats are kept
+	total    int
+	first    error // Cause of the first error, before the path was added
+	allSame  bool  // Whether every error so far has the cause of the first
+}
+
+// newErrorCollector returns a collector keeping at most limit errors, or
+// DefaultMaxRetainedErrors if limit is not positive.
+func newErrorCollector(limit int) *errorCollector {
+	c := newErrorLog(limit)
+	c.seen = make(map[errnoDir]struct{})
+	return c
+}
+
+// newErrorLog returns a collector keeping at most limit errors, or
+// DefaultMaxRetainedErrors if limit is not positive, repeats included.
+func newErrorLog(limit int) *errorCollector {
+	if limit <= 0 {
+		limit = DefaultMaxRetainedErrors
+	}
+	return &errorCollector{limit: limit}
+}
+
+// add records err, which occurred at path unless path is empty.
+func (c *errorCollector) add(path string, err error) {
+	c.addEntry(WalkError{Path: path, Err: err})
+}
+
+// addEntry records the error of entry. A nil collector ignores it.
+func (c *errorCollector) addEntry(entry WalkError) {
+	if c == nil {
+		return
+	}
+	path, err := entry.Path, entry.Err
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.total++
+	if c.first == nil {
+		c.first = err
+		c.allSame = true
+	} else if c.allSame && !errors.Is(err, c.first) && err.Error() != c.first.Error() {
+		c.allSame = false
+	}
+
+	if len(c.retained) >= c.limit {
+		return
+	}
+	var errno syscall.Errno
+	if c.seen != nil && path != "" && errors.As(err, &errno) {
+		key := errnoDir{errno: errno, dir: filepath.Dir(path)}
+		if _, ok := c.seen[key]; ok {
+			return
+		}
+		c.seen[key] = struct{}{}
+	}
+	c.retained = append(c.retained, entry)
+}
+
+// entries returns the kept errors in the order they were added.
+func (c *errorCollector) entries() []WalkError {
+	if c == nil {
+		return nil
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]WalkError(nil), c.retained...)
+}
+
+// err returns the error the walk ends with, or nil if nothing was added.
+// A lone cancellation is returned as context.Canceled and errors that all
+// share one cause as the first of them; otherwise a *WalkErrors holds the
+// kept errors and how many there were in all.
+func (c *errorCollector) err() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	switch {
+	case c.total == 0:
+		return nil
+	case c.total == 1 && errors.Is(c.first, context.Canceled):
+		return context.Canceled
+	case c.allSame:
+		return c.retained[0]
+	}
+	return &WalkErrors{Errors: append([]WalkError(nil), c.retained...), Total: c.total}
+}
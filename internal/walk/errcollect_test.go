@@ -0,0 +1,182 @@
+package stride
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+
+	"github.com/TFMV/stride/walk/walktest"
+)
+
+func TestErrorCollector(t *testing.T) {
+	c := newErrorCollector(3)
+	if c.err() != nil {
+		t.Errorf("Expected no error, got %v", c.err())
+	}
+
+	// Repeats of one errno in one directory are kept once
+	for i := 0; i < 5; i++ {
+		c.add(filepath.Join("mnt", "a", fmt.Sprint(i)), &os.PathError{Op: "open", Path: "x", Err: syscall.EIO})
+	}
+	c.add(filepath.Join("mnt", "a", "x"), syscall.EACCES)
+	c.add(filepath.Join("mnt", "b", "x"), syscall.EIO)
+	c.add(filepath.Join("mnt", "c", "x"), syscall.EIO)
+
+	if c.total != 8 {
+		t.Errorf("Expected 8 errors counted, got %d", c.total)
+	}
+	if len(c.retained) != 3 {
+		t.Errorf("Expected 3 errors kept, got %v", c.retained)
+	}
+	msg := c.err().Error()
+	if !strings.HasPrefix(msg, "8 errors occurred during walk (showing first 3):\n") {
+		t.Errorf("Unexpected error: %s", msg)
+	}
+	if strings.Contains(msg, filepath.Join("mnt", "a", "1")) || strings.Contains(msg, filepath.Join("mnt", "c")) {
+		t.Errorf("Expected repeats and errors beyond the cap to be dropped, got %s", msg)
+	}
+
+	// The kept errors stay inspectable
+	if !errors.Is(c.err(), syscall.EACCES) || errors.Is(c.err(), syscall.ENOENT) {
+		t.Errorf("Expected the kept errors to be matched, got %v", c.err())
+	}
+	var walkErr WalkError
+	if !errors.As(c.err(), &walkErr) || walkErr.Path != filepath.Join("mnt", "a", "0") {
+		t.Errorf("Expected the first error at %s, got %+v", filepath.Join("mnt", "a", "0"), walkErr)
+	}
+	var walkErrs *WalkErrors
+	if !errors.As(c.err(), &walkErrs) || walkErrs.Total != 8 || len(walkErrs.Errors) != 3 {
+		t.Fatalf("Expected *WalkErrors with 3 of 8 errors, got %#v", c.err())
+	}
+	if !walkErrs.HasPermissionError() || !errors.Is(c.err(), fs.ErrPermission) {
+		t.Errorf("Expected a permission error among %v", walkErrs.Errors)
+	}
+
+	// A lone cancellation is reported as such
+	c = newErrorCollector(0)
+	c.add("", context.Canceled)
+	if !errors.Is(c.err(), context.Canceled) || c.limit != DefaultMaxRetainedErrors {
+		t.Errorf("Expected context.Canceled, got %v", c.err())
+	}
+}
+
+func TestMaxRetainedErrors(t *testing.T) {
+	if testing.Short() {
+		t.Skip("creates 100k files")
+	}
+
+	const dirs, perDir, retained = 100, 1000, 50
+	root := t.TempDir()
+	for i := 0; i < dirs; i++ {
+		dir := filepath.Join(root, fmt.Sprint(i))
+		if err := os.Mkdir(dir, 0755); err != nil {
+			t.Fatalf("Failed to create directory: %v", err)
+		}
+		for j := 0; j < perDir; j++ {
+			f, err := os.Create(filepath.Join(dir, fmt.Sprint(j)))
+			if err != nil {
+				t.Fatalf("Failed to create file: %v", err)
+			}
+			f.Close()
+		}
+	}
+	opts := WalkOptions{ErrorHandling: ErrorHandlingContinue, MaxRetainedErrors: retained}
+
+	// Distinct errors are counted in full but only the first are kept
+	stats, err := WalkLimitWithOptionsStats(context.Background(), root, func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return err
+		}
+		return fmt.Errorf("synthetic failure of %s", filepath.Base(path))
+	}, opts)
+	if stats.ErrorCount != dirs*perDir {
+		t.Errorf("Expected ErrorCount %d, got %d", dirs*perDir, stats.ErrorCount)
+	}
+	if err == nil {
+		t.Fatal("Expected the walk to fail")
+	}
+	lines := strings.Split(strings.TrimSuffix(err.Error(), "\n"), "\n")
+	if want := fmt.Sprintf("%d errors occurred during walk (showing first %d):", dirs*perDir, retained); lines[0] != want {
+		t.Errorf("Expected %q, got %q", want, lines[0])
+	}
+	if len(lines) != retained+1 {
+		t.Errorf("Expected %d errors kept, got %d", retained, len(lines)-1)
+	}
+
+	// The same error everywhere is still returned as itself
+	errBoom := errors.New("boom")
+	stats, err = WalkLimitWithOptionsStats(context.Background(), root, func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return err
+		}
+		return errBoom
+	}, opts)
+	if !errors.Is(err, errBoom) || strings.Contains(err.Error(), "errors occurred") {
+		t.Errorf("Expected the shared error, got %.200v", err)
+	}
+	if stats.ErrorCount != dirs*perDir {
+		t.Errorf("Expected ErrorCount %d, got %d", dirs*perDir, stats.ErrorCount)
+	}
+}
+
+func TestWalkErrorsFromWalks(t *testing.T) {
+	root := walktest.Tree{
+		"denied.txt": walktest.File{Content: "x"},
+		"broken.txt": walktest.File{Content: "x"},
+		"fine.txt":   walktest.File{Content: "x"},
+	}.Build(t)
+	errBroken := errors.New("broken")
+	fail := func(path string) error {
+		switch filepath.Base(path) {
+		case "denied.txt":
+			return &os.PathError{Op: "open", Path: path, Err: syscall.EACCES}
+		case "broken.txt":
+			return errBroken
+		}
+		return nil
+	}
+
+	walks := []struct {
+		name string
+		walk func() error
+	}{
+		{"WalkLimit", func() error {
+			return WalkLimit(context.Background(), root, func(path string, info os.FileInfo, err error) error {
+				return fail(path)
+			}, 2)
+		}},
+		{"WalkLimitWithOptions", func() error {
+			return WalkLimitWithOptions(context.Background(), root, func(path string, info os.FileInfo, err error) error {
+				return fail(path)
+			}, WalkOptions{ErrorHandling: ErrorHandlingContinue})
+		}},
+		{"WalkRoots", func() error {
+			return WalkRoots(context.Background(), []string{filepath.Join(root, "denied.txt"), filepath.Join(root, "broken.txt")}, func(path string, info os.FileInfo, err error) error {
+				return fail(path)
+			}, WalkOptions{})
+		}},
+		{"Find", func() error {
+			return Find(context.Background(), root, FindOptions{}, func(ctx context.Context, result FindResult) error {
+				return fail(result.Message.Path)
+			})
+		}},
+	}
+	for _, w := range walks {
+		t.Run(w.name, func(t *testing.T) {
+			err := w.walk()
+			var walkErrs *WalkErrors
+			if !errors.As(err, &walkErrs) || len(walkErrs.Errors) != 2 {
+				t.Fatalf("Expected *WalkErrors with 2 errors, got %v", err)
+			}
+			if !errors.Is(err, fs.ErrPermission) || !errors.Is(err, errBroken) || !walkErrs.HasPermissionError() {
+				t.Errorf("Expected both errors to be matched, got %v", err)
+			}
+		})
+	}
+}
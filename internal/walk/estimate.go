@@ -0,0 +1,55 @@
+package stride
+
+import (
+	"context"
+	"io/fs"
+	"path/filepath"
+)
+
+// estimateFiles counts the files beneath root a walk with filter would
+// reach, for WalkOptions.EstimateTotals. Only the directory listings are
+// read: directories the filter excludes, and those below its MaxDepth, are
+// not entered, and files are checked against its path patterns but not
+// against anything that needs their FileInfo. Symbolic links count as files
+// and are not followed, and directories that cannot be read are left out.
+// A confined walk is counted through confined, its root's handle, and a
+// nil one walks root by path. It returns ctx's error once ctx is done.
+func estimateFiles(ctx context.Context, root string, filter FilterOptions, confined *confinedRoot) (int64, error) {
+	walkTree := filepath.WalkDir
+	if confined != nil {
+		walkTree = confined.walkDir
+	}
+	var files int64
+	err := walkTree(root, func(path string, d fs.DirEntry, err error) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err != nil {
+			if d != nil && d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if path == root {
+			return nil
+		}
+		depth := depthOf(root, path)
+		if filter.MaxDepth > 0 && depth > filter.MaxDepth {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if d.IsDir() {
+			if dirExcluded(path, root, filter) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !filePatternRejects(path, root, filter) {
+			files++
+		}
+		return nil
+	})
+	return files, err
+}
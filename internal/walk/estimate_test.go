@@ -0,0 +1,213 @@
+package stride
+
+import (
+	"context"
+	"errors"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/TFMV/stride/walk/walktest"
+)
+
+func createEstimateFixture(t testing.TB) string {
+	return walktest.Tree{
+		"a.txt":              walktest.File{Content: "a"},
+		"b.log":              walktest.File{Content: "b"},
+		"sub/c.txt":          walktest.File{Content: "c"},
+		"sub/deep/d.txt":     walktest.File{Content: "d"},
+		"vendor/e.txt":       walktest.File{Content: "e"},
+		"vendor/lib/f.txt":   walktest.File{Content: "f"},
+		"sub/vendor/g.txt":   walktest.File{Content: "g"},
+		"sub/deep/more/h.go": walktest.File{Content: "h"},
+	}.Build(t)
+}
+
+func TestEstimateFiles(t *testing.T) {
+	root := createEstimateFixture(t)
+
+	tests := []struct {
+		name   string
+		filter FilterOptions
+		want   int64
+	}{
+		{"No filter", FilterOptions{}, 8},
+		{"Excluded directories", FilterOptions{ExcludeDir: []string{"vendor"}}, 5},
+		{"Max depth", FilterOptions{MaxDepth: 2}, 4},
+		{"Path pattern", FilterOptions{Pattern: "sub/**"}, 4},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := estimateFiles(context.Background(), root, tt.filter, nil)
+			if err != nil {
+				t.Fatalf("Failed to estimate files: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("Expected %d files, got %d", tt.want, got)
+			}
+		})
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := estimateFiles(ctx, root, FilterOptions{}, nil); !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected context.Canceled, got %v", err)
+	}
+}
+
+func TestWalkEstimateTotals(t *testing.T) {
+	root := createEstimateFixture(t)
+
+	walk := func(ctx context.Context, opts WalkOptions) ([]Stats, Stats, error) {
+		var mu sync.Mutex
+		var reports []Stats
+		opts.NumWorkers = 1
+		opts.ProgressInterval = time.Millisecond
+		opts.Progress = func(s Stats) {
+			mu.Lock()
+			reports = append(reports, s)
+			mu.Unlock()
+		}
+		final, err := WalkLimitWithOptionsStats(ctx, root, func(path string, info os.FileInfo, err error) error {
+			if err == nil && !info.IsDir() {
+				time.Sleep(5 * time.Millisecond)
+			}
+			return err
+		}, opts)
+		mu.Lock()
+		defer mu.Unlock()
+		return reports, final, err
+	}
+
+	reports, final, err := walk(context.Background(), WalkOptions{
+		EstimateTotals: true,
+		Filter:         FilterOptions{ExcludeDir: []string{"vendor"}},
+	})
+	if err != nil {
+		t.Fatalf("Walk failed: %v", err)
+	}
+	if final.TotalFilesEstimate != 5 {
+		t.Errorf("Expected an estimate of 5 files, got %d", final.TotalFilesEstimate)
+	}
+	if final.ETA != 0 {
+		t.Errorf("Expected no ETA in the final stats, got %v", final.ETA)
+	}
+	var withETA int
+	for _, s := range reports {
+		if s.TotalFilesEstimate != 5 {
+			t.Errorf("Expected an estimate of 5 files in every report, got %d", s.TotalFilesEstimate)
+		}
+		if s.ETA > 0 {
+			withETA++
+		}
+	}
+	if withETA == 0 {
+		t.Errorf("Expected a report with an ETA, got %+v", reports)
+	}
+
+	// Without estimation both stay zero
+	reports, _, err = walk(context.Background(), WalkOptions{})
+	if err != nil {
+		t.Fatalf("Walk failed: %v", err)
+	}
+	for _, s := range reports {
+		if s.TotalFilesEstimate != 0 || s.ETA != 0 {
+			t.Errorf("Expected no estimate or ETA, got %d and %v", s.TotalFilesEstimate, s.ETA)
+		}
+	}
+
+	// A canceled pre-scan stops the walk before it starts
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	reports, _, err = walk(ctx, WalkOptions{EstimateTotals: true})
+	if !errors.Is(err, ErrCanceledByUser) {
+		t.Errorf("Expected ErrCanceledByUser, got %v", err)
+	}
+	if len(reports) != 0 {
+		t.Errorf("Expected no progress reports, got %d", len(reports))
+	}
+}
+
+func TestProgressInterval(t *testing.T) {
+	root := createEstimateFixture(t)
+
+	reports := func(interval time.Duration) int {
+		var mu sync.Mutex
+		var n int
+		err := WalkLimitWithOptions(context.Background(), root, func(path string, info os.FileInfo, err error) error {
+			if err == nil && !info.IsDir() {
+				time.Sleep(10 * time.Millisecond)
+			}
+			return err
+		}, WalkOptions{
+			NumWorkers:       1,
+			ProgressInterval: interval,
+			Progress: func(Stats) {
+				mu.Lock()
+				n++
+				mu.Unlock()
+			},
+		})
+		if err != nil {
+			t.Fatalf("Walk failed: %v", err)
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		return n
+	}
+
+	// Eight files take some 80ms: the default interval reports only the
+	// final stats, a short one several times before
+	if n := reports(0); n != 1 {
+		t.Errorf("Expected 1 report at the default interval, got %d", n)
+	}
+	if n := reports(5 * time.Millisecond); n < 3 {
+		t.Errorf("Expected at least 3 reports at a 5ms interval, got %d", n)
+	}
+}
+
+func TestWalkLimitWithProgressInterval(t *testing.T) {
+	root := walktest.Tree{
+		"a.txt": walktest.File{Content: "a"},
+		"b.txt": walktest.File{Content: "b"},
+	}.Build(t)
+
+	// Two files taking 250ms each last for two ticks of the default
+	// interval, then the final stats are reported
+	var mu sync.Mutex
+	var n int
+	err := WalkLimitWithProgress(context.Background(), root, func(path string, info os.FileInfo, err error) error {
+		if err == nil && !info.IsDir() {
+			time.Sleep(250 * time.Millisecond)
+		}
+		return err
+	}, 1, func(Stats) {
+		mu.Lock()
+		n++
+		mu.Unlock()
+	})
+	if err != nil {
+		t.Fatalf("Walk failed: %v", err)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if n < 3 {
+		t.Errorf("Expected at least 3 reports at DefaultProgressInterval, got %d", n)
+	}
+}
+
+func TestStatsETA(t *testing.T) {
+	stats := Stats{FilesProcessed: 25, TotalFilesEstimate: 100, ElapsedTime: time.Second}
+	stats.updateDerivedStats()
+	if stats.ETA != 3*time.Second {
+		t.Errorf("Expected an ETA of 3s, got %v", stats.ETA)
+	}
+
+	// Past the estimate there is nothing left to wait for
+	stats.FilesProcessed = 120
+	stats.updateDerivedStats()
+	if stats.ETA != 0 {
+		t.Errorf("Expected no ETA past the estimate, got %v", stats.ETA)
+	}
+}
@@ -0,0 +1,62 @@
+package stride
+
+import (
+	"os"
+	"strconv"
+	"time"
+)
+
+// commandEnv builds the environment of commands run for matches and
+// events: the parent's environment, read once and shared by every command,
+// followed by variables describing the entry. A nil *commandEnv leaves
+// commands with the parent's environment only.
+type commandEnv struct {
+	base []string
+}
+
+// execEnvEnabled resolves an ExecEnv option, which defaults to true.
+func execEnvEnabled(opt *bool) bool {
+	return opt == nil || *opt
+}
+
+// newCommandEnv returns a commandEnv, or nil if enabled is false.
+func newCommandEnv(enabled bool) *commandEnv {
+	if !enabled {
+		return nil
+	}
+	return &commandEnv{base: os.Environ()}
+}
+
+// forFind returns the environment of a command run for a match.
+func (e *commandEnv) forFind(msg FindMessage) []string {
+	return e.with(msg, "")
+}
+
+// forWatch returns the environment of a command run for a watch event,
+// which also names the event and, in STRIDE_TIME, its time: that of the
+// change, or when a deleted file was found gone.
+func (e *commandEnv) forWatch(msg WatchMessage) []string {
+	return e.with(watchFindMessage(msg), string(msg.Event))
+}
+
+// with appends the variables describing msg, and event and its time if
+// event is set, to the base environment.
+func (e *commandEnv) with(msg FindMessage, event string) []string {
+	if e == nil {
+		return nil
+	}
+	// The full slice expression makes append copy base instead of sharing
+	// its spare capacity between concurrent commands
+	env := append(e.base[:len(e.base):len(e.base)],
+		"STRIDE_PATH="+msg.Path,
+		"STRIDE_BASE="+msg.Name,
+		"STRIDE_DIR="+msg.Dir,
+		"STRIDE_SIZE="+strconv.FormatInt(msg.Size, 10),
+		"STRIDE_MTIME="+msg.Time.Format(time.RFC3339),
+		"STRIDE_IS_DIR="+strconv.FormatBool(msg.IsDir),
+	)
+	if event != "" {
+		env = append(env, "STRIDE_EVENT="+event, "STRIDE_TIME="+msg.Time.Format(time.RFC3339))
+	}
+	return env
+}
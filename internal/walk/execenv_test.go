@@ -0,0 +1,182 @@
+package stride
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCommandEnv(t *testing.T) {
+	if env := (*commandEnv)(nil).forFind(FindMessage{Path: "/a"}); env != nil {
+		t.Errorf("Expected a disabled environment to be nil, got %v", env)
+	}
+
+	e := &commandEnv{base: make([]string, 1, 16)}
+	e.base[0] = "HOME=/home/test"
+	msg := WatchMessage{Path: "/data/a b.txt", Name: "a b.txt", Dir: "/data", Size: 3,
+		Time: time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC), Event: EventModify}
+	got := e.forWatch(msg)
+	want := []string{
+		"HOME=/home/test",
+		"STRIDE_PATH=/data/a b.txt",
+		"STRIDE_BASE=a b.txt",
+		"STRIDE_DIR=/data",
+		"STRIDE_SIZE=3",
+		"STRIDE_MTIME=2024-05-01T12:00:00Z",
+		"STRIDE_IS_DIR=false",
+		"STRIDE_EVENT=modify",
+		"STRIDE_TIME=2024-05-01T12:00:00Z",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("Expected %q, got %q", want, got)
+	}
+
+	// Environments built concurrently must not share the spare capacity of base
+	other := e.forFind(FindMessage{Path: "/other"})
+	if got[1] != "STRIDE_PATH=/data/a b.txt" || other[1] != "STRIDE_PATH=/other" {
+		t.Errorf("Expected independent environments, got %q and %q", got[1], other[1])
+	}
+}
+
+func TestFindWithExecEnv(t *testing.T) {
+	root := t.TempDir()
+	if err := os.WriteFile(filepath.Join(root, "it's.txt"), []byte("abc"), 0644); err != nil {
+		t.Fatalf("Failed to create file: %v", err)
+	}
+	out := filepath.Join(t.TempDir(), "out")
+	cmd := `echo "$STRIDE_BASE:$STRIDE_SIZE:$STRIDE_IS_DIR:$STRIDE_EVENT" >> ` + out
+
+	if err := FindWithExec(context.Background(), root, FindOptions{NamePattern: "*.txt", MaxDepth: 1}, cmd); err != nil {
+		t.Fatalf("FindWithExec failed: %v", err)
+	}
+	disabled := false
+	if err := FindWithExec(context.Background(), root, FindOptions{NamePattern: "*.txt", MaxDepth: 1, ExecEnv: &disabled}, cmd); err != nil {
+		t.Fatalf("FindWithExec failed: %v", err)
+	}
+
+	content, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatalf("Failed to read output: %v", err)
+	}
+	if want := "it's.txt:3:false:\n:::\n"; string(content) != want {
+		t.Errorf("Expected %q, got %q", want, string(content))
+	}
+}
+
+func TestWatchWithExecEnv(t *testing.T) {
+	root := t.TempDir()
+	out := filepath.Join(t.TempDir(), "out")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	done := make(chan error, 1)
+	go func() {
+		opts := WatchOptions{Pattern: "*.dat", Events: []WatchEvent{EventCreate, EventModify}}
+		done <- WatchWithExec(ctx, root, opts, `echo "$STRIDE_EVENT:$STRIDE_BASE" >> `+out)
+	}()
+	time.Sleep(200 * time.Millisecond)
+
+	file := filepath.Join(root, "data.dat")
+	if err := os.WriteFile(file, []byte("a"), 0644); err != nil {
+		t.Fatalf("Failed to create file: %v", err)
+	}
+	time.Sleep(200 * time.Millisecond)
+	f, err := os.OpenFile(file, os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		t.Fatalf("Failed to open file: %v", err)
+	}
+	f.WriteString("b")
+	f.Close()
+
+	// Wait for both commands to run
+	var content []byte
+	for deadline := time.Now().Add(3 * time.Second); time.Now().Before(deadline); time.Sleep(50 * time.Millisecond) {
+		content, _ = os.ReadFile(out)
+		if strings.Contains(string(content), "modify:data.dat") {
+			break
+		}
+	}
+	cancel()
+	<-done
+
+	for _, want := range []string{"create:data.dat", "modify:data.dat"} {
+		if !strings.Contains(string(content), want) {
+			t.Errorf("Expected output to contain %q, got %q", want, string(content))
+		}
+	}
+}
+
+func TestShellQuote(t *testing.T) {
+	for _, s := range []string{"plain", "a b.txt", "it's.txt", `$HOME "x" \n`, "`date`;|&", ""} {
+		out, err := shellCommand(context.Background(), "printf '%s' "+shellQuote(s)).Output()
+		if err != nil {
+			t.Fatalf("Failed to run the shell for %q: %v", s, err)
+		}
+		if string(out) != s {
+			t.Errorf("Expected %q back from the shell, got %q", s, out)
+		}
+	}
+}
+
+func TestTemplateCommand(t *testing.T) {
+	// Bare placeholders are quoted for the shell like quoted ones, so
+	// nothing in the name is run
+	marker := filepath.Join(t.TempDir(), "ran")
+	name := "a b;touch " + marker + "$(touch " + marker + ").txt"
+	tpl, err := ParseTemplate(`for arg in {} {"base"} {size}; do echo "$arg"; done`)
+	if err != nil {
+		t.Fatalf("Failed to parse template: %v", err)
+	}
+	msg := FindMessage{Path: filepath.Join("dir", name), Name: name, Size: 3}
+	out, err := shellCommand(context.Background(), tpl.command(msg, "")).Output()
+	if err != nil {
+		t.Fatalf("Failed to run the command: %v", err)
+	}
+	if want := msg.Path + "\n" + name + "\n3\n"; string(out) != want {
+		t.Errorf("Expected %q, got %q", want, out)
+	}
+	if _, err := os.Stat(marker); err == nil {
+		t.Error("Expected the name not to run as shell code")
+	}
+}
+
+func TestWatchWithExecShell(t *testing.T) {
+	root := t.TempDir()
+	out := filepath.Join(t.TempDir(), "out")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	done := make(chan error, 1)
+	go func() {
+		opts := WatchOptions{Events: []WatchEvent{EventCreate}}
+		// A pipeline and a redirection, with the path as a placeholder and
+		// from the environment
+		done <- WatchWithExec(ctx, root, opts, `echo {"base"}"|$(basename "$STRIDE_PATH")|$STRIDE_TIME" | tr a-z A-Z >> `+out)
+	}()
+	time.Sleep(200 * time.Millisecond)
+
+	name := `it's a $file.txt`
+	if err := os.WriteFile(filepath.Join(root, name), []byte("a"), 0644); err != nil {
+		t.Fatalf("Failed to create file: %v", err)
+	}
+	var content []byte
+	for deadline := time.Now().Add(3 * time.Second); time.Now().Before(deadline); time.Sleep(50 * time.Millisecond) {
+		if content, _ = os.ReadFile(out); len(content) > 0 {
+			break
+		}
+	}
+	cancel()
+	<-done
+
+	fields := strings.Split(strings.TrimSuffix(string(content), "\n"), "|")
+	upper := strings.ToUpper(name)
+	if len(fields) != 3 || fields[0] != upper || fields[1] != upper {
+		t.Fatalf("Expected %q twice, got %q", upper, content)
+	}
+	if _, err := time.Parse(time.RFC3339, fields[2]); err != nil {
+		t.Errorf("Expected an RFC 3339 STRIDE_TIME, got %q: %v", fields[2], err)
+	}
+}
@@ -0,0 +1,81 @@
+package stride
+
+import (
+	"bytes"
+	"io"
+	"os"
+)
+
+// maxPrefixedLine bounds the partial line a prefixWriter holds; longer
+// lines are written in pieces, each with its own prefix.
+const maxPrefixedLine = 64 << 10
+
+// execOutput is where commands run for matches and events write their
+// output. The child's output is streamed, never held in memory.
+type execOutput struct {
+	stdout io.Writer // Synchronized; see newOutputWriter
+	stderr io.Writer // Synchronized; see newOutputWriter
+	prefix bool      // Whether each line is tagged with the path of the entry
+}
+
+// newExecOutput returns an execOutput writing to stdout and stderr, which
+// default to the process's own.
+func newExecOutput(stdout, stderr io.Writer, prefix bool) execOutput {
+	if stderr == nil {
+		stderr = os.Stderr
+	}
+	return execOutput{stdout: newOutputWriter(stdout), stderr: newOutputWriter(stderr), prefix: prefix}
+}
+
+// prefixWriter writes whole lines to w, each preceded by prefix, so the
+// lines of concurrent commands sharing w never interleave. Call flush once
+// the command has exited to write a final line without a newline.
+type prefixWriter struct {
+	w      io.Writer
+	prefix string
+	line   []byte
+}
+
+// newPrefixWriter returns a prefixWriter tagging lines with "path: ".
+func newPrefixWriter(w io.Writer, path string) *prefixWriter {
+	return &prefixWriter{w: w, prefix: path + ": "}
+}
+
+// Write writes each complete line of p, keeping the rest for the next call.
+func (p *prefixWriter) Write(b []byte) (int, error) {
+	n := len(b)
+	for len(b) > 0 {
+		i := bytes.IndexByte(b, '\n')
+		if i < 0 {
+			p.line = append(p.line, b...)
+			if len(p.line) >= maxPrefixedLine {
+				if err := p.flush(); err != nil {
+					return 0, err
+				}
+			}
+			break
+		}
+		p.line = append(p.line, b[:i+1]...)
+		if err := p.flush(); err != nil {
+			return 0, err
+		}
+		b = b[i+1:]
+	}
+	return n, nil
+}
+
+// flush writes the pending line, if any, in a single write.
+func (p *prefixWriter) flush() error {
+	if len(p.line) == 0 {
+		return nil
+	}
+	out := make([]byte, 0, len(p.prefix)+len(p.line)+1)
+	out = append(out, p.prefix...)
+	out = append(out, p.line...)
+	if out[len(out)-1] != '\n' {
+		out = append(out, '\n')
+	}
+	p.line = p.line[:0]
+	_, err := p.w.Write(out)
+	return err
+}
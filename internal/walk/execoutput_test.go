@@ -0,0 +1,159 @@
+package stride
+
+import (
+	"context"
+	"errors"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// countingWriter counts the bytes written to it without keeping them.
+type countingWriter struct {
+	mu       sync.Mutex
+	total    int
+	writes   int
+	maxWrite int
+}
+
+func (w *countingWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.total += len(p)
+	w.writes++
+	if len(p) > w.maxWrite {
+		w.maxWrite = len(p)
+	}
+	return len(p), nil
+}
+
+func TestExecuteCommandStreamsOutput(t *testing.T) {
+	const size = 10 << 20
+	var stdout countingWriter
+	out := newExecOutput(&stdout, &countingWriter{}, false)
+	if err := executeCommand(context.Background(), "head -c 10485760 /dev/zero", nil, "big", out, 0); err != nil {
+		t.Fatalf("executeCommand failed: %v", err)
+	}
+	if stdout.total != size {
+		t.Errorf("Expected %d bytes, got %d", size, stdout.total)
+	}
+
+	// The output arrives in pieces instead of one buffered write
+	if stdout.writes < 2 || stdout.maxWrite > 1<<20 {
+		t.Errorf("Expected streamed writes, got %d writes of up to %d bytes", stdout.writes, stdout.maxWrite)
+	}
+}
+
+func TestExecuteCommandStderr(t *testing.T) {
+	var stdout, stderr strings.Builder
+	out := newExecOutput(&stdout, &stderr, false)
+
+	// Standard error is passed through even when the command succeeds
+	if err := executeCommand(context.Background(), "echo out; echo warning >&2", nil, "a.txt", out, 0); err != nil {
+		t.Fatalf("executeCommand failed: %v", err)
+	}
+	if stdout.String() != "out\n" || stderr.String() != "warning\n" {
+		t.Errorf("Unexpected output %q and %q", stdout.String(), stderr.String())
+	}
+
+	// Failures name the entry and carry the exit status
+	err := executeCommand(context.Background(), "exit 3", nil, "a.txt", out, 0)
+	var exitErr *exec.ExitError
+	if err == nil || !strings.Contains(err.Error(), "a.txt") || !errors.As(err, &exitErr) || exitErr.ExitCode() != 3 {
+		t.Errorf("Expected exit status 3 for a.txt, got %v", err)
+	}
+}
+
+func TestExecuteCommandPrefix(t *testing.T) {
+	var stdout, stderr strings.Builder
+	out := newExecOutput(&stdout, &stderr, true)
+	cmd := `printf 'one\ntwo\n'; echo oops >&2; printf 'three'`
+	if err := executeCommand(context.Background(), cmd, nil, "dir/a.txt", out, 0); err != nil {
+		t.Fatalf("executeCommand failed: %v", err)
+	}
+	if want := "dir/a.txt: one\ndir/a.txt: two\ndir/a.txt: three\n"; stdout.String() != want {
+		t.Errorf("Expected %q, got %q", want, stdout.String())
+	}
+	if want := "dir/a.txt: oops\n"; stderr.String() != want {
+		t.Errorf("Expected %q, got %q", want, stderr.String())
+	}
+}
+
+func TestExecuteCommandPrefixConcurrent(t *testing.T) {
+	var stdout strings.Builder
+	out := newExecOutput(&stdout, &countingWriter{}, true)
+
+	// Lines of concurrent commands never interleave
+	var wg sync.WaitGroup
+	for _, path := range []string{"a", "b", "c", "d"} {
+		wg.Add(1)
+		go func(path string) {
+			defer wg.Done()
+			if err := executeCommand(context.Background(), "seq 1 2000", nil, path, out, 0); err != nil {
+				t.Errorf("executeCommand failed: %v", err)
+			}
+		}(path)
+	}
+	wg.Wait()
+
+	lines := strings.Split(strings.TrimSuffix(stdout.String(), "\n"), "\n")
+	if len(lines) != 4*2000 {
+		t.Fatalf("Expected %d lines, got %d", 4*2000, len(lines))
+	}
+	next := make(map[string]int)
+	for _, line := range lines {
+		path, n, ok := strings.Cut(line, ": ")
+		next[path]++
+		if !ok || n != strconv.Itoa(next[path]) {
+			t.Fatalf("Unexpected line %q", line)
+		}
+	}
+}
+
+func TestPrefixWriterLongLine(t *testing.T) {
+	var out strings.Builder
+	w := newPrefixWriter(&out, "p")
+
+	// A partial line is held until it reaches the bound
+	if _, err := w.Write([]byte(strings.Repeat("x", maxPrefixedLine-1))); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+	if out.Len() != 0 {
+		t.Errorf("Expected the partial line to be held, got %d bytes", out.Len())
+	}
+	if _, err := w.Write([]byte("yy")); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+	if want := "p: " + strings.Repeat("x", maxPrefixedLine-1) + "yy\n"; out.String() != want {
+		t.Errorf("Expected the line to be written once it reached the bound, got %d bytes", out.Len())
+	}
+	if err := w.flush(); err != nil || strings.Count(out.String(), "p: ") != 1 {
+		t.Errorf("Expected nothing left to flush, got %v", err)
+	}
+}
+
+func TestExecuteCommandGracePeriod(t *testing.T) {
+	cmd := `trap 'kill $!; echo stopping; exit 0' TERM; sleep 5 >/dev/null 2>&1 & wait`
+	for _, grace := range []time.Duration{0, 5 * time.Second} {
+		var stdout strings.Builder
+		out := newExecOutput(&stdout, &strings.Builder{}, false)
+		ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+		start := time.Now()
+		err := executeCommand(ctx, cmd, nil, "a.txt", out, grace)
+		cancel()
+		if err == nil {
+			t.Errorf("grace %v: expected the canceled command to fail", grace)
+		}
+		if elapsed := time.Since(start); elapsed > 3*time.Second {
+			t.Errorf("grace %v: expected the command to stop early, took %v", grace, elapsed)
+		}
+
+		// Only a grace period lets the command clean up
+		if got, want := stdout.String(), map[bool]string{false: "", true: "stopping\n"}[grace > 0]; got != want {
+			t.Errorf("grace %v: expected output %q, got %q", grace, want, got)
+		}
+	}
+}
@@ -0,0 +1,113 @@
+package stride
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+)
+
+// ExecRoute sends the matches of FindWithExecRoutes whose base name
+// matches Match to the command CmdTemplate.
+type ExecRoute struct {
+	Match       string // Glob matched against the base name, such as "*.png"
+	CmdTemplate string // Command template, as for FindWithExec
+}
+
+// execRouter picks the command of each match from parsed routes.
+type execRouter struct {
+	globs     []string
+	templates []*Template
+	fallback  *Template // For matches no route takes, or nil to skip them
+}
+
+// newExecRouter parses routes and the template of fallback, which may be
+// empty, so that a bad route fails before the search starts.
+func newExecRouter(routes []ExecRoute, fallback string) (*execRouter, error) {
+	if len(routes) == 0 {
+		return nil, errors.New("no exec routes")
+	}
+	r := &execRouter{}
+	for i, route := range routes {
+		if route.Match == "" {
+			return nil, fmt.Errorf("exec route %d: empty pattern", i+1)
+		}
+		if _, err := filepath.Match(route.Match, ""); err != nil {
+			return nil, fmt.Errorf("exec route %d: invalid pattern %q: %w", i+1, route.Match, err)
+		}
+		t, err := ParseTemplate(route.CmdTemplate)
+		if err != nil {
+			return nil, fmt.Errorf("exec route %d: %w", i+1, err)
+		}
+		r.globs = append(r.globs, route.Match)
+		r.templates = append(r.templates, t)
+	}
+	if fallback != "" {
+		t, err := ParseTemplate(fallback)
+		if err != nil {
+			return nil, fmt.Errorf("default command: %w", err)
+		}
+		r.fallback = t
+	}
+	return r, nil
+}
+
+// route returns the template of the first route matching msg, the
+// fallback if none does, or nil if msg is skipped.
+func (r *execRouter) route(msg FindMessage) *Template {
+	for i, glob := range r.globs {
+		if matched, _ := filepath.Match(glob, msg.Name); matched {
+			return r.templates[i]
+		}
+	}
+	return r.fallback
+}
+
+// usesOwner reports whether any template references the owner or group.
+func (r *execRouter) usesOwner() bool {
+	return r.any((*Template).usesOwner)
+}
+
+// usesACL reports whether any template references the ACL.
+func (r *execRouter) usesACL() bool {
+	return r.any((*Template).usesACL)
+}
+
+func (r *execRouter) any(uses func(*Template) bool) bool {
+	for _, t := range r.templates {
+		if uses(t) {
+			return true
+		}
+	}
+	return r.fallback != nil && uses(r.fallback)
+}
+
+// execRoutesHandler returns a handler executing the command routed to each
+// found file, if any.
+func execRoutesHandler(router *execRouter, env *commandEnv, out execOutput) FindHandler {
+	return func(ctx context.Context, result FindResult) error {
+		if result.Error != nil {
+			return result.Error
+		}
+		t := router.route(result.Message)
+		if t == nil {
+			return nil
+		}
+		return executeCommand(ctx, t.command(result.Message, ""), env.forFind(result.Message), result.Message.Path, out, 0)
+	}
+}
+
+// FindWithExecRoutes searches for files once and executes, for each
+// match, the command of the first of opts.ExecRoutes whose pattern matches
+// its base name, or opts.DefaultCmd if none does. Matches with neither are
+// skipped. Commands are run as by FindWithExec, and invalid routes are
+// reported before the search starts.
+func FindWithExecRoutes(ctx context.Context, root string, opts FindOptions) error {
+	router, err := newExecRouter(opts.ExecRoutes, opts.DefaultCmd)
+	if err != nil {
+		return err
+	}
+	opts.ResolveOwner = opts.ResolveOwner || router.usesOwner()
+	opts.LoadACLs = opts.LoadACLs || router.usesACL()
+	return Find(ctx, root, opts, execRoutesHandler(router, newCommandEnv(execEnvEnabled(opts.ExecEnv)), newExecOutput(opts.Output, opts.ErrOutput, opts.ExecPrefixOutput)))
+}
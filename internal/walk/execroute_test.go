@@ -0,0 +1,102 @@
+package stride
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/TFMV/stride/walk/walktest"
+)
+
+func TestFindWithExecRoutes(t *testing.T) {
+	root := walktest.Tree{
+		"a.png":        walktest.File{Content: "png"},
+		"b.png":        walktest.File{Content: "png"},
+		"app.log":      walktest.File{Content: "log"},
+		"notes.txt":    walktest.File{Content: "txt"},
+		"sub/deep.log": walktest.File{Content: "log"},
+	}.Build(t)
+
+	// touch returns a command recording each file it runs for in out
+	touch := func(out, suffix string) string {
+		return "touch " + filepath.Join(out, "{base}."+suffix)
+	}
+
+	tests := []struct {
+		name     string
+		routes   func(out string) []ExecRoute
+		fallback func(out string) string
+		expected []string // Side-effect files the commands create
+	}{
+		{
+			name: "no default",
+			routes: func(out string) []ExecRoute {
+				return []ExecRoute{{Match: "*.png", CmdTemplate: touch(out, "converted")}, {Match: "*.log", CmdTemplate: touch(out, "gzipped")}}
+			},
+			expected: []string{"a.png.converted", "app.log.gzipped", "b.png.converted", "deep.log.gzipped"},
+		},
+		{
+			name: "first match wins",
+			routes: func(out string) []ExecRoute {
+				return []ExecRoute{{Match: "app.*", CmdTemplate: touch(out, "first")}, {Match: "*.log", CmdTemplate: touch(out, "second")}}
+			},
+			expected: []string{"app.log.first", "deep.log.second"},
+		},
+		{
+			name: "default",
+			routes: func(out string) []ExecRoute {
+				return []ExecRoute{{Match: "*.png", CmdTemplate: touch(out, "converted")}}
+			},
+			fallback: func(out string) string { return touch(out, "other") },
+			expected: []string{"a.png.converted", "app.log.other", "b.png.converted", "deep.log.other", "notes.txt.other"},
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			out := t.TempDir()
+			opts := FindOptions{MaxDepth: 2, ExecRoutes: tc.routes(out)}
+			if tc.fallback != nil {
+				opts.DefaultCmd = tc.fallback(out)
+			}
+			if err := FindWithExecRoutes(context.Background(), root, opts); err != nil {
+				t.Fatalf("FindWithExecRoutes failed: %v", err)
+			}
+			entries, err := os.ReadDir(out)
+			if err != nil {
+				t.Fatalf("Failed to read output: %v", err)
+			}
+			var got []string
+			for _, e := range entries {
+				got = append(got, e.Name())
+			}
+			sort.Strings(got)
+			if strings.Join(got, ",") != strings.Join(tc.expected, ",") {
+				t.Errorf("Expected commands to create %v, got %v", tc.expected, got)
+			}
+		})
+	}
+}
+
+func TestFindWithExecRoutesInvalid(t *testing.T) {
+	root := t.TempDir()
+	tests := []struct {
+		name string
+		opts FindOptions
+	}{
+		{"no routes", FindOptions{}},
+		{"empty pattern", FindOptions{ExecRoutes: []ExecRoute{{CmdTemplate: "true"}}}},
+		{"bad pattern", FindOptions{ExecRoutes: []ExecRoute{{Match: "[", CmdTemplate: "true"}}}},
+		{"bad template", FindOptions{ExecRoutes: []ExecRoute{{Match: "*", CmdTemplate: "echo {nope}"}}}},
+		{"bad default", FindOptions{ExecRoutes: []ExecRoute{{Match: "*", CmdTemplate: "true"}}, DefaultCmd: "echo {nope}"}},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if err := FindWithExecRoutes(context.Background(), root, tc.opts); err == nil {
+				t.Error("Expected an error")
+			}
+		})
+	}
+}
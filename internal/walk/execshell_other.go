@@ -0,0 +1,21 @@
+//go:build !windows
+
+package stride
+
+import (
+	"context"
+	"os/exec"
+	"strings"
+)
+
+// shellCommand returns the command running cmdStr with sh -c.
+func shellCommand(ctx context.Context, cmdStr string) *exec.Cmd {
+	return exec.CommandContext(ctx, "sh", "-c", cmdStr)
+}
+
+// shellQuote quotes s as one word for sh: within single quotes nothing is
+// special, so each single quote in s closes them, is escaped with a
+// backslash and opens them again.
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
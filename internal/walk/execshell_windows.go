@@ -0,0 +1,25 @@
+package stride
+
+import (
+	"context"
+	"os/exec"
+	"strings"
+	"syscall"
+)
+
+// shellCommand returns the command running cmdStr with cmd /C. The command
+// line is passed as written, since cmd does not parse arguments the way
+// exec escapes them; with /S, the outer quotes are removed and the rest is
+// run unchanged.
+func shellCommand(ctx context.Context, cmdStr string) *exec.Cmd {
+	cmd := exec.CommandContext(ctx, "cmd")
+	cmd.SysProcAttr = &syscall.SysProcAttr{CmdLine: `cmd /S /C "` + cmdStr + `"`}
+	return cmd
+}
+
+// shellQuote quotes s as one word for cmd, doubling its double quotes.
+// Variables such as %PATH% are still expanded by cmd; commands reading
+// STRIDE_* variables avoid that.
+func shellQuote(s string) string {
+	return `"` + strings.ReplaceAll(s, `"`, `""`) + `"`
+}
@@ -0,0 +1,60 @@
+package stride
+
+import (
+	"strings"
+)
+
+// PredicateResult is the outcome of one search criterion for an entry.
+type PredicateResult struct {
+	Name   string // Criterion, e.g. "name", "larger_than" or "hash_list"
+	OK     bool   // Whether the entry satisfied it
+	Detail string // Why it did not, e.g. "12<=1024" for a size; may be empty
+}
+
+// FindDecision explains whether an entry matched the criteria of a search.
+type FindDecision struct {
+	Matched    bool              // Whether every criterion was satisfied
+	Predicates []PredicateResult // Every criterion in use, in evaluation order
+}
+
+// String formats d as a compact trace such as
+// "name:ok older_than:FAIL(2h0m0s<=36h0m0s)".
+func (d FindDecision) String() string {
+	var b strings.Builder
+	for i, p := range d.Predicates {
+		if i > 0 {
+			b.WriteByte(' ')
+		}
+		b.WriteString(p.Name)
+		switch {
+		case p.OK:
+			b.WriteString(":ok")
+		case p.Detail != "":
+			b.WriteString(":FAIL(" + p.Detail + ")")
+		default:
+			b.WriteString(":FAIL")
+		}
+	}
+	return b.String()
+}
+
+// add records the outcome of a criterion.
+func (d *FindDecision) add(name string, ok bool, detail string) {
+	d.Predicates = append(d.Predicates, PredicateResult{Name: name, OK: ok, Detail: detail})
+	d.Matched = d.Matched && ok
+}
+
+// explain evaluates every criterion of m against msg. Unlike match, it does
+// not stop at the first failure, so the trace is complete.
+func (m *findMatcher) explain(msg FindMessage) FindDecision {
+	d := FindDecision{Matched: true}
+	for _, p := range m.predicates {
+		ok := p.test(msg)
+		var detail string
+		if !ok && p.detail != nil {
+			detail = p.detail(msg)
+		}
+		d.add(p.name, ok, detail)
+	}
+	return d
+}
@@ -0,0 +1,132 @@
+package stride
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestExplainFind(t *testing.T) {
+	msg := FindMessage{
+		Path: "/data/logs/app.log",
+		Name: "app.log",
+		Size: 12,
+		Time: time.Now().Add(-48 * time.Hour),
+	}
+	opts := FindOptions{
+		NamePattern:  "*.log",
+		RegexPattern: regexp.MustCompile(`logs/`),
+		OlderThan:    36 * time.Hour,
+		LargerSize:   1024,
+	}
+
+	d := ExplainFind(opts, msg)
+	if d.Matched {
+		t.Error("Expected the entry not to match")
+	}
+	var failed []string
+	for _, p := range d.Predicates {
+		if !p.OK {
+			failed = append(failed, p.Name)
+		}
+	}
+	if len(failed) != 1 || failed[0] != "larger_than" {
+		t.Errorf("Expected only larger_than to fail, got %v", failed)
+	}
+	if want := "name:ok regex:ok older_than:ok larger_than:FAIL(12<=1024)"; d.String() != want {
+		t.Errorf("String() = %q, want %q", d.String(), want)
+	}
+
+	// Explaining agrees with matching
+	opts.LargerSize = 10
+	if d := ExplainFind(opts, msg); !d.Matched || !MatchFind(opts, msg) {
+		t.Errorf("Expected the entry to match, got %s", d)
+	}
+	if d := ExplainFind(FindOptions{}, msg); !d.Matched || len(d.Predicates) != 0 {
+		t.Errorf("Expected no criteria to match everything, got %+v", d)
+	}
+}
+
+func TestFindExplain(t *testing.T) {
+	root := t.TempDir()
+	for name, size := range map[string]int{"big.log": 2048, "small.log": 10, "other.txt": 2048} {
+		if err := os.WriteFile(filepath.Join(root, name), make([]byte, size), 0644); err != nil {
+			t.Fatalf("Failed to create file: %v", err)
+		}
+	}
+
+	var mu sync.Mutex
+	traces := make(map[string]string)
+	var matched []FindMessage
+	opts := FindOptions{
+		NamePattern: "*.log",
+		LargerSize:  1024,
+		MaxDepth:    1,
+		Explain:     true,
+		OnEvaluated: func(msg FindMessage, d FindDecision) {
+			mu.Lock()
+			defer mu.Unlock()
+			traces[msg.Name] = d.String()
+		},
+	}
+	err := Find(context.Background(), root, opts, func(ctx context.Context, result FindResult) error {
+		mu.Lock()
+		defer mu.Unlock()
+		matched = append(matched, result.Message)
+		return result.Error
+	})
+	if err != nil {
+		t.Fatalf("Find failed: %v", err)
+	}
+
+	want := map[string]string{
+		"big.log":   "name:ok larger_than:ok",
+		"small.log": "name:ok larger_than:FAIL(10<=1024)",
+		"other.txt": "name:FAIL larger_than:ok",
+	}
+	if len(traces) != len(want) {
+		t.Errorf("Expected every file to be evaluated, got %v", traces)
+	}
+	for name, trace := range want {
+		if traces[name] != trace {
+			t.Errorf("Trace for %s = %q, want %q", name, traces[name], trace)
+		}
+	}
+
+	if len(matched) != 1 || matched[0].Metadata["explain"] != want["big.log"] {
+		t.Errorf("Expected big.log to match with its trace, got %+v", matched)
+	}
+}
+
+func TestFindExplainHashList(t *testing.T) {
+	root := t.TempDir()
+	if err := os.WriteFile(filepath.Join(root, "a.txt"), []byte("a"), 0644); err != nil {
+		t.Fatalf("Failed to create file: %v", err)
+	}
+	list := filepath.Join(t.TempDir(), "hashes")
+	if err := os.WriteFile(list, []byte(strings.Repeat("0", 64)+"\n"), 0644); err != nil {
+		t.Fatalf("Failed to create hash list: %v", err)
+	}
+
+	var trace string
+	opts := FindOptions{
+		HashList: list,
+		MaxDepth: 1,
+		Workers:  1,
+		Explain:  true,
+		OnEvaluated: func(msg FindMessage, d FindDecision) {
+			trace = d.String()
+		},
+	}
+	if err := Find(context.Background(), root, opts, func(ctx context.Context, result FindResult) error { return nil }); err != nil {
+		t.Fatalf("Find failed: %v", err)
+	}
+	if trace != "hash_list:FAIL" {
+		t.Errorf("Expected the hash list to reject the file, got %q", trace)
+	}
+}
@@ -0,0 +1,194 @@
+package stride
+
+import (
+	"container/heap"
+	"sort"
+	"sync"
+	"time"
+)
+
+// DefaultExtremesK is the number of entries kept in each Extremes list when
+// WalkOptions.ExtremesK is not set.
+const DefaultExtremesK = 10
+
+// ExtremeEntry is an entry that stood out in a walk, with the measures it is
+// ranked by. Size and ModTime are zero for directories.
+type ExtremeEntry struct {
+	Path    string    `json:"path"`
+	Size    int64     `json:"size"`
+	Depth   int       `json:"depth"` // Levels below the root
+	ModTime time.Time `json:"mod_time"`
+}
+
+// Extremes lists the entries of a walk that stood out, most extreme first.
+// Ties are broken by path, so the lists do not depend on the order in which
+// the workers delivered the entries.
+type Extremes struct {
+	LargestFiles []ExtremeEntry `json:"largest_files"` // Files by size
+	DeepestPaths []ExtremeEntry `json:"deepest_paths"` // Files and directories by depth
+	LongestPaths []ExtremeEntry `json:"longest_paths"` // Files and directories by path length in bytes
+	OldestFiles  []ExtremeEntry `json:"oldest_files"`  // Files by modification time, oldest first
+	NewestFiles  []ExtremeEntry `json:"newest_files"`  // Files by modification time, newest first
+}
+
+// extremeRank reports whether a is more extreme than b.
+type extremeRank func(a, b ExtremeEntry) bool
+
+// byPath breaks ties between entries ranked equal.
+func byPath(a, b ExtremeEntry) bool { return a.Path < b.Path }
+
+var (
+	rankLargest extremeRank = func(a, b ExtremeEntry) bool {
+		if a.Size != b.Size {
+			return a.Size > b.Size
+		}
+		return byPath(a, b)
+	}
+	rankDeepest extremeRank = func(a, b ExtremeEntry) bool {
+		if a.Depth != b.Depth {
+			return a.Depth > b.Depth
+		}
+		return byPath(a, b)
+	}
+	rankLongest extremeRank = func(a, b ExtremeEntry) bool {
+		if len(a.Path) != len(b.Path) {
+			return len(a.Path) > len(b.Path)
+		}
+		return byPath(a, b)
+	}
+	rankOldest extremeRank = func(a, b ExtremeEntry) bool {
+		if !a.ModTime.Equal(b.ModTime) {
+			return a.ModTime.Before(b.ModTime)
+		}
+		return byPath(a, b)
+	}
+	rankNewest extremeRank = func(a, b ExtremeEntry) bool {
+		if !a.ModTime.Equal(b.ModTime) {
+			return a.ModTime.After(b.ModTime)
+		}
+		return byPath(a, b)
+	}
+)
+
+// topK keeps the k most extreme entries offered to it. The least extreme of
+// them is at the top of the heap, so a new entry is compared with it alone.
+type topK struct {
+	mu    sync.Mutex
+	k     int
+	rank  extremeRank
+	items []ExtremeEntry
+}
+
+func (t *topK) Len() int           { return len(t.items) }
+func (t *topK) Less(i, j int) bool { return t.rank(t.items[j], t.items[i]) }
+func (t *topK) Swap(i, j int)      { t.items[i], t.items[j] = t.items[j], t.items[i] }
+func (t *topK) Push(x any)         { t.items = append(t.items, x.(ExtremeEntry)) }
+func (t *topK) Pop() any {
+	last := t.items[len(t.items)-1]
+	t.items = t.items[:len(t.items)-1]
+	return last
+}
+
+// offer keeps e if it is among the k most extreme entries so far.
+func (t *topK) offer(e ExtremeEntry) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if len(t.items) < t.k {
+		heap.Push(t, e)
+	} else if t.rank(e, t.items[0]) {
+		t.items[0] = e
+		heap.Fix(t, 0)
+	}
+}
+
+// sorted returns the entries kept, most extreme first.
+func (t *topK) sorted() []ExtremeEntry {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return rankEntries(append([]ExtremeEntry(nil), t.items...), t.rank, t.k)
+}
+
+// rankEntries sorts entries most extreme first and keeps the first k.
+func rankEntries(entries []ExtremeEntry, rank extremeRank, k int) []ExtremeEntry {
+	sort.Slice(entries, func(i, j int) bool { return rank(entries[i], entries[j]) })
+	if len(entries) > k {
+		entries = entries[:k]
+	}
+	return entries
+}
+
+// extremesCollector gathers the Extremes of a walk from its workers.
+type extremesCollector struct {
+	largest, deepest, longest, oldest, newest topK
+}
+
+// newExtremesCollector returns a collector keeping k entries per list, or
+// DefaultExtremesK if k is not positive.
+func newExtremesCollector(k int) *extremesCollector {
+	if k <= 0 {
+		k = DefaultExtremesK
+	}
+	return &extremesCollector{
+		largest: topK{k: k, rank: rankLargest},
+		deepest: topK{k: k, rank: rankDeepest},
+		longest: topK{k: k, rank: rankLongest},
+		oldest:  topK{k: k, rank: rankOldest},
+		newest:  topK{k: k, rank: rankNewest},
+	}
+}
+
+// add records an entry delivered to the callback at the given depth.
+func (c *extremesCollector) add(path string, size int64, modTime time.Time, depth int, dir bool) {
+	e := ExtremeEntry{Path: path, Depth: depth}
+	c.deepest.offer(e)
+	c.longest.offer(e)
+	if dir {
+		return
+	}
+	e.Size, e.ModTime = size, modTime
+	c.largest.offer(e)
+	c.oldest.offer(e)
+	c.newest.offer(e)
+}
+
+// extremes returns the lists gathered so far.
+func (c *extremesCollector) extremes() *Extremes {
+	return &Extremes{
+		LargestFiles: c.largest.sorted(),
+		DeepestPaths: c.deepest.sorted(),
+		LongestPaths: c.longest.sorted(),
+		OldestFiles:  c.oldest.sorted(),
+		NewestFiles:  c.newest.sorted(),
+	}
+}
+
+// mergeExtremes combines the Extremes of several walks into lists of k
+// entries each, skipping nil ones. It returns nil if all are nil.
+func mergeExtremes(k int, all ...*Extremes) *Extremes {
+	if k <= 0 {
+		k = DefaultExtremesK
+	}
+	var merged *Extremes
+	for _, e := range all {
+		if e == nil {
+			continue
+		}
+		if merged == nil {
+			merged = &Extremes{}
+		}
+		merged.LargestFiles = append(merged.LargestFiles, e.LargestFiles...)
+		merged.DeepestPaths = append(merged.DeepestPaths, e.DeepestPaths...)
+		merged.LongestPaths = append(merged.LongestPaths, e.LongestPaths...)
+		merged.OldestFiles = append(merged.OldestFiles, e.OldestFiles...)
+		merged.NewestFiles = append(merged.NewestFiles, e.NewestFiles...)
+	}
+	if merged == nil {
+		return nil
+	}
+	merged.LargestFiles = rankEntries(merged.LargestFiles, rankLargest, k)
+	merged.DeepestPaths = rankEntries(merged.DeepestPaths, rankDeepest, k)
+	merged.LongestPaths = rankEntries(merged.LongestPaths, rankLongest, k)
+	merged.OldestFiles = rankEntries(merged.OldestFiles, rankOldest, k)
+	merged.NewestFiles = rankEntries(merged.NewestFiles, rankNewest, k)
+	return merged
+}
@@ -0,0 +1,131 @@
+package stride
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/TFMV/stride/walk/walktest"
+)
+
+func TestCollectExtremes(t *testing.T) {
+	day := func(n int) time.Time { return time.Date(2024, 1, n, 12, 0, 0, 0, time.UTC) }
+	root := walktest.Tree{
+		"a.txt":                          walktest.File{Size: 10, ModTime: day(1)},
+		"big.bin":                        walktest.File{Size: 500, ModTime: day(5)},
+		"mid.bin":                        walktest.File{Size: 300, ModTime: day(3)},
+		"small.txt":                      walktest.File{Size: 5, ModTime: day(4)},
+		"x/very-long-file-name-here.txt": walktest.File{Size: 50, ModTime: day(6)},
+		"x/y/two.txt":                    walktest.File{Size: 200, ModTime: day(2)},
+		"x/y/z/deep.txt":                 walktest.File{Size: 1, ModTime: day(7)},
+	}.Build(t)
+
+	opts := WalkOptions{NumWorkers: 8, CollectExtremes: true, ExtremesK: 3}
+	stats, err := WalkLimitWithOptionsStats(context.Background(), root, func(path string, info os.FileInfo, err error) error {
+		return err
+	}, opts)
+	if err != nil {
+		t.Fatalf("Walk failed: %v", err)
+	}
+	if stats.Extremes == nil {
+		t.Fatal("Expected extremes in the final stats")
+	}
+
+	// Each list holds the relative paths and measures of its entries
+	type entry struct {
+		path    string
+		measure int64
+	}
+	list := func(entries []ExtremeEntry, measure func(ExtremeEntry) int64) []entry {
+		var got []entry
+		for _, e := range entries {
+			got = append(got, entry{relSlashPath(root, e.Path), measure(e)})
+		}
+		return got
+	}
+	size := func(e ExtremeEntry) int64 { return e.Size }
+	depth := func(e ExtremeEntry) int64 { return int64(e.Depth) }
+	length := func(e ExtremeEntry) int64 { return int64(len(e.Path) - len(root) - 1) }
+	modDay := func(e ExtremeEntry) int64 { return int64(e.ModTime.UTC().Day()) }
+
+	e := stats.Extremes
+	tests := []struct {
+		name     string
+		got      []entry
+		expected []entry
+	}{
+		{"Largest files", list(e.LargestFiles, size), []entry{{"big.bin", 500}, {"mid.bin", 300}, {"x/y/two.txt", 200}}},
+		// Ties are broken by path: two.txt before z
+		{"Deepest paths", list(e.DeepestPaths, depth), []entry{{"x/y/z/deep.txt", 4}, {"x/y/two.txt", 3}, {"x/y/z", 3}}},
+		{"Longest paths", list(e.LongestPaths, length), []entry{{"x/very-long-file-name-here.txt", 30}, {"x/y/z/deep.txt", 14}, {"x/y/two.txt", 11}}},
+		{"Oldest files", list(e.OldestFiles, modDay), []entry{{"a.txt", 1}, {"x/y/two.txt", 2}, {"mid.bin", 3}}},
+		{"Newest files", list(e.NewestFiles, modDay), []entry{{"x/y/z/deep.txt", 7}, {"x/very-long-file-name-here.txt", 6}, {"big.bin", 5}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if !reflect.DeepEqual(tt.got, tt.expected) {
+				t.Errorf("Expected %v, got %v", tt.expected, tt.got)
+			}
+		})
+	}
+
+	// Several roots are ranked together
+	other := walktest.Tree{
+		"huge.bin": walktest.File{Size: 1000, ModTime: day(9)},
+	}.Build(t)
+	stats, err = WalkRootsStats(context.Background(), []string{root, other}, func(path string, info os.FileInfo, err error) error {
+		return err
+	}, opts)
+	if err != nil {
+		t.Fatalf("Walk failed: %v", err)
+	}
+	var largest []string
+	for _, e := range stats.Extremes.LargestFiles {
+		largest = append(largest, filepath.Base(e.Path))
+	}
+	if expected := []string{"huge.bin", "big.bin", "mid.bin"}; !reflect.DeepEqual(largest, expected) {
+		t.Errorf("Expected %v across roots, got %v", expected, largest)
+	}
+	if newest := stats.Extremes.NewestFiles[0].Path; filepath.Base(newest) != "huge.bin" {
+		t.Errorf("Expected huge.bin to be the newest file, got %s", newest)
+	}
+
+	// Nothing is collected unless asked for
+	stats, err = WalkLimitWithOptionsStats(context.Background(), root, func(path string, info os.FileInfo, err error) error {
+		return err
+	}, WalkOptions{})
+	if err != nil {
+		t.Fatalf("Walk failed: %v", err)
+	}
+	if stats.Extremes != nil {
+		t.Errorf("Expected no extremes, got %+v", stats.Extremes)
+	}
+}
+
+func TestTopK(t *testing.T) {
+	tk := topK{k: 3, rank: rankLargest}
+	for i, size := range []int64{5, 1, 9, 7, 3, 9, 8, 2} {
+		tk.offer(ExtremeEntry{Path: string(rune('a' + i)), Size: size})
+	}
+
+	// The second 9 (f) ties with c and loses on path
+	var got []string
+	for _, e := range tk.sorted() {
+		got = append(got, e.Path)
+	}
+	if expected := []string{"c", "f", "g"}; !reflect.DeepEqual(got, expected) {
+		t.Errorf("Expected %v, got %v", expected, got)
+	}
+
+	// The default length applies when K is not set
+	c := newExtremesCollector(0)
+	for i := 0; i < 2*DefaultExtremesK; i++ {
+		c.add(filepath.Join("dir", string(rune('a'+i))), int64(i), time.Time{}, 1, false)
+	}
+	if n := len(c.extremes().LargestFiles); n != DefaultExtremesK {
+		t.Errorf("Expected %d entries, got %d", DefaultExtremesK, n)
+	}
+}
@@ -0,0 +1,56 @@
+package stride
+
+import (
+	"context"
+	"os"
+	"sort"
+)
+
+// DirFanoutInfo holds the number of direct entries of a directory.
+type DirFanoutInfo struct {
+	Path        string // Path of the directory, as walked
+	DirectFiles int    // Direct entries that are not directories
+	DirectDirs  int    // Direct entries that are directories
+}
+
+// Entries returns the number of direct entries of the directory.
+func (d DirFanoutInfo) Entries() int {
+	return d.DirectFiles + d.DirectDirs
+}
+
+// fanoutCollector keeps the directories whose direct entries exceed a
+// threshold. Counts are reported by a dirTracker, so only the directories
+// kept take memory. A nil *fanoutCollector ignores them.
+type fanoutCollector struct {
+	threshold int
+	dirs      []DirFanoutInfo
+}
+
+// record reports the direct entries of the directory at path.
+func (c *fanoutCollector) record(path string, files, dirs int) {
+	if c == nil || files+dirs <= c.threshold {
+		return
+	}
+	c.dirs = append(c.dirs, DirFanoutInfo{Path: path, DirectFiles: files, DirectDirs: dirs})
+}
+
+// DirFanout walks the tree rooted at root and returns the directories with
+// more than threshold direct entries, those with the most entries first.
+// Entries are counted as the walk enumerates them, so no directory is read
+// twice. Directories the walk does not enter, because of opts.Filter or a
+// read error, are not reported.
+func DirFanout(ctx context.Context, root string, threshold int, opts WalkOptions) ([]DirFanoutInfo, error) {
+	fanout := &fanoutCollector{threshold: threshold}
+	_, err := walkLimitWithOptions(ctx, root, func(path string, info os.FileInfo, err error) error {
+		return nil
+	}, opts, true, fanout)
+
+	sort.Slice(fanout.dirs, func(i, j int) bool {
+		a, b := fanout.dirs[i], fanout.dirs[j]
+		if a.Entries() != b.Entries() {
+			return a.Entries() > b.Entries()
+		}
+		return a.Path < b.Path
+	})
+	return fanout.dirs, err
+}
@@ -0,0 +1,60 @@
+package stride
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestDirFanout(t *testing.T) {
+	root := t.TempDir()
+	big := filepath.Join(root, "big")
+	for _, dir := range []string{filepath.Join(big, "sub1"), filepath.Join(big, "sub2"), filepath.Join(root, "small", "nested")} {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatalf("Failed to create directory: %v", err)
+		}
+	}
+	for i := 0; i < 5000; i++ {
+		f, err := os.Create(filepath.Join(big, fmt.Sprintf("file%04d", i)))
+		if err != nil {
+			t.Fatalf("Failed to create file: %v", err)
+		}
+		f.Close()
+	}
+	for i := 0; i < 10; i++ {
+		if err := os.WriteFile(filepath.Join(root, "small", fmt.Sprint(i)), nil, 0644); err != nil {
+			t.Fatalf("Failed to create file: %v", err)
+		}
+	}
+
+	dirs, err := DirFanout(context.Background(), root, 100, WalkOptions{NumWorkers: 4})
+	if err != nil {
+		t.Fatalf("DirFanout failed: %v", err)
+	}
+	want := []DirFanoutInfo{{Path: big, DirectFiles: 5000, DirectDirs: 2}}
+	if !reflect.DeepEqual(dirs, want) {
+		t.Errorf("Expected %+v, got %+v", want, dirs)
+	}
+
+	// Lowering the threshold reports the small directory after the big one
+	dirs, err = DirFanout(context.Background(), root, 2, WalkOptions{})
+	if err != nil {
+		t.Fatalf("DirFanout failed: %v", err)
+	}
+	want = append(want, DirFanoutInfo{Path: filepath.Join(root, "small"), DirectFiles: 10, DirectDirs: 1})
+	if !reflect.DeepEqual(dirs, want) {
+		t.Errorf("Expected %+v, got %+v", want, dirs)
+	}
+
+	// Directories the walk does not enter are not reported
+	dirs, err = DirFanout(context.Background(), root, 2, WalkOptions{Filter: FilterOptions{ExcludeDir: []string{"big"}}})
+	if err != nil {
+		t.Fatalf("DirFanout failed: %v", err)
+	}
+	if len(dirs) != 1 || dirs[0].Path != filepath.Join(root, "small") {
+		t.Errorf("Expected only the small directory, got %+v", dirs)
+	}
+}
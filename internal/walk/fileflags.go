@@ -0,0 +1,85 @@
+package stride
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// FileFlags is a set of the file flags and attributes that the platforms
+// keep beside the permission bits: chattr(1) flags on Linux, chflags(1)
+// flags on macOS and the BSDs, and file attributes on Windows. Flags a
+// platform does not have are never set.
+type FileFlags uint32
+
+const (
+	FlagImmutable  FileFlags = 1 << iota // Cannot be changed, renamed or removed (Linux, macOS, BSD)
+	FlagAppendOnly                       // Can only be appended to (Linux, macOS, BSD)
+	FlagNoDump                           // Left out by dump(8) backups (Linux, macOS, BSD)
+	FlagReadOnly                         // FILE_ATTRIBUTE_READONLY (Windows)
+	FlagHidden                           // Hidden from listings (Windows, macOS)
+	FlagSystem                           // FILE_ATTRIBUTE_SYSTEM (Windows)
+)
+
+// fileFlagNames names each flag for ParseFileFlags and String.
+var fileFlagNames = []struct {
+	flag FileFlags
+	name string
+}{
+	{FlagImmutable, "immutable"},
+	{FlagAppendOnly, "appendonly"},
+	{FlagNoDump, "nodump"},
+	{FlagReadOnly, "readonly"},
+	{FlagHidden, "hidden"},
+	{FlagSystem, "system"},
+}
+
+// ParseFileFlags parses a comma-separated list of flag names, such as
+// "immutable,appendonly": immutable, appendonly, nodump, readonly, hidden
+// and system.
+func ParseFileFlags(s string) (FileFlags, error) {
+	var flags FileFlags
+	for _, name := range strings.Split(s, ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+		found := false
+		for _, f := range fileFlagNames {
+			if f.name == name {
+				flags |= f.flag
+				found = true
+				break
+			}
+		}
+		if !found {
+			return 0, fmt.Errorf("unknown file flag %q", name)
+		}
+	}
+	return flags, nil
+}
+
+// String returns the names of the flags in f, separated by commas.
+func (f FileFlags) String() string {
+	var names []string
+	for _, n := range fileFlagNames {
+		if f&n.flag != 0 {
+			names = append(names, n.name)
+		}
+	}
+	return strings.Join(names, ",")
+}
+
+// readFileFlags returns the flags of the file at path, described by info.
+// It is platformFileFlags, replaceable in tests.
+var readFileFlags = platformFileFlags
+
+// fileFlagsOf returns the flags of the file at path, or none if they cannot
+// be read, as on filesystems that keep no flags.
+func fileFlagsOf(path string, info os.FileInfo) FileFlags {
+	flags, err := readFileFlags(path, info)
+	if err != nil {
+		return 0
+	}
+	return flags
+}
@@ -0,0 +1,48 @@
+//go:build darwin || dragonfly || freebsd || netbsd || openbsd
+
+package stride
+
+import (
+	"os"
+	"syscall"
+)
+
+// File flags of chflags(2), the same on each of these platforms
+const (
+	ufNoDump    = 0x1
+	ufImmutable = 0x2
+	ufAppend    = 0x4
+	ufHidden    = 0x8000 // macOS and FreeBSD only
+	sfImmutable = 0x20000
+	sfAppend    = 0x40000
+)
+
+// platformFileFlags takes the file flags from the stat data of info, which
+// costs no system call, and stats path only if info has none. Flags set by
+// the user and by the superuser count alike.
+func platformFileFlags(path string, info os.FileInfo) (FileFlags, error) {
+	st, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		var stat syscall.Stat_t
+		if err := syscall.Lstat(path, &stat); err != nil {
+			return 0, &os.PathError{Op: "lstat", Path: path, Err: err}
+		}
+		st = &stat
+	}
+
+	attrs := uint64(st.Flags)
+	var flags FileFlags
+	if attrs&(ufImmutable|sfImmutable) != 0 {
+		flags |= FlagImmutable
+	}
+	if attrs&(ufAppend|sfAppend) != 0 {
+		flags |= FlagAppendOnly
+	}
+	if attrs&ufNoDump != 0 {
+		flags |= FlagNoDump
+	}
+	if attrs&ufHidden != 0 {
+		flags |= FlagHidden
+	}
+	return flags, nil
+}
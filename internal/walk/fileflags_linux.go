@@ -0,0 +1,45 @@
+package stride
+
+import (
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// Inode flags of chattr(1), from linux/fs.h
+const (
+	fsImmutableFL = 0x10
+	fsAppendFL    = 0x20
+	fsNoDumpFL    = 0x40
+)
+
+// platformFileFlags reads the inode flags of a regular file or directory
+// with the FS_IOC_GETFLAGS ioctl, which needs the file to be opened. Other
+// files, which cannot carry flags and may have side effects when opened,
+// have none.
+func platformFileFlags(path string, info os.FileInfo) (FileFlags, error) {
+	if !info.Mode().IsRegular() && !info.IsDir() {
+		return 0, nil
+	}
+	fd, err := unix.Open(path, unix.O_RDONLY|unix.O_NONBLOCK|unix.O_CLOEXEC, 0)
+	if err != nil {
+		return 0, &os.PathError{Op: "open", Path: path, Err: err}
+	}
+	defer unix.Close(fd)
+	attrs, err := unix.IoctlGetUint32(fd, unix.FS_IOC_GETFLAGS)
+	if err != nil {
+		return 0, &os.PathError{Op: "ioctl", Path: path, Err: err}
+	}
+
+	var flags FileFlags
+	if attrs&fsImmutableFL != 0 {
+		flags |= FlagImmutable
+	}
+	if attrs&fsAppendFL != 0 {
+		flags |= FlagAppendOnly
+	}
+	if attrs&fsNoDumpFL != 0 {
+		flags |= FlagNoDump
+	}
+	return flags, nil
+}
@@ -0,0 +1,55 @@
+package stride
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"golang.org/x/sys/unix"
+)
+
+// setInodeFlags sets the chattr(1) flags of path.
+func setInodeFlags(path string, attrs int) error {
+	fd, err := unix.Open(path, unix.O_RDONLY|unix.O_CLOEXEC, 0)
+	if err != nil {
+		return err
+	}
+	defer unix.Close(fd)
+	return unix.IoctlSetPointerInt(fd, unix.FS_IOC_SETFLAGS, attrs)
+}
+
+func TestPlatformFileFlagsLinux(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "locked.txt")
+	if err := os.WriteFile(path, []byte("x"), 0644); err != nil {
+		t.Fatalf("Failed to create file: %v", err)
+	}
+
+	if err := setInodeFlags(path, fsNoDumpFL); err != nil {
+		t.Skipf("Cannot set inode flags here: %v", err)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("Failed to stat file: %v", err)
+	}
+	if flags, err := platformFileFlags(path, info); err != nil || flags != FlagNoDump {
+		t.Errorf("Expected nodump, got %v (%v)", flags, err)
+	}
+
+	// The immutable and append-only flags need privileges; clear them
+	// afterwards so the directory can be removed
+	if err := setInodeFlags(path, fsImmutableFL|fsAppendFL); err != nil {
+		if errors.Is(err, unix.EPERM) {
+			t.Skip("Setting the immutable flag needs CAP_LINUX_IMMUTABLE")
+		}
+		t.Fatalf("Failed to set inode flags: %v", err)
+	}
+	t.Cleanup(func() { setInodeFlags(path, 0) })
+	if flags, err := platformFileFlags(path, info); err != nil || flags != FlagImmutable|FlagAppendOnly {
+		t.Errorf("Expected immutable and appendonly, got %v (%v)", flags, err)
+	}
+	if err := os.WriteFile(path, []byte("y"), 0644); err == nil {
+		t.Error("Expected an immutable file to refuse writes")
+	}
+}
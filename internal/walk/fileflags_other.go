@@ -0,0 +1,13 @@
+//go:build !darwin && !dragonfly && !freebsd && !linux && !netbsd && !openbsd && !windows
+
+package stride
+
+import (
+	"errors"
+	"os"
+)
+
+// platformFileFlags is not supported on this platform.
+func platformFileFlags(path string, info os.FileInfo) (FileFlags, error) {
+	return 0, errors.New("file flags are not supported on this platform")
+}
@@ -0,0 +1,164 @@
+package stride
+
+import (
+	"context"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/TFMV/stride/walk/walktest"
+)
+
+// fakeFileFlags replaces readFileFlags with the flags given by base name for
+// the duration of the test, returning a count of the reads.
+func fakeFileFlags(t *testing.T, flags map[string]FileFlags) *int64 {
+	var reads int64
+	orig := readFileFlags
+	readFileFlags = func(path string, info os.FileInfo) (FileFlags, error) {
+		atomic.AddInt64(&reads, 1)
+		return flags[filepath.Base(path)], nil
+	}
+	t.Cleanup(func() { readFileFlags = orig })
+	return &reads
+}
+
+func TestParseFileFlags(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    FileFlags
+		wantErr bool
+	}{
+		{"", 0, false},
+		{"immutable", FlagImmutable, false},
+		{"immutable,appendonly", FlagImmutable | FlagAppendOnly, false},
+		{" NoDump , readonly,", FlagNoDump | FlagReadOnly, false},
+		{"hidden,system", FlagHidden | FlagSystem, false},
+		{"immutable,sticky", 0, true},
+	}
+	for _, tt := range tests {
+		got, err := ParseFileFlags(tt.input)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseFileFlags(%q): expected error %v, got %v", tt.input, tt.wantErr, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("ParseFileFlags(%q): expected %v, got %v", tt.input, tt.want, got)
+		}
+	}
+
+	all := FlagImmutable | FlagAppendOnly | FlagNoDump | FlagReadOnly | FlagHidden | FlagSystem
+	if s := all.String(); s != "immutable,appendonly,nodump,readonly,hidden,system" {
+		t.Errorf("Unexpected names %q", s)
+	}
+	if parsed, err := ParseFileFlags(all.String()); err != nil || parsed != all {
+		t.Errorf("Expected the names to parse back, got %v (%v)", parsed, err)
+	}
+}
+
+func TestFileFlagsFilter(t *testing.T) {
+	root := walktest.Tree{
+		"plain.txt":    walktest.File{},
+		"locked.txt":   walktest.File{},
+		"log.txt":      walktest.File{},
+		"both.txt":     walktest.File{},
+		"scratch.tmp":  walktest.File{},
+		"sub/deep.txt": walktest.File{},
+	}.Build(t)
+	reads := fakeFileFlags(t, map[string]FileFlags{
+		"locked.txt":  FlagImmutable,
+		"log.txt":     FlagAppendOnly,
+		"both.txt":    FlagImmutable | FlagAppendOnly | FlagNoDump,
+		"scratch.tmp": FlagNoDump,
+		"deep.txt":    FlagImmutable,
+	})
+
+	tests := []struct {
+		name    string
+		require FileFlags
+		exclude FileFlags
+		want    []string
+	}{
+		{"No flags", 0, 0, []string{"both.txt", "locked.txt", "log.txt", "plain.txt", "scratch.tmp", "sub/deep.txt"}},
+		{"Immutable", FlagImmutable, 0, []string{"both.txt", "locked.txt", "sub/deep.txt"}},
+		{"Immutable and append-only", FlagImmutable | FlagAppendOnly, 0, []string{"both.txt"}},
+		{"Exclude nodump", 0, FlagNoDump, []string{"locked.txt", "log.txt", "plain.txt", "sub/deep.txt"}},
+		{"Immutable without nodump", FlagImmutable, FlagNoDump, []string{"locked.txt", "sub/deep.txt"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			atomic.StoreInt64(reads, 0)
+			filter := FilterOptions{RequireFlags: tt.require, ExcludeFlags: tt.exclude}
+
+			var mu sync.Mutex
+			var seen []string
+			record := func(path string) {
+				mu.Lock()
+				seen = append(seen, relSlashPath(root, path))
+				mu.Unlock()
+			}
+			check := func(what string) {
+				t.Helper()
+				sort.Strings(seen)
+				if !reflect.DeepEqual(seen, tt.want) {
+					t.Errorf("%s: expected %q, got %q", what, tt.want, seen)
+				}
+				seen = nil
+			}
+
+			err := WalkLimitWithOptions(context.Background(), root, func(path string, info os.FileInfo, err error) error {
+				if err == nil && !info.IsDir() {
+					record(path)
+				}
+				return err
+			}, WalkOptions{NumWorkers: 2, Filter: filter})
+			if err != nil {
+				t.Fatalf("Walk failed: %v", err)
+			}
+			check("Walk")
+
+			err = WalkDir(root, func(ctx context.Context, path string, d fs.DirEntry) error {
+				if !d.IsDir() {
+					record(path)
+				}
+				return nil
+			}, WalkOptions{NumWorkers: 2, Filter: filter})
+			if err != nil {
+				t.Fatalf("WalkDir failed: %v", err)
+			}
+			check("WalkDir")
+
+			err = Find(context.Background(), root, FindOptions{MaxDepth: 2, RequireFlags: tt.require, ExcludeFlags: tt.exclude}, func(ctx context.Context, result FindResult) error {
+				if result.Error != nil {
+					return result.Error
+				}
+				record(result.Message.Path)
+				return nil
+			})
+			if err != nil {
+				t.Fatalf("Find failed: %v", err)
+			}
+			check("Find")
+
+			// Flags are only read when a flag filter is set
+			if n := atomic.LoadInt64(reads); (n == 0) != (tt.require == 0 && tt.exclude == 0) {
+				t.Errorf("Expected flags to be read only for flag filters, got %d reads", n)
+			}
+		})
+	}
+
+	// EvaluateFilter names the flag criteria
+	info, err := os.Stat(filepath.Join(root, "both.txt"))
+	if err != nil {
+		t.Fatalf("Failed to stat file: %v", err)
+	}
+	_, failed := EvaluateFilter(filepath.Join(root, "both.txt"), info, FilterOptions{RequireFlags: FlagReadOnly, ExcludeFlags: FlagNoDump})
+	if want := []string{"require_flags", "exclude_flags"}; !reflect.DeepEqual(failed, want) {
+		t.Errorf("Expected %q, got %q", want, failed)
+	}
+}
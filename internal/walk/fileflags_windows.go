@@ -0,0 +1,35 @@
+package stride
+
+import (
+	"os"
+	"syscall"
+)
+
+// platformFileFlags maps the read-only, hidden and system attributes of the
+// file, taken from info or read with GetFileAttributes if info has none.
+func platformFileFlags(path string, info os.FileInfo) (FileFlags, error) {
+	var attrs uint32
+	if data, ok := info.Sys().(*syscall.Win32FileAttributeData); ok {
+		attrs = data.FileAttributes
+	} else {
+		p, err := syscall.UTF16PtrFromString(path)
+		if err != nil {
+			return 0, err
+		}
+		if attrs, err = syscall.GetFileAttributes(p); err != nil {
+			return 0, &os.PathError{Op: "GetFileAttributes", Path: path, Err: err}
+		}
+	}
+
+	var flags FileFlags
+	if attrs&syscall.FILE_ATTRIBUTE_READONLY != 0 {
+		flags |= FlagReadOnly
+	}
+	if attrs&syscall.FILE_ATTRIBUTE_HIDDEN != 0 {
+		flags |= FlagHidden
+	}
+	if attrs&syscall.FILE_ATTRIBUTE_SYSTEM != 0 {
+		flags |= FlagSystem
+	}
+	return flags, nil
+}
@@ -0,0 +1,185 @@
+package stride
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+// rootCall is a callback invocation recorded by rootEntryPoints.
+type rootCall struct {
+	path string
+	mode os.FileMode
+	size int64
+}
+
+// rootEntryPoints runs each entry point on root with opts, returning the
+// callback invocations and error of each by name. Walk and WalkLimit, which
+// take no options, run only without filters, and Find only with the
+// symlink handling and filters it has options for.
+func rootEntryPoints(t *testing.T, root string, opts WalkOptions) (map[string][]rootCall, map[string]error) {
+	t.Helper()
+	var mu sync.Mutex
+	calls := make(map[string][]rootCall)
+	errs := make(map[string]error)
+	record := func(name, path string, info os.FileInfo) {
+		mu.Lock()
+		defer mu.Unlock()
+		calls[name] = append(calls[name], rootCall{path, info.Mode(), info.Size()})
+	}
+	walkFn := func(name string) filepath.WalkFunc {
+		return func(path string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			record(name, path, info)
+			return nil
+		}
+	}
+
+	if opts.Filter.Pattern == "" && len(opts.Filter.FileTypes) == 0 {
+		errs["Walk"] = Walk(root, walkFn("Walk"))
+		errs["WalkLimit"] = WalkLimit(context.Background(), root, walkFn("WalkLimit"), 2)
+	}
+	errs["WalkLimitWithOptions"] = WalkLimitWithOptions(context.Background(), root, walkFn("WalkLimitWithOptions"), opts)
+	errs["WalkWithOptions"] = WalkWithOptions(root, func(ctx context.Context, path string, info os.FileInfo) error {
+		record("WalkWithOptions", path, info)
+		return nil
+	}, opts)
+
+	findOpts := FindOptions{FollowSymlinks: opts.SymlinkHandling == SymlinkFollow, NamePattern: opts.Filter.Pattern}
+	if len(opts.Filter.FileTypes) == 0 && (opts.SymlinkHandling == SymlinkFollow || opts.SymlinkHandling == SymlinkIgnore) {
+		errs["Find"] = Find(context.Background(), root, findOpts, func(ctx context.Context, result FindResult) error {
+			if result.Error != nil {
+				return result.Error
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			calls["Find"] = append(calls["Find"], rootCall{result.Message.Path, result.Message.Mode, result.Message.Size})
+			return nil
+		})
+	}
+	return calls, errs
+}
+
+func TestFileRoot(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "a.txt")
+	if err := os.WriteFile(file, []byte("hello"), 0644); err != nil {
+		t.Fatalf("Failed to create file: %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		filter FilterOptions
+		want   int // Callback invocations expected of each entry point
+	}{
+		{"No filter", FilterOptions{}, 1},
+		{"Matching pattern", FilterOptions{Pattern: "*.txt"}, 1},
+		{"Rejecting pattern", FilterOptions{Pattern: "*.go"}, 0},
+		{"Rejecting type", FilterOptions{FileTypes: []string{"dir"}}, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls, errs := rootEntryPoints(t, file, WalkOptions{NumWorkers: 2, Filter: tt.filter})
+			for name, err := range errs {
+				if err != nil {
+					t.Errorf("%s: expected no error, got %v", name, err)
+				}
+				if len(calls[name]) != tt.want {
+					t.Errorf("%s: expected %d calls, got %v", name, tt.want, calls[name])
+					continue
+				}
+				if tt.want == 1 && (calls[name][0].path != file || calls[name][0].size != 5) {
+					t.Errorf("%s: expected %s with 5 bytes, got %v", name, file, calls[name][0])
+				}
+			}
+		})
+	}
+
+	// Stats count the root as one file with its size
+	stats, err := WalkLimitWithOptionsStats(context.Background(), file, func(path string, info os.FileInfo, err error) error {
+		return err
+	}, WalkOptions{NumWorkers: 2})
+	if err != nil {
+		t.Fatalf("Walk failed: %v", err)
+	}
+	if stats.FilesProcessed != 1 || stats.BytesProcessed != 5 || stats.DirsProcessed != 0 {
+		t.Errorf("Expected 1 file of 5 bytes and no directories, got %+v", stats)
+	}
+}
+
+func TestSymlinkFileRoot(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "a.txt")
+	if err := os.WriteFile(file, []byte("hello"), 0644); err != nil {
+		t.Fatalf("Failed to create file: %v", err)
+	}
+	link := filepath.Join(dir, "link")
+	if err := os.Symlink(file, link); err != nil {
+		t.Skipf("Symlinks not supported: %v", err)
+	}
+
+	tests := []struct {
+		handling SymlinkHandling
+		want     int
+		symlink  bool // Whether the link itself is reported rather than its target
+	}{
+		{SymlinkFollow, 1, false},
+		{SymlinkFollowInternal, 1, false},
+		{SymlinkReport, 1, true},
+		{SymlinkIgnore, 0, false},
+	}
+	for _, tt := range tests {
+		calls, errs := rootEntryPoints(t, link, WalkOptions{NumWorkers: 2, SymlinkHandling: tt.handling})
+		for name, err := range errs {
+			want, symlink := tt.want, tt.symlink
+			if name == "Walk" || name == "WalkLimit" {
+				// Like filepath.Walk, these report links without following them
+				want, symlink = 1, true
+			}
+			if err != nil {
+				t.Errorf("%v %s: expected no error, got %v", tt.handling, name, err)
+			}
+			if len(calls[name]) != want {
+				t.Errorf("%v %s: expected %d calls, got %v", tt.handling, name, want, calls[name])
+				continue
+			}
+			if want == 0 {
+				continue
+			}
+			call := calls[name][0]
+			if call.path != link {
+				t.Errorf("%v %s: expected the link's path, got %s", tt.handling, name, call.path)
+			}
+			if isLink := call.mode&os.ModeSymlink != 0; isLink != symlink {
+				t.Errorf("%v %s: expected a symlink to be %v, got mode %v", tt.handling, name, symlink, call.mode)
+			}
+			if !symlink && call.size != 5 {
+				t.Errorf("%v %s: expected the target's size, got %d", tt.handling, name, call.size)
+			}
+		}
+	}
+}
+
+func TestMissingRoot(t *testing.T) {
+	root := filepath.Join(t.TempDir(), "missing")
+	for _, handling := range []ErrorHandling{ErrorHandlingContinue, ErrorHandlingStop, ErrorHandlingSkip} {
+		calls, errs := rootEntryPoints(t, root, WalkOptions{NumWorkers: 2, ErrorHandling: handling})
+		errs["WalkDir"] = WalkDir(root, func(ctx context.Context, path string, d fs.DirEntry) error {
+			return nil
+		}, WalkOptions{NumWorkers: 2, ErrorHandling: handling})
+		for name, err := range errs {
+			if !errors.Is(err, fs.ErrNotExist) {
+				t.Errorf("%v %s: expected fs.ErrNotExist, got %v", handling, name, err)
+			}
+			if len(calls[name]) != 0 {
+				t.Errorf("%v %s: expected no calls, got %v", handling, name, calls[name])
+			}
+		}
+	}
+}
@@ -0,0 +1,25 @@
+package stride
+
+import (
+	"os"
+)
+
+// EvaluateFilter reports whether filter includes the file at path, described
+// by info, and names the criteria it fails, such as "min_size" or "pattern",
+// in evaluation order, so that a filter can be checked against known paths
+// before a long walk. Reference files are read on each call; one that cannot
+// be read fails the filter as "reference_file". Criteria that depend on the
+// walk root, namely depths, directory exclusions, include paths and path
+// patterns, are not evaluated.
+func EvaluateFilter(path string, info os.FileInfo, filter FilterOptions) (bool, []string) {
+	filter, err := resolveReferenceFiles(filter)
+	if err != nil {
+		return false, []string{"reference_file"}
+	}
+	var failed []string
+	included := checkFilter(path, info, filter, func(criterion string) bool {
+		failed = append(failed, criterion)
+		return true
+	})
+	return included, failed
+}
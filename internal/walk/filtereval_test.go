@@ -0,0 +1,65 @@
+package stride
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/TFMV/stride/walk/walktest"
+)
+
+func TestEvaluateFilter(t *testing.T) {
+	dir := walktest.Tree{"a.txt": walktest.File{Content: "0123456789", Mode: 0644}}.Build(t)
+	file := filepath.Join(dir, "a.txt")
+	hour := time.Hour
+	future, past := time.Now().Add(hour), time.Now().Add(-24*hour)
+
+	tests := []struct {
+		name   string
+		path   string
+		filter FilterOptions
+		want   []string
+	}{
+		{"included", file, FilterOptions{Pattern: "*.txt", MinSize: 5}, nil},
+		{"min size", file, FilterOptions{MinSize: 100}, []string{"min_size"}},
+		{"max size", file, FilterOptions{MaxSize: 5}, []string{"max_size"}},
+		{"modified after", file, FilterOptions{ModifiedAfter: future}, []string{"modified_after"}},
+		{"modified before", file, FilterOptions{ModifiedBefore: past}, []string{"modified_before"}},
+		{"accessed after", file, FilterOptions{AccessedAfter: future}, []string{"accessed_after"}},
+		{"accessed before", file, FilterOptions{AccessedBefore: past}, []string{"accessed_before"}},
+		{"created after", file, FilterOptions{CreatedAfter: future}, []string{"created_after"}},
+		{"created before", file, FilterOptions{CreatedBefore: past}, []string{"created_before"}},
+		{"owner uid", file, FilterOptions{OwnerUID: os.Getuid() + 1}, []string{"owner_uid"}},
+		{"owner gid", file, FilterOptions{OwnerGID: os.Getgid() + 1}, []string{"owner_gid"}},
+		{"owner", file, FilterOptions{OwnerName: "no-such-user"}, []string{"owner"}},
+		{"group", file, FilterOptions{GroupName: "no-such-group"}, []string{"group"}},
+		{"pattern", file, FilterOptions{Pattern: "*.go"}, []string{"pattern"}},
+		{"exclude pattern", file, FilterOptions{ExcludePattern: []string{"*.md", "a.*"}}, []string{"exclude_pattern"}},
+		{"include types", file, FilterOptions{IncludeTypes: []string{".go"}}, []string{"include_types"}},
+		{"file types", file, FilterOptions{FileTypes: []string{"dir"}}, []string{"file_types"}},
+		{"empty files", file, FilterOptions{IncludeEmptyFiles: true}, []string{"empty_files"}},
+		{"empty dirs", dir, FilterOptions{IncludeEmptyDirs: true}, []string{"empty_dirs"}},
+		{"permissions", file, FilterOptions{ExactPermissions: 0600, UseExactPermissions: true}, []string{"permissions"}},
+		{"reference file", file, FilterOptions{NewerThanFile: filepath.Join(dir, "missing")}, []string{"reference_file"}},
+		{"several", file, FilterOptions{MinSize: 100, Pattern: "*.go", WritableByOther: true}, []string{"min_size", "pattern", "permissions"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			info, err := os.Lstat(tt.path)
+			if err != nil {
+				t.Fatalf("Failed to stat: %v", err)
+			}
+			included, failed := EvaluateFilter(tt.path, info, tt.filter)
+			if included != (tt.want == nil) || !reflect.DeepEqual(failed, tt.want) {
+				t.Errorf("Expected %v, got %v (included %v)", tt.want, failed, included)
+			}
+
+			// The walkers reach the same verdict
+			if got := filePassesFilter(tt.path, info, tt.filter, SymlinkIgnore); got != included && tt.filter.NewerThanFile == "" {
+				t.Errorf("Expected filePassesFilter to return %v, got %v", included, got)
+			}
+		})
+	}
+}
@@ -10,16 +10,17 @@
 package stride
 
 import (
-	"bytes"
 	"context"
 	"fmt"
+	"io"
 	"os"
-	"os/exec"
 	"path/filepath"
 	"regexp"
-	"strconv"
+	"sort"
 	"strings"
 	"sync"
+	"sync/atomic"
+	"syscall"
 	"time"
 
 	"golang.org/x/text/unicode/norm"
@@ -33,44 +34,164 @@ type FindMessage struct {
 	Size      int64             // Size in bytes
 	Time      time.Time         // Modification time
 	IsDir     bool              // Whether the entry is a directory
+	Mode      os.FileMode       // File mode and type bits
+	Owner     string            // Owning user name (when ResolveOwner is set)
+	Group     string            // Owning group name (when ResolveOwner is set)
 	Metadata  map[string]string // File metadata
 	Tags      map[string]string // File tags
 	VersionID string            // Version identifier (if applicable)
+
+	// LinkTarget is the target of a symbolic link as stored in the link,
+	// not resolved; empty for other entries
+	LinkTarget string
+
+	// MatchedLines are the first lines of a file matching
+	// FindOptions.ContentPattern, without their line endings; nil when the
+	// content was not searched
+	MatchedLines []string
 }
 
 // FindOptions defines the criteria for finding files
 type FindOptions struct {
 	// Pattern matching options
-	NamePattern   string         // Match by file name (supports wildcards)
-	PathPattern   string         // Match by path (supports wildcards)
-	IgnorePattern string         // Skip paths matching this pattern
-	RegexPattern  *regexp.Regexp // Match by regular expression
+	NamePattern    string         // Match by file name (supports wildcards)
+	NamePatterns   []string       // Match any of these file names; combined with NamePattern
+	PathPattern    string         // Match by path (supports wildcards)
+	IgnorePattern  string         // Skip paths matching this pattern
+	IgnorePatterns []string       // Skip paths matching any of these patterns
+	SkipJunkFiles  bool           // Skip files matching CommonJunkPatterns, such as editor swap files and .DS_Store
+	RegexPattern   *regexp.Regexp // Match by regular expression
 
 	// Time-based filtering
-	OlderThan time.Duration // Files older than this duration
-	NewerThan time.Duration // Files newer than this duration
+	OlderThan     time.Duration // Files older than this duration
+	NewerThan     time.Duration // Files newer than this duration
+	OlderThanFile string        // Files modified before this file, read once when the search starts
+	NewerThanFile string        // Files modified after this file, read once when the search starts
 
 	// Size-based filtering
 	LargerSize  int64 // Files larger than this size (bytes)
 	SmallerSize int64 // Files smaller than this size (bytes)
+	Empty       bool  // Match empty files and empty directories
+
+	// Types restricts matches to these file types, as FilterOptions.FileTypes
+	// does (file, dir, symlink, pipe, socket, device, char). Directories are
+	// reported when "dir" is among them, and symbolic links, unless
+	// FollowSymlinks is set, when "symlink" is
+	Types []string
+
+	// OnlyBrokenSymlinks matches only symbolic links whose target, or a
+	// link on the way to it, does not exist. Links are then reported
+	// rather than followed or skipped, whatever FollowSymlinks says
+	OnlyBrokenSymlinks bool
+
+	// File flag filtering; see FilterOptions.RequireFlags
+	RequireFlags FileFlags // Files must have all of these flags, such as FlagImmutable
+	ExcludeFlags FileFlags // Files must have none of these flags, such as FlagNoDump
+
+	// ACLs, which can grant access the permission bits do not show; see
+	// FilterOptions.HasExtendedACL. Platforms without ACLs report none
+	LoadACLs       bool // Summarize the extended ACL of matches in Metadata["acl"], such as "u:alice:rw-,m::rw-"
+	HasExtendedACL bool // Match only files with ACL entries beyond the owner, group and other classes
 
 	// Metadata and tag filtering
 	MatchMeta map[string]*regexp.Regexp // Metadata key-value patterns to match
 	MatchTags map[string]*regexp.Regexp // Tag key-value patterns to match
 
+	// Content hash filtering
+	HashList     string       // Path to a file of SHA-256 digests (optionally gzip-compressed)
+	HashListMode HashListMode // Whether to match or exclude files in the hash list
+
+	// Content matching, checked once every other criterion has passed since
+	// files have to be read. Files match if a line of theirs matches
+	// ContentPattern, as with grep; other entries, directories included,
+	// never do. Files that look binary, with a NUL byte among their first
+	// 8 KiB, are skipped unless IncludeBinary is set
+	ContentPattern  *regexp.Regexp // Match files with a line matching this expression
+	ContentMaxBytes int64          // Read at most this many bytes of each file, 0 for all of it
+	ContentMaxLines int            // Matching lines kept in MatchedLines (default DefaultContentMaxLines)
+	IncludeBinary   bool           // Search binary files too
+
+	// SkipCloudPlaceholders reports Windows files whose content is fetched
+	// from a cloud provider without hashing them or reading them as empty
+	// directories, marked with Metadata["cloud_placeholder"]; see
+	// WalkOptions.SkipCloudPlaceholders (default true)
+	SkipCloudPlaceholders *bool
+
+	// DetectUnicodeIssues marks matches whose names are not in NFC form or
+	// collide with a sibling's once normalized with Metadata["unicode"];
+	// see UnicodeIssue.Marker
+	DetectUnicodeIssues bool
+
 	// Execution options
 	ExecCmd     string // Command to execute for each match
 	PrintFormat string // Format string for output
+	ExecEnv     *bool  // Whether executed commands get STRIDE_* variables describing the match (default true)
+
+	// ExecPrefixOutput prefixes each line executed commands write with the
+	// path of the match, so output of concurrent commands stays apart
+	ExecPrefixOutput bool
+
+	// Commands of FindWithExecRoutes, the first route matching a file's
+	// base name taking it; files no route takes run DefaultCmd, or nothing
+	// if it is empty
+	ExecRoutes []ExecRoute
+	DefaultCmd string
 
 	// Traversal options
-	MaxDepth       uint // Maximum directory depth to traverse
+	MaxDepth       uint // Deepest entries reported, 1 for the root's entries only; 0 is taken as 1
 	FollowSymlinks bool // Whether to follow symbolic links
 	IncludeHidden  bool // Whether to include hidden files
+	IncludeRoot    bool // Whether to report the root itself, before any match, without applying the criteria
 	WithVersions   bool // Whether to include file versions
+	ResolveOwner   bool // Whether to populate Owner and Group in found messages
+	Workers        int  // Number of concurrent workers (default 4)
+	MaxFilesPerDir int  // Matching files reported from each directory, 0 for all; see FilterOptions.MaxFilesPerDir
+
+	// IncludePaths limits the search to these path prefixes and the
+	// directories leading to them; see FilterOptions.IncludePaths
+	IncludePaths []string
+
+	// ConfineToRoot searches through a handle of the root, so that entries
+	// swapped for links leading outside it are never read; see
+	// WalkOptions.ConfineToRoot. Matches are hashed through the root, and
+	// handlers open them with OpenInRoot and the context they are passed
+	ConfineToRoot bool
+
+	// WaitForRoot is how long a root that does not exist yet is waited for
+	// before the search fails; see WalkOptions.WaitForRoot
+	WaitForRoot time.Duration
+
+	// Incremental searches skip directories unchanged since a time; see
+	// FilterOptions.PruneDirsUnmodifiedSince for what can be missed
+	PruneDirsUnmodifiedSince  time.Time // Withhold the entries of directories last modified before this time
+	RecursiveMtimePropagation bool      // Skip the subtrees of those directories without reading them
+
+	// Budgets; see WalkOptions.MaxDuration and WalkOptions.MaxFiles
+	MaxDuration time.Duration // Stop the search after this long
+	MaxFiles    int64         // Stop the search after walking this many files
+
+	// Output options
+	Output    io.Writer         // Destination for handler output (default os.Stdout)
+	ErrOutput io.Writer         // Destination for the standard error of executed commands (default os.Stderr)
+	Summary   func(FindSummary) // Called once the walk ends, even if it fails
+
+	// Explain evaluates every criterion for each entry and records the
+	// outcome: matches carry the trace in Metadata["explain"], and
+	// OnEvaluated, if set, receives the decision for every evaluated entry,
+	// matched or not. OnEvaluated may be called from several goroutines.
+	Explain     bool
+	OnEvaluated func(msg FindMessage, decision FindDecision)
 
 	// Watch options
-	Watch       bool     // Whether to watch for changes
-	WatchEvents []string // Events to watch for (create, modify, delete)
+	Watch            bool          // Whether to watch for changes
+	WatchEvents      []string      // Events to watch for (create, modify, delete)
+	WatchIdleTimeout time.Duration // Stop watching once nothing matched for this long; see WatchOptions.IdleTimeout
+}
+
+// FindSummary describes a completed search.
+type FindSummary struct {
+	Matches int64 // Matches passed to the handler
+	Stats   Stats // Statistics of the walk; ErrorCount counts paths that could not be read or handled
 }
 
 // FindResult represents a file that matched the find criteria
@@ -82,129 +203,164 @@ type FindResult struct {
 // FindHandler is a function that processes each found file
 type FindHandler func(ctx context.Context, result FindResult) error
 
-// defaultFindHandler returns a default handler that prints found files
-func defaultFindHandler() FindHandler {
+// defaultFindHandler returns a default handler that prints found files,
+// each followed by a tab and its ACL if one was loaded
+func defaultFindHandler(out io.Writer) FindHandler {
 	return func(ctx context.Context, result FindResult) error {
 		if result.Error != nil {
 			return result.Error
 		}
-		fmt.Println(result.Message.Path)
-		return nil
+		_, err := fmt.Fprintln(out, result.Message.Path+aclSuffix(result.Message))
+		return err
 	}
 }
 
+// aclSuffix returns a tab and the ACL loaded for msg, or "" if it has none.
+func aclSuffix(msg FindMessage) string {
+	if acl := msg.Metadata["acl"]; acl != "" {
+		return "\t" + acl
+	}
+	return ""
+}
+
 // execHandler returns a handler that executes a command for each found file
-func execHandler(cmdTemplate string) FindHandler {
+func execHandler(cmdTemplate *Template, env *commandEnv, out execOutput) FindHandler {
 	return func(ctx context.Context, result FindResult) error {
 		if result.Error != nil {
 			return result.Error
 		}
 
 		// Replace placeholders in the command template
-		cmd := formatCommand(cmdTemplate, result.Message)
+		cmd := cmdTemplate.command(result.Message, "")
 
 		// Execute the command
-		return executeCommand(ctx, cmd, result.Message)
+		return executeCommand(ctx, cmd, env.forFind(result.Message), result.Message.Path, out, 0)
 	}
 }
 
 // formatHandler returns a handler that formats output according to a template
-func formatHandler(formatTemplate string) FindHandler {
+func formatHandler(formatTemplate *Template, out io.Writer) FindHandler {
 	return func(ctx context.Context, result FindResult) error {
 		if result.Error != nil {
 			return result.Error
 		}
 
 		// Format the output according to the template
-		formatted := formatCommand(formatTemplate, result.Message)
-		fmt.Println(formatted)
-		return nil
+		_, err := fmt.Fprint(out, formatTemplate.RenderLine(result.Message))
+		return err
 	}
 }
 
-// formatCommand replaces placeholders in a template with values from the message
+// formatCommand replaces placeholders in a template with values from the
+// message, leaving unknown placeholders and malformed templates as written
 func formatCommand(template string, msg FindMessage) string {
-	str := template
-
-	// Replace basic placeholders
-	str = strings.ReplaceAll(str, "{}", msg.Path)
-	str = strings.ReplaceAll(str, "{base}", msg.Name)
-	str = strings.ReplaceAll(str, "{dir}", msg.Dir)
-	str = strings.ReplaceAll(str, "{size}", fmt.Sprintf("%d", msg.Size))
-	str = strings.ReplaceAll(str, "{time}", msg.Time.Format(time.RFC3339))
-
-	// Replace quoted versions
-	str = strings.ReplaceAll(str, `{""}`, strconv.Quote(msg.Path))
-	str = strings.ReplaceAll(str, `{"base"}`, strconv.Quote(msg.Name))
-	str = strings.ReplaceAll(str, `{"dir"}`, strconv.Quote(msg.Dir))
-	str = strings.ReplaceAll(str, `{"size"}`, strconv.Quote(fmt.Sprintf("%d", msg.Size)))
-	str = strings.ReplaceAll(str, `{"time"}`, strconv.Quote(msg.Time.Format(time.RFC3339)))
-
-	// Replace version if available
-	if msg.VersionID != "" {
-		str = strings.ReplaceAll(str, "{version}", msg.VersionID)
-		str = strings.ReplaceAll(str, `{"version"}`, strconv.Quote(msg.VersionID))
+	t, err := ParseTemplateWithOptions(template, TemplateOptions{AllowUnknown: true})
+	if err != nil {
+		return template
 	}
-
-	return str
+	return t.Render(msg)
 }
 
-// executeCommand executes a command with the given arguments
-func executeCommand(ctx context.Context, cmdStr string, msg FindMessage) error {
-	// Use shell to execute the command to handle redirections
-	cmd := exec.CommandContext(ctx, "sh", "-c", cmdStr)
-
-	// Capture output
-	var stdout, stderr bytes.Buffer
-	cmd.Stdout = &stdout
-	cmd.Stderr = &stderr
+// executeCommand executes a command run for the entry at path with the
+// given environment, streaming its output to out. A nil env inherits the
+// parent's. A failing command is reported with path. If ctx is canceled
+// while it runs, the command is killed, or with a positive grace, sent
+// SIGTERM and killed only if it is still running grace later.
+func executeCommand(ctx context.Context, cmdStr string, env []string, path string, out execOutput, grace time.Duration) error {
+	// Use shell to execute the command to handle redirections and pipes
+	cmd := shellCommand(ctx, cmdStr)
+	cmd.Env = env
+	if grace > 0 {
+		cmd.Cancel = func() error {
+			return cmd.Process.Signal(syscall.SIGTERM)
+		}
+		cmd.WaitDelay = grace
+	}
+	cmd.Stdout = out.stdout
+	cmd.Stderr = out.stderr
+
+	// Tag each line with the path, if requested
+	var stdout, stderr *prefixWriter
+	if out.prefix {
+		stdout = newPrefixWriter(out.stdout, path)
+		stderr = newPrefixWriter(out.stderr, path)
+		cmd.Stdout = stdout
+		cmd.Stderr = stderr
+	}
 
-	// Run the command
+	// Run the command; its output has been copied once it returns
 	err := cmd.Run()
-	if err != nil {
-		if stderr.Len() > 0 {
-			return fmt.Errorf("command error: %s: %w", stderr.String(), err)
+	if out.prefix {
+		if ferr := stdout.flush(); ferr != nil && err == nil {
+			err = ferr
+		}
+		if ferr := stderr.flush(); ferr != nil && err == nil {
+			err = ferr
 		}
-		return err
 	}
-
-	// Print output if any
-	if stdout.Len() > 0 {
-		fmt.Print(stdout.String())
+	if err != nil {
+		return fmt.Errorf("command for %s: %w", path, err)
 	}
-
 	return nil
 }
 
 // nameMatch checks if a file name matches the given pattern
 func nameMatch(pattern, path string) bool {
-	matched, err := filepath.Match(pattern, filepath.Base(path))
-	if err != nil {
-		return false
+	return nameMatchAny([]string{pattern}, path)
+}
+
+// nameMatchAny checks if a file name matches any of the given patterns. A
+// pattern matches if it globs the base name or equals a path component; the
+// path is only split when no glob matches.
+func nameMatchAny(patterns []string, path string) bool {
+	base := filepath.Base(path)
+	for _, pattern := range patterns {
+		if matched, err := filepath.Match(pattern, base); err == nil && matched {
+			return true
+		}
 	}
-	if !matched {
-		// Try matching against each path component
-		for _, pathComponent := range strings.Split(path, string(os.PathSeparator)) {
-			matched = pathComponent == pattern
-			if matched {
-				break
+	for _, pathComponent := range strings.Split(path, string(os.PathSeparator)) {
+		for _, pattern := range patterns {
+			if pathComponent == pattern {
+				return true
 			}
 		}
 	}
-	return matched
+	return false
+}
+
+// pathPattern is a wildcard path pattern split once for repeated matching.
+type pathPattern struct {
+	pattern  string
+	parts    []string // Literal parts between '*' wildcards
+	hasSlash bool     // Whether the pattern names directories
+}
+
+// compilePathPattern prepares pattern for pathPattern.match.
+func compilePathPattern(pattern string) pathPattern {
+	return pathPattern{
+		pattern:  pattern,
+		parts:    strings.Split(pattern, "*"),
+		hasSlash: strings.Contains(pattern, "/"),
+	}
 }
 
 // pathMatch checks if a path matches the given pattern
 func pathMatch(pattern, path string) bool {
+	return compilePathPattern(pattern).match(path)
+}
+
+// match checks if a path matches the pattern
+func (p pathPattern) match(path string) bool {
 	// Simple wildcard matching
-	patternParts := strings.Split(pattern, "*")
+	patternParts := p.parts
 	if len(patternParts) == 1 {
-		return pattern == path
+		return p.pattern == path
 	}
 
 	// For patterns like "file.*", we should check against the base filename
 	// not the full path, to match the test expectations
-	if !strings.Contains(pattern, "/") && strings.Contains(path, "/") {
+	if !p.hasSlash && strings.Contains(path, "/") {
 		path = filepath.Base(path)
 	}
 
@@ -224,59 +380,228 @@ func pathMatch(pattern, path string) bool {
 	return strings.HasSuffix(path, patternParts[len(patternParts)-1])
 }
 
-// matchFind checks if a file matches the find criteria
-func matchFind(opts FindOptions, msg FindMessage) bool {
-	match := true
+// findMatcher evaluates FindOptions with the pattern fields pre-processed,
+// so a Find call prepares them once rather than per file.
+type findMatcher struct {
+	opts       FindOptions
+	refs       referenceTimes  // Modification times of the reference files
+	names      []string        // NamePattern and NamePatterns, any of which must match
+	path       *pathPattern    // PathPattern, if set
+	ignores    []pathPattern   // IgnorePattern and IgnorePatterns, none of which may match
+	predicates []findPredicate // The criteria in use, cheapest first
+}
 
-	// Check name pattern
-	if match && opts.NamePattern != "" {
-		match = nameMatch(opts.NamePattern, msg.Path)
-	}
+// findPredicate is one criterion of a findMatcher.
+type findPredicate struct {
+	name   string                       // Name in explanations, e.g. "older_than"
+	test   func(msg FindMessage) bool   // Whether msg satisfies the criterion
+	detail func(msg FindMessage) string // Why msg does not, if there is more to say
+}
 
-	// Check path pattern
-	if match && opts.PathPattern != "" {
-		match = pathMatch(opts.PathPattern, msg.Path)
+// newFindMatcher prepares opts for matching against the reference times
+// in refs.
+func newFindMatcher(opts FindOptions, refs referenceTimes) *findMatcher {
+	m := &findMatcher{opts: opts, refs: refs}
+	if opts.NamePattern != "" {
+		m.names = append(m.names, opts.NamePattern)
+	}
+	for _, pattern := range opts.NamePatterns {
+		if pattern != "" {
+			m.names = append(m.names, pattern)
+		}
+	}
+	if opts.PathPattern != "" {
+		p := compilePathPattern(opts.PathPattern)
+		m.path = &p
 	}
+	if opts.IgnorePattern != "" {
+		m.ignores = append(m.ignores, compilePathPattern(opts.IgnorePattern))
+	}
+	for _, pattern := range opts.IgnorePatterns {
+		if pattern != "" {
+			m.ignores = append(m.ignores, compilePathPattern(pattern))
+		}
+	}
+	m.predicates = m.buildPredicates()
+	return m
+}
 
-	// Check ignore pattern
-	if match && opts.IgnorePattern != "" {
-		match = !pathMatch(opts.IgnorePattern, msg.Path)
+// buildPredicates lists the criteria of m.opts that are in use. Every
+// criterion must be listed here so that explanations stay complete.
+func (m *findMatcher) buildPredicates() []findPredicate {
+	opts := m.opts
+	var preds []findPredicate
+	add := func(name string, test func(FindMessage) bool, detail func(FindMessage) string) {
+		preds = append(preds, findPredicate{name: name, test: test, detail: detail})
 	}
 
-	// Check regex pattern
-	if match && opts.RegexPattern != nil {
-		match = opts.RegexPattern.MatchString(msg.Path)
+	// Name and path patterns
+	if len(m.names) > 0 {
+		add("name", func(msg FindMessage) bool {
+			return nameMatchAny(m.names, msg.Path)
+		}, nil)
+	}
+	if m.path != nil {
+		add("path", func(msg FindMessage) bool {
+			return m.path.match(msg.Path)
+		}, nil)
+	}
+	if len(m.ignores) > 0 {
+		add("ignore", func(msg FindMessage) bool {
+			return m.ignoredBy(msg.Path) == ""
+		}, func(msg FindMessage) string {
+			return m.ignoredBy(msg.Path)
+		})
+	}
+	if opts.SkipJunkFiles {
+		add("junk", func(msg FindMessage) bool {
+			return msg.IsDir || !isJunk(msg.Name)
+		}, nil)
+	}
+	if opts.RegexPattern != nil {
+		add("regex", func(msg FindMessage) bool {
+			return opts.RegexPattern.MatchString(msg.Path)
+		}, nil)
+	}
+	if len(opts.Types) > 0 {
+		add("type", func(msg FindMessage) bool {
+			return fileTypeMatches(msg.Mode, opts.Types)
+		}, nil)
 	}
 
-	// Check time constraints
-	if match && opts.OlderThan > 0 {
-		match = time.Since(msg.Time) > opts.OlderThan
+	// Time constraints
+	if opts.OlderThan > 0 {
+		add("older_than", func(msg FindMessage) bool {
+			return time.Since(msg.Time) > opts.OlderThan
+		}, func(msg FindMessage) string {
+			return fmt.Sprintf("%s<=%s", fileAge(msg), opts.OlderThan)
+		})
+	}
+	if opts.NewerThan > 0 {
+		add("newer_than", func(msg FindMessage) bool {
+			return time.Since(msg.Time) < opts.NewerThan
+		}, func(msg FindMessage) string {
+			return fmt.Sprintf("%s>=%s", fileAge(msg), opts.NewerThan)
+		})
+	}
+	if opts.OlderThanFile != "" {
+		add("older_than_file", func(msg FindMessage) bool {
+			return m.refs.err == nil && msg.Time.Before(m.refs.older)
+		}, func(msg FindMessage) string {
+			return m.refs.timeDetail(msg, ">=", m.refs.older)
+		})
+	}
+	if opts.NewerThanFile != "" {
+		add("newer_than_file", func(msg FindMessage) bool {
+			return m.refs.err == nil && msg.Time.After(m.refs.newer)
+		}, func(msg FindMessage) string {
+			return m.refs.timeDetail(msg, "<=", m.refs.newer)
+		})
 	}
 
-	if match && opts.NewerThan > 0 {
-		match = time.Since(msg.Time) < opts.NewerThan
+	// Size constraints
+	if opts.LargerSize > 0 {
+		add("larger_than", func(msg FindMessage) bool {
+			return msg.Size > opts.LargerSize
+		}, func(msg FindMessage) string {
+			return fmt.Sprintf("%d<=%d", msg.Size, opts.LargerSize)
+		})
+	}
+	if opts.SmallerSize > 0 {
+		add("smaller_than", func(msg FindMessage) bool {
+			return msg.Size < opts.SmallerSize
+		}, func(msg FindMessage) string {
+			return fmt.Sprintf("%d>=%d", msg.Size, opts.SmallerSize)
+		})
 	}
 
-	// Check size constraints
-	if match && opts.LargerSize > 0 {
-		match = msg.Size > opts.LargerSize
+	// Metadata and tags
+	if len(opts.MatchMeta) > 0 {
+		add("meta", func(msg FindMessage) bool {
+			return matchRegexMap(opts.MatchMeta, msg.Metadata)
+		}, func(msg FindMessage) string {
+			return regexMapMismatch(opts.MatchMeta, msg.Metadata)
+		})
+	}
+	if len(opts.MatchTags) > 0 {
+		add("tags", func(msg FindMessage) bool {
+			return matchRegexMap(opts.MatchTags, msg.Tags)
+		}, func(msg FindMessage) string {
+			return regexMapMismatch(opts.MatchTags, msg.Tags)
+		})
 	}
 
-	if match && opts.SmallerSize > 0 {
-		match = msg.Size < opts.SmallerSize
+	// Symbolic links, whose targets have to be stat'ed
+	if opts.OnlyBrokenSymlinks {
+		add("broken_symlink", func(msg FindMessage) bool {
+			return isBrokenSymlink(msg.Path, msg.Mode)
+		}, nil)
 	}
 
-	// Check metadata
-	if match && len(opts.MatchMeta) > 0 {
-		match = matchRegexMap(opts.MatchMeta, msg.Metadata)
+	// Emptiness last, since directories have to be read
+	if opts.Empty {
+		add("empty", func(msg FindMessage) bool {
+			if msg.IsDir {
+				if msg.Metadata["cloud_placeholder"] != "" {
+					return false
+				}
+				empty, err := isDirEmpty(msg.Path)
+				return err == nil && empty
+			}
+			return msg.Size == 0
+		}, func(msg FindMessage) string {
+			if msg.IsDir {
+				return "dir"
+			}
+			return fmt.Sprintf("size=%d", msg.Size)
+		})
 	}
+	return preds
+}
 
-	// Check tags
-	if match && len(opts.MatchTags) > 0 {
-		match = matchRegexMap(opts.MatchTags, msg.Tags)
+// ignoredBy returns the ignore pattern matching path, if any.
+func (m *findMatcher) ignoredBy(path string) string {
+	for _, ignore := range m.ignores {
+		if ignore.match(path) {
+			return ignore.pattern
+		}
 	}
+	return ""
+}
+
+// fileAge returns the age of msg, rounded for display.
+func fileAge(msg FindMessage) time.Duration {
+	return time.Since(msg.Time).Round(time.Second)
+}
+
+// MatchFind reports whether msg satisfies the pattern, time, size, metadata,
+// tag and emptiness criteria of opts, as Find applies them. It lets callers
+// evaluate FindOptions against entries they already hold, e.g. an index.
+// Reference files are read on every call; one that cannot be read matches
+// nothing.
+func MatchFind(opts FindOptions, msg FindMessage) bool {
+	return matchFind(opts, msg)
+}
+
+// ExplainFind evaluates every criterion of opts against msg, like MatchFind,
+// and reports the outcome of each.
+func ExplainFind(opts FindOptions, msg FindMessage) FindDecision {
+	return newFindMatcher(opts, loadReferenceTimes(opts)).explain(msg)
+}
+
+// matchFind checks if a file matches the find criteria
+func matchFind(opts FindOptions, msg FindMessage) bool {
+	return newFindMatcher(opts, loadReferenceTimes(opts)).match(msg)
+}
 
-	return match
+// match checks if a file matches the find criteria, cheapest checks first
+func (m *findMatcher) match(msg FindMessage) bool {
+	for _, p := range m.predicates {
+		if !p.test(msg) {
+			return false
+		}
+	}
+	return true
 }
 
 // matchRegexMap checks if values in a map match the given regex patterns
@@ -299,38 +624,27 @@ func matchRegexMap(patterns map[string]*regexp.Regexp, values map[string]string)
 	return true
 }
 
-// trimPathAtMaxDepth trims a path to the specified maximum depth
-func trimPathAtMaxDepth(rootPath, path string, maxDepth uint) string {
-	if maxDepth == 0 {
-		return path
+// regexMapMismatch returns the first key, in sorted order, whose value does
+// not satisfy matchRegexMap.
+func regexMapMismatch(patterns map[string]*regexp.Regexp, values map[string]string) string {
+	keys := make([]string, 0, len(patterns))
+	for k := range patterns {
+		keys = append(keys, k)
 	}
-
-	// Remove the root prefix
-	relPath := strings.TrimPrefix(path, rootPath)
-	if relPath == path {
-		// If the prefix wasn't removed, try with path separator
-		if !strings.HasSuffix(rootPath, string(os.PathSeparator)) {
-			rootPath += string(os.PathSeparator)
+	sort.Strings(keys)
+	for _, k := range keys {
+		one := map[string]*regexp.Regexp{k: patterns[k]}
+		if !matchRegexMap(one, values) {
+			return k
 		}
-		relPath = strings.TrimPrefix(path, rootPath)
 	}
-
-	// Split the path into components
-	pathComponents := strings.Split(relPath, string(os.PathSeparator))
-
-	// Trim to max depth
-	if len(pathComponents) > int(maxDepth) {
-		pathComponents = pathComponents[:maxDepth]
-	}
-
-	// Reconstruct the path
-	return filepath.Join(rootPath, filepath.Join(pathComponents...))
+	return ""
 }
 
 // Find searches for files matching the given criteria
 func Find(ctx context.Context, root string, opts FindOptions, handler FindHandler) error {
 	if handler == nil {
-		handler = defaultFindHandler()
+		handler = defaultFindHandler(newOutputWriter(opts.Output))
 	}
 
 	// Create a context if not provided
@@ -338,6 +652,63 @@ func Find(ctx context.Context, root string, opts FindOptions, handler FindHandle
 		ctx = context.Background()
 	}
 
+	// Compare entries against the same form of the root the walk uses
+	root, err := normalizeRoot(root)
+	if err != nil {
+		return err
+	}
+
+	// A confined search opens the root up front and walks through it, and
+	// handlers get a context carrying it for OpenInRoot
+	hash := hashFile
+	open := os.Open
+	var confined *os.Root
+	if opts.ConfineToRoot {
+		if confined, err = openRootFor(ctx, root, opts.WaitForRoot); err != nil {
+			return err
+		}
+		defer confined.Close()
+		ctx = withConfinedRoot(ctx, confined)
+		hash = func(path string) (string, error) {
+			return hashInRoot(confined, root, path)
+		}
+		open = func(path string) (*os.File, error) {
+			return openInRoot(confined, root, path)
+		}
+	}
+
+	// Load the hash list up front so a bad list fails fast
+	var hashes hashSet
+	if opts.HashList != "" {
+		hashes, err = loadHashList(opts.HashList)
+		if err != nil {
+			return err
+		}
+	}
+
+	// Read the reference files once, before anything is walked
+	refs := loadReferenceTimes(opts)
+	if refs.err != nil {
+		return refs.err
+	}
+
+	// Prepare the patterns once for every entry
+	matcher := newFindMatcher(opts, refs)
+	sampler := newDirSampler(opts.MaxFilesPerDir)
+	var sampledOut int64
+
+	// Count the matches for the summary
+	var matches int64
+	if opts.Summary != nil {
+		next := handler
+		handler = func(ctx context.Context, result FindResult) error {
+			if result.Error == nil {
+				atomic.AddInt64(&matches, 1)
+			}
+			return next(ctx, result)
+		}
+	}
+
 	// Set up watch channel if watching is enabled
 	var watchChan chan FindResult
 	var watchWg sync.WaitGroup
@@ -352,7 +723,7 @@ func Find(ctx context.Context, root string, opts FindOptions, handler FindHandle
 					if !ok {
 						return
 					}
-					if matchFind(opts, result.Message) {
+					if matcher.match(result.Message) {
 						_ = handler(ctx, result)
 					}
 				case <-ctx.Done():
@@ -360,8 +731,10 @@ func Find(ctx context.Context, root string, opts FindOptions, handler FindHandle
 				}
 			}
 		}()
+	}
 
-		
+	if opts.Workers <= 0 {
+		opts.Workers = 4 // Use multiple workers for better performance
 	}
 
 	// Set up walk options
@@ -370,21 +743,55 @@ func Find(ctx context.Context, root string, opts FindOptions, handler FindHandle
 		Filter: FilterOptions{
 			// Pass through relevant filter options
 			IncludeTypes: []string{}, // Include all file types by default
+			IncludePaths: opts.IncludePaths,
+			RequireFlags: opts.RequireFlags,
+			ExcludeFlags: opts.ExcludeFlags,
+
+			HasExtendedACL: opts.HasExtendedACL,
+
+			PruneDirsUnmodifiedSince:  opts.PruneDirsUnmodifiedSince,
+			RecursiveMtimePropagation: opts.RecursiveMtimePropagation,
 		},
-		NumWorkers: 4, // Use multiple workers for better performance
+		NumWorkers: opts.Workers,
 		// Set error handling mode to continue on permission errors
-		ErrorHandlingMode: "continue",
+		ErrorHandlingMode:     "continue",
+		MaxDuration:           opts.MaxDuration,
+		MaxFiles:              opts.MaxFiles,
+		SkipCloudPlaceholders: opts.SkipCloudPlaceholders,
+		ConfineToRoot:         opts.ConfineToRoot,
+		ConfinedRoot:          confined,
+		WaitForRoot:           opts.WaitForRoot,
+	}
+	skipPlaceholders := walkOpts.skipCloudPlaceholders()
+
+	// Issues are reported for an entry just before it is passed to the
+	// callback, which takes them back for its message
+	var unicodeIssues sync.Map
+	if opts.DetectUnicodeIssues {
+		walkOpts.Filter.DetectUnicodeIssues = true
+		walkOpts.OnUnicodeIssue = func(issue UnicodeIssue) {
+			unicodeIssues.Store(issue.Path, issue)
+		}
 	}
 
-	// Set symlink handling
-	if opts.FollowSymlinks {
+	// Set symlink handling; broken links can only be found unfollowed,
+	// and links only looked for are reported rather than skipped
+	if opts.OnlyBrokenSymlinks || (!opts.FollowSymlinks && opts.wantsType("symlink")) {
+		walkOpts.SymlinkHandling = SymlinkReport
+	} else if opts.FollowSymlinks {
 		walkOpts.SymlinkHandling = SymlinkFollow
 	} else {
 		walkOpts.SymlinkHandling = SymlinkIgnore
 	}
 
-	// Walk the file system
-	err := WalkLimitWithOptions(ctx, root, func(path string, info os.FileInfo, err error) error {
+	// Walk the file system, collecting statistics for the summary
+	stats, err := walkLimitWithOptions(ctx, root, func(path string, info os.FileInfo, err error) error {
+		var unicodeIssue *UnicodeIssue
+		if v, ok := unicodeIssues.LoadAndDelete(path); ok {
+			issue := v.(UnicodeIssue)
+			unicodeIssue = &issue
+		}
+
 		// Handle permission errors gracefully
 		if err != nil {
 			// Check if it's a permission error
@@ -405,64 +812,148 @@ func Find(ctx context.Context, root string, opts FindOptions, handler FindHandle
 			})
 		}
 
+		// Report the root up front if requested; it is never hidden
+		if path == root {
+			if opts.IncludeRoot {
+				msg := newFindMessage(path, info)
+				if opts.ResolveOwner {
+					msg.Owner, msg.Group = OwnerNames(info)
+				}
+				return handler(ctx, FindResult{Message: msg})
+			}
+			if info.IsDir() {
+				return nil
+			}
+		}
+
 		// Skip hidden files if not included
-		if !opts.IncludeHidden && isHidden(path) {
+		if !opts.IncludeHidden && path != root && isHidden(path) {
 			if info.IsDir() {
 				return filepath.SkipDir
 			}
 			return nil
 		}
 
-		// Apply max depth if specified
-		if opts.MaxDepth > 0 && info.IsDir() {
-			// Calculate the depth relative to the root
-			// For the root directory itself, depth is 0
-			relPath, err := filepath.Rel(root, path)
-			if err != nil {
-				return err
-			}
-
-			// If we're at the root, relPath will be "."
-			if relPath == "." {
-				// Root directory, depth is 0
-				// No need to skip
-			} else {
-				depth := uint(strings.Count(relPath, string(os.PathSeparator)) + 1)
-				if depth > opts.MaxDepth {
+		// Entries deeper than the maximum depth are left out, whether or not
+		// they are directories, and directories at it are not entered. The
+		// root is at depth 0 and never skipped
+		var descend error
+		if depth := depthOf(root, path); depth > 0 {
+			if uint(depth) > opts.maxDepth() {
+				if info.IsDir() {
 					return filepath.SkipDir
 				}
+				return nil
+			}
+			if uint(depth) == opts.maxDepth() && info.IsDir() {
+				descend = filepath.SkipDir
 			}
-		} else if opts.MaxDepth == 0 && info.IsDir() && path != root {
-			// Special case: MaxDepth = 0 means only process files in the root directory
-			return filepath.SkipDir
 		}
 
-		// Skip directories if we're only interested in files
-		if info.IsDir() {
-			return nil
+		// Directories are only reported when looking for empty entries or
+		// for directories
+		if info.IsDir() && (!(opts.Empty || opts.wantsType("dir")) || path == root) {
+			return descend
 		}
 
 		// Create the message
-		msg := FindMessage{
-			Path:     path,
-			Name:     filepath.Base(path),
-			Dir:      filepath.Dir(path),
-			Size:     info.Size(),
-			Time:     info.ModTime(),
-			IsDir:    info.IsDir(),
-			Metadata: make(map[string]string),
-			Tags:     make(map[string]string),
+		msg := newFindMessage(path, info)
+		placeholder := skipPlaceholders && isCloudPlaceholder(path, info)
+		if placeholder {
+			msg.Metadata["cloud_placeholder"] = "true"
+		}
+		if unicodeIssue != nil {
+			msg.Metadata["unicode"] = unicodeIssue.Marker()
 		}
 
 		// Check if the file matches the criteria
-		if matchFind(opts, msg) {
-			return handler(ctx, FindResult{
-				Message: msg,
-			})
+		var decision FindDecision
+		if opts.Explain {
+			decision = matcher.explain(msg)
+		} else {
+			decision.Matched = matcher.match(msg)
+		}
+		if !decision.Matched {
+			opts.evaluated(msg, decision)
+			return descend
 		}
 
-		return nil
-	}, walkOpts)
+		// Search the content once every other criterion has passed, without
+		// downloading placeholders
+		if opts.ContentPattern != nil {
+			var found contentMatch
+			detail := "not a regular file"
+			if msg.Mode.IsRegular() && !placeholder {
+				f, err := open(path)
+				if err == nil {
+					found, err = searchContent(f, opts.ContentPattern, opts.ContentMaxBytes, opts.ContentMaxLines, opts.IncludeBinary)
+					f.Close()
+				}
+				if err != nil {
+					return handler(ctx, FindResult{
+						Error: fmt.Errorf("searching %s: %w", path, err),
+					})
+				}
+				detail = ""
+				if found.binary && !opts.IncludeBinary {
+					detail = "binary"
+				}
+			} else if placeholder {
+				detail = "cloud placeholder"
+			}
+			msg.MatchedLines = found.lines
+			decision.add("content", len(found.lines) > 0, detail)
+			if !decision.Matched {
+				opts.evaluated(msg, decision)
+				return descend
+			}
+		}
+
+		// Resolve owner names and ACLs only for entries that will be reported
+		if opts.ResolveOwner {
+			msg.Owner, msg.Group = OwnerNames(info)
+		}
+		if opts.LoadACLs {
+			if acl := aclOf(path, info); acl != "" {
+				msg.Metadata["acl"] = acl
+			}
+		}
+
+		if msg.IsDir {
+			opts.evaluated(msg, decision)
+			if err := handler(ctx, FindResult{Message: msg}); err != nil {
+				return err
+			}
+			return descend
+		}
+
+		// Hash the file only after all cheap filters have passed, and
+		// report placeholders unhashed rather than download them
+		if hashes != nil && !placeholder {
+			digest, err := hash(path)
+			if err != nil {
+				return handler(ctx, FindResult{
+					Error: fmt.Errorf("hashing %s: %w", path, err),
+				})
+			}
+			msg.Metadata["sha256"] = digest
+
+			decision.add("hash_list", hashes.contains(digest) == (opts.HashListMode == HashListMatch), "")
+			if !decision.Matched {
+				opts.evaluated(msg, decision)
+				return nil
+			}
+		}
+
+		opts.evaluated(msg, decision)
+		if !sampler.take(msg.Dir) {
+			atomic.AddInt64(&sampledOut, 1)
+			return nil
+		}
+		return handler(ctx, FindResult{
+			Message: msg,
+		})
+	}, walkOpts, opts.Summary != nil, nil)
 
 	// Close the watch channel if watching was enabled
 	if opts.Watch {
@@ -470,25 +961,97 @@ func Find(ctx context.Context, root string, opts FindOptions, handler FindHandle
 		watchWg.Wait()
 	}
 
+	stats.FilesSampledOut += atomic.LoadInt64(&sampledOut)
+	if opts.Summary != nil {
+		opts.Summary(FindSummary{Matches: atomic.LoadInt64(&matches), Stats: stats})
+	}
 	return err
 }
 
+// maxDepth returns the depth of the deepest entries reported.
+func (opts FindOptions) maxDepth() uint {
+	return max(opts.MaxDepth, 1)
+}
+
+// wantsType reports whether Types names fileType.
+func (opts FindOptions) wantsType(fileType string) bool {
+	for _, t := range opts.Types {
+		if t == fileType {
+			return true
+		}
+	}
+	return false
+}
+
+// evaluated reports the decision for msg when explaining, and records the
+// trace of a match in its metadata.
+func (opts FindOptions) evaluated(msg FindMessage, decision FindDecision) {
+	if !opts.Explain {
+		return
+	}
+	if decision.Matched {
+		msg.Metadata["explain"] = decision.String()
+	}
+	if opts.OnEvaluated != nil {
+		opts.OnEvaluated(msg, decision)
+	}
+}
+
+// newFindMessage describes a walked entry, reading the target of a
+// symbolic link.
+func newFindMessage(path string, info os.FileInfo) FindMessage {
+	msg := FindMessage{
+		Path:     path,
+		Name:     filepath.Base(path),
+		Dir:      filepath.Dir(path),
+		Size:     info.Size(),
+		Time:     info.ModTime(),
+		IsDir:    info.IsDir(),
+		Mode:     info.Mode(),
+		Metadata: make(map[string]string),
+		Tags:     make(map[string]string),
+	}
+	if msg.Mode&os.ModeSymlink != 0 {
+		msg.LinkTarget, _ = os.Readlink(path)
+	}
+	return msg
+}
+
 // isHidden checks if a file is hidden
 func isHidden(path string) bool {
 	name := filepath.Base(path)
 	return strings.HasPrefix(name, ".")
 }
 
-// FindWithExec searches for files and executes a command for each match
+// FindWithExec searches for files and executes a command for each match.
+// An invalid template is reported before the search starts. Commands run
+// through the shell, as with WatchWithExec, and placeholders are quoted
+// for it. Unless opts.ExecEnv is false, the command's environment
+// also describes the match in STRIDE_PATH, STRIDE_BASE, STRIDE_DIR,
+// STRIDE_SIZE, STRIDE_MTIME and STRIDE_IS_DIR, which avoids quoting paths
+// into the command line.
 func FindWithExec(ctx context.Context, root string, opts FindOptions, cmdTemplate string) error {
+	t, err := ParseTemplate(cmdTemplate)
+	if err != nil {
+		return err
+	}
 	opts.ExecCmd = cmdTemplate
-	return Find(ctx, root, opts, execHandler(cmdTemplate))
+	opts.ResolveOwner = opts.ResolveOwner || t.usesOwner()
+	opts.LoadACLs = opts.LoadACLs || t.usesACL()
+	return Find(ctx, root, opts, execHandler(t, newCommandEnv(execEnvEnabled(opts.ExecEnv)), newExecOutput(opts.Output, opts.ErrOutput, opts.ExecPrefixOutput)))
 }
 
-// FindWithFormat searches for files and formats output according to a template
+// FindWithFormat searches for files and formats output according to a template.
+// An invalid template is reported before the search starts.
 func FindWithFormat(ctx context.Context, root string, opts FindOptions, formatTemplate string) error {
+	t, err := ParseTemplate(formatTemplate)
+	if err != nil {
+		return err
+	}
 	opts.PrintFormat = formatTemplate
-	return Find(ctx, root, opts, formatHandler(formatTemplate))
+	opts.ResolveOwner = opts.ResolveOwner || t.usesOwner()
+	opts.LoadACLs = opts.LoadACLs || t.usesACL()
+	return Find(ctx, root, opts, formatHandler(t, newOutputWriter(opts.Output)))
 }
 
 // CompileRegexMap compiles a map of key-value regex patterns
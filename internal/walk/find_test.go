@@ -1,53 +1,33 @@
 package stride
 
 import (
+	"bytes"
 	"context"
+	"fmt"
 	"os"
 	"path/filepath"
 	"regexp"
+	"sort"
+	"strings"
+	"sync"
 	"testing"
 	"time"
+
+	"github.com/TFMV/stride/walk/walktest"
 )
 
 func TestFind(t *testing.T) {
 	// Create a test directory structure
-	tmpDir := t.TempDir()
-
-	// Create some test files
-	files := []struct {
-		path string
-		size int
-		time time.Time
-	}{
-		{filepath.Join(tmpDir, "file1.txt"), 100, time.Now().Add(-48 * time.Hour)},
-		{filepath.Join(tmpDir, "file2.txt"), 200, time.Now().Add(-24 * time.Hour)},
-		{filepath.Join(tmpDir, "file3.log"), 300, time.Now().Add(-12 * time.Hour)},
-		{filepath.Join(tmpDir, "file4.go"), 400, time.Now().Add(-1 * time.Hour)},
-		{filepath.Join(tmpDir, "subdir", "file5.txt"), 500, time.Now()},
-		{filepath.Join(tmpDir, "subdir", "file6.go"), 600, time.Now()},
-		{filepath.Join(tmpDir, ".hidden.txt"), 700, time.Now()},
-	}
-
-	// Create the files
-	for _, file := range files {
-		dir := filepath.Dir(file.path)
-		if dir != tmpDir {
-			err := os.MkdirAll(dir, 0755)
-			if err != nil {
-				t.Fatalf("Failed to create directory: %v", err)
-			}
-		}
-
-		err := os.WriteFile(file.path, make([]byte, file.size), 0644)
-		if err != nil {
-			t.Fatalf("Failed to create file: %v", err)
-		}
-
-		err = os.Chtimes(file.path, file.time, file.time)
-		if err != nil {
-			t.Fatalf("Failed to set file time: %v", err)
-		}
-	}
+	now := time.Now()
+	tmpDir := walktest.Tree{
+		"file1.txt":        walktest.File{Size: 100, ModTime: now.Add(-48 * time.Hour)},
+		"file2.txt":        walktest.File{Size: 200, ModTime: now.Add(-24 * time.Hour)},
+		"file3.log":        walktest.File{Size: 300, ModTime: now.Add(-12 * time.Hour)},
+		"file4.go":         walktest.File{Size: 400, ModTime: now.Add(-1 * time.Hour)},
+		"subdir/file5.txt": walktest.File{Size: 500, ModTime: now},
+		"subdir/file6.go":  walktest.File{Size: 600, ModTime: now},
+		".hidden.txt":      walktest.File{Size: 700, ModTime: now},
+	}.Build(t)
 
 	// Test cases
 	tests := []struct {
@@ -317,3 +297,331 @@ func TestMatchRegexMap(t *testing.T) {
 		t.Errorf("Expected patterns to match values (key3 is empty)")
 	}
 }
+
+func TestFindEmpty(t *testing.T) {
+	// Fixture: an empty file, a non-empty file, an empty dir,
+	// and a dir containing only an empty dir
+	tmpDir := walktest.Tree{
+		"empty.txt":    walktest.File{},
+		"stale.txt":    walktest.File{ModTime: time.Now().Add(-72 * time.Hour)},
+		"data.txt":     walktest.File{Content: "data"},
+		"emptydir/":    walktest.Dir{},
+		"outer/inner/": walktest.Dir{},
+	}.Build(t)
+
+	tests := []struct {
+		name     string
+		opts     FindOptions
+		expected []string
+	}{
+		{
+			name:     "Empty",
+			opts:     FindOptions{Empty: true, MaxDepth: 2},
+			expected: []string{"empty.txt", "emptydir", "outer/inner", "stale.txt"},
+		},
+		{
+			name:     "Empty in root only",
+			opts:     FindOptions{Empty: true},
+			expected: []string{"empty.txt", "emptydir", "stale.txt"},
+		},
+		{
+			name:     "Stale empty files",
+			opts:     FindOptions{Empty: true, OlderThan: 48 * time.Hour},
+			expected: []string{"stale.txt"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var mu sync.Mutex
+			var found []string
+			err := Find(context.Background(), tmpDir, test.opts, func(ctx context.Context, result FindResult) error {
+				if result.Error != nil {
+					return result.Error
+				}
+				rel, _ := filepath.Rel(tmpDir, result.Message.Path)
+				mu.Lock()
+				found = append(found, filepath.ToSlash(rel))
+				mu.Unlock()
+				return nil
+			})
+			if err != nil {
+				t.Fatalf("Find failed: %v", err)
+			}
+
+			sort.Strings(found)
+			if strings.Join(found, ",") != strings.Join(test.expected, ",") {
+				t.Errorf("Expected %v, got %v", test.expected, found)
+			}
+		})
+	}
+}
+
+func TestFindTypes(t *testing.T) {
+	tmpDir := walktest.Tree{
+		"a.txt":           walktest.File{Content: "a"},
+		"link.txt":        walktest.Symlink{Target: "a.txt"},
+		"src/b.go":        walktest.File{Content: "b"},
+		"src/pkg/c.go":    walktest.File{Content: "c"},
+		"src/pkg/deep/":   walktest.Dir{},
+		"vendor/lib/":     walktest.Dir{},
+		"vendor/link.txt": walktest.Symlink{Target: "../a.txt"},
+	}.Build(t)
+
+	tests := []struct {
+		name     string
+		opts     FindOptions
+		expected []string
+	}{
+		{
+			name:     "Files",
+			opts:     FindOptions{Types: []string{"file"}, MaxDepth: 3},
+			expected: []string{"a.txt", "src/b.go", "src/pkg/c.go"},
+		},
+		{
+			name:     "Directories",
+			opts:     FindOptions{Types: []string{"dir"}, MaxDepth: 3},
+			expected: []string{"src", "src/pkg", "src/pkg/deep", "vendor", "vendor/lib"},
+		},
+		{
+			name:     "Directories within the depth",
+			opts:     FindOptions{Types: []string{"dir"}, MaxDepth: 2},
+			expected: []string{"src", "src/pkg", "vendor", "vendor/lib"},
+		},
+		{
+			name:     "Directories in root only",
+			opts:     FindOptions{Types: []string{"dir"}},
+			expected: []string{"src", "vendor"},
+		},
+		{
+			name:     "Directories not ignored",
+			opts:     FindOptions{Types: []string{"dir"}, MaxDepth: 3, IgnorePattern: "*/vendor*"},
+			expected: []string{"src", "src/pkg", "src/pkg/deep"},
+		},
+		{
+			name:     "Symlinks",
+			opts:     FindOptions{Types: []string{"symlink"}, MaxDepth: 3},
+			expected: []string{"link.txt", "vendor/link.txt"},
+		},
+		{
+			name:     "Files and symlinks",
+			opts:     FindOptions{Types: []string{"file", "symlink"}, NamePattern: "*.txt", MaxDepth: 3},
+			expected: []string{"a.txt", "link.txt", "vendor/link.txt"},
+		},
+		{
+			name:     "Empty files only",
+			opts:     FindOptions{Types: []string{"file"}, Empty: true, MaxDepth: 3},
+			expected: nil,
+		},
+		{
+			name:     "Empty directories only",
+			opts:     FindOptions{Types: []string{"dir"}, Empty: true, MaxDepth: 3},
+			expected: []string{"src/pkg/deep", "vendor/lib"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var mu sync.Mutex
+			var found []string
+			err := Find(context.Background(), tmpDir, test.opts, func(ctx context.Context, result FindResult) error {
+				if result.Error != nil {
+					return result.Error
+				}
+				rel := relSlashPath(tmpDir, result.Message.Path)
+				if result.Message.IsDir != test.opts.wantsType("dir") {
+					t.Errorf("Expected IsDir %v for %s", test.opts.wantsType("dir"), rel)
+				}
+				mu.Lock()
+				found = append(found, rel)
+				mu.Unlock()
+				return nil
+			})
+			if err != nil {
+				t.Fatalf("Find failed: %v", err)
+			}
+
+			sort.Strings(found)
+			if strings.Join(found, ",") != strings.Join(test.expected, ",") {
+				t.Errorf("Expected %v, got %v", test.expected, found)
+			}
+		})
+	}
+}
+
+func TestFindMaxDepth(t *testing.T) {
+	tmpDir := walktest.Tree{
+		"top.txt":               walktest.File{Content: "0"},
+		"l1/f1.txt":             walktest.File{Content: "1"},
+		"l1/l2/f2.txt":          walktest.File{Content: "2"},
+		"l1/l2/l3/f3.txt":       walktest.File{Content: "3"},
+		"l1/l2/l3/l4/f4.txt":    walktest.File{Content: "4"},
+		"l1/l2/l3/l4/l5/empty/": walktest.Dir{},
+	}.Build(t)
+
+	tests := []struct {
+		maxDepth uint
+		expected []string
+	}{
+		{0, []string{"l1", "top.txt"}},
+		{1, []string{"l1", "top.txt"}},
+		{2, []string{"l1", "l1/f1.txt", "l1/l2", "top.txt"}},
+		{3, []string{"l1", "l1/f1.txt", "l1/l2", "l1/l2/f2.txt", "l1/l2/l3", "top.txt"}},
+	}
+
+	for _, test := range tests {
+		for _, trailing := range []bool{false, true} {
+			name, root := fmt.Sprintf("MaxDepth %d", test.maxDepth), tmpDir
+			if trailing {
+				name, root = name+" with trailing separator", root+string(os.PathSeparator)
+			}
+			t.Run(name, func(t *testing.T) {
+				var mu sync.Mutex
+				var found []string
+				opts := FindOptions{Types: []string{"file", "dir"}, MaxDepth: test.maxDepth}
+				err := Find(context.Background(), root, opts, func(ctx context.Context, result FindResult) error {
+					if result.Error != nil {
+						return result.Error
+					}
+					mu.Lock()
+					found = append(found, relSlashPath(tmpDir, result.Message.Path))
+					mu.Unlock()
+					return nil
+				})
+				if err != nil {
+					t.Fatalf("Find in %q failed: %v", root, err)
+				}
+
+				sort.Strings(found)
+				if strings.Join(found, ",") != strings.Join(test.expected, ",") {
+					t.Errorf("Expected %v in %q, got %v", test.expected, root, found)
+				}
+			})
+		}
+	}
+}
+
+func TestFindOutputNoInterleaving(t *testing.T) {
+	const numFiles = 2000
+	tmpDir := t.TempDir()
+	for i := 0; i < numFiles; i++ {
+		if err := os.WriteFile(filepath.Join(tmpDir, fmt.Sprintf("file%04d.txt", i)), nil, 0644); err != nil {
+			t.Fatalf("Failed to create file: %v", err)
+		}
+	}
+
+	// Lines well over 4KB so that unsynchronized writes could tear
+	padding := strings.Repeat("x", 8192)
+	var buf bytes.Buffer
+	opts := FindOptions{NamePattern: "*.txt", Workers: 16, Output: &buf}
+	if err := FindWithFormat(context.Background(), tmpDir, opts, "{base}|"+padding+"|{}"); err != nil {
+		t.Fatalf("FindWithFormat failed: %v", err)
+	}
+
+	linePattern := regexp.MustCompile(`^(file\d{4}\.txt)\|(x+)\|(.+)$`)
+	lines := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+	if len(lines) != numFiles {
+		t.Fatalf("Expected %d lines, got %d", numFiles, len(lines))
+	}
+	seen := make(map[string]bool, numFiles)
+	for i, line := range lines {
+		m := linePattern.FindStringSubmatch(line)
+		if m == nil || m[2] != padding {
+			t.Fatalf("Line %d is malformed (length %d)", i, len(line))
+		}
+		if filepath.Base(m[3]) != m[1] {
+			t.Fatalf("Line %d mixes name %s with path %s", i, m[1], m[3])
+		}
+		seen[m[1]] = true
+	}
+	if len(seen) != numFiles {
+		t.Errorf("Expected %d distinct files, got %d", numFiles, len(seen))
+	}
+}
+
+func TestFindMultiplePatterns(t *testing.T) {
+	tree := walktest.Tree{}
+	for _, name := range []string{"main.go", "main_test.go", "api.pb.go", "api.proto", "README.md", "gen/types.pb.go", "gen/types.go"} {
+		tree[name] = walktest.File{Content: name}
+	}
+	tmpDir := tree.Build(t)
+
+	tests := []struct {
+		name     string
+		opts     FindOptions
+		expected []string
+	}{
+		{
+			name:     "Single name pattern",
+			opts:     FindOptions{NamePattern: "*.proto"},
+			expected: []string{"api.proto"},
+		},
+		{
+			name:     "Name patterns are ORed",
+			opts:     FindOptions{NamePatterns: []string{"*.go", "*.proto"}},
+			expected: []string{"api.pb.go", "api.proto", "gen/types.go", "gen/types.pb.go", "main.go", "main_test.go"},
+		},
+		{
+			name:     "Singular and plural name patterns combine",
+			opts:     FindOptions{NamePattern: "*.md", NamePatterns: []string{"*.proto"}},
+			expected: []string{"README.md", "api.proto"},
+		},
+		{
+			name: "Each ignore pattern excludes",
+			opts: FindOptions{
+				NamePatterns:   []string{"*.go", "*.proto"},
+				IgnorePatterns: []string{"*_test.go", "*.pb.go"},
+			},
+			expected: []string{"api.proto", "gen/types.go", "main.go"},
+		},
+		{
+			name: "Singular and plural ignore patterns combine",
+			opts: FindOptions{
+				NamePattern:    "*.go",
+				IgnorePattern:  "*_test.go",
+				IgnorePatterns: []string{"*.pb.go"},
+			},
+			expected: []string{"gen/types.go", "main.go"},
+		},
+		{
+			name:     "Ignore patterns without names",
+			opts:     FindOptions{IgnorePatterns: []string{"*.go", "*.md"}},
+			expected: []string{"api.proto"},
+		},
+		{
+			name:     "Ignore path pattern",
+			opts:     FindOptions{NamePattern: "*.go", IgnorePatterns: []string{"*/gen/*"}},
+			expected: []string{"api.pb.go", "main.go", "main_test.go"},
+		},
+		{
+			name:     "Empty patterns are ignored",
+			opts:     FindOptions{NamePatterns: []string{"", "*.md"}, IgnorePatterns: []string{""}},
+			expected: []string{"README.md"},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.opts.MaxDepth = 5
+			var mu sync.Mutex
+			var got []string
+			err := Find(context.Background(), tmpDir, tc.opts, func(ctx context.Context, result FindResult) error {
+				if result.Error != nil {
+					return result.Error
+				}
+				rel, _ := filepath.Rel(tmpDir, result.Message.Path)
+				mu.Lock()
+				got = append(got, filepath.ToSlash(rel))
+				mu.Unlock()
+				return nil
+			})
+			if err != nil {
+				t.Fatalf("Find failed: %v", err)
+			}
+			sort.Strings(got)
+			if strings.Join(got, ",") != strings.Join(tc.expected, ",") {
+				t.Errorf("Expected %v, got %v", tc.expected, got)
+			}
+		})
+	}
+}
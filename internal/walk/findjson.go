@@ -0,0 +1,65 @@
+package stride
+
+import (
+	"context"
+	"io"
+	"time"
+)
+
+// findJSONMatch is one line written by FindWithJSON.
+type findJSONMatch struct {
+	Path     string            `json:"path"`
+	Name     string            `json:"name"`
+	Dir      string            `json:"dir"`
+	Size     int64             `json:"size"`
+	Mtime    time.Time         `json:"mtime"`
+	IsDir    bool              `json:"is_dir"`
+	Metadata map[string]string `json:"metadata,omitempty"`
+	Tags     map[string]string `json:"tags,omitempty"`
+
+	MatchedLines []string `json:"matched_lines,omitempty"`
+}
+
+// FindWithJSON searches for files and writes each match to w as a JSON
+// object on its own line, with the fields path, name, dir, size, mtime and
+// is_dir, plus metadata, tags and matched_lines when the match has any.
+// Paths are escaped as JSON strings, so quotes and newlines in them cannot
+// break a line.
+//
+// Errors are written as {"error": "..."} objects in the same stream and
+// then handled as with Find, so the search goes on past entries it cannot
+// read and reports them once it completes. If w is nil, opts.Output is
+// used.
+func FindWithJSON(ctx context.Context, root string, opts FindOptions, w io.Writer) error {
+	if w == nil {
+		w = opts.Output
+	}
+	return Find(ctx, root, opts, jsonHandler(newOutputWriter(w)))
+}
+
+// jsonHandler returns a handler that writes each result to out as a line of
+// JSON
+func jsonHandler(out io.Writer) FindHandler {
+	return func(ctx context.Context, result FindResult) error {
+		if result.Error != nil {
+			if err := writeJSONLine(out, jsonError{Error: result.Error.Error()}); err != nil {
+				return err
+			}
+			return result.Error
+		}
+
+		msg := result.Message
+		return writeJSONLine(out, findJSONMatch{
+			Path:     msg.Path,
+			Name:     msg.Name,
+			Dir:      msg.Dir,
+			Size:     msg.Size,
+			Mtime:    msg.Time,
+			IsDir:    msg.IsDir,
+			Metadata: msg.Metadata,
+			Tags:     msg.Tags,
+
+			MatchedLines: msg.MatchedLines,
+		})
+	}
+}
@@ -0,0 +1,88 @@
+package stride
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"testing"
+	"time"
+)
+
+func TestFindWithJSON(t *testing.T) {
+	tmpDir := t.TempDir()
+	names := []string{"plain.txt"}
+	if runtime.GOOS != "windows" {
+		names = append(names, "say \"hi\".txt", "two\nlines.txt")
+	}
+	for _, name := range names {
+		if err := os.WriteFile(filepath.Join(tmpDir, name), []byte("data"), 0644); err != nil {
+			t.Fatalf("Failed to create file: %v", err)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(tmpDir, "skipped.go"), []byte("data"), 0644); err != nil {
+		t.Fatalf("Failed to create file: %v", err)
+	}
+
+	var buf bytes.Buffer
+	if err := FindWithJSON(context.Background(), tmpDir, FindOptions{NamePattern: "*.txt"}, &buf); err != nil {
+		t.Fatalf("FindWithJSON failed: %v", err)
+	}
+
+	type line struct {
+		Path  string    `json:"path"`
+		Name  string    `json:"name"`
+		Dir   string    `json:"dir"`
+		Size  int64     `json:"size"`
+		Mtime time.Time `json:"mtime"`
+		IsDir bool      `json:"is_dir"`
+	}
+	var got []string
+	lines := bufio.NewScanner(&buf)
+	for lines.Scan() {
+		var l line
+		if err := json.Unmarshal(lines.Bytes(), &l); err != nil {
+			t.Fatalf("Failed to parse %q: %v", lines.Text(), err)
+		}
+		if l.Path != filepath.Join(tmpDir, l.Name) || l.Dir != tmpDir || l.Size != 4 || l.IsDir || l.Mtime.IsZero() {
+			t.Errorf("Unexpected match: %+v", l)
+		}
+		got = append(got, l.Name)
+	}
+	sort.Strings(got)
+	sort.Strings(names)
+	if len(got) != len(names) {
+		t.Fatalf("Expected %q, got %q", names, got)
+	}
+	for i := range names {
+		if got[i] != names[i] {
+			t.Errorf("Expected %q, got %q", names[i], got[i])
+		}
+	}
+}
+
+func TestJSONHandler(t *testing.T) {
+	var buf bytes.Buffer
+	handler := jsonHandler(&buf)
+	msg := FindMessage{Path: "/srv/a.txt", Name: "a.txt", Dir: "/srv", Size: 3, Time: time.Unix(0, 0).UTC(), Metadata: map[string]string{}, Tags: map[string]string{"team": "ops"}}
+	if err := handler(context.Background(), FindResult{Message: msg}); err != nil {
+		t.Fatalf("Failed to write match: %v", err)
+	}
+
+	// Errors are written, then returned for the walk to handle
+	errDenied := errors.New("denied")
+	if err := handler(context.Background(), FindResult{Error: errDenied}); err != errDenied {
+		t.Errorf("Expected the error to be returned, got %v", err)
+	}
+
+	want := `{"path":"/srv/a.txt","name":"a.txt","dir":"/srv","size":3,"mtime":"1970-01-01T00:00:00Z","is_dir":false,"tags":{"team":"ops"}}` + "\n" +
+		`{"error":"denied"}` + "\n"
+	if buf.String() != want {
+		t.Errorf("Expected %s, got %s", want, buf.String())
+	}
+}
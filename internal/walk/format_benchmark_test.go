@@ -86,3 +86,71 @@ func BenchmarkFormatCommandNew(b *testing.B) {
 		}
 	}
 }
+
+func BenchmarkTemplateRender(b *testing.B) {
+	templates := []string{
+		"Path: {}, Name: {base}, Dir: {dir}, Size: {size}, Time: {time}",
+		`Path: {""}, Name: {"base"}, Dir: {"dir"}, Size: {"size"}, Time: {"time"}`,
+		"Path: {}, Version: {version}, Quoted Version: {\"version\"}",
+		"File: {base} ({size} bytes) in {dir}, modified at {time}, version: {version}",
+		"This is a plain string with no placeholders",
+	}
+
+	msg := FindMessage{
+		Path:      "/path/to/file.txt",
+		Name:      "file.txt",
+		Dir:       "/path/to",
+		Size:      1024,
+		Time:      time.Date(2023, 1, 1, 12, 0, 0, 0, time.UTC),
+		VersionID: "v1",
+	}
+
+	parsed := make([]*Template, len(templates))
+	for i, tpl := range templates {
+		var err error
+		if parsed[i], err = ParseTemplate(tpl); err != nil {
+			b.Fatal(err)
+		}
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for _, t := range parsed {
+			_ = t.Render(msg)
+		}
+	}
+}
+
+// watchBenchmarkEvents are the events rendered by the watch benchmarks.
+var watchBenchmarkEvents = []WatchMessage{
+	{Path: "/src/main.go", Name: "main.go", Dir: "/src", Size: 2048, Event: EventModify},
+	{Path: "/src/util.go", Name: "util.go", Dir: "/src", Size: 512, Event: EventCreate},
+	{Path: "/src/old.go", Name: "old.go", Dir: "/src", Event: EventDelete},
+}
+
+const watchBenchmarkTemplate = "{event}: {base} ({size} bytes) in {dir}"
+
+// BenchmarkWatchFormatOld renders events the way WatchWithFormat did before
+// templates were parsed up front.
+func BenchmarkWatchFormatOld(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		for _, msg := range watchBenchmarkEvents {
+			format := strings.ReplaceAll(watchBenchmarkTemplate, "{event}", string(msg.Event))
+			_ = oldFormatCommand(format, watchFindMessage(msg))
+		}
+	}
+}
+
+func BenchmarkWatchFormatTemplate(b *testing.B) {
+	t, err := ParseTemplate(watchBenchmarkTemplate)
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for _, msg := range watchBenchmarkEvents {
+			_ = t.RenderWatch(msg)
+		}
+	}
+}
@@ -0,0 +1,75 @@
+package stride
+
+import (
+	"fmt"
+	"strings"
+)
+
+// FSInfo describes the filesystem holding the walk root, as reported by the
+// operating system when the walk started. Fields the platform does not
+// report are zero.
+type FSInfo struct {
+	TotalBytes  uint64 `json:"total_bytes"`  // Size of the filesystem
+	FreeBytes   uint64 `json:"free_bytes"`   // Free space, including space reserved for the superuser
+	AvailBytes  uint64 `json:"avail_bytes"`  // Free space available to unprivileged users
+	TotalInodes uint64 `json:"total_inodes"` // Number of inodes (file nodes)
+	FreeInodes  uint64 `json:"free_inodes"`  // Number of free inodes
+	FSType      string `json:"fs_type"`      // Filesystem type, e.g. "apfs", "ext4" or "NTFS"
+}
+
+// String summarizes the space and inodes of the filesystem.
+func (fi FSInfo) String() string {
+	s := fmt.Sprintf("%.2f GB available of %.2f GB", gigabytes(fi.AvailBytes), gigabytes(fi.TotalBytes))
+	if fi.FSType != "" {
+		s = fi.FSType + ", " + s
+	}
+	if fi.TotalInodes > 0 {
+		s += fmt.Sprintf(", %d of %d inodes free", fi.FreeInodes, fi.TotalInodes)
+	}
+	return s
+}
+
+// gigabytes converts a byte count for display.
+func gigabytes(n uint64) float64 {
+	return float64(n) / (1024 * 1024 * 1024)
+}
+
+// statFS returns the FSInfo of the filesystem holding path. It is
+// platformStatFS, replaceable in tests.
+var statFS = platformStatFS
+
+// collectFSInfo returns the FSInfo for root, or nil if it is unavailable.
+func collectFSInfo(root string) *FSInfo {
+	info, err := statFS(root)
+	if err != nil {
+		return nil
+	}
+	return &info
+}
+
+// remoteFSTypes are the filesystem types DetectRemoteFS takes for network
+// filesystems.
+var remoteFSTypes = map[string]bool{
+	"9p":     true,
+	"afpfs":  true,
+	"ceph":   true,
+	"cifs":   true,
+	"nfs":    true,
+	"nfs4":   true,
+	"smb":    true,
+	"smb2":   true,
+	"smbfs":  true,
+	"webdav": true,
+}
+
+// DetectRemoteFS reports whether path appears to be on a network filesystem,
+// such as NFS or SMB, where change notifications may not report changes
+// made by other hosts. It is a heuristic based on the filesystem type, and
+// reports false if the type cannot be read.
+func DetectRemoteFS(path string) bool {
+	info, err := statFS(path)
+	if err != nil {
+		return false
+	}
+	return remoteFSTypes[strings.ToLower(info.FSType)]
+}
@@ -0,0 +1,17 @@
+package stride
+
+import (
+	"syscall"
+)
+
+// fsTypeName returns the filesystem type name recorded by statfs(2).
+func fsTypeName(st *syscall.Statfs_t) string {
+	name := make([]byte, 0, len(st.Fstypename))
+	for _, c := range st.Fstypename {
+		if c == 0 {
+			break
+		}
+		name = append(name, byte(c))
+	}
+	return string(name)
+}
@@ -0,0 +1,33 @@
+package stride
+
+import (
+	"fmt"
+	"syscall"
+)
+
+// linuxFSTypes names the magic numbers of common Linux filesystems.
+var linuxFSTypes = map[int64]string{
+	0x9123683e: "btrfs",
+	0x00c36400: "ceph",
+	0xff534d42: "cifs",
+	0xef53:     "ext4", // Also ext2 and ext3
+	0x4d44:     "vfat",
+	0x6969:     "nfs",
+	0x5346544e: "ntfs",
+	0x794c7630: "overlay",
+	0x517b:     "smb",
+	0xfe534d42: "smb2",
+	0x01021994: "tmpfs",
+	0x01021997: "9p",
+	0x58465342: "xfs",
+	0x2fc12fc1: "zfs",
+}
+
+// fsTypeName returns the name of the filesystem type reported by statfs(2),
+// or its magic number if it is not a common one.
+func fsTypeName(st *syscall.Statfs_t) string {
+	if name, ok := linuxFSTypes[int64(st.Type)]; ok {
+		return name
+	}
+	return fmt.Sprintf("0x%x", st.Type)
+}
@@ -0,0 +1,12 @@
+//go:build !darwin && !linux && !windows
+
+package stride
+
+import (
+	"errors"
+)
+
+// platformStatFS is not supported on this platform.
+func platformStatFS(path string) (FSInfo, error) {
+	return FSInfo{}, errors.New("filesystem statistics are not supported on this platform")
+}
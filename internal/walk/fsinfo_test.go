@@ -0,0 +1,98 @@
+package stride
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+// fakeStatFS replaces statFS for the test.
+func fakeStatFS(t *testing.T, fn func(path string) (FSInfo, error)) {
+	t.Helper()
+	orig := statFS
+	statFS = fn
+	t.Cleanup(func() { statFS = orig })
+}
+
+func TestCollectFSInfo(t *testing.T) {
+	root := t.TempDir()
+	if err := os.WriteFile(filepath.Join(root, "a.txt"), []byte("a"), 0644); err != nil {
+		t.Fatalf("Failed to create file: %v", err)
+	}
+
+	var statted []string
+	want := FSInfo{TotalBytes: 1000, FreeBytes: 400, AvailBytes: 300, TotalInodes: 50, FreeInodes: 20, FSType: "fakefs"}
+	fakeStatFS(t, func(path string) (FSInfo, error) {
+		statted = append(statted, path)
+		return want, nil
+	})
+	walkFn := func(path string, info os.FileInfo, err error) error { return err }
+
+	stats, err := WalkLimitWithOptionsStats(context.Background(), root, walkFn, WalkOptions{CollectFSInfo: true})
+	if err != nil {
+		t.Fatalf("Walk failed: %v", err)
+	}
+	if stats.FSInfo == nil || *stats.FSInfo != want {
+		t.Errorf("Expected %+v, got %+v", want, stats.FSInfo)
+	}
+	if len(statted) != 1 || statted[0] != root {
+		t.Errorf("Expected the root to be statted once, got %v", statted)
+	}
+
+	// Not collected unless asked for, nor when unavailable
+	stats, err = WalkLimitWithOptionsStats(context.Background(), root, walkFn, WalkOptions{})
+	if err != nil || stats.FSInfo != nil {
+		t.Errorf("Expected no filesystem info, got %+v (err %v)", stats.FSInfo, err)
+	}
+	fakeStatFS(t, func(path string) (FSInfo, error) {
+		return FSInfo{}, errors.New("unsupported")
+	})
+	stats, err = WalkLimitWithOptionsStats(context.Background(), root, walkFn, WalkOptions{CollectFSInfo: true})
+	if err != nil || stats.FSInfo != nil {
+		t.Errorf("Expected no filesystem info, got %+v (err %v)", stats.FSInfo, err)
+	}
+}
+
+func TestStorageReportFSInfo(t *testing.T) {
+	root := t.TempDir()
+	if err := os.WriteFile(filepath.Join(root, "a.txt"), []byte("a"), 0644); err != nil {
+		t.Fatalf("Failed to create file: %v", err)
+	}
+	fakeStatFS(t, func(path string) (FSInfo, error) {
+		return FSInfo{TotalBytes: 8 << 30, AvailBytes: 2 << 30, TotalInodes: 100, FreeInodes: 40, FSType: "fakefs"}, nil
+	})
+
+	analyzer := NewAnalyzer()
+	analyzer.EnableStorageReport()
+	result, err := analyzer.Analyze(root)
+	if err != nil {
+		t.Fatalf("Analyze failed: %v", err)
+	}
+	if result.StorageReport.FSInfo == nil {
+		t.Fatal("Expected the storage report to include the filesystem")
+	}
+	want := "Filesystem: fakefs, 2.00 GB available of 8.00 GB, 40 of 100 inodes free"
+	if !strings.Contains(result.String(), want) {
+		t.Errorf("Expected report to contain %q, got:\n%s", want, result.String())
+	}
+}
+
+func TestPlatformStatFS(t *testing.T) {
+	if runtime.GOOS != "linux" && runtime.GOOS != "darwin" {
+		t.Skip("statfs is only checked on Linux and macOS")
+	}
+	info, err := platformStatFS(t.TempDir())
+	if err != nil {
+		t.Fatalf("platformStatFS failed: %v", err)
+	}
+	if info.TotalBytes == 0 || info.TotalInodes == 0 || info.FSType == "" {
+		t.Errorf("Expected non-zero totals and a type, got %+v", info)
+	}
+	if info.AvailBytes > info.TotalBytes || info.FreeInodes > info.TotalInodes {
+		t.Errorf("Expected free counts within the totals, got %+v", info)
+	}
+}
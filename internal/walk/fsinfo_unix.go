@@ -0,0 +1,24 @@
+//go:build darwin || linux
+
+package stride
+
+import (
+	"syscall"
+)
+
+// platformStatFS reads the filesystem statistics of path with statfs(2).
+func platformStatFS(path string) (FSInfo, error) {
+	var st syscall.Statfs_t
+	if err := syscall.Statfs(path, &st); err != nil {
+		return FSInfo{}, err
+	}
+	bsize := uint64(st.Bsize)
+	return FSInfo{
+		TotalBytes:  uint64(st.Blocks) * bsize,
+		FreeBytes:   uint64(st.Bfree) * bsize,
+		AvailBytes:  uint64(st.Bavail) * bsize,
+		TotalInodes: uint64(st.Files),
+		FreeInodes:  uint64(st.Ffree),
+		FSType:      fsTypeName(&st),
+	}, nil
+}
@@ -0,0 +1,34 @@
+package stride
+
+import (
+	"path/filepath"
+
+	"golang.org/x/sys/windows"
+)
+
+// platformStatFS reads the space of the volume holding path with
+// GetDiskFreeSpaceEx. Windows does not report inodes.
+func platformStatFS(path string) (FSInfo, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return FSInfo{}, err
+	}
+	p, err := windows.UTF16PtrFromString(abs)
+	if err != nil {
+		return FSInfo{}, err
+	}
+
+	var info FSInfo
+	if err := windows.GetDiskFreeSpaceEx(p, &info.AvailBytes, &info.TotalBytes, &info.FreeBytes); err != nil {
+		return FSInfo{}, err
+	}
+
+	// The type is best effort
+	volume := make([]uint16, windows.MAX_PATH+1)
+	fsName := make([]uint16, windows.MAX_PATH+1)
+	if windows.GetVolumePathName(p, &volume[0], uint32(len(volume))) == nil &&
+		windows.GetVolumeInformation(&volume[0], nil, 0, nil, nil, nil, &fsName[0], uint32(len(fsName))) == nil {
+		info.FSType = windows.UTF16ToString(fsName)
+	}
+	return info, nil
+}
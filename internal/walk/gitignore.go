@@ -0,0 +1,189 @@
+package stride
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// GitignoreName is the file whose rules FilterOptions.RespectGitignore
+// applies to the directory holding it.
+const GitignoreName = ".gitignore"
+
+// ignoreRule is a pattern line of a gitignore file.
+type ignoreRule struct {
+	pattern  string // Glob without its '!', trailing '/' and leading '/'
+	negate   bool   // Re-includes what earlier rules excluded
+	dirOnly  bool   // Matches directories only
+	anchored bool   // Matched against the path below the file's directory rather than the name
+}
+
+// parseIgnoreRules reads rules in gitignore syntax: blank lines and lines
+// starting with '#' are skipped, trailing spaces are dropped unless
+// escaped with '\', a leading '!' negates a rule, and a trailing '/'
+// restricts it to directories. A rule with a '/' elsewhere is anchored to
+// the directory of its file, and "**" segments match any number of
+// directories. Lines whose pattern is malformed are skipped, as git does.
+func parseIgnoreRules(r io.Reader) ([]ignoreRule, error) {
+	var rules []ignoreRule
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := trimIgnoreLine(scanner.Text())
+		if line == "" || line[0] == '#' {
+			continue
+		}
+		var rule ignoreRule
+		if line[0] == '!' {
+			rule.negate = true
+			line = line[1:]
+		}
+		if strings.HasSuffix(line, "/") {
+			rule.dirOnly = true
+			line = strings.TrimRight(line, "/")
+		}
+		rule.anchored = strings.Contains(line, "/")
+		line = strings.TrimLeft(line, "/")
+		if line == "" {
+			continue
+		}
+
+		// "dir/**" matches everything inside dir, but not dir itself
+		if strings.HasSuffix(line, "/**") {
+			line += "/*"
+		}
+		if _, err := path.Match(line, ""); err != nil {
+			continue
+		}
+		rule.pattern = line
+		rules = append(rules, rule)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return rules, nil
+}
+
+// trimIgnoreLine drops the trailing spaces of line, keeping one escaped
+// with a backslash, and a Windows line ending.
+func trimIgnoreLine(line string) string {
+	line = strings.TrimSuffix(line, "\r")
+	for strings.HasSuffix(line, " ") && !strings.HasSuffix(line, "\\ ") {
+		line = line[:len(line)-1]
+	}
+	return line
+}
+
+// matches reports whether the rule matches rel, the slash-separated path
+// of an entry below the directory of the rule's file.
+func (r ignoreRule) matches(rel string, isDir bool) bool {
+	if r.dirOnly && !isDir {
+		return false
+	}
+	if !r.anchored {
+		matched, _ := path.Match(r.pattern, path.Base(rel))
+		return matched
+	}
+	matched, _ := matchSegments(strings.Split(r.pattern, "/"), strings.Split(rel, "/"))
+	return matched
+}
+
+// decideIgnore returns whether the last of rules matching rel ignores it,
+// and whether any does.
+func decideIgnore(rules []ignoreRule, rel string, isDir bool) (ignored, matched bool) {
+	for i := len(rules) - 1; i >= 0; i-- {
+		if rules[i].matches(rel, isDir) {
+			return !rules[i].negate, true
+		}
+	}
+	return false, false
+}
+
+// ignoreMatcher holds the gitignore rules of one walk: those of
+// FilterOptions.IgnoreFile, relative to the root, and, with
+// FilterOptions.RespectGitignore, the .gitignore files of the walked
+// directories, read as the walk reaches them. It is safe for concurrent
+// use.
+type ignoreMatcher struct {
+	root   string
+	base   []ignoreRule
+	nested bool
+	dirs   sync.Map // Directory path -> []ignoreRule of its .gitignore
+}
+
+// resolveIgnoreRules reads filter.IgnoreFile once per walk and prepares
+// the matcher of its rules and, if filter.RespectGitignore is set, of the
+// .gitignore files below root.
+func resolveIgnoreRules(root string, filter FilterOptions) (FilterOptions, error) {
+	filter.ignores = nil
+	if filter.IgnoreFile == "" && !filter.RespectGitignore {
+		return filter, nil
+	}
+	m := &ignoreMatcher{root: root, nested: filter.RespectGitignore}
+	if filter.IgnoreFile != "" {
+		f, err := os.Open(filter.IgnoreFile)
+		if err != nil {
+			return filter, fmt.Errorf("ignore file: %w", err)
+		}
+		defer f.Close()
+		if m.base, err = parseIgnoreRules(f); err != nil {
+			return filter, fmt.Errorf("ignore file %s: %w", filter.IgnoreFile, err)
+		}
+	}
+	filter.ignores = m
+	return filter, nil
+}
+
+// rulesOf returns the rules of the .gitignore in dir, reading it the first
+// time. A file that cannot be read has no rules.
+func (m *ignoreMatcher) rulesOf(dir string) []ignoreRule {
+	if v, ok := m.dirs.Load(dir); ok {
+		return v.([]ignoreRule)
+	}
+	var rules []ignoreRule
+	if f, err := os.Open(filepath.Join(dir, GitignoreName)); err == nil {
+		rules, _ = parseIgnoreRules(f)
+		f.Close()
+	}
+	v, _ := m.dirs.LoadOrStore(dir, rules)
+	return v.([]ignoreRule)
+}
+
+// ignores reports whether the rules exclude the entry at name, below the
+// root. As with git, the .gitignore of a deeper directory overrides those
+// above it, which override the ignore file, and within a file the last
+// matching rule decides. Entries inside an ignored directory are not
+// checked, since the walk never enters it, so none can be re-included.
+func (m *ignoreMatcher) ignores(name string, isDir bool) bool {
+	if m == nil {
+		return false
+	}
+	rel, err := filepath.Rel(m.root, name)
+	if err != nil || rel == "." || !filepath.IsLocal(rel) {
+		return false
+	}
+	rel = filepath.ToSlash(rel)
+	if m.nested {
+		dir := path.Dir(rel)
+		for {
+			below := rel
+			if dir != "." {
+				below = rel[len(dir)+1:]
+			}
+			rules := m.rulesOf(filepath.Join(m.root, filepath.FromSlash(dir)))
+			if ignored, matched := decideIgnore(rules, below, isDir); matched {
+				return ignored
+			}
+			if dir == "." {
+				break
+			}
+			dir = path.Dir(dir)
+		}
+	}
+	ignored, _ := decideIgnore(m.base, rel, isDir)
+	return ignored
+}
@@ -0,0 +1,231 @@
+package stride
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/TFMV/stride/walk/walktest"
+)
+
+func TestParseIgnoreRules(t *testing.T) {
+	input := strings.Join([]string{
+		"# build output",
+		"",
+		"*.o",
+		"!keep.o",
+		"build/",
+		"/root.txt",
+		"docs/*.md",
+		"logs/**",
+		"trailing   ",
+		`escaped\ `,
+		`\#hash`,
+		`\!bang`,
+		"[",
+	}, "\n")
+	rules, err := parseIgnoreRules(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("Failed to parse rules: %v", err)
+	}
+	want := []ignoreRule{
+		{pattern: "*.o"},
+		{pattern: "keep.o", negate: true},
+		{pattern: "build", dirOnly: true},
+		{pattern: "root.txt", anchored: true},
+		{pattern: "docs/*.md", anchored: true},
+		{pattern: "logs/**/*", anchored: true},
+		{pattern: "trailing"},
+		{pattern: `escaped\ `},
+		{pattern: `\#hash`},
+		{pattern: `\!bang`},
+	}
+	if !reflect.DeepEqual(rules, want) {
+		t.Errorf("Expected %+v, got %+v", want, rules)
+	}
+}
+
+func TestIgnoreRules(t *testing.T) {
+	tests := []struct {
+		name  string
+		rules string
+		rel   string
+		isDir bool
+		want  bool
+	}{
+		{"name at any depth", "*.log", "a/b/x.log", false, true},
+		{"name mismatch", "*.log", "a/b/x.txt", false, false},
+		{"negated", "*.log\n!keep.log", "a/keep.log", false, false},
+		{"negation before exclusion", "!keep.log\n*.log", "a/keep.log", false, true},
+		{"directory only, directory", "build/", "src/build", true, true},
+		{"directory only, file", "build/", "src/build", false, false},
+		{"anchored by a leading slash", "/todo.txt", "todo.txt", false, true},
+		{"anchored, deeper", "/todo.txt", "sub/todo.txt", false, false},
+		{"anchored by an inner slash", "doc/*.txt", "doc/a.txt", false, true},
+		{"anchored, star stops at slashes", "doc/*.txt", "doc/x/a.txt", false, false},
+		{"anchored, not below the file", "doc/*.txt", "src/doc/a.txt", false, false},
+		{"leading double star", "**/cache", "a/b/cache", true, true},
+		{"leading double star at the top", "**/cache", "cache", true, true},
+		{"inner double star", "a/**/z.txt", "a/z.txt", false, true},
+		{"inner double star, deeper", "a/**/z.txt", "a/b/c/z.txt", false, true},
+		{"trailing double star, inside", "logs/**", "logs/x/y.txt", false, true},
+		{"trailing double star, itself", "logs/**", "logs", true, false},
+		{"escaped hash", `\#notes`, "#notes", false, true},
+		{"escaped bang", `\!important`, "!important", false, true},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			rules, err := parseIgnoreRules(strings.NewReader(tc.rules))
+			if err != nil {
+				t.Fatalf("Failed to parse rules: %v", err)
+			}
+			if got, _ := decideIgnore(rules, tc.rel, tc.isDir); got != tc.want {
+				t.Errorf("Expected ignored %v for %s with %q, got %v", tc.want, tc.rel, tc.rules, got)
+			}
+		})
+	}
+}
+
+// createGitignoreFixture creates a source tree with nested .gitignore files.
+func createGitignoreFixture(t *testing.T) string {
+	t.Helper()
+	return walktest.Tree{
+		".gitignore":            walktest.File{Content: "*.log\n!important.log\nbuild/\n/secret.txt\n"},
+		"main.go":               walktest.File{Content: "package main"},
+		"debug.log":             walktest.File{Content: "x"},
+		"important.log":         walktest.File{Content: "x"},
+		"secret.txt":            walktest.File{Content: "x"},
+		"build/out.bin":         walktest.File{Content: "x"},
+		"pkg/secret.txt":        walktest.File{Content: "x"},
+		"pkg/util.go":           walktest.File{Content: "x"},
+		"pkg/trace.log":         walktest.File{Content: "x"},
+		"pkg/.gitignore":        walktest.File{Content: "!trace.log\n*.tmp\n"},
+		"pkg/scratch.tmp":       walktest.File{Content: "x"},
+		"pkg/build":             walktest.File{Content: "a file, not a directory"},
+		"pkg/gen/.gitignore":    walktest.File{Content: "/*.go\n!keep.go\n"},
+		"pkg/gen/gen.go":        walktest.File{Content: "x"},
+		"pkg/gen/keep.go":       walktest.File{Content: "x"},
+		"pkg/gen/sub/deep.go":   walktest.File{Content: "x"},
+		"docs/guide.md":         walktest.File{Content: "x"},
+		"docs/build/index.html": walktest.File{Content: "x"},
+	}.Build(t)
+}
+
+// gitignoreWant is what a walk of createGitignoreFixture with
+// RespectGitignore delivers, directories included.
+var gitignoreWant = []string{
+	".", ".gitignore", "docs", "docs/guide.md", "important.log", "main.go",
+	"pkg", "pkg/.gitignore", "pkg/build", "pkg/gen", "pkg/gen/.gitignore",
+	"pkg/gen/keep.go", "pkg/gen/sub", "pkg/gen/sub/deep.go", "pkg/secret.txt",
+	"pkg/trace.log", "pkg/util.go",
+}
+
+func TestWalkRespectGitignore(t *testing.T) {
+	root := createGitignoreFixture(t)
+	filter := FilterOptions{RespectGitignore: true}
+
+	var mu sync.Mutex
+	var got []string
+	collect := func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		got = append(got, relSlashPath(root, path))
+		return nil
+	}
+
+	walks := []struct {
+		name string
+		walk func() error
+	}{
+		{"WalkLimitWithOptions", func() error {
+			return WalkLimitWithOptions(context.Background(), root, collect, WalkOptions{Filter: filter, NumWorkers: 4})
+		}},
+		{"WalkLimitWithFilter", func() error {
+			return WalkLimitWithFilter(context.Background(), root, collect, 4, filter)
+		}},
+		{"WalkWithOptions", func() error {
+			return WalkWithOptions(root, func(ctx context.Context, path string, info os.FileInfo) error {
+				return collect(path, info, nil)
+			}, WalkOptions{Filter: filter, NumWorkers: 4})
+		}},
+		{"WalkDir", func() error {
+			return WalkDir(root, func(ctx context.Context, path string, d fs.DirEntry) error {
+				return collect(path, nil, nil)
+			}, WalkOptions{Filter: filter, NumWorkers: 4})
+		}},
+	}
+	for _, w := range walks {
+		t.Run(w.name, func(t *testing.T) {
+			got = nil
+			if err := w.walk(); err != nil {
+				t.Fatalf("Failed to walk: %v", err)
+			}
+			sort.Strings(got)
+			if !reflect.DeepEqual(got, gitignoreWant) {
+				t.Errorf("Expected %v, got %v", gitignoreWant, got)
+			}
+		})
+	}
+}
+
+func TestWalkIgnoreFile(t *testing.T) {
+	root := walktest.Tree{
+		"a.txt":          walktest.File{Content: "a"},
+		"b.tmp":          walktest.File{Content: "b"},
+		"cache/c.txt":    walktest.File{Content: "c"},
+		"sub/.gitignore": walktest.File{Content: "!*.tmp\n"},
+		"sub/d.tmp":      walktest.File{Content: "d"},
+	}.Build(t)
+	ignoreFile := filepath.Join(t.TempDir(), "ignore")
+	if err := os.WriteFile(ignoreFile, []byte("*.tmp\ncache/\n"), 0644); err != nil {
+		t.Fatalf("Failed to create ignore file: %v", err)
+	}
+
+	walk := func(filter FilterOptions) []string {
+		t.Helper()
+		var mu sync.Mutex
+		var paths []string
+		err := WalkLimitWithOptions(context.Background(), root, func(path string, info os.FileInfo, err error) error {
+			if err == nil && !info.IsDir() {
+				mu.Lock()
+				paths = append(paths, relSlashPath(root, path))
+				mu.Unlock()
+			}
+			return err
+		}, WalkOptions{Filter: filter})
+		if err != nil {
+			t.Fatalf("Failed to walk: %v", err)
+		}
+		sort.Strings(paths)
+		return paths
+	}
+
+	// The ignore file alone leaves .gitignore files alone
+	if got, want := walk(FilterOptions{IgnoreFile: ignoreFile}), []string{"a.txt", "sub/.gitignore"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected %v, got %v", want, got)
+	}
+
+	// A .gitignore overrides the ignore file
+	if got, want := walk(FilterOptions{IgnoreFile: ignoreFile, RespectGitignore: true}), []string{"a.txt", "sub/.gitignore", "sub/d.tmp"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected %v, got %v", want, got)
+	}
+
+	// A missing ignore file fails the walk before it starts
+	err := WalkLimitWithOptions(context.Background(), root, func(path string, info os.FileInfo, err error) error {
+		t.Errorf("Expected no callback, got %s", path)
+		return nil
+	}, WalkOptions{Filter: FilterOptions{IgnoreFile: filepath.Join(root, "missing")}})
+	if !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("Expected an error matching fs.ErrNotExist, got %v", err)
+	}
+}
@@ -0,0 +1,72 @@
+package stride
+
+import (
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// MatchPattern reports whether rel, a slash-separated path relative to a walk
+// or watch root, matches pattern.
+//
+// A pattern without a '/' is matched against the base name only, exactly as
+// filepath.Match would, so "*.txt" matches "a/b/notes.txt". A pattern with a
+// '/' is matched against the whole relative path, segment by segment: "**"
+// as a complete segment matches zero or more path segments, and every other
+// segment uses path.Match syntax. So "src/**/*.go" matches "src/main.go" and
+// "src/a/b/x.go", and "vendor/**" matches "vendor" and everything below it.
+//
+// The only possible error is path.ErrBadPattern or filepath.ErrBadPattern.
+func MatchPattern(pattern, rel string) (bool, error) {
+	if !isPathPattern(pattern) {
+		return filepath.Match(pattern, path.Base(rel))
+	}
+	return matchSegments(strings.Split(pattern, "/"), strings.Split(rel, "/"))
+}
+
+// isPathPattern reports whether pattern is matched against the relative path
+// rather than the base name.
+func isPathPattern(pattern string) bool {
+	return strings.Contains(pattern, "/")
+}
+
+// matchSegments matches path segments against pattern segments, expanding
+// "**" to any number of segments.
+func matchSegments(pattern, segs []string) (bool, error) {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			for len(pattern) > 0 && pattern[0] == "**" {
+				pattern = pattern[1:]
+			}
+			if len(pattern) == 0 {
+				return true, nil
+			}
+			for i := 0; i <= len(segs); i++ {
+				if ok, err := matchSegments(pattern, segs[i:]); err != nil || ok {
+					return ok, err
+				}
+			}
+			return false, nil
+		}
+
+		if len(segs) == 0 {
+			return false, nil
+		}
+		ok, err := path.Match(pattern[0], segs[0])
+		if err != nil || !ok {
+			return false, err
+		}
+		pattern, segs = pattern[1:], segs[1:]
+	}
+	return len(segs) == 0, nil
+}
+
+// relSlashPath returns path relative to root with forward slashes. Paths
+// outside root are returned unchanged apart from the separators.
+func relSlashPath(root, p string) string {
+	rel, err := filepath.Rel(filepath.Clean(root), filepath.Clean(p))
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return filepath.ToSlash(p)
+	}
+	return filepath.ToSlash(rel)
+}
@@ -0,0 +1,140 @@
+package stride
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestMatchPattern(t *testing.T) {
+	tests := []struct {
+		pattern  string
+		path     string
+		expected bool
+	}{
+		// Base name patterns behave like filepath.Match on the name
+		{"*.txt", "notes.txt", true},
+		{"*.txt", "a/b/notes.txt", true},
+		{"*.txt", "a/b/notes.go", false},
+		{"ignore*", "dir/ignore.txt", true},
+		{"notes.txt", "a/notes.txt", true},
+		{"*", "a/b", true},
+
+		// Path patterns match the whole relative path
+		{"src/**/*.go", "src/a/b/x.go", true},
+		{"src/**/*.go", "src/main.go", true},
+		{"src/**/*.go", "main.go", false},
+		{"src/**/*.go", "lib/src/main.go", false},
+		{"src/**/*.go", "src/a/b/x.txt", false},
+		{"vendor/**", "vendor/pkg/y.go", true},
+		{"vendor/**", "vendor", true},
+		{"vendor/**", "src/vendor/y.go", false},
+		{"**/vendor/**", "src/vendor/y.go", true},
+		{"**/*.go", "main.go", true},
+		{"**/*.go", "a/b/c/main.go", true},
+		{"a/**/b/**/c", "a/x/b/y/z/c", true},
+		{"a/**/b/**/c", "a/x/c", false},
+		{"a/**/**/c", "a/c", true},
+		{"src/*.go", "src/main.go", true},
+		{"src/*.go", "src/a/main.go", false},
+		{"src/a?/*.go", "src/ab/x.go", true},
+		{"src/[ab]/*.go", "src/c/x.go", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.pattern+" "+tt.path, func(t *testing.T) {
+			got, err := MatchPattern(tt.pattern, tt.path)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if got != tt.expected {
+				t.Errorf("Expected %v, got %v", tt.expected, got)
+			}
+		})
+	}
+
+	// Base name patterns agree with filepath.Match
+	for _, pattern := range []string{"*.txt", "test?.go", "[a-c]*", "*"} {
+		for _, name := range []string{"test1.go", "a.txt", "d.txt", ".hidden"} {
+			want, _ := filepath.Match(pattern, name)
+			got, _ := MatchPattern(pattern, "dir/"+name)
+			if got != want {
+				t.Errorf("MatchPattern(%q, %q) = %v, filepath.Match = %v", pattern, name, got, want)
+			}
+		}
+	}
+
+	if _, err := MatchPattern("src/[/*.go", "src/a/x.go"); err == nil {
+		t.Error("Expected error for malformed pattern")
+	}
+}
+
+func TestFilterPathPattern(t *testing.T) {
+	root := t.TempDir()
+	for _, name := range []string{"main.go", "src/main.go", "src/a/b/x.go", "src/a/b/notes.txt", "vendor/pkg/y.go"} {
+		path := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("Failed to create directory: %v", err)
+		}
+		if err := os.WriteFile(path, []byte("x"), 0644); err != nil {
+			t.Fatalf("Failed to create file: %v", err)
+		}
+	}
+
+	tests := []struct {
+		pattern  string
+		expected []string
+	}{
+		{"src/**/*.go", []string{"src/a/b/x.go", "src/main.go"}},
+		{"*.go", []string{"main.go", "src/a/b/x.go", "src/main.go", "vendor/pkg/y.go"}},
+		{"vendor/**", []string{"vendor/pkg/y.go"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.pattern, func(t *testing.T) {
+			opts := WalkOptions{Filter: FilterOptions{Pattern: tt.pattern}}
+
+			var mu sync.Mutex
+			var got []string
+			record := func(path string) {
+				rel, _ := filepath.Rel(root, path)
+				mu.Lock()
+				got = append(got, filepath.ToSlash(rel))
+				mu.Unlock()
+			}
+
+			err := WalkLimitWithOptions(context.Background(), root, func(path string, info os.FileInfo, err error) error {
+				if err == nil && !info.IsDir() {
+					record(path)
+				}
+				return err
+			}, opts)
+			if err != nil {
+				t.Fatalf("Walk failed: %v", err)
+			}
+			sort.Strings(got)
+			if strings.Join(got, ",") != strings.Join(tt.expected, ",") {
+				t.Errorf("WalkLimitWithOptions: expected %v, got %v", tt.expected, got)
+			}
+
+			got = nil
+			err = WalkDir(root, func(ctx context.Context, path string, d os.DirEntry) error {
+				if !d.IsDir() {
+					record(path)
+				}
+				return nil
+			}, opts)
+			if err != nil {
+				t.Fatalf("WalkDir failed: %v", err)
+			}
+			sort.Strings(got)
+			if strings.Join(got, ",") != strings.Join(tt.expected, ",") {
+				t.Errorf("WalkDir: expected %v, got %v", tt.expected, got)
+			}
+		})
+	}
+}
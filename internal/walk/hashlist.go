@@ -0,0 +1,109 @@
+package stride
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// HashListMode defines how files found in a hash list are treated.
+type HashListMode int
+
+const (
+	HashListMatch   HashListMode = iota // Only emit files whose hash is in the list
+	HashListExclude                     // Drop files whose hash is in the list
+)
+
+// hashSet is a set of SHA-256 digests loaded from a hash list file.
+type hashSet map[[sha256.Size]byte]struct{}
+
+// contains reports whether the hex-encoded digest is in the set.
+func (s hashSet) contains(digest string) bool {
+	var key [sha256.Size]byte
+	if _, err := hex.Decode(key[:], []byte(digest)); err != nil {
+		return false
+	}
+	_, ok := s[key]
+	return ok
+}
+
+// loadHashList reads a file of hex SHA-256 digests, one per line.
+// Lines may carry an optional "sha256:" prefix and trailing fields (as
+// produced by sha256sum); blank lines and lines starting with '#' are
+// ignored. Gzip-compressed lists are detected and decompressed transparently.
+func loadHashList(path string) (hashSet, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("opening hash list: %w", err)
+	}
+	defer f.Close()
+
+	br := bufio.NewReader(f)
+	var r io.Reader = br
+	if magic, err := br.Peek(2); err == nil && bytes.Equal(magic, []byte{0x1f, 0x8b}) {
+		gz, err := gzip.NewReader(br)
+		if err != nil {
+			return nil, fmt.Errorf("reading gzip hash list: %w", err)
+		}
+		defer gz.Close()
+		r = gz
+	}
+
+	set := make(hashSet)
+	scanner := bufio.NewScanner(r)
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		digest := strings.Fields(line)[0]
+		if algo, rest, ok := strings.Cut(digest, ":"); ok {
+			if !strings.EqualFold(algo, "sha256") {
+				return nil, fmt.Errorf("hash list line %d: unsupported algorithm %q", lineNo, algo)
+			}
+			digest = rest
+		}
+
+		var key [sha256.Size]byte
+		if len(digest) != hex.EncodedLen(sha256.Size) {
+			return nil, fmt.Errorf("hash list line %d: invalid sha256 digest %q", lineNo, digest)
+		}
+		if _, err := hex.Decode(key[:], []byte(digest)); err != nil {
+			return nil, fmt.Errorf("hash list line %d: invalid sha256 digest %q", lineNo, digest)
+		}
+		set[key] = struct{}{}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("reading hash list: %w", err)
+	}
+
+	return set, nil
+}
+
+// hashFile returns the hex-encoded SHA-256 digest of a file's content.
+func hashFile(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	return hashContent(f)
+}
+
+// hashContent returns the hex-encoded SHA-256 digest of what r reads.
+func hashContent(r io.Reader) (string, error) {
+	h := sha256.New()
+	if _, err := io.Copy(h, r); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
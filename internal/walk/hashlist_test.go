@@ -0,0 +1,119 @@
+package stride
+
+import (
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"testing"
+)
+
+func TestFindHashList(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	// Create fixture files with known content
+	contents := map[string]string{
+		"bad.txt":   "known bad content",
+		"good1.txt": "harmless content",
+		"good2.txt": "more harmless content",
+	}
+	digests := make(map[string]string)
+	for name, content := range contents {
+		if err := os.WriteFile(filepath.Join(tmpDir, name), []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to create file: %v", err)
+		}
+		sum := sha256.Sum256([]byte(content))
+		digests[name] = hex.EncodeToString(sum[:])
+	}
+
+	// Write the hash list outside the walked tree
+	listDir := t.TempDir()
+	plainList := filepath.Join(listDir, "list.txt")
+	listContent := "# known bad hashes\n\nsha256:" + digests["bad.txt"] + "  bad.txt\n"
+	if err := os.WriteFile(plainList, []byte(listContent), 0644); err != nil {
+		t.Fatalf("Failed to create hash list: %v", err)
+	}
+
+	gzList := filepath.Join(listDir, "list.txt.gz")
+	f, err := os.Create(gzList)
+	if err != nil {
+		t.Fatalf("Failed to create gzip hash list: %v", err)
+	}
+	gz := gzip.NewWriter(f)
+	if _, err := gz.Write([]byte(listContent)); err != nil {
+		t.Fatalf("Failed to write gzip hash list: %v", err)
+	}
+	gz.Close()
+	f.Close()
+
+	tests := []struct {
+		name     string
+		list     string
+		mode     HashListMode
+		expected []string
+	}{
+		{"Match", plainList, HashListMatch, []string{"bad.txt"}},
+		{"Exclude", plainList, HashListExclude, []string{"good1.txt", "good2.txt"}},
+		{"Gzip match", gzList, HashListMatch, []string{"bad.txt"}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var mu sync.Mutex
+			var found []string
+			opts := FindOptions{HashList: test.list, HashListMode: test.mode}
+			err := Find(context.Background(), tmpDir, opts, func(ctx context.Context, result FindResult) error {
+				if result.Error != nil {
+					return result.Error
+				}
+				if got := result.Message.Metadata["sha256"]; got != digests[result.Message.Name] {
+					t.Errorf("Expected sha256 %s for %s, got %s", digests[result.Message.Name], result.Message.Name, got)
+				}
+				mu.Lock()
+				found = append(found, result.Message.Name)
+				mu.Unlock()
+				return nil
+			})
+			if err != nil {
+				t.Fatalf("Find failed: %v", err)
+			}
+
+			sort.Strings(found)
+			if len(found) != len(test.expected) {
+				t.Fatalf("Expected %v, got %v", test.expected, found)
+			}
+			for i := range found {
+				if found[i] != test.expected[i] {
+					t.Errorf("Expected %v, got %v", test.expected, found)
+				}
+			}
+		})
+	}
+
+	t.Run("Invalid list", func(t *testing.T) {
+		badList := filepath.Join(listDir, "bad.txt")
+		if err := os.WriteFile(badList, []byte("not-a-digest\n"), 0644); err != nil {
+			t.Fatalf("Failed to create hash list: %v", err)
+		}
+		err := Find(context.Background(), tmpDir, FindOptions{HashList: badList}, func(ctx context.Context, result FindResult) error {
+			return nil
+		})
+		if err == nil {
+			t.Error("Expected error for invalid hash list")
+		}
+	})
+}
+
+func TestFormatCommandSHA256(t *testing.T) {
+	msg := FindMessage{
+		Path:     "/tmp/file.txt",
+		Metadata: map[string]string{"sha256": "abc123"},
+	}
+	if got := formatCommand("{sha256} {}", msg); got != "abc123 /tmp/file.txt" {
+		t.Errorf("Expected %q, got %q", "abc123 /tmp/file.txt", got)
+	}
+}
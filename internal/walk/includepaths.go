@@ -0,0 +1,172 @@
+package stride
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// pathAllowlist holds FilterOptions.IncludePaths compiled for one walk: the
+// prefixes relative to the walk root, sorted, with prefixes below another
+// prefix removed. Separators are stored as NUL so that a prefix sorts
+// directly before its descendants, ahead of siblings such as "a-b" or "a.go"
+// that would otherwise fall between "a" and "a/b", and both checks are a
+// single binary search.
+type pathAllowlist struct {
+	root string
+	keys []string
+}
+
+// resolveIncludePaths compiles filter.IncludePaths against root once per
+// walk. Relative prefixes are taken relative to root; prefixes outside root
+// allow nothing, and one naming root or above it allows everything.
+func resolveIncludePaths(root string, filter FilterOptions) (FilterOptions, error) {
+	filter.includes = nil
+	if len(filter.IncludePaths) == 0 {
+		return filter, nil
+	}
+	absRoot, err := filepath.Abs(root)
+	if err != nil {
+		return filter, err
+	}
+
+	var keys []string
+	for _, prefix := range filter.IncludePaths {
+		if prefix == "" {
+			continue
+		}
+		rel := filepath.Clean(prefix)
+		if filepath.IsAbs(prefix) {
+			if rel, err = filepath.Rel(absRoot, rel); err != nil {
+				continue
+			}
+		}
+		if rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			// Outside the root, unless the root lies within the prefix
+			if abs, _ := filepath.Abs(filepath.Join(root, rel)); !withinPath(absRoot, abs) {
+				continue
+			}
+			rel = "."
+		}
+		keys = append(keys, allowlistKey(rel))
+	}
+	sort.Strings(keys)
+
+	// Drop prefixes already covered by an earlier, shorter one
+	a := &pathAllowlist{root: root}
+	for _, key := range keys {
+		if n := len(a.keys); n > 0 && coveredBy(key, a.keys[n-1]) {
+			continue
+		}
+		a.keys = append(a.keys, key)
+	}
+	filter.includes = a
+	return filter, nil
+}
+
+// withinPath checks if path is dir or lies below it.
+func withinPath(path, dir string) bool {
+	rel, err := filepath.Rel(dir, path)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// allowlistKey converts a cleaned path relative to the root into a key, the
+// root itself being the empty key.
+func allowlistKey(rel string) string {
+	if rel == "." {
+		return ""
+	}
+	return strings.ReplaceAll(filepath.ToSlash(rel), "/", "\x00")
+}
+
+// coveredBy checks if key equals prefix or lies below it.
+func coveredBy(key, prefix string) bool {
+	return prefix == "" || key == prefix || strings.HasPrefix(key, prefix+"\x00")
+}
+
+// key returns the key of path, or false if path lies outside the root.
+func (a *pathAllowlist) key(path string) (string, bool) {
+	rel, err := filepath.Rel(a.root, path)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", false
+	}
+	return allowlistKey(rel), true
+}
+
+// allows checks if path equals an allowed prefix or lies below one. Since
+// the keys hold no prefix of one another, only the greatest key not after
+// path can cover it.
+func (a *pathAllowlist) allows(path string) bool {
+	if a == nil {
+		return true
+	}
+	key, ok := a.key(path)
+	if !ok {
+		return false
+	}
+	i := sort.SearchStrings(a.keys, key)
+	if i < len(a.keys) && a.keys[i] == key {
+		return true
+	}
+	return i > 0 && coveredBy(key, a.keys[i-1])
+}
+
+// leadsTo checks if the directory at path holds an allowed prefix, so that
+// it must be walked to reach it. Descendants of path sort directly after it,
+// so only the first key after them all can tell.
+func (a *pathAllowlist) leadsTo(path string) bool {
+	key, ok := a.key(path)
+	if !ok {
+		return false
+	}
+	if key == "" {
+		return len(a.keys) > 0
+	}
+	below := key + "\x00"
+	i := sort.SearchStrings(a.keys, below)
+	return i < len(a.keys) && strings.HasPrefix(a.keys[i], below)
+}
+
+// excludesDir checks if the directory at path is neither on the way to an
+// allowed prefix nor within one.
+func (a *pathAllowlist) excludesDir(path string) bool {
+	return a != nil && !a.allows(path) && !a.leadsTo(path)
+}
+
+// ReadIncludePaths reads path prefixes for FilterOptions.IncludePaths, one
+// per line. Blank lines and lines starting with '#' are skipped, and
+// surrounding whitespace is trimmed.
+func ReadIncludePaths(r io.Reader) ([]string, error) {
+	var paths []string
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		paths = append(paths, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return paths, nil
+}
+
+// LoadIncludePaths reads the path prefixes in the file at path; see
+// ReadIncludePaths.
+func LoadIncludePaths(path string) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("include paths: %w", err)
+	}
+	defer f.Close()
+	paths, err := ReadIncludePaths(f)
+	if err != nil {
+		return nil, fmt.Errorf("include paths: %s: %w", path, err)
+	}
+	return paths, nil
+}
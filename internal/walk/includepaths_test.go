@@ -0,0 +1,181 @@
+package stride
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/TFMV/stride/walk/walktest"
+)
+
+func TestPathAllowlist(t *testing.T) {
+	root := filepath.FromSlash("/data/root")
+	filter, err := resolveIncludePaths(root, FilterOptions{IncludePaths: []string{
+		"a",
+		"a/b/c", // Covered by a
+		"x/y",
+		"/data/root/m/n/", // Absolute, within the root
+		"/data/other",     // Outside the root
+		"../sibling",      // Outside the root
+		"",
+	}})
+	if err != nil {
+		t.Fatalf("resolveIncludePaths failed: %v", err)
+	}
+	want := []string{"a", "m\x00n", "x\x00y"}
+	if !reflect.DeepEqual(filter.includes.keys, want) {
+		t.Fatalf("Expected keys %q, got %q", want, filter.includes.keys)
+	}
+
+	tests := []struct {
+		path    string
+		allows  bool
+		descend bool
+	}{
+		{".", false, true},
+		{"a", true, true},
+		{"a/b/c/d.txt", true, true},
+		{"a-b", false, false},
+		{"a.txt", false, false},
+		{"x", false, true},
+		{"x/y", true, true},
+		{"x/y/z", true, true},
+		{"x/yz", false, false},
+		{"x/z", false, false},
+		{"m", false, true},
+		{"m/n/o", true, true},
+		{"b", false, false},
+	}
+	for _, tt := range tests {
+		path := filepath.Join(root, filepath.FromSlash(tt.path))
+		if got := filter.includes.allows(path); got != tt.allows {
+			t.Errorf("Expected allows(%s) to be %v, got %v", tt.path, tt.allows, got)
+		}
+		if got := !filter.includes.excludesDir(path); got != tt.descend {
+			t.Errorf("Expected descending into %s to be %v, got %v", tt.path, tt.descend, got)
+		}
+	}
+
+	// A prefix at or above the root allows everything
+	for _, prefix := range []string{".", "..", "/"} {
+		filter, err := resolveIncludePaths(root, FilterOptions{IncludePaths: []string{"a", prefix}})
+		if err != nil {
+			t.Fatalf("resolveIncludePaths failed: %v", err)
+		}
+		if !filter.includes.allows(filepath.Join(root, "b", "c.txt")) {
+			t.Errorf("Expected %q to allow everything, got keys %q", prefix, filter.includes.keys)
+		}
+	}
+
+	// No include paths allow everything
+	filter, _ = resolveIncludePaths(root, FilterOptions{})
+	if filter.includes != nil || !filter.includes.allows(filepath.Join(root, "b")) {
+		t.Error("Expected no allowlist without include paths")
+	}
+}
+
+func TestReadIncludePaths(t *testing.T) {
+	got, err := ReadIncludePaths(strings.NewReader("# Sources\nsrc\n\n  docs/api  \n#vendor\n"))
+	if err != nil {
+		t.Fatalf("ReadIncludePaths failed: %v", err)
+	}
+	if want := []string{"src", "docs/api"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected %q, got %q", want, got)
+	}
+
+	if _, err := LoadIncludePaths(filepath.Join(t.TempDir(), "missing")); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("Expected a missing file error, got %v", err)
+	}
+}
+
+func TestIncludePathsWalk(t *testing.T) {
+	// Five sibling subtrees, of which "a-b" and "a.d" sort between "a" and
+	// its entries with '/' as the separator
+	root := walktest.Tree{
+		"top.txt":         walktest.File{},
+		"a/1.txt":         walktest.File{},
+		"a/sub/2.txt":     walktest.File{},
+		"a-b/3.txt":       walktest.File{},
+		"a.d/4.txt":       walktest.File{},
+		"b/5.txt":         walktest.File{},
+		"b/sub/6.txt":     walktest.File{},
+		"c/7.txt":         walktest.File{},
+		"c/deep/8.txt":    walktest.File{},
+		"c/deep/in/9.txt": walktest.File{},
+	}.Build(t)
+	filter := FilterOptions{IncludePaths: []string{"a", filepath.Join(root, "c", "deep")}}
+
+	wantFiles := []string{"a/1.txt", "a/sub/2.txt", "c/deep/8.txt", "c/deep/in/9.txt"}
+	wantDirs := []string{".", "a", "a/sub", "c", "c/deep", "c/deep/in"}
+
+	var mu sync.Mutex
+	var files, dirs []string
+	record := func(path string, isDir bool) {
+		mu.Lock()
+		defer mu.Unlock()
+		if isDir {
+			dirs = append(dirs, relSlashPath(root, path))
+		} else {
+			files = append(files, relSlashPath(root, path))
+		}
+	}
+	walkFn := func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		record(path, info.IsDir())
+		return nil
+	}
+
+	walks := []struct {
+		name  string
+		walk  func() error
+		stats func() Stats
+	}{
+		{"WalkLimitWithFilter", func() error {
+			return WalkLimitWithFilter(context.Background(), root, walkFn, 2, filter)
+		}, nil},
+		{"WalkLimitWithOptions", nil, func() Stats {
+			stats, err := WalkLimitWithOptionsStats(context.Background(), root, walkFn, WalkOptions{Filter: filter, NumWorkers: 2})
+			if err != nil {
+				t.Errorf("WalkLimitWithOptionsStats failed: %v", err)
+			}
+			return stats
+		}},
+		{"WalkDir", func() error {
+			return WalkDir(root, func(ctx context.Context, path string, d fs.DirEntry) error {
+				record(path, d.IsDir())
+				return nil
+			}, WalkOptions{Filter: filter, NumWorkers: 2})
+		}, nil},
+	}
+	for _, w := range walks {
+		t.Run(w.name, func(t *testing.T) {
+			files, dirs = nil, nil
+			if w.stats != nil {
+				stats := w.stats()
+				if stats.FilesProcessed != int64(len(wantFiles)) {
+					t.Errorf("Expected %d files processed, got %d", len(wantFiles), stats.FilesProcessed)
+				}
+			} else if err := w.walk(); err != nil {
+				t.Fatalf("Walk failed: %v", err)
+			}
+
+			sort.Strings(files)
+			sort.Strings(dirs)
+			if !reflect.DeepEqual(files, wantFiles) {
+				t.Errorf("Expected files %q, got %q", wantFiles, files)
+			}
+			if !reflect.DeepEqual(dirs, wantDirs) {
+				t.Errorf("Expected directories %q, got %q", wantDirs, dirs)
+			}
+		})
+	}
+}
@@ -0,0 +1,401 @@
+package stride
+
+import (
+	"context"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// IncrementalMode selects what an incremental walk does with the contents of
+// directories that are unchanged since the previous run.
+type IncrementalMode int
+
+const (
+	IncrementalReplay IncrementalMode = iota // Report cached file entries without reading the directory
+	IncrementalPrune                         // Skip cached file entries entirely
+)
+
+// CacheStrictness selects how an incremental walk decides that a directory
+// is unchanged.
+type CacheStrictness int
+
+const (
+	CacheStrictCount CacheStrictness = iota // Modification time and entry count must match
+	CacheStrictMtime                        // Only the modification time must match; no directory read
+	CacheStrictRacy                         // Like CacheStrictCount, and directories modified shortly before the previous run are always re-read
+)
+
+// mtimeCacheMagic and mtimeCacheVersion form the header of a cache file.
+// Files with a different header are ignored and rewritten.
+const (
+	mtimeCacheMagic   = "stride-mtime-cache"
+	mtimeCacheVersion = 1
+)
+
+// racyWindow is how close to the previous run a directory's modification
+// time may be before CacheStrictRacy distrusts it. Changes made within the
+// same timestamp granularity as the recorded mtime would otherwise go unseen.
+const racyWindow = 2 * time.Second
+
+// readDirFn reads a directory for an incremental walk. Tests replace it to
+// count which directories are re-enumerated.
+var readDirFn = os.ReadDir
+
+// mtimeCacheHeader precedes the cache body in a cache file.
+type mtimeCacheHeader struct {
+	Magic   string
+	Version int
+}
+
+// mtimeCache records the directories seen by an incremental walk, keyed by
+// slash-separated path relative to the root ("." for the root itself).
+type mtimeCache struct {
+	Root    string
+	Started time.Time // When the walk that wrote the cache started
+	Dirs    map[string]cachedDir
+}
+
+// cachedDir is the recorded state of a single directory.
+type cachedDir struct {
+	ModTime int64        // Modification time in Unix nanoseconds
+	Entries int          // Number of direct entries
+	Files   []cachedFile // Non-directory entries
+	Subdirs []string     // Names of subdirectories
+}
+
+// cachedFile is the recorded state of a non-directory entry.
+type cachedFile struct {
+	Name    string
+	Size    int64
+	Mode    fs.FileMode
+	ModTime int64
+}
+
+// cachedFileInfo implements os.FileInfo for a replayed entry. It carries no
+// system-specific data, so owner filters do not match replayed entries.
+type cachedFileInfo struct {
+	f cachedFile
+}
+
+func (i cachedFileInfo) Name() string       { return i.f.Name }
+func (i cachedFileInfo) Size() int64        { return i.f.Size }
+func (i cachedFileInfo) Mode() fs.FileMode  { return i.f.Mode }
+func (i cachedFileInfo) ModTime() time.Time { return time.Unix(0, i.f.ModTime) }
+func (i cachedFileInfo) IsDir() bool        { return false }
+func (i cachedFileInfo) Sys() any           { return nil }
+
+// loadMtimeCache reads the cache at path. A missing, unreadable or
+// incompatible cache, or one written for another root, yields nil.
+func loadMtimeCache(path, root string) *mtimeCache {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+
+	dec := gob.NewDecoder(f)
+	var header mtimeCacheHeader
+	if err := dec.Decode(&header); err != nil || header.Magic != mtimeCacheMagic || header.Version != mtimeCacheVersion {
+		return nil
+	}
+	var cache mtimeCache
+	if err := dec.Decode(&cache); err != nil || cache.Root != root {
+		return nil
+	}
+	return &cache
+}
+
+// save writes the cache to path, replacing any previous file atomically.
+func (c *mtimeCache) save(path string) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	enc := gob.NewEncoder(tmp)
+	if err := enc.Encode(mtimeCacheHeader{Magic: mtimeCacheMagic, Version: mtimeCacheVersion}); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := enc.Encode(c); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// incrementalWalker walks a tree depth-first, reusing the previous run's
+// cache for unchanged directories and recording the current run's.
+type incrementalWalker struct {
+	ctx      context.Context
+	root     string
+	walkFn   filepath.WalkFunc
+	opts     WalkOptions
+	stats    *Stats
+	tracker  *dirTracker
+	post     *postVisitor
+	prev     *mtimeCache
+	next     *mtimeCache
+	tasks    chan walkArgs
+	prune    func(path string) bool
+	retry    *transientRetrier
+	gate     *enumGate
+	prefetch *prefetcher
+}
+
+// walkIncremental walks root for WalkOptions.MtimeCache. Directories whose
+// cached state still matches are not read: their files are replayed from the
+// cache or pruned, depending on opts.IncrementalMode, and their cached
+// subdirectories are visited in turn, since a directory's modification time
+// does not reflect changes further down. The cache is rewritten unless the
+// walk is canceled. Dispatched files are reported to post.
+func walkIncremental(ctx context.Context, root string, walkFn filepath.WalkFunc, opts WalkOptions, stats *Stats, tracker *dirTracker, post *postVisitor) error {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	absRoot, err := filepath.Abs(root)
+	if err != nil {
+		return err
+	}
+
+	w := &incrementalWalker{
+		ctx:     ctx,
+		root:    root,
+		walkFn:  walkFn,
+		opts:    opts,
+		stats:   stats,
+		tracker: tracker,
+		post:    post,
+		prev:    loadMtimeCache(opts.MtimeCache, absRoot),
+		next:    &mtimeCache{Root: absRoot, Started: time.Now(), Dirs: make(map[string]cachedDir)},
+		tasks:   make(chan walkArgs, queueSize(opts.QueueSize, opts.NumWorkers)),
+		prune:   pruneExcluded(root, opts.Filter, &stats.FilesFiltered),
+		retry:   newTransientRetrier(ctx, opts.TransientRetry, &stats.TransientRetries),
+	}
+
+	walkErrors := newErrorCollector(opts.MaxRetainedErrors)
+	var workerWg sync.WaitGroup
+	for i := 0; i < opts.NumWorkers; i++ {
+		workerWg.Add(1)
+		go func() {
+			defer workerWg.Done()
+			for task := range w.tasks {
+				if ret := walkFn(task.path, task.info, task.err); ret != nil && !errors.Is(ret, filepath.SkipDir) {
+					walkErrors.add(task.path, ret)
+				}
+				post.fileDone(task.path)
+			}
+		}()
+	}
+
+	// Enumeration shares a limiter with other walks, letting go of it
+	// for the callbacks it makes itself
+	w.gate = newEnumGate(ctx, opts.limiter())
+	w.walkFn = w.gate.pauseWalkFn(walkFn)
+	w.prefetch = newPrefetcher(opts.Prefetch)
+	err = w.walkRoot()
+	w.gate.done()
+	tracker.finish()
+	post.finish()
+	close(w.tasks)
+	workerWg.Wait()
+	w.prefetch.stop()
+
+	if err != nil && !errors.Is(err, filepath.SkipDir) {
+		walkErrors.add("", err)
+	}
+	if ctx.Err() == nil {
+		if err := w.next.save(opts.MtimeCache); err != nil {
+			walkErrors.add("", fmt.Errorf("saving mtime cache: %w", err))
+		}
+	}
+
+	return walkErrors.err()
+}
+
+// walkRoot visits the root, which may also be a single file.
+func (w *incrementalWalker) walkRoot() error {
+	info, err := os.Lstat(w.root)
+	if err != nil {
+		return w.walkFn(w.root, nil, err)
+	}
+	w.tracker.enterEntry(w.root, info)
+	if !info.IsDir() {
+		return w.send(w.root, info)
+	}
+	return w.walkDir(w.root, ".", info)
+}
+
+// walkDir visits the directory at path, whose root-relative key is rel.
+func (w *incrementalWalker) walkDir(path, rel string, info os.FileInfo) error {
+	if err := w.ctx.Err(); err != nil {
+		return err
+	}
+	if ret := w.walkFn(path, info, nil); ret != nil {
+		if errors.Is(ret, filepath.SkipDir) {
+			w.tracker.skip(path)
+			return nil
+		}
+		return ret
+	}
+
+	dir, ok := w.unchanged(path, rel, info)
+	if ok {
+		atomic.AddInt64(&w.stats.SkippedUnchangedDirs, 1)
+	} else {
+		var err error
+		dir, err = w.readDir(path, info)
+		if err != nil {
+			w.tracker.skip(path)
+			return w.walkFn(path, info, err)
+		}
+	}
+	w.next.Dirs[rel] = dir
+
+	// Visit entries in name order, like filepath.WalkDir
+	names := make([]string, 0, len(dir.Files)+len(dir.Subdirs))
+	files := make(map[string]cachedFile, len(dir.Files))
+	for _, f := range dir.Files {
+		names = append(names, f.Name)
+		files[f.Name] = f
+	}
+	names = append(names, dir.Subdirs...)
+	sort.Strings(names)
+
+	for _, name := range names {
+		child := filepath.Join(path, name)
+		if f, isFile := files[name]; isFile {
+			if f.Mode&os.ModeSymlink != 0 && w.opts.SymlinkHandling == SymlinkIgnore {
+				w.tracker.link(false)
+				continue
+			}
+			w.tracker.enter(child, false)
+			if ok && w.opts.IncrementalMode == IncrementalPrune {
+				continue
+			}
+			if err := w.send(child, cachedFileInfo{f}); err != nil {
+				return err
+			}
+			continue
+		}
+
+		// Excluded directories are neither stat'ed nor read
+		if w.prune != nil && w.prune(child) {
+			w.tracker.enter(child, true)
+			w.tracker.skip(child)
+			continue
+		}
+		var childInfo os.FileInfo
+		err := w.retry.call(child, func() (err error) {
+			childInfo, err = os.Lstat(child)
+			return err
+		})
+		if err != nil {
+			// Removed since the directory was checked
+			if ret := w.walkFn(child, nil, err); ret != nil {
+				return ret
+			}
+			continue
+		}
+		w.tracker.enterEntry(child, childInfo)
+		if !childInfo.IsDir() {
+			if err := w.send(child, childInfo); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := w.walkDir(child, filepath.ToSlash(filepath.Join(rel, name)), childInfo); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// unchanged returns the cached state of the directory if it still matches
+// under the configured strictness.
+func (w *incrementalWalker) unchanged(path, rel string, info os.FileInfo) (cachedDir, bool) {
+	if w.prev == nil {
+		return cachedDir{}, false
+	}
+	dir, ok := w.prev.Dirs[rel]
+	if !ok || dir.ModTime != info.ModTime().UnixNano() {
+		return cachedDir{}, false
+	}
+	if w.opts.IncrementalStrictness == CacheStrictMtime {
+		return dir, true
+	}
+	if w.opts.IncrementalStrictness == CacheStrictRacy && !info.ModTime().Before(w.prev.Started.Add(-racyWindow)) {
+		return cachedDir{}, false
+	}
+
+	// Counting names does not stat the entries
+	f, err := os.Open(path)
+	if err != nil {
+		return cachedDir{}, false
+	}
+	defer f.Close()
+	names, err := f.Readdirnames(-1)
+	if err != nil || len(names) != dir.Entries {
+		return cachedDir{}, false
+	}
+	return dir, true
+}
+
+// readDir enumerates the directory at path and returns its state.
+func (w *incrementalWalker) readDir(path string, info os.FileInfo) (cachedDir, error) {
+	var entries []os.DirEntry
+	err := w.retry.call(path, func() (err error) {
+		entries, err = readDirFn(path)
+		return err
+	})
+	if err != nil {
+		return cachedDir{}, err
+	}
+	dir := cachedDir{ModTime: info.ModTime().UnixNano(), Entries: len(entries)}
+	for _, e := range entries {
+		if e.IsDir() {
+			dir.Subdirs = append(dir.Subdirs, e.Name())
+			continue
+		}
+		fi, err := e.Info()
+		if err != nil {
+			// Removed since it was listed; the next run sees a new count
+			continue
+		}
+		if fi.IsDir() {
+			// Listed without its type, as on some FUSE filesystems
+			dir.Subdirs = append(dir.Subdirs, e.Name())
+			continue
+		}
+		dir.Files = append(dir.Files, cachedFile{
+			Name:    e.Name(),
+			Size:    fi.Size(),
+			Mode:    fi.Mode(),
+			ModTime: fi.ModTime().UnixNano(),
+		})
+	}
+	return dir, nil
+}
+
+// send passes a file to the worker pool.
+func (w *incrementalWalker) send(path string, info os.FileInfo) error {
+	w.post.dispatchFile(path)
+	w.prefetch.hint(path, info)
+	if !w.gate.send(w.ctx, w.tasks, walkArgs{path: path, info: info}) {
+		w.post.fileDone(path)
+		return w.ctx.Err()
+	}
+	return nil
+}
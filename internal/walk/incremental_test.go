@@ -0,0 +1,240 @@
+package stride
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+)
+
+// createIncrementalFixture builds a small tree with nested directories:
+//
+//	root/top.txt
+//	root/a/1.txt, root/a/2.txt
+//	root/b/3.txt
+//	root/b/c/4.txt
+func createIncrementalFixture(t *testing.T) string {
+	root := t.TempDir()
+	for _, name := range []string{"top.txt", "a/1.txt", "a/2.txt", "b/3.txt", "b/c/4.txt"} {
+		path := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("Failed to create directory: %v", err)
+		}
+		if err := os.WriteFile(path, []byte(name), 0644); err != nil {
+			t.Fatalf("Failed to create file: %v", err)
+		}
+	}
+	return root
+}
+
+// countReadDirs replaces readDirFn for the duration of the test and returns
+// a function reporting the root-relative directories read since its last call.
+func countReadDirs(t *testing.T, root string) func() []string {
+	var mu sync.Mutex
+	var reads []string
+	orig := readDirFn
+	readDirFn = func(name string) ([]os.DirEntry, error) {
+		rel, _ := filepath.Rel(root, name)
+		mu.Lock()
+		reads = append(reads, filepath.ToSlash(rel))
+		mu.Unlock()
+		return orig(name)
+	}
+	t.Cleanup(func() { readDirFn = orig })
+
+	return func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+		got := reads
+		reads = nil
+		sort.Strings(got)
+		return got
+	}
+}
+
+// incrementalWalk walks root with the given cache settings and returns the
+// root-relative paths passed to the callback.
+func incrementalWalk(t *testing.T, root, cache string, mode IncrementalMode, strictness CacheStrictness) ([]string, Stats) {
+	t.Helper()
+	var mu sync.Mutex
+	var paths []string
+	stats, err := WalkLimitWithOptionsStats(context.Background(), root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, _ := filepath.Rel(root, path)
+		mu.Lock()
+		paths = append(paths, filepath.ToSlash(rel))
+		mu.Unlock()
+		return nil
+	}, WalkOptions{
+		NumWorkers:            2,
+		MtimeCache:            cache,
+		IncrementalMode:       mode,
+		IncrementalStrictness: strictness,
+	})
+	if err != nil {
+		t.Fatalf("Walk failed: %v", err)
+	}
+	sort.Strings(paths)
+	return paths, stats
+}
+
+func TestIncrementalWalk(t *testing.T) {
+	root := createIncrementalFixture(t)
+	cache := filepath.Join(t.TempDir(), "mtimes.cache")
+	reads := countReadDirs(t, root)
+
+	// The first run reads everything and writes the cache
+	paths, stats := incrementalWalk(t, root, cache, IncrementalReplay, CacheStrictCount)
+	if got, want := reads(), []string{".", "a", "b", "b/c"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("First run read %v, want %v", got, want)
+	}
+	want := []string{".", "a", "a/1.txt", "a/2.txt", "b", "b/3.txt", "b/c", "b/c/4.txt", "top.txt"}
+	if !reflect.DeepEqual(paths, want) {
+		t.Errorf("First run reported %v, want %v", paths, want)
+	}
+	if stats.SkippedUnchangedDirs != 0 || stats.FilesProcessed != 5 {
+		t.Errorf("Unexpected first run stats: %+v", stats)
+	}
+
+	// Adding a file changes only b/c's mtime and entry count
+	if err := os.WriteFile(filepath.Join(root, "b", "c", "5.txt"), []byte("5"), 0644); err != nil {
+		t.Fatalf("Failed to create file: %v", err)
+	}
+
+	t.Run("Replay", func(t *testing.T) {
+		paths, stats := incrementalWalk(t, root, cache, IncrementalReplay, CacheStrictCount)
+		if got, want := reads(), []string{"b/c"}; !reflect.DeepEqual(got, want) {
+			t.Errorf("Re-walk read %v, want %v", got, want)
+		}
+		want := []string{".", "a", "a/1.txt", "a/2.txt", "b", "b/3.txt", "b/c", "b/c/4.txt", "b/c/5.txt", "top.txt"}
+		if !reflect.DeepEqual(paths, want) {
+			t.Errorf("Re-walk reported %v, want %v", paths, want)
+		}
+		if stats.SkippedUnchangedDirs != 3 || stats.FilesProcessed != 6 || stats.BytesProcessed != 38 {
+			t.Errorf("Unexpected re-walk stats: %+v", stats)
+		}
+	})
+
+	t.Run("Prune", func(t *testing.T) {
+		paths, stats := incrementalWalk(t, root, cache, IncrementalPrune, CacheStrictCount)
+		if got := reads(); len(got) != 0 {
+			t.Errorf("Unchanged tree read %v", got)
+		}
+		want := []string{".", "a", "b", "b/c"}
+		if !reflect.DeepEqual(paths, want) {
+			t.Errorf("Pruned walk reported %v, want %v", paths, want)
+		}
+		if stats.SkippedUnchangedDirs != 4 || stats.FilesProcessed != 0 {
+			t.Errorf("Unexpected pruned walk stats: %+v", stats)
+		}
+	})
+
+	t.Run("Racy", func(t *testing.T) {
+		// Everything was modified moments before the previous run
+		incrementalWalk(t, root, cache, IncrementalPrune, CacheStrictRacy)
+		if got, want := reads(), []string{".", "a", "b", "b/c"}; !reflect.DeepEqual(got, want) {
+			t.Errorf("Racy walk read %v, want %v", got, want)
+		}
+	})
+
+	t.Run("Touched", func(t *testing.T) {
+		// Rewriting a file in place leaves its directory's mtime alone, so
+		// the file is replayed as the previous run saw it
+		file := filepath.Join(root, "a", "1.txt")
+		if err := os.WriteFile(file, []byte("rewritten in place"), 0644); err != nil {
+			t.Fatalf("Failed to rewrite file: %v", err)
+		}
+		later := time.Now().Add(time.Hour)
+		if err := os.Chtimes(file, later, later); err != nil {
+			t.Fatalf("Failed to touch file: %v", err)
+		}
+		paths, stats := incrementalWalk(t, root, cache, IncrementalReplay, CacheStrictCount)
+		if got := reads(); len(got) != 0 {
+			t.Errorf("Walk after rewriting a file read %v", got)
+		}
+		want := []string{".", "a", "a/1.txt", "a/2.txt", "b", "b/3.txt", "b/c", "b/c/4.txt", "b/c/5.txt", "top.txt"}
+		if !reflect.DeepEqual(paths, want) {
+			t.Errorf("Walk after rewriting a file reported %v, want %v", paths, want)
+		}
+		if stats.FilesProcessed != 6 || stats.BytesProcessed != 38 {
+			t.Errorf("Expected the previous size to be replayed, got %+v", stats)
+		}
+
+		// Touching the directory as well has it read again, with the new size
+		if err := os.Chtimes(filepath.Dir(file), later, later); err != nil {
+			t.Fatalf("Failed to touch directory: %v", err)
+		}
+		_, stats = incrementalWalk(t, root, cache, IncrementalReplay, CacheStrictCount)
+		if got, want := reads(), []string{"a"}; !reflect.DeepEqual(got, want) {
+			t.Errorf("Walk after touching the directory read %v, want %v", got, want)
+		}
+		if stats.FilesProcessed != 6 || stats.BytesProcessed != 49 {
+			t.Errorf("Expected the new size to be read, got %+v", stats)
+		}
+	})
+}
+
+func TestIncrementalStrictness(t *testing.T) {
+	root := createIncrementalFixture(t)
+	cache := filepath.Join(t.TempDir(), "mtimes.cache")
+	reads := countReadDirs(t, root)
+	incrementalWalk(t, root, cache, IncrementalPrune, CacheStrictCount)
+	reads()
+
+	// Add a file but restore a's mtime, as a change within the same
+	// timestamp granularity would leave it
+	dir := filepath.Join(root, "a")
+	info, err := os.Stat(dir)
+	if err != nil {
+		t.Fatalf("Failed to stat directory: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "3.txt"), []byte("3"), 0644); err != nil {
+		t.Fatalf("Failed to create file: %v", err)
+	}
+	if err := os.Chtimes(dir, time.Now(), info.ModTime()); err != nil {
+		t.Fatalf("Failed to restore mtime: %v", err)
+	}
+
+	// Comparing mtimes alone misses the change; the entry count catches it
+	incrementalWalk(t, root, cache, IncrementalPrune, CacheStrictMtime)
+	if got := reads(); len(got) != 0 {
+		t.Errorf("Mtime-only walk read %v", got)
+	}
+	incrementalWalk(t, root, cache, IncrementalPrune, CacheStrictCount)
+	if got, want := reads(), []string{"a"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Counting walk read %v, want %v", got, want)
+	}
+}
+
+func TestIncrementalInvalidCache(t *testing.T) {
+	root := createIncrementalFixture(t)
+	cache := filepath.Join(t.TempDir(), "mtimes.cache")
+	reads := countReadDirs(t, root)
+
+	// An unrecognized cache is ignored and replaced
+	if err := os.WriteFile(cache, []byte("not a cache"), 0644); err != nil {
+		t.Fatalf("Failed to write cache: %v", err)
+	}
+	incrementalWalk(t, root, cache, IncrementalPrune, CacheStrictCount)
+	if got := reads(); len(got) != 4 {
+		t.Errorf("Expected a full walk, read %v", got)
+	}
+	if loadMtimeCache(cache, root) == nil {
+		t.Error("Expected the cache to be rewritten")
+	}
+
+	// A cache written for another root is ignored
+	other := createIncrementalFixture(t)
+	incrementalWalk(t, other, cache, IncrementalPrune, CacheStrictCount)
+	reads()
+	incrementalWalk(t, root, cache, IncrementalPrune, CacheStrictCount)
+	if got := reads(); len(got) != 4 {
+		t.Errorf("Expected a full walk, read %v", got)
+	}
+}
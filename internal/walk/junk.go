@@ -0,0 +1,55 @@
+package stride
+
+import (
+	"path/filepath"
+)
+
+// junkNames are the exact base names of junk files: vim's write test file,
+// and the folder metadata of macOS Finder and Windows Explorer.
+var junkNames = []string{
+	"4913",
+	".DS_Store",
+	"Thumbs.db",
+}
+
+// junkNameSet holds junkNames for lookups.
+var junkNameSet = func() map[string]bool {
+	set := make(map[string]bool, len(junkNames))
+	for _, name := range junkNames {
+		set[name] = true
+	}
+	return set
+}()
+
+// junkGlobs match the base names of the other junk files: vim swap files,
+// backups ending in ~, and the lock and autosave files of Emacs.
+var junkGlobs = []string{
+	"*.swp",
+	"*.swo",
+	"*~",
+	".#*",
+	"#*#",
+}
+
+// CommonJunkPatterns returns the base-name patterns of the files that
+// SkipJunkFiles leaves out: editor swap, backup and lock files, and folder
+// metadata written by file managers. Exact names come first, then globs.
+func CommonJunkPatterns() []string {
+	patterns := make([]string, 0, len(junkNames)+len(junkGlobs))
+	patterns = append(patterns, junkNames...)
+	return append(patterns, junkGlobs...)
+}
+
+// isJunk reports whether a file's base name matches CommonJunkPatterns.
+// Exact names are looked up; only the few globs are matched.
+func isJunk(name string) bool {
+	if junkNameSet[name] {
+		return true
+	}
+	for _, glob := range junkGlobs {
+		if matched, _ := filepath.Match(glob, name); matched {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,166 @@
+package stride
+
+import (
+	"context"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/TFMV/stride/walk/walktest"
+)
+
+// junkFixture holds one file of each junk type and the files kept beside them.
+var junkFixture = struct {
+	junk []string
+	kept []string
+}{
+	junk: []string{".DS_Store", "Thumbs.db", "4913", "main.go.swp", "main.go.swo", "main.go~", ".#main.go", "#main.go#"},
+	kept: []string{"main.go", "notes.txt", "swp", "a~b", "#main.go"},
+}
+
+func TestIsJunk(t *testing.T) {
+	for _, name := range junkFixture.junk {
+		if !isJunk(name) {
+			t.Errorf("Expected %q to be junk", name)
+		}
+	}
+	for _, name := range junkFixture.kept {
+		if isJunk(name) {
+			t.Errorf("Expected %q not to be junk", name)
+		}
+	}
+	if got := CommonJunkPatterns(); len(got) != len(junkNames)+len(junkGlobs) || got[0] != junkNames[0] {
+		t.Errorf("Expected the exact names then the globs, got %q", got)
+	}
+}
+
+func TestSkipJunkFiles(t *testing.T) {
+	tree := walktest.Tree{"sub/": walktest.Dir{}}
+	for _, name := range append(append([]string{}, junkFixture.junk...), junkFixture.kept...) {
+		tree[name] = walktest.File{Content: "x"}
+		tree["sub/"+name] = walktest.File{Content: "x"}
+	}
+	root := tree.Build(t)
+
+	expected := func(skip bool) []string {
+		names := append([]string{}, junkFixture.kept...)
+		if !skip {
+			names = append(names, junkFixture.junk...)
+		}
+		var want []string
+		for _, name := range names {
+			want = append(want, name, "sub/"+name)
+		}
+		sort.Strings(want)
+		return want
+	}
+
+	for _, skip := range []bool{true, false} {
+		var mu sync.Mutex
+		var seen []string
+		record := func(path string, isDir bool) {
+			if isDir {
+				return
+			}
+			mu.Lock()
+			seen = append(seen, relSlashPath(root, path))
+			mu.Unlock()
+		}
+		check := func(what string) {
+			t.Helper()
+			sort.Strings(seen)
+			if want := expected(skip); !reflect.DeepEqual(seen, want) {
+				t.Errorf("%s with SkipJunkFiles %v: expected %q, got %q", what, skip, want, seen)
+			}
+			seen = nil
+		}
+
+		err := WalkLimitWithOptions(context.Background(), root, func(path string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			record(path, info.IsDir())
+			return nil
+		}, WalkOptions{NumWorkers: 2, Filter: FilterOptions{SkipJunkFiles: skip}})
+		if err != nil {
+			t.Fatalf("Walk failed: %v", err)
+		}
+		check("Walk")
+
+		err = WalkDir(root, func(ctx context.Context, path string, d fs.DirEntry) error {
+			record(path, d.IsDir())
+			return nil
+		}, WalkOptions{NumWorkers: 2, Filter: FilterOptions{SkipJunkFiles: skip}})
+		if err != nil {
+			t.Fatalf("WalkDir failed: %v", err)
+		}
+		check("WalkDir")
+
+		err = Find(context.Background(), root, FindOptions{MaxDepth: 2, IncludeHidden: true, SkipJunkFiles: skip}, func(ctx context.Context, result FindResult) error {
+			if result.Error != nil {
+				return result.Error
+			}
+			record(result.Message.Path, result.Message.IsDir)
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("Find failed: %v", err)
+		}
+		check("Find")
+	}
+}
+
+func TestWatchSkipJunkFiles(t *testing.T) {
+	for _, skip := range []bool{true, false} {
+		tmpDir := t.TempDir()
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+
+		var mu sync.Mutex
+		received := make(map[string]bool)
+		watchDone := make(chan struct{})
+		go func() {
+			defer close(watchDone)
+			opts := WatchOptions{Events: []WatchEvent{EventCreate}, IncludeHidden: true, SkipJunkFiles: skip}
+			Watch(ctx, tmpDir, opts, func(ctx context.Context, result WatchResult) error {
+				if result.Error == nil {
+					mu.Lock()
+					received[filepath.Base(result.Message.Path)] = true
+					mu.Unlock()
+				}
+				return nil
+			})
+		}()
+
+		// Give the watcher a moment to initialize
+		time.Sleep(200 * time.Millisecond)
+
+		for _, name := range append(append([]string{}, junkFixture.junk...), junkFixture.kept...) {
+			if err := os.WriteFile(filepath.Join(tmpDir, name), []byte("x"), 0644); err != nil {
+				t.Fatalf("Failed to create file: %v", err)
+			}
+		}
+		time.Sleep(500 * time.Millisecond)
+		cancel()
+		<-watchDone
+
+		mu.Lock()
+		for _, name := range junkFixture.kept {
+			if !received[name] {
+				t.Errorf("SkipJunkFiles %v: expected an event for %s", skip, name)
+			}
+		}
+		for _, name := range junkFixture.junk {
+			if skip && received[name] {
+				t.Errorf("Expected no event for %s with SkipJunkFiles", name)
+			} else if !skip && !received[name] {
+				t.Errorf("Expected an event for %s without SkipJunkFiles", name)
+			}
+		}
+		mu.Unlock()
+	}
+}
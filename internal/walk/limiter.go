@@ -0,0 +1,163 @@
+package stride
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+)
+
+// Limiter bounds the number of walks enumerating the filesystem at once,
+// across all the walks that share it, so that many concurrent walks in one
+// process do not overwhelm the filesystem. A walk holds one token while it
+// reads directories and stats entries, and gives it up whenever it waits on
+// others: while a callback runs, and while its workers are too busy to take
+// more files. A callback that starts another walk therefore never holds a
+// token the nested walk needs. Each walk still runs at most its own
+// NumWorkers callbacks at once.
+//
+// A nil *Limiter imposes no limit. A Limiter is safe for concurrent use.
+type Limiter struct {
+	tokens chan struct{}
+}
+
+// NewLimiter returns a Limiter allowing maxConcurrentOps operations at once,
+// or nil, imposing no limit, if maxConcurrentOps is not positive.
+func NewLimiter(maxConcurrentOps int) *Limiter {
+	if maxConcurrentOps <= 0 {
+		return nil
+	}
+	return &Limiter{tokens: make(chan struct{}, maxConcurrentOps)}
+}
+
+// Acquire takes a token, waiting until one is free or ctx is done, in which
+// case it returns the context's error.
+func (l *Limiter) Acquire(ctx context.Context) error {
+	if l == nil {
+		return nil
+	}
+	select {
+	case l.tokens <- struct{}{}:
+		return nil
+	default:
+	}
+	select {
+	case l.tokens <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Release returns a token taken by Acquire.
+func (l *Limiter) Release() {
+	if l != nil {
+		<-l.tokens
+	}
+}
+
+// InFlight returns the number of tokens taken.
+func (l *Limiter) InFlight() int {
+	if l == nil {
+		return 0
+	}
+	return len(l.tokens)
+}
+
+// globalLimiter is the Limiter of walks without WalkOptions.SharedLimiter.
+var globalLimiter atomic.Pointer[Limiter]
+
+// SetGlobalLimiter bounds the operations in flight across all walks in the
+// process that do not set WalkOptions.SharedLimiter, as a Limiter does. A
+// maxConcurrentOps of 0 removes the limit. Walks already running keep the
+// limiter they started with.
+func SetGlobalLimiter(maxConcurrentOps int) {
+	globalLimiter.Store(NewLimiter(maxConcurrentOps))
+}
+
+// limiter returns the Limiter a walk with these options shares.
+func (o WalkOptions) limiter() *Limiter {
+	if o.SharedLimiter != nil {
+		return o.SharedLimiter
+	}
+	return globalLimiter.Load()
+}
+
+// enumGate holds a Limiter token for the goroutine enumerating a walk,
+// from newEnumGate until done, except while paused. A nil *enumGate does
+// nothing. It is not safe for concurrent use.
+type enumGate struct {
+	ctx  context.Context
+	l    *Limiter
+	held bool
+}
+
+// newEnumGate waits for a token of l, or returns nil if l is nil. If ctx is
+// done first, the gate holds no token and the walk is left to notice.
+func newEnumGate(ctx context.Context, l *Limiter) *enumGate {
+	if l == nil {
+		return nil
+	}
+	g := &enumGate{ctx: ctx, l: l}
+	g.acquire()
+	return g
+}
+
+func (g *enumGate) acquire() {
+	if g != nil && !g.held {
+		g.held = g.l.Acquire(g.ctx) == nil
+	}
+}
+
+func (g *enumGate) release() {
+	if g != nil && g.held {
+		g.l.Release()
+		g.held = false
+	}
+}
+
+// pause runs fn without holding the token.
+func (g *enumGate) pause(fn func()) {
+	g.release()
+	defer g.acquire()
+	fn()
+}
+
+// done gives up the token for good.
+func (g *enumGate) done() {
+	g.release()
+}
+
+// send hands task to the workers through tasks, without the token while
+// they are too busy to take it. It reports false if ctx is done first.
+func (g *enumGate) send(ctx context.Context, tasks chan<- walkArgs, task walkArgs) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+	select {
+	case tasks <- task:
+		return true
+	default:
+	}
+	sent := false
+	g.pause(func() {
+		select {
+		case <-ctx.Done():
+		case tasks <- task:
+			sent = true
+		}
+	})
+	return sent
+}
+
+// pauseWalkFn returns walkFn called without the token of g, for the calls
+// the enumerating goroutine makes itself.
+func (g *enumGate) pauseWalkFn(walkFn filepath.WalkFunc) filepath.WalkFunc {
+	if g == nil {
+		return walkFn
+	}
+	return func(path string, info os.FileInfo, err error) (ret error) {
+		g.pause(func() { ret = walkFn(path, info, err) })
+		return ret
+	}
+}
@@ -0,0 +1,233 @@
+package stride
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"os"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/TFMV/stride/walk/walktest"
+)
+
+// peakStats makes entryInfo count the stats in flight, slowed down so that
+// walks overlap, and returns the highest count seen.
+func peakStats(t *testing.T) *int64 {
+	var inFlight, peak int64
+	orig := entryInfo
+	entryInfo = func(path string, d fs.DirEntry) (fs.FileInfo, error) {
+		n := atomic.AddInt64(&inFlight, 1)
+		defer atomic.AddInt64(&inFlight, -1)
+		for {
+			p := atomic.LoadInt64(&peak)
+			if n <= p || atomic.CompareAndSwapInt64(&peak, p, n) {
+				break
+			}
+		}
+		time.Sleep(200 * time.Microsecond)
+		return orig(path, d)
+	}
+	t.Cleanup(func() { entryInfo = orig })
+	return &peak
+}
+
+// limiterTree builds a small tree of 3 directories holding 12 files.
+func limiterTree(t *testing.T) string {
+	tree := walktest.Tree{}
+	for _, dir := range []string{"a", "b", "c"} {
+		for _, name := range []string{"1", "2", "3", "4"} {
+			tree[dir+"/"+name+".txt"] = walktest.File{Content: name}
+		}
+	}
+	return tree.Build(t)
+}
+
+// waitOrFail fails the test if wg is not done within a generous timeout,
+// as when walks deadlock.
+func waitOrFail(t *testing.T, wg *sync.WaitGroup) {
+	t.Helper()
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(30 * time.Second):
+		t.Fatal("Walks did not complete")
+	}
+}
+
+func TestSharedLimiterStress(t *testing.T) {
+	root := limiterTree(t)
+	peak := peakStats(t)
+	limiter := NewLimiter(8)
+
+	const walks = 50
+	var wg sync.WaitGroup
+	var files int64
+	errs := make([]error, walks)
+	for i := 0; i < walks; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			errs[i] = WalkLimitWithOptions(context.Background(), root, func(path string, info os.FileInfo, err error) error {
+				if err == nil && !info.IsDir() {
+					atomic.AddInt64(&files, 1)
+				}
+				return err
+			}, WalkOptions{NumWorkers: 4, SharedLimiter: limiter})
+		}(i)
+	}
+	waitOrFail(t, &wg)
+
+	for i, err := range errs {
+		if err != nil {
+			t.Errorf("Walk %d failed: %v", i, err)
+		}
+	}
+	if files != walks*12 {
+		t.Errorf("Expected %d files, got %d", walks*12, files)
+	}
+	if *peak > 8 {
+		t.Errorf("Expected at most 8 stats in flight, got %d", *peak)
+	}
+	if *peak < 2 {
+		t.Errorf("Expected walks to run concurrently, got at most %d stats in flight", *peak)
+	}
+	if n := limiter.InFlight(); n != 0 {
+		t.Errorf("Expected every token returned, %d are taken", n)
+	}
+}
+
+func TestSharedLimiterNested(t *testing.T) {
+	root := limiterTree(t)
+	limiter := NewLimiter(1)
+	opts := WalkOptions{NumWorkers: 4, SharedLimiter: limiter}
+
+	// Directory callbacks, run by the enumeration itself, and file
+	// callbacks, run by workers, each start a nested walk
+	var nested int64
+	walkNested := func() error {
+		atomic.AddInt64(&nested, 1)
+		return WalkLimitWithOptions(context.Background(), root, func(path string, info os.FileInfo, err error) error {
+			return err
+		}, opts)
+	}
+	var wg sync.WaitGroup
+	var err error
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		err = WalkLimitWithOptions(context.Background(), root, func(path string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			return walkNested()
+		}, opts)
+	}()
+	waitOrFail(t, &wg)
+
+	if err != nil {
+		t.Fatalf("Walk failed: %v", err)
+	}
+	if nested != 16 {
+		t.Errorf("Expected 16 nested walks, got %d", nested)
+	}
+
+	// WalkDir and incremental walks let go of the token in the same way
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		WalkDir(root, func(ctx context.Context, path string, d fs.DirEntry) error {
+			return walkNested()
+		}, opts)
+	}()
+	go func() {
+		defer wg.Done()
+		incremental := opts
+		incremental.MtimeCache = t.TempDir() + "/cache"
+		WalkLimitWithOptions(context.Background(), root, func(path string, info os.FileInfo, err error) error {
+			return walkNested()
+		}, incremental)
+	}()
+	waitOrFail(t, &wg)
+}
+
+func TestSetGlobalLimiter(t *testing.T) {
+	root := limiterTree(t)
+	peak := peakStats(t)
+	SetGlobalLimiter(2)
+	t.Cleanup(func() { SetGlobalLimiter(0) })
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := Walk(root, func(path string, info os.FileInfo, err error) error { return err }); err != nil {
+				t.Errorf("Walk failed: %v", err)
+			}
+		}()
+	}
+	waitOrFail(t, &wg)
+	if *peak > 2 {
+		t.Errorf("Expected at most 2 stats in flight, got %d", *peak)
+	}
+
+	// A shared limiter takes the place of the global one
+	own := NewLimiter(1)
+	if err := WalkLimitWithOptions(context.Background(), root, func(path string, info os.FileInfo, err error) error {
+		if n := own.InFlight(); err == nil && info.IsDir() && n != 0 {
+			t.Errorf("Expected the token given up during callbacks, %d taken", n)
+		}
+		return err
+	}, WalkOptions{SharedLimiter: own}); err != nil {
+		t.Fatalf("Walk failed: %v", err)
+	}
+}
+
+func TestLimiterAcquire(t *testing.T) {
+	if l := NewLimiter(0); l != nil {
+		t.Errorf("Expected no limiter for 0, got %v", l)
+	}
+	var none *Limiter
+	if err := none.Acquire(context.Background()); err != nil {
+		t.Errorf("Expected a nil limiter to never wait, got %v", err)
+	}
+	none.Release()
+
+	l := NewLimiter(1)
+	if err := l.Acquire(context.Background()); err != nil {
+		t.Fatalf("Acquire failed: %v", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := l.Acquire(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected the deadline to end the wait, got %v", err)
+	}
+	if n := l.InFlight(); n != 1 {
+		t.Errorf("Expected 1 token taken, got %d", n)
+	}
+	l.Release()
+	if n := l.InFlight(); n != 0 {
+		t.Errorf("Expected no tokens taken, got %d", n)
+	}
+
+	// A walk whose context is done while waiting still returns
+	if err := l.Acquire(context.Background()); err != nil {
+		t.Fatalf("Acquire failed: %v", err)
+	}
+	canceled, cancelWalk := context.WithCancel(context.Background())
+	cancelWalk()
+	err := WalkLimitWithOptions(canceled, limiterTree(t), func(path string, info os.FileInfo, err error) error {
+		return err
+	}, WalkOptions{SharedLimiter: l})
+	if err == nil {
+		t.Error("Expected the canceled walk to fail")
+	}
+	l.Release()
+}
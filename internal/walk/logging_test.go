@@ -0,0 +1,96 @@
+package stride
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+
+	"go.uber.org/zap"
+)
+
+// cancelAfter returns a callback canceling the walk at the nth entry.
+func cancelAfter(n int64, cancel context.CancelFunc) filepath.WalkFunc {
+	var seen int64
+	return func(path string, info os.FileInfo, err error) error {
+		if atomic.AddInt64(&seen, 1) == n {
+			cancel()
+		}
+		return nil
+	}
+}
+
+// TestNoLogOutputByDefault walks, cancels walks and walks nested roots in a
+// child process without configuring a logger, which must leave stderr
+// empty. The shared loggers hold on to the stderr of the process they were
+// built in, hence the child.
+func TestNoLogOutputByDefault(t *testing.T) {
+	if os.Getenv("STRIDE_TEST_LOGGING_CHILD") == "1" {
+		root := os.Getenv("STRIDE_TEST_LOGGING_ROOT")
+		ctx, cancel := context.WithCancel(context.Background())
+		WalkLimit(ctx, root, cancelAfter(5, cancel), 2)
+
+		ctx, cancel = context.WithCancel(context.Background())
+		WalkLimitWithOptions(ctx, root, cancelAfter(5, cancel), WalkOptions{NumWorkers: 2})
+
+		WalkRootsStats(context.Background(), []string{root, filepath.Join(root, "dir0")}, cancelAfter(0, nil), WalkOptions{})
+		return
+	}
+
+	root := createBudgetFixture(t, 50)
+	cmd := exec.Command(os.Args[0], "-test.run=^TestNoLogOutputByDefault$")
+	cmd.Env = append(os.Environ(), "STRIDE_TEST_LOGGING_CHILD=1", "STRIDE_TEST_LOGGING_ROOT="+root)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		t.Fatalf("Failed to run child: %v\n%s", err, stderr.String())
+	}
+	if stderr.Len() != 0 {
+		t.Errorf("Expected no output on stderr, got:\n%s", stderr.String())
+	}
+}
+
+func TestDefaultLoggerShared(t *testing.T) {
+	for _, level := range []LogLevel{LogLevelError, LogLevelWarn, LogLevelInfo, LogLevelDebug} {
+		if defaultLogger(level) != defaultLogger(level) {
+			t.Errorf("Expected one logger for level %d", level)
+		}
+	}
+	if defaultLogger(LogLevel(42)) != defaultLogger(LogLevelInfo) {
+		t.Error("Expected unknown levels to share the info logger")
+	}
+	if ce := defaultLogger(LogLevelError).Check(zap.DebugLevel, "hot path"); ce != nil {
+		t.Error("Expected debug entries to be dropped at the error level")
+	}
+
+	configured := zap.NewNop()
+	if got := (WalkOptions{Logger: configured}).logger(); got != configured {
+		t.Error("Expected the configured logger to be used")
+	}
+}
+
+// BenchmarkWalkLogging walks the large fixture with no logger configured,
+// which uses the shared logger, against a logger built and synced for
+// every walk as walks used to.
+func BenchmarkWalkLogging(b *testing.B) {
+	root := setupLargeTestDir(b)
+	walkFn := func(path string, info os.FileInfo, err error) error { return nil }
+
+	b.Run("unset", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			WalkLimitWithOptions(context.Background(), root, walkFn, WalkOptions{NumWorkers: 4})
+		}
+	})
+	b.Run("per-walk", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			logger := createLogger(LogLevelInfo)
+			WalkLimitWithOptions(context.Background(), root, walkFn, WalkOptions{NumWorkers: 4, Logger: logger})
+			logger.Sync()
+		}
+	})
+}
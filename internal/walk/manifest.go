@@ -0,0 +1,340 @@
+package stride
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// manifestHeader is the first line of every manifest.
+const manifestHeader = "#stride manifest v1"
+
+// ManifestType is the kind of entry recorded in a manifest.
+type ManifestType string
+
+const (
+	ManifestFile  ManifestType = "file"
+	ManifestDir   ManifestType = "dir"
+	ManifestLink  ManifestType = "link"
+	ManifestOther ManifestType = "other" // Devices, pipes and sockets
+)
+
+// ManifestOptions configures WriteManifest and VerifyManifest.
+type ManifestOptions struct {
+	IgnoreMtime bool // Do not compare modification times when verifying
+	Jobs        int  // Files hashed concurrently; defaults to the number of CPUs
+}
+
+// ManifestEntry is one line of a manifest. Directories record no size or
+// time, since both change whenever their contents do.
+type ManifestEntry struct {
+	Path    string       // Slash-separated path relative to the root
+	Type    ManifestType // Kind of entry
+	Mode    os.FileMode  // Permission bits
+	Size    int64        // Size in bytes (files only)
+	ModTime time.Time    // Modification time (files and links)
+	SHA256  string       // Hex-encoded content digest (files only)
+	Target  string       // Link target (links only)
+}
+
+// ManifestStatus classifies an entry when verifying a manifest.
+type ManifestStatus string
+
+const (
+	ManifestOK       ManifestStatus = "ok"       // The entry matches the manifest
+	ManifestModified ManifestStatus = "modified" // The entry differs from the manifest
+	ManifestMissing  ManifestStatus = "missing"  // The entry is in the manifest but not the tree
+	ManifestExtra    ManifestStatus = "extra"    // The entry is in the tree but not the manifest
+)
+
+// ManifestChange is an entry of the tree that does not match its manifest.
+type ManifestChange struct {
+	Path   string         // Slash-separated path relative to the root
+	Status ManifestStatus // Modified, missing or extra
+
+	// Reasons lists the fields of a modified entry that differ: type, size,
+	// sha256, mode, mtime or target. The content is only compared when the
+	// sizes match.
+	Reasons []string
+}
+
+// VerifyReport is the result of VerifyManifest.
+type VerifyReport struct {
+	OK      int              // Entries matching the manifest
+	Changes []ManifestChange // Entries that do not, sorted by path
+}
+
+// Clean reports whether the tree matches the manifest.
+func (r VerifyReport) Clean() bool {
+	return len(r.Changes) == 0
+}
+
+// WriteManifest walks root and writes a manifest of every entry beneath it
+// to w: one line per entry, sorted by path, so the output only depends on
+// the tree. Files are hashed on the walk's workers. Symbolic links are
+// recorded, not followed.
+func WriteManifest(ctx context.Context, root string, w io.Writer, opts ManifestOptions) error {
+	entries, err := scanManifest(ctx, root, opts, func(ManifestEntry) bool { return true })
+	if err != nil {
+		return err
+	}
+
+	bw := bufio.NewWriter(w)
+	fmt.Fprintln(bw, manifestHeader)
+	for _, e := range entries {
+		fmt.Fprintln(bw, e.String())
+	}
+	return bw.Flush()
+}
+
+// VerifyManifest reads a manifest written by WriteManifest from r, walks
+// root again and reports the entries that were modified, removed or added
+// since.
+func VerifyManifest(ctx context.Context, root string, r io.Reader, opts ManifestOptions) (VerifyReport, error) {
+	want, err := ReadManifest(r)
+	if err != nil {
+		return VerifyReport{}, err
+	}
+	wantByPath := make(map[string]ManifestEntry, len(want))
+	for _, e := range want {
+		wantByPath[e.Path] = e
+	}
+
+	// Only files whose size still matches need hashing
+	got, err := scanManifest(ctx, root, opts, func(e ManifestEntry) bool {
+		prev, ok := wantByPath[e.Path]
+		return ok && prev.Type == ManifestFile && prev.Size == e.Size
+	})
+	if err != nil {
+		return VerifyReport{}, err
+	}
+
+	var report VerifyReport
+	seen := make(map[string]bool, len(got))
+	for _, e := range got {
+		seen[e.Path] = true
+		prev, ok := wantByPath[e.Path]
+		if !ok {
+			report.Changes = append(report.Changes, ManifestChange{Path: e.Path, Status: ManifestExtra})
+			continue
+		}
+		if reasons := prev.diff(e, opts); len(reasons) > 0 {
+			report.Changes = append(report.Changes, ManifestChange{Path: e.Path, Status: ManifestModified, Reasons: reasons})
+			continue
+		}
+		report.OK++
+	}
+	for _, e := range want {
+		if !seen[e.Path] {
+			report.Changes = append(report.Changes, ManifestChange{Path: e.Path, Status: ManifestMissing})
+		}
+	}
+	sort.Slice(report.Changes, func(i, j int) bool {
+		return report.Changes[i].Path < report.Changes[j].Path
+	})
+	return report, nil
+}
+
+// scanManifest walks root and returns its entries sorted by path. Files for
+// which hash returns true are hashed.
+func scanManifest(ctx context.Context, root string, opts ManifestOptions, hash func(ManifestEntry) bool) ([]ManifestEntry, error) {
+	root, err := normalizeRoot(root)
+	if err != nil {
+		return nil, err
+	}
+
+	var mu sync.Mutex
+	var entries []ManifestEntry
+	err = WalkLimitWithOptions(ctx, root, func(path string, info os.FileInfo, err error) error {
+		if err != nil || info == nil {
+			return err
+		}
+		rel, err := filepath.Rel(root, path)
+		if err != nil || rel == "." {
+			return err
+		}
+
+		e := ManifestEntry{Path: filepath.ToSlash(rel), Mode: info.Mode().Perm()}
+		switch mode := info.Mode(); {
+		case mode.IsDir():
+			e.Type = ManifestDir
+		case mode.IsRegular():
+			e.Type = ManifestFile
+			e.Size = info.Size()
+			e.ModTime = info.ModTime()
+			if hash(e) {
+				if e.SHA256, err = hashFile(path); err != nil {
+					return fmt.Errorf("hashing %s: %w", path, err)
+				}
+			}
+		case mode&os.ModeSymlink != 0:
+			e.Type = ManifestLink
+			e.ModTime = info.ModTime()
+			if e.Target, err = os.Readlink(path); err != nil {
+				return err
+			}
+		default:
+			e.Type = ManifestOther
+			e.ModTime = info.ModTime()
+		}
+
+		mu.Lock()
+		entries = append(entries, e)
+		mu.Unlock()
+		return nil
+	}, WalkOptions{
+		ErrorHandling:   ErrorHandlingStop,
+		SymlinkHandling: SymlinkReport,
+		NumWorkers:      opts.Jobs,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Workers finish in any order
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Path < entries[j].Path
+	})
+	return entries, nil
+}
+
+// diff returns the fields of got that differ from e.
+func (e ManifestEntry) diff(got ManifestEntry, opts ManifestOptions) []string {
+	if e.Type != got.Type {
+		return []string{"type"}
+	}
+	var reasons []string
+	if e.Size != got.Size {
+		reasons = append(reasons, "size")
+	} else if e.SHA256 != got.SHA256 {
+		reasons = append(reasons, "sha256")
+	}
+	if e.Mode != got.Mode {
+		reasons = append(reasons, "mode")
+	}
+	if !opts.IgnoreMtime && !e.ModTime.Equal(got.ModTime) {
+		reasons = append(reasons, "mtime")
+	}
+	if e.Target != got.Target {
+		reasons = append(reasons, "target")
+	}
+	return reasons
+}
+
+// String formats e as a manifest line: the quoted path followed by
+// key=value fields.
+func (e ManifestEntry) String() string {
+	var b strings.Builder
+	b.WriteString(strconv.Quote(e.Path))
+	fmt.Fprintf(&b, " type=%s mode=%04o", e.Type, uint32(e.Mode))
+	if e.Type == ManifestFile {
+		fmt.Fprintf(&b, " size=%d", e.Size)
+	}
+	if e.Type != ManifestDir {
+		fmt.Fprintf(&b, " mtime=%d.%09d", e.ModTime.Unix(), e.ModTime.Nanosecond())
+	}
+	if e.Type == ManifestFile && e.SHA256 != "" {
+		fmt.Fprintf(&b, " sha256=%s", e.SHA256)
+	}
+	if e.Type == ManifestLink {
+		fmt.Fprintf(&b, " target=%s", strconv.Quote(e.Target))
+	}
+	return b.String()
+}
+
+// ReadManifest parses a manifest written by WriteManifest. Blank lines and
+// lines starting with '#' are ignored.
+func ReadManifest(r io.Reader) ([]ManifestEntry, error) {
+	var entries []ManifestEntry
+	scanner := bufio.NewScanner(r)
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		e, err := parseManifestLine(line)
+		if err != nil {
+			return nil, fmt.Errorf("manifest line %d: %w", lineNo, err)
+		}
+		entries = append(entries, e)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("reading manifest: %w", err)
+	}
+	return entries, nil
+}
+
+// parseManifestLine parses a line formatted by ManifestEntry.String.
+func parseManifestLine(line string) (ManifestEntry, error) {
+	var e ManifestEntry
+	quoted, err := strconv.QuotedPrefix(line)
+	if err != nil {
+		return e, fmt.Errorf("invalid path: %w", err)
+	}
+	e.Path, _ = strconv.Unquote(quoted)
+
+	rest := strings.TrimSpace(line[len(quoted):])
+	for rest != "" {
+		key, value, ok := strings.Cut(rest, "=")
+		if !ok {
+			return e, fmt.Errorf("invalid field %q", rest)
+		}
+		// Quoted values may contain spaces
+		if strings.HasPrefix(value, `"`) {
+			if value, err = strconv.QuotedPrefix(value); err != nil {
+				return e, fmt.Errorf("invalid %s: %w", key, err)
+			}
+			rest = strings.TrimSpace(rest[len(key)+1+len(value):])
+			value, _ = strconv.Unquote(value)
+		} else {
+			value, rest, _ = strings.Cut(value, " ")
+			rest = strings.TrimSpace(rest)
+		}
+
+		switch key {
+		case "type":
+			e.Type = ManifestType(value)
+		case "mode":
+			mode, err := strconv.ParseUint(value, 8, 32)
+			if err != nil {
+				return e, fmt.Errorf("invalid mode %q", value)
+			}
+			e.Mode = os.FileMode(mode)
+		case "size":
+			if e.Size, err = strconv.ParseInt(value, 10, 64); err != nil {
+				return e, fmt.Errorf("invalid size %q", value)
+			}
+		case "mtime":
+			sec, nsec, _ := strings.Cut(value, ".")
+			s, err1 := strconv.ParseInt(sec, 10, 64)
+			ns, err2 := strconv.ParseInt(nsec, 10, 64)
+			if err1 != nil || err2 != nil {
+				return e, fmt.Errorf("invalid mtime %q", value)
+			}
+			e.ModTime = time.Unix(s, ns)
+		case "sha256":
+			e.SHA256 = value
+		case "target":
+			e.Target = value
+		default:
+			return e, fmt.Errorf("unknown field %q", key)
+		}
+	}
+
+	switch e.Type {
+	case ManifestFile, ManifestDir, ManifestLink, ManifestOther:
+	default:
+		return e, fmt.Errorf("invalid type %q", e.Type)
+	}
+	return e, nil
+}
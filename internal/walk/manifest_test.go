@@ -0,0 +1,156 @@
+package stride
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// createManifestFixture creates a small tree with nested files and a link.
+func createManifestFixture(t *testing.T) string {
+	t.Helper()
+	root := t.TempDir()
+	files := map[string]string{
+		"a.txt":             "alpha",
+		"b.txt":             "bravo",
+		"dir/c.txt":         "charlie",
+		"dir/sub/d.txt":     "delta",
+		"dir/with space.md": "echo",
+	}
+	for name, content := range files {
+		path := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("Failed to create directory: %v", err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to create file: %v", err)
+		}
+	}
+	if err := os.Symlink("a.txt", filepath.Join(root, "link")); err != nil {
+		t.Fatalf("Failed to create symlink: %v", err)
+	}
+	return root
+}
+
+func TestWriteManifestDeterministic(t *testing.T) {
+	root := createManifestFixture(t)
+
+	var first bytes.Buffer
+	if err := WriteManifest(context.Background(), root, &first, ManifestOptions{Jobs: 1}); err != nil {
+		t.Fatalf("WriteManifest failed: %v", err)
+	}
+	for _, jobs := range []int{2, 8} {
+		var out bytes.Buffer
+		if err := WriteManifest(context.Background(), root, &out, ManifestOptions{Jobs: jobs}); err != nil {
+			t.Fatalf("WriteManifest failed: %v", err)
+		}
+		if out.String() != first.String() {
+			t.Errorf("Manifest with %d jobs differs:\n%s\nwant:\n%s", jobs, out.String(), first.String())
+		}
+	}
+
+	entries, err := ReadManifest(&first)
+	if err != nil {
+		t.Fatalf("ReadManifest failed: %v", err)
+	}
+	var paths []string
+	for _, e := range entries {
+		paths = append(paths, e.Path)
+	}
+	want := []string{"a.txt", "b.txt", "dir", "dir/c.txt", "dir/sub", "dir/sub/d.txt", "dir/with space.md", "link"}
+	if !reflect.DeepEqual(paths, want) {
+		t.Errorf("Expected paths %v, got %v", want, paths)
+	}
+	for _, e := range entries {
+		switch e.Path {
+		case "a.txt":
+			if e.Type != ManifestFile || e.Size != 5 || len(e.SHA256) != 64 || e.Mode != 0644 {
+				t.Errorf("Unexpected file entry: %+v", e)
+			}
+		case "link":
+			if e.Type != ManifestLink || e.Target != "a.txt" {
+				t.Errorf("Unexpected link entry: %+v", e)
+			}
+		}
+	}
+}
+
+func TestVerifyManifest(t *testing.T) {
+	root := createManifestFixture(t)
+	ctx := context.Background()
+
+	var manifest bytes.Buffer
+	if err := WriteManifest(ctx, root, &manifest, ManifestOptions{}); err != nil {
+		t.Fatalf("WriteManifest failed: %v", err)
+	}
+	saved := manifest.String()
+
+	report, err := VerifyManifest(ctx, root, strings.NewReader(saved), ManifestOptions{})
+	if err != nil {
+		t.Fatalf("VerifyManifest failed: %v", err)
+	}
+	if !report.Clean() || report.OK != 8 {
+		t.Errorf("Expected an unchanged tree to verify, got %+v", report)
+	}
+
+	// Modify one file in place and delete another
+	if err := os.WriteFile(filepath.Join(root, "dir", "c.txt"), []byte("CHARLIE"), 0644); err != nil {
+		t.Fatalf("Failed to modify file: %v", err)
+	}
+	if err := os.Remove(filepath.Join(root, "b.txt")); err != nil {
+		t.Fatalf("Failed to remove file: %v", err)
+	}
+
+	report, err = VerifyManifest(ctx, root, strings.NewReader(saved), ManifestOptions{IgnoreMtime: true})
+	if err != nil {
+		t.Fatalf("VerifyManifest failed: %v", err)
+	}
+	want := []ManifestChange{
+		{Path: "b.txt", Status: ManifestMissing},
+		{Path: "dir/c.txt", Status: ManifestModified, Reasons: []string{"sha256"}},
+	}
+	if !reflect.DeepEqual(report.Changes, want) {
+		t.Errorf("Expected changes %+v, got %+v", want, report.Changes)
+	}
+	if report.OK != 6 {
+		t.Errorf("Expected 6 unchanged entries, got %d", report.OK)
+	}
+
+	// Size, mode and additions are reported too
+	if err := os.WriteFile(filepath.Join(root, "a.txt"), []byte("longer alpha"), 0600); err != nil {
+		t.Fatalf("Failed to modify file: %v", err)
+	}
+	if err := os.Chmod(filepath.Join(root, "a.txt"), 0600); err != nil {
+		t.Fatalf("Failed to chmod file: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(root, "new.txt"), []byte("new"), 0644); err != nil {
+		t.Fatalf("Failed to create file: %v", err)
+	}
+	report, err = VerifyManifest(ctx, root, strings.NewReader(saved), ManifestOptions{IgnoreMtime: true})
+	if err != nil {
+		t.Fatalf("VerifyManifest failed: %v", err)
+	}
+	want = append([]ManifestChange{{Path: "a.txt", Status: ManifestModified, Reasons: []string{"size", "mode"}}}, want...)
+	want = append(want, ManifestChange{Path: "new.txt", Status: ManifestExtra})
+	if !reflect.DeepEqual(report.Changes, want) {
+		t.Errorf("Expected changes %+v, got %+v", want, report.Changes)
+	}
+}
+
+func TestReadManifestErrors(t *testing.T) {
+	for _, manifest := range []string{
+		`a.txt type=file`,
+		`"a.txt" type=bogus`,
+		`"a.txt" type=file size=x`,
+		`"a.txt" type=file colour=red`,
+		`"link" type=link target="unterminated`,
+	} {
+		if _, err := ReadManifest(strings.NewReader(manifest)); err == nil {
+			t.Errorf("ReadManifest(%q) succeeded, want an error", manifest)
+		}
+	}
+}
@@ -0,0 +1,105 @@
+package stride
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+)
+
+// mapResult is a value computed by Map and the path it was computed for.
+type mapResult[T any] struct {
+	path  string
+	value T
+}
+
+// Map walks root like WalkWithOptions, calling fn on the worker pool for
+// every file passing opts.Filter, and returns the values fn computed without
+// error. Directories are walked but not passed to fn. The values are in no
+// particular order unless opts.SortResults is set, in which case they are
+// ordered by path.
+//
+// Errors of fn follow the error handling mode of opts: under
+// ErrorHandlingStop the first one stops the walk and is returned, otherwise
+// the walk goes on and they are returned together once it ends. Either way
+// the values computed so far are returned too.
+func Map[T any](ctx context.Context, root string, fn func(ctx context.Context, path string, info os.FileInfo) (T, error), opts WalkOptions) ([]T, error) {
+	var mu sync.Mutex
+	var results []mapResult[T]
+	err := mapFiles(ctx, root, fn, opts, func(path string, value T) {
+		mu.Lock()
+		results = append(results, mapResult[T]{path: path, value: value})
+		mu.Unlock()
+	})
+
+	if opts.SortResults {
+		sort.Slice(results, func(i, j int) bool { return results[i].path < results[j].path })
+	}
+	values := make([]T, len(results))
+	for i, r := range results {
+		values[i] = r.value
+	}
+	return values, err
+}
+
+// Reduce is like Map, but combines the values with merge as they are
+// computed instead of collecting them, and returns the combination, or the
+// zero value if there were none. merge is never called concurrently. Values
+// are merged in the order their callbacks finish, so merge must be
+// associative, and the result is only deterministic if it is also
+// commutative, like a sum, a maximum or a set union.
+func Reduce[T any](ctx context.Context, root string, fn func(ctx context.Context, path string, info os.FileInfo) (T, error), merge func(a, b T) T, opts WalkOptions) (T, error) {
+	var mu sync.Mutex
+	var acc T
+	have := false
+	err := mapFiles(ctx, root, fn, opts, func(path string, value T) {
+		mu.Lock()
+		defer mu.Unlock()
+		if have {
+			acc = merge(acc, value)
+		} else {
+			acc, have = value, true
+		}
+	})
+	return acc, err
+}
+
+// mapFiles implements Map and Reduce, passing each value fn computes to
+// emit, which may be called from several goroutines.
+func mapFiles[T any](ctx context.Context, root string, fn func(ctx context.Context, path string, info os.FileInfo) (T, error), opts WalkOptions, emit func(path string, value T)) error {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	opts.Context = ctx
+
+	// The first error stops the walk under ErrorHandlingStop
+	stop := opts.errorHandling() == ErrorHandlingStop
+	var stopErr error
+	var stopOnce sync.Once
+
+	err := WalkWithOptions(root, func(ctx context.Context, path string, info os.FileInfo) error {
+		if info.IsDir() {
+			return nil
+		}
+		value, err := fn(ctx, path, info)
+		if err != nil {
+			if stop {
+				stopOnce.Do(func() {
+					stopErr = fmt.Errorf("path %q: %w", path, err)
+					cancel()
+				})
+			}
+			return err
+		}
+		emit(path, value)
+		return nil
+	}, opts)
+
+	if stopErr != nil {
+		return stopErr
+	}
+	return err
+}
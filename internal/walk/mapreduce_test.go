@@ -0,0 +1,117 @@
+package stride
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/TFMV/stride/walk/walktest"
+)
+
+// mapFixture builds a tree of files of different sizes.
+func mapFixture(t *testing.T) string {
+	return walktest.Tree{
+		"a.txt":         walktest.File{Size: 10},
+		"b.txt":         walktest.File{Size: 20},
+		"dir/c.txt":     walktest.File{Size: 30},
+		"dir/d.txt":     walktest.File{Size: 10},
+		"dir/sub/e.txt": walktest.File{Size: 50},
+		"empty/":        walktest.Dir{},
+	}.Build(t)
+}
+
+func fileSize(ctx context.Context, path string, info os.FileInfo) (int64, error) {
+	return info.Size(), nil
+}
+
+func TestMap(t *testing.T) {
+	root := mapFixture(t)
+
+	// The sizes a sequential walk sees
+	var want []int64
+	filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err == nil && !info.IsDir() {
+			want = append(want, info.Size())
+		}
+		return nil
+	})
+	sort.Slice(want, func(i, j int) bool { return want[i] < want[j] })
+
+	got, err := Map(context.Background(), root, fileSize, WalkOptions{NumWorkers: 4})
+	if err != nil {
+		t.Fatalf("Map failed: %v", err)
+	}
+	sort.Slice(got, func(i, j int) bool { return got[i] < got[j] })
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected sizes %v, got %v", want, got)
+	}
+
+	// Sorted results follow the paths
+	paths, err := Map(context.Background(), root, func(ctx context.Context, path string, info os.FileInfo) (string, error) {
+		return relSlashPath(root, path), nil
+	}, WalkOptions{NumWorkers: 4, SortResults: true})
+	if err != nil {
+		t.Fatalf("Map failed: %v", err)
+	}
+	if want := []string{"a.txt", "b.txt", "dir/c.txt", "dir/d.txt", "dir/sub/e.txt"}; !reflect.DeepEqual(paths, want) {
+		t.Errorf("Expected paths %q, got %q", want, paths)
+	}
+}
+
+func TestReduce(t *testing.T) {
+	root := mapFixture(t)
+	stats, err := WalkLimitWithOptionsStats(context.Background(), root, func(path string, info os.FileInfo, err error) error {
+		return err
+	}, WalkOptions{NumWorkers: 4})
+	if err != nil {
+		t.Fatalf("Walk failed: %v", err)
+	}
+
+	sum := func(a, b int64) int64 { return a + b }
+	total, err := Reduce(context.Background(), root, fileSize, sum, WalkOptions{NumWorkers: 4})
+	if err != nil {
+		t.Fatalf("Reduce failed: %v", err)
+	}
+	if total != stats.BytesProcessed || total != 120 {
+		t.Errorf("Expected a total of %d bytes, got %d", stats.BytesProcessed, total)
+	}
+
+	// Nothing to merge gives the zero value
+	empty := walktest.Tree{"dir/": walktest.Dir{}}.Build(t)
+	if total, err := Reduce(context.Background(), empty, fileSize, sum, WalkOptions{}); err != nil || total != 0 {
+		t.Errorf("Expected 0 for an empty tree, got %d (%v)", total, err)
+	}
+}
+
+func TestMapErrorHandling(t *testing.T) {
+	root := mapFixture(t)
+	errBad := errors.New("bad file")
+	fn := func(ctx context.Context, path string, info os.FileInfo) (int64, error) {
+		if filepath.Base(path) == "c.txt" {
+			return 0, errBad
+		}
+		return info.Size(), nil
+	}
+
+	// Other files are still mapped
+	for _, mode := range []ErrorHandlingMode{ContinueOnError, SkipOnError} {
+		got, err := Map(context.Background(), root, fn, WalkOptions{NumWorkers: 4, ErrorHandlingMode: mode})
+		if !errors.Is(err, errBad) {
+			t.Errorf("%s: expected the error to be returned, got %v", mode, err)
+		}
+		if len(got) != 4 {
+			t.Errorf("%s: expected 4 values, got %v", mode, got)
+		}
+	}
+
+	// The first error stops the walk
+	_, err := Map(context.Background(), root, fn, WalkOptions{NumWorkers: 1, ErrorHandling: ErrorHandlingStop})
+	if !errors.Is(err, errBad) || !strings.Contains(err.Error(), "c.txt") {
+		t.Errorf("Expected the error for c.txt, got %v", err)
+	}
+}
@@ -0,0 +1,62 @@
+package stride
+
+import (
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// mtimePruner skips the directories that FilterOptions.PruneDirsUnmodifiedSince
+// treats as unchanged. With RecursiveMtimePropagation their subtrees are not
+// read at all; otherwise they are still read to reach the directories beneath
+// them, but none of their own entries are delivered.
+type mtimePruner struct {
+	root      string
+	since     time.Time
+	recursive bool
+	pruned    *int64   // Stats.DirsPrunedByMtime, or nil
+	unchanged sync.Map // Directories whose entries are withheld
+}
+
+// newMtimePruner returns the pruner for a walk of root, or nil if filter
+// prunes nothing. Unchanged directories are counted in pruned unless it is
+// nil.
+func newMtimePruner(root string, filter FilterOptions, pruned *int64) *mtimePruner {
+	if filter.PruneDirsUnmodifiedSince.IsZero() {
+		return nil
+	}
+	return &mtimePruner{
+		root:      root,
+		since:     filter.PruneDirsUnmodifiedSince,
+		recursive: filter.RecursiveMtimePropagation,
+		pruned:    pruned,
+	}
+}
+
+// prunes reports whether the directory at path, described by info, is to be
+// skipped with its subtree. An unchanged directory that is not skipped has
+// its entries withheld instead. The root is always walked.
+func (m *mtimePruner) prunes(path string, info os.FileInfo) bool {
+	if m == nil || path == m.root || !info.ModTime().Before(m.since) {
+		return false
+	}
+	if m.pruned != nil {
+		atomic.AddInt64(m.pruned, 1)
+	}
+	if m.recursive {
+		return true
+	}
+	m.unchanged.Store(path, struct{}{})
+	return false
+}
+
+// withholds reports whether the entries of dir are withheld because it is
+// unchanged. Directories among them are still walked.
+func (m *mtimePruner) withholds(dir string) bool {
+	if m == nil {
+		return false
+	}
+	_, ok := m.unchanged.Load(dir)
+	return ok
+}
@@ -0,0 +1,166 @@
+package stride
+
+import (
+	"context"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/TFMV/stride/walk/walktest"
+)
+
+func TestPruneDirsUnmodifiedSince(t *testing.T) {
+	now := time.Now().Truncate(time.Second)
+	lastRun := now.Add(-24 * time.Hour)
+	old := now.Add(-48 * time.Hour)
+	root := walktest.Tree{
+		"top.txt":              walktest.File{Content: "top"},
+		"stale/":               walktest.Dir{ModTime: old},
+		"stale/a.txt":          walktest.File{Content: "a", ModTime: old},
+		"stale/fresh/":         walktest.Dir{ModTime: now},
+		"stale/fresh/new.txt":  walktest.File{Content: "new"},
+		"stale/quiet/":         walktest.Dir{ModTime: old},
+		"stale/quiet/q.txt":    walktest.File{Content: "q", ModTime: old},
+		"changed/":             walktest.Dir{ModTime: now},
+		"changed/b.txt":        walktest.File{Content: "b"},
+		"changed/old/":         walktest.Dir{ModTime: old},
+		"changed/old/c.txt":    walktest.File{Content: "c", ModTime: old},
+		"changed/old/sub/":     walktest.Dir{ModTime: old},
+		"changed/old/sub/d.go": walktest.File{Content: "d", ModTime: old},
+	}.Build(t)
+
+	tests := []struct {
+		name       string
+		recursive  bool
+		files      []string
+		pruned     int64
+		unenumered []string // Directories whose entries must never be listed
+	}{
+		{
+			name:   "entries withheld",
+			files:  []string{"changed/b.txt", "stale/fresh/new.txt", "top.txt"},
+			pruned: 4,
+		},
+		{
+			name:       "subtrees skipped",
+			recursive:  true,
+			files:      []string{"changed/b.txt", "top.txt"},
+			pruned:     2,
+			unenumered: []string{"stale", "changed/old"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Record every path the walkers enumerate
+			var mu sync.Mutex
+			var listed []string
+			useWalkDirTree(t, func(dir string, fn fs.WalkDirFunc) error {
+				return walkDirReparse(dir, func(path string, d fs.DirEntry, err error) error {
+					mu.Lock()
+					listed = append(listed, relSlashPath(root, path))
+					mu.Unlock()
+					return fn(path, d, err)
+				})
+			})
+			checkListed := func(t *testing.T) {
+				t.Helper()
+				for _, path := range listed {
+					for _, dir := range tt.unenumered {
+						if strings.HasPrefix(path, dir+"/") {
+							t.Errorf("Expected %s not to be read, got %s", dir, path)
+						}
+					}
+				}
+				listed = nil
+			}
+			filter := FilterOptions{PruneDirsUnmodifiedSince: lastRun, RecursiveMtimePropagation: tt.recursive}
+
+			var files []string
+			stats, err := WalkWithOptionsAndStats(root, func(ctx context.Context, path string, info os.FileInfo) error {
+				if !info.IsDir() {
+					mu.Lock()
+					files = append(files, relSlashPath(root, path))
+					mu.Unlock()
+				}
+				return nil
+			}, WalkOptions{NumWorkers: 2, Filter: filter})
+			if err != nil {
+				t.Fatalf("Failed to walk: %v", err)
+			}
+			sort.Strings(files)
+			if !reflect.DeepEqual(files, tt.files) {
+				t.Errorf("Expected files %v, got %v", tt.files, files)
+			}
+			if stats.DirsPrunedByMtime != tt.pruned {
+				t.Errorf("Expected %d directories pruned, got %d", tt.pruned, stats.DirsPrunedByMtime)
+			}
+			checkListed(t)
+
+			// WalkDir and Find prune alike
+			files = nil
+			err = WalkDir(root, func(ctx context.Context, path string, d fs.DirEntry) error {
+				if !d.IsDir() {
+					mu.Lock()
+					files = append(files, relSlashPath(root, path))
+					mu.Unlock()
+				}
+				return nil
+			}, WalkOptions{NumWorkers: 2, Filter: filter})
+			if err != nil {
+				t.Fatalf("Failed to walk: %v", err)
+			}
+			sort.Strings(files)
+			if !reflect.DeepEqual(files, tt.files) {
+				t.Errorf("Expected files %v from WalkDir, got %v", tt.files, files)
+			}
+			checkListed(t)
+
+			files = nil
+			err = Find(context.Background(), root, FindOptions{
+				NamePattern:               "*",
+				MaxDepth:                  10,
+				PruneDirsUnmodifiedSince:  lastRun,
+				RecursiveMtimePropagation: tt.recursive,
+			}, func(ctx context.Context, result FindResult) error {
+				if result.Error == nil && !result.Message.IsDir {
+					mu.Lock()
+					files = append(files, relSlashPath(root, result.Message.Path))
+					mu.Unlock()
+				}
+				return nil
+			})
+			if err != nil {
+				t.Fatalf("Failed to find: %v", err)
+			}
+			sort.Strings(files)
+			if !reflect.DeepEqual(files, tt.files) {
+				t.Errorf("Expected files %v from Find, got %v", tt.files, files)
+			}
+			checkListed(t)
+		})
+	}
+
+	// The root is walked however old it is
+	if err := os.Chtimes(root, old, old); err != nil {
+		t.Fatalf("Failed to set root time: %v", err)
+	}
+	var found bool
+	err := WalkWithOptions(root, func(ctx context.Context, path string, info os.FileInfo) error {
+		if filepath.Base(path) == "top.txt" {
+			found = true
+		}
+		return nil
+	}, WalkOptions{Filter: FilterOptions{PruneDirsUnmodifiedSince: lastRun, RecursiveMtimePropagation: true}})
+	if err != nil {
+		t.Fatalf("Failed to walk: %v", err)
+	}
+	if !found {
+		t.Error("Expected the files of an old root to be walked")
+	}
+}
@@ -0,0 +1,33 @@
+package stride
+
+import (
+	"io"
+	"os"
+	"sync"
+)
+
+// syncWriter serializes writes from concurrent handlers so that each Write
+// call reaches the underlying writer intact.
+type syncWriter struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+// Write writes p to the underlying writer while holding the lock.
+func (s *syncWriter) Write(p []byte) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.w.Write(p)
+}
+
+// newOutputWriter returns a synchronized writer for handler output,
+// defaulting to standard output.
+func newOutputWriter(w io.Writer) io.Writer {
+	if w == nil {
+		w = os.Stdout
+	}
+	if sw, ok := w.(*syncWriter); ok {
+		return sw
+	}
+	return &syncWriter{w: w}
+}
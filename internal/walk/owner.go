@@ -0,0 +1,111 @@
+package stride
+
+import (
+	"os"
+	"os/user"
+	"strconv"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// nameCacheTTL bounds how long a resolved user or group name is reused.
+const nameCacheTTL = 5 * time.Minute
+
+// nameResolver looks up user and group names by numeric id.
+type nameResolver interface {
+	LookupUser(uid string) (string, error)
+	LookupGroup(gid string) (string, error)
+}
+
+// osNameResolver resolves names through the os/user package.
+type osNameResolver struct{}
+
+func (osNameResolver) LookupUser(uid string) (string, error) {
+	u, err := user.LookupId(uid)
+	if err != nil {
+		return "", err
+	}
+	return u.Username, nil
+}
+
+func (osNameResolver) LookupGroup(gid string) (string, error) {
+	g, err := user.LookupGroupId(gid)
+	if err != nil {
+		return "", err
+	}
+	return g.Name, nil
+}
+
+// nameCacheEntry is a cached name with its expiry time.
+type nameCacheEntry struct {
+	name    string
+	expires time.Time
+}
+
+// nameCache caches uid→username and gid→groupname lookups, which can be
+// slow on systems backed by directory services.
+type nameCache struct {
+	resolver nameResolver
+	users    sync.Map // uid string -> nameCacheEntry
+	groups   sync.Map // gid string -> nameCacheEntry
+}
+
+// newNameCache creates a name cache backed by the given resolver.
+func newNameCache(resolver nameResolver) *nameCache {
+	return &nameCache{resolver: resolver}
+}
+
+// defaultNameCache is the process-wide cache used by walks and finds.
+var defaultNameCache = newNameCache(osNameResolver{})
+
+// userName returns the username for uid, or the numeric uid if it is unknown.
+func (c *nameCache) userName(uid uint32) string {
+	return c.lookup(&c.users, strconv.FormatUint(uint64(uid), 10), c.resolver.LookupUser)
+}
+
+// groupName returns the group name for gid, or the numeric gid if it is unknown.
+func (c *nameCache) groupName(gid uint32) string {
+	return c.lookup(&c.groups, strconv.FormatUint(uint64(gid), 10), c.resolver.LookupGroup)
+}
+
+// lookup returns a cached name for id, resolving and caching it on a miss.
+func (c *nameCache) lookup(cache *sync.Map, id string, resolve func(string) (string, error)) string {
+	now := time.Now()
+	if v, ok := cache.Load(id); ok {
+		entry := v.(nameCacheEntry)
+		if now.Before(entry.expires) {
+			return entry.name
+		}
+	}
+
+	name, err := resolve(id)
+	if err != nil || name == "" {
+		name = id
+	}
+	cache.Store(id, nameCacheEntry{name: name, expires: now.Add(nameCacheTTL)})
+	return name
+}
+
+// fileOwner returns the owning uid and gid of a file, if available.
+func fileOwner(info os.FileInfo) (uid, gid uint32, ok bool) {
+	if info == nil {
+		return 0, 0, false
+	}
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return 0, 0, false
+	}
+	return stat.Uid, stat.Gid, true
+}
+
+// OwnerNames returns the user and group names owning the file described by
+// info, using the process-wide lookup cache. Unknown ids are returned as
+// numeric strings; both names are empty if ownership is unavailable.
+func OwnerNames(info os.FileInfo) (owner, group string) {
+	uid, gid, ok := fileOwner(info)
+	if !ok {
+		return "", ""
+	}
+	return defaultNameCache.userName(uid), defaultNameCache.groupName(gid)
+}
@@ -0,0 +1,104 @@
+package stride
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// fakeResolver is a nameResolver that counts lookups and knows a fixed set of ids.
+type fakeResolver struct {
+	delay   time.Duration
+	lookups int64
+	names   map[string]string
+}
+
+func (r *fakeResolver) resolve(id string) (string, error) {
+	atomic.AddInt64(&r.lookups, 1)
+	time.Sleep(r.delay)
+	if name, ok := r.names[id]; ok {
+		return name, nil
+	}
+	return "", errors.New("unknown id")
+}
+
+func (r *fakeResolver) LookupUser(uid string) (string, error)  { return r.resolve(uid) }
+func (r *fakeResolver) LookupGroup(gid string) (string, error) { return r.resolve(gid) }
+
+func TestNameCache(t *testing.T) {
+	resolver := &fakeResolver{names: map[string]string{"0": "root", "1000": "alice"}}
+	cache := newNameCache(resolver)
+
+	for i := 0; i < 10; i++ {
+		if got := cache.userName(1000); got != "alice" {
+			t.Errorf("Expected alice, got %s", got)
+		}
+	}
+	if got := resolver.lookups; got != 1 {
+		t.Errorf("Expected 1 lookup for repeated uid, got %d", got)
+	}
+
+	// Unknown ids fall back to the numeric string and are cached too
+	if got := cache.userName(4242); got != "4242" {
+		t.Errorf("Expected numeric fallback 4242, got %s", got)
+	}
+	if got := cache.groupName(4242); got != "4242" {
+		t.Errorf("Expected numeric fallback 4242, got %s", got)
+	}
+	cache.userName(4242)
+	if got := resolver.lookups; got != 3 {
+		t.Errorf("Expected 3 lookups, got %d", got)
+	}
+}
+
+func TestFindResolveOwner(t *testing.T) {
+	tmpDir := t.TempDir()
+	path := filepath.Join(tmpDir, "file.txt")
+	if err := os.WriteFile(path, []byte("data"), 0644); err != nil {
+		t.Fatalf("Failed to create file: %v", err)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("Failed to stat file: %v", err)
+	}
+	wantOwner, wantGroup := OwnerNames(info)
+
+	var got FindMessage
+	err = Find(context.Background(), tmpDir, FindOptions{ResolveOwner: true}, func(ctx context.Context, result FindResult) error {
+		got = result.Message
+		return result.Error
+	})
+	if err != nil {
+		t.Fatalf("Find failed: %v", err)
+	}
+	if got.Owner != wantOwner || got.Group != wantGroup {
+		t.Errorf("Expected owner %s:%s, got %s:%s", wantOwner, wantGroup, got.Owner, got.Group)
+	}
+	if formatted := formatCommand("{owner}:{group}", got); formatted != wantOwner+":"+wantGroup {
+		t.Errorf("Expected %s:%s, got %s", wantOwner, wantGroup, formatted)
+	}
+}
+
+func BenchmarkNameCache(b *testing.B) {
+	const distinctUIDs = 8
+	resolver := &fakeResolver{delay: time.Millisecond, names: map[string]string{}}
+	for i := 0; i < distinctUIDs; i++ {
+		resolver.names[strconv.Itoa(i)] = "user" + strconv.Itoa(i)
+	}
+	cache := newNameCache(resolver)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		cache.userName(uint32(i % distinctUIDs))
+	}
+	b.StopTimer()
+
+	if lookups := atomic.LoadInt64(&resolver.lookups); lookups > distinctUIDs {
+		b.Errorf("Expected at most %d lookups, got %d", distinctUIDs, lookups)
+	}
+}
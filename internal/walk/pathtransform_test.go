@@ -0,0 +1,167 @@
+package stride
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/TFMV/stride/walk/walktest"
+)
+
+func TestPathTransform(t *testing.T) {
+	entries := []string{"Docs", "Docs/Guide.TXT", "Docs/README.md", "src", "src/Main.go", "src/util", "src/util/Util.go"}
+
+	tests := []struct {
+		name      string
+		invalid   bool // Add a file whose name is not valid UTF-8
+		transform func(root string) PathTransformFunc
+		want      func(root, rel string) string // Path seen for each entry
+		skipped   int64
+	}{
+		{
+			name: "Lowercase",
+			transform: func(root string) PathTransformFunc {
+				return func(path string, info os.FileInfo) (string, bool) {
+					return strings.ToLower(path), true
+				}
+			},
+			want: func(root, rel string) string {
+				return strings.ToLower(filepath.Join(root, filepath.FromSlash(rel)))
+			},
+		},
+		{
+			name: "Strip mount prefix",
+			transform: func(root string) PathTransformFunc {
+				return func(path string, info os.FileInfo) (string, bool) {
+					rel, _ := filepath.Rel(root, path)
+					return "/" + filepath.ToSlash(rel), true
+				}
+			},
+			want: func(root, rel string) string {
+				return "/" + rel
+			},
+		},
+		{
+			name:    "Veto invalid UTF-8",
+			invalid: true,
+			transform: func(root string) PathTransformFunc {
+				return func(path string, info os.FileInfo) (string, bool) {
+					return path, utf8.ValidString(path)
+				}
+			},
+			want: func(root, rel string) string {
+				return filepath.Join(root, filepath.FromSlash(rel))
+			},
+			skipped: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			root := walktest.Tree{
+				"Docs/README.md":   walktest.File{Content: "readme"},
+				"Docs/Guide.TXT":   walktest.File{Content: "guide"},
+				"src/Main.go":      walktest.File{Content: "main"},
+				"src/util/Util.go": walktest.File{Content: "util"},
+			}.Build(t)
+			files := int64(4)
+			if tt.invalid {
+				if err := os.WriteFile(filepath.Join(root, "src", "bad\xff.go"), []byte("bad"), 0644); err != nil {
+					t.Skipf("Cannot create a file name that is not valid UTF-8: %v", err)
+				}
+				files++
+			}
+
+			var mu sync.Mutex
+			var seen, middlewareSeen []string
+			record := func(list *[]string, path string) {
+				mu.Lock()
+				defer mu.Unlock()
+				*list = append(*list, path)
+			}
+
+			transform := tt.transform(root)
+			stats, err := WalkWithOptionsAndStats(root, func(ctx context.Context, path string, info os.FileInfo) error {
+				record(&seen, path)
+				return nil
+			}, WalkOptions{
+				NumWorkers:    2,
+				IncludeRoot:   new(bool),
+				PathTransform: transform,
+				Middleware: []MiddlewareFunc{func(next WalkFunc) WalkFunc {
+					return func(ctx context.Context, path string, info os.FileInfo) error {
+						record(&middlewareSeen, path)
+						return next(ctx, path, info)
+					}
+				}},
+			})
+			if err != nil {
+				t.Fatalf("Walk failed: %v", err)
+			}
+
+			var want []string
+			for _, rel := range entries {
+				want = append(want, tt.want(root, rel))
+			}
+			sort.Strings(want)
+			sort.Strings(seen)
+			sort.Strings(middlewareSeen)
+			if !reflect.DeepEqual(seen, want) {
+				t.Errorf("Expected the callback to see %q, got %q", want, seen)
+			}
+			if !reflect.DeepEqual(middlewareSeen, want) {
+				t.Errorf("Expected the middleware to see %q, got %q", want, middlewareSeen)
+			}
+
+			// Stats reflect the real traversal
+			if stats.FilesProcessed != files-tt.skipped || stats.FilesSkipped != tt.skipped {
+				t.Errorf("Expected %d files processed and %d skipped, got %d and %d", files-tt.skipped, tt.skipped, stats.FilesProcessed, stats.FilesSkipped)
+			}
+			if stats.DirsProcessed != 3 {
+				t.Errorf("Expected 3 directories, got %d", stats.DirsProcessed)
+			}
+		})
+	}
+}
+
+func TestPathTransformVetoDir(t *testing.T) {
+	root := walktest.Tree{
+		"keep/a.txt":   walktest.File{},
+		"hidden/b.txt": walktest.File{},
+	}.Build(t)
+
+	var mu sync.Mutex
+	var seen []string
+	stats, err := WalkLimitWithOptionsStats(context.Background(), root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		mu.Lock()
+		seen = append(seen, relSlashPath(root, path))
+		mu.Unlock()
+		return nil
+	}, WalkOptions{
+		NumWorkers: 2,
+		PathTransform: func(path string, info os.FileInfo) (string, bool) {
+			return path, filepath.Base(path) != "hidden"
+		},
+	})
+	if err != nil {
+		t.Fatalf("Walk failed: %v", err)
+	}
+
+	// The vetoed directory's children are still walked
+	sort.Strings(seen)
+	if want := []string{".", "hidden/b.txt", "keep", "keep/a.txt"}; !reflect.DeepEqual(seen, want) {
+		t.Errorf("Expected %q, got %q", want, seen)
+	}
+	if stats.FilesSkipped != 1 || stats.DirsProcessed != 2 {
+		t.Errorf("Expected 1 entry skipped and 2 directories processed, got %d and %d", stats.FilesSkipped, stats.DirsProcessed)
+	}
+}
@@ -0,0 +1,168 @@
+package stride
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Permission bits for other users, used by the *ByOther filters.
+const (
+	otherRead    os.FileMode = 0004
+	otherWrite   os.FileMode = 0002
+	otherExecute os.FileMode = 0001
+)
+
+// permissionsMatch reports whether the permission bits of mode satisfy the
+// permission criteria of filter. All criteria that are set must hold.
+//
+// ExactPermissions (with UseExactPermissions) requires the bits to be equal
+// and, when set, replaces the MinPermissions and MaxPermissions checks.
+//
+// MinPermissions requires every bit it names to be set in the file; other
+// bits are ignored (like find -perm -MODE):
+//
+//	Min   file  match   reason
+//	0444  0644  yes     r--r--r-- all set
+//	0444  0600  no      group and other read missing
+//	0444  0755  yes     extra bits are ignored
+//	0600  0640  yes     rw------- set
+//	0002  0644  no      other write missing
+//	0002  0666  yes     other write set
+//
+// MaxPermissions requires the file to have no bit outside the ones it names;
+// bits it names may be unset (the file mode is a subset of Max):
+//
+//	Max   file  match   reason
+//	0755  0644  yes     subset of rwxr-xr-x
+//	0755  0600  yes     subset of rwxr-xr-x
+//	0755  0777  no      group and other write not allowed
+//	0644  0755  no      execute bits not allowed
+//	0644  0640  yes     subset of rw-r--r--
+//	0600  0604  no      other read not allowed
+//
+// Note that Max is not a numeric bound: 0700 is numerically smaller than 0755
+// but a 0744 file does not match Max 0700.
+func permissionsMatch(mode os.FileMode, filter FilterOptions) bool {
+	perm := mode.Perm()
+	if filter.UseExactPermissions && filter.ExactPermissions != 0 {
+		if perm != filter.ExactPermissions.Perm() {
+			return false
+		}
+	} else {
+		if filter.MinPermissions != 0 && perm&filter.MinPermissions != filter.MinPermissions {
+			return false
+		}
+		if filter.MaxPermissions != 0 && perm&^filter.MaxPermissions != 0 {
+			return false
+		}
+	}
+
+	if len(filter.PermissionsAnyOf) > 0 {
+		matched := false
+		for _, want := range filter.PermissionsAnyOf {
+			if perm == want.Perm() {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return false
+		}
+	}
+	if filter.AnyPermissions != 0 && perm&filter.AnyPermissions == 0 {
+		return false
+	}
+
+	if filter.ReadableByOther && perm&otherRead == 0 {
+		return false
+	}
+	if filter.WritableByOther && perm&otherWrite == 0 {
+		return false
+	}
+	if filter.ExecutableByOther && perm&otherExecute == 0 {
+		return false
+	}
+	return true
+}
+
+// hasPermissionFilter reports whether filter has any permission criteria.
+func hasPermissionFilter(filter FilterOptions) bool {
+	return filter.MinPermissions != 0 || filter.MaxPermissions != 0 ||
+		(filter.UseExactPermissions && filter.ExactPermissions != 0) ||
+		len(filter.PermissionsAnyOf) > 0 || filter.AnyPermissions != 0 ||
+		filter.ReadableByOther || filter.WritableByOther || filter.ExecutableByOther
+}
+
+// ParseSymbolicMode parses a permission mode given either in octal ("644",
+// "0755") or as comma-separated chmod-style clauses ("u=rw,go=r", "a+x",
+// "u=rwx,o-w"). Clauses are applied in order starting from no permissions;
+// a clause without a who part ("+x") applies to all of u, g and o. Only the
+// r, w and x permissions are supported.
+func ParseSymbolicMode(s string) (os.FileMode, error) {
+	if s == "" {
+		return 0, fmt.Errorf("empty mode")
+	}
+	if s[0] >= '0' && s[0] <= '9' {
+		v, err := strconv.ParseUint(s, 8, 32)
+		if err != nil {
+			return 0, fmt.Errorf("invalid octal mode %q", s)
+		}
+		if v > 0777 {
+			return 0, fmt.Errorf("mode %q has bits outside 0777", s)
+		}
+		return os.FileMode(v), nil
+	}
+
+	var mode os.FileMode
+	for _, clause := range strings.Split(s, ",") {
+		opIdx := strings.IndexAny(clause, "=+-")
+		if opIdx < 0 {
+			return 0, fmt.Errorf("invalid mode clause %q: missing operator", clause)
+		}
+
+		var who os.FileMode
+		for _, c := range clause[:opIdx] {
+			switch c {
+			case 'u':
+				who |= 0700
+			case 'g':
+				who |= 0070
+			case 'o':
+				who |= 0007
+			case 'a':
+				who |= 0777
+			default:
+				return 0, fmt.Errorf("invalid mode clause %q: unknown who %q", clause, c)
+			}
+		}
+		if who == 0 {
+			who = 0777
+		}
+
+		var perms os.FileMode
+		for _, c := range clause[opIdx+1:] {
+			switch c {
+			case 'r':
+				perms |= 0444
+			case 'w':
+				perms |= 0222
+			case 'x':
+				perms |= 0111
+			default:
+				return 0, fmt.Errorf("invalid mode clause %q: unsupported permission %q", clause, c)
+			}
+		}
+
+		switch clause[opIdx] {
+		case '=':
+			mode = mode&^who | perms&who
+		case '+':
+			mode |= perms & who
+		case '-':
+			mode &^= perms & who
+		}
+	}
+	return mode, nil
+}
@@ -0,0 +1,167 @@
+package stride
+
+import (
+	"fmt"
+	"os"
+	"testing"
+)
+
+// representativeModes covers common file and directory permissions plus
+// single-bit and world-writable cases
+var representativeModes = []os.FileMode{
+	0000, 0001, 0002, 0004, 0400, 0444, 0600, 0604, 0640, 0644,
+	0664, 0666, 0700, 0744, 0750, 0755, 0775, 0777,
+}
+
+func TestPermissionsMatchMin(t *testing.T) {
+	for _, min := range representativeModes {
+		if min == 0 {
+			continue
+		}
+		for _, mode := range representativeModes {
+			t.Run(fmt.Sprintf("min=%04o/mode=%04o", min, mode), func(t *testing.T) {
+				// Every bit of min must be set in mode
+				expected := true
+				for bit := os.FileMode(1); bit <= 0400; bit <<= 1 {
+					if min&bit != 0 && mode&bit == 0 {
+						expected = false
+					}
+				}
+				if got := permissionsMatch(mode, FilterOptions{MinPermissions: min}); got != expected {
+					t.Errorf("Expected %v, got %v", expected, got)
+				}
+			})
+		}
+	}
+}
+
+func TestPermissionsMatchMax(t *testing.T) {
+	for _, max := range representativeModes {
+		if max == 0 {
+			continue
+		}
+		for _, mode := range representativeModes {
+			t.Run(fmt.Sprintf("max=%04o/mode=%04o", max, mode), func(t *testing.T) {
+				// No bit of mode may lie outside max
+				expected := true
+				for bit := os.FileMode(1); bit <= 0400; bit <<= 1 {
+					if mode&bit != 0 && max&bit == 0 {
+						expected = false
+					}
+				}
+				if got := permissionsMatch(mode, FilterOptions{MaxPermissions: max}); got != expected {
+					t.Errorf("Expected %v, got %v", expected, got)
+				}
+			})
+		}
+	}
+}
+
+func TestPermissionsMatchTables(t *testing.T) {
+	// The rows documented on permissionsMatch
+	tests := []struct {
+		filter   FilterOptions
+		mode     os.FileMode
+		expected bool
+	}{
+		{FilterOptions{MinPermissions: 0444}, 0644, true},
+		{FilterOptions{MinPermissions: 0444}, 0600, false},
+		{FilterOptions{MinPermissions: 0444}, 0755, true},
+		{FilterOptions{MinPermissions: 0600}, 0640, true},
+		{FilterOptions{MinPermissions: 0002}, 0644, false},
+		{FilterOptions{MinPermissions: 0002}, 0666, true},
+		{FilterOptions{MaxPermissions: 0755}, 0644, true},
+		{FilterOptions{MaxPermissions: 0755}, 0600, true},
+		{FilterOptions{MaxPermissions: 0755}, 0777, false},
+		{FilterOptions{MaxPermissions: 0644}, 0755, false},
+		{FilterOptions{MaxPermissions: 0644}, 0640, true},
+		{FilterOptions{MaxPermissions: 0600}, 0604, false},
+		{FilterOptions{MaxPermissions: 0700}, 0744, false},
+	}
+	for _, tt := range tests {
+		if got := permissionsMatch(tt.mode, tt.filter); got != tt.expected {
+			t.Errorf("min=%04o max=%04o mode=%04o: expected %v, got %v",
+				tt.filter.MinPermissions, tt.filter.MaxPermissions, tt.mode, tt.expected, got)
+		}
+	}
+}
+
+func TestPermissionsMatchOther(t *testing.T) {
+	anyOf := []os.FileMode{0644, 0600}
+	for _, mode := range representativeModes {
+		t.Run(fmt.Sprintf("mode=%04o", mode), func(t *testing.T) {
+			tests := []struct {
+				name     string
+				filter   FilterOptions
+				expected bool
+			}{
+				{"AnyOf", FilterOptions{PermissionsAnyOf: anyOf}, mode == 0644 || mode == 0600},
+				{"AnyBits", FilterOptions{AnyPermissions: 0022}, mode&0022 != 0},
+				{"ReadableByOther", FilterOptions{ReadableByOther: true}, mode&0004 != 0},
+				{"WritableByOther", FilterOptions{WritableByOther: true}, mode&0002 != 0},
+				{"ExecutableByOther", FilterOptions{ExecutableByOther: true}, mode&0001 != 0},
+				{"Exact", FilterOptions{ExactPermissions: 0644, UseExactPermissions: true}, mode == 0644},
+				{"Exact overrides min", FilterOptions{ExactPermissions: 0600, UseExactPermissions: true, MinPermissions: 0444}, mode == 0600},
+				{"Combined", FilterOptions{MaxPermissions: 0755, ReadableByOther: true}, mode&^0755 == 0 && mode&0004 != 0},
+				{"None", FilterOptions{}, true},
+			}
+			for _, tt := range tests {
+				if got := permissionsMatch(mode, tt.filter); got != tt.expected {
+					t.Errorf("%s: expected %v, got %v", tt.name, tt.expected, got)
+				}
+			}
+		})
+	}
+
+	// Type bits never affect permission matching
+	if !permissionsMatch(os.ModeDir|0755, FilterOptions{PermissionsAnyOf: []os.FileMode{0755}}) {
+		t.Error("Expected directory mode to match on permission bits only")
+	}
+}
+
+func TestParseSymbolicMode(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected os.FileMode
+		wantErr  bool
+	}{
+		{input: "644", expected: 0644},
+		{input: "0755", expected: 0755},
+		{input: "0", expected: 0},
+		{input: "u=rw,go=r", expected: 0644},
+		{input: "u=rwx,g=rx,o=rx", expected: 0755},
+		{input: "a=r", expected: 0444},
+		{input: "=rw", expected: 0666},
+		{input: "+x", expected: 0111},
+		{input: "o+w", expected: 0002},
+		{input: "a=rwx,o-w", expected: 0775},
+		{input: "ug=rw,g-w", expected: 0640},
+		{input: "u=rw,u=r", expected: 0400},
+		{input: "go=", expected: 0},
+		{input: "", wantErr: true},
+		{input: "0888", wantErr: true},
+		{input: "01777", wantErr: true},
+		{input: "u", wantErr: true},
+		{input: "z=r", wantErr: true},
+		{input: "u=rs", wantErr: true},
+		{input: "u=rw,", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, err := ParseSymbolicMode(tt.input)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("Expected error, got %04o", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if got != tt.expected {
+				t.Errorf("Expected %04o, got %04o", tt.expected, got)
+			}
+		})
+	}
+}
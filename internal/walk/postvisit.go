@@ -0,0 +1,209 @@
+package stride
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+)
+
+// ChildStats summarizes the entries processed beneath a directory, at any
+// depth, as counted in Stats.
+type ChildStats struct {
+	Files int64 // Files processed beneath the directory
+	Dirs  int64 // Subdirectories processed beneath the directory
+	Bytes int64 // Total size of the files processed beneath the directory
+}
+
+// PostChildrenFunc is called for a directory once everything beneath it has
+// been processed. See WalkOptions.PostChildrenCallback.
+type PostChildrenFunc func(ctx context.Context, path string, info os.FileInfo, childStats ChildStats) error
+
+// postNode is a directory whose subtree is still being processed.
+type postNode struct {
+	path    string
+	info    os.FileInfo
+	visible bool // Whether the directory was passed to the callback
+	parent  *postNode
+	listed  bool // Breadth first: whether its enumeration has ended
+
+	// pending counts what the directory waits for: its own enumeration,
+	// files dispatched to workers and unfinished subdirectories.
+	pending int64
+	stats   ChildStats // Updated atomically
+}
+
+// postVisitor runs the post-children callback of a walk. Directories are
+// opened and files dispatched from the walking goroutine, in traversal order;
+// files complete on the workers. A directory finishes when the walk has moved
+// past it and every file and subdirectory beneath it has completed, so the
+// callback runs on whichever goroutine completes the last of them. A nil
+// *postVisitor does nothing.
+//
+// In a breadth-first walk, the walk moving past a directory is not seen from
+// the paths it enumerates, so it reports each directory whose entries it has
+// enumerated to listed instead.
+type postVisitor struct {
+	ctx          context.Context
+	fn           PostChildrenFunc
+	onErr        func(path string, err error) // Handles errors returned by fn
+	breadthFirst bool
+	nodes        sync.Map    // Cleaned path -> *postNode
+	stack        []*postNode // Directories still being enumerated, depth first
+}
+
+// newPostVisitor creates a visitor calling fn, or returns nil if fn is nil.
+// breadthFirst tells whether the walk is a breadth-first one.
+func newPostVisitor(ctx context.Context, fn PostChildrenFunc, onErr func(path string, err error), breadthFirst bool) *postVisitor {
+	if fn == nil {
+		return nil
+	}
+	return &postVisitor{ctx: ctx, fn: fn, onErr: onErr, breadthFirst: breadthFirst}
+}
+
+// enterDir opens the directory at path, whose entries are walked next.
+// Only visible directories are passed to the callback.
+func (v *postVisitor) enterDir(path string, info os.FileInfo, visible bool) {
+	if v == nil {
+		return
+	}
+	path = filepath.Clean(path)
+	n := &postNode{path: path, info: info, visible: visible, pending: 1}
+	if v.breadthFirst {
+		v.listed(path)
+		n.parent = v.parent(path)
+	} else {
+		v.leave(path)
+		if len(v.stack) > 0 {
+			n.parent = v.stack[len(v.stack)-1]
+		}
+		v.stack = append(v.stack, n)
+	}
+	if n.parent != nil {
+		atomic.AddInt64(&n.parent.pending, 1)
+	}
+	v.nodes.Store(path, n)
+}
+
+// listed ends the enumeration of the directory at path in a breadth-first
+// walk. It is ignored for paths that are not open directories.
+func (v *postVisitor) listed(path string) {
+	if v == nil || !v.breadthFirst {
+		return
+	}
+	n, ok := v.nodes.Load(filepath.Clean(path))
+	if !ok || n.(*postNode).listed {
+		return
+	}
+	n.(*postNode).listed = true
+	v.release(n.(*postNode))
+}
+
+// dispatchFile records that the file at path is about to be sent to a
+// worker. Each call must be matched by a call to fileDone.
+func (v *postVisitor) dispatchFile(path string) {
+	if v == nil {
+		return
+	}
+	path = filepath.Clean(path)
+	if !v.breadthFirst {
+		v.leave(path)
+	}
+	if n := v.parent(path); n != nil {
+		atomic.AddInt64(&n.pending, 1)
+	}
+}
+
+// countFile adds a processed file to its directory's statistics.
+func (v *postVisitor) countFile(path string, size int64) {
+	if v == nil {
+		return
+	}
+	if n := v.parent(filepath.Clean(path)); n != nil {
+		atomic.AddInt64(&n.stats.Files, 1)
+		atomic.AddInt64(&n.stats.Bytes, size)
+	}
+}
+
+// fileDone records that a dispatched file has been processed.
+func (v *postVisitor) fileDone(path string) {
+	if v == nil {
+		return
+	}
+	if n := v.parent(filepath.Clean(path)); n != nil {
+		v.release(n)
+	}
+}
+
+// finish ends the enumeration of every directory still open. Directories
+// waiting for workers finish when their last file completes.
+func (v *postVisitor) finish() {
+	if v == nil {
+		return
+	}
+	if v.breadthFirst {
+		var open []string
+		v.nodes.Range(func(path, _ any) bool {
+			open = append(open, path.(string))
+			return true
+		})
+		for _, path := range open {
+			v.listed(path)
+		}
+		return
+	}
+	v.leave("")
+}
+
+// parent returns the open directory containing path.
+func (v *postVisitor) parent(path string) *postNode {
+	n, ok := v.nodes.Load(filepath.Dir(path))
+	if !ok {
+		return nil
+	}
+	return n.(*postNode)
+}
+
+// leave ends the enumeration of the open directories that do not contain
+// path, innermost first.
+func (v *postVisitor) leave(path string) {
+	for len(v.stack) > 0 {
+		n := v.stack[len(v.stack)-1]
+		if path != "" && isWithinDir(n.path, path) {
+			return
+		}
+		v.stack = v.stack[:len(v.stack)-1]
+		v.release(n)
+	}
+}
+
+// release drops one pending item of n and finishes it if none remain.
+func (v *postVisitor) release(n *postNode) {
+	for n != nil && atomic.AddInt64(&n.pending, -1) == 0 {
+		v.nodes.CompareAndDelete(n.path, n)
+		stats := ChildStats{
+			Files: atomic.LoadInt64(&n.stats.Files),
+			Dirs:  atomic.LoadInt64(&n.stats.Dirs),
+			Bytes: atomic.LoadInt64(&n.stats.Bytes),
+		}
+		// A canceled walk did not complete the subtree
+		if n.visible && v.ctx.Err() == nil {
+			if err := v.fn(v.ctx, n.path, n.info, stats); err != nil {
+				v.onErr(n.path, err)
+			}
+		}
+
+		// Fold the subtree into the parent, then release it
+		p := n.parent
+		if p != nil {
+			if n.visible {
+				stats.Dirs++
+			}
+			atomic.AddInt64(&p.stats.Files, stats.Files)
+			atomic.AddInt64(&p.stats.Dirs, stats.Dirs)
+			atomic.AddInt64(&p.stats.Bytes, stats.Bytes)
+		}
+		n = p
+	}
+}
@@ -0,0 +1,146 @@
+package stride
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestPostChildrenCallback(t *testing.T) {
+	root := t.TempDir()
+	files := map[string]int{
+		"a.txt":           1,
+		"sub1/b.txt":      10,
+		"sub1/c.txt":      100,
+		"sub1/deep/d.txt": 1000,
+		"sub2/e.txt":      10000,
+		"sub2/skip/f.txt": 100000,
+	}
+	for name, size := range files {
+		path := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("Failed to create directory: %v", err)
+		}
+		if err := os.WriteFile(path, make([]byte, size), 0644); err != nil {
+			t.Fatalf("Failed to create file: %v", err)
+		}
+	}
+	if err := os.Mkdir(filepath.Join(root, "empty"), 0755); err != nil {
+		t.Fatalf("Failed to create directory: %v", err)
+	}
+
+	// Incremental walks dispatch files through a different walker, and
+	// breadth-first walks leave directories in another order
+	for _, variant := range []struct {
+		cache string
+		order TraversalOrder
+	}{
+		{"", DepthFirst},
+		{"", BreadthFirst},
+		{filepath.Join(t.TempDir(), "mtimes.cache"), DepthFirst},
+	} {
+		var mu sync.Mutex
+		var order []string
+		got := make(map[string]ChildStats)
+		rel := func(path string) string {
+			r, _ := filepath.Rel(root, path)
+			return filepath.ToSlash(r)
+		}
+
+		err := WalkLimitWithOptions(context.Background(), root, func(path string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			if info.IsDir() && info.Name() == "skip" {
+				return filepath.SkipDir
+			}
+			if !info.IsDir() {
+				// Let files finish out of order
+				time.Sleep(time.Duration(len(info.Name())%3) * time.Millisecond)
+			}
+			return nil
+		}, WalkOptions{
+			NumWorkers:     4,
+			MtimeCache:     variant.cache,
+			TraversalOrder: variant.order,
+			PostChildrenCallback: func(ctx context.Context, path string, info os.FileInfo, childStats ChildStats) error {
+				mu.Lock()
+				defer mu.Unlock()
+				r := rel(path)
+				if _, seen := got[r]; seen {
+					t.Errorf("Post callback called twice for %s", r)
+				}
+				got[r] = childStats
+				order = append(order, r)
+				return nil
+			},
+		})
+		if err != nil {
+			t.Fatalf("Walk failed: %v", err)
+		}
+
+		want := map[string]ChildStats{
+			".":         {Files: 5, Dirs: 4, Bytes: 11111},
+			"sub1":      {Files: 3, Dirs: 1, Bytes: 1110},
+			"sub1/deep": {Files: 1, Dirs: 0, Bytes: 1000},
+			"sub2":      {Files: 1, Dirs: 0, Bytes: 10000},
+			"empty":     {},
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("Got child stats %v, want %v", got, want)
+		}
+
+		// Every directory follows its descendants
+		position := make(map[string]int, len(order))
+		for i, r := range order {
+			position[r] = i
+		}
+		for r := range want {
+			for other := range want {
+				if other != r && (r == "." || strings.HasPrefix(other, r+"/")) && position[other] > position[r] {
+					t.Errorf("Post callback for %s ran before %s: %v", r, other, order)
+				}
+			}
+		}
+	}
+}
+
+func TestPostChildrenCallbackErrors(t *testing.T) {
+	root := createBudgetFixture(t, 20)
+	errBoom := errors.New("boom")
+	post := func(ctx context.Context, path string, info os.FileInfo, childStats ChildStats) error {
+		if path != root {
+			return errBoom
+		}
+		return nil
+	}
+	walkFn := func(path string, info os.FileInfo, err error) error { return err }
+
+	// Continuing counts the errors
+	stats, err := WalkLimitWithOptionsStats(context.Background(), root, walkFn, WalkOptions{
+		NumWorkers:           2,
+		PostChildrenCallback: post,
+	})
+	if err != nil {
+		t.Errorf("Expected errors to be ignored, got %v", err)
+	}
+	if stats.ErrorCount != 5 {
+		t.Errorf("ErrorCount = %d, want 5", stats.ErrorCount)
+	}
+
+	// Stopping returns the first error
+	_, err = WalkLimitWithOptionsStats(context.Background(), root, walkFn, WalkOptions{
+		NumWorkers:           2,
+		ErrorHandling:        ErrorHandlingStop,
+		PostChildrenCallback: post,
+	})
+	if !errors.Is(err, errBoom) {
+		t.Errorf("Expected the post callback error, got %v", err)
+	}
+}
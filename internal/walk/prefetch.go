@@ -0,0 +1,110 @@
+package stride
+
+import (
+	"os"
+)
+
+// Defaults of PrefetchOptions.
+const (
+	DefaultPrefetchWindow      = 16
+	DefaultPrefetchMaxFileSize = 16 << 20
+)
+
+// PrefetchOptions has the walk tell the operating system which files the
+// callbacks are about to read, so that their contents are read ahead while
+// the workers are busy with earlier files. Each file handed to the workers
+// is queued for a hint; queued hints are issued one at a time in the
+// background, and files are not hinted when WindowFiles of them are already
+// waiting, so hints never hold up the walk. Hints are issued on Linux with
+// posix_fadvise(POSIX_FADV_WILLNEED) and on macOS with F_RDADVISE, and are
+// not issued elsewhere. WalkDir ignores them.
+type PrefetchOptions struct {
+	Enabled     bool
+	WindowFiles int   // Hints waiting at most (default DefaultPrefetchWindow)
+	MaxFileSize int64 // Size above which files are not hinted (default DefaultPrefetchMaxFileSize)
+}
+
+// prefetchAdviser issues read-ahead hints.
+type prefetchAdviser interface {
+	// willNeed hints that the first size bytes of path are about to be read.
+	willNeed(path string, size int64) error
+}
+
+// platformAdviser issues hints with platformWillNeed.
+type platformAdviser struct{}
+
+func (platformAdviser) willNeed(path string, size int64) error {
+	return platformWillNeed(path, size)
+}
+
+// adviser issues the hints of walks, replaceable in tests.
+var adviser prefetchAdviser = platformAdviser{}
+
+// prefetchHint is a file queued for a hint.
+type prefetchHint struct {
+	path string
+	size int64
+}
+
+// prefetcher issues the hints of a walk from its own goroutine. A nil
+// *prefetcher does nothing.
+type prefetcher struct {
+	hints   chan prefetchHint
+	maxSize int64
+	quit    chan struct{}
+}
+
+// newPrefetcher starts issuing hints for a walk with opts, or returns nil if
+// prefetching is disabled.
+func newPrefetcher(opts PrefetchOptions) *prefetcher {
+	if !opts.Enabled {
+		return nil
+	}
+	window := opts.WindowFiles
+	if window <= 0 {
+		window = DefaultPrefetchWindow
+	}
+	maxSize := opts.MaxFileSize
+	if maxSize <= 0 {
+		maxSize = DefaultPrefetchMaxFileSize
+	}
+	p := &prefetcher{
+		hints:   make(chan prefetchHint, window),
+		maxSize: maxSize,
+		quit:    make(chan struct{}),
+	}
+	a := adviser
+	go func() {
+		for {
+			select {
+			case <-p.quit:
+				return
+			case h := <-p.hints:
+				// Hints are best effort
+				_ = a.willNeed(h.path, h.size)
+			}
+		}
+	}()
+	return p
+}
+
+// hint queues a hint for the file at path, unless it is not a regular file
+// with contents, is too large, or the window is full.
+func (p *prefetcher) hint(path string, info os.FileInfo) {
+	if p == nil || !info.Mode().IsRegular() || info.Size() == 0 || info.Size() > p.maxSize {
+		return
+	}
+	select {
+	case p.hints <- prefetchHint{path: path, size: info.Size()}:
+	default:
+	}
+}
+
+// stop discards the hints still queued. A hint in progress is left to
+// finish in the background, so a slow filesystem does not hold up the end of
+// the walk.
+func (p *prefetcher) stop() {
+	if p != nil {
+		close(p.quit)
+	}
+}
@@ -0,0 +1,22 @@
+package stride
+
+import (
+	"math"
+	"os"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+// platformWillNeed hints with fcntl(F_RDADVISE) that the first size bytes of
+// path are about to be read.
+func platformWillNeed(path string, size int64) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	ra := &unix.Radvisory_t{Count: int32(min(size, math.MaxInt32))}
+	_, err = unix.FcntlInt(f.Fd(), unix.F_RDADVISE, int(uintptr(unsafe.Pointer(ra))))
+	return err
+}
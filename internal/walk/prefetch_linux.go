@@ -0,0 +1,18 @@
+package stride
+
+import (
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// platformWillNeed hints with posix_fadvise(2) that the first size bytes of
+// path are about to be read.
+func platformWillNeed(path string, size int64) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return unix.Fadvise(int(f.Fd()), 0, size, unix.FADV_WILLNEED)
+}
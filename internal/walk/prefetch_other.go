@@ -0,0 +1,8 @@
+//go:build !darwin && !linux
+
+package stride
+
+// platformWillNeed issues no hints on this platform.
+func platformWillNeed(path string, size int64) error {
+	return nil
+}
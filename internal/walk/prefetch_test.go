@@ -0,0 +1,197 @@
+package stride
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/TFMV/stride/walk/walktest"
+)
+
+// fakeAdviser records the hints issued, each held up until release is
+// closed if it is set.
+type fakeAdviser struct {
+	mu      sync.Mutex
+	paths   []string
+	called  chan struct{}
+	release chan struct{}
+}
+
+func (a *fakeAdviser) willNeed(path string, size int64) error {
+	a.mu.Lock()
+	a.paths = append(a.paths, path)
+	a.mu.Unlock()
+	select {
+	case a.called <- struct{}{}:
+	default:
+	}
+	if a.release != nil {
+		<-a.release
+	}
+	return nil
+}
+
+func (a *fakeAdviser) issued() []string {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return append([]string(nil), a.paths...)
+}
+
+// useAdviser replaces the adviser of walks for the test.
+func useAdviser(t *testing.T, a *fakeAdviser) {
+	t.Helper()
+	a.called = make(chan struct{}, 1)
+	orig := adviser
+	adviser = a
+	t.Cleanup(func() { adviser = orig })
+}
+
+func TestPrefetchWindow(t *testing.T) {
+	fake := &fakeAdviser{release: make(chan struct{})}
+	useAdviser(t, fake)
+	p := newPrefetcher(PrefetchOptions{Enabled: true, WindowFiles: 2})
+	defer p.stop()
+
+	file := func(name string) os.FileInfo {
+		return cachedFileInfo{f: cachedFile{Name: name, Size: 10}}
+	}
+
+	// The first hint is taken at once and held up, the next two wait in the
+	// window and the rest are dropped
+	p.hint("0", file("0"))
+	<-fake.called
+	for _, name := range []string{"1", "2", "3", "4"} {
+		p.hint(name, file(name))
+	}
+	close(fake.release)
+
+	deadline := time.After(5 * time.Second)
+	for len(fake.issued()) < 3 {
+		select {
+		case <-fake.called:
+		case <-deadline:
+			t.Fatalf("Expected 3 hints, got %v", fake.issued())
+		}
+	}
+	if got, expected := fake.issued(), []string{"0", "1", "2"}; !reflect.DeepEqual(got, expected) {
+		t.Errorf("Expected %v, got %v", expected, got)
+	}
+}
+
+func TestPrefetchWalk(t *testing.T) {
+	tree := walktest.Tree{
+		"empty.txt": walktest.File{},
+		"big.bin":   walktest.File{Size: 2000},
+	}
+	var expected []string
+	for _, name := range []string{"a/1.txt", "a/2.txt", "b/3.txt", "b/c/4.txt", "5.txt"} {
+		tree[name] = walktest.File{Content: name}
+		expected = append(expected, name)
+	}
+	root := tree.Build(t)
+	sort.Strings(expected)
+
+	// Every file with contents is hinted, unless it is too large
+	for _, incremental := range []bool{false, true} {
+		fake := &fakeAdviser{}
+		useAdviser(t, fake)
+		opts := WalkOptions{NumWorkers: 1, Prefetch: PrefetchOptions{Enabled: true, MaxFileSize: 1000}}
+		if incremental {
+			opts.MtimeCache = filepath.Join(t.TempDir(), "cache")
+		}
+		err := WalkLimitWithOptions(context.Background(), root, func(path string, info os.FileInfo, err error) error {
+			if err == nil && !info.IsDir() {
+				time.Sleep(5 * time.Millisecond)
+			}
+			return err
+		}, opts)
+		if err != nil {
+			t.Fatalf("Walk failed: %v", err)
+		}
+		var got []string
+		for _, p := range fake.issued() {
+			got = append(got, relSlashPath(root, p))
+		}
+		sort.Strings(got)
+		if !reflect.DeepEqual(got, expected) {
+			t.Errorf("Expected hints for %v (incremental: %v), got %v", expected, incremental, got)
+		}
+	}
+
+	// Hints that do not return never hold up the walk
+	fake := &fakeAdviser{release: make(chan struct{})}
+	useAdviser(t, fake)
+	defer close(fake.release)
+	done := make(chan error, 1)
+	go func() {
+		done <- WalkLimitWithOptions(context.Background(), root, func(path string, info os.FileInfo, err error) error {
+			return err
+		}, WalkOptions{NumWorkers: 2, Prefetch: PrefetchOptions{Enabled: true, WindowFiles: 1}})
+	}()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("Walk failed: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected the walk to finish while a hint is held up")
+	}
+}
+
+// BenchmarkPrefetch reads every file of a tree in the callbacks, without and
+// with prefetch hints. Hints only help when the files are not already
+// cached, so to measure their effect, point STRIDE_PREFETCH_BENCH_ROOT at a
+// large tree on a spinning disk and drop the page cache before each run,
+// e.g. with "sync; echo 3 > /proc/sys/vm/drop_caches" on Linux or "purge" on
+// macOS, then run
+//
+//	go test -run=NONE -bench=Prefetch -benchtime=1x ./internal/walk/
+//
+// Without the variable, a small generated tree checks that the hints cost
+// little when everything is cached.
+func BenchmarkPrefetch(b *testing.B) {
+	root := os.Getenv("STRIDE_PREFETCH_BENCH_ROOT")
+	if root == "" {
+		tree := walktest.Tree{}
+		for i := 0; i < 500; i++ {
+			tree[fmt.Sprintf("dir%02d/f%03d", i%20, i)] = walktest.File{Size: 4096}
+		}
+		root = tree.Build(b)
+	}
+	for _, enabled := range []bool{false, true} {
+		b.Run("prefetch="+map[bool]string{false: "off", true: "on"}[enabled], func(b *testing.B) {
+			opts := WalkOptions{NumWorkers: 4, Prefetch: PrefetchOptions{Enabled: enabled}}
+			var bytes int64
+			var mu sync.Mutex
+			walkFn := func(path string, info os.FileInfo, err error) error {
+				if err != nil || !info.Mode().IsRegular() {
+					return nil
+				}
+				f, err := os.Open(path)
+				if err != nil {
+					return nil
+				}
+				defer f.Close()
+				n, _ := io.Copy(io.Discard, f)
+				mu.Lock()
+				bytes += n
+				mu.Unlock()
+				return nil
+			}
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if err := WalkLimitWithOptions(context.Background(), root, walkFn, opts); err != nil {
+					b.Fatalf("Walk failed: %v", err)
+				}
+			}
+			b.SetBytes(bytes / int64(b.N))
+		})
+	}
+}
@@ -0,0 +1,153 @@
+package stride
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"sync"
+
+	"github.com/TFMV/stride/proto/stridepb"
+	"google.golang.org/protobuf/encoding/protodelim"
+)
+
+// File type bits of st_mode, from sys/stat.h
+const (
+	statIFIFO  = 0o010000
+	statIFCHR  = 0o020000
+	statIFDIR  = 0o040000
+	statIFBLK  = 0o060000
+	statIFREG  = 0o100000
+	statIFLNK  = 0o120000
+	statIFSOCK = 0o140000
+)
+
+// ProtoStreamWriter writes walked entries to a stream as stridepb.Entry
+// messages, each preceded by its length as a varint, for consumers in other
+// processes and languages; see proto/entry.proto. Entries are buffered:
+// call Flush once the walk is done. It is safe for concurrent use.
+type ProtoStreamWriter struct {
+	mu        sync.Mutex
+	w         *bufio.Writer
+	fileFlags bool
+}
+
+// NewProtoStreamWriter creates a writer streaming entries to w.
+func NewProtoStreamWriter(w io.Writer) *ProtoStreamWriter {
+	return &ProtoStreamWriter{w: bufio.NewWriterSize(w, 64*1024)}
+}
+
+// EnableFileFlags fills the flags of each entry, which takes a system call
+// per entry on some platforms.
+func (p *ProtoStreamWriter) EnableFileFlags() {
+	p.fileFlags = true
+}
+
+// WriteFile writes the entry at path, described by info, found by a walk of
+// root.
+func (p *ProtoStreamWriter) WriteFile(root, path string, info os.FileInfo) error {
+	entry := &stridepb.Entry{
+		Path:        path,
+		Size:        info.Size(),
+		Mode:        statMode(info.Mode()),
+		MtimeUnixNs: info.ModTime().UnixNano(),
+		Depth:       int32(depthOf(root, path)),
+	}
+	if p.fileFlags {
+		entry.Flags = uint32(fileFlagsOf(path, info))
+	}
+	return p.Write(entry)
+}
+
+// Write writes entry as is.
+func (p *ProtoStreamWriter) Write(entry *stridepb.Entry) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	_, err := protodelim.MarshalTo(p.w, entry)
+	return err
+}
+
+// Flush writes the buffered entries to the underlying writer.
+func (p *ProtoStreamWriter) Flush() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.w.Flush()
+}
+
+// Middleware returns a middleware writing each entry of a walk of root
+// before passing it on.
+func (p *ProtoStreamWriter) Middleware(root string) MiddlewareFunc {
+	return func(next WalkFunc) WalkFunc {
+		return func(ctx context.Context, path string, info os.FileInfo) error {
+			if err := p.WriteFile(root, path, info); err != nil {
+				return err
+			}
+			return next(ctx, path, info)
+		}
+	}
+}
+
+// FindHandler returns a handler writing each match of a search of root.
+// Errors are not written; the handler returns them.
+func (p *ProtoStreamWriter) FindHandler(root string) FindHandler {
+	return func(ctx context.Context, result FindResult) error {
+		if result.Error != nil {
+			return result.Error
+		}
+		msg := result.Message
+		entry := &stridepb.Entry{
+			Path:        msg.Path,
+			Size:        msg.Size,
+			Mode:        statMode(msg.Mode),
+			MtimeUnixNs: msg.Time.UnixNano(),
+			Depth:       int32(depthOf(root, msg.Path)),
+		}
+		if p.fileFlags {
+			if info, err := os.Lstat(msg.Path); err == nil {
+				entry.Flags = uint32(fileFlagsOf(msg.Path, info))
+			}
+		}
+		return p.Write(entry)
+	}
+}
+
+// ReadProtoStream reads the entries written by a ProtoStreamWriter from r,
+// passing each to fn, until the end of the stream or an error from fn.
+func ReadProtoStream(r io.Reader, fn func(*stridepb.Entry) error) error {
+	br := bufio.NewReaderSize(r, 64*1024)
+	for {
+		entry := &stridepb.Entry{}
+		if err := protodelim.UnmarshalFrom(br, entry); err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return err
+		}
+		if err := fn(entry); err != nil {
+			return err
+		}
+	}
+}
+
+// statMode returns mode as the st_mode field of stat(2) holds it.
+func statMode(mode os.FileMode) uint32 {
+	bits := unixPermissions(mode)
+	switch {
+	case mode.IsDir():
+		bits |= statIFDIR
+	case mode&os.ModeSymlink != 0:
+		bits |= statIFLNK
+	case mode&os.ModeNamedPipe != 0:
+		bits |= statIFIFO
+	case mode&os.ModeSocket != 0:
+		bits |= statIFSOCK
+	case mode&os.ModeCharDevice != 0:
+		bits |= statIFCHR
+	case mode&os.ModeDevice != 0:
+		bits |= statIFBLK
+	default:
+		bits |= statIFREG
+	}
+	return bits
+}
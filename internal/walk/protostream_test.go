@@ -0,0 +1,150 @@
+package stride
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"os"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/TFMV/stride/proto/stridepb"
+	"github.com/TFMV/stride/walk/walktest"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestProtoStreamRoundTrip(t *testing.T) {
+	mtime := time.Date(2024, 3, 1, 12, 30, 45, 123456789, time.UTC)
+	root := walktest.Tree{
+		"a.txt":         walktest.File{Content: "alpha", ModTime: mtime},
+		"sub/b.bin":     walktest.File{Content: "bravo bravo", Mode: 0600, ModTime: mtime},
+		"sub/deep/c.go": walktest.File{Content: "package c", Mode: 0755, ModTime: mtime},
+		"empty":         walktest.Dir{},
+	}.Build(t)
+
+	var buf bytes.Buffer
+	writer := NewProtoStreamWriter(&buf)
+	var mu sync.Mutex
+	var expected []*stridepb.Entry
+	err := WalkWithOptions(root, func(ctx context.Context, path string, info os.FileInfo) error {
+		mu.Lock()
+		defer mu.Unlock()
+		expected = append(expected, &stridepb.Entry{
+			Path:        path,
+			Size:        info.Size(),
+			Mode:        statMode(info.Mode()),
+			MtimeUnixNs: info.ModTime().UnixNano(),
+			Depth:       int32(depthOf(root, path)),
+		})
+		return nil
+	}, WalkOptions{NumWorkers: 2, Middleware: []MiddlewareFunc{writer.Middleware(root)}})
+	if err != nil {
+		t.Fatalf("Failed to walk: %v", err)
+	}
+	if err := writer.Flush(); err != nil {
+		t.Fatalf("Failed to flush: %v", err)
+	}
+
+	var decoded []*stridepb.Entry
+	if err := ReadProtoStream(&buf, func(entry *stridepb.Entry) error {
+		decoded = append(decoded, entry)
+		return nil
+	}); err != nil {
+		t.Fatalf("Failed to read stream: %v", err)
+	}
+
+	byPath := func(entries []*stridepb.Entry) {
+		sort.Slice(entries, func(i, j int) bool { return entries[i].Path < entries[j].Path })
+	}
+	byPath(expected)
+	byPath(decoded)
+	if len(decoded) != len(expected) || len(expected) < 6 {
+		t.Fatalf("Expected %d entries, got %d", len(expected), len(decoded))
+	}
+	for i := range expected {
+		if !proto.Equal(decoded[i], expected[i]) {
+			t.Errorf("Expected entry %v, got %v", expected[i], decoded[i])
+		}
+	}
+
+	// Spot check the encoding of a file against what stat(2) reports
+	for _, entry := range decoded {
+		if relSlashPath(root, entry.Path) != "sub/b.bin" {
+			continue
+		}
+		if entry.Mode != statIFREG|0600 || entry.Size != int64(len("bravo bravo")) || entry.Depth != 2 || entry.MtimeUnixNs != mtime.UnixNano() {
+			t.Errorf("Expected sub/b.bin to be a 0600 file of 11 bytes at depth 2, got %v", entry)
+		}
+	}
+}
+
+func TestReadProtoStreamTruncated(t *testing.T) {
+	var buf bytes.Buffer
+	writer := NewProtoStreamWriter(&buf)
+	for _, path := range []string{"one", "two"} {
+		if err := writer.Write(&stridepb.Entry{Path: path, Size: 42}); err != nil {
+			t.Fatalf("Failed to write entry: %v", err)
+		}
+	}
+	if err := writer.Flush(); err != nil {
+		t.Fatalf("Failed to flush: %v", err)
+	}
+
+	// A stream cut inside an entry is an error, after the entries before it
+	var paths []string
+	err := ReadProtoStream(bytes.NewReader(buf.Bytes()[:buf.Len()-2]), func(entry *stridepb.Entry) error {
+		paths = append(paths, entry.Path)
+		return nil
+	})
+	if err == nil {
+		t.Error("Expected an error for a truncated stream")
+	}
+	if len(paths) != 1 || paths[0] != "one" {
+		t.Errorf("Expected [one] before the error, got %v", paths)
+	}
+}
+
+// benchmarkEntries returns n messages like those of a walk of a large tree.
+func benchmarkEntries(n int) []FindMessage {
+	msgs := make([]FindMessage, n)
+	now := time.Now()
+	for i := range msgs {
+		msgs[i] = FindMessage{
+			Path: "/srv/data/projects/stride/internal/walk/testdata/file" + string(rune('a'+i%26)) + ".go",
+			Size: int64(i * 37),
+			Time: now,
+			Mode: 0644,
+		}
+	}
+	return msgs
+}
+
+func BenchmarkEncodeProto(b *testing.B) {
+	msgs := benchmarkEntries(1000)
+	handler := NewProtoStreamWriter(io.Discard).FindHandler("/srv/data")
+	ctx := context.Background()
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for _, msg := range msgs {
+			if err := handler(ctx, FindResult{Message: msg}); err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+}
+
+func BenchmarkEncodeNDJSON(b *testing.B) {
+	msgs := benchmarkEntries(1000)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for _, msg := range msgs {
+			if err := writeJSONLine(io.Discard, msg); err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+}
@@ -0,0 +1,173 @@
+package stride
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// statRecorder records the entries passed to entryInfo and readDirFn.
+type statRecorder struct {
+	mu      sync.Mutex
+	statted []string
+	read    []string
+}
+
+// recordStats replaces entryInfo and readDirFn with recording versions for
+// the test.
+func recordStats(tb testing.TB) *statRecorder {
+	r := &statRecorder{}
+	origInfo, origRead := entryInfo, readDirFn
+	entryInfo = func(path string, d fs.DirEntry) (fs.FileInfo, error) {
+		r.mu.Lock()
+		r.statted = append(r.statted, path)
+		r.mu.Unlock()
+		return origInfo(path, d)
+	}
+	readDirFn = func(path string) ([]os.DirEntry, error) {
+		r.mu.Lock()
+		r.read = append(r.read, path)
+		r.mu.Unlock()
+		return origRead(path)
+	}
+	tb.Cleanup(func() { entryInfo, readDirFn = origInfo, origRead })
+	return r
+}
+
+// reset forgets what was recorded so far.
+func (r *statRecorder) reset() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.statted, r.read = nil, nil
+}
+
+// createPruneFixture creates dirs directories named node_modules, each
+// holding files files, next to a few files that are kept.
+func createPruneFixture(tb testing.TB, dirs, files int) string {
+	tb.Helper()
+	root := tb.TempDir()
+	var names []string
+	for i := 0; i < dirs; i++ {
+		for j := 0; j < files; j++ {
+			names = append(names, fmt.Sprintf("pkg%d/node_modules/dep/f%d.js", i, j))
+		}
+		names = append(names, fmt.Sprintf("pkg%d/index.js", i))
+	}
+	names = append(names, "gen/out/a.js", "main.js")
+	for _, name := range names {
+		path := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			tb.Fatalf("Failed to create directory: %v", err)
+		}
+		if err := os.WriteFile(path, nil, 0644); err != nil {
+			tb.Fatalf("Failed to create file: %v", err)
+		}
+	}
+	return root
+}
+
+func TestExcludedDirsNeverStatted(t *testing.T) {
+	root := createPruneFixture(t, 3, 5)
+	filter := FilterOptions{
+		ExcludeDir:      []string{"node_modules"},
+		ExcludeDirRegex: []*regexp.Regexp{regexp.MustCompile(`^gen/out$`)},
+	}
+	excluded := func(path string) bool {
+		rel := relSlashPath(root, path)
+		return strings.Contains(rel, "node_modules") || strings.HasPrefix(rel, "gen/out")
+	}
+
+	rec := recordStats(t)
+	walks := map[string]func() error{
+		"options": func() error {
+			return WalkLimitWithOptions(context.Background(), root, func(path string, info os.FileInfo, err error) error {
+				return err
+			}, WalkOptions{Filter: filter, NumWorkers: 2})
+		},
+		"filter": func() error {
+			return WalkLimitWithFilter(context.Background(), root, func(path string, info os.FileInfo, err error) error {
+				return err
+			}, 2, filter)
+		},
+		"incremental": func() error {
+			return WalkLimitWithOptions(context.Background(), root, func(path string, info os.FileInfo, err error) error {
+				return err
+			}, WalkOptions{Filter: filter, NumWorkers: 2, MtimeCache: filepath.Join(t.TempDir(), "cache")})
+		},
+	}
+	for name, walk := range walks {
+		rec.reset()
+		if err := walk(); err != nil {
+			t.Fatalf("%s: walk failed: %v", name, err)
+		}
+		if len(rec.statted)+len(rec.read) == 0 {
+			t.Errorf("%s: expected the walk to be recorded", name)
+		}
+		for _, path := range append(rec.statted, rec.read...) {
+			if excluded(path) {
+				t.Errorf("%s: excluded entry %s was statted or read", name, path)
+			}
+		}
+	}
+}
+
+func TestExcludedDirsBelowMinDepth(t *testing.T) {
+	root := createPruneFixture(t, 2, 1)
+
+	// node_modules sits at depth 2, above the minimum depth of 3
+	var mu sync.Mutex
+	var got []string
+	err := WalkLimitWithOptions(context.Background(), root, func(path string, info os.FileInfo, err error) error {
+		mu.Lock()
+		defer mu.Unlock()
+		got = append(got, relSlashPath(root, path))
+		return err
+	}, WalkOptions{Filter: FilterOptions{ExcludeDir: []string{"node_modules"}, MinDepth: 3}})
+	if err != nil {
+		t.Fatalf("Walk failed: %v", err)
+	}
+	for _, rel := range got {
+		if strings.Contains(rel, "node_modules") {
+			t.Errorf("Expected node_modules to be excluded, got %s", rel)
+		}
+	}
+}
+
+// BenchmarkPruneExcludedDirs compares excluding directories through the
+// filter, which prunes them as they are enumerated, with skipping them from
+// the callback, which only happens once they have been statted.
+func BenchmarkPruneExcludedDirs(b *testing.B) {
+	root := createPruneFixture(b, 200, 20)
+	rec := recordStats(b)
+
+	walkFn := func(path string, info os.FileInfo, err error) error {
+		if err == nil && info.IsDir() && info.Name() == "node_modules" {
+			return filepath.SkipDir
+		}
+		return err
+	}
+	benchmarks := []struct {
+		name string
+		opts WalkOptions
+	}{
+		{"callback", WalkOptions{}},
+		{"filter", WalkOptions{Filter: FilterOptions{ExcludeDir: []string{"node_modules"}}}},
+	}
+	for _, bm := range benchmarks {
+		b.Run(bm.name, func(b *testing.B) {
+			rec.reset()
+			for i := 0; i < b.N; i++ {
+				if err := WalkLimitWithOptions(context.Background(), root, walkFn, bm.opts); err != nil {
+					b.Fatalf("Walk failed: %v", err)
+				}
+			}
+			b.ReportMetric(float64(len(rec.statted))/float64(b.N), "stats/op")
+		})
+	}
+}
@@ -0,0 +1,103 @@
+package stride
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestQueueSize(t *testing.T) {
+	tests := []struct {
+		requested, workers, want int
+	}{
+		{0, 8, 32},
+		{-1, 8, 32},
+		{10, 8, 10},
+		{0, 0, 1},
+		{0, MaxQueueSize, MaxQueueSize},
+		{MaxQueueSize + 1, 8, MaxQueueSize},
+	}
+	for _, tc := range tests {
+		if got := queueSize(tc.requested, tc.workers); got != tc.want {
+			t.Errorf("queueSize(%d, %d) = %d, want %d", tc.requested, tc.workers, got, tc.want)
+		}
+	}
+}
+
+func TestQueueSizeVisitsEverything(t *testing.T) {
+	root := createSlowCallbackFixture(t, 10, 10)
+	for _, size := range []int{1, 3, MaxQueueSize} {
+		var files int64
+		err := WalkLimitWithOptions(context.Background(), root, func(path string, info os.FileInfo, err error) error {
+			if err == nil && !info.IsDir() {
+				atomic.AddInt64(&files, 1)
+			}
+			return err
+		}, WalkOptions{NumWorkers: 4, QueueSize: size})
+		if err != nil {
+			t.Fatalf("Walk with queue size %d failed: %v", size, err)
+		}
+		if files != 100 {
+			t.Errorf("Expected 100 files with queue size %d, got %d", size, files)
+		}
+	}
+}
+
+// createSlowCallbackFixture creates dirs directories of files files each.
+func createSlowCallbackFixture(tb testing.TB, dirs, files int) string {
+	tb.Helper()
+	root := tb.TempDir()
+	for i := 0; i < dirs; i++ {
+		dir := filepath.Join(root, fmt.Sprintf("dir%03d", i))
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			tb.Fatalf("Failed to create directory: %v", err)
+		}
+		for j := 0; j < files; j++ {
+			if err := os.WriteFile(filepath.Join(dir, fmt.Sprintf("f%d", j)), nil, 0644); err != nil {
+				tb.Fatalf("Failed to create file: %v", err)
+			}
+		}
+	}
+	return root
+}
+
+// BenchmarkSlowCallbackQueue walks many small directories with a callback
+// taking 1ms per file, reporting how busy the workers were kept for each
+// queue size. A queue no larger than the pool stalls enumeration whenever
+// every worker is busy.
+func BenchmarkSlowCallbackQueue(b *testing.B) {
+	const workers = 8
+	root := createSlowCallbackFixture(b, 200, 4)
+
+	for _, size := range []int{workers, 0, MaxQueueSize} {
+		name := fmt.Sprintf("queue=%d", queueSize(size, workers))
+		b.Run(name, func(b *testing.B) {
+			var busy int64
+			walkFn := func(path string, info os.FileInfo, err error) error {
+				if err != nil || info.IsDir() {
+					return err
+				}
+				start := time.Now()
+				time.Sleep(time.Millisecond)
+				atomic.AddInt64(&busy, int64(time.Since(start)))
+				return nil
+			}
+			opts := WalkOptions{NumWorkers: workers, QueueSize: size}
+
+			b.ResetTimer()
+			start := time.Now()
+			for i := 0; i < b.N; i++ {
+				if err := WalkLimitWithOptions(context.Background(), root, walkFn, opts); err != nil {
+					b.Fatalf("Walk failed: %v", err)
+				}
+			}
+			elapsed := time.Since(start)
+			b.ReportMetric(100*float64(busy)/float64(elapsed*workers), "busy%")
+			b.ReportMetric(float64(800*b.N)/elapsed.Seconds(), "files/s")
+		})
+	}
+}
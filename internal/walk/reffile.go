@@ -0,0 +1,74 @@
+package stride
+
+import (
+	"fmt"
+	"os"
+	"time"
+)
+
+// referenceTime returns the modification time of the reference file at path,
+// following symbolic links like find -newer.
+func referenceTime(path string) (time.Time, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("reference file: %w", err)
+	}
+	return info.ModTime(), nil
+}
+
+// resolveReferenceFiles reads the reference files of filter once and folds
+// them into ModifiedAfter and ModifiedBefore, keeping whichever bound is more
+// restrictive. Like find -newer, entries must be strictly newer or older than
+// the reference file, so the file itself never matches.
+func resolveReferenceFiles(filter FilterOptions) (FilterOptions, error) {
+	if filter.NewerThanFile != "" {
+		ref, err := referenceTime(filter.NewerThanFile)
+		if err != nil {
+			return filter, err
+		}
+		if after := ref.Add(time.Nanosecond); after.After(filter.ModifiedAfter) {
+			filter.ModifiedAfter = after
+		}
+		filter.NewerThanFile = ""
+	}
+	if filter.OlderThanFile != "" {
+		ref, err := referenceTime(filter.OlderThanFile)
+		if err != nil {
+			return filter, err
+		}
+		if before := ref.Add(-time.Nanosecond); filter.ModifiedBefore.IsZero() || before.Before(filter.ModifiedBefore) {
+			filter.ModifiedBefore = before
+		}
+		filter.OlderThanFile = ""
+	}
+	return filter, nil
+}
+
+// referenceTimes holds the modification times of the reference files of a
+// search, read once when it starts.
+type referenceTimes struct {
+	newer time.Time // FindOptions.NewerThanFile, if set
+	older time.Time // FindOptions.OlderThanFile, if set
+	err   error     // Why a reference file could not be read
+}
+
+// loadReferenceTimes reads the reference files of opts.
+func loadReferenceTimes(opts FindOptions) referenceTimes {
+	var refs referenceTimes
+	if opts.NewerThanFile != "" {
+		refs.newer, refs.err = referenceTime(opts.NewerThanFile)
+	}
+	if opts.OlderThanFile != "" && refs.err == nil {
+		refs.older, refs.err = referenceTime(opts.OlderThanFile)
+	}
+	return refs
+}
+
+// timeDetail explains why msg failed a comparison with the reference time
+// ref, for FindDecision.
+func (r referenceTimes) timeDetail(msg FindMessage, op string, ref time.Time) string {
+	if r.err != nil {
+		return r.err.Error()
+	}
+	return msg.Time.Format(time.RFC3339Nano) + op + ref.Format(time.RFC3339Nano)
+}
@@ -0,0 +1,174 @@
+package stride
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// createReferenceFixture creates a marker file and files modified an hour
+// before and after it, returning the root and the marker's path.
+func createReferenceFixture(t *testing.T) (string, string) {
+	t.Helper()
+	root := t.TempDir()
+	mark := time.Now().Add(-24 * time.Hour).Truncate(time.Second)
+	times := map[string]time.Time{
+		"marker":      mark,
+		"old.txt":     mark.Add(-time.Hour),
+		"sub/old.txt": mark.Add(-time.Hour),
+		"new.txt":     mark.Add(time.Hour),
+		"sub/new.txt": mark.Add(time.Hour),
+		"same.txt":    mark,
+	}
+	for name, mtime := range times {
+		path := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("Failed to create directory: %v", err)
+		}
+		if err := os.WriteFile(path, []byte(name), 0644); err != nil {
+			t.Fatalf("Failed to create file: %v", err)
+		}
+		if err := os.Chtimes(path, mtime, mtime); err != nil {
+			t.Fatalf("Failed to set times: %v", err)
+		}
+	}
+	return root, filepath.Join(root, "marker")
+}
+
+// walkedFiles returns the root-relative paths of the files walked with filter.
+func walkedFiles(t *testing.T, root string, filter FilterOptions, visit func()) []string {
+	t.Helper()
+	var mu sync.Mutex
+	var files []string
+	err := WalkLimitWithOptions(context.Background(), root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if visit != nil {
+			visit()
+		}
+		if !info.IsDir() {
+			mu.Lock()
+			files = append(files, relSlashPath(root, path))
+			mu.Unlock()
+		}
+		return nil
+	}, WalkOptions{Filter: filter, NumWorkers: 1})
+	if err != nil {
+		t.Fatalf("Walk failed: %v", err)
+	}
+	sort.Strings(files)
+	return files
+}
+
+func TestReferenceFileFilters(t *testing.T) {
+	root, marker := createReferenceFixture(t)
+	mark := time.Now().Add(-24 * time.Hour).Truncate(time.Second)
+
+	tests := []struct {
+		name   string
+		filter FilterOptions
+		want   string
+	}{
+		{"newer", FilterOptions{NewerThanFile: marker}, "new.txt,sub/new.txt"},
+		{"older", FilterOptions{OlderThanFile: marker}, "old.txt,sub/old.txt"},
+		{"both", FilterOptions{NewerThanFile: marker, OlderThanFile: marker}, ""},
+		// The later of the two lower bounds applies
+		{"newer and earlier date", FilterOptions{NewerThanFile: marker, ModifiedAfter: mark.Add(-48 * time.Hour)}, "new.txt,sub/new.txt"},
+		{"newer and later date", FilterOptions{NewerThanFile: marker, ModifiedAfter: mark.Add(48 * time.Hour)}, ""},
+		{"older and later date", FilterOptions{OlderThanFile: marker, ModifiedBefore: mark.Add(48 * time.Hour)}, "old.txt,sub/old.txt"},
+		{"older and earlier date", FilterOptions{OlderThanFile: marker, ModifiedBefore: mark.Add(-48 * time.Hour)}, ""},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := strings.Join(walkedFiles(t, root, tc.filter, nil), ","); got != tc.want {
+				t.Errorf("Expected %q, got %q", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestReferenceFileReadOnce(t *testing.T) {
+	root, marker := createReferenceFixture(t)
+
+	// Moving the marker during the walk does not change the comparison
+	var once sync.Once
+	future := time.Now().Add(time.Hour)
+	got := walkedFiles(t, root, FilterOptions{NewerThanFile: marker}, func() {
+		once.Do(func() {
+			if err := os.Chtimes(marker, future, future); err != nil {
+				t.Errorf("Failed to set times: %v", err)
+			}
+		})
+	})
+	// Only the marker itself is now newer than its recorded time
+	if strings.Join(got, ",") != "marker,new.txt,sub/new.txt" {
+		t.Errorf("Expected the marker to be read once, got %v", got)
+	}
+}
+
+func TestReferenceFileMissing(t *testing.T) {
+	root := t.TempDir()
+	missing := filepath.Join(root, "missing")
+	walkFn := func(path string, info os.FileInfo, err error) error { return err }
+
+	if err := WalkLimitWithOptions(context.Background(), root, walkFn, WalkOptions{Filter: FilterOptions{NewerThanFile: missing}}); err == nil || !strings.Contains(err.Error(), "reference file") {
+		t.Errorf("Expected a reference file error from the walk, got %v", err)
+	}
+	if err := WalkLimitWithFilter(context.Background(), root, walkFn, 1, FilterOptions{OlderThanFile: missing}); err == nil {
+		t.Error("Expected an error from WalkLimitWithFilter")
+	}
+	err := Find(context.Background(), root, FindOptions{NewerThanFile: missing}, func(ctx context.Context, result FindResult) error { return nil })
+	if err == nil || !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("Expected a not-exist error from Find, got %v", err)
+	}
+}
+
+func TestFindReferenceFiles(t *testing.T) {
+	root, marker := createReferenceFixture(t)
+
+	find := func(opts FindOptions) string {
+		var mu sync.Mutex
+		var found []string
+		err := Find(context.Background(), root, opts, func(ctx context.Context, result FindResult) error {
+			if result.Error != nil {
+				return result.Error
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			found = append(found, relSlashPath(root, result.Message.Path))
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("Find failed: %v", err)
+		}
+		sort.Strings(found)
+		return strings.Join(found, ",")
+	}
+
+	if got := find(FindOptions{NewerThanFile: marker, MaxDepth: 2}); got != "new.txt,sub/new.txt" {
+		t.Errorf("Expected the newer files, got %q", got)
+	}
+	if got := find(FindOptions{OlderThanFile: marker, NewerThan: 48 * time.Hour, MaxDepth: 2}); got != "old.txt,sub/old.txt" {
+		t.Errorf("Expected the older files, got %q", got)
+	}
+	if got := find(FindOptions{OlderThanFile: marker, NewerThan: time.Hour, MaxDepth: 2}); got != "" {
+		t.Errorf("Expected the stricter duration to reject every file, got %q", got)
+	}
+
+	// Explanations name the reference criterion
+	info, err := os.Stat(filepath.Join(root, "same.txt"))
+	if err != nil {
+		t.Fatalf("Failed to stat file: %v", err)
+	}
+	d := ExplainFind(FindOptions{NewerThanFile: marker}, newFindMessage(filepath.Join(root, "same.txt"), info))
+	if d.Matched || len(d.Predicates) != 1 || d.Predicates[0].Name != "newer_than_file" {
+		t.Errorf("Expected newer_than_file to reject a file as old as the marker, got %s", d)
+	}
+}
@@ -0,0 +1,87 @@
+package stride
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// Windows file attributes and reparse tags the walks look at.
+const (
+	fileAttributeReparsePoint       = 0x00000400
+	fileAttributeRecallOnDataAccess = 0x00400000
+	reparseTagNameSurrogate         = 0x20000000 // Set in the tags of junctions and symlinks
+)
+
+// reparseInfo holds the Windows file attributes of an entry and, for
+// reparse points, their reparse tag. It is zero on other platforms.
+type reparseInfo struct {
+	attributes uint32
+	tag        uint32
+}
+
+// reparseInfoOf reads the reparse information of the entry d at path.
+// Tests replace it to simulate junctions and cloud placeholders.
+var reparseInfoOf = platformReparseInfo
+
+// isJunction reports whether r describes a directory junction, or another
+// reparse point standing for a directory elsewhere, that Go reports as a
+// plain directory.
+func (r reparseInfo) isJunction() bool {
+	return r.attributes&fileAttributeReparsePoint != 0 && r.tag&reparseTagNameSurrogate != 0
+}
+
+// isCloudPlaceholder reports whether r describes a file whose content is
+// fetched from a cloud provider when read, like a OneDrive file that is not
+// kept on the device.
+func (r reparseInfo) isCloudPlaceholder() bool {
+	return r.attributes&fileAttributeRecallOnDataAccess != 0
+}
+
+// isCloudPlaceholder reports whether reading the entry at path would fetch
+// its content from a cloud provider.
+func isCloudPlaceholder(path string, info os.FileInfo) bool {
+	return reparseInfoOf(path, fs.FileInfoToDirEntry(info)).isCloudPlaceholder()
+}
+
+// junctionInfo presents a junction as the symlink it behaves like.
+type junctionInfo struct {
+	os.FileInfo
+}
+
+// Mode returns the junction's mode with the type of a symlink.
+func (i junctionInfo) Mode() fs.FileMode {
+	return i.FileInfo.Mode()&^fs.ModeType | fs.ModeSymlink
+}
+
+// IsDir reports false, as for any symlink.
+func (i junctionInfo) IsDir() bool {
+	return false
+}
+
+// walkDirReparse is filepath.WalkDir, except that junctions below root are
+// passed to fn as symlinks and never descended in place, so the walks treat
+// them according to their SymlinkHandling and follow them with the same
+// cycle detection. Go reports junctions as directories, and walking through
+// one that points at an ancestor would never end.
+func walkDirReparse(root string, fn fs.WalkDirFunc) error {
+	return filepath.WalkDir(root, reparseVisit(root, fn))
+}
+
+// reparseVisit wraps fn for a walk of root, passing it junctions below
+// root as walkDirReparse does.
+func reparseVisit(root string, fn fs.WalkDirFunc) fs.WalkDirFunc {
+	return func(path string, d fs.DirEntry, err error) error {
+		if err == nil && d.IsDir() && path != root && reparseInfoOf(path, d).isJunction() {
+			info, err := d.Info()
+			if err != nil {
+				return fn(path, d, err)
+			}
+			if ret := fn(path, fs.FileInfoToDirEntry(junctionInfo{info}), nil); ret != nil {
+				return ret
+			}
+			return filepath.SkipDir
+		}
+		return fn(path, d, err)
+	}
+}
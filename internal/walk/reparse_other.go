@@ -0,0 +1,12 @@
+//go:build !windows
+
+package stride
+
+import (
+	"io/fs"
+)
+
+// platformReparseInfo reports nothing: only Windows has reparse points.
+func platformReparseInfo(path string, d fs.DirEntry) reparseInfo {
+	return reparseInfo{}
+}
@@ -0,0 +1,186 @@
+package stride
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"sync"
+	"testing"
+
+	"github.com/TFMV/stride/walk/walktest"
+)
+
+// Reparse tags seen on Windows
+const (
+	tagMountPoint = 0xA0000003
+	tagSymlink    = 0xA000000C
+	tagCloud      = 0x9000001A
+	tagDedup      = 0x80000013
+)
+
+// fakeReparseInfo replaces reparseInfoOf with the infos given by path for
+// the duration of the test.
+func fakeReparseInfo(t *testing.T, infos map[string]reparseInfo) {
+	orig := reparseInfoOf
+	reparseInfoOf = func(path string, d fs.DirEntry) reparseInfo {
+		return infos[path]
+	}
+	t.Cleanup(func() { reparseInfoOf = orig })
+}
+
+func TestReparseInfo(t *testing.T) {
+	tests := []struct {
+		name        string
+		info        reparseInfo
+		junction    bool
+		placeholder bool
+	}{
+		{"Plain", reparseInfo{}, false, false},
+		{"Junction", reparseInfo{fileAttributeReparsePoint, tagMountPoint}, true, false},
+		{"Symlink", reparseInfo{fileAttributeReparsePoint, tagSymlink}, true, false},
+		{"Tag without attribute", reparseInfo{0, tagMountPoint}, false, false},
+		{"Dedup", reparseInfo{fileAttributeReparsePoint, tagDedup}, false, false},
+		{"Cloud", reparseInfo{fileAttributeReparsePoint | fileAttributeRecallOnDataAccess, tagCloud}, false, true},
+		{"Recall only", reparseInfo{fileAttributeRecallOnDataAccess, 0}, false, true},
+	}
+	for _, tt := range tests {
+		if got := tt.info.isJunction(); got != tt.junction {
+			t.Errorf("%s: expected isJunction to be %v, got %v", tt.name, tt.junction, got)
+		}
+		if got := tt.info.isCloudPlaceholder(); got != tt.placeholder {
+			t.Errorf("%s: expected isCloudPlaceholder to be %v, got %v", tt.name, tt.placeholder, got)
+		}
+	}
+}
+
+func TestJunctionWalk(t *testing.T) {
+	root := walktest.Tree{
+		"a/1.txt":     walktest.File{},
+		"j/2.txt":     walktest.File{},
+		"j/sub/3.txt": walktest.File{},
+	}.Build(t)
+	fakeReparseInfo(t, map[string]reparseInfo{
+		filepath.Join(root, "j"): {fileAttributeReparsePoint, tagMountPoint},
+	})
+
+	tests := []struct {
+		handling SymlinkHandling
+		want     []string
+	}{
+		{SymlinkIgnore, []string{".", "a", "a/1.txt"}},
+		{SymlinkReport, []string{".", "a", "a/1.txt", "j"}},
+	}
+	for _, tt := range tests {
+		var mu sync.Mutex
+		var seen []string
+		var junctionMode fs.FileMode
+		record := func(path string, mode fs.FileMode) {
+			mu.Lock()
+			defer mu.Unlock()
+			seen = append(seen, relSlashPath(root, path))
+			if filepath.Base(path) == "j" {
+				junctionMode = mode
+			}
+		}
+
+		_, err := WalkLimitWithOptionsStats(context.Background(), root, func(path string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			record(path, info.Mode())
+			return nil
+		}, WalkOptions{NumWorkers: 2, SymlinkHandling: tt.handling})
+		if err != nil {
+			t.Fatalf("Walk failed: %v", err)
+		}
+		sort.Strings(seen)
+		if !reflect.DeepEqual(seen, tt.want) {
+			t.Errorf("%v: expected %q, got %q", tt.handling, tt.want, seen)
+		}
+		if tt.handling == SymlinkReport && junctionMode&fs.ModeType != fs.ModeSymlink {
+			t.Errorf("Expected the junction to be reported as a symlink, got mode %v", junctionMode)
+		}
+
+		// WalkDir sees the same entries
+		seen = nil
+		err = WalkDir(root, func(ctx context.Context, path string, d fs.DirEntry) error {
+			record(path, d.Type())
+			return nil
+		}, WalkOptions{NumWorkers: 2, SymlinkHandling: tt.handling})
+		if err != nil {
+			t.Fatalf("WalkDir failed: %v", err)
+		}
+		sort.Strings(seen)
+		if !reflect.DeepEqual(seen, tt.want) {
+			t.Errorf("WalkDir %v: expected %q, got %q", tt.handling, tt.want, seen)
+		}
+	}
+}
+
+func TestCloudPlaceholderFind(t *testing.T) {
+	root := walktest.Tree{
+		"local.txt": walktest.File{Content: "local"},
+		"cloud.bin": walktest.File{Content: "remote"},
+		"empty/":    walktest.Dir{},
+		"cloudy/":   walktest.Dir{},
+	}.Build(t)
+	fakeReparseInfo(t, map[string]reparseInfo{
+		filepath.Join(root, "cloud.bin"): {fileAttributeReparsePoint | fileAttributeRecallOnDataAccess, tagCloud},
+		filepath.Join(root, "cloudy"):    {fileAttributeRecallOnDataAccess, 0},
+	})
+
+	sum := sha256.Sum256([]byte("local"))
+	list := filepath.Join(t.TempDir(), "list.txt")
+	if err := os.WriteFile(list, []byte(hex.EncodeToString(sum[:])+"\n"), 0644); err != nil {
+		t.Fatalf("Failed to create hash list: %v", err)
+	}
+
+	find := func(opts FindOptions) map[string]map[string]string {
+		var mu sync.Mutex
+		found := make(map[string]map[string]string)
+		err := Find(context.Background(), root, opts, func(ctx context.Context, result FindResult) error {
+			if result.Error != nil {
+				return result.Error
+			}
+			mu.Lock()
+			found[result.Message.Name] = result.Message.Metadata
+			mu.Unlock()
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("Find failed: %v", err)
+		}
+		return found
+	}
+
+	// Placeholders are reported unhashed
+	found := find(FindOptions{HashList: list})
+	if len(found) != 2 || found["local.txt"]["sha256"] == "" {
+		t.Fatalf("Expected local.txt hashed and cloud.bin, got %v", found)
+	}
+	if meta := found["cloud.bin"]; meta["cloud_placeholder"] != "true" || meta["sha256"] != "" {
+		t.Errorf("Expected cloud.bin to be marked and unhashed, got %v", meta)
+	}
+
+	// Placeholder directories are not listed to check they are empty
+	found = find(FindOptions{Empty: true})
+	if _, ok := found["empty"]; !ok || len(found) != 1 {
+		t.Errorf("Expected only the empty directory, got %v", found)
+	}
+
+	// Unless they may be read
+	read := false
+	found = find(FindOptions{HashList: list, SkipCloudPlaceholders: &read})
+	if _, ok := found["cloud.bin"]; ok || len(found) != 1 {
+		t.Errorf("Expected cloud.bin to be hashed and dropped, got %v", found)
+	}
+	found = find(FindOptions{Empty: true, SkipCloudPlaceholders: &read})
+	if len(found) != 2 || found["cloudy"]["cloud_placeholder"] != "" {
+		t.Errorf("Expected both empty directories unmarked, got %v", found)
+	}
+}
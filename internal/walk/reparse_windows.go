@@ -0,0 +1,39 @@
+package stride
+
+import (
+	"io/fs"
+	"syscall"
+
+	"golang.org/x/sys/windows"
+)
+
+// platformReparseInfo reads the attributes of d from its directory listing,
+// and the reparse tag of reparse points with FindFirstFile, since only its
+// find data carries it.
+func platformReparseInfo(path string, d fs.DirEntry) reparseInfo {
+	info, err := d.Info()
+	if err != nil {
+		return reparseInfo{}
+	}
+	data, ok := info.Sys().(*syscall.Win32FileAttributeData)
+	if !ok {
+		return reparseInfo{}
+	}
+	r := reparseInfo{attributes: data.FileAttributes}
+	if r.attributes&fileAttributeReparsePoint == 0 {
+		return r
+	}
+
+	p, err := windows.UTF16PtrFromString(path)
+	if err != nil {
+		return r
+	}
+	var find windows.Win32finddata
+	h, err := windows.FindFirstFile(p, &find)
+	if err != nil {
+		return r
+	}
+	windows.FindClose(h)
+	r.tag = find.Reserved0
+	return r
+}
@@ -0,0 +1,75 @@
+package stride
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/TFMV/stride/walk/walktest"
+)
+
+// mklinkJunction creates a junction at link pointing to target.
+func mklinkJunction(t *testing.T, link, target string) {
+	if out, err := exec.Command("cmd", "/c", "mklink", "/J", link, target).CombinedOutput(); err != nil {
+		t.Skipf("Cannot create junctions: %v: %s", err, out)
+	}
+}
+
+func TestJunctionCycle(t *testing.T) {
+	root := walktest.Tree{
+		"a/1.txt": walktest.File{},
+	}.Build(t)
+
+	// a/up points back at the root
+	mklinkJunction(t, filepath.Join(root, "a", "up"), root)
+	if !reparseInfoOf(filepath.Join(root, "a", "up"), mustDirEntry(t, filepath.Join(root, "a"), "up")).isJunction() {
+		t.Fatal("Expected a/up to be detected as a junction")
+	}
+
+	for _, handling := range []SymlinkHandling{SymlinkIgnore, SymlinkReport, SymlinkFollow} {
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		var mu sync.Mutex
+		seen := make(map[string]int)
+		_, err := WalkLimitWithOptionsStats(ctx, root, func(path string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			mu.Lock()
+			seen[relSlashPath(root, path)]++
+			mu.Unlock()
+			return nil
+		}, WalkOptions{NumWorkers: 2, SymlinkHandling: handling})
+		cancel()
+		if err != nil {
+			t.Fatalf("%v: walk failed: %v", handling, err)
+		}
+		if seen["a/1.txt"] != 1 {
+			t.Errorf("%v: expected a/1.txt once, got %v", handling, seen)
+		}
+		if handling == SymlinkFollow && seen["a/up/a/1.txt"] != 1 {
+			t.Errorf("%v: expected the junction to be followed once, got %v", handling, seen)
+		}
+		if len(seen) > 8 {
+			t.Errorf("%v: expected the cycle to end, got %v", handling, seen)
+		}
+	}
+}
+
+// mustDirEntry returns the entry named name in the listing of dir.
+func mustDirEntry(t *testing.T, dir, name string) os.DirEntry {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("Failed to read %s: %v", dir, err)
+	}
+	for _, e := range entries {
+		if e.Name() == name {
+			return e
+		}
+	}
+	t.Fatalf("No entry %s in %s", name, dir)
+	return nil
+}
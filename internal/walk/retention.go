@@ -0,0 +1,119 @@
+package stride
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// RetentionPolicy describes which files matching a pattern should be kept.
+type RetentionPolicy struct {
+	Pattern          string        // Glob pattern matched against file names (e.g. "*.log")
+	OlderThan        time.Duration // Only files older than this are actioned
+	KeepNewestPerDir int           // Newest matches in each directory that are always kept
+	MinTotalKeep     int           // Minimum number of matches kept across the whole tree
+	IncludeHidden    bool          // Whether to consider hidden files
+}
+
+// RetentionAction is invoked for each file the policy does not keep.
+// Typically it deletes the file; a no-op or logging action gives a dry run.
+type RetentionAction func(ctx context.Context, path string, info os.FileInfo) error
+
+// retentionCandidate is a file matched by a retention policy.
+type retentionCandidate struct {
+	path string
+	info os.FileInfo
+}
+
+// ApplyRetention finds the files under root matching policy.Pattern, keeps the
+// newest KeepNewestPerDir in each directory and at least MinTotalKeep overall,
+// and calls action for every remaining match older than OlderThan. Actions run
+// sequentially in path order; the first action error stops the run.
+func ApplyRetention(ctx context.Context, root string, policy RetentionPolicy, action RetentionAction) error {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	var mu sync.Mutex
+	byDir := make(map[string][]retentionCandidate)
+	opts := FindOptions{
+		NamePattern:   policy.Pattern,
+		MaxDepth:      ^uint(0), // No depth limit
+		IncludeHidden: policy.IncludeHidden,
+	}
+	err := Find(ctx, root, opts, func(ctx context.Context, result FindResult) error {
+		if result.Error != nil {
+			return result.Error
+		}
+		info, err := os.Lstat(result.Message.Path)
+		if err != nil || !info.Mode().IsRegular() {
+			return nil
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		dir := filepath.Dir(result.Message.Path)
+		byDir[dir] = append(byDir[dir], retentionCandidate{path: result.Message.Path, info: info})
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	expired := retentionExpired(byDir, policy, time.Now())
+	for _, c := range expired {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := action(ctx, c.path, c.info); err != nil {
+			return fmt.Errorf("retention action on %s: %w", c.path, err)
+		}
+	}
+	return nil
+}
+
+// retentionExpired selects the matches that policy does not keep, in path order.
+func retentionExpired(byDir map[string][]retentionCandidate, policy RetentionPolicy, now time.Time) []retentionCandidate {
+	var expired []retentionCandidate
+	total := 0
+	for _, files := range byDir {
+		total += len(files)
+		sortNewestFirst(files)
+		for i, c := range files {
+			if i < policy.KeepNewestPerDir {
+				continue
+			}
+			if now.Sub(c.info.ModTime()) > policy.OlderThan {
+				expired = append(expired, c)
+			}
+		}
+	}
+
+	// Spare the newest expired files until the overall minimum is kept
+	if spare := policy.MinTotalKeep - (total - len(expired)); spare > 0 {
+		sortNewestFirst(expired)
+		if spare > len(expired) {
+			spare = len(expired)
+		}
+		expired = expired[spare:]
+	}
+
+	sort.Slice(expired, func(i, j int) bool {
+		return expired[i].path < expired[j].path
+	})
+	return expired
+}
+
+// sortNewestFirst orders files by modification time, newest first.
+func sortNewestFirst(files []retentionCandidate) {
+	sort.Slice(files, func(i, j int) bool {
+		ti, tj := files[i].info.ModTime(), files[j].info.ModTime()
+		if !ti.Equal(tj) {
+			return ti.After(tj)
+		}
+		return files[i].path < files[j].path
+	})
+}
@@ -0,0 +1,110 @@
+package stride
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// createDatedLogs creates count log files in dir, file i being i days old.
+func createDatedLogs(t *testing.T, dir string, count int) {
+	t.Helper()
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatalf("Failed to create directory: %v", err)
+	}
+	now := time.Now()
+	for i := 0; i < count; i++ {
+		path := filepath.Join(dir, fmt.Sprintf("app-%02d.log", i))
+		if err := os.WriteFile(path, []byte("log"), 0644); err != nil {
+			t.Fatalf("Failed to create file: %v", err)
+		}
+		mtime := now.Add(-time.Duration(i) * 24 * time.Hour)
+		if err := os.Chtimes(path, mtime, mtime); err != nil {
+			t.Fatalf("Failed to set file time: %v", err)
+		}
+	}
+}
+
+func TestApplyRetention(t *testing.T) {
+	tests := []struct {
+		name     string
+		policy   RetentionPolicy
+		expected []string
+	}{
+		{
+			// Files 4-9 are older than 3.5 days; the newest 3 are 0-2
+			name:     "Older than with keep",
+			policy:   RetentionPolicy{Pattern: "*.log", OlderThan: 84 * time.Hour, KeepNewestPerDir: 3},
+			expected: []string{"app-04.log", "app-05.log", "app-06.log", "app-07.log", "app-08.log", "app-09.log"},
+		},
+		{
+			name:     "Keep protects old files",
+			policy:   RetentionPolicy{Pattern: "*.log", OlderThan: 84 * time.Hour, KeepNewestPerDir: 8},
+			expected: []string{"app-08.log", "app-09.log"},
+		},
+		{
+			name:     "Minimum total keep",
+			policy:   RetentionPolicy{Pattern: "*.log", OlderThan: 84 * time.Hour, MinTotalKeep: 7},
+			expected: []string{"app-07.log", "app-08.log", "app-09.log"},
+		},
+		{
+			name:     "Pattern mismatch",
+			policy:   RetentionPolicy{Pattern: "*.txt", OlderThan: time.Hour},
+			expected: nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			root := t.TempDir()
+			createDatedLogs(t, root, 10)
+
+			var mu sync.Mutex
+			var actioned []string
+			err := ApplyRetention(context.Background(), root, tt.policy, func(ctx context.Context, path string, info os.FileInfo) error {
+				mu.Lock()
+				actioned = append(actioned, filepath.Base(path))
+				mu.Unlock()
+				return nil
+			})
+			if err != nil {
+				t.Fatalf("ApplyRetention failed: %v", err)
+			}
+			if strings.Join(actioned, ",") != strings.Join(tt.expected, ",") {
+				t.Errorf("Expected %v, got %v", tt.expected, actioned)
+			}
+		})
+	}
+}
+
+func TestApplyRetentionPerDirectory(t *testing.T) {
+	root := t.TempDir()
+	createDatedLogs(t, filepath.Join(root, "a"), 5)
+	createDatedLogs(t, filepath.Join(root, "b", "nested"), 5)
+
+	var actioned []string
+	policy := RetentionPolicy{Pattern: "*.log", OlderThan: time.Hour, KeepNewestPerDir: 3}
+	err := ApplyRetention(context.Background(), root, policy, func(ctx context.Context, path string, info os.FileInfo) error {
+		rel, _ := filepath.Rel(root, path)
+		actioned = append(actioned, filepath.ToSlash(rel))
+		return os.Remove(path)
+	})
+	if err != nil {
+		t.Fatalf("ApplyRetention failed: %v", err)
+	}
+
+	expected := []string{"a/app-03.log", "a/app-04.log", "b/nested/app-03.log", "b/nested/app-04.log"}
+	if strings.Join(actioned, ",") != strings.Join(expected, ",") {
+		t.Errorf("Expected %v, got %v", expected, actioned)
+	}
+	for _, rel := range expected {
+		if _, err := os.Stat(filepath.Join(root, rel)); !os.IsNotExist(err) {
+			t.Errorf("Expected %s to be deleted", rel)
+		}
+	}
+}
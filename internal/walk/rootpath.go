@@ -0,0 +1,101 @@
+package stride
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// errEmptyRoot is returned when a walk is given no root.
+var errEmptyRoot = errors.New("stride: empty root")
+
+// normalizeRoot returns the form of root that every entry point walks and
+// measures paths against: cleaned, so "./src/" and "src" are the same root,
+// with no trailing separator. Relative roots stay relative so that the
+// paths passed to callbacks keep the caller's form; depthOf makes both sides
+// absolute when only one of them is.
+func normalizeRoot(root string) (string, error) {
+	if root == "" {
+		return "", errEmptyRoot
+	}
+	root = filepath.Clean(root)
+
+	// Clean keeps the separator of a filesystem root such as "/" or `C:\`
+	if trimmed := strings.TrimRight(root, string(os.PathSeparator)); trimmed != "" && trimmed != filepath.VolumeName(root) {
+		root = trimmed
+	}
+	return root, nil
+}
+
+// checkRoot returns the error of an Lstat of root, so that a root that
+// does not exist fails every entry point with an error matching
+// fs.ErrNotExist whatever its error handling. A root that exists but is not
+// a directory is walked as a single entry, like filepath.Walk does: the
+// filters apply to it, and a link is resolved or not per SymlinkHandling.
+func checkRoot(root string) error {
+	_, err := os.Lstat(root)
+	return err
+}
+
+// Delays between the checks of waitForRoot, doubling from the first to the
+// last.
+const (
+	rootPollFirst = 10 * time.Millisecond
+	rootPollMax   = time.Second
+)
+
+// waitForRoot is checkRoot for a root that may not exist yet: while it
+// does not, it is checked again, at growing intervals, until it appears,
+// wait runs out or ctx is done. A root still missing after wait fails with
+// an error matching fs.ErrNotExist; a wait of 0 or less checks it once.
+func waitForRoot(ctx context.Context, root string, wait time.Duration) error {
+	err := checkRoot(root)
+	if wait <= 0 || !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	deadline := time.Now().Add(wait)
+	for delay := rootPollFirst; ; delay = min(2*delay, rootPollMax) {
+		left := time.Until(deadline)
+		if left <= 0 {
+			return fmt.Errorf("stride: root did not appear within %v: %w", wait, err)
+		}
+		timer := time.NewTimer(min(delay, left))
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		}
+		if err = checkRoot(root); !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+	}
+}
+
+// depthOf returns the depth of path below root: 0 for the root itself, 1
+// for its entries, and so on, or -1 if path is not below root.
+func depthOf(root, path string) int {
+	root, path = filepath.Clean(root), filepath.Clean(path)
+	if filepath.IsAbs(root) != filepath.IsAbs(path) {
+		var err error
+		if root, err = filepath.Abs(root); err != nil {
+			return -1
+		}
+		if path, err = filepath.Abs(path); err != nil {
+			return -1
+		}
+	}
+	rel, err := filepath.Rel(root, path)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(os.PathSeparator)) {
+		return -1
+	}
+	if rel == "." {
+		return 0
+	}
+	return strings.Count(rel, string(os.PathSeparator)) + 1
+}
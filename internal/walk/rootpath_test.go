@@ -0,0 +1,314 @@
+package stride
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestNormalizeRoot(t *testing.T) {
+	sep := string(os.PathSeparator)
+	tests := []struct {
+		root, want string
+	}{
+		{".", "."},
+		{"." + sep, "."},
+		{"." + sep + "x" + sep, "x"},
+		{"x" + sep + sep, "x"},
+		{"x" + sep + "." + sep + "y", filepath.Join("x", "y")},
+		{sep, sep},
+		{sep + "data" + sep, sep + "data"},
+		{filepath.FromSlash("a/b/"), filepath.Join("a", "b")},
+	}
+	for _, tc := range tests {
+		got, err := normalizeRoot(tc.root)
+		if err != nil {
+			t.Errorf("normalizeRoot(%q) failed: %v", tc.root, err)
+			continue
+		}
+		if got != tc.want {
+			t.Errorf("normalizeRoot(%q) = %q, want %q", tc.root, got, tc.want)
+		}
+	}
+
+	if _, err := normalizeRoot(""); err == nil {
+		t.Error("Expected an error for an empty root")
+	}
+}
+
+func TestNormalizeRootWindows(t *testing.T) {
+	if runtime.GOOS != "windows" {
+		t.Skip("Backslashes are only separators on Windows")
+	}
+	tests := []struct {
+		root, want string
+	}{
+		{`C:\`, `C:\`},
+		{`C:/data/`, `C:\data`},
+		{`.\x/y\`, `x\y`},
+		{`a/b\c`, `a\b\c`},
+	}
+	for _, tc := range tests {
+		if got, _ := normalizeRoot(tc.root); got != tc.want {
+			t.Errorf("normalizeRoot(%q) = %q, want %q", tc.root, got, tc.want)
+		}
+	}
+}
+
+func TestDepthOf(t *testing.T) {
+	sep := string(os.PathSeparator)
+	tests := []struct {
+		root, path string
+		want       int
+	}{
+		{"/data", "/data", 0},
+		{"/data/", "/data", 0},
+		{"/data", "/data" + sep + "a", 1},
+		{"/data", "/data" + sep + "a" + sep + "b", 2},
+		{".", ".", 0},
+		{".", "a", 1},
+		{".", "a" + sep + "b", 2},
+		{"." + sep, "a", 1},
+		{"." + sep + "x" + sep, "x", 0},
+		{"." + sep + "x" + sep, "x" + sep + "a", 1},
+		{"x", "." + sep + "x" + sep + "a" + sep, 1},
+		{filepath.FromSlash("a/b/"), filepath.FromSlash("a/b/c/d"), 2},
+		{"x", "y", -1},
+		{"x", "..", -1},
+		{"/data", "/other", -1},
+		{"/data", "/database", -1},
+	}
+	for _, tc := range tests {
+		if got := depthOf(filepath.FromSlash(tc.root), filepath.FromSlash(tc.path)); got != tc.want {
+			t.Errorf("depthOf(%q, %q) = %d, want %d", tc.root, tc.path, got, tc.want)
+		}
+	}
+}
+
+func TestDepthOfMixedRoots(t *testing.T) {
+	dir := t.TempDir()
+	t.Chdir(dir)
+
+	// A relative root measures absolute paths beneath the working directory
+	if got := depthOf(".", filepath.Join(dir, "a", "b")); got != 2 {
+		t.Errorf("Expected depth 2, got %d", got)
+	}
+	if got := depthOf(dir, filepath.Join("a", "b")); got != 2 {
+		t.Errorf("Expected depth 2, got %d", got)
+	}
+}
+
+// TestMaxDepthRootForms checks that every spelling of a root walks the same
+// entries at the same depth.
+func TestMaxDepthRootForms(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"src/a.go", "src/pkg/b.go", "src/pkg/sub/c.go"} {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("Failed to create directory: %v", err)
+		}
+		if err := os.WriteFile(path, nil, 0644); err != nil {
+			t.Fatalf("Failed to create file: %v", err)
+		}
+	}
+	t.Chdir(dir)
+
+	sep := string(os.PathSeparator)
+	walked := func(root string) []string {
+		var mu sync.Mutex
+		var names []string
+		err := WalkLimitWithOptions(context.Background(), root, func(path string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			names = append(names, info.Name())
+			return nil
+		}, WalkOptions{Filter: FilterOptions{MaxDepth: 1}, NumWorkers: 2})
+		if err != nil {
+			t.Fatalf("Walk of %q failed: %v", root, err)
+		}
+		sort.Strings(names)
+		return names
+	}
+	found := func(root string) []string {
+		var mu sync.Mutex
+		var names []string
+		err := Find(context.Background(), root, FindOptions{MaxDepth: 1}, func(ctx context.Context, result FindResult) error {
+			mu.Lock()
+			defer mu.Unlock()
+			names = append(names, result.Message.Name)
+			return result.Error
+		})
+		if err != nil {
+			t.Fatalf("Find in %q failed: %v", root, err)
+		}
+		sort.Strings(names)
+		return names
+	}
+
+	wantWalk := walked("src")
+	wantFind := found("src")
+	if strings.Join(wantFind, ",") != "a.go" {
+		t.Errorf("Expected find to reach depth 1, got %v", wantFind)
+	}
+	for _, root := range []string{"." + sep + "src" + sep, "src" + sep, "src" + sep + sep, filepath.Join(dir, "src") + sep} {
+		if got := walked(root); strings.Join(got, ",") != strings.Join(wantWalk, ",") {
+			t.Errorf("Walk of %q visited %v, want %v", root, got, wantWalk)
+		}
+		if got := found(root); strings.Join(got, ",") != strings.Join(wantFind, ",") {
+			t.Errorf("Find in %q matched %v, want %v", root, got, wantFind)
+		}
+	}
+}
+
+func FuzzDepthOf(f *testing.F) {
+	f.Add(".", "a")
+	f.Add("./x/", "a/b")
+	f.Add("/data/", "a/b/c")
+	f.Add("a/b", "")
+	f.Add("a\\b", "c/./d/../e")
+
+	f.Fuzz(func(t *testing.T, root, sub string) {
+		if root == "" || strings.ContainsRune(root+sub, 0) {
+			t.Skip()
+		}
+		path := filepath.Join(root, sub)
+		rel, err := filepath.Rel(filepath.Clean(root), path)
+		if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(os.PathSeparator)) {
+			t.Skip()
+		}
+
+		want := 0
+		if rel != "." {
+			want = len(strings.Split(rel, string(os.PathSeparator)))
+		}
+		if got := depthOf(root, path); got != want {
+			t.Errorf("depthOf(%q, %q) = %d, want %d (rel %q)", root, path, got, want, rel)
+		}
+
+		// The spelling of the root does not change the depth
+		normalized, err := normalizeRoot(root)
+		if err != nil {
+			t.Fatalf("normalizeRoot(%q) failed: %v", root, err)
+		}
+		if got := depthOf(normalized, path); got != want {
+			t.Errorf("depthOf(%q, %q) = %d, want %d", normalized, path, got, want)
+		}
+		if got := depthOf(root+string(os.PathSeparator), path); got != want {
+			t.Errorf("depthOf(%q, %q) = %d, want %d", root+string(os.PathSeparator), path, got, want)
+		}
+	})
+}
+
+// createRootLater moves a directory holding files into place at root once
+// after has passed, so that it appears whole. The returned channel reports
+// the error of doing so.
+func createRootLater(t *testing.T, root string, after time.Duration, files ...string) <-chan error {
+	t.Helper()
+	staging := filepath.Join(t.TempDir(), "staging")
+	for _, name := range files {
+		path := filepath.Join(staging, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("Failed to create directory: %v", err)
+		}
+		if err := os.WriteFile(path, []byte("x"), 0644); err != nil {
+			t.Fatalf("Failed to create file: %v", err)
+		}
+	}
+	created := make(chan error, 1)
+	timer := time.AfterFunc(after, func() {
+		created <- os.Rename(staging, root)
+	})
+	t.Cleanup(func() { timer.Stop() })
+	return created
+}
+
+func TestWaitForRoot(t *testing.T) {
+	t.Run("appears", func(t *testing.T) {
+		root := filepath.Join(t.TempDir(), "later")
+		created := createRootLater(t, root, 200*time.Millisecond, "a.txt", "sub/b.txt")
+
+		var mu sync.Mutex
+		var paths []string
+		start := time.Now()
+		err := WalkLimitWithOptions(context.Background(), root, func(path string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			mu.Lock()
+			paths = append(paths, relSlashPath(root, path))
+			mu.Unlock()
+			return nil
+		}, WalkOptions{WaitForRoot: 5 * time.Second})
+		if err != nil {
+			t.Fatalf("Failed to walk a root that appeared: %v", err)
+		}
+		if err := <-created; err != nil {
+			t.Fatalf("Failed to create the root: %v", err)
+		}
+		if elapsed := time.Since(start); elapsed < 200*time.Millisecond {
+			t.Errorf("Expected the walk to wait for the root, it returned after %v", elapsed)
+		}
+		sort.Strings(paths)
+		want := []string{".", "a.txt", "sub", "sub/b.txt"}
+		if strings.Join(paths, ",") != strings.Join(want, ",") {
+			t.Errorf("Expected %v, got %v", want, paths)
+		}
+	})
+
+	t.Run("never appears", func(t *testing.T) {
+		root := filepath.Join(t.TempDir(), "never")
+		const wait = 300 * time.Millisecond
+		start := time.Now()
+		err := WalkLimitWithOptions(context.Background(), root, func(path string, info os.FileInfo, err error) error {
+			t.Errorf("Expected no callback, got %s", path)
+			return nil
+		}, WalkOptions{WaitForRoot: wait})
+		elapsed := time.Since(start)
+		if !errors.Is(err, fs.ErrNotExist) {
+			t.Fatalf("Expected an error matching fs.ErrNotExist, got %v", err)
+		}
+		if elapsed < wait {
+			t.Errorf("Expected the walk to wait %v, it returned after %v", wait, elapsed)
+		}
+		if elapsed > wait+2*time.Second {
+			t.Errorf("Expected the walk to give up after %v, it took %v", wait, elapsed)
+		}
+	})
+
+	t.Run("cancelled", func(t *testing.T) {
+		root := filepath.Join(t.TempDir(), "never")
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+		defer cancel()
+		start := time.Now()
+		err := WalkDir(root, func(ctx context.Context, path string, d fs.DirEntry) error {
+			return nil
+		}, WalkOptions{Context: ctx, WaitForRoot: time.Minute})
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("Expected the cancelled context's error, got %v", err)
+		}
+		if elapsed := time.Since(start); elapsed > 2*time.Second {
+			t.Errorf("Expected the wait to end with the context, it took %v", elapsed)
+		}
+	})
+
+	t.Run("not waited for", func(t *testing.T) {
+		root := filepath.Join(t.TempDir(), "never")
+		err := WalkLimitWithOptions(context.Background(), root, func(path string, info os.FileInfo, err error) error {
+			return nil
+		}, WalkOptions{})
+		if !errors.Is(err, fs.ErrNotExist) {
+			t.Errorf("Expected an error matching fs.ErrNotExist, got %v", err)
+		}
+	})
+}
@@ -0,0 +1,226 @@
+package stride
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// CanonicalRoots returns the distinct roots to walk for roots, sorted by
+// their absolute paths. A root equal to or beneath another root is dropped,
+// since walking the outer root visits it, and reported to collapsed, unless
+// it is nil. Each kept root keeps its original form, normalized.
+func CanonicalRoots(roots []string, collapsed func(inner, outer string)) ([]string, error) {
+	type candidate struct {
+		root string
+		abs  string
+	}
+	candidates := make([]candidate, 0, len(roots))
+	for _, root := range roots {
+		root, err := normalizeRoot(root)
+		if err != nil {
+			return nil, err
+		}
+		abs, err := filepath.Abs(root)
+		if err != nil {
+			return nil, err
+		}
+		candidates = append(candidates, candidate{root: root, abs: abs})
+	}
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].abs < candidates[j].abs
+	})
+
+	// An outer root sorts before everything beneath it
+	var kept []candidate
+	for _, c := range candidates {
+		if n := len(kept); n > 0 && withinRoot(kept[n-1].abs, c.abs) {
+			if collapsed != nil {
+				collapsed(c.root, kept[n-1].root)
+			}
+			continue
+		}
+		kept = append(kept, c)
+	}
+
+	canonical := make([]string, len(kept))
+	for i, c := range kept {
+		canonical[i] = c.root
+	}
+	return canonical, nil
+}
+
+// withinRoot reports whether the absolute path is root or beneath it.
+func withinRoot(root, path string) bool {
+	if path == root {
+		return true
+	}
+	if !strings.HasSuffix(root, string(os.PathSeparator)) {
+		root += string(os.PathSeparator)
+	}
+	return strings.HasPrefix(path, root)
+}
+
+// WalkRoots walks each of roots like WalkLimitWithOptions, as one walk.
+// Overlapping roots are walked once, through the outermost of them (see
+// CanonicalRoots), and up to opts.RootParallelism roots are walked at once.
+// The callbacks of all roots share opts.NumWorkers, and opts.Progress
+// receives the statistics of all roots combined. Budgets apply to each root
+// separately. If several roots fail, a *WalkErrors holds the error of
+// each.
+func WalkRoots(ctx context.Context, roots []string, walkFn filepath.WalkFunc, opts WalkOptions) error {
+	_, err := WalkRootsStats(ctx, roots, walkFn, opts)
+	return err
+}
+
+// WalkRootsStats is like WalkRoots but also returns the combined statistics
+// of the roots. FSInfo, when collected, is that of the first root; Extremes
+// are those of all roots, with depths counted from each entry's own root,
+// and ModifiedDuringWalk lists the directories of all roots.
+func WalkRootsStats(ctx context.Context, roots []string, walkFn filepath.WalkFunc, opts WalkOptions) (Stats, error) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	if len(roots) == 0 {
+		return Stats{}, errEmptyRoot
+	}
+
+	logger := opts.logger()
+	roots, err := CanonicalRoots(roots, func(inner, outer string) {
+		logger.Info("root is walked as part of another root",
+			zap.String("root", inner),
+			zap.String("within", outer))
+	})
+	if err != nil {
+		return Stats{}, err
+	}
+
+	if opts.NumWorkers <= 0 {
+		opts.NumWorkers = runtime.NumCPU()
+	}
+	parallel := min(max(opts.RootParallelism, 1), len(roots))
+
+	// Callbacks take a slot from the shared pool
+	slots := make(chan struct{}, opts.NumWorkers)
+	sharedFn := func(path string, info os.FileInfo, err error) error {
+		slots <- struct{}{}
+		defer func() { <-slots }()
+		return walkFn(path, info, err)
+	}
+
+	// Progress combines the latest statistics of every root
+	started := time.Now()
+	var mu sync.Mutex
+	latest := make([]Stats, len(roots))
+	combined := func() Stats {
+		total := sumStats(latest)
+		total.ElapsedTime = time.Since(started)
+		total.updateDerivedStats()
+		return total
+	}
+	progress := opts.Progress
+
+	errs := make([]error, len(roots))
+	next := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < parallel; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range next {
+				rootOpts := opts
+				if progress != nil {
+					rootOpts.Progress = func(s Stats) {
+						mu.Lock()
+						defer mu.Unlock()
+						latest[i] = s
+						progress(combined())
+					}
+				}
+				stats, err := walkLimitWithOptions(ctx, roots[i], sharedFn, rootOpts, true, nil)
+				mu.Lock()
+				latest[i] = stats
+				mu.Unlock()
+				errs[i] = err
+			}
+		}()
+	}
+	for i := range roots {
+		next <- i
+	}
+	close(next)
+	wg.Wait()
+
+	total := combined()
+	total.FSInfo = latest[0].FSInfo
+	if opts.CollectExtremes {
+		all := make([]*Extremes, len(latest))
+		for i, s := range latest {
+			all[i] = s.Extremes
+		}
+		total.Extremes = mergeExtremes(opts.ExtremesK, all...)
+	}
+	for _, s := range latest {
+		total.ModifiedDuringWalk = append(total.ModifiedDuringWalk, s.ModifiedDuringWalk...)
+		if total.TerminationReason == TerminationCompleted {
+			total.TerminationReason = s.TerminationReason
+		}
+	}
+	sort.Strings(total.ModifiedDuringWalk)
+	return total, rootErrors(roots, errs)
+}
+
+// rootErrors returns the error of the one root in roots that failed, or a
+// *WalkErrors holding the error of each root that did, errs holding the
+// error of each root.
+func rootErrors(roots []string, errs []error) error {
+	var failed []WalkError
+	for i, err := range errs {
+		if err != nil {
+			failed = append(failed, WalkError{Path: roots[i], Err: err})
+		}
+	}
+	switch len(failed) {
+	case 0:
+		return nil
+	case 1:
+		return failed[0].Err
+	}
+	return &WalkErrors{Errors: failed, Total: len(failed)}
+}
+
+// sumStats adds up the counters of stats. Elapsed time and derived
+// statistics are left for the caller.
+func sumStats(stats []Stats) Stats {
+	var total Stats
+	for _, s := range stats {
+		total.FilesProcessed += s.FilesProcessed
+		total.DirsProcessed += s.DirsProcessed
+		total.EmptyDirs += s.EmptyDirs
+		total.FilelessDirs += s.FilelessDirs
+		total.BytesProcessed += s.BytesProcessed
+		total.ErrorCount += s.ErrorCount
+		total.SkippedUnchangedDirs += s.SkippedUnchangedDirs
+		total.DuplicateDirsSkipped += s.DuplicateDirsSkipped
+		total.FilesSampledOut += s.FilesSampledOut
+		total.TransientRetries += s.TransientRetries
+		total.FilesSkipped += s.FilesSkipped
+		total.UnicodeIssues += s.UnicodeIssues
+		total.DirsModifiedDuringWalk += s.DirsModifiedDuringWalk
+		total.DirsPrunedByMtime += s.DirsPrunedByMtime
+		total.SymlinksFollowed += s.SymlinksFollowed
+		total.SymlinksSkipped += s.SymlinksSkipped
+		total.FilesFiltered += s.FilesFiltered
+		if s.MaxFilePath != "" && (total.MaxFilePath == "" || s.MaxFileSize > total.MaxFileSize) {
+			total.MaxFileSize, total.MaxFilePath = s.MaxFileSize, s.MaxFilePath
+		}
+	}
+	return total
+}
@@ -0,0 +1,121 @@
+package stride
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"testing"
+)
+
+func TestCanonicalRoots(t *testing.T) {
+	base := t.TempDir()
+	a, ab, b := filepath.Join(base, "a"), filepath.Join(base, "a", "b"), filepath.Join(base, "b")
+	aa := filepath.Join(base, "aa")
+
+	tests := []struct {
+		name      string
+		roots     []string
+		want      []string
+		collapsed []string
+	}{
+		{"sorted", []string{b, a}, []string{a, b}, nil},
+		{"nested", []string{ab, b, a}, []string{a, b}, []string{ab + " in " + a}},
+		{"duplicate", []string{a, a + "/"}, []string{a}, []string{a + " in " + a}},
+		{"sibling prefix", []string{aa, a}, []string{a, aa}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var collapsed []string
+			got, err := CanonicalRoots(tt.roots, func(inner, outer string) {
+				collapsed = append(collapsed, inner+" in "+outer)
+			})
+			if err != nil {
+				t.Fatalf("CanonicalRoots failed: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Expected %v, got %v", tt.want, got)
+			}
+			if !reflect.DeepEqual(collapsed, tt.collapsed) {
+				t.Errorf("Expected collapsed %v, got %v", tt.collapsed, collapsed)
+			}
+		})
+	}
+
+	if _, err := CanonicalRoots([]string{a, ""}, nil); err == nil {
+		t.Error("Expected an empty root to be rejected")
+	}
+}
+
+// countVisits walks roots with WalkRootsStats and returns how often each
+// path was visited.
+func countVisits(t *testing.T, roots []string, opts WalkOptions) (map[string]int, Stats) {
+	t.Helper()
+	var mu sync.Mutex
+	visits := make(map[string]int)
+	stats, err := WalkRootsStats(context.Background(), roots, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		visits[path]++
+		return nil
+	}, opts)
+	if err != nil {
+		t.Fatalf("WalkRootsStats failed: %v", err)
+	}
+	return visits, stats
+}
+
+func TestWalkRootsOverlapping(t *testing.T) {
+	root := createIncrementalFixture(t)
+
+	// The nested roots are walked as part of the outer one
+	visits, stats := countVisits(t, []string{filepath.Join(root, "b", "c"), root, filepath.Join(root, "b")}, WalkOptions{RootParallelism: 3})
+	for path, n := range visits {
+		if n != 1 {
+			t.Errorf("Expected %s to be visited once, got %d", path, n)
+		}
+	}
+	if len(visits) != 9 || stats.FilesProcessed != 5 || stats.DirsProcessed != 4 {
+		t.Errorf("Expected 9 entries in one walk, got %d and %+v", len(visits), stats)
+	}
+}
+
+func TestWalkRootsStats(t *testing.T) {
+	roots := []string{createIncrementalFixture(t), createEntriesFixture(t), createIncrementalFixture(t)}
+
+	// The combined statistics are those of independent walks added up
+	var want Stats
+	for _, root := range roots {
+		stats, err := WalkLimitWithOptionsStats(context.Background(), root, func(path string, info os.FileInfo, err error) error {
+			return err
+		}, WalkOptions{})
+		if err != nil {
+			t.Fatalf("WalkLimitWithOptionsStats failed: %v", err)
+		}
+		want = sumStats([]Stats{want, stats})
+	}
+
+	for _, parallel := range []int{1, 2} {
+		var mu sync.Mutex
+		var last Stats
+		opts := WalkOptions{NumWorkers: 2, RootParallelism: parallel, Progress: func(s Stats) {
+			mu.Lock()
+			defer mu.Unlock()
+			last = s
+		}}
+		visits, got := countVisits(t, roots, opts)
+		if len(visits) != int(want.FilesProcessed+want.DirsProcessed) {
+			t.Errorf("Expected %d entries, got %d", want.FilesProcessed+want.DirsProcessed, len(visits))
+		}
+		if sum := sumStats([]Stats{got}); !reflect.DeepEqual(sum, want) {
+			t.Errorf("Expected %+v with %d roots at once, got %+v", want, parallel, sum)
+		}
+		if last.FilesProcessed != want.FilesProcessed {
+			t.Errorf("Expected the last progress to cover every root, got %+v", last)
+		}
+	}
+}
@@ -0,0 +1,37 @@
+package stride
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// dirSampler caps the number of files taken from each directory, for
+// FilterOptions.MaxFilesPerDir. Files are counted as workers reach them, so
+// which files of a large directory are taken depends on scheduling, but
+// the count per directory is exact.
+type dirSampler struct {
+	limit  int64
+	counts sync.Map // Directory path to *int64 files seen so far
+}
+
+// newDirSampler returns a sampler taking up to limit files per directory,
+// or nil if limit is not positive.
+func newDirSampler(limit int) *dirSampler {
+	if limit <= 0 {
+		return nil
+	}
+	return &dirSampler{limit: int64(limit)}
+}
+
+// take reports whether another file of dir may be passed on. A nil sampler
+// takes every file. take may be called from several goroutines at once.
+func (s *dirSampler) take(dir string) bool {
+	if s == nil {
+		return true
+	}
+	count, ok := s.counts.Load(dir)
+	if !ok {
+		count, _ = s.counts.LoadOrStore(dir, new(int64))
+	}
+	return atomic.AddInt64(count.(*int64), 1) <= s.limit
+}
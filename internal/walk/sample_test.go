@@ -0,0 +1,130 @@
+package stride
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+// createSampleFixture creates a directory of 1000 files next to one of 3
+// files, each with a subdirectory of 3 files.
+func createSampleFixture(t *testing.T) string {
+	t.Helper()
+	root := t.TempDir()
+	counts := map[string]int{"big": 1000, "big/sub": 3, "small": 3}
+	for dir, n := range counts {
+		path := filepath.Join(root, filepath.FromSlash(dir))
+		if err := os.MkdirAll(path, 0755); err != nil {
+			t.Fatalf("Failed to create directory: %v", err)
+		}
+		for i := 0; i < n; i++ {
+			if err := os.WriteFile(filepath.Join(path, fmt.Sprintf("f%04d", i)), nil, 0644); err != nil {
+				t.Fatalf("Failed to create file: %v", err)
+			}
+		}
+	}
+	return root
+}
+
+// perDirCounter counts callbacks per root-relative directory.
+type perDirCounter struct {
+	mu     sync.Mutex
+	counts map[string]int
+}
+
+func (c *perDirCounter) add(root, path string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.counts == nil {
+		c.counts = make(map[string]int)
+	}
+	c.counts[relSlashPath(root, filepath.Dir(path))]++
+}
+
+func (c *perDirCounter) check(t *testing.T, name string, limit int) {
+	t.Helper()
+	want := map[string]int{"big": limit, "big/sub": 3, "small": 3}
+	for dir, n := range want {
+		if c.counts[dir] != n {
+			t.Errorf("%s: expected %d files from %s, got %d", name, n, dir, c.counts[dir])
+		}
+	}
+}
+
+func TestMaxFilesPerDir(t *testing.T) {
+	root := createSampleFixture(t)
+	filter := FilterOptions{MaxFilesPerDir: 10}
+
+	var walked perDirCounter
+	stats, err := WalkLimitWithOptionsStats(context.Background(), root, func(path string, info os.FileInfo, err error) error {
+		if err == nil && !info.IsDir() {
+			walked.add(root, path)
+		}
+		return err
+	}, WalkOptions{Filter: filter, NumWorkers: 8})
+	if err != nil {
+		t.Fatalf("Walk failed: %v", err)
+	}
+	walked.check(t, "WalkLimitWithOptions", 10)
+	if stats.FilesSampledOut != 990 || stats.FilesProcessed != 16 {
+		t.Errorf("Expected 990 files sampled out and 16 processed, got %d and %d", stats.FilesSampledOut, stats.FilesProcessed)
+	}
+
+	var filtered perDirCounter
+	err = WalkLimitWithFilter(context.Background(), root, func(path string, info os.FileInfo, err error) error {
+		if err == nil && !info.IsDir() {
+			filtered.add(root, path)
+		}
+		return err
+	}, 8, filter)
+	if err != nil {
+		t.Fatalf("WalkLimitWithFilter failed: %v", err)
+	}
+	filtered.check(t, "WalkLimitWithFilter", 10)
+
+	var dirWalked perDirCounter
+	err = WalkDir(root, func(ctx context.Context, path string, d fs.DirEntry) error {
+		if !d.IsDir() {
+			dirWalked.add(root, path)
+		}
+		return nil
+	}, WalkOptions{Filter: filter, NumWorkers: 8})
+	if err != nil {
+		t.Fatalf("WalkDir failed: %v", err)
+	}
+	dirWalked.check(t, "WalkDir", 10)
+}
+
+func TestFindMaxFilesPerDir(t *testing.T) {
+	root := createSampleFixture(t)
+
+	var found perDirCounter
+	var summary FindSummary
+	opts := FindOptions{
+		NamePattern:    "f00*",
+		MaxDepth:       3,
+		MaxFilesPerDir: 5,
+		Workers:        8,
+		Summary:        func(s FindSummary) { summary = s },
+	}
+	err := Find(context.Background(), root, opts, func(ctx context.Context, result FindResult) error {
+		if result.Error != nil {
+			return result.Error
+		}
+		found.add(root, result.Message.Path)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Find failed: %v", err)
+	}
+
+	// Only the 100 matches in big are sampled
+	found.check(t, "Find", 5)
+	if summary.Matches != 11 || summary.Stats.FilesSampledOut != 95 {
+		t.Errorf("Expected 11 matches and 95 sampled out, got %d and %d", summary.Matches, summary.Stats.FilesSampledOut)
+	}
+}
@@ -0,0 +1,178 @@
+package stride
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// Security rules applied by the analyzer and SecurityScan
+const (
+	RuleWorldWritable       = "world-writable"
+	RuleSetuid              = "setuid"
+	RuleSetgid              = "setgid"
+	RuleSuspiciousExtension = "suspicious-extension"
+)
+
+// securityRule is a check applied to each file by a security scan.
+type securityRule struct {
+	name        string
+	severity    string
+	description string
+	check       func(path string, info os.FileInfo) bool
+}
+
+// securityRules lists every rule, in the order they are applied.
+var securityRules = []securityRule{
+	{
+		name:        RuleWorldWritable,
+		severity:    "High",
+		description: "File is world-writable",
+		check: func(path string, info os.FileInfo) bool {
+			// The permission bits of a symbolic link are not used
+			return info.Mode()&os.ModeSymlink == 0 && info.Mode()&0002 != 0
+		},
+	},
+	{
+		name:        RuleSetuid,
+		severity:    "High",
+		description: "File has setuid bit set",
+		check: func(path string, info os.FileInfo) bool {
+			return info.Mode()&os.ModeSetuid != 0
+		},
+	},
+	{
+		name:        RuleSetgid,
+		severity:    "High",
+		description: "File has setgid bit set",
+		check: func(path string, info os.FileInfo) bool {
+			return info.Mode()&os.ModeSetgid != 0
+		},
+	},
+	{
+		name:        RuleSuspiciousExtension,
+		severity:    "Medium",
+		description: "File has suspicious extension",
+		check: func(path string, info os.FileInfo) bool {
+			return isSuspiciousExt(strings.ToLower(filepath.Ext(path)))
+		},
+	},
+}
+
+// selects reports whether sel names r or its severity.
+func (r securityRule) selects(sel string) bool {
+	return strings.EqualFold(sel, r.name) || strings.EqualFold(sel, r.severity)
+}
+
+// selectSecurityRules returns the rules named by include, or every rule if
+// it is empty, less those named by exclude. Rules are named by name or by
+// severity, and naming neither is an error.
+func selectSecurityRules(include, exclude []string) ([]securityRule, error) {
+	for _, sel := range append(include[:len(include):len(include)], exclude...) {
+		known := false
+		for _, r := range securityRules {
+			known = known || r.selects(sel)
+		}
+		if !known {
+			return nil, fmt.Errorf("unknown security rule or severity: %q", sel)
+		}
+	}
+
+	var rules []securityRule
+	for _, r := range securityRules {
+		if len(include) > 0 && !anyRuleSelects(r, include) {
+			continue
+		}
+		if anyRuleSelects(r, exclude) {
+			continue
+		}
+		rules = append(rules, r)
+	}
+	return rules, nil
+}
+
+// anyRuleSelects reports whether any of sels names r.
+func anyRuleSelects(r securityRule, sels []string) bool {
+	for _, sel := range sels {
+		if r.selects(sel) {
+			return true
+		}
+	}
+	return false
+}
+
+// checkSecurity appends the issues rules find in the file at path to issues.
+func checkSecurity(rules []securityRule, path string, info os.FileInfo, issues []SecurityIssue) []SecurityIssue {
+	for _, r := range rules {
+		if r.check(path, info) {
+			issues = append(issues, SecurityIssue{
+				Path:        path,
+				Rule:        r.name,
+				Description: r.description,
+				Severity:    r.severity,
+			})
+		}
+	}
+	return issues
+}
+
+// SecurityScanOptions configures SecurityScan.
+type SecurityScanOptions struct {
+	Filter  FilterOptions // Files to scan
+	Workers int           // Number of concurrent workers (default the number of CPUs)
+
+	// Rules selects the rules to apply by name, e.g. RuleSetuid, or by
+	// severity, e.g. "high", case-insensitively; empty for every rule.
+	// ExcludeRules removes the rules it names the same way.
+	Rules        []string
+	ExcludeRules []string
+}
+
+// SecurityScan applies the analyzer's security rules to the files beneath
+// root on the concurrent walker, passing each finding to onFinding as soon
+// as it is found. onFinding is never called concurrently. If it returns an
+// error, the scan stops and returns that error. Symbolic links are checked
+// themselves and not followed.
+func SecurityScan(ctx context.Context, root string, opts SecurityScanOptions, onFinding func(SecurityIssue) error) error {
+	rules, err := selectSecurityRules(opts.Rules, opts.ExcludeRules)
+	if err != nil {
+		return err
+	}
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	// Findings are delivered one at a time until the callback fails
+	var mu sync.Mutex
+	var findingErr error
+	walkOpts := WalkOptions{
+		Filter:          opts.Filter,
+		NumWorkers:      opts.Workers,
+		SymlinkHandling: SymlinkReport,
+	}
+	err = WalkLimitWithOptions(ctx, root, func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return nil
+		}
+		for _, issue := range checkSecurity(rules, path, info, nil) {
+			mu.Lock()
+			if findingErr == nil {
+				if findingErr = onFinding(issue); findingErr != nil {
+					cancel()
+				}
+			}
+			mu.Unlock()
+		}
+		return nil
+	}, walkOpts)
+
+	if findingErr != nil {
+		return findingErr
+	}
+	return err
+}
@@ -0,0 +1,113 @@
+package stride
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"sync"
+	"testing"
+)
+
+// createSecurityFixture creates one file for each security rule and a
+// harmless file.
+func createSecurityFixture(t *testing.T) string {
+	t.Helper()
+	root := t.TempDir()
+	files := map[string]os.FileMode{
+		"plain.txt":          0644,
+		"world-writable.txt": 0666,
+		"sub/setuid-bin":     0755 | os.ModeSetuid,
+		"sub/executable.exe": 0644,
+	}
+	for name, mode := range files {
+		path := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("Failed to create directory: %v", err)
+		}
+		if err := os.WriteFile(path, []byte(name), 0644); err != nil {
+			t.Fatalf("Failed to create file: %v", err)
+		}
+		if err := os.Chmod(path, mode); err != nil {
+			t.Fatalf("Failed to set file permissions: %v", err)
+		}
+	}
+	if err := os.Symlink("plain.txt", filepath.Join(root, "link")); err != nil {
+		t.Fatalf("Failed to create symlink: %v", err)
+	}
+	return root
+}
+
+// scanFindings runs SecurityScan and returns "rule path" for each finding.
+func scanFindings(t *testing.T, root string, opts SecurityScanOptions) []string {
+	t.Helper()
+	var mu sync.Mutex
+	var findings []string
+	err := SecurityScan(context.Background(), root, opts, func(issue SecurityIssue) error {
+		mu.Lock()
+		defer mu.Unlock()
+		rel, _ := filepath.Rel(root, issue.Path)
+		findings = append(findings, issue.Rule+" "+filepath.ToSlash(rel))
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("SecurityScan failed: %v", err)
+	}
+	sort.Strings(findings)
+	return findings
+}
+
+func TestSecurityScan(t *testing.T) {
+	root := createSecurityFixture(t)
+
+	// Each issue arrives exactly once; the link's permissions are ignored
+	got := scanFindings(t, root, SecurityScanOptions{Workers: 4})
+	want := []string{
+		"setuid sub/setuid-bin",
+		"suspicious-extension sub/executable.exe",
+		"world-writable world-writable.txt",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected %v, got %v", want, got)
+	}
+
+	tests := []struct {
+		name string
+		opts SecurityScanOptions
+		want []string
+	}{
+		{"by severity", SecurityScanOptions{Rules: []string{"medium"}}, want[1:2]},
+		{"by name", SecurityScanOptions{Rules: []string{"World-Writable", "setuid"}}, []string{want[0], want[2]}},
+		{"excluded", SecurityScanOptions{ExcludeRules: []string{"high"}}, want[1:2]},
+		{"filtered", SecurityScanOptions{Filter: FilterOptions{ExcludeDir: []string{"sub"}}}, want[2:]},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := scanFindings(t, root, tt.opts); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestSecurityScanErrors(t *testing.T) {
+	root := createSecurityFixture(t)
+
+	err := SecurityScan(context.Background(), root, SecurityScanOptions{Rules: []string{"critical"}}, func(SecurityIssue) error { return nil })
+	if err == nil {
+		t.Error("Expected an unknown rule to be rejected")
+	}
+
+	// A failing callback stops the scan after its first finding
+	errStop := errors.New("stop")
+	calls := 0
+	err = SecurityScan(context.Background(), root, SecurityScanOptions{}, func(SecurityIssue) error {
+		calls++
+		return errStop
+	})
+	if !errors.Is(err, errStop) || calls != 1 {
+		t.Errorf("Expected the callback error after one call, got %v after %d", err, calls)
+	}
+}
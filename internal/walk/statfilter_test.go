@@ -0,0 +1,111 @@
+package stride
+
+import (
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/TFMV/stride/walk/walktest"
+)
+
+// countStats replaces statPath with a counting version for the test.
+func countStats(tb testing.TB) *int {
+	calls := 0
+	orig := statPath
+	statPath = func(path string, stat *syscall.Stat_t) error {
+		calls++
+		return orig(path, stat)
+	}
+	tb.Cleanup(func() { statPath = orig })
+	return &calls
+}
+
+// noSysInfo hides the stat data of a FileInfo.
+type noSysInfo struct{ os.FileInfo }
+
+func (noSysInfo) Sys() interface{} { return nil }
+
+func TestFilterUsesInfoSource(t *testing.T) {
+	old := time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)
+	dir := walktest.Tree{
+		"target.txt": walktest.File{Size: 4096, ModTime: old},
+		"link.txt":   walktest.Symlink{Target: "target.txt"},
+	}.Build(t)
+	target, link := filepath.Join(dir, "target.txt"), filepath.Join(dir, "link.txt")
+
+	// The target is large and old, the link small and new
+	followed, err := os.Stat(link)
+	if err != nil {
+		t.Fatalf("Failed to stat link: %v", err)
+	}
+	own, err := os.Lstat(link)
+	if err != nil {
+		t.Fatalf("Failed to lstat link: %v", err)
+	}
+
+	cutoff := time.Date(2010, 1, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name   string
+		filter FilterOptions
+	}{
+		{"size", FilterOptions{MinSize: 1024}},
+		{"modified", FilterOptions{ModifiedBefore: cutoff}},
+		{"accessed", FilterOptions{AccessedBefore: cutoff}},
+		{"size and accessed", FilterOptions{MinSize: 1024, AccessedBefore: cutoff}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls := countStats(t)
+			if !filePassesFilter(link, followed, tt.filter, SymlinkFollow) {
+				t.Error("Expected the followed link to be judged by its target")
+			}
+			if filePassesFilter(link, own, tt.filter, SymlinkIgnore) {
+				t.Error("Expected the unfollowed link to be judged by itself")
+			}
+			if *calls != 0 {
+				t.Errorf("Expected no extra stat calls, got %d", *calls)
+			}
+		})
+	}
+
+	// Without stat data in the FileInfo, the path is stat'ed
+	calls := countStats(t)
+	if !filePassesFilter(target, noSysInfo{followed}, FilterOptions{AccessedBefore: cutoff}, SymlinkFollow) {
+		t.Error("Expected the fallback stat to find the old access time")
+	}
+	if *calls != 1 {
+		t.Errorf("Expected 1 fallback stat call, got %d", *calls)
+	}
+}
+
+// BenchmarkFilePassesFilterStat compares stat-based filtering with and
+// without the stat data carried by the FileInfo.
+func BenchmarkFilePassesFilterStat(b *testing.B) {
+	path := filepath.Join(b.TempDir(), "test.txt")
+	if err := os.WriteFile(path, []byte("test content"), 0644); err != nil {
+		b.Fatalf("Failed to create temp file: %v", err)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		b.Fatalf("Failed to stat temp file: %v", err)
+	}
+	filter := FilterOptions{AccessedAfter: time.Unix(0, 0), OwnerUID: os.Getuid() + 1}
+
+	for _, bm := range []struct {
+		name string
+		info os.FileInfo
+	}{
+		{"Sys", info},
+		{"Fallback", noSysInfo{info}},
+	} {
+		b.Run(bm.name, func(b *testing.B) {
+			calls := countStats(b)
+			for i := 0; i < b.N; i++ {
+				filePassesFilter(path, bm.info, filter, SymlinkIgnore)
+			}
+			b.ReportMetric(float64(*calls)/float64(b.N), "stats/op")
+		})
+	}
+}
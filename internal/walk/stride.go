@@ -13,8 +13,8 @@ import (
 	"io"
 	"io/fs"
 	"os"
-	"os/user"
 	"path/filepath"
+	"regexp"
 	"runtime"
 	"strings"
 	"sync"
@@ -30,6 +30,19 @@ import (
 // when no specific limit is provided.
 const DefaultConcurrentWalks int = 100
 
+// DefaultProgressInterval is how often walks report progress: always for
+// WalkLimitWithProgress, and for WalkLimitWithOptions unless
+// WalkOptions.ProgressInterval is set.
+const DefaultProgressInterval = 200 * time.Millisecond
+
+// MaxQueueSize bounds the number of entries waiting between enumeration and
+// the callbacks. Each queued entry holds its path and os.FileInfo, roughly
+// 300 bytes plus the path on Unix, so a full queue takes a few megabytes.
+const MaxQueueSize = 16384
+
+// queuePerWorker is the default number of queued entries per worker.
+const queuePerWorker = 4
+
 // --------------------------------------------------------------------------
 // Core types for progress monitoring
 // --------------------------------------------------------------------------
@@ -42,12 +55,89 @@ type ProgressFn func(stats Stats)
 type Stats struct {
 	FilesProcessed int64         // Number of files processed
 	DirsProcessed  int64         // Number of directories processed
-	EmptyDirs      int64         // Number of empty directories
+	EmptyDirs      int64         // Number of directories with no entries
+	FilelessDirs   int64         // Number of directories with no files at any depth
 	BytesProcessed int64         // Total bytes processed
 	ErrorCount     int64         // Number of errors encountered
 	ElapsedTime    time.Duration // Total time elapsed
 	AvgFileSize    int64         // Average file size in bytes
 	SpeedMBPerSec  float64       // Processing speed in MB/s
+
+	// TotalFilesEstimate is the number of files counted before the walk
+	// when WalkOptions.EstimateTotals is set, and ETA the time the rest of
+	// them should take at the rate files have been processed so far. Both
+	// are zero when estimation is disabled, and ETA also until a file has
+	// been processed, once the estimate is reached and in the final stats.
+	TotalFilesEstimate int64
+	ETA                time.Duration
+
+	SkippedUnchangedDirs int64 // Directories taken from the mtime cache instead of being read
+	DuplicateDirsSkipped int64 // Directories not walked again when reached through another symlink
+	FilesSampledOut      int64 // Files left out by FilterOptions.MaxFilesPerDir
+	TransientRetries     int64 // Filesystem calls retried after transient errors, see WalkOptions.TransientRetry
+	FilesSkipped         int64 // Entries withheld from the callback by WalkOptions.PathTransform
+	UnicodeIssues        int64 // Entries with a UnicodeIssue, counted when FilterOptions.DetectUnicodeIssues is set
+
+	// Directories whose contents changed while they were walked, counted
+	// when WalkOptions.DetectConcurrentModification is set
+	DirsModifiedDuringWalk int64
+
+	// Directories found unchanged by FilterOptions.PruneDirsUnmodifiedSince
+	DirsPrunedByMtime int64
+
+	SymlinksFollowed int64 // Symbolic links whose targets were walked
+	SymlinksSkipped  int64 // Symbolic links neither followed nor reported, e.g. under SymlinkIgnore or as cycles
+	FilesFiltered    int64 // Entries rejected by FilterOptions, directories counting once for their subtree
+
+	// The largest file delivered to the callback, the first seen of those
+	// of equal size; MaxFilePath is empty if no file was
+	MaxFileSize int64
+	MaxFilePath string `json:",omitempty"`
+
+	largest *largestFile // Tracks MaxFileSize and MaxFilePath during the walk
+
+	FSInfo   *FSInfo   `json:",omitempty"` // Filesystem of the root, in the final stats when WalkOptions.CollectFSInfo is set
+	Extremes *Extremes `json:",omitempty"` // Standout entries, in the final stats when WalkOptions.CollectExtremes is set
+
+	// ModifiedDuringWalk lists those directories, sorted, in the final stats
+	ModifiedDuringWalk []string `json:",omitempty"`
+
+	// TerminationReason tells why the walk stopped early, in the final
+	// stats; it is empty if the walk completed. See StopReason.
+	TerminationReason TerminationReason `json:",omitempty"`
+}
+
+// snapshot returns a consistent copy of the counters with the given elapsed
+// time and derived statistics filled in.
+func (s *Stats) snapshot(elapsed time.Duration) Stats {
+	snap := Stats{
+		FilesProcessed: atomic.LoadInt64(&s.FilesProcessed),
+		DirsProcessed:  atomic.LoadInt64(&s.DirsProcessed),
+		EmptyDirs:      atomic.LoadInt64(&s.EmptyDirs),
+		FilelessDirs:   atomic.LoadInt64(&s.FilelessDirs),
+		BytesProcessed: atomic.LoadInt64(&s.BytesProcessed),
+		ErrorCount:     atomic.LoadInt64(&s.ErrorCount),
+		ElapsedTime:    elapsed,
+
+		SkippedUnchangedDirs: atomic.LoadInt64(&s.SkippedUnchangedDirs),
+		DuplicateDirsSkipped: atomic.LoadInt64(&s.DuplicateDirsSkipped),
+		FilesSampledOut:      atomic.LoadInt64(&s.FilesSampledOut),
+		TransientRetries:     atomic.LoadInt64(&s.TransientRetries),
+		FilesSkipped:         atomic.LoadInt64(&s.FilesSkipped),
+		UnicodeIssues:        atomic.LoadInt64(&s.UnicodeIssues),
+
+		DirsModifiedDuringWalk: atomic.LoadInt64(&s.DirsModifiedDuringWalk),
+		DirsPrunedByMtime:      atomic.LoadInt64(&s.DirsPrunedByMtime),
+
+		SymlinksFollowed: atomic.LoadInt64(&s.SymlinksFollowed),
+		SymlinksSkipped:  atomic.LoadInt64(&s.SymlinksSkipped),
+		FilesFiltered:    atomic.LoadInt64(&s.FilesFiltered),
+
+		TotalFilesEstimate: s.TotalFilesEstimate,
+	}
+	snap.MaxFileSize, snap.MaxFilePath = s.largest.get()
+	snap.updateDerivedStats()
+	return snap
 }
 
 // updateDerivedStats calculates derived statistics like averages and speeds.
@@ -66,6 +156,48 @@ func (s *Stats) updateDerivedStats() {
 	} else {
 		s.SpeedMBPerSec = 0
 	}
+
+	s.ETA = 0
+	if remaining := s.TotalFilesEstimate - filesProcessed; remaining > 0 && filesProcessed > 0 {
+		s.ETA = time.Duration(float64(s.ElapsedTime) * float64(remaining) / float64(filesProcessed))
+	}
+}
+
+// newStats returns counters for a walk, ready to track its largest file.
+func newStats() *Stats {
+	return &Stats{largest: &largestFile{}}
+}
+
+// largestFile keeps the largest file seen by a walk. It is safe for
+// concurrent use.
+type largestFile struct {
+	size int64 // Read atomically to skip smaller files without locking
+	mu   sync.Mutex
+	path string
+}
+
+// observe records a file of the given size at path. A nil largestFile
+// ignores it.
+func (l *largestFile) observe(path string, size int64) {
+	if l == nil || size < atomic.LoadInt64(&l.size) {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.path == "" || size > l.size {
+		atomic.StoreInt64(&l.size, size)
+		l.path = path
+	}
+}
+
+// get returns the size and path of the largest file observed.
+func (l *largestFile) get() (int64, string) {
+	if l == nil {
+		return 0, ""
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.size, l.path
 }
 
 // --------------------------------------------------------------------------
@@ -85,9 +217,18 @@ const (
 type SymlinkHandling int
 
 const (
-	SymlinkFollow SymlinkHandling = iota // Follow symbolic links
-	SymlinkIgnore                        // Ignore symbolic links
-	SymlinkReport                        // Report links but don't follow
+	SymlinkFollow         SymlinkHandling = iota // Follow symbolic links
+	SymlinkIgnore                                // Ignore symbolic links
+	SymlinkReport                                // Report links but don't follow
+	SymlinkFollowInternal                        // Follow only links that stay inside the walk root
+)
+
+// TraversalOrder defines the order in which a walk enumerates the tree.
+type TraversalOrder int
+
+const (
+	DepthFirst   TraversalOrder = iota // Each directory's subtree before its next sibling, as filepath.WalkDir
+	BreadthFirst                       // Every entry of one depth before any entry deeper down
 )
 
 // MemoryLimit sets memory usage boundaries for the traversal.  Not implemented in this example.
@@ -117,6 +258,15 @@ type WalkOptions struct {
 	// Progress monitoring
 	Progress         ProgressFn        // Legacy progress function
 	ProgressCallback func(stats Stats) // Enhanced progress callback
+	ProgressInterval time.Duration     // Time between progress reports (default DefaultProgressInterval)
+
+	// EstimateTotals counts the files beneath the root, reading only
+	// directory listings, before the walk starts, so progress reports carry
+	// Stats.TotalFilesEstimate and Stats.ETA; without it both stay zero.
+	// The count skips the directories Filter excludes and those below its
+	// MaxDepth, but not files rejected by their FileInfo, such as by size,
+	// so it may run high. Cancelling the context stops it, and the walk.
+	EstimateTotals bool
 
 	// Logging and debug
 	Logger   *zap.Logger // Structured logger
@@ -125,45 +275,240 @@ type WalkOptions struct {
 
 	// Performance tuning
 	BufferSize  int // Size of internal buffers
+	QueueSize   int // Entries enumerated ahead of the callbacks (default 4 per worker, at most MaxQueueSize)
 	NumWorkers  int // Legacy worker count
 	WorkerCount int // Enhanced worker count
 
+	RootParallelism int // Roots walked at once by WalkRoots (default 1)
+
+	// SharedLimiter bounds the enumeration of this walk together with the
+	// other walks sharing it; see Limiter. Walks without one share the
+	// limiter set by SetGlobalLimiter, if any.
+	SharedLimiter *Limiter
+
+	// MaxRetainedErrors bounds the errors kept for the error a walk returns
+	// (default DefaultMaxRetainedErrors). Every error is still counted in
+	// Stats.ErrorCount, and repeats of one errno in one directory are kept
+	// only once.
+	MaxRetainedErrors int
+
+	// ForceStatFallback makes WalkDir lstat every entry instead of trusting
+	// the types in directory listings, for filesystems that misreport them.
+	// Entries whose type is unknown are always stat'ed, and WalkDir forces
+	// the fallback by itself after a run of them. The other walks stat
+	// every entry regardless.
+	ForceStatFallback bool
+
+	// TransientRetry retries the walk's own filesystem calls after
+	// transient errors instead of reporting them for the path at once.
+	TransientRetry TransientRetry
+
+	// TraversalOrder is the order in which the tree is enumerated. With
+	// BreadthFirst, directories wait in a queue, so every entry of a depth
+	// is passed to the callback before any entry deeper down, and SkipDir
+	// keeps a directory from being queued at all. The subtree behind a
+	// followed symbolic link is walked, breadth first, when the link is
+	// reached. Files are still handed to concurrent workers, so only
+	// directories are seen in exactly that order. Incremental walks ignore
+	// it.
+	TraversalOrder TraversalOrder
+
 	// Special handling
+	IncludeRoot     *bool              // Whether the root is passed to the callback regardless of Filter.MinDepth (default true)
 	SymlinkHandling SymlinkHandling    // How to handle symbolic links
-	MemoryLimit     MemoryLimit        // Legacy memory limits
-	MemoryLimits    MemoryLimitOptions // Enhanced memory limits
+	AllowRevisit    bool               // Walk a directory again each time a followed symlink reaches it
+	MemoryLimit     MemoryLimit        // Legacy memory limits, ignored
+	MemoryLimits    MemoryLimitOptions // Stop the walk with ErrMemoryLimit once the heap outgrows HardLimit
+
+	// ConfineToRoot opens the root, which must be a directory, once and
+	// reads and stats everything beneath it relative to that handle, for
+	// trees others can change during the walk: a directory swapped for a
+	// symbolic link leading outside the root between its enumeration and
+	// its reading fails with an error instead of being followed. Symbolic
+	// links are never followed, so SymlinkFollow and SymlinkFollowInternal
+	// report them as SymlinkReport does. Callbacks open entries through
+	// ConfinedRoot to stay confined too, or, when they are passed a
+	// context, with OpenInRoot and that context. Filters that read more
+	// than an entry's FileInfo, such as Filter.IncludeEmptyDirs, file flags
+	// and ACLs, still use paths. Incremental walks and prefetching are not
+	// available.
+	ConfineToRoot bool
+
+	// ConfinedRoot is the handle of the root a walk with ConfineToRoot
+	// goes through, opened by the caller with os.OpenRoot, so that
+	// callbacks can open entries through it as well. The walk leaves it
+	// open. If nil, the walk opens the root itself.
+	ConfinedRoot *os.Root
+
+	// WaitForRoot is how long a root that does not exist yet, such as a
+	// directory another job is about to create, is waited for, checking it
+	// again at growing intervals, before the walk fails with an error
+	// matching fs.ErrNotExist. Cancelling the context ends the wait. With
+	// 0, a missing root fails at once.
+	WaitForRoot time.Duration
+
+	// PostChildrenCallback, if set, is called once for each directory passed
+	// to the walk callback, after every entry beneath it has been processed,
+	// so a directory's children are always finished before the directory
+	// itself. It is not called for directories skipped with SkipDir or when
+	// the walk is stopped early, and it may be called from several goroutines
+	// at once. Its errors are handled like those of the walk callback.
+	PostChildrenCallback PostChildrenFunc
+
+	// Budgets. When one runs out, the walk stops dispatching entries, waits
+	// for the callbacks in progress and returns a *BudgetError with the
+	// statistics gathered so far.
+	MaxDuration time.Duration // Wall-clock time the walk may take; 0 for no limit
+	MaxFiles    int64         // Files that may be passed to the callback; 0 for no limit
+
+	// CollectFSInfo reads the size and inode counts of the root's
+	// filesystem when the walk starts and reports them in the final Stats.
+	// They are left nil where the platform does not support it.
+	CollectFSInfo bool
+
+	// CollectExtremes keeps the largest, oldest and newest files and the
+	// deepest and longest paths delivered to the callback, ExtremesK of
+	// each (DefaultExtremesK if 0), and reports them in the final Stats.
+	CollectExtremes bool
+	ExtremesK       int
+
+	// DetectConcurrentModification stats each directory again once
+	// everything beneath it has been enumerated, and reports those whose
+	// modification time moved, because entries were added, removed or
+	// renamed in them meanwhile, in the final Stats: what the walk delivered
+	// from them may mix old and new states. With ReEnumerateOnChange, each
+	// such directory is walked once more after the walk, delivering and
+	// counting its entries again, and only reported if it changed again;
+	// incremental walks are not retried.
+	DetectConcurrentModification bool
+	ReEnumerateOnChange          bool
+
+	// Incremental walks. When MtimeCache is set, each directory's mtime and
+	// entries are recorded in that file, and directories unchanged since the
+	// previous run are not read again. Replayed entries reflect the previous
+	// run, and symlinks are reported rather than followed.
+	MtimeCache            string          // Path of the cache file; empty disables incremental walks
+	IncrementalMode       IncrementalMode // Replay or prune the entries of unchanged directories
+	IncrementalStrictness CacheStrictness // How a directory is judged unchanged
+
+	// SortResults orders the results of Map by path; they are otherwise in
+	// the order the callbacks finished.
+	SortResults bool
+
+	// SkipCloudPlaceholders keeps the walk from reading anything of Windows
+	// files and directories whose content is fetched from a cloud provider
+	// when read (FILE_ATTRIBUTE_RECALL_ON_DATA_ACCESS), which would download
+	// them: such directories never pass Filter.IncludeEmptyDirs, and Find
+	// reports such files without hashing them, marked with
+	// Metadata["cloud_placeholder"] (default true).
+	SkipCloudPlaceholders *bool
+
+	// PathTransform, if set, is called for each entry that passes the
+	// filters, before the callback and its middleware. The path it returns
+	// is the one handed to them, while the walk itself goes on with the
+	// original; returning false withholds the entry from the callback, and
+	// counts it in Stats.FilesSkipped, though a directory's children are
+	// still walked. It runs on the hot path and may be called from several
+	// goroutines at once, so it must be pure and fast. Errors are reported
+	// with the original paths, and WalkDir, which has no os.FileInfo to
+	// pass, ignores it.
+	PathTransform PathTransformFunc
+
+	// OnUnicodeIssue, if set, is called with the UnicodeIssue of each entry
+	// that has one when Filter.DetectUnicodeIssues is set, just before the
+	// entry is passed to the callback. It may be called from several
+	// goroutines at once.
+	OnUnicodeIssue func(issue UnicodeIssue)
+
+	// Prefetch hints to the operating system which files the callbacks
+	// are about to read; see PrefetchOptions.
+	Prefetch PrefetchOptions
+
+	// EnableDirConfigs applies the DirConfig in each directory holding a
+	// file named DirConfigName (DefaultDirConfigName if empty) to that
+	// directory's subtree. WalkDir ignores them.
+	EnableDirConfigs bool
+	DirConfigName    string
 
 	// Extensibility
 	Middleware []MiddlewareFunc // Middleware functions for customization
+
+	errorLog *errorCollector // Records every error of the walk for WalkWithSummary
 }
 
+// PathTransformFunc rewrites the path of an entry before it is passed to
+// the walk callback, or vetoes its delivery by returning false.
+type PathTransformFunc func(path string, info os.FileInfo) (string, bool)
+
 // FilterOptions defines criteria for including/excluding files and directories.
+// Every criterion is evaluated against the os.FileInfo passed with the entry:
+// the target's when a symlink is followed, the link's own otherwise.
 type FilterOptions struct {
-	MinSize             int64       // Minimum file size in bytes
-	MaxSize             int64       // Maximum file size in bytes
-	Pattern             string      // Glob pattern for matching files
-	ExcludeDir          []string    // Directory patterns to exclude
-	IncludeTypes        []string    // File extensions to include (e.g. ".txt", ".go")
-	FileTypes           []string    // File types to include (file, dir, symlink)
-	ExcludePattern      []string    // Patterns to exclude files
-	ModifiedAfter       time.Time   // Only include files modified after
-	ModifiedBefore      time.Time   // Only include files modified before
-	AccessedAfter       time.Time   // Include files accessed after this time
-	AccessedBefore      time.Time   // Include files accessed before this time
-	CreatedAfter        time.Time   // Include files created after this time
-	CreatedBefore       time.Time   // Include files created before this time
-	MinPermissions      os.FileMode // Minimum file permissions (e.g. 0644)
-	MaxPermissions      os.FileMode // Maximum file permissions (e.g. 0755)
-	ExactPermissions    os.FileMode // Exact file permissions to match
-	UseExactPermissions bool        // Whether to use exact permissions matching
-	OwnerUID            int         // Filter by owner UID
-	OwnerGID            int         // Filter by group GID
-	OwnerName           string      // Filter by owner username
-	GroupName           string      // Filter by group name
-	MinDepth            int         // Minimum traversal depth
-	MaxDepth            int         // Maximum traversal depth
-	IncludeEmptyFiles   bool        // Include only empty files
-	IncludeEmptyDirs    bool        // Include only empty directories
+	MinSize             int64            // Minimum file size in bytes
+	MaxSize             int64            // Maximum file size in bytes
+	Pattern             string           // Glob for file names, or root-relative paths with ** if it contains '/'
+	ExcludeDir          []string         // Directory patterns to exclude; matching directories are never stat'ed or read
+	ExcludeDirRegex     []*regexp.Regexp // Patterns matched against root-relative, slash-separated directory paths
+	IncludeTypes        []string         // File extensions to include (e.g. ".txt", ".go")
+	FileTypes           []string         // File types to include (file, dir, symlink)
+	ExcludePattern      []string         // Patterns to exclude files
+	SkipJunkFiles       bool             // Exclude files matching CommonJunkPatterns, such as editor swap files and .DS_Store
+	ModifiedAfter       time.Time        // Only include files modified after
+	ModifiedBefore      time.Time        // Only include files modified before
+	NewerThanFile       string           // Only include files modified after this file, read once when the walk starts
+	OlderThanFile       string           // Only include files modified before this file, read once when the walk starts
+	AccessedAfter       time.Time        // Include files accessed after this time
+	AccessedBefore      time.Time        // Include files accessed before this time
+	CreatedAfter        time.Time        // Include files created after this time
+	CreatedBefore       time.Time        // Include files created before this time
+	MinPermissions      os.FileMode      // Bits that must all be set (e.g. 0444); see permissionsMatch
+	MaxPermissions      os.FileMode      // Bits outside this mask must be unset (e.g. 0755); see permissionsMatch
+	ExactPermissions    os.FileMode      // Exact file permissions to match
+	UseExactPermissions bool             // Whether to use exact permissions matching
+	PermissionsAnyOf    []os.FileMode    // Permission bits must equal one of these values
+	AnyPermissions      os.FileMode      // At least one of these bits must be set (e.g. 0022)
+	ReadableByOther     bool             // Only include files readable by other users
+	WritableByOther     bool             // Only include files writable by other users
+	ExecutableByOther   bool             // Only include files executable by other users
+	OwnerUID            int              // Filter by owner UID
+	OwnerGID            int              // Filter by group GID
+	OwnerName           string           // Filter by owner username
+	GroupName           string           // Filter by group name
+	MinDepth            int              // Minimum traversal depth
+	MaxDepth            int              // Maximum traversal depth
+	IncludeEmptyFiles   bool             // Include only empty files
+	IncludeEmptyDirs    bool             // Include only empty directories
+	MaxFilesPerDir      int              // Files passing the other criteria taken from each directory, 0 for all; subdirectories are still walked
+	IncludePaths        []string         // Path prefixes, relative to the root or absolute, outside which nothing is walked; see ReadIncludePaths
+	IgnoreFile          string           // File of gitignore rules, taken relative to the root, whose matches are skipped like excluded directories
+	RespectGitignore    bool             // Apply the .gitignore of each walked directory to its subtree as git does, on top of IgnoreFile
+	RequireFlags        FileFlags        // Flags files must all have, such as FlagImmutable; read only when set
+	ExcludeFlags        FileFlags        // Flags files must have none of, such as FlagNoDump; read only when set
+	DetectUnicodeIssues bool             // Report entries with a UnicodeIssue to WalkOptions.OnUnicodeIssue and count them; they are still delivered
+	OnlyBrokenSymlinks  bool             // Include only symbolic links whose target does not exist; links are only seen with SymlinkReport
+	HasExtendedACL      bool             // Include only entries with ACL entries beyond their permission bits; read per entry, so it comes last
+
+	// PruneDirsUnmodifiedSince, when set, treats directories below the root
+	// last modified before it as unchanged, for incremental runs that only
+	// look for new entries. A directory's modification time changes when
+	// entries are added to it, removed or renamed, but not when a file in
+	// it is rewritten in place, nor when anything changes deeper down: a
+	// new grandchild leaves its grandparent's time alone. The entries of an
+	// unchanged directory are withheld, but the directory is still read so
+	// that changed directories beneath it are found. Each unchanged
+	// directory is counted in Stats.DirsPrunedByMtime.
+	PruneDirsUnmodifiedSince time.Time
+
+	// RecursiveMtimePropagation skips the subtrees of unchanged directories
+	// without reading them. Set it only where every change updates the
+	// times of all the directories above it, such as trees maintained by a
+	// tool that does so; otherwise changes beneath an unchanged directory
+	// are missed.
+	RecursiveMtimePropagation bool
+
+	includes         *pathAllowlist // IncludePaths compiled when the walk starts
+	ignores          *ignoreMatcher // IgnoreFile and .gitignore files, read as the walk goes
+	readPlaceholders bool           // WalkOptions.SkipCloudPlaceholders is false
 }
 
 // --------------------------------------------------------------------------
@@ -171,7 +516,8 @@ type FilterOptions struct {
 // --------------------------------------------------------------------------
 
 // Walk traverses a directory tree using the default concurrency limit.
-// It's a convenience wrapper around WalkLimit.
+// It's a convenience wrapper around WalkLimit. A root that is not a
+// directory is visited as a single entry; see checkRoot.
 func Walk(root string, walkFn filepath.WalkFunc) error {
 	return WalkLimit(context.Background(), root, walkFn, DefaultConcurrentWalks)
 }
@@ -180,40 +526,53 @@ func Walk(root string, walkFn filepath.WalkFunc) error {
 // directory in the tree, including root. It uses a worker pool with the specified
 // concurrency limit to process files concurrently.
 func WalkLimit(ctx context.Context, root string, walkFn filepath.WalkFunc, limit int) error {
+	return walkLimit(ctx, root, walkFn, limit, nil, nil)
+}
+
+// walkLimit implements WalkLimit, reporting each enumerated entry to tracker
+// if it is non-nil. Directories for which prune returns true are skipped as
+// soon as they are enumerated, unless prune is nil. The enumeration shares
+// the limiter set by SetGlobalLimiter.
+func walkLimit(ctx context.Context, root string, walkFn filepath.WalkFunc, limit int, tracker *dirTracker, prune func(path string) bool) error {
 	if limit < 1 {
 		return errors.New("stride: concurrency limit must be greater than zero")
 	}
+	root, err := normalizeRoot(root)
+	if err != nil {
+		return err
+	}
+	if err := checkRoot(root); err != nil {
+		return err
+	}
 
-	logger := createLogger(LogLevelInfo) // Default log level
-	defer logger.Sync()
-
-	logger.Debug("starting walk",
-		zap.String("root", root),
-		zap.Int("workers", limit))
+	// Without options nothing is configured to log, so only errors are
+	logger := defaultLogger(LogLevelError)
+	if ce := logger.Check(zap.DebugLevel, "starting walk"); ce != nil {
+		ce.Write(zap.String("root", root), zap.Int("workers", limit))
+	}
 
-	tasks := make(chan walkArgs, limit)
+	tasks := make(chan walkArgs, queueSize(0, limit))
 	var tasksWg sync.WaitGroup
 	var workerWg sync.WaitGroup
 
 	// Error collection.
-	var walkErrors []error
-	var errLock sync.Mutex
+	walkErrors := newErrorCollector(0)
 
 	// Worker processes tasks (files only).
 	worker := func() {
 		defer workerWg.Done()
 		for task := range tasks {
 			if ctx.Err() != nil {
-				logger.Debug("worker canceled", zap.String("path", task.path))
+				if ce := logger.Check(zap.DebugLevel, "worker canceled"); ce != nil {
+					ce.Write(zap.String("path", task.path))
+				}
 				tasksWg.Done()
 				continue
 			}
 			if err := walkFn(task.path, task.info, task.err); err != nil {
 				// Do not collect SkipDir errors.
 				if !errors.Is(err, filepath.SkipDir) {
-					errLock.Lock()
-					walkErrors = append(walkErrors, fmt.Errorf("path %q: %w", task.path, err))
-					errLock.Unlock()
+					walkErrors.add(task.path, err)
 				}
 			}
 			tasksWg.Done()
@@ -226,94 +585,106 @@ func WalkLimit(ctx context.Context, root string, walkFn filepath.WalkFunc, limit
 		go worker()
 	}
 
+	// Enumeration shares the global limiter with other walks
+	gate := newEnumGate(ctx, globalLimiter.Load())
+	enumFn := gate.pauseWalkFn(walkFn)
+
 	// Use filepath.WalkDir which is more efficient than filepath.Walk or godirwalk
-	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+	var visit fs.WalkDirFunc
+	visit = func(path string, d fs.DirEntry, err error) error {
 		if err != nil {
+			tracker.skip(path)
 			return err
 		}
 
 		if ctx.Err() != nil {
-			logger.Warn("walk canceled", zap.String("path", path))
+			if ce := logger.Check(zap.WarnLevel, "walk canceled"); ce != nil {
+				ce.Write(zap.String("path", path))
+			}
 			return context.Canceled
 		}
 
+		// Excluded directories are neither stat'ed nor read
+		if prune != nil && d.IsDir() && prune(path) {
+			tracker.enter(path, true)
+			tracker.skip(path)
+			return filepath.SkipDir
+		}
+
 		// Get file info
-		fileInfo, err := d.Info()
+		fileInfo, err := entryInfo(path, d)
 		if err != nil {
+			tracker.enter(path, d.IsDir())
 			return err
 		}
+		if fileInfo.IsDir() && !d.IsDir() {
+			return walkListedDir(walkDirTree, path, visit)
+		}
+		tracker.enter(path, fileInfo.IsDir())
 
 		// For directories, process synchronously so that SkipDir is honored.
 		if fileInfo.IsDir() {
-			ret := walkFn(path, fileInfo, nil)
+			ret := enumFn(path, fileInfo, nil)
 			if errors.Is(ret, filepath.SkipDir) {
+				tracker.skip(path)
 				return filepath.SkipDir
 			}
 			if ret != nil {
-				errLock.Lock()
-				walkErrors = append(walkErrors, fmt.Errorf("path %q: %w", path, ret))
-				errLock.Unlock()
+				walkErrors.add(path, ret)
 			}
 		} else {
 			// For files, send the task to workers.
 			tasksWg.Add(1)
-			select {
-			case <-ctx.Done():
+			if !gate.send(ctx, tasks, walkArgs{path: path, info: fileInfo, err: nil}) {
 				tasksWg.Done()
 				return context.Canceled
-			case tasks <- walkArgs{path: path, info: fileInfo, err: nil}: // Pass fileInfo
 			}
 		}
 		return nil
-	})
+	}
+	err = walkDirTree(root, visit)
+	gate.done()
+
+	tracker.finish()
 
 	if err != nil && !errors.Is(err, filepath.SkipDir) {
-		errLock.Lock()
-		walkErrors = append(walkErrors, err)
-		errLock.Unlock()
+		walkErrors.add("", err)
 	}
 
 	close(tasks)
 	workerWg.Wait()
 
-	// Collect errors.
-	if len(walkErrors) > 0 {
-		// If there's only one error and it's context.Canceled, return it directly
-		if len(walkErrors) == 1 && (errors.Is(walkErrors[0], context.Canceled) ||
-			walkErrors[0].Error() == "context canceled") {
-			return context.Canceled
-		}
+	return stopError(ctx, walkErrors.err())
+}
 
-		// Check if all errors are the same custom error
-		if len(walkErrors) > 0 {
-			firstErr := walkErrors[0]
-			allSame := true
-			for _, err := range walkErrors[1:] {
-				if !errors.Is(err, firstErr) && err.Error() != firstErr.Error() {
-					allSame = false
-					break
-				}
-			}
-			if allSame {
-				return firstErr
-			}
-		}
+// WalkLimitWithProgress adds progress monitoring to the walk operation,
+// reporting every DefaultProgressInterval; WalkLimitWithOptions takes the
+// interval in WalkOptions.ProgressInterval. Errors returned by walkFn do
+// not stop the walk; they are returned together once it is done.
+func WalkLimitWithProgress(ctx context.Context, root string, walkFn filepath.WalkFunc, limit int, progressFn ProgressFn) error {
+	return walkLimitWithProgress(ctx, root, walkFn, limit, progressFn, errorHandlingCollect)
+}
 
-		return fmt.Errorf("multiple errors: %v", walkErrors)
-	}
-	return nil
+// WalkLimitWithProgressAndOptions is like WalkLimitWithProgress, but errors
+// returned by walkFn are counted in Stats.ErrorCount and handled as
+// errorHandling directs: ErrorHandlingContinue keeps walking and drops them,
+// ErrorHandlingSkip also skips the directory whose callback failed, and
+// ErrorHandlingStop stops the walk and returns the first.
+func WalkLimitWithProgressAndOptions(ctx context.Context, root string, walkFn filepath.WalkFunc, limit int, progressFn ProgressFn, errorHandling ErrorHandling) error {
+	return walkLimitWithProgress(ctx, root, walkFn, limit, progressFn, errorHandling)
 }
 
-// WalkLimitWithProgress adds progress monitoring to the walk operation.
-func WalkLimitWithProgress(ctx context.Context, root string, walkFn filepath.WalkFunc, limit int, progressFn ProgressFn) error {
-	stats := &Stats{}
+// errorHandlingCollect is the error handling of WalkLimitWithProgress:
+// callback errors are returned once the walk is done.
+const errorHandlingCollect ErrorHandling = -1
+
+func walkLimitWithProgress(ctx context.Context, root string, walkFn filepath.WalkFunc, limit int, progressFn ProgressFn, errorHandling ErrorHandling) error {
+	stats := newStats()
 	startTime := time.Now()
 
 	// Ensure a final progress update even on early return.
 	defer func() {
-		stats.ElapsedTime = time.Since(startTime)
-		stats.updateDerivedStats()
-		progressFn(*stats)
+		progressFn(stats.snapshot(time.Since(startTime)))
 	}()
 
 	doneCh := make(chan struct{})
@@ -321,52 +692,72 @@ func WalkLimitWithProgress(ctx context.Context, root string, walkFn filepath.Wal
 	tickerWg.Add(1)
 	go func() {
 		defer tickerWg.Done()
-		ticker := time.NewTicker(500 * time.Millisecond)
+		ticker := time.NewTicker(DefaultProgressInterval)
 		defer ticker.Stop()
 		for {
 			select {
 			case <-doneCh:
 				return
 			case <-ticker.C:
-				stats.ElapsedTime = time.Since(startTime)
-				stats.updateDerivedStats()
-				progressFn(*stats)
+				progressFn(stats.snapshot(time.Since(startTime)))
 			}
 		}
 	}()
 
-	// Wrap walkFn to update progress statistics.
+	// The first callback error stops the walk under ErrorHandlingStop
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	var stopErr error
+	var stopOnce sync.Once
+
+	// Wrap walkFn to update progress statistics, counting each failed
+	// entry once.
 	wrappedWalkFn := func(path string, info os.FileInfo, err error) error {
-		if err != nil {
-			atomic.AddInt64(&stats.ErrorCount, 1)
-			return err
-		}
-		if info.IsDir() {
-			atomic.AddInt64(&stats.DirsProcessed, 1)
-			if !hasFiles(path) {
-				atomic.AddInt64(&stats.EmptyDirs, 1)
+		if err == nil {
+			if info.IsDir() {
+				atomic.AddInt64(&stats.DirsProcessed, 1)
+			} else {
+				size := info.Size()
+				atomic.AddInt64(&stats.FilesProcessed, 1)
+				atomic.AddInt64(&stats.BytesProcessed, size)
+				stats.largest.observe(path, size)
+			}
+			err = walkFn(path, info, nil) // Pass nil for err
+			if err == nil || errors.Is(err, filepath.SkipDir) {
+				return err
 			}
-		} else {
-			size := info.Size()
-			atomic.AddInt64(&stats.FilesProcessed, 1)
-			atomic.AddInt64(&stats.BytesProcessed, size)
 		}
-		err = walkFn(path, info, nil) // Pass nil for err
-		if err != nil {
-			atomic.AddInt64(&stats.ErrorCount, 1)
+		atomic.AddInt64(&stats.ErrorCount, 1)
+
+		switch errorHandling {
+		case ErrorHandlingContinue:
+			return nil
+		case ErrorHandlingSkip:
+			if info != nil && info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		case ErrorHandlingStop:
+			stopOnce.Do(func() {
+				stopErr = fmt.Errorf("path %q: %w", path, err)
+				cancel()
+			})
+			return nil
 		}
 		return err
 	}
 
-	err := WalkLimit(ctx, root, wrappedWalkFn, limit)
+	err := walkLimit(ctx, root, wrappedWalkFn, limit, newDirTracker(stats), nil)
 	close(doneCh)
 	tickerWg.Wait()
+	if stopErr != nil {
+		return stopErr
+	}
 	return err
 }
 
 // Thread-safe maps for caching.
 var (
-	excludedDirs    sync.Map // Cache of excluded directories
 	visitedSymlinks sync.Map // Cache of visited symlinks to detect cycles, keyed by initial symlink path
 	symlinkLock     sync.RWMutex
 )
@@ -430,18 +821,89 @@ func resolveSymlink(path string, symlinkHandling SymlinkHandling) (string, os.Fi
 	return realPath, realFileInfo, true, nil // Resolved, not cyclic
 }
 
-// shouldSkipDir checks if a directory should be excluded, using a cached result.
-func shouldSkipDir(path, root string, excludes []string) bool {
-	// Use the cache.
-	if excluded, ok := excludedDirs.Load(path); ok && excluded.(bool) {
+// dirExcluded checks if a directory is excluded by the filter's basename
+// globs, its relative-path regexes or its gitignore rules, or lies off the
+// paths to and below its include paths.
+func dirExcluded(path, root string, filter FilterOptions) bool {
+	return shouldSkipDir(path, root, filter.ExcludeDir) ||
+		matchesExcludeDirRegex(path, root, filter.ExcludeDirRegex) ||
+		filter.includes.excludesDir(path) ||
+		filter.ignores.ignores(path, true)
+}
+
+// pruneExcluded returns the check walkers use to skip excluded directories
+// as soon as they are enumerated, before the directory is stat'ed or read,
+// or nil if filter excludes no directories. The root is left to the walk
+// function, which decides whether it is reported. Skipped directories are
+// counted in filtered, unless it is nil.
+func pruneExcluded(root string, filter FilterOptions, filtered *int64) func(path string) bool {
+	if len(filter.ExcludeDir) == 0 && len(filter.ExcludeDirRegex) == 0 && filter.includes == nil && filter.ignores == nil {
+		return nil
+	}
+	return func(path string) bool {
+		if path == root || !dirExcluded(path, root, filter) {
+			return false
+		}
+		if filtered != nil {
+			atomic.AddInt64(filtered, 1)
+		}
 		return true
 	}
+}
+
+// filePatternRejects checks if the file lies outside the filter's include
+// paths or is ignored by its gitignore rules, or if filter.Pattern is a
+// path pattern that the file's path relative to root does not match.
+// Base-name patterns are left to filePassesFilter.
+func filePatternRejects(path, root string, filter FilterOptions) bool {
+	if !filter.includes.allows(path) || filter.ignores.ignores(path, false) {
+		return true
+	}
+	if !isPathPattern(filter.Pattern) {
+		return false
+	}
+	matched, err := MatchPattern(filter.Pattern, relSlashPath(root, path))
+	return err != nil || !matched
+}
+
+// matchesExcludeDirRegex checks if a directory or any of its ancestors below
+// root matches one of the regexes. Paths are matched relative to root with
+// forward slashes, e.g. "third_party/lib/generated".
+func matchesExcludeDirRegex(path, root string, regexes []*regexp.Regexp) bool {
+	if len(regexes) == 0 {
+		return false
+	}
+	rel, err := filepath.Rel(filepath.Clean(root), filepath.Clean(path))
+	if err != nil || rel == "." || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return false
+	}
+	rel = filepath.ToSlash(rel)
+
+	for {
+		for _, re := range regexes {
+			if re.MatchString(rel) {
+				return true
+			}
+		}
+		i := strings.LastIndexByte(rel, '/')
+		if i < 0 {
+			return false
+		}
+		rel = rel[:i]
+	}
+}
+
+// shouldSkipDir checks if a directory or any of its ancestors below root has
+// a basename matching one of the exclude globs.
+func shouldSkipDir(path, root string, excludes []string) bool {
+	if len(excludes) == 0 {
+		return false
+	}
 
 	dir := path
 	for dir != root && dir != "." && dir != "/" { // Correct loop condition
 		for _, exclude := range excludes {
 			if matched, _ := filepath.Match(exclude, filepath.Base(dir)); matched {
-				excludedDirs.Store(path, true) // Cache the result
 				return true
 			}
 		}
@@ -457,7 +919,6 @@ func shouldSkipDir(path, root string, excludes []string) bool {
 	// Also check the root itself
 	for _, exclude := range excludes {
 		if matched, _ := filepath.Match(exclude, filepath.Base(dir)); matched {
-			// Don't cache the root, because it's not a subpath.
 			return true
 		}
 	}
@@ -466,7 +927,24 @@ func shouldSkipDir(path, root string, excludes []string) bool {
 
 // WalkLimitWithFilter adds file filtering capabilities to the walk operation.
 func WalkLimitWithFilter(ctx context.Context, root string, walkFn filepath.WalkFunc, limit int, filter FilterOptions) error {
-	root = filepath.Clean(root)
+	root, err := normalizeRoot(root)
+	if err != nil {
+		return err
+	}
+	filter, err = resolveReferenceFiles(filter)
+	if err != nil {
+		return err
+	}
+	filter, err = resolveIncludePaths(root, filter)
+	if err != nil {
+		return err
+	}
+	filter, err = resolveIgnoreRules(root, filter)
+	if err != nil {
+		return err
+	}
+	sampler := newDirSampler(filter.MaxFilesPerDir)
+	mtimes := newMtimePruner(root, filter, nil)
 	symlinkLock.Lock()
 
 	visitedSymlinks = sync.Map{} // Reset visited symlinks
@@ -493,17 +971,23 @@ func WalkLimitWithFilter(ctx context.Context, root string, walkFn filepath.WalkF
 		}
 
 		if info.IsDir() {
-			if shouldSkipDir(path, root, filter.ExcludeDir) {
+			if dirExcluded(path, root, filter) || mtimes.prunes(path, info) {
 				return filepath.SkipDir
 			}
+			if mtimes.withholds(filepath.Dir(path)) {
+				return nil
+			}
 		} else {
 			// Check if the parent directory is excluded.
 			parent := filepath.Dir(path)
-			if shouldSkipDir(parent, root, filter.ExcludeDir) {
+			if dirExcluded(parent, root, filter) || mtimes.withholds(parent) {
 				return nil
 			}
 			// Use the full path when filtering files.
-			if !filePassesFilter(path, info, filter, SymlinkFollow) {
+			if filePatternRejects(path, root, filter) || !filePassesFilter(path, info, filter, SymlinkFollow) {
+				return nil
+			}
+			if !sampler.take(parent) {
 				return nil
 			}
 		}
@@ -511,12 +995,67 @@ func WalkLimitWithFilter(ctx context.Context, root string, walkFn filepath.WalkF
 		return walkFn(path, info, nil)
 	}
 
-	return WalkLimit(ctx, root, filteredWalkFn, limit)
+	return walkLimit(ctx, root, filteredWalkFn, limit, nil, pruneExcluded(root, filter, nil))
 }
 
 // WalkLimitWithOptions provides the most flexible configuration,
 // combining error handling, filtering, progress reporting, and optional custom logger/symlink handling.
 func WalkLimitWithOptions(ctx context.Context, root string, walkFn filepath.WalkFunc, opts WalkOptions) error {
+	_, err := walkLimitWithOptions(ctx, root, walkFn, opts, opts.Progress != nil, nil)
+	return err
+}
+
+// WalkLimitWithOptionsStats is like WalkLimitWithOptions but also returns the
+// final traversal statistics, computed after all workers have finished. The
+// result equals the last snapshot passed to opts.Progress, if set.
+func WalkLimitWithOptionsStats(ctx context.Context, root string, walkFn filepath.WalkFunc, opts WalkOptions) (Stats, error) {
+	return walkLimitWithOptions(ctx, root, walkFn, opts, true, nil)
+}
+
+// walkLimitWithOptions implements WalkLimitWithOptions, maintaining
+// statistics only when collect is set. Directory fan-out is reported to
+// fanout, which requires collect, unless it is nil.
+func walkLimitWithOptions(ctx context.Context, root string, walkFn filepath.WalkFunc, opts WalkOptions, collect bool, fanout *fanoutCollector) (Stats, error) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	root, err := normalizeRoot(root)
+	if err != nil {
+		return Stats{}, err
+	}
+	if err := waitForRoot(ctx, root, opts.WaitForRoot); err != nil {
+		return Stats{}, err
+	}
+	opts.Filter, err = resolveReferenceFiles(opts.Filter)
+	if err != nil {
+		return Stats{}, err
+	}
+	opts.Filter, err = resolveIncludePaths(root, opts.Filter)
+	if err != nil {
+		return Stats{}, err
+	}
+	opts.Filter, err = resolveIgnoreRules(root, opts.Filter)
+	if err != nil {
+		return Stats{}, err
+	}
+	opts.Filter.readPlaceholders = !opts.skipCloudPlaceholders()
+
+	// A confined walk goes through the root's handle and follows no links
+	var confined *confinedRoot
+	if opts.ConfineToRoot {
+		if opts.MtimeCache != "" {
+			return Stats{}, errors.New("stride: incremental walks cannot be confined to their root")
+		}
+		if confined, err = openConfinedRoot(root, opts.ConfinedRoot); err != nil {
+			return Stats{}, err
+		}
+		defer confined.close()
+		if opts.SymlinkHandling == SymlinkFollow || opts.SymlinkHandling == SymlinkFollowInternal {
+			opts.SymlinkHandling = SymlinkReport
+		}
+		opts.Prefetch = PrefetchOptions{}
+	}
+
 	if opts.BufferSize < 1 {
 		opts.BufferSize = DefaultConcurrentWalks
 	}
@@ -525,42 +1064,55 @@ func WalkLimitWithOptions(ctx context.Context, root string, walkFn filepath.Walk
 		opts.NumWorkers = runtime.NumCPU() // Use number of CPUs by default
 	}
 
-	logger := opts.Logger
-	if logger == nil {
-		logger = createLogger(opts.LogLevel)
-		defer logger.Sync()
+	logger := opts.logger()
+	if ce := logger.Check(zap.DebugLevel, "starting walk with options"); ce != nil {
+		ce.Write(
+			zap.String("root", root),
+			zap.Int("buffer_size", opts.BufferSize),
+			zap.Any("error_handling", opts.ErrorHandling),
+			zap.Any("symlink_handling", opts.SymlinkHandling),
+		)
 	}
 
-	logger.Debug("starting walk with options",
-		zap.String("root", root),
-		zap.Int("buffer_size", opts.BufferSize),
-		zap.Any("error_handling", opts.ErrorHandling),
-		zap.Any("symlink_handling", opts.SymlinkHandling),
-	)
+	// The estimate is taken first, so its time does not slow the rate
+	var estimate int64
+	if opts.EstimateTotals {
+		if estimate, err = estimateFiles(ctx, root, opts.Filter, confined); err != nil {
+			return Stats{}, stopError(ctx, err)
+		}
+	}
 
-	stats := &Stats{}
+	stats := newStats()
+	stats.TotalFilesEstimate = estimate
 	startTime := time.Now()
-	visitedSymlinks = sync.Map{} // Clear symlink cache
+
+	// Read the filesystem before the walk changes anything
+	var fsInfo *FSInfo
+	if opts.CollectFSInfo {
+		fsInfo = collectFSInfo(root)
+	}
+
+	var extremes *extremesCollector
+	if opts.CollectExtremes && collect {
+		extremes = newExtremesCollector(opts.ExtremesK)
+	}
 
 	// Set up periodic progress updates if progress function is provided
+	doneCh := make(chan struct{})
+	var tickerWg sync.WaitGroup
 	if opts.Progress != nil {
 		// Create a ticker to send progress updates periodically
-		ticker := time.NewTicker(200 * time.Millisecond)
+		ticker := time.NewTicker(opts.progressInterval())
 		defer ticker.Stop()
 
-		// Create a done channel to signal when to stop the ticker
-		doneCh := make(chan struct{})
-		defer close(doneCh)
-
 		// Start a goroutine to send progress updates
+		tickerWg.Add(1)
 		go func() {
+			defer tickerWg.Done()
 			for {
 				select {
 				case <-ticker.C:
-					// Update elapsed time and derived stats
-					stats.ElapsedTime = time.Since(startTime)
-					stats.updateDerivedStats()
-					opts.Progress(*stats)
+					opts.Progress(stats.snapshot(time.Since(startTime)))
 				case <-doneCh:
 					return
 				case <-ctx.Done():
@@ -570,16 +1122,57 @@ func WalkLimitWithOptions(ctx context.Context, root string, walkFn filepath.Walk
 		}()
 	}
 
-	// Track the root depth for MinDepth/MaxDepth filtering
-	rootDepth := strings.Count(filepath.Clean(root), string(os.PathSeparator))
+	// Budgets stop the walk through its context
+	budget := newWalkBudget(ctx, opts)
+	sampler := newDirSampler(opts.Filter.MaxFilesPerDir)
+
+	// Post-children callback and directory config errors follow the error
+	// handling mode
+	var postErr error
+	var postErrOnce sync.Once
+	failEntry := func(path string, err error, skipped bool) {
+		opts.errorLog.addEntry(WalkError{Path: path, Err: err, Skipped: skipped})
+		if collect {
+			atomic.AddInt64(&stats.ErrorCount, 1)
+		}
+		if opts.ErrorHandling == ErrorHandlingStop {
+			postErrOnce.Do(func() {
+				postErr = fmt.Errorf("path %q: %w", path, err)
+				budget.cancel(postErr)
+			})
+		}
+	}
+	failPath := func(path string, err error) {
+		failEntry(path, err, false)
+	}
+	breadthFirst := opts.TraversalOrder == BreadthFirst && opts.MtimeCache == ""
+	post := newPostVisitor(budget.ctx, opts.PostChildrenCallback, failPath, breadthFirst)
+	dirConfigs := newDirConfigs(opts)
+	unicodeIssues := newUnicodeDetector(opts.Filter)
+	mtimes := newMtimePruner(root, opts.Filter, &stats.DirsPrunedByMtime)
+
+	var wrappedWalkFn filepath.WalkFunc
+	wrappedWalkFn = func(path string, info os.FileInfo, err error) error {
+		// Entries still queued when the walk stops are dropped
+		if budget.exceeded() {
+			return nil
+		}
 
-	wrappedWalkFn := func(path string, info os.FileInfo, err error) error {
 		if err != nil {
-			if opts.Progress != nil {
+			// The entry, or the directory that could not be read, is left out
+			opts.errorLog.addEntry(WalkError{Path: path, Err: err, Skipped: true})
+			if collect {
 				atomic.AddInt64(&stats.ErrorCount, 1)
-				stats.ElapsedTime = time.Since(startTime)
-				stats.updateDerivedStats()
-				opts.Progress(*stats)
+			}
+			if opts.Progress != nil {
+				opts.Progress(stats.snapshot(time.Since(startTime)))
+			}
+			// Skipped external symlinks are reported to the callback
+			if errors.Is(err, ErrOutsideRoot) {
+				if ret := walkFn(path, info, err); ret != nil && opts.ErrorHandling == ErrorHandlingStop {
+					return ret
+				}
+				return nil
 			}
 			switch opts.ErrorHandling {
 			case ErrorHandlingContinue, ErrorHandlingSkip:
@@ -595,18 +1188,92 @@ func WalkLimitWithOptions(ctx context.Context, root string, walkFn filepath.Walk
 		}
 
 		// Calculate current depth relative to root
-		pathDepth := strings.Count(filepath.Clean(path), string(os.PathSeparator)) - rootDepth
+		pathDepth := depthOf(root, path)
+
+		// Directory configs narrow the options for their subtrees
+		if dirConfigs != nil {
+			scope := dirConfigs.scopeOf(filepath.Dir(path))
+			if pathDepth > 0 && scope.skips(info, pathDepth) {
+				if info.IsDir() {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			if link, links := scope.link(path, info, opts.SymlinkHandling); link != nil {
+				if collect {
+					if links == SymlinkIgnore {
+						atomic.AddInt64(&stats.SymlinksSkipped, 1)
+					} else {
+						atomic.AddInt64(&stats.SymlinksFollowed, 1)
+					}
+				}
+				switch {
+				case links == SymlinkIgnore:
+					if info.IsDir() {
+						return filepath.SkipDir
+					}
+					return nil
+				case info.IsDir():
+					// A followed link to a directory is reported alone
+					if ret := wrappedWalkFn(path, link, nil); ret != nil && !errors.Is(ret, filepath.SkipDir) {
+						return ret
+					}
+					return filepath.SkipDir
+				default:
+					info = link
+				}
+			}
+			if info.IsDir() {
+				if err := dirConfigs.enter(path, scope, pathDepth); err != nil {
+					if ce := logger.Check(zap.WarnLevel, "invalid directory config"); ce != nil {
+						ce.Write(zap.String("path", path), zap.Error(err))
+					}
+					failEntry(path, err, opts.ErrorHandling != ErrorHandlingContinue)
+					if opts.ErrorHandling != ErrorHandlingContinue {
+						return filepath.SkipDir
+					}
+				}
+			}
+		}
+
+		// The root is delivered unless disabled, whatever its depth filters
+		if pathDepth == 0 && !opts.includeRoot() {
+			if info.IsDir() {
+				post.enterDir(path, info, false)
+			}
+			return nil
+		}
+
+		// Unchanged directories are skipped, or walked for their subdirectories
+		if info.IsDir() && mtimes.prunes(path, info) {
+			return filepath.SkipDir
+		}
+		if mtimes.withholds(filepath.Dir(path)) {
+			if info.IsDir() {
+				post.enterDir(path, info, false)
+			}
+			return nil
+		}
+
+		// Entries the filter rejects are counted
+		filtered := func() {
+			if collect {
+				atomic.AddInt64(&stats.FilesFiltered, 1)
+			}
+		}
 
 		// Apply depth filtering
-		if opts.Filter.MinDepth > 0 && pathDepth < opts.Filter.MinDepth {
-			if info.IsDir() && pathDepth < opts.Filter.MinDepth-1 {
+		if pathDepth > 0 && opts.Filter.MinDepth > 0 && pathDepth < opts.Filter.MinDepth {
+			filtered()
+			if info.IsDir() {
 				// Continue traversing but don't process
-				return nil
+				post.enterDir(path, info, false)
 			}
 			return nil // Skip this file/dir but don't skip its children
 		}
 
 		if opts.Filter.MaxDepth > 0 && pathDepth > opts.Filter.MaxDepth {
+			filtered()
 			if info.IsDir() {
 				return filepath.SkipDir // Skip this directory and its children
 			}
@@ -614,67 +1281,239 @@ func WalkLimitWithOptions(ctx context.Context, root string, walkFn filepath.Walk
 		}
 
 		if info.IsDir() {
-			if shouldSkipDir(path, root, opts.Filter.ExcludeDir) {
+			if dirExcluded(path, root, opts.Filter) {
+				filtered()
 				return filepath.SkipDir
 			}
 		} else {
 			parent := filepath.Dir(path)
-			if shouldSkipDir(parent, root, opts.Filter.ExcludeDir) {
+			if dirExcluded(parent, root, opts.Filter) {
+				filtered()
+				return nil
+			}
+			if filePatternRejects(path, root, opts.Filter) || !filePassesFilter(path, info, opts.Filter, opts.SymlinkHandling) {
+				filtered()
 				return nil
 			}
-			if !filePassesFilter(path, info, opts.Filter, opts.SymlinkHandling) {
+			if !sampler.take(parent) {
+				if collect {
+					atomic.AddInt64(&stats.FilesSampledOut, 1)
+				}
 				return nil
 			}
 		}
 
-		if opts.Progress != nil {
-			if info.IsDir() {
-				atomic.AddInt64(&stats.DirsProcessed, 1)
-				if !hasFiles(path) {
-					atomic.AddInt64(&stats.EmptyDirs, 1)
+		// The callback sees the transformed path, the traversal the original
+		userPath := path
+		if opts.PathTransform != nil {
+			var deliver bool
+			if userPath, deliver = opts.PathTransform(path, info); !deliver {
+				if collect {
+					atomic.AddInt64(&stats.FilesSkipped, 1)
+				}
+				if info.IsDir() {
+					post.enterDir(path, info, false)
 				}
+				return nil
+			}
+		}
 
+		if !info.IsDir() && !budget.takeFile() {
+			return nil
+		}
+
+		if collect {
+			if info.IsDir() {
+				atomic.AddInt64(&stats.DirsProcessed, 1)
 			} else {
 				atomic.AddInt64(&stats.FilesProcessed, 1)
 				atomic.AddInt64(&stats.BytesProcessed, info.Size())
+				stats.largest.observe(userPath, info.Size())
 			}
 		}
-		return walkFn(path, info, nil) // Call the users walkFn
+		if extremes != nil {
+			extremes.add(userPath, info.Size(), info.ModTime(), pathDepth, info.IsDir())
+		}
+		if !info.IsDir() {
+			post.countFile(path, info.Size())
+		}
+		if unicodeIssues != nil && pathDepth > 0 {
+			if issue, ok := unicodeIssues.check(path); ok {
+				if collect {
+					atomic.AddInt64(&stats.UnicodeIssues, 1)
+				}
+				if opts.OnUnicodeIssue != nil {
+					opts.OnUnicodeIssue(issue)
+				}
+			}
+		}
+
+		ret := walkFn(userPath, info, nil) // Call the users walkFn
+		if errors.Is(ret, filepath.SkipAll) {
+			budget.skipAll()
+			return nil
+		}
+		if ret != nil && !errors.Is(ret, filepath.SkipDir) {
+			opts.errorLog.addEntry(WalkError{Path: userPath, Err: ret})
+			if collect {
+				atomic.AddInt64(&stats.ErrorCount, 1)
+			}
+		}
+		if info.IsDir() && !errors.Is(ret, filepath.SkipDir) {
+			post.enterDir(path, info, true)
+		}
+		return ret
+	}
+
+	// Count empty directories from the traversal order when collecting stats
+	var tracker *dirTracker
+	if collect {
+		tracker = newDirTracker(stats)
+		tracker.fanout = fanout
+		tracker.detectChanges = opts.DetectConcurrentModification
+		tracker.breadthFirst = breadthFirst
+	}
+
+	// Walk each directory once when links may lead back into the tree
+	var visited *visitedDirs
+	if (opts.SymlinkHandling == SymlinkFollow || opts.SymlinkHandling == SymlinkFollowInternal) && !opts.AllowRevisit {
+		visited = newVisitedDirs(&stats.DuplicateDirsSkipped)
 	}
 
 	// Use a custom implementation for WalkLimit that respects symlink handling
-	finalErr := walkLimitWithSymlinkHandling(ctx, root, wrappedWalkFn, opts.NumWorkers, opts.SymlinkHandling)
+	var finalErr error
+	if opts.MtimeCache != "" {
+		finalErr = walkIncremental(budget.ctx, root, wrappedWalkFn, opts, stats, tracker, post)
+	} else {
+		retry := newTransientRetrier(budget.ctx, opts.TransientRetry, &stats.TransientRetries)
+		prefetch := newPrefetcher(opts.Prefetch)
+		walkTree := func(dir string, tracker *dirTracker, visited *visitedDirs) error {
+			return walkLimitWithSymlinkHandling(budget.ctx, dir, wrappedWalkFn, walkConfig{
+				limit:           opts.NumWorkers,
+				queue:           queueSize(opts.QueueSize, opts.NumWorkers),
+				maxErrors:       opts.MaxRetainedErrors,
+				symlinkHandling: opts.SymlinkHandling,
+				order:           opts.TraversalOrder,
+				tracker:         tracker,
+				visited:         visited,
+				post:            post,
+				prune:           pruneExcluded(root, opts.Filter, &stats.FilesFiltered),
+				retry:           retry,
+				limiter:         opts.limiter(),
+				prefetch:        prefetch,
+				logger:          logger,
+				confined:        confined,
+			})
+		}
+		finalErr = walkTree(root, tracker, visited)
+
+		// Directories changed during the walk are walked once more
+		if finalErr == nil && opts.DetectConcurrentModification && opts.ReEnumerateOnChange {
+			finalErr = tracker.retryModified(func(dir string, tracker *dirTracker) error {
+				var revisit *visitedDirs
+				if visited != nil {
+					revisit = newVisitedDirs(&stats.DuplicateDirsSkipped)
+				}
+				return walkTree(dir, tracker, revisit)
+			})
+		}
+		prefetch.stop()
+	}
+	if postErr != nil {
+		finalErr = postErr
+	}
+	finalErr = stopError(ctx, finalErr)
+
+	// A budget that ran out takes the place of the cancellation it caused
+	if budgetErr := budget.stop(); budgetErr != nil {
+		finalErr = budgetErr
+	}
 
-	// Stop progress updates
+	// Stop periodic updates before reporting the final stats
+	close(doneCh)
+	tickerWg.Wait()
+
+	final := stats.snapshot(time.Since(startTime))
+	final.ETA = 0
+	final.FSInfo = fsInfo
+	final.ModifiedDuringWalk = tracker.modifiedDirs()
+	final.TerminationReason = StopReason(finalErr)
+	if extremes != nil {
+		final.Extremes = extremes.extremes()
+	}
 	if opts.Progress != nil {
-		stats.ElapsedTime = time.Since(startTime)
-		stats.updateDerivedStats()
-		opts.Progress(*stats)
+		opts.Progress(final)
 	}
-	return finalErr
+	return final, finalErr
 }
 
-// walkLimitWithSymlinkHandling is a version of WalkLimit that respects the SymlinkHandling option
-func walkLimitWithSymlinkHandling(ctx context.Context, root string, walkFn filepath.WalkFunc, limit int, symlinkHandling SymlinkHandling) error {
+// walkConfig holds the settings of walkLimitWithSymlinkHandling. Pointers
+// and functions that are nil turn off what they stand for.
+type walkConfig struct {
+	limit           int             // Workers running the callback for files
+	queue           int             // Files waiting for the workers, so enumeration goes on while callbacks are slow
+	maxErrors       int             // Errors kept for the returned error, or DefaultMaxRetainedErrors if 0
+	symlinkHandling SymlinkHandling // How symbolic links are handled
+	order           TraversalOrder  // Order the tree is enumerated in
+
+	tracker  *dirTracker            // Told of each enumerated entry, and under BreadthFirst each listed directory
+	visited  *visitedDirs           // Directories already walked, which are skipped
+	post     *postVisitor           // Told of dispatched files, and under BreadthFirst listed directories
+	prune    func(path string) bool // Directories it returns true for are skipped before being stat'ed
+	retry    *transientRetrier      // Retries stats, directory reads and link resolutions that fail transiently
+	limiter  *Limiter               // Shared with other walks to bound enumeration
+	prefetch *prefetcher            // Hinted with dispatched files
+	logger   *zap.Logger            // Logs cancellation
+	confined *confinedRoot          // Handle the tree is enumerated through
+}
+
+// walkLimitWithSymlinkHandling is a version of WalkLimit that respects the
+// SymlinkHandling option, configured by cfg.
+func walkLimitWithSymlinkHandling(ctx context.Context, root string, walkFn filepath.WalkFunc, cfg walkConfig) error {
+	walkTree := walkDirTree
+	if cfg.confined != nil {
+		walkTree = cfg.confined.walkDir
+	}
+
+	// walkLinked walks target, the directory behind the followed link at
+	// link, whose entries are reported under link
+	walkLinked := func(target, link string, fn fs.WalkDirFunc) error {
+		return walkDirTree(target, fn)
+	}
+	if cfg.order == BreadthFirst {
+		listed := func(dir string) {
+			cfg.tracker.listed(dir)
+			cfg.post.listed(dir)
+		}
+		walkTree = func(dir string, fn fs.WalkDirFunc) error {
+			if cfg.confined != nil {
+				return cfg.confined.walkBreadthFirst(dir, fn, listed)
+			}
+			return walkBreadthFirst(dir, fn, listed)
+		}
+		walkLinked = func(target, link string, fn fs.WalkDirFunc) error {
+			return walkBreadthFirst(target, fn, func(dir string) {
+				if rel, err := filepath.Rel(target, dir); err == nil {
+					listed(filepath.Join(link, rel))
+				}
+			})
+		}
+	}
+
 	// Create a context if not provided
 	if ctx == nil {
 		ctx = context.Background()
 	}
 
-	// Create a logger
-	logger := createLogger(LogLevelInfo)
-	defer logger.Sync()
-
 	// Create a channel for tasks
-	tasks := make(chan walkArgs, limit*2)
+	tasks := make(chan walkArgs, cfg.queue)
 
 	// Create a wait group for workers
 	var workerWg sync.WaitGroup
 	var tasksWg sync.WaitGroup
 
-	// Create a slice to collect errors
-	var walkErrors []error
-	var errLock sync.Mutex
+	// Collect errors, keeping at most cfg.maxErrors of them
+	walkErrors := newErrorCollector(cfg.maxErrors)
 
 	// Create a worker function
 	worker := func() {
@@ -682,54 +1521,111 @@ func walkLimitWithSymlinkHandling(ctx context.Context, root string, walkFn filep
 		for task := range tasks {
 			ret := walkFn(task.path, task.info, task.err)
 			if ret != nil {
-				errLock.Lock()
-				walkErrors = append(walkErrors, fmt.Errorf("path %q: %w", task.path, ret))
-				errLock.Unlock()
+				walkErrors.add(task.path, ret)
 			}
+			cfg.post.fileDone(task.path)
 			tasksWg.Done()
 		}
 	}
 
 	// Launch worker pool.
-	for i := 0; i < limit; i++ {
+	for i := 0; i < cfg.limit; i++ {
 		workerWg.Add(1)
 		go worker()
 	}
 
+	// Enumeration holds a token of the shared limiter, except while
+	// callbacks run and while the workers are too busy to take files
+	gate := newEnumGate(ctx, cfg.limiter)
+	enumFn := gate.pauseWalkFn(walkFn)
+
 	// Track visited paths to avoid cycles when following symlinks
 	var visitedPaths sync.Map
 
-	// Use filepath.WalkDir with custom symlink handling
-	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+	// Use filepath.WalkDir with custom symlink handling. Errors are passed to
+	// walkFn, which decides whether the walk goes on.
+	var visit fs.WalkDirFunc
+	visit = func(path string, d fs.DirEntry, err error) error {
 		if err != nil {
-			return err
+			// A directory entered before its read failed is read again
+			if d != nil && cfg.retry.again(path, err) {
+				return rereadDir(walkTree, path, visit)
+			}
+			cfg.tracker.skip(path)
+			return enumFn(path, nil, err)
 		}
 
 		if ctx.Err() != nil {
-			logger.Warn("walk canceled", zap.String("path", path))
+			// Running out of budget or SkipAll is reported by the caller
+			if cause := context.Cause(ctx); !errors.Is(cause, ErrBudgetExceeded) && !errors.Is(cause, ErrSkipAll) {
+				if ce := cfg.logger.Check(zap.WarnLevel, "walk canceled"); ce != nil {
+					ce.Write(zap.String("path", path))
+				}
+			}
 			return context.Canceled
 		}
 
+		// Excluded directories are neither stat'ed nor read
+		if cfg.prune != nil && d.IsDir() && cfg.prune(path) {
+			cfg.tracker.enter(path, true)
+			cfg.tracker.skip(path)
+			return filepath.SkipDir
+		}
+
 		// Get file info
-		fileInfo, err := d.Info()
-		if err != nil {
+		var fileInfo os.FileInfo
+		err = cfg.retry.call(path, func() (err error) {
+			fileInfo, err = entryInfo(path, d)
 			return err
+		})
+		if err != nil {
+			cfg.tracker.enter(path, d.IsDir())
+			return enumFn(path, nil, err)
+		}
+		if fileInfo.IsDir() && !d.IsDir() {
+			return walkListedDir(walkTree, path, visit)
 		}
 
 		// Handle symlinks based on the symlink handling mode
 		if fileInfo.Mode()&os.ModeSymlink != 0 {
-			switch symlinkHandling {
+			// Followed directory links are entered once their target is known
+			if cfg.symlinkHandling != SymlinkFollow && cfg.symlinkHandling != SymlinkFollowInternal {
+				cfg.tracker.enter(path, false)
+			}
+			switch cfg.symlinkHandling {
 			case SymlinkIgnore:
 				// Skip symlinks
+				cfg.tracker.link(false)
 				return nil
 			case SymlinkReport:
 				// Process symlinks as regular files/dirs without following
 				// No special handling needed
-			case SymlinkFollow:
-				// Follow symlinks
-				target, err := os.Readlink(path)
+			case SymlinkFollow, SymlinkFollowInternal:
+				// Follow symlinks, only within the root if requested
+				var target string
+				if cfg.symlinkHandling == SymlinkFollowInternal {
+					err = cfg.retry.call(path, func() (err error) {
+						target, err = resolveInternalSymlink(root, path)
+						return err
+					})
+					if errors.Is(err, ErrOutsideRoot) {
+						// Report the link to the callback and skip it
+						cfg.tracker.enter(path, false)
+						cfg.tracker.link(false)
+						if ret := enumFn(path, fileInfo, err); ret != nil && !errors.Is(ret, filepath.SkipDir) {
+							walkErrors.add(path, ret)
+						}
+						return nil
+					}
+				} else {
+					err = cfg.retry.call(path, func() (err error) {
+						target, err = os.Readlink(path)
+						return err
+					})
+				}
 				if err != nil {
-					return err
+					cfg.tracker.enter(path, false)
+					return enumFn(path, fileInfo, err)
 				}
 
 				// Make the target path absolute if it's not already
@@ -740,6 +1636,8 @@ func walkLimitWithSymlinkHandling(ctx context.Context, root string, walkFn filep
 				// Check for cycles
 				if _, visited := visitedPaths.Load(target); visited {
 					// Skip this symlink to avoid cycles
+					cfg.tracker.enter(path, false)
+					cfg.tracker.link(false)
 					return nil
 				}
 
@@ -747,28 +1645,43 @@ func walkLimitWithSymlinkHandling(ctx context.Context, root string, walkFn filep
 				visitedPaths.Store(target, true)
 
 				// Get info about the target
-				targetInfo, err := os.Stat(target)
-				if err != nil {
+				var targetInfo os.FileInfo
+				err = cfg.retry.call(path, func() (err error) {
+					targetInfo, err = os.Stat(target)
 					return err
+				})
+				if err != nil {
+					cfg.tracker.enter(path, false)
+					return enumFn(path, fileInfo, err)
+				}
+
+				// Skip targets already walked through another path
+				if targetInfo.IsDir() && !cfg.visited.visit(targetInfo) {
+					cfg.tracker.enter(path, false)
+					cfg.tracker.link(false)
+					return nil
 				}
+				cfg.tracker.link(true)
+				cfg.tracker.enterEntry(path, targetInfo)
 
 				// If the target is a directory, walk it
 				if targetInfo.IsDir() {
 					// Process the directory itself
-					ret := walkFn(path, targetInfo, nil)
+					ret := enumFn(path, targetInfo, nil)
 					if errors.Is(ret, filepath.SkipDir) {
-						return filepath.SkipDir
+						// The link is not a directory to WalkDir, where
+						// SkipDir would skip the rest of its parent
+						cfg.tracker.skip(path)
+						return nil
 					}
 					if ret != nil {
-						errLock.Lock()
-						walkErrors = append(walkErrors, fmt.Errorf("path %q: %w", path, ret))
-						errLock.Unlock()
+						walkErrors.add(path, ret)
 					}
 
 					// Walk the target directory
-					return filepath.WalkDir(target, func(targetPath string, targetD fs.DirEntry, targetErr error) error {
+					return walkLinked(target, path, func(targetPath string, targetD fs.DirEntry, targetErr error) error {
 						if targetErr != nil {
-							return targetErr
+							return enumFn(targetPath, nil, targetErr)
 						}
 
 						// Skip the root of the target directory as we've already processed it
@@ -776,112 +1689,150 @@ func walkLimitWithSymlinkHandling(ctx context.Context, root string, walkFn filep
 							return nil
 						}
 
-						// Get file info for the target
-						targetFileInfo, err := targetD.Info()
+						// Create a virtual path that preserves the original symlink path
+						relPath, err := filepath.Rel(target, targetPath)
 						if err != nil {
 							return err
 						}
+						virtualPath := filepath.Join(path, relPath)
+						if cfg.prune != nil && targetD.IsDir() && cfg.prune(virtualPath) {
+							cfg.tracker.enter(virtualPath, true)
+							cfg.tracker.skip(virtualPath)
+							return filepath.SkipDir
+						}
 
-						// Create a virtual path that preserves the original symlink path
-						relPath, err := filepath.Rel(target, targetPath)
+						// Get file info for the target
+						var targetFileInfo os.FileInfo
+						err = cfg.retry.call(targetPath, func() (err error) {
+							targetFileInfo, err = entryInfo(targetPath, targetD)
+							return err
+						})
 						if err != nil {
 							return err
 						}
-						virtualPath := filepath.Join(path, relPath)
+						if targetFileInfo.IsDir() && !cfg.visited.visit(targetFileInfo) {
+							cfg.tracker.enter(virtualPath, false)
+							return filepath.SkipDir
+						}
+						cfg.tracker.enterEntry(virtualPath, targetFileInfo)
 
 						// Process the file/directory
 						if targetFileInfo.IsDir() {
-							ret := walkFn(virtualPath, targetFileInfo, nil)
+							ret := enumFn(virtualPath, targetFileInfo, nil)
 							if errors.Is(ret, filepath.SkipDir) {
+								cfg.tracker.skip(virtualPath)
 								return filepath.SkipDir
 							}
 							if ret != nil {
-								errLock.Lock()
-								walkErrors = append(walkErrors, fmt.Errorf("path %q: %w", virtualPath, ret))
-								errLock.Unlock()
+								walkErrors.add(virtualPath, ret)
 							}
 						} else {
 							// For files, send the task to workers
+							cfg.post.dispatchFile(virtualPath)
+							cfg.prefetch.hint(virtualPath, targetFileInfo)
 							tasksWg.Add(1)
-							select {
-							case <-ctx.Done():
+							if !gate.send(ctx, tasks, walkArgs{path: virtualPath, info: targetFileInfo, err: nil}) {
+								cfg.post.fileDone(virtualPath)
 								tasksWg.Done()
 								return context.Canceled
-							case tasks <- walkArgs{path: virtualPath, info: targetFileInfo, err: nil}:
 							}
 						}
 						return nil
 					})
 				} else {
 					// For files, send the task to workers
+					cfg.post.dispatchFile(path)
+					cfg.prefetch.hint(path, targetInfo)
 					tasksWg.Add(1)
-					select {
-					case <-ctx.Done():
+					if !gate.send(ctx, tasks, walkArgs{path: path, info: targetInfo, err: nil}) {
+						cfg.post.fileDone(path)
 						tasksWg.Done()
 						return context.Canceled
-					case tasks <- walkArgs{path: path, info: targetInfo, err: nil}:
 					}
 					return nil
 				}
 			}
 		}
 
+		// Skip directories already walked through a followed link
+		if fileInfo.IsDir() && !cfg.visited.visit(fileInfo) {
+			cfg.tracker.enter(path, false)
+			return filepath.SkipDir
+		}
+		cfg.tracker.enterEntry(path, fileInfo)
+
 		// For directories, process synchronously so that SkipDir is honored.
 		if fileInfo.IsDir() {
-			ret := walkFn(path, fileInfo, nil)
+			ret := enumFn(path, fileInfo, nil)
 			if errors.Is(ret, filepath.SkipDir) {
+				cfg.tracker.skip(path)
 				return filepath.SkipDir
 			}
 			if ret != nil {
-				errLock.Lock()
-				walkErrors = append(walkErrors, fmt.Errorf("path %q: %w", path, ret))
-				errLock.Unlock()
+				walkErrors.add(path, ret)
 			}
 		} else {
 			// For files, send the task to workers.
+			cfg.post.dispatchFile(path)
+			cfg.prefetch.hint(path, fileInfo)
 			tasksWg.Add(1)
-			select {
-			case <-ctx.Done():
+			if !gate.send(ctx, tasks, walkArgs{path: path, info: fileInfo, err: nil}) {
+				cfg.post.fileDone(path)
 				tasksWg.Done()
 				return context.Canceled
-			case tasks <- walkArgs{path: path, info: fileInfo, err: nil}: // Pass fileInfo
 			}
 		}
 		return nil
-	})
+	}
+	err := walkTree(root, visit)
+	gate.done()
+
+	cfg.tracker.finish()
+	cfg.post.finish()
 
 	if err != nil && !errors.Is(err, filepath.SkipDir) {
-		errLock.Lock()
-		walkErrors = append(walkErrors, err)
-		errLock.Unlock()
+		walkErrors.add("", err)
 	}
 
 	close(tasks)
 	workerWg.Wait()
 
-	// Collect errors.
-	if len(walkErrors) > 0 {
-		// If there's only one error and it's context.Canceled, return it directly
-		if len(walkErrors) == 1 && errors.Is(walkErrors[0], context.Canceled) {
-			return context.Canceled
-		}
-
-		// Otherwise, create a combined error
-		var errMsg strings.Builder
-		errMsg.WriteString(fmt.Sprintf("%d errors occurred during walk:\n", len(walkErrors)))
-		for i, err := range walkErrors {
-			errMsg.WriteString(fmt.Sprintf("  %d: %v\n", i+1, err))
-		}
-		return errors.New(errMsg.String())
-	}
-
-	return nil
+	return walkErrors.err()
 }
 
 // --------------------------------------------------------------------------
 // Internal helper types and functions
 // --------------------------------------------------------------------------
 
+// includeRoot reports whether the root entry is passed to the callback.
+func (o WalkOptions) includeRoot() bool {
+	return o.IncludeRoot == nil || *o.IncludeRoot
+}
+
+// skipCloudPlaceholders reports whether cloud placeholders are left unread.
+func (o WalkOptions) skipCloudPlaceholders() bool {
+	return o.SkipCloudPlaceholders == nil || *o.SkipCloudPlaceholders
+}
+
+// progressInterval returns the time between progress reports.
+func (o WalkOptions) progressInterval() time.Duration {
+	if o.ProgressInterval <= 0 {
+		return DefaultProgressInterval
+	}
+	return o.ProgressInterval
+}
+
+// queueSize returns the capacity of the queue feeding workers: requested if
+// positive, queuePerWorker per worker otherwise, and never more than
+// MaxQueueSize.
+func queueSize(requested, workers int) int {
+	size := requested
+	if size <= 0 {
+		size = queuePerWorker * workers
+	}
+	return min(max(size, 1), MaxQueueSize)
+}
+
 // walkArgs holds the parameters passed to workers.
 type walkArgs struct {
 	path string
@@ -892,36 +1843,48 @@ type walkArgs struct {
 // filePassesFilter returns true if the file meets the filtering criteria.
 // It uses the full file path for symlink cycle detection.
 func filePassesFilter(path string, info os.FileInfo, filter FilterOptions, symlinkHandling SymlinkHandling) bool {
+	return checkFilter(path, info, filter, func(string) bool { return false })
+}
+
+// checkFilter evaluates filter against the file at path, passing the name of
+// each criterion the file does not meet to fail. Evaluation stops as soon as
+// fail returns false. It reports whether every evaluated criterion was met.
+func checkFilter(path string, info os.FileInfo, filter FilterOptions, fail func(criterion string) bool) bool {
+	passed := true
+	failed := func(criterion string) bool {
+		passed = false
+		return fail(criterion)
+	}
+
 	// Size checks.
-	if filter.MinSize > 0 && info.Size() < filter.MinSize {
+	if filter.MinSize > 0 && info.Size() < filter.MinSize && !failed("min_size") {
 		return false
 	}
-	if filter.MaxSize > 0 && info.Size() > filter.MaxSize {
+	if filter.MaxSize > 0 && info.Size() > filter.MaxSize && !failed("max_size") {
 		return false
 	}
 
 	// Modification time checks.
-	if !filter.ModifiedAfter.IsZero() && info.ModTime().Before(filter.ModifiedAfter) {
+	if !filter.ModifiedAfter.IsZero() && info.ModTime().Before(filter.ModifiedAfter) && !failed("modified_after") {
 		return false
 	}
-	if !filter.ModifiedBefore.IsZero() && info.ModTime().After(filter.ModifiedBefore) {
+	if !filter.ModifiedBefore.IsZero() && info.ModTime().After(filter.ModifiedBefore) && !failed("modified_before") {
 		return false
 	}
 
-	// Access and creation time checks (platform-dependent)
-	if runtime.GOOS != "windows" {
-		// On Unix-like systems, we can get access and creation times
-		var stat syscall.Stat_t
-		if err := syscall.Stat(path, &stat); err == nil {
+	// Access, creation time and ownership checks (platform-dependent). They
+	// use the same stat data as info, so a followed symlink is judged by its
+	// target throughout.
+	if runtime.GOOS != "windows" && needsStat(filter) {
+		stat, ok := fileStat(path, info)
+		if ok {
 			// Access time check
 			if !filter.AccessedAfter.IsZero() || !filter.AccessedBefore.IsZero() {
-				// Use a platform-independent approach to get atime
-				atime := getAccessTime(path, info)
-
-				if !filter.AccessedAfter.IsZero() && atime.Before(filter.AccessedAfter) {
+				atime := accessTime(stat)
+				if !filter.AccessedAfter.IsZero() && atime.Before(filter.AccessedAfter) && !failed("accessed_after") {
 					return false
 				}
-				if !filter.AccessedBefore.IsZero() && atime.After(filter.AccessedBefore) {
+				if !filter.AccessedBefore.IsZero() && atime.After(filter.AccessedBefore) && !failed("accessed_before") {
 					return false
 				}
 			}
@@ -929,69 +1892,55 @@ func filePassesFilter(path string, info os.FileInfo, filter FilterOptions, symli
 			// Creation time check (birthtime) - not available on all platforms
 			// This is a best-effort approach
 			if !filter.CreatedAfter.IsZero() || !filter.CreatedBefore.IsZero() {
-				// Use a platform-independent approach to get creation time
-				ctime := getCreationTime(path, info)
-
-				if !filter.CreatedAfter.IsZero() && ctime.Before(filter.CreatedAfter) {
+				ctime := creationTime(stat)
+				if !filter.CreatedAfter.IsZero() && ctime.Before(filter.CreatedAfter) && !failed("created_after") {
 					return false
 				}
-				if !filter.CreatedBefore.IsZero() && ctime.After(filter.CreatedBefore) {
+				if !filter.CreatedBefore.IsZero() && ctime.After(filter.CreatedBefore) && !failed("created_before") {
 					return false
 				}
 			}
 
 			// Owner and group checks
-			if filter.OwnerUID > 0 && int(stat.Uid) != filter.OwnerUID {
+			if filter.OwnerUID > 0 && int(stat.Uid) != filter.OwnerUID && !failed("owner_uid") {
 				return false
 			}
-			if filter.OwnerGID > 0 && int(stat.Gid) != filter.OwnerGID {
+			if filter.OwnerGID > 0 && int(stat.Gid) != filter.OwnerGID && !failed("owner_gid") {
 				return false
 			}
-		}
-	}
-
-	// Owner and group name checks
-	if filter.OwnerName != "" || filter.GroupName != "" {
-		if runtime.GOOS != "windows" {
-			var stat syscall.Stat_t
-			if err := syscall.Stat(path, &stat); err == nil {
-				// Check owner name
-				if filter.OwnerName != "" {
-					owner, err := user.LookupId(fmt.Sprintf("%d", stat.Uid))
-					if err != nil || owner.Username != filter.OwnerName {
-						return false
-					}
-				}
-
-				// Check group name
-				if filter.GroupName != "" {
-					group, err := user.LookupGroupId(fmt.Sprintf("%d", stat.Gid))
-					if err != nil || group.Name != filter.GroupName {
-						return false
-					}
-				}
+			if filter.OwnerName != "" && defaultNameCache.userName(stat.Uid) != filter.OwnerName && !failed("owner") {
+				return false
+			}
+			if filter.GroupName != "" && defaultNameCache.groupName(stat.Gid) != filter.GroupName && !failed("group") {
+				return false
 			}
 		}
 	}
 
 	// Glob pattern matching. Use info.Name() (base name) for pattern matching, not the full path!
-	if filter.Pattern != "" {
+	// Path patterns need the walk root and are checked by the walkers via filePatternRejects.
+	if filter.Pattern != "" && !isPathPattern(filter.Pattern) {
 		matched, err := filepath.Match(filter.Pattern, info.Name())
-		if err != nil || !matched {
+		if (err != nil || !matched) && !failed("pattern") {
 			return false
 		}
 	}
 
 	// Exclude pattern matching
-	if len(filter.ExcludePattern) > 0 {
-		for _, pattern := range filter.ExcludePattern {
-			matched, err := filepath.Match(pattern, info.Name())
-			if err == nil && matched {
+	for _, pattern := range filter.ExcludePattern {
+		if matched, err := filepath.Match(pattern, info.Name()); err == nil && matched {
+			if !failed("exclude_pattern") {
 				return false
 			}
+			break
 		}
 	}
 
+	// Editor and file manager droppings
+	if filter.SkipJunkFiles && !info.IsDir() && isJunk(info.Name()) && !failed("junk") {
+		return false
+	}
+
 	// Type filtering (extension check).
 	if len(filter.IncludeTypes) > 0 {
 		ext := filepath.Ext(path)
@@ -1002,85 +1951,53 @@ func filePassesFilter(path string, info os.FileInfo, filter FilterOptions, symli
 				break
 			}
 		}
-		if !matched {
+		if !matched && !failed("include_types") {
 			return false
 		}
 	}
 
 	// File type filtering
-	if len(filter.FileTypes) > 0 {
-		var found bool
-		mode := info.Mode()
-		for _, fileType := range filter.FileTypes {
-			switch fileType {
-			case "file":
-				if mode.IsRegular() {
-					found = true
-				}
-			case "dir":
-				if mode.IsDir() {
-					found = true
-				}
-			case "symlink":
-				if mode&os.ModeSymlink != 0 {
-					found = true
-				}
-			case "pipe":
-				if mode&os.ModeNamedPipe != 0 {
-					found = true
-				}
-			case "socket":
-				if mode&os.ModeSocket != 0 {
-					found = true
-				}
-			case "device":
-				if mode&os.ModeDevice != 0 {
-					found = true
-				}
-			case "char":
-				if mode&os.ModeCharDevice != 0 {
-					found = true
-				}
-			}
-			if found {
-				break
-			}
-		}
-		if !found {
-			return false
-		}
+	if len(filter.FileTypes) > 0 && !fileTypeMatches(info.Mode(), filter.FileTypes) && !failed("file_types") {
+		return false
+	}
+	if filter.OnlyBrokenSymlinks && !isBrokenSymlink(path, info.Mode()) && !failed("broken_symlinks") {
+		return false
 	}
 
 	// Empty file/directory check
-	if filter.IncludeEmptyFiles && !info.IsDir() && info.Size() > 0 {
+	if filter.IncludeEmptyFiles && !info.IsDir() && info.Size() > 0 && !failed("empty_files") {
 		return false
 	}
 	if filter.IncludeEmptyDirs && info.IsDir() {
-		// Check if directory is empty
-		empty, _ := isDirEmpty(path)
-		if !empty {
+		// Check if directory is empty, unless listing it would download it
+		var empty bool
+		if filter.readPlaceholders || !isCloudPlaceholder(path, info) {
+			empty, _ = isDirEmpty(path)
+		}
+		if !empty && !failed("empty_dirs") {
 			return false
 		}
 	}
 
 	// Permission filtering
-	mode := info.Mode().Perm() // Get just the permission bits
-	if filter.UseExactPermissions && filter.ExactPermissions != 0 {
-		// Exact permission matching
-		if mode != filter.ExactPermissions {
-			return false
-		}
-	} else {
-		// Range-based permission matching
-		if filter.MinPermissions != 0 && mode&filter.MinPermissions != filter.MinPermissions {
+	if !permissionsMatch(info.Mode(), filter) && !failed("permissions") {
+		return false
+	}
+
+	// File flags may take a system call per file, so they come last
+	if filter.RequireFlags != 0 || filter.ExcludeFlags != 0 {
+		flags := fileFlagsOf(path, info)
+		if flags&filter.RequireFlags != filter.RequireFlags && !failed("require_flags") {
 			return false
 		}
-		if filter.MaxPermissions != 0 && mode&^filter.MaxPermissions != 0 {
+		if flags&filter.ExcludeFlags != 0 && !failed("exclude_flags") {
 			return false
 		}
 	}
-
-	return true
+	if filter.HasExtendedACL && aclOf(path, info) == "" && !failed("extended_acl") {
+		return false
+	}
+	return passed
 }
 
 // isDirEmpty checks if a directory is empty
@@ -1121,34 +2038,82 @@ func createLogger(level LogLevel) *zap.Logger {
 		config = zap.NewProductionConfig()
 		config.Level = zap.NewAtomicLevelAt(zap.InfoLevel)
 	}
-	logger, _ := config.Build()
+	logger, err := config.Build()
+	if err != nil {
+		return zap.NewNop()
+	}
 	return logger
 }
 
-// hasFiles checks if a directory contains any entries.
-func hasFiles(dir string) bool {
-	entries, err := os.ReadDir(dir) // Use the faster ReadDir
-	return err == nil && len(entries) > 0
+// defaultLoggers holds the loggers of walks without WalkOptions.Logger,
+// one per level, built on first use and shared by every walk since
+// building one costs more than many small walks.
+var defaultLoggers [LogLevelDebug + 1]struct {
+	once   sync.Once
+	logger *zap.Logger
 }
 
-// getAccessTime returns the access time of a file
-func getAccessTime(path string, info os.FileInfo) time.Time {
-	// Use a platform-independent approach to get atime
-	var stat syscall.Stat_t
-	if err := syscall.Stat(path, &stat); err == nil {
-		return time.Unix(stat.Atimespec.Sec, stat.Atimespec.Nsec)
+// defaultLogger returns the shared logger for level. It writes to stderr
+// unbuffered, so it is never synced.
+func defaultLogger(level LogLevel) *zap.Logger {
+	if level < LogLevelError || level > LogLevelDebug {
+		level = LogLevelInfo
 	}
-	return time.Time{}
+	l := &defaultLoggers[level]
+	l.once.Do(func() {
+		l.logger = createLogger(level)
+	})
+	return l.logger
 }
 
-// getCreationTime returns the creation time of a file
-func getCreationTime(path string, info os.FileInfo) time.Time {
-	// Use a platform-independent approach to get creation time
+// logger returns opts.Logger, or the shared logger for opts.LogLevel.
+func (opts WalkOptions) logger() *zap.Logger {
+	if opts.Logger != nil {
+		return opts.Logger
+	}
+	return defaultLogger(opts.LogLevel)
+}
+
+// needsStat reports whether filter checks anything beyond os.FileInfo.
+func needsStat(filter FilterOptions) bool {
+	return !filter.AccessedAfter.IsZero() || !filter.AccessedBefore.IsZero() ||
+		!filter.CreatedAfter.IsZero() || !filter.CreatedBefore.IsZero() ||
+		filter.OwnerUID > 0 || filter.OwnerGID > 0 ||
+		filter.OwnerName != "" || filter.GroupName != ""
+}
+
+// entryInfo returns the FileInfo of an entry enumerated by a walk. Tests
+// replace it to count which entries are stat'ed.
+var entryInfo = func(path string, d fs.DirEntry) (fs.FileInfo, error) {
+	return d.Info()
+}
+
+// statPath is the fallback used by fileStat, replaceable in tests.
+var statPath = syscall.Stat
+
+// fileStat returns the stat data behind info. Only when info does not carry
+// it is path stat'ed again.
+func fileStat(path string, info os.FileInfo) (*syscall.Stat_t, bool) {
+	if info != nil {
+		if stat, ok := info.Sys().(*syscall.Stat_t); ok {
+			return stat, true
+		}
+	}
 	var stat syscall.Stat_t
-	if err := syscall.Stat(path, &stat); err == nil {
-		return time.Unix(stat.Birthtimespec.Sec, stat.Birthtimespec.Nsec)
+	if err := statPath(path, &stat); err != nil {
+		return nil, false
 	}
-	return time.Time{}
+	return &stat, true
+}
+
+// accessTime returns the access time recorded in stat.
+func accessTime(stat *syscall.Stat_t) time.Time {
+	return time.Unix(stat.Atimespec.Sec, stat.Atimespec.Nsec)
+}
+
+// creationTime returns the creation time recorded in stat.
+func creationTime(stat *syscall.Stat_t) time.Time {
+	return time.Unix(stat.Birthtimespec.Sec, stat.Birthtimespec.Nsec)
 }
 
 // WalkFunc defines the signature for file processing callbacks.
@@ -1168,8 +2133,8 @@ const (
 
 // MemoryLimitOptions sets memory usage boundaries for the traversal.
 type MemoryLimitOptions struct {
-	SoftLimit int64
-	HardLimit int64
+	SoftLimit int64 // Not enforced
+	HardLimit int64 // Bytes of live heap objects past which the walk stops, checked every 50ms
 }
 
 // MiddlewareFunc defines a middleware function for extensibility.
@@ -1178,6 +2143,34 @@ type MiddlewareFunc func(next WalkFunc) WalkFunc
 // WalkWithOptions traverses the file tree rooted at root, calling the user-provided walkFn
 // for each file or directory in the tree, including root, with the enhanced context-aware API.
 func WalkWithOptions(root string, walkFn WalkFunc, options WalkOptions) error {
+	options, release, err := confineOptions(root, options)
+	if err != nil {
+		return err
+	}
+	defer release()
+	ctx, adaptedWalkFn, options := adaptWalkOptions(walkFn, options)
+
+	// Use the existing implementation but with our adapted walkFn
+	return WalkLimitWithOptions(ctx, root, adaptedWalkFn, options)
+}
+
+// WalkWithOptionsAndStats is like WalkWithOptions but also returns the final
+// traversal statistics, including ElapsedTime and derived fields, computed
+// after all workers have drained. The result equals the last snapshot passed
+// to options.Progress, if set.
+func WalkWithOptionsAndStats(root string, walkFn WalkFunc, options WalkOptions) (Stats, error) {
+	options, release, err := confineOptions(root, options)
+	if err != nil {
+		return Stats{}, err
+	}
+	defer release()
+	ctx, adaptedWalkFn, options := adaptWalkOptions(walkFn, options)
+	return WalkLimitWithOptionsStats(ctx, root, adaptedWalkFn, options)
+}
+
+// adaptWalkOptions resolves the context, middleware chain and error handling
+// mode of options for use with the classic filepath.WalkFunc API.
+func adaptWalkOptions(walkFn WalkFunc, options WalkOptions) (context.Context, filepath.WalkFunc, WalkOptions) {
 	// Default context if not provided
 	ctx := options.Context
 	if ctx == nil {
@@ -1186,6 +2179,9 @@ func WalkWithOptions(root string, walkFn WalkFunc, options WalkOptions) error {
 
 	// Convert the enhanced WalkFunc to the standard filepath.WalkFunc
 	adaptedWalkFn := func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
 		return walkFn(ctx, path, info)
 	}
 
@@ -1199,24 +2195,31 @@ func WalkWithOptions(root string, walkFn WalkFunc, options WalkOptions) error {
 
 		// Update the adapted function with the middleware-wrapped one
 		adaptedWalkFn = func(path string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
 			return wrappedFn(ctx, path, info)
 		}
 	}
 
-	// Convert ErrorHandlingMode to ErrorHandling if needed
-	if options.ErrorHandlingMode != "" && options.ErrorHandling == 0 {
-		switch options.ErrorHandlingMode {
+	options.ErrorHandling = options.errorHandling()
+	return ctx, adaptedWalkFn, options
+}
+
+// errorHandling returns the error handling mode of o, converting
+// ErrorHandlingMode to ErrorHandling if only the former is set.
+func (o WalkOptions) errorHandling() ErrorHandling {
+	if o.ErrorHandlingMode != "" && o.ErrorHandling == 0 {
+		switch o.ErrorHandlingMode {
 		case ContinueOnError:
-			options.ErrorHandling = ErrorHandlingContinue
+			return ErrorHandlingContinue
 		case StopOnError:
-			options.ErrorHandling = ErrorHandlingStop
+			return ErrorHandlingStop
 		case SkipOnError:
-			options.ErrorHandling = ErrorHandlingSkip
+			return ErrorHandlingSkip
 		}
 	}
-
-	// Use the existing implementation but with our adapted walkFn
-	return WalkLimitWithOptions(ctx, root, adaptedWalkFn, options)
+	return o.ErrorHandling
 }
 
 // WalkWithAdvancedOptions traverses the file tree rooted at root, calling the user-provided advanced walkFn
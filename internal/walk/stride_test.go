@@ -2,12 +2,21 @@ package stride
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"io/fs"
 	"os"
 	"path/filepath"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
 	"sync/atomic"
 	"testing"
 	"time"
+
+	"github.com/TFMV/stride/walk/walktest"
 )
 
 // TestWalk tests the basic Walk function
@@ -110,6 +119,75 @@ func TestWalkLimitWithProgress(t *testing.T) {
 	}
 }
 
+// TestWalkLimitWithProgressErrorHandling tests that callback errors follow
+// the error handling mode
+func TestWalkLimitWithProgressErrorHandling(t *testing.T) {
+	root := walktest.Tree{
+		"a.txt":        walktest.File{},
+		"b.txt":        walktest.File{},
+		"bad1.txt":     walktest.File{},
+		"sub/c.txt":    walktest.File{},
+		"sub/bad2.txt": walktest.File{},
+		"bad3/d.txt":   walktest.File{},
+		"bad3/e.txt":   walktest.File{},
+	}.Build(t)
+	failing := errors.New("callback failed")
+
+	tests := []struct {
+		name      string
+		handling  ErrorHandling
+		wantErr   bool
+		wantFiles int64 // Files reaching the callback, or -1 to skip the check
+	}{
+		{"continue", ErrorHandlingContinue, false, 7},
+		{"skip", ErrorHandlingSkip, false, 5},
+		{"stop", ErrorHandlingStop, true, -1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var final Stats
+			err := WalkLimitWithProgressAndOptions(context.Background(), root, func(path string, info os.FileInfo, err error) error {
+				if strings.HasPrefix(filepath.Base(path), "bad") {
+					return failing
+				}
+				return nil
+			}, 1, func(stats Stats) {
+				final = stats
+			}, tt.handling)
+
+			if (err != nil) != tt.wantErr || (err != nil && !errors.Is(err, failing)) {
+				t.Errorf("Expected error %v, got %v", tt.wantErr, err)
+			}
+			if tt.wantErr {
+				if final.ErrorCount < 1 {
+					t.Errorf("Expected at least 1 error counted, got %d", final.ErrorCount)
+				}
+				return
+			}
+			if final.ErrorCount != 3 {
+				t.Errorf("Expected 3 errors counted, got %d", final.ErrorCount)
+			}
+			if final.FilesProcessed != tt.wantFiles {
+				t.Errorf("Expected %d files, got %d", tt.wantFiles, final.FilesProcessed)
+			}
+		})
+	}
+
+	// Without a mode the errors are returned, still counted once each
+	var final Stats
+	err := WalkLimitWithProgress(context.Background(), root, func(path string, info os.FileInfo, err error) error {
+		if strings.HasPrefix(filepath.Base(path), "bad") {
+			return failing
+		}
+		return nil
+	}, 2, func(stats Stats) {
+		final = stats
+	})
+	if !errors.Is(err, failing) || final.ErrorCount != 3 {
+		t.Errorf("Expected the 3 callback errors, got %d and %v", final.ErrorCount, err)
+	}
+}
+
 // TestWalkLimitWithFilter tests the filtering functionality
 func TestWalkLimitWithFilter(t *testing.T) {
 	ctx := context.Background()
@@ -299,7 +377,7 @@ func TestCancelContext(t *testing.T) {
 		return nil
 	}, 2)
 
-	if err == nil || (err != context.Canceled && err.Error() != "context canceled") {
+	if !errors.Is(err, context.Canceled) || !errors.Is(err, ErrCanceledByUser) {
 		t.Errorf("Expected context.Canceled error, got %v", err)
 	}
 }
@@ -424,6 +502,177 @@ func TestShouldSkipDir(t *testing.T) {
 	}
 }
 
+// TestExcludeDirRegex tests excluding directories by root-relative path regexes
+func TestExcludeDirRegex(t *testing.T) {
+	root := t.TempDir()
+	files := []string{
+		"main.go",
+		"node_modules/top.js",
+		"a/node_modules/mid.js",
+		"a/b/node_modules/deep.js",
+		"src/generated/gen.go",
+		"third_party/lib/generated/gen.go",
+		"third_party/lib/src/lib.go",
+	}
+	for _, name := range files {
+		path := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("Failed to create directory: %v", err)
+		}
+		if err := os.WriteFile(path, []byte("x"), 0644); err != nil {
+			t.Fatalf("Failed to create file: %v", err)
+		}
+	}
+
+	tests := []struct {
+		name     string
+		root     string
+		patterns []string
+		excludes []string
+		expected []string
+	}{
+		{
+			name:     "Anchored nested path",
+			patterns: []string{`^third_party/.*/generated$`},
+			expected: []string{"a/b/node_modules/deep.js", "a/node_modules/mid.js", "main.go", "node_modules/top.js", "src/generated/gen.go", "third_party/lib/src/lib.go"},
+		},
+		{
+			name:     "Unanchored",
+			patterns: []string{`generated`},
+			expected: []string{"a/b/node_modules/deep.js", "a/node_modules/mid.js", "main.go", "node_modules/top.js", "third_party/lib/src/lib.go"},
+		},
+		{
+			name:     "Depth specific",
+			patterns: []string{`^[^/]+/node_modules$`},
+			expected: []string{"a/b/node_modules/deep.js", "main.go", "node_modules/top.js", "src/generated/gen.go", "third_party/lib/generated/gen.go", "third_party/lib/src/lib.go"},
+		},
+		{
+			name:     "Top level directory",
+			patterns: []string{`^third_party$`},
+			expected: []string{"a/b/node_modules/deep.js", "a/node_modules/mid.js", "main.go", "node_modules/top.js", "src/generated/gen.go"},
+		},
+		{
+			name:     "Root with trailing separator",
+			root:     root + string(os.PathSeparator),
+			patterns: []string{`^third_party/.*/generated$`, `^node_modules$`},
+			expected: []string{"a/b/node_modules/deep.js", "a/node_modules/mid.js", "main.go", "src/generated/gen.go", "third_party/lib/src/lib.go"},
+		},
+		{
+			name:     "Combined with basename globs",
+			patterns: []string{`^src$`},
+			excludes: []string{"node_modules"},
+			expected: []string{"main.go", "third_party/lib/generated/gen.go", "third_party/lib/src/lib.go"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			walkRoot := root
+			if tt.root != "" {
+				walkRoot = tt.root
+			}
+			filter := FilterOptions{ExcludeDir: tt.excludes}
+			for _, p := range tt.patterns {
+				filter.ExcludeDirRegex = append(filter.ExcludeDirRegex, regexp.MustCompile(p))
+			}
+
+			var mu sync.Mutex
+			var got []string
+			err := WalkLimitWithOptions(context.Background(), walkRoot, func(path string, info os.FileInfo, err error) error {
+				if err != nil || info.IsDir() {
+					return err
+				}
+				rel, err := filepath.Rel(root, path)
+				if err != nil {
+					return err
+				}
+				mu.Lock()
+				got = append(got, filepath.ToSlash(rel))
+				mu.Unlock()
+				return nil
+			}, WalkOptions{Filter: filter})
+			if err != nil {
+				t.Fatalf("Walk failed: %v", err)
+			}
+
+			sort.Strings(got)
+			if strings.Join(got, ",") != strings.Join(tt.expected, ",") {
+				t.Errorf("Expected %v, got %v", tt.expected, got)
+			}
+		})
+	}
+}
+
+// TestDotDotNames checks that names starting with dots, such as ..cache,
+// are taken to be inside the root, unlike .. itself.
+func TestDotDotNames(t *testing.T) {
+	root := filepath.Join(string(filepath.Separator), "root")
+	inside := filepath.Join(root, "..cache", "x")
+
+	if !matchesExcludeDirRegex(filepath.Join(root, "..cache"), root, []*regexp.Regexp{regexp.MustCompile(`^\.\.cache$`)}) {
+		t.Error("Expected ..cache to match the exclude regex")
+	}
+	if !matchesExcludeDirRegex(inside, root, []*regexp.Regexp{regexp.MustCompile(`^\.\.cache$`)}) {
+		t.Error("Expected ..cache/x to be excluded with ..cache")
+	}
+	if matchesExcludeDirRegex(filepath.Join(root, "..", "other"), root, []*regexp.Regexp{regexp.MustCompile(`other`)}) {
+		t.Error("Expected a directory outside the root not to match")
+	}
+
+	if got := relSlashPath(root, inside); got != "..cache/x" {
+		t.Errorf("Expected ..cache/x, got %s", got)
+	}
+	if got := relSlashPath(root, filepath.Join(root, "...")); got != "..." {
+		t.Errorf("Expected ..., got %s", got)
+	}
+	outside := filepath.Join(string(filepath.Separator), "other")
+	if got := relSlashPath(root, outside); got != filepath.ToSlash(outside) {
+		t.Errorf("Expected %s, got %s", filepath.ToSlash(outside), got)
+	}
+
+	for path, want := range map[string]bool{
+		"..cache":                     true,
+		filepath.Join("..cache", "x"): true,
+		"...":                         true,
+		"..":                          false,
+		filepath.Join("..", "x"):      false,
+		".":                           false,
+	} {
+		if got := isWithinDir(".", path); got != want {
+			t.Errorf("Expected isWithinDir(., %s) to be %v, got %v", path, want, got)
+		}
+	}
+}
+
+// TestExcludeDirPerWalk checks that a directory excluded by one walk is
+// still walked by another with different filters.
+func TestExcludeDirPerWalk(t *testing.T) {
+	root := walktest.Tree{
+		"main.go":       walktest.File{Content: "main"},
+		"vendor/lib.go": walktest.File{Content: "lib"},
+	}.Build(t)
+
+	count := func(filter FilterOptions) int {
+		var n atomic.Int32
+		err := WalkLimitWithFilter(context.Background(), root, func(path string, info os.FileInfo, err error) error {
+			if err == nil && !info.IsDir() {
+				n.Add(1)
+			}
+			return err
+		}, 2, filter)
+		if err != nil {
+			t.Fatalf("Walk failed: %v", err)
+		}
+		return int(n.Load())
+	}
+	if n := count(FilterOptions{ExcludeDir: []string{"vendor"}}); n != 1 {
+		t.Errorf("Expected 1 file with vendor excluded, got %d", n)
+	}
+	if n := count(FilterOptions{}); n != 2 {
+		t.Errorf("Expected 2 files without exclusions, got %d", n)
+	}
+}
+
 // TestStatsUpdateDerivedStats tests the updateDerivedStats method
 func TestStatsUpdateDerivedStats(t *testing.T) {
 	stats := Stats{
@@ -445,6 +694,199 @@ func TestStatsUpdateDerivedStats(t *testing.T) {
 	}
 }
 
+// TestWalkWithOptionsAndStats tests that the returned stats are final and
+// match both the fixture and the last progress report
+func TestWalkWithOptionsAndStats(t *testing.T) {
+	root := t.TempDir()
+	for i := 0; i < 50; i++ {
+		dir := filepath.Join(root, fmt.Sprintf("dir%d", i%5), fmt.Sprintf("sub%d", i%3))
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatalf("Failed to create directory: %v", err)
+		}
+		data := make([]byte, i*100)
+		if err := os.WriteFile(filepath.Join(dir, fmt.Sprintf("file%d.txt", i)), data, 0644); err != nil {
+			t.Fatalf("Failed to create file: %v", err)
+		}
+	}
+	if err := os.MkdirAll(filepath.Join(root, "empty", "nested"), 0755); err != nil {
+		t.Fatalf("Failed to create directory: %v", err)
+	}
+
+	// Count the fixture independently
+	var want Stats
+	err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			want.DirsProcessed++
+			entries, err := os.ReadDir(path)
+			if err != nil {
+				return err
+			}
+			if len(entries) == 0 {
+				want.EmptyDirs++
+			}
+			return nil
+		}
+		want.FilesProcessed++
+		want.BytesProcessed += info.Size()
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Failed to count fixture: %v", err)
+	}
+
+	check := func(t *testing.T, got Stats, last Stats, calls int) {
+		if got.FilesProcessed != want.FilesProcessed || got.DirsProcessed != want.DirsProcessed ||
+			got.BytesProcessed != want.BytesProcessed || got.EmptyDirs != want.EmptyDirs {
+			t.Errorf("Expected %d files, %d dirs, %d bytes, %d empty dirs; got %d, %d, %d, %d",
+				want.FilesProcessed, want.DirsProcessed, want.BytesProcessed, want.EmptyDirs,
+				got.FilesProcessed, got.DirsProcessed, got.BytesProcessed, got.EmptyDirs)
+		}
+		if got.ElapsedTime <= 0 {
+			t.Error("Expected positive elapsed time")
+		}
+		if got.AvgFileSize != got.BytesProcessed/got.FilesProcessed {
+			t.Errorf("Expected average file size %d, got %d", got.BytesProcessed/got.FilesProcessed, got.AvgFileSize)
+		}
+		if calls == 0 {
+			t.Fatal("Expected at least one progress report")
+		}
+		if !reflect.DeepEqual(got, last) {
+			t.Errorf("Returned stats %+v differ from final progress report %+v", got, last)
+		}
+	}
+
+	t.Run("WalkWithOptionsAndStats", func(t *testing.T) {
+		var mu sync.Mutex
+		var last Stats
+		calls := 0
+		got, err := WalkWithOptionsAndStats(root, func(ctx context.Context, path string, info os.FileInfo) error {
+			return nil
+		}, WalkOptions{
+			NumWorkers: 4,
+			Progress: func(s Stats) {
+				mu.Lock()
+				last = s
+				calls++
+				mu.Unlock()
+			},
+		})
+		if err != nil {
+			t.Fatalf("Walk failed: %v", err)
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		check(t, got, last, calls)
+	})
+
+	t.Run("WalkLimitWithOptionsStats", func(t *testing.T) {
+		var mu sync.Mutex
+		var last Stats
+		calls := 0
+		got, err := WalkLimitWithOptionsStats(context.Background(), root, func(path string, info os.FileInfo, err error) error {
+			return err
+		}, WalkOptions{
+			NumWorkers: 4,
+			Progress: func(s Stats) {
+				mu.Lock()
+				last = s
+				calls++
+				mu.Unlock()
+			},
+		})
+		if err != nil {
+			t.Fatalf("Walk failed: %v", err)
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		check(t, got, last, calls)
+	})
+
+	t.Run("Without progress", func(t *testing.T) {
+		got, err := WalkLimitWithOptionsStats(context.Background(), root, func(path string, info os.FileInfo, err error) error {
+			return err
+		}, WalkOptions{NumWorkers: 4})
+		if err != nil {
+			t.Fatalf("Walk failed: %v", err)
+		}
+		check(t, got, got, 1)
+	})
+}
+
+// TestWalkStatsFilteredAndLinks tests the filtered, symlink and largest
+// file counters
+func TestWalkStatsFilteredAndLinks(t *testing.T) {
+	root := walktest.Tree{
+		"small.txt":      walktest.File{Content: "a"},
+		"medium.txt":     walktest.File{Content: strings.Repeat("b", 100)},
+		"sub/large.txt":  walktest.File{Content: strings.Repeat("c", 1000)},
+		"sub/tiny.txt":   walktest.File{Content: "d"},
+		"skip/other.txt": walktest.File{Content: "e"},
+		"link.txt":       walktest.Symlink{Target: "medium.txt"},
+		"linkdir":        walktest.Symlink{Target: "sub"},
+	}.Build(t)
+	walk := func(opts WalkOptions) Stats {
+		t.Helper()
+		stats, err := WalkLimitWithOptionsStats(context.Background(), root, func(path string, info os.FileInfo, err error) error {
+			return err
+		}, opts)
+		if err != nil {
+			t.Fatalf("Walk failed: %v", err)
+		}
+		return stats
+	}
+
+	// A size filter rejects the small files, an excluded directory counts once
+	stats := walk(WalkOptions{
+		Filter:          FilterOptions{MinSize: 10, ExcludeDir: []string{"skip"}},
+		SymlinkHandling: SymlinkIgnore,
+	})
+	if stats.FilesFiltered != 3 {
+		t.Errorf("Expected 3 entries filtered, got %d", stats.FilesFiltered)
+	}
+	if stats.FilesProcessed != 2 {
+		t.Errorf("Expected 2 files processed, got %d", stats.FilesProcessed)
+	}
+	if stats.AvgFileSize != 550 {
+		t.Errorf("Expected the average of the processed files only, 550, got %d", stats.AvgFileSize)
+	}
+	if stats.MaxFileSize != 1000 || stats.MaxFilePath != filepath.Join(root, "sub", "large.txt") {
+		t.Errorf("Expected the largest file sub/large.txt of 1000 bytes, got %s of %d", stats.MaxFilePath, stats.MaxFileSize)
+	}
+	if stats.SymlinksSkipped != 2 || stats.SymlinksFollowed != 0 {
+		t.Errorf("Expected 2 links skipped and none followed, got %d and %d", stats.SymlinksSkipped, stats.SymlinksFollowed)
+	}
+
+	// Without filters nothing is filtered, and followed links are counted
+	stats = walk(WalkOptions{SymlinkHandling: SymlinkFollow, AllowRevisit: true})
+	if stats.FilesFiltered != 0 {
+		t.Errorf("Expected no entries filtered, got %d", stats.FilesFiltered)
+	}
+	if stats.SymlinksFollowed != 2 || stats.SymlinksSkipped != 0 {
+		t.Errorf("Expected 2 links followed and none skipped, got %d and %d", stats.SymlinksFollowed, stats.SymlinksSkipped)
+	}
+
+	// Reported links are neither followed nor skipped
+	stats = walk(WalkOptions{SymlinkHandling: SymlinkReport})
+	if stats.SymlinksFollowed != 0 || stats.SymlinksSkipped != 0 {
+		t.Errorf("Expected no links followed or skipped, got %d and %d", stats.SymlinksFollowed, stats.SymlinksSkipped)
+	}
+
+	// WalkLimitWithProgress tracks the largest file too
+	var last Stats
+	err := WalkLimitWithProgress(context.Background(), root, func(path string, info os.FileInfo, err error) error {
+		return err
+	}, 2, func(s Stats) { last = s })
+	if err != nil {
+		t.Fatalf("WalkLimitWithProgress failed: %v", err)
+	}
+	if last.MaxFileSize != 1000 || last.MaxFilePath != filepath.Join(root, "sub", "large.txt") {
+		t.Errorf("Expected the largest file sub/large.txt of 1000 bytes, got %s of %d", last.MaxFilePath, last.MaxFileSize)
+	}
+}
+
 // TestCreateLogger tests the createLogger function
 func TestCreateLogger(t *testing.T) {
 	tests := []struct {
@@ -580,3 +1022,144 @@ func BenchmarkShouldSkipDir(b *testing.B) {
 		shouldSkipDir("testdata/dir1/subdir1", "testdata", excludes)
 	}
 }
+
+func TestIncludeRoot(t *testing.T) {
+	root := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(root, "sub"), 0755); err != nil {
+		t.Fatalf("Failed to create directory: %v", err)
+	}
+	for _, name := range []string{"a.txt", filepath.Join("sub", "b.txt")} {
+		if err := os.WriteFile(filepath.Join(root, name), []byte("x"), 0644); err != nil {
+			t.Fatalf("Failed to create file: %v", err)
+		}
+	}
+	boolPtr := func(b bool) *bool { return &b }
+
+	// collect returns a function recording the root-relative paths it sees
+	collect := func(base string) (*[]string, func(path string)) {
+		var mu sync.Mutex
+		var seen []string
+		return &seen, func(path string) {
+			rel, _ := filepath.Rel(base, path)
+			mu.Lock()
+			seen = append(seen, filepath.ToSlash(rel))
+			mu.Unlock()
+		}
+	}
+	hasRoot := func(paths []string) bool {
+		for _, p := range paths {
+			if p == "." {
+				return true
+			}
+		}
+		return false
+	}
+
+	t.Run("Walk", func(t *testing.T) {
+		seen, record := collect(root)
+		err := Walk(root, func(path string, info os.FileInfo, err error) error {
+			record(path)
+			return err
+		})
+		if err != nil {
+			t.Fatalf("Walk failed: %v", err)
+		}
+		if !hasRoot(*seen) {
+			t.Errorf("Expected the root in %v", *seen)
+		}
+	})
+
+	for _, walkRoot := range []string{root, "."} {
+		tests := []struct {
+			name        string
+			includeRoot *bool
+			minDepth    int
+			wantRoot    bool
+			wantCount   int
+		}{
+			{"Default", nil, 0, true, 4},
+			{"Default with MinDepth", nil, 1, true, 4},
+			{"Included with MinDepth 2", boolPtr(true), 2, true, 2},
+			{"Excluded", boolPtr(false), 0, false, 3},
+			{"Excluded with MinDepth", boolPtr(false), 1, false, 3},
+		}
+		for _, tc := range tests {
+			t.Run(fmt.Sprintf("%s/relative=%v", tc.name, walkRoot == "."), func(t *testing.T) {
+				if walkRoot == "." {
+					t.Chdir(root)
+				}
+				opts := WalkOptions{IncludeRoot: tc.includeRoot, Filter: FilterOptions{MinDepth: tc.minDepth}}
+
+				seen, record := collect(walkRoot)
+				err := WalkLimitWithOptions(context.Background(), walkRoot, func(path string, info os.FileInfo, err error) error {
+					record(path)
+					return err
+				}, opts)
+				if err != nil {
+					t.Fatalf("WalkLimitWithOptions failed: %v", err)
+				}
+				if hasRoot(*seen) != tc.wantRoot || len(*seen) != tc.wantCount {
+					t.Errorf("WalkLimitWithOptions: got %v, want root=%v and %d entries", *seen, tc.wantRoot, tc.wantCount)
+				}
+
+				seen, record = collect(walkRoot)
+				err = WalkDir(walkRoot, func(ctx context.Context, path string, d fs.DirEntry) error {
+					record(path)
+					return nil
+				}, opts)
+				if err != nil {
+					t.Fatalf("WalkDir failed: %v", err)
+				}
+				if hasRoot(*seen) != tc.wantRoot || len(*seen) != tc.wantCount {
+					t.Errorf("WalkDir: got %v, want root=%v and %d entries", *seen, tc.wantRoot, tc.wantCount)
+				}
+			})
+		}
+	}
+
+	for _, includeRoot := range []bool{false, true} {
+		t.Run(fmt.Sprintf("Find/IncludeRoot=%v", includeRoot), func(t *testing.T) {
+			seen, record := collect(root)
+			opts := FindOptions{NamePattern: "*.txt", MaxDepth: 5, IncludeRoot: includeRoot}
+			err := Find(context.Background(), root, opts, func(ctx context.Context, result FindResult) error {
+				if result.Error != nil {
+					return result.Error
+				}
+				record(result.Message.Path)
+				return nil
+			})
+			if err != nil {
+				t.Fatalf("Find failed: %v", err)
+			}
+			if hasRoot(*seen) != includeRoot {
+				t.Errorf("Expected root=%v in %v", includeRoot, *seen)
+			}
+			if includeRoot && (*seen)[0] != "." {
+				t.Errorf("Expected the root first, got %v", *seen)
+			}
+			want := 2
+			if includeRoot {
+				want++
+			}
+			if len(*seen) != want {
+				t.Errorf("Expected %d results, got %v", want, *seen)
+			}
+		})
+	}
+
+	// A relative root is not treated as hidden
+	t.Run("Find/dot root", func(t *testing.T) {
+		t.Chdir(root)
+		var count int
+		err := Find(context.Background(), ".", FindOptions{NamePattern: "*.txt", MaxDepth: 5}, func(ctx context.Context, result FindResult) error {
+			count++
+			return result.Error
+		})
+		if err != nil {
+			t.Fatalf("Find failed: %v", err)
+		}
+		if count != 2 {
+			t.Errorf("Expected 2 matches, got %d", count)
+		}
+	})
+}
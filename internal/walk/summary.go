@@ -0,0 +1,44 @@
+package stride
+
+import (
+	"time"
+)
+
+// Summary describes a completed walk: its final statistics, the errors it
+// met at each path, and when it ran.
+type Summary struct {
+	Stats
+
+	// Errors lists the errors met at entries, and returned by the callback,
+	// in the order they occurred. Unlike the walk's error, repeats of one
+	// error in a directory are all listed, up to
+	// WalkOptions.MaxRetainedErrors (DefaultMaxRetainedErrors if 0);
+	// Stats.ErrorCount counts them all.
+	Errors []WalkError `json:",omitempty"`
+
+	Start time.Time // When the walk started
+	End   time.Time // When the walk returned
+}
+
+// WalkWithSummary is like WalkWithOptionsAndStats, but returns a Summary of
+// the walk, which also lists the errors it met under every error handling
+// mode, including those ErrorHandlingContinue otherwise only counts. The
+// error is that of WalkWithOptions.
+func WalkWithSummary(root string, walkFn WalkFunc, options WalkOptions) (Summary, error) {
+	options, release, err := confineOptions(root, options)
+	if err != nil {
+		return Summary{}, err
+	}
+	defer release()
+	ctx, adaptedWalkFn, options := adaptWalkOptions(walkFn, options)
+	options.errorLog = newErrorLog(options.MaxRetainedErrors)
+
+	start := time.Now()
+	stats, err := walkLimitWithOptions(ctx, root, adaptedWalkFn, options, true, nil)
+	return Summary{
+		Stats:  stats,
+		Errors: options.errorLog.entries(),
+		Start:  start,
+		End:    time.Now(),
+	}, err
+}
@@ -0,0 +1,71 @@
+package stride
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/TFMV/stride/walk/walktest"
+)
+
+func TestWalkWithSummary(t *testing.T) {
+	root := walktest.Tree{
+		"a.txt":      walktest.File{Content: "a"},
+		"sub/b.txt":  walktest.File{Content: "bb"},
+		"sub/bad.go": walktest.File{Content: "x"},
+		"dangling":   walktest.Symlink{Target: "missing"},
+	}.Build(t)
+	errBad := errors.New("cannot process")
+
+	before := time.Now()
+	summary, err := WalkWithSummary(root, func(ctx context.Context, path string, info os.FileInfo) error {
+		if filepath.Ext(path) == ".go" {
+			return errBad
+		}
+		return nil
+	}, WalkOptions{SymlinkHandling: SymlinkFollow, NumWorkers: 4})
+	if !errors.Is(err, errBad) {
+		t.Errorf("Expected the callback error, got %v", err)
+	}
+
+	sort.Slice(summary.Errors, func(i, j int) bool { return summary.Errors[i].Path < summary.Errors[j].Path })
+	if len(summary.Errors) != 2 {
+		t.Fatalf("Expected 2 errors, got %v", summary.Errors)
+	}
+	if e := summary.Errors[0]; e.Path != filepath.Join(root, "dangling") || !errors.Is(e, fs.ErrNotExist) || !e.Skipped {
+		t.Errorf("Expected the dangling link to be skipped as missing, got %+v", e)
+	}
+	if e := summary.Errors[1]; e.Path != filepath.Join(root, "sub", "bad.go") || !errors.Is(e, errBad) || e.Skipped {
+		t.Errorf("Expected the callback error of sub/bad.go, got %+v", e)
+	}
+	if summary.ErrorCount != 2 || summary.FilesProcessed != 3 {
+		t.Errorf("Expected 2 errors and 3 files in the stats, got %d and %d", summary.ErrorCount, summary.FilesProcessed)
+	}
+	if summary.Start.Before(before) || summary.End.Before(summary.Start) || summary.ElapsedTime > summary.End.Sub(summary.Start) {
+		t.Errorf("Expected the walk to run from %v to %v, taking %v", summary.Start, summary.End, summary.ElapsedTime)
+	}
+}
+
+func TestWalkWithSummaryKeepsRepeats(t *testing.T) {
+	tree := walktest.Tree{}
+	for _, name := range []string{"a", "b", "c", "d"} {
+		tree[name+".txt"] = walktest.Symlink{Target: name + ".missing"}
+	}
+	root := tree.Build(t)
+
+	// The same errno in one directory is listed for every path, up to the cap
+	summary, err := WalkWithSummary(root, func(ctx context.Context, path string, info os.FileInfo) error {
+		return nil
+	}, WalkOptions{SymlinkHandling: SymlinkFollow, MaxRetainedErrors: 3})
+	if err != nil {
+		t.Fatalf("Walk failed: %v", err)
+	}
+	if len(summary.Errors) != 3 || summary.ErrorCount != 4 {
+		t.Errorf("Expected 3 of 4 errors listed, got %d of %d: %v", len(summary.Errors), summary.ErrorCount, summary.Errors)
+	}
+}
@@ -0,0 +1,99 @@
+package stride
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// ErrOutsideRoot is reported, wrapped, for symbolic links that
+// SymlinkFollowInternal does not follow because they leave the walk root.
+var ErrOutsideRoot = errors.New("stride: symlink target is outside the walk root")
+
+// maxSymlinkHops bounds how many links a chain may contain, like the
+// kernel's ELOOP limit.
+const maxSymlinkHops = 40
+
+// caseInsensitivePaths reports whether paths on this platform's default
+// filesystems compare case-insensitively.
+var caseInsensitivePaths = runtime.GOOS == "darwin" || runtime.GOOS == "windows"
+
+// resolveInternalSymlink resolves the symlink at link and returns its final
+// target, provided the link stays inside root both lexically and physically:
+// every hop of the chain must point inside root (so a chain that leaves the
+// root and re-enters it is rejected), and the fully evaluated target, with
+// all intermediate directory links resolved, must also be inside root.
+// Otherwise it returns an error wrapping ErrOutsideRoot.
+func resolveInternalSymlink(root, link string) (string, error) {
+	absRoot, err := filepath.Abs(root)
+	if err != nil {
+		return "", err
+	}
+	realRoot, err := filepath.EvalSymlinks(absRoot)
+	if err != nil {
+		return "", err
+	}
+	within := func(p string) bool {
+		return pathWithin(absRoot, p, caseInsensitivePaths) || pathWithin(realRoot, p, caseInsensitivePaths)
+	}
+
+	cur, err := filepath.Abs(link)
+	if err != nil {
+		return "", err
+	}
+	for hops := 0; ; hops++ {
+		if hops == maxSymlinkHops {
+			return "", fmt.Errorf("symlink %q: too many levels of symbolic links", link)
+		}
+		target, err := os.Readlink(cur)
+		if err != nil {
+			return "", err
+		}
+		if !filepath.IsAbs(target) {
+			target = filepath.Join(filepath.Dir(cur), target)
+		}
+		target = filepath.Clean(target)
+		if !within(target) {
+			return "", fmt.Errorf("symlink %q -> %q: %w", link, target, ErrOutsideRoot)
+		}
+
+		info, err := os.Lstat(target)
+		if err != nil {
+			return "", err
+		}
+		if info.Mode()&os.ModeSymlink == 0 {
+			cur = target
+			break
+		}
+		cur = target
+	}
+
+	real, err := filepath.EvalSymlinks(cur)
+	if err != nil {
+		return "", err
+	}
+	if !pathWithin(realRoot, real, caseInsensitivePaths) {
+		return "", fmt.Errorf("symlink %q -> %q: %w", link, real, ErrOutsideRoot)
+	}
+	return cur, nil
+}
+
+// pathWithin reports whether the absolute path p is root or lies below it,
+// comparing case-insensitively if fold is set. Both paths are cleaned, so
+// "../" components cannot escape.
+func pathWithin(root, p string, fold bool) bool {
+	root, p = filepath.Clean(root), filepath.Clean(p)
+	if fold {
+		root, p = strings.ToLower(root), strings.ToLower(p)
+	}
+	if root == p {
+		return true
+	}
+	if !strings.HasSuffix(root, string(os.PathSeparator)) {
+		root += string(os.PathSeparator)
+	}
+	return strings.HasPrefix(p, root)
+}
@@ -0,0 +1,106 @@
+package stride
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestSymlinkFollowInternal(t *testing.T) {
+	base := t.TempDir()
+	root := filepath.Join(base, "root")
+	outside := filepath.Join(base, "outside")
+
+	files := []string{"root/data/a.txt", "root/data2/b.txt", "root/data3/c.txt", "root/sub/d.txt", "outside/secret.txt"}
+	for _, name := range files {
+		path := filepath.Join(base, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("Failed to create directory: %v", err)
+		}
+		if err := os.WriteFile(path, []byte("x"), 0644); err != nil {
+			t.Fatalf("Failed to create file: %v", err)
+		}
+	}
+
+	links := []struct{ link, target string }{
+		{"root/internal", "data"},                                // Relative, inside
+		{"root/internalabs", filepath.Join(root, "data2")},       // Absolute, inside
+		{"root/sub/dotdot", "../data3"},                          // ../ that stays inside
+		{"root/escape", "../outside"},                            // ../ that leaves the root
+		{"root/tmp", os.TempDir()},                               // Absolute, outside
+		{"root/chain", filepath.Join(outside, "hop")},            // Exits the root...
+		{"outside/hop", filepath.Join(root, "data")},             // ...and re-enters it
+		{"root/data/self", filepath.Join(root, "data", "a.txt")}, // File link, inside
+	}
+	for _, l := range links {
+		if err := os.Symlink(l.target, filepath.Join(base, filepath.FromSlash(l.link))); err != nil {
+			t.Skipf("Symlinks not supported: %v", err)
+		}
+	}
+
+	var mu sync.Mutex
+	var visited, reported []string
+	err := WalkLimitWithOptions(context.Background(), root, func(path string, info os.FileInfo, err error) error {
+		rel, _ := filepath.Rel(root, path)
+		rel = filepath.ToSlash(rel)
+		mu.Lock()
+		defer mu.Unlock()
+		if err != nil {
+			if !errors.Is(err, ErrOutsideRoot) {
+				t.Errorf("Unexpected error for %s: %v", rel, err)
+			}
+			reported = append(reported, rel)
+			return nil
+		}
+		if !info.IsDir() {
+			visited = append(visited, rel)
+		}
+		return nil
+	}, WalkOptions{SymlinkHandling: SymlinkFollowInternal, NumWorkers: 2, AllowRevisit: true}) // Report every followed link's contents
+	if err != nil {
+		t.Fatalf("Walk failed: %v", err)
+	}
+
+	sort.Strings(visited)
+	sort.Strings(reported)
+
+	// A chain that leaves the root is not followed even if it ends inside it
+	expectedVisited := []string{"data/a.txt", "data/self", "data2/b.txt", "data3/c.txt", "internal/a.txt", "internal/self", "internalabs/b.txt", "sub/d.txt", "sub/dotdot/c.txt"}
+	expectedReported := []string{"chain", "escape", "tmp"}
+	if strings.Join(visited, ",") != strings.Join(expectedVisited, ",") {
+		t.Errorf("Expected visited %v, got %v", expectedVisited, visited)
+	}
+	if strings.Join(reported, ",") != strings.Join(expectedReported, ",") {
+		t.Errorf("Expected reported %v, got %v", expectedReported, reported)
+	}
+}
+
+func TestPathWithin(t *testing.T) {
+	sep := string(os.PathSeparator)
+	root := sep + filepath.Join("srv", "Data")
+	tests := []struct {
+		path     string
+		fold     bool
+		expected bool
+	}{
+		{root, false, true},
+		{filepath.Join(root, "a", "b"), false, true},
+		{root + sep + ".." + sep + "Data" + sep + "x", false, true},
+		{root + sep + ".." + sep + "other", false, false},
+		{root + "2", false, false},
+		{sep + filepath.Join("srv", "data", "a"), false, false},
+		{sep + filepath.Join("srv", "data", "a"), true, true},
+		{sep + filepath.Join("SRV", "DATA"), true, true},
+		{sep + filepath.Join("srv", "data2"), true, false},
+	}
+	for _, tt := range tests {
+		if got := pathWithin(root, tt.path, tt.fold); got != tt.expected {
+			t.Errorf("pathWithin(%q, %q, %v) = %v, expected %v", root, tt.path, tt.fold, got, tt.expected)
+		}
+	}
+}
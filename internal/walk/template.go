@@ -0,0 +1,457 @@
+package stride
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// templateField identifies the value substituted for a placeholder.
+type templateField int
+
+const (
+	fieldLiteral      templateField = iota // Literal text, or an allowed unknown placeholder
+	fieldPath                              // {}
+	fieldBase                              // {base}
+	fieldDir                               // {dir}
+	fieldSize                              // {size}
+	fieldTime                              // {time}
+	fieldOwner                             // {owner}
+	fieldGroup                             // {group}
+	fieldSHA256                            // {sha256}
+	fieldVersion                           // {version}
+	fieldEvent                             // {event}
+	fieldTarget                            // {target}
+	fieldACL                               // {acl}
+	fieldMatches                           // {matches}
+	fieldMode                              // %m
+	fieldSymbolicMode                      // %M
+	fieldType                              // %y
+	fieldTimePart                          // %TY, %Tm, ...; the token holds the layout
+)
+
+// templateFields maps placeholder names to fields. Each name may also be
+// written quoted, as in {"base"}; the path is {} or {""}.
+var templateFields = map[string]templateField{
+	"":        fieldPath,
+	"base":    fieldBase,
+	"dir":     fieldDir,
+	"size":    fieldSize,
+	"time":    fieldTime,
+	"owner":   fieldOwner,
+	"group":   fieldGroup,
+	"sha256":  fieldSHA256,
+	"version": fieldVersion,
+	"event":   fieldEvent,
+	"target":  fieldTarget,
+	"acl":     fieldACL,
+	"matches": fieldMatches,
+}
+
+// printfFields maps the find -printf directives, after the %, to fields.
+var printfFields = map[byte]templateField{
+	'p': fieldPath,
+	'f': fieldBase,
+	'h': fieldDir,
+	's': fieldSize,
+	'm': fieldMode,
+	'M': fieldSymbolicMode,
+	'u': fieldOwner,
+	'g': fieldGroup,
+	'y': fieldType,
+}
+
+// printfTimeLayouts maps the modification time directives, after the %T,
+// to time layouts.
+var printfTimeLayouts = map[byte]string{
+	'Y': "2006",
+	'm': "01",
+	'd': "02",
+	'H': "15",
+	'M': "04",
+	'S': "05",
+}
+
+// printfEscapes maps the escapes of find -printf templates, after the
+// backslash, to the characters they stand for.
+var printfEscapes = map[byte]byte{
+	'n':  '\n',
+	't':  '\t',
+	'\\': '\\',
+}
+
+// TemplateOptions configures ParseTemplateWithOptions.
+type TemplateOptions struct {
+	AllowUnknown bool // Keep unknown placeholders as literal text instead of failing
+}
+
+// Template is a parsed find or watch output template. Placeholders such as
+// {base} are replaced with values from each message; {{ and }} produce
+// literal braces. Placeholders whose value is not available, such as {owner}
+// before owner names are resolved, are output unchanged.
+//
+// Templates containing find -printf directives use that syntax instead:
+// %p (path), %f (base name), %h (directory), %s (size), %m (octal
+// permissions), %M (symbolic mode, as ls -l shows it), %u and %g (owner and
+// group names), %TY, %Tm, %Td, %TH, %TM and %TS (modification year, month,
+// day, hour, minute and whole seconds), %y (type letter: f, d, l, p, s, b or
+// c) and %% (a literal %), with the escapes \n, \t and \\. Braces are
+// then literal text, and, as with find, lines end only where the template
+// says \n.
+//
+// A Template is safe for concurrent use.
+type Template struct {
+	text   string
+	tokens []templateToken
+	plain  bool // No placeholders; Render returns the unescaped text
+	printf bool // Parsed as find -printf directives
+}
+
+// templateToken is a literal run of text or a single placeholder.
+type templateToken struct {
+	field  templateField
+	quoted bool
+	text   string // Literal text, or the placeholder as written
+	layout string // Time layout of fieldTimePart
+}
+
+// ParseTemplate parses tpl, rejecting unknown placeholders.
+func ParseTemplate(tpl string) (*Template, error) {
+	return ParseTemplateWithOptions(tpl, TemplateOptions{})
+}
+
+// ParseTemplateWithOptions parses tpl with the given options.
+func ParseTemplateWithOptions(tpl string, opts TemplateOptions) (*Template, error) {
+	if hasPrintfDirective(tpl) {
+		return parsePrintfTemplate(tpl, opts)
+	}
+	t := &Template{text: tpl, plain: true}
+	var lit strings.Builder
+	flush := func() {
+		if lit.Len() > 0 {
+			t.tokens = append(t.tokens, templateToken{text: lit.String()})
+			lit.Reset()
+		}
+	}
+
+	for i := 0; i < len(tpl); i++ {
+		c := tpl[i]
+		switch {
+		case c == '{' && strings.HasPrefix(tpl[i:], "{{"):
+			lit.WriteByte('{')
+			i++
+		case c == '}' && strings.HasPrefix(tpl[i:], "}}"):
+			lit.WriteByte('}')
+			i++
+		case c == '{':
+			end := strings.IndexByte(tpl[i:], '}')
+			if end < 0 {
+				return nil, fmt.Errorf("template %q: unterminated placeholder at offset %d", tpl, i)
+			}
+			placeholder := tpl[i : i+end+1]
+			name, quoted := placeholder[1:end], false
+			if len(name) >= 2 && name[0] == '"' && name[len(name)-1] == '"' {
+				name, quoted = name[1:len(name)-1], true
+			}
+			field, known := templateFields[name]
+			switch {
+			case known:
+				flush()
+				t.tokens = append(t.tokens, templateToken{field: field, quoted: quoted, text: placeholder})
+				t.plain = false
+			case opts.AllowUnknown:
+				lit.WriteString(placeholder)
+			default:
+				return nil, fmt.Errorf("template %q: unknown placeholder %s", tpl, placeholder)
+			}
+			i += end
+		default:
+			lit.WriteByte(c)
+		}
+	}
+	flush()
+	return t, nil
+}
+
+// hasPrintfDirective reports whether tpl contains a find -printf directive,
+// which selects that syntax for the whole template.
+func hasPrintfDirective(tpl string) bool {
+	for i := 0; i+1 < len(tpl); i++ {
+		if tpl[i] != '%' {
+			continue
+		}
+		c := tpl[i+1]
+		if c == '%' || printfFields[c] != fieldLiteral || c == 'T' && i+2 < len(tpl) && printfTimeLayouts[tpl[i+2]] != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// parsePrintfTemplate parses tpl as find -printf directives.
+func parsePrintfTemplate(tpl string, opts TemplateOptions) (*Template, error) {
+	t := &Template{text: tpl, plain: true, printf: true}
+	var lit strings.Builder
+	flush := func() {
+		if lit.Len() > 0 {
+			t.tokens = append(t.tokens, templateToken{text: lit.String()})
+			lit.Reset()
+		}
+	}
+	add := func(tok templateToken) {
+		flush()
+		t.tokens = append(t.tokens, tok)
+		t.plain = false
+	}
+
+	for i := 0; i < len(tpl); i++ {
+		c := tpl[i]
+		switch {
+		case c == '\\' && i+1 < len(tpl) && printfEscapes[tpl[i+1]] != 0:
+			lit.WriteByte(printfEscapes[tpl[i+1]])
+			i++
+		case c != '%':
+			lit.WriteByte(c)
+		case i+1 < len(tpl) && tpl[i+1] == '%':
+			lit.WriteByte('%')
+			i++
+		case i+2 < len(tpl) && tpl[i+1] == 'T' && printfTimeLayouts[tpl[i+2]] != "":
+			add(templateToken{field: fieldTimePart, text: tpl[i : i+3], layout: printfTimeLayouts[tpl[i+2]]})
+			i += 2
+		case i+1 < len(tpl) && printfFields[tpl[i+1]] != fieldLiteral:
+			add(templateToken{field: printfFields[tpl[i+1]], text: tpl[i : i+2]})
+			i++
+		default:
+			// Unknown directives are kept as written, up to the next character
+			end := min(i+2, len(tpl))
+			if !opts.AllowUnknown {
+				return nil, fmt.Errorf("template %q: unknown directive %s", tpl, tpl[i:end])
+			}
+			lit.WriteString(tpl[i:end])
+			i = end - 1
+		}
+	}
+	flush()
+	return t, nil
+}
+
+// String returns the template as written.
+func (t *Template) String() string {
+	return t.text
+}
+
+// Render substitutes the placeholders with values from msg. The {event}
+// placeholder is output unchanged.
+func (t *Template) Render(msg FindMessage) string {
+	return t.render(msg, "", strconv.Quote, false)
+}
+
+// RenderWatch substitutes the placeholders with values from a watch event.
+func (t *Template) RenderWatch(msg WatchMessage) string {
+	return t.render(watchFindMessage(msg), msg.Event, strconv.Quote, false)
+}
+
+// command renders the command run for msg, and event if set, through the
+// shell: every placeholder, quoted or not, is quoted for the shell, so
+// paths with spaces, quotes or dollar signs stay one argument and are
+// never run as shell code.
+func (t *Template) command(msg FindMessage, event WatchEvent) string {
+	return t.render(msg, event, shellQuote, true)
+}
+
+// RenderLine is Render followed by a newline, unless the template uses
+// find -printf directives, whose lines end where the template says \n.
+func (t *Template) RenderLine(msg FindMessage) string {
+	return t.line(t.Render(msg))
+}
+
+// RenderFile renders the line for an entry of a walk, as RenderLine does
+// for a found file, resolving owner names if the template uses them.
+func (t *Template) RenderFile(path string, info os.FileInfo) string {
+	msg := newFindMessage(path, info)
+	if t.usesOwner() {
+		msg.Owner, msg.Group = OwnerNames(info)
+	}
+	return t.RenderLine(msg)
+}
+
+// line ends a rendered line as the template's syntax does.
+func (t *Template) line(s string) string {
+	if t.printf {
+		return s
+	}
+	return s + "\n"
+}
+
+// render implements Render, RenderWatch and command, quoting the values of
+// quoted placeholders, or of all of them with quoteAll, with quote. An
+// empty event is unavailable.
+func (t *Template) render(msg FindMessage, event WatchEvent, quote func(string) string, quoteAll bool) string {
+	if t.plain {
+		if len(t.tokens) == 0 {
+			return ""
+		}
+		return t.tokens[0].text
+	}
+
+	var b strings.Builder
+	b.Grow(len(t.text) + len(msg.Path))
+	for _, tok := range t.tokens {
+		if tok.field == fieldLiteral {
+			b.WriteString(tok.text)
+			continue
+		}
+		value, ok := templateValue(tok, msg, event)
+		switch {
+		case !ok:
+			b.WriteString(tok.text)
+		case tok.quoted || quoteAll:
+			b.WriteString(quote(value))
+		default:
+			b.WriteString(value)
+		}
+	}
+	return b.String()
+}
+
+// templateValue returns the value of tok, or false if it is unavailable.
+func templateValue(tok templateToken, msg FindMessage, event WatchEvent) (string, bool) {
+	switch tok.field {
+	case fieldPath:
+		return msg.Path, true
+	case fieldBase:
+		return msg.Name, true
+	case fieldDir:
+		return msg.Dir, true
+	case fieldSize:
+		return strconv.FormatInt(msg.Size, 10), true
+	case fieldTime:
+		return msg.Time.Format(time.RFC3339), true
+	case fieldOwner:
+		return msg.Owner, msg.Owner != ""
+	case fieldGroup:
+		return msg.Group, msg.Group != ""
+	case fieldSHA256:
+		digest, ok := msg.Metadata["sha256"]
+		return digest, ok
+	case fieldVersion:
+		return msg.VersionID, msg.VersionID != ""
+	case fieldEvent:
+		return string(event), event != ""
+	case fieldTarget:
+		return msg.LinkTarget, msg.LinkTarget != ""
+	case fieldACL:
+		// Entries without an extended ACL have an empty one
+		return msg.Metadata["acl"], true
+	case fieldMatches:
+		return strings.Join(msg.MatchedLines, "\n"), msg.MatchedLines != nil
+	case fieldMode:
+		return strconv.FormatUint(uint64(unixPermissions(msg.Mode)), 8), msg.Mode != 0
+	case fieldSymbolicMode:
+		return symbolicMode(msg.Mode), msg.Mode != 0
+	case fieldType:
+		return string(typeLetter(msg)), true
+	case fieldTimePart:
+		return msg.Time.Format(tok.layout), true
+	}
+	return "", false
+}
+
+// unixPermissions returns the permission bits of mode as stat reports
+// them, with the setuid, setgid and sticky bits.
+func unixPermissions(mode os.FileMode) uint32 {
+	perm := uint32(mode.Perm())
+	if mode&os.ModeSetuid != 0 {
+		perm |= 04000
+	}
+	if mode&os.ModeSetgid != 0 {
+		perm |= 02000
+	}
+	if mode&os.ModeSticky != 0 {
+		perm |= 01000
+	}
+	return perm
+}
+
+// typeLetter returns the find -printf %y letter of the entry described by
+// msg.
+func typeLetter(msg FindMessage) byte {
+	switch mode := msg.Mode; {
+	case mode&os.ModeSymlink != 0:
+		return 'l'
+	case mode&os.ModeNamedPipe != 0:
+		return 'p'
+	case mode&os.ModeSocket != 0:
+		return 's'
+	case mode&os.ModeCharDevice != 0:
+		return 'c'
+	case mode&os.ModeDevice != 0:
+		return 'b'
+	case mode.IsDir() || msg.IsDir:
+		return 'd'
+	}
+	return 'f'
+}
+
+// symbolicMode formats mode as ls -l does, such as drwxr-xr-x or
+// -rwsr-xr-t, unlike os.FileMode.String.
+func symbolicMode(mode os.FileMode) string {
+	b := []byte("-rwxrwxrwx")
+	if t := typeLetter(FindMessage{Mode: mode}); t != 'f' {
+		b[0] = t
+	}
+	for i := 0; i < 9; i++ {
+		if mode&(1<<uint(8-i)) == 0 {
+			b[i+1] = '-'
+		}
+	}
+	special := func(set bool, i int, lower byte) {
+		if !set {
+			return
+		}
+		if b[i] == '-' {
+			b[i] = lower - 'a' + 'A'
+		} else {
+			b[i] = lower
+		}
+	}
+	special(mode&os.ModeSetuid != 0, 3, 's')
+	special(mode&os.ModeSetgid != 0, 6, 's')
+	special(mode&os.ModeSticky != 0, 9, 't')
+	return string(b)
+}
+
+// usesOwner reports whether the template references the owner or group.
+func (t *Template) usesOwner() bool {
+	for _, tok := range t.tokens {
+		if tok.field == fieldOwner || tok.field == fieldGroup {
+			return true
+		}
+	}
+	return false
+}
+
+// usesACL reports whether the template references the ACL.
+func (t *Template) usesACL() bool {
+	for _, tok := range t.tokens {
+		if tok.field == fieldACL {
+			return true
+		}
+	}
+	return false
+}
+
+// watchFindMessage describes a watch event as a found file.
+func watchFindMessage(msg WatchMessage) FindMessage {
+	return FindMessage{
+		Path:     msg.Path,
+		Name:     msg.Name,
+		Dir:      msg.Dir,
+		Size:     msg.Size,
+		Time:     msg.Time,
+		IsDir:    msg.IsDir,
+		Metadata: msg.Metadata,
+	}
+}
@@ -0,0 +1,212 @@
+package stride
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/TFMV/stride/walk/walktest"
+)
+
+func TestTemplateRender(t *testing.T) {
+	msg := FindMessage{
+		Path:     "/data/file.txt",
+		Name:     "file.txt",
+		Dir:      "/data",
+		Size:     42,
+		Time:     time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC),
+		Owner:    "alice",
+		Metadata: map[string]string{"sha256": "abc123"},
+	}
+
+	tests := []struct {
+		tpl  string
+		want string
+	}{
+		{"plain text", "plain text"},
+		{"", ""},
+		{"{}", "/data/file.txt"},
+		{"{base} ({size} bytes) in {dir}", "file.txt (42 bytes) in /data"},
+		{`{""} {"base"}`, `"/data/file.txt" "file.txt"`},
+		{"{time}", "2024-05-01T12:00:00Z"},
+		{"{owner}:{group}", "alice:{group}"}, // The group was not resolved
+		{"{sha256} {version}", "abc123 {version}"},
+		{"{event} {}", "{event} /data/file.txt"},
+		{"{matches}", "{matches}"}, // The content was not searched
+		{"{{}}", "{}"},
+		{"{{base}} is {base}", "{base} is file.txt"},
+		{"awk '{{print $1}}' {}", "awk '{print $1}' /data/file.txt"},
+		{"a } b", "a } b"},
+	}
+	for _, tt := range tests {
+		tpl, err := ParseTemplate(tt.tpl)
+		if err != nil {
+			t.Errorf("ParseTemplate(%q) failed: %v", tt.tpl, err)
+			continue
+		}
+		if got := tpl.Render(msg); got != tt.want {
+			t.Errorf("Render(%q) = %q, want %q", tt.tpl, got, tt.want)
+		}
+		if tpl.String() != tt.tpl {
+			t.Errorf("String() = %q, want %q", tpl.String(), tt.tpl)
+		}
+	}
+}
+
+func TestTemplateRenderWatch(t *testing.T) {
+	tpl, err := ParseTemplate(`{event}: {"base"} in {dir}`)
+	if err != nil {
+		t.Fatalf("ParseTemplate failed: %v", err)
+	}
+	got := tpl.RenderWatch(WatchMessage{Path: "/data/a.go", Name: "a.go", Dir: "/data", Event: EventCreate})
+	if want := `create: "a.go" in /data`; got != want {
+		t.Errorf("RenderWatch = %q, want %q", got, want)
+	}
+}
+
+func TestTemplateParseErrors(t *testing.T) {
+	for _, tpl := range []string{"{bsae}", `{"bsae"}`, "{print $1}", "{base", "size: {size} {"} {
+		if _, err := ParseTemplate(tpl); err == nil {
+			t.Errorf("ParseTemplate(%q) succeeded, want an error", tpl)
+		}
+	}
+
+	// Unknown placeholders may be allowed and are then kept as written
+	tpl, err := ParseTemplateWithOptions("{bsae} {base}", TemplateOptions{AllowUnknown: true})
+	if err != nil {
+		t.Fatalf("ParseTemplateWithOptions failed: %v", err)
+	}
+	if got := tpl.Render(FindMessage{Name: "x"}); got != "{bsae} x" {
+		t.Errorf("Render = %q, want %q", got, "{bsae} x")
+	}
+	if _, err := ParseTemplateWithOptions("{base", TemplateOptions{AllowUnknown: true}); err == nil {
+		t.Error("Expected an unterminated placeholder to fail even when unknowns are allowed")
+	}
+}
+
+func TestFindWithFormatInvalidTemplate(t *testing.T) {
+	root := t.TempDir()
+	if err := os.WriteFile(filepath.Join(root, "a.txt"), []byte("a"), 0644); err != nil {
+		t.Fatalf("Failed to create file: %v", err)
+	}
+
+	// The template is rejected before anything is walked
+	var out bytes.Buffer
+	err := FindWithFormat(context.Background(), root, FindOptions{MaxDepth: 5, Output: &out}, "{bsae}")
+	if err == nil || !strings.Contains(err.Error(), "{bsae}") {
+		t.Errorf("Expected an unknown placeholder error, got %v", err)
+	}
+	if out.Len() != 0 {
+		t.Errorf("Expected no output, got %q", out.String())
+	}
+}
+
+func TestTemplatePrintf(t *testing.T) {
+	root := walktest.Tree{
+		"a.txt":    walktest.File{Content: "hello", Mode: 0640},
+		"bin/tool": walktest.File{Content: "#!/bin/sh\n", Mode: 0755},
+	}.Build(t)
+	tool := filepath.Join(root, "bin", "tool")
+	if err := os.Chmod(tool, 0755|os.ModeSetuid); err != nil {
+		t.Fatalf("Failed to chmod: %v", err)
+	}
+	mtime := time.Date(2024, 5, 1, 9, 7, 3, 0, time.Local)
+	for _, path := range []string{filepath.Join(root, "a.txt"), tool} {
+		if err := os.Chtimes(path, mtime, mtime); err != nil {
+			t.Fatalf("Failed to set times: %v", err)
+		}
+	}
+	info, err := os.Stat(tool)
+	if err != nil {
+		t.Fatalf("Failed to stat: %v", err)
+	}
+	owner, group := OwnerNames(info)
+
+	// Every directive once
+	format := `%y %M %m %u:%g %s %TY-%Tm-%Td %TH:%TM:%TS %f in %h: %p 100%%\t{base}\n`
+	var out bytes.Buffer
+	if err := FindWithFormat(context.Background(), root, FindOptions{MaxDepth: 5, Output: &out}, format); err != nil {
+		t.Fatalf("FindWithFormat failed: %v", err)
+	}
+	lines := strings.SplitAfter(strings.ReplaceAll(out.String(), root, "ROOT"), "\n")
+	sort.Strings(lines)
+	got := strings.Join(lines, "")
+	want := "f -rw-r----- 640 " + owner + ":" + group + " 5 2024-05-01 09:07:03 a.txt in ROOT: ROOT/a.txt 100%\t{base}\n" +
+		"f -rwsr-xr-x 4755 " + owner + ":" + group + " 10 2024-05-01 09:07:03 tool in ROOT/bin: ROOT/bin/tool 100%\t{base}\n"
+	if got != want {
+		t.Errorf("Expected\n%s\ngot\n%s", want, got)
+	}
+
+	// Types and modes of other entries
+	tests := []struct {
+		mode os.FileMode
+		want string
+	}{
+		{os.ModeDir | 0755, "d drwxr-xr-x 755"},
+		{os.ModeDir | os.ModeSticky | 0777, "d drwxrwxrwt 1777"},
+		{os.ModeSymlink | 0777, "l lrwxrwxrwx 777"},
+		{os.ModeSetgid | 0644, "f -rw-r-Sr-- 2644"},
+		{os.ModeNamedPipe | 0600, "p prw------- 600"},
+		{os.ModeSocket | 0755, "s srwxr-xr-x 755"},
+		{os.ModeDevice | os.ModeCharDevice | 0666, "c crw-rw-rw- 666"},
+		{os.ModeDevice | 0660, "b brw-rw---- 660"},
+	}
+	tpl, err := ParseTemplate("%y %M %m")
+	if err != nil {
+		t.Fatalf("ParseTemplate failed: %v", err)
+	}
+	for _, tt := range tests {
+		if got := tpl.Render(FindMessage{Mode: tt.mode}); got != tt.want {
+			t.Errorf("Render(%v) = %q, want %q", tt.mode, got, tt.want)
+		}
+	}
+
+	// Watch events have no mode, but a type
+	if got := tpl.RenderWatch(WatchMessage{IsDir: true}); got != "d %M %m" {
+		t.Errorf("RenderWatch = %q, want %q", got, "d %M %m")
+	}
+}
+
+func TestTemplatePrintfSyntax(t *testing.T) {
+	tests := []struct {
+		tpl  string
+		line string
+	}{
+		{"%f\n", "a.txt\n"},
+		{`%f\n`, "a.txt\n"},
+		{`%f\\n`, `a.txt\n`},
+		{"%f", "a.txt"},                 // No newline unless asked for
+		{"{base} 100%", "a.txt 100%\n"}, // No directive, so braces apply
+		{"{base} %Tq", "a.txt %Tq\n"},
+	}
+	msg := FindMessage{Name: "a.txt"}
+	for _, tt := range tests {
+		tpl, err := ParseTemplate(tt.tpl)
+		if err != nil {
+			t.Errorf("ParseTemplate(%q) failed: %v", tt.tpl, err)
+			continue
+		}
+		if got := tpl.RenderLine(msg); got != tt.line {
+			t.Errorf("RenderLine(%q) = %q, want %q", tt.tpl, got, tt.line)
+		}
+	}
+
+	// Unknown directives fail unless allowed
+	for _, tpl := range []string{"%p %q", "%p %Tq", "%p %"} {
+		if _, err := ParseTemplate(tpl); err == nil {
+			t.Errorf("ParseTemplate(%q) succeeded, want an error", tpl)
+		}
+	}
+	tpl, err := ParseTemplateWithOptions("%f %q %", TemplateOptions{AllowUnknown: true})
+	if err != nil {
+		t.Fatalf("ParseTemplateWithOptions failed: %v", err)
+	}
+	if got := tpl.Render(msg); got != "a.txt %q %" {
+		t.Errorf("Render = %q, want %q", got, "a.txt %q %")
+	}
+}
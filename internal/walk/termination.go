@@ -0,0 +1,62 @@
+package stride
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// Errors reported when a walk stops before it is complete. Each wraps what
+// stopped the walk, so errors.Is matches both: a canceled walk's error is
+// ErrCanceledByUser and context.Canceled, a timed out one's ErrDeadline and
+// context.DeadlineExceeded. Budgets are reported with ErrBudgetExceeded.
+var (
+	ErrCanceledByUser = errors.New("stride: walk canceled")
+	ErrDeadline       = errors.New("stride: walk deadline exceeded")
+	ErrMemoryLimit    = errors.New("stride: walk memory limit exceeded")
+	ErrSkipAll        = errors.New("stride: walk stopped by the callback")
+)
+
+// TerminationReason tells why a walk stopped early, in Stats.TerminationReason.
+type TerminationReason string
+
+const (
+	TerminationCompleted   TerminationReason = ""             // The walk was not stopped
+	TerminationCanceled    TerminationReason = "canceled"     // The context was canceled
+	TerminationDeadline    TerminationReason = "deadline"     // The context's deadline passed
+	TerminationBudget      TerminationReason = "budget"       // WalkOptions.MaxDuration or WalkOptions.MaxFiles ran out
+	TerminationMemoryLimit TerminationReason = "memory_limit" // The heap outgrew WalkOptions.MemoryLimits.HardLimit
+	TerminationSkipAll     TerminationReason = "skip_all"     // The callback returned filepath.SkipAll
+)
+
+// StopReason returns the reason a walk that returned err stopped early, or
+// TerminationCompleted if err does not say it was stopped.
+func StopReason(err error) TerminationReason {
+	switch {
+	case err == nil:
+		return TerminationCompleted
+	case errors.Is(err, ErrSkipAll):
+		return TerminationSkipAll
+	case errors.Is(err, ErrMemoryLimit):
+		return TerminationMemoryLimit
+	case errors.Is(err, ErrBudgetExceeded):
+		return TerminationBudget
+	case errors.Is(err, ErrDeadline):
+		return TerminationDeadline
+	case errors.Is(err, ErrCanceledByUser):
+		return TerminationCanceled
+	}
+	return TerminationCompleted
+}
+
+// stopError returns err, the error of a walk under ctx, wrapped in the
+// sentinel for how ctx ended if err is the cancellation it caused.
+func stopError(ctx context.Context, err error) error {
+	if ctx == nil || ctx.Err() == nil || !errors.Is(err, context.Canceled) && !errors.Is(err, context.DeadlineExceeded) {
+		return err
+	}
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return fmt.Errorf("%w: %w", ErrDeadline, context.DeadlineExceeded)
+	}
+	return fmt.Errorf("%w: %w", ErrCanceledByUser, context.Canceled)
+}
@@ -0,0 +1,119 @@
+package stride
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestTerminationReasons(t *testing.T) {
+	root := createBudgetFixture(t, 200)
+	slow := func(path string, info os.FileInfo, err error) error {
+		time.Sleep(5 * time.Millisecond)
+		return nil
+	}
+
+	tests := []struct {
+		name   string
+		ctx    func() (context.Context, context.CancelFunc)
+		opts   WalkOptions
+		walkFn func(cancel context.CancelFunc) filepath.WalkFunc
+		errs   []error // Errors the walk's error must match
+		reason TerminationReason
+	}{
+		{
+			name: "completed",
+			walkFn: func(context.CancelFunc) filepath.WalkFunc {
+				return func(path string, info os.FileInfo, err error) error { return nil }
+			},
+			reason: TerminationCompleted,
+		},
+		{
+			name: "canceled",
+			walkFn: func(cancel context.CancelFunc) filepath.WalkFunc {
+				var files int64
+				return func(path string, info os.FileInfo, err error) error {
+					if atomic.AddInt64(&files, 1) == 10 {
+						cancel()
+					}
+					return nil
+				}
+			},
+			errs:   []error{ErrCanceledByUser, context.Canceled},
+			reason: TerminationCanceled,
+		},
+		{
+			name: "deadline",
+			ctx: func() (context.Context, context.CancelFunc) {
+				return context.WithTimeout(context.Background(), 30*time.Millisecond)
+			},
+			walkFn: func(context.CancelFunc) filepath.WalkFunc { return slow },
+			errs:   []error{ErrDeadline, context.DeadlineExceeded},
+			reason: TerminationDeadline,
+		},
+		{
+			name: "max files",
+			opts: WalkOptions{MaxFiles: 10},
+			walkFn: func(context.CancelFunc) filepath.WalkFunc {
+				return func(path string, info os.FileInfo, err error) error { return nil }
+			},
+			errs:   []error{ErrBudgetExceeded},
+			reason: TerminationBudget,
+		},
+		{
+			name:   "memory limit",
+			opts:   WalkOptions{MemoryLimits: MemoryLimitOptions{HardLimit: 1}},
+			walkFn: func(context.CancelFunc) filepath.WalkFunc { return slow },
+			errs:   []error{ErrMemoryLimit},
+			reason: TerminationMemoryLimit,
+		},
+		{
+			name: "skip all",
+			walkFn: func(context.CancelFunc) filepath.WalkFunc {
+				var files int64
+				return func(path string, info os.FileInfo, err error) error {
+					if !info.IsDir() && atomic.AddInt64(&files, 1) >= 5 {
+						return filepath.SkipAll
+					}
+					return nil
+				}
+			},
+			errs:   []error{ErrSkipAll},
+			reason: TerminationSkipAll,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			if tt.ctx != nil {
+				ctx, cancel = tt.ctx()
+			}
+			defer cancel()
+			opts := tt.opts
+			opts.NumWorkers = 2
+
+			stats, err := WalkLimitWithOptionsStats(ctx, root, tt.walkFn(cancel), opts)
+			if tt.errs == nil && err != nil {
+				t.Fatalf("Expected no error, got %v", err)
+			}
+			for _, want := range tt.errs {
+				if !errors.Is(err, want) {
+					t.Errorf("Expected error matching %v, got %v", want, err)
+				}
+			}
+			if stats.TerminationReason != tt.reason || StopReason(err) != tt.reason {
+				t.Errorf("Expected reason %q, got %q in stats and %q from the error", tt.reason, stats.TerminationReason, StopReason(err))
+			}
+			if tt.reason != TerminationCompleted && stats.FilesProcessed >= 200 {
+				t.Errorf("Expected the walk to stop early, got %d files", stats.FilesProcessed)
+			}
+			if tt.reason == TerminationSkipAll && stats.ErrorCount != 0 {
+				t.Errorf("Expected SkipAll not to count as an error, got %d", stats.ErrorCount)
+			}
+		})
+	}
+}
@@ -0,0 +1,104 @@
+package stride
+
+import (
+	"context"
+	"io/fs"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// DefaultTransientBackoff is the delay before the first retry of a call
+// that failed with a transient error, when TransientRetry.Backoff is unset.
+const DefaultTransientBackoff = 10 * time.Millisecond
+
+// maxTransientBackoff caps the delay between two retries.
+const maxTransientBackoff = time.Second
+
+// TransientRetry configures how a walk retries its own filesystem calls
+// (stating entries, reading directories and resolving symbolic links) after
+// transient errors, such as ESTALE and EINTR on network filesystems. Errors
+// returned by callbacks are never retried.
+type TransientRetry struct {
+	Attempts int           // Retries per path over the walk; 0 disables retrying
+	Backoff  time.Duration // Delay before the first retry of a path, doubled for each further one (default DefaultTransientBackoff, at most a second)
+}
+
+// transientRetrier retries the filesystem calls of a walk after transient
+// errors. It is safe for concurrent use; a nil *transientRetrier never
+// retries.
+type transientRetrier struct {
+	ctx      context.Context
+	policy   TransientRetry
+	retries  *int64 // Incremented for each retry
+	mu       sync.Mutex
+	attempts map[string]int
+}
+
+// newTransientRetrier returns a retrier for policy counting retries in
+// retries, or nil if policy does not retry. Waiting for a retry ends when
+// ctx is done.
+func newTransientRetrier(ctx context.Context, policy TransientRetry, retries *int64) *transientRetrier {
+	if policy.Attempts <= 0 {
+		return nil
+	}
+	if policy.Backoff <= 0 {
+		policy.Backoff = DefaultTransientBackoff
+	}
+	return &transientRetrier{ctx: ctx, policy: policy, retries: retries, attempts: make(map[string]int)}
+}
+
+// again reports whether a call for path that failed with err should be
+// retried, after waiting for its backoff. Only transient errors are retried,
+// and each path at most policy.Attempts times.
+func (r *transientRetrier) again(path string, err error) bool {
+	if r == nil || !isTransientError(err) {
+		return false
+	}
+	r.mu.Lock()
+	n := r.attempts[path]
+	if n >= r.policy.Attempts {
+		r.mu.Unlock()
+		return false
+	}
+	r.attempts[path] = n + 1
+	r.mu.Unlock()
+	atomic.AddInt64(r.retries, 1)
+
+	delay := maxTransientBackoff
+	if n < 16 {
+		delay = min(r.policy.Backoff<<n, maxTransientBackoff)
+	}
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return true
+	case <-r.ctx.Done():
+		return false
+	}
+}
+
+// call calls fn for path until it succeeds or fails with an error that is
+// not retried, and returns its last error.
+func (r *transientRetrier) call(path string, fn func() error) error {
+	for {
+		err := fn()
+		if err == nil || !r.again(path, err) {
+			return err
+		}
+	}
+}
+
+// rereadDir walks the directory at path again with visit after reading it
+// failed. visit has already seen the directory's own entry, so only its
+// contents, or a further error, are passed to visit. walkTree is the walk's
+// walkDirTree.
+func rereadDir(walkTree func(string, fs.WalkDirFunc) error, path string, visit fs.WalkDirFunc) error {
+	return walkTree(path, func(p string, d fs.DirEntry, err error) error {
+		if p == path && err == nil {
+			return nil
+		}
+		return visit(p, d, err)
+	})
+}
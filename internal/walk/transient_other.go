@@ -0,0 +1,8 @@
+//go:build !darwin && !linux
+
+package stride
+
+// isTransientError reports no errors as transient on this platform.
+func isTransientError(err error) bool {
+	return false
+}
@@ -0,0 +1,170 @@
+package stride
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestIsTransientError(t *testing.T) {
+	tests := []struct {
+		err  error
+		want bool
+	}{
+		{syscall.ESTALE, true},
+		{syscall.EINTR, true},
+		{&fs.PathError{Op: "lstat", Path: "a", Err: syscall.ESTALE}, true},
+		{fmt.Errorf("reading: %w", &os.SyscallError{Syscall: "getdents", Err: syscall.EINTR}), true},
+		{syscall.ENOENT, false},
+		{&fs.PathError{Op: "open", Path: "a", Err: syscall.EACCES}, false},
+		{errors.New("stale"), false},
+		{nil, false},
+	}
+	for _, tt := range tests {
+		if got := isTransientError(tt.err); got != tt.want {
+			t.Errorf("isTransientError(%v) = %v, expected %v", tt.err, got, tt.want)
+		}
+	}
+}
+
+// failStats makes entryInfo fail with err for the first failures stats of
+// the entry named name.
+func failStats(t *testing.T, name string, failures int, err error) {
+	var mu sync.Mutex
+	orig := entryInfo
+	entryInfo = func(path string, d fs.DirEntry) (fs.FileInfo, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		if filepath.Base(path) == name && failures > 0 {
+			failures--
+			return nil, &fs.PathError{Op: "lstat", Path: path, Err: err}
+		}
+		return orig(path, d)
+	}
+	t.Cleanup(func() { entryInfo = orig })
+}
+
+// visitedWithRetry walks root retrying up to attempts times and reports
+// whether the entry named name was visited.
+func visitedWithRetry(t *testing.T, root, name string, attempts int) (bool, Stats) {
+	t.Helper()
+	var mu sync.Mutex
+	visited := false
+	stats, _ := WalkLimitWithOptionsStats(context.Background(), root, func(path string, info os.FileInfo, err error) error {
+		mu.Lock()
+		defer mu.Unlock()
+		visited = visited || (err == nil && filepath.Base(path) == name)
+		return nil
+	}, WalkOptions{NumWorkers: 2, TransientRetry: TransientRetry{Attempts: attempts, Backoff: time.Millisecond}})
+	return visited, stats
+}
+
+func TestTransientRetry(t *testing.T) {
+	root := createIncrementalFixture(t)
+
+	// Two stale handles are retried and the entry is visited
+	failStats(t, "1.txt", 2, syscall.ESTALE)
+	visited, stats := visitedWithRetry(t, root, "1.txt", 3)
+	if !visited || stats.TransientRetries != 2 || stats.ErrorCount != 0 {
+		t.Errorf("Expected 1.txt visited after 2 retries, got visited=%v, %+v", visited, stats)
+	}
+
+	// Running out of retries reports the error
+	failStats(t, "4.txt", 2, syscall.ESTALE)
+	visited, stats = visitedWithRetry(t, root, "4.txt", 1)
+	if visited || stats.TransientRetries != 1 || stats.ErrorCount != 1 {
+		t.Errorf("Expected 4.txt to fail after 1 retry, got visited=%v, %+v", visited, stats)
+	}
+
+	// Other errors are not retried
+	failStats(t, "3.txt", 1, syscall.EACCES)
+	visited, stats = visitedWithRetry(t, root, "3.txt", 3)
+	if visited || stats.TransientRetries != 0 {
+		t.Errorf("Expected EACCES not to be retried, got visited=%v, %+v", visited, stats)
+	}
+}
+
+func TestTransientRetryReadDir(t *testing.T) {
+	root := createIncrementalFixture(t)
+
+	// Reads of a directory interrupted twice are retried
+	var mu sync.Mutex
+	failures := 2
+	orig := readDirFn
+	readDirFn = func(name string) ([]os.DirEntry, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		if filepath.Base(name) == "a" && failures > 0 {
+			failures--
+			return nil, &fs.PathError{Op: "readdirent", Path: name, Err: syscall.EINTR}
+		}
+		return orig(name)
+	}
+	t.Cleanup(func() { readDirFn = orig })
+
+	var paths []string
+	stats, err := WalkLimitWithOptionsStats(context.Background(), root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		paths = append(paths, path)
+		return nil
+	}, WalkOptions{
+		NumWorkers:     2,
+		MtimeCache:     filepath.Join(t.TempDir(), "cache"),
+		TransientRetry: TransientRetry{Attempts: 2, Backoff: time.Millisecond},
+	})
+	if err != nil {
+		t.Fatalf("Walk failed: %v", err)
+	}
+	if len(paths) != 9 || stats.TransientRetries != 2 {
+		t.Errorf("Expected 9 entries after 2 retries, got %d after %d", len(paths), stats.TransientRetries)
+	}
+}
+
+func TestRereadDir(t *testing.T) {
+	root := createIncrementalFixture(t)
+
+	// A failed read of a directory is retried without visiting it twice
+	reads := 0
+	useWalkDirTree(t, func(root string, fn fs.WalkDirFunc) error {
+		return filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+			if err == nil && d.IsDir() && d.Name() == "b" && reads == 0 {
+				reads++
+				if err := fn(path, d, nil); err != nil {
+					return err
+				}
+				if err := fn(path, d, &fs.PathError{Op: "readdirent", Path: path, Err: syscall.ESTALE}); err != nil {
+					return err
+				}
+				return filepath.SkipDir
+			}
+			return fn(path, d, err)
+		})
+	})
+
+	counts := make(map[string]int)
+	var mu sync.Mutex
+	stats, err := WalkLimitWithOptionsStats(context.Background(), root, func(path string, info os.FileInfo, err error) error {
+		mu.Lock()
+		defer mu.Unlock()
+		rel, _ := filepath.Rel(root, path)
+		counts[filepath.ToSlash(rel)]++
+		return err
+	}, WalkOptions{NumWorkers: 2, TransientRetry: TransientRetry{Attempts: 1, Backoff: time.Millisecond}})
+	if err != nil {
+		t.Fatalf("Walk failed: %v", err)
+	}
+	if counts["b"] != 1 || counts["b/3.txt"] != 1 || counts["b/c/4.txt"] != 1 || stats.TransientRetries != 1 {
+		t.Errorf("Expected b and its contents once after 1 retry, got %v after %d", counts, stats.TransientRetries)
+	}
+}
@@ -0,0 +1,27 @@
+//go:build darwin || linux
+
+package stride
+
+import (
+	"errors"
+	"syscall"
+)
+
+// transientErrnos are the errors from filesystem calls that may succeed when
+// retried: a stale NFS file handle, an interrupted call, a resource that is
+// temporarily unavailable and a timed out network filesystem.
+var transientErrnos = []syscall.Errno{syscall.ESTALE, syscall.EINTR, syscall.EAGAIN, syscall.ETIMEDOUT}
+
+// isTransientError reports whether err is one of transientErrnos.
+func isTransientError(err error) bool {
+	var errno syscall.Errno
+	if !errors.As(err, &errno) {
+		return false
+	}
+	for _, transient := range transientErrnos {
+		if errno == transient {
+			return true
+		}
+	}
+	return false
+}
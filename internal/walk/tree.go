@@ -0,0 +1,204 @@
+package stride
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+)
+
+// TreeNode is a file or directory in a tree built from a filtered walk.
+type TreeNode struct {
+	Name     string      // Base name of the entry
+	Path     string      // Full path to the entry
+	Size     int64       // Size in bytes (0 for directories)
+	Mode     os.FileMode // File mode bits
+	IsDir    bool        // Whether the entry is a directory
+	Children []*TreeNode // Sorted child entries
+}
+
+// TreeSummary holds the totals for a rendered tree.
+type TreeSummary struct {
+	Dirs      int   // Number of directories below the root
+	Files     int   // Number of files
+	TotalSize int64 // Total size of files in bytes
+}
+
+// TreeRenderOptions controls how a tree is rendered.
+type TreeRenderOptions struct {
+	DirsOnly bool // Render directories only
+	ASCII    bool // Use ASCII instead of Unicode box-drawing characters
+
+	// Paint optionally decorates entry names, e.g. with terminal colors
+	Paint func(name string, mode os.FileMode) string
+}
+
+// treeCharset holds the connector strings used to draw a tree.
+type treeCharset struct {
+	branch, last, pipe, space string
+}
+
+var (
+	unicodeTreeCharset = treeCharset{"├── ", "└── ", "│   ", "    "}
+	asciiTreeCharset   = treeCharset{"|-- ", "`-- ", "|   ", "    "}
+)
+
+// BuildTree walks root with the given options and assembles the visited
+// entries into a tree. The walk itself is concurrent; entries are buffered
+// and sorted by name so the resulting tree is deterministic.
+func BuildTree(ctx context.Context, root string, opts WalkOptions) (*TreeNode, error) {
+	root, err := normalizeRoot(root)
+	if err != nil {
+		return nil, err
+	}
+	rootNode := &TreeNode{Name: root, Path: root, Mode: os.ModeDir, IsDir: true}
+
+	var mu sync.Mutex
+	nodes := map[string]*TreeNode{root: rootNode}
+
+	// node returns the node for a directory path, creating missing ancestors.
+	var node func(path string) *TreeNode
+	node = func(path string) *TreeNode {
+		if n, ok := nodes[path]; ok {
+			return n
+		}
+		n := &TreeNode{Name: filepath.Base(path), Path: path, Mode: os.ModeDir, IsDir: true}
+		nodes[path] = n
+		parent := node(filepath.Dir(path))
+		parent.Children = append(parent.Children, n)
+		return n
+	}
+
+	err = WalkLimitWithOptions(ctx, root, func(path string, info os.FileInfo, err error) error {
+		if err != nil || info == nil {
+			return err
+		}
+		path = filepath.Clean(path)
+		if path == root {
+			return nil
+		}
+
+		mu.Lock()
+		defer mu.Unlock()
+		if info.IsDir() {
+			node(path).Mode = info.Mode()
+			return nil
+		}
+		parent := node(filepath.Dir(path))
+		parent.Children = append(parent.Children, &TreeNode{
+			Name: filepath.Base(path),
+			Path: path,
+			Size: info.Size(),
+			Mode: info.Mode(),
+		})
+		return nil
+	}, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	rootNode.sort()
+	return rootNode, nil
+}
+
+// sort orders the children of n and all its descendants by name.
+func (n *TreeNode) sort() {
+	sort.Slice(n.Children, func(i, j int) bool {
+		return n.Children[i].Name < n.Children[j].Name
+	})
+	for _, child := range n.Children {
+		child.sort()
+	}
+}
+
+// Summary returns the directory, file and size totals below n.
+func (n *TreeNode) Summary() TreeSummary {
+	var s TreeSummary
+	for _, child := range n.Children {
+		if child.IsDir {
+			s.Dirs++
+			cs := child.Summary()
+			s.Dirs += cs.Dirs
+			s.Files += cs.Files
+			s.TotalSize += cs.TotalSize
+		} else {
+			s.Files++
+			s.TotalSize += child.Size
+		}
+	}
+	return s
+}
+
+// Render writes n as a tree(1)-style listing followed by a summary line.
+func (n *TreeNode) Render(w io.Writer, opts TreeRenderOptions) error {
+	charset := unicodeTreeCharset
+	if opts.ASCII {
+		charset = asciiTreeCharset
+	}
+
+	if _, err := fmt.Fprintln(w, opts.paint(n)); err != nil {
+		return err
+	}
+	if err := n.renderChildren(w, "", charset, opts); err != nil {
+		return err
+	}
+
+	s := n.Summary()
+	if opts.DirsOnly {
+		_, err := fmt.Fprintf(w, "\n%s\n", pluralize(s.Dirs, "directory", "directories"))
+		return err
+	}
+	_, err := fmt.Fprintf(w, "\n%s, %s, %s\n",
+		pluralize(s.Dirs, "directory", "directories"),
+		pluralize(s.Files, "file", "files"),
+		pluralize(int(s.TotalSize), "byte", "bytes"))
+	return err
+}
+
+// paint returns the name of n, decorated by Paint if set.
+func (opts TreeRenderOptions) paint(n *TreeNode) string {
+	if opts.Paint == nil {
+		return n.Name
+	}
+	return opts.Paint(n.Name, n.Mode)
+}
+
+// pluralize formats a count with the singular or plural form of a noun.
+func pluralize(n int, singular, plural string) string {
+	if n == 1 {
+		return fmt.Sprintf("%d %s", n, singular)
+	}
+	return fmt.Sprintf("%d %s", n, plural)
+}
+
+// renderChildren writes the children of n with the given line prefix.
+func (n *TreeNode) renderChildren(w io.Writer, prefix string, charset treeCharset, opts TreeRenderOptions) error {
+	children := n.Children
+	if opts.DirsOnly {
+		children = make([]*TreeNode, 0, len(n.Children))
+		for _, child := range n.Children {
+			if child.IsDir {
+				children = append(children, child)
+			}
+		}
+	}
+
+	for i, child := range children {
+		connector, indent := charset.branch, charset.pipe
+		if i == len(children)-1 {
+			connector, indent = charset.last, charset.space
+		}
+		if _, err := fmt.Fprintln(w, prefix+connector+opts.paint(child)); err != nil {
+			return err
+		}
+		if child.IsDir {
+			if err := child.renderChildren(w, prefix+indent, charset, opts); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
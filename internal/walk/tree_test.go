@@ -0,0 +1,114 @@
+package stride
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// createTreeFixture builds a small, fixed directory tree for rendering tests.
+func createTreeFixture(t *testing.T) string {
+	t.Helper()
+	root := t.TempDir()
+
+	files := map[string]int{
+		"README.md":           10,
+		"main.go":             20,
+		"cmd/root.go":         30,
+		"cmd/tree.go":         40,
+		"docs/guide.txt":      50,
+		"internal/a/a.go":     60,
+		"internal/a/a.txt":    70,
+		"internal/b/empty.go": 0,
+	}
+	for name, size := range files {
+		path := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("Failed to create directory: %v", err)
+		}
+		if err := os.WriteFile(path, make([]byte, size), 0644); err != nil {
+			t.Fatalf("Failed to create file: %v", err)
+		}
+	}
+	return root
+}
+
+func TestBuildTreeRender(t *testing.T) {
+	tests := []struct {
+		name     string
+		filter   FilterOptions
+		render   TreeRenderOptions
+		expected string
+	}{
+		{
+			name: "All files",
+			expected: `ROOT
+├── README.md
+├── cmd
+│   ├── root.go
+│   └── tree.go
+├── docs
+│   └── guide.txt
+├── internal
+│   ├── a
+│   │   ├── a.go
+│   │   └── a.txt
+│   └── b
+│       └── empty.go
+└── main.go
+
+5 directories, 8 files, 280 bytes
+`,
+		},
+		{
+			name:   "Pattern and excluded dir",
+			filter: FilterOptions{Pattern: "*.go", ExcludeDir: []string{"cmd"}},
+			expected: `ROOT
+├── docs
+├── internal
+│   ├── a
+│   │   └── a.go
+│   └── b
+│       └── empty.go
+└── main.go
+
+4 directories, 3 files, 80 bytes
+`,
+		},
+		{
+			name:   "Depth limited, dirs only, ASCII",
+			filter: FilterOptions{MaxDepth: 1},
+			render: TreeRenderOptions{DirsOnly: true, ASCII: true},
+			expected: "ROOT\n" +
+				"|-- cmd\n" +
+				"|-- docs\n" +
+				"`-- internal\n" +
+				"\n" +
+				"3 directories\n",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			root := createTreeFixture(t)
+			opts := WalkOptions{Filter: test.filter, SymlinkHandling: SymlinkIgnore}
+			tree, err := BuildTree(context.Background(), root, opts)
+			if err != nil {
+				t.Fatalf("BuildTree failed: %v", err)
+			}
+
+			var buf bytes.Buffer
+			if err := tree.Render(&buf, test.render); err != nil {
+				t.Fatalf("Render failed: %v", err)
+			}
+
+			got := strings.Replace(buf.String(), root, "ROOT", 1)
+			if got != test.expected {
+				t.Errorf("Unexpected rendering:\n%s\nexpected:\n%s", got, test.expected)
+			}
+		})
+	}
+}
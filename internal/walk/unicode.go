@@ -0,0 +1,90 @@
+package stride
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// Kinds of UnicodeIssue.
+const (
+	UnicodeNotNFC    = "nfd"       // The name is not in NFC form, as names written on macOS often are
+	UnicodeCollision = "collision" // The name is the same as a sibling's once both are in NFC form
+)
+
+// UnicodeIssue describes an entry whose name may not survive a trip between
+// systems that normalize Unicode differently, reported when
+// FilterOptions.DetectUnicodeIssues is set.
+type UnicodeIssue struct {
+	Path  string // Path of the entry, before any WalkOptions.PathTransform
+	Kind  string // UnicodeNotNFC or UnicodeCollision
+	Other string // For UnicodeCollision, the name of the sibling it collides with
+}
+
+// Marker returns the issue as recorded in Metadata["unicode"]: "nfd", or
+// "collision:" followed by the other name.
+func (i UnicodeIssue) Marker() string {
+	if i.Kind == UnicodeCollision {
+		return UnicodeCollision + ":" + i.Other
+	}
+	return i.Kind
+}
+
+// NFCPath returns the path of the entry with its name in NFC form.
+func (i UnicodeIssue) NFCPath() string {
+	return filepath.Join(filepath.Dir(i.Path), norm.NFC.String(filepath.Base(i.Path)))
+}
+
+// unicodeDetector finds the UnicodeIssues of a walk. Two names can only
+// collide if at least one of them is not in NFC form, so only those are
+// grouped by directory, keeping the memory used proportional to the
+// offenders. It is safe for concurrent use.
+type unicodeDetector struct {
+	mu     sync.Mutex
+	groups map[string]map[string]string // Directory -> NFC form -> first name not in NFC with it
+}
+
+// newUnicodeDetector returns a detector for a walk with filter, or nil if
+// detection is disabled.
+func newUnicodeDetector(filter FilterOptions) *unicodeDetector {
+	if !filter.DetectUnicodeIssues {
+		return nil
+	}
+	return &unicodeDetector{groups: make(map[string]map[string]string)}
+}
+
+// check returns the issue of the entry at path, if it has one.
+func (d *unicodeDetector) check(path string) (UnicodeIssue, bool) {
+	name := filepath.Base(path)
+	if norm.NFC.IsNormalString(name) {
+		return UnicodeIssue{}, false
+	}
+	issue := UnicodeIssue{Path: path, Kind: UnicodeNotNFC}
+	dir, nfc := filepath.Dir(path), norm.NFC.String(name)
+
+	// The sibling in NFC form is looked up directly. Filesystems that
+	// ignore normalization find the entry itself under that name.
+	if sibling, err := os.Lstat(filepath.Join(dir, nfc)); err == nil {
+		if self, err := os.Lstat(path); err == nil && !os.SameFile(self, sibling) {
+			issue.Kind, issue.Other = UnicodeCollision, nfc
+			return issue, true
+		}
+	}
+
+	// Other names not in NFC form are found as they are enumerated
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	group := d.groups[dir]
+	if group == nil {
+		group = make(map[string]string)
+		d.groups[dir] = group
+	}
+	if other, ok := group[nfc]; ok && other != name {
+		issue.Kind, issue.Other = UnicodeCollision, other
+	} else if !ok {
+		group[nfc] = name
+	}
+	return issue, true
+}
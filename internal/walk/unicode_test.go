@@ -0,0 +1,138 @@
+package stride
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"sync"
+	"testing"
+
+	"github.com/TFMV/stride/walk/walktest"
+)
+
+// Names spelled with explicit bytes, so that no editor or tool normalizes
+// them
+const (
+	cafeNFC   = "caf\xc3\xa9"            // "café" with U+00E9
+	cafeNFD   = "cafe\xcc\x81"           // "café" with e and U+0301
+	dotAcute1 = "e\xcc\xa3\xcc\x81"      // e, U+0323 dot below, U+0301 acute: NFD
+	dotAcute2 = "e\xcc\x81\xcc\xa3"      // e, acute, dot below: neither NFC nor NFD
+	resumeNFD = "re\xcc\x81sume\xcc\x81" // "résumé" in NFD
+)
+
+// distinctNames reports whether the filesystem of temporary directories
+// keeps names that only differ in normalization apart, as most Linux
+// filesystems do, rather than taking them for the same name, as APFS does.
+func distinctNames(t *testing.T) bool {
+	t.Helper()
+	probe := t.TempDir()
+	for _, name := range []string{cafeNFC, cafeNFD} {
+		if err := os.WriteFile(filepath.Join(probe, name), nil, 0644); err != nil {
+			t.Fatalf("Failed to create file: %v", err)
+		}
+	}
+	entries, err := os.ReadDir(probe)
+	if err != nil {
+		t.Fatalf("Failed to read directory: %v", err)
+	}
+	return len(entries) == 2
+}
+
+func TestDetectUnicodeIssues(t *testing.T) {
+	tree := walktest.Tree{
+		"ascii.txt":               walktest.File{},
+		cafeNFC + ".txt":          walktest.File{},
+		"a/" + cafeNFD + ".txt":   walktest.File{},
+		resumeNFD + "/notes.txt":  walktest.File{},
+		"c/" + dotAcute1 + ".txt": walktest.File{},
+		"c/" + dotAcute2 + ".txt": walktest.File{},
+	}
+	collisions := distinctNames(t)
+	if collisions {
+		tree["b/"+cafeNFC] = walktest.File{}
+		tree["b/"+cafeNFD] = walktest.File{}
+	}
+	root := tree.Build(t)
+
+	var mu sync.Mutex
+	issues := make(map[string]string)
+	opts := WalkOptions{
+		NumWorkers: 1,
+		Filter:     FilterOptions{DetectUnicodeIssues: true},
+		OnUnicodeIssue: func(issue UnicodeIssue) {
+			mu.Lock()
+			defer mu.Unlock()
+			issues[relSlashPath(root, issue.Path)] = issue.Marker()
+		},
+	}
+	stats, err := WalkLimitWithOptionsStats(context.Background(), root, func(path string, info os.FileInfo, err error) error {
+		return err
+	}, opts)
+	if err != nil {
+		t.Fatalf("Walk failed: %v", err)
+	}
+
+	// Names in NFC form are left alone, and the two spellings of c's name
+	// collide with each other, whichever comes second
+	expected := map[string]string{
+		"a/" + cafeNFD + ".txt": UnicodeNotNFC,
+		resumeNFD:               UnicodeNotNFC,
+	}
+	first, second := "c/"+dotAcute1+".txt", "c/"+dotAcute2+".txt"
+	if issues[first] != UnicodeNotNFC {
+		first, second = second, first
+	}
+	expected[first] = UnicodeNotNFC
+	expected[second] = "collision:" + filepath.Base(first)
+	if collisions {
+		expected["b/"+cafeNFD] = "collision:" + cafeNFC
+	}
+	if !reflect.DeepEqual(issues, expected) {
+		t.Errorf("Expected %q, got %q", expected, issues)
+	}
+	if stats.UnicodeIssues != int64(len(expected)) {
+		t.Errorf("Expected %d issues counted, got %d", len(expected), stats.UnicodeIssues)
+	}
+
+	// Find marks its matches
+	var marked []string
+	err = Find(context.Background(), root, FindOptions{MaxDepth: 5, DetectUnicodeIssues: true}, func(ctx context.Context, result FindResult) error {
+		if marker := result.Message.Metadata["unicode"]; marker != "" {
+			mu.Lock()
+			marked = append(marked, relSlashPath(root, result.Message.Path)+" "+marker)
+			mu.Unlock()
+		}
+		return result.Error
+	})
+	if err != nil {
+		t.Fatalf("Find failed: %v", err)
+	}
+	sort.Strings(marked)
+	if len(marked) == 0 || marked[0] != "a/"+cafeNFD+".txt nfd" {
+		t.Errorf("Expected a/%s.txt marked nfd, got %q", cafeNFD, marked)
+	}
+
+	// Nothing is checked unless asked for
+	stats, err = WalkLimitWithOptionsStats(context.Background(), root, func(path string, info os.FileInfo, err error) error {
+		return err
+	}, WalkOptions{})
+	if err != nil {
+		t.Fatalf("Walk failed: %v", err)
+	}
+	if stats.UnicodeIssues != 0 {
+		t.Errorf("Expected no issues counted, got %d", stats.UnicodeIssues)
+	}
+}
+
+func TestUnicodeIssueNFCPath(t *testing.T) {
+	issue := UnicodeIssue{Path: filepath.Join("dir", cafeNFD+".txt"), Kind: UnicodeNotNFC}
+	if got, expected := issue.NFCPath(), filepath.Join("dir", cafeNFC+".txt"); got != expected {
+		t.Errorf("Expected %q, got %q", expected, got)
+	}
+	issue = UnicodeIssue{Kind: UnicodeCollision, Other: cafeNFC}
+	if got := issue.Marker(); got != "collision:"+cafeNFC {
+		t.Errorf("Expected a collision marker, got %q", got)
+	}
+}
@@ -0,0 +1,56 @@
+package stride
+
+import (
+	"os"
+	"sync"
+	"sync/atomic"
+	"syscall"
+)
+
+// dirKey identifies a directory independently of the path it was reached by.
+type dirKey struct {
+	dev uint64
+	ino uint64
+}
+
+// dirKeyOf returns the device and inode of info, or false if the platform
+// does not provide them.
+func dirKeyOf(info os.FileInfo) (dirKey, bool) {
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return dirKey{}, false
+	}
+	return dirKey{dev: uint64(stat.Dev), ino: uint64(stat.Ino)}, true
+}
+
+// visitedDirs records the directories enumerated by a walk that follows
+// symlinks, so that a directory reachable both directly and through a link
+// is only walked once. It is safe for concurrent use; a nil *visitedDirs
+// allows every visit.
+type visitedDirs struct {
+	seen    sync.Map // dirKey -> struct{}
+	skipped *int64   // Incremented for each repeated directory
+}
+
+// newVisitedDirs creates an empty set counting repeated directories in skipped.
+func newVisitedDirs(skipped *int64) *visitedDirs {
+	return &visitedDirs{skipped: skipped}
+}
+
+// visit records the directory described by info and reports whether it was
+// not enumerated before. Directories without a device and inode are always
+// visited.
+func (v *visitedDirs) visit(info os.FileInfo) bool {
+	if v == nil {
+		return true
+	}
+	key, ok := dirKeyOf(info)
+	if !ok {
+		return true
+	}
+	if _, seen := v.seen.LoadOrStore(key, struct{}{}); seen {
+		atomic.AddInt64(v.skipped, 1)
+		return false
+	}
+	return true
+}
@@ -0,0 +1,62 @@
+package stride
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+func TestFollowSymlinkVisitsOnce(t *testing.T) {
+	root := setupLargeTestDir(t)
+	if _, err := os.Lstat(filepath.Join(root, "symlink")); err != nil {
+		t.Skip("Symlinks not supported")
+	}
+
+	walk := func(opts WalkOptions) (map[string]int, Stats) {
+		var mu sync.Mutex
+		calls := make(map[string]int)
+		opts.NumWorkers = 4
+		stats, err := WalkLimitWithOptionsStats(context.Background(), root, func(path string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			mu.Lock()
+			calls[path]++
+			mu.Unlock()
+			return nil
+		}, opts)
+		if err != nil {
+			t.Fatalf("Walk failed: %v", err)
+		}
+		return calls, stats
+	}
+
+	_, plain := walk(WalkOptions{SymlinkHandling: SymlinkIgnore})
+
+	for _, handling := range []SymlinkHandling{SymlinkFollow, SymlinkFollowInternal} {
+		calls, stats := walk(WalkOptions{SymlinkHandling: handling})
+		for path, n := range calls {
+			if n != 1 {
+				t.Errorf("handling %d: %s reported %d times", handling, path, n)
+			}
+		}
+		if stats.FilesProcessed != plain.FilesProcessed || stats.BytesProcessed != plain.BytesProcessed {
+			t.Errorf("handling %d: processed %d files, %d bytes; want %d files, %d bytes",
+				handling, stats.FilesProcessed, stats.BytesProcessed, plain.FilesProcessed, plain.BytesProcessed)
+		}
+		if stats.DuplicateDirsSkipped != 1 {
+			t.Errorf("handling %d: DuplicateDirsSkipped = %d, want 1", handling, stats.DuplicateDirsSkipped)
+		}
+	}
+
+	// AllowRevisit walks the linked directory a second time
+	_, revisit := walk(WalkOptions{SymlinkHandling: SymlinkFollow, AllowRevisit: true})
+	if want := plain.BytesProcessed + plain.BytesProcessed/5; revisit.BytesProcessed != want {
+		t.Errorf("AllowRevisit processed %d bytes, want %d", revisit.BytesProcessed, want)
+	}
+	if revisit.DuplicateDirsSkipped != 0 {
+		t.Errorf("AllowRevisit skipped %d directories", revisit.DuplicateDirsSkipped)
+	}
+}
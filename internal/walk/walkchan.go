@@ -0,0 +1,72 @@
+package stride
+
+import (
+	"context"
+	"os"
+)
+
+// WalkResult is an entry, or an error, produced by WalkChan.
+type WalkResult struct {
+	Path  string      // Path of the entry, beneath the root as given
+	Info  os.FileInfo // Information about the entry, nil with some errors
+	Error error       // Error of the entry, or of the walk for the last result
+}
+
+// WalkChan walks the tree rooted at root in the background and sends its
+// entries on the returned channel, which is closed once the walk is done.
+// The entries, filters, symlink handling, statistics and progress reporting
+// are those of WalkLimitWithOptions with opts, and entries arrive in the
+// order the walk's callbacks run. Errors passed to the callback, such as
+// ErrOutsideRoot, arrive as results with Error set, and if the walk fails,
+// the last result carries its error with root as its Path.
+//
+// Up to opts.BufferSize results (default DefaultConcurrentWalks) are
+// buffered; beyond that the walk waits for them to be received, so a slow
+// consumer holds it back rather than letting results pile up. A consumer
+// that stops receiving before the channel is closed must cancel ctx, which
+// stops the walk and closes the channel without sending its error.
+//
+// An invalid root fails at once, as does one that does not exist unless
+// opts.WaitForRoot allows waiting for it.
+func WalkChan(ctx context.Context, root string, opts WalkOptions) (<-chan WalkResult, error) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	root, err := normalizeRoot(root)
+	if err != nil {
+		return nil, err
+	}
+	if opts.WaitForRoot <= 0 {
+		if err := checkRoot(root); err != nil {
+			return nil, err
+		}
+	}
+
+	size := opts.BufferSize
+	if size < 1 {
+		size = DefaultConcurrentWalks
+	}
+	results := make(chan WalkResult, size)
+	send := func(result WalkResult) error {
+		// Nothing more is sent once ctx is done, even with room to spare
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		select {
+		case results <- result:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	go func() {
+		defer close(results)
+		err := WalkLimitWithOptions(ctx, root, func(path string, info os.FileInfo, err error) error {
+			return send(WalkResult{Path: path, Info: info, Error: err})
+		}, opts)
+		if err != nil && ctx.Err() == nil {
+			send(WalkResult{Path: root, Error: err})
+		}
+	}()
+	return results, nil
+}
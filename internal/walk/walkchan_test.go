@@ -0,0 +1,142 @@
+package stride
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"go.uber.org/goleak"
+)
+
+func TestWalkChan(t *testing.T) {
+	defer goleak.VerifyNone(t)
+	root := createEntriesFixture(t)
+	opts := WalkOptions{
+		NumWorkers: 4,
+		Filter:     FilterOptions{Pattern: "*.txt", ExcludeDir: []string{".hidden"}},
+	}
+
+	// The callback API visits the same entries
+	var mu sync.Mutex
+	var want []string
+	err := WalkLimitWithOptions(context.Background(), root, func(path string, info os.FileInfo, err error) error {
+		mu.Lock()
+		defer mu.Unlock()
+		want = append(want, path)
+		return err
+	}, opts)
+	if err != nil {
+		t.Fatalf("WalkLimitWithOptions failed: %v", err)
+	}
+
+	results, err := WalkChan(context.Background(), root, opts)
+	if err != nil {
+		t.Fatalf("WalkChan failed: %v", err)
+	}
+	var got []string
+	for result := range results {
+		if result.Error != nil {
+			t.Fatalf("Expected no error, got %v", result.Error)
+		}
+		if result.Info == nil {
+			t.Errorf("Expected FileInfo for %s", result.Path)
+		}
+		got = append(got, result.Path)
+	}
+	sort.Strings(want)
+	sort.Strings(got)
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected %v, got %v", want, got)
+	}
+}
+
+func TestWalkChanBackpressure(t *testing.T) {
+	defer goleak.VerifyNone(t)
+	root := createEntriesFixture(t)
+	for i := 0; i < 200; i++ {
+		if err := os.WriteFile(filepath.Join(root, "dir", fmt.Sprintf("%03d.dat", i)), nil, 0644); err != nil {
+			t.Fatalf("Failed to create file: %v", err)
+		}
+	}
+
+	// Nothing is received, so the walk fills the buffer and waits
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	results, err := WalkChan(ctx, root, WalkOptions{NumWorkers: 4, BufferSize: 2})
+	if err != nil {
+		t.Fatalf("WalkChan failed: %v", err)
+	}
+	time.Sleep(200 * time.Millisecond)
+	if n := len(results); n != 2 {
+		t.Errorf("Expected a full buffer of 2 results, got %d", n)
+	}
+
+	// Cancelling stops the walk and closes the channel
+	cancel()
+	received := 0
+	timeout := time.After(5 * time.Second)
+	for done := false; !done; {
+		select {
+		case _, ok := <-results:
+			if ok {
+				received++
+			}
+			done = !ok
+		case <-timeout:
+			t.Fatal("Expected the channel to be closed after cancelling")
+		}
+	}
+	if received > 2 {
+		t.Errorf("Expected only the buffered results after cancelling, got %d", received)
+	}
+}
+
+func TestWalkChanErrors(t *testing.T) {
+	defer goleak.VerifyNone(t)
+	root := createEntriesFixture(t)
+
+	if _, err := WalkChan(context.Background(), "", WalkOptions{}); !errors.Is(err, errEmptyRoot) {
+		t.Errorf("Expected errEmptyRoot, got %v", err)
+	}
+	if _, err := WalkChan(context.Background(), filepath.Join(root, "missing"), WalkOptions{}); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("Expected an error matching fs.ErrNotExist, got %v", err)
+	}
+
+	// A walk that fails ends with its error
+	results, err := WalkChan(context.Background(), root, WalkOptions{MaxFiles: 1})
+	if err != nil {
+		t.Fatalf("WalkChan failed: %v", err)
+	}
+	var last WalkResult
+	for result := range results {
+		last = result
+	}
+	var budgetErr *BudgetError
+	if !errors.As(last.Error, &budgetErr) {
+		t.Errorf("Expected the last result to carry a *BudgetError, got %v", last.Error)
+	}
+	if last.Path != root {
+		t.Errorf("Expected the walk's error for %s, got it for %s", root, last.Path)
+	}
+
+	// A cancelled walk closes the channel without an error
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	results, err = WalkChan(ctx, root, WalkOptions{})
+	if err != nil {
+		t.Fatalf("WalkChan failed: %v", err)
+	}
+	for result := range results {
+		if result.Error != nil {
+			t.Errorf("Expected no error after cancelling, got %v", result.Error)
+		}
+	}
+}
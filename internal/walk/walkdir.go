@@ -0,0 +1,353 @@
+package stride
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sync"
+)
+
+// WalkDirFunc is the callback for WalkDir. Calling d.Info() stats the entry
+// lazily; d.Type() and d.IsDir() are answered from the directory listing,
+// unless the listing did not know the type or opts.ForceStatFallback is set.
+type WalkDirFunc func(ctx context.Context, path string, d fs.DirEntry) error
+
+// infoDirEntry is a DirEntry whose FileInfo has already been loaded.
+type infoDirEntry struct {
+	fs.DirEntry
+	info fs.FileInfo
+}
+
+// Info returns the cached FileInfo.
+func (e infoDirEntry) Info() (fs.FileInfo, error) {
+	return e.info, nil
+}
+
+// WalkDir walks the file tree rooted at root like filepath.WalkDir, calling fn
+// for each entry that passes opts.Filter. Unlike the FileInfo-based walks, it
+// only stats an entry when the callback asks for it or when a filter needs
+// more than the name, type and depth (size, times, permissions or ownership).
+//
+// Directories are visited synchronously, so returning filepath.SkipDir from fn
+// skips a directory; files are handed to opts.NumWorkers concurrent workers.
+// Returning filepath.SkipAll stops the walk without error, and any other error
+// stops the walk and is returned. Directory read errors are returned when
+// opts.ErrorHandling is ErrorHandlingStop and skipped otherwise. Symbolic links
+// are reported but never followed, unless opts.SymlinkHandling is
+// SymlinkIgnore, in which case they are skipped. opts.TraversalOrder applies
+// as it does to the other walks.
+func WalkDir(root string, fn WalkDirFunc, opts WalkOptions) error {
+	root, err := normalizeRoot(root)
+	if err != nil {
+		return err
+	}
+	ctx := opts.Context
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	if err := waitForRoot(ctx, root, opts.WaitForRoot); err != nil {
+		return err
+	}
+	opts.Filter, err = resolveReferenceFiles(opts.Filter)
+	if err != nil {
+		return err
+	}
+	opts.Filter, err = resolveIncludePaths(root, opts.Filter)
+	if err != nil {
+		return err
+	}
+	opts.Filter, err = resolveIgnoreRules(root, opts.Filter)
+	if err != nil {
+		return err
+	}
+	opts.Filter.readPlaceholders = !opts.skipCloudPlaceholders()
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	workers := opts.NumWorkers
+	if workers <= 0 {
+		workers = runtime.NumCPU()
+	}
+
+	filter := opts.Filter
+	needInfo := filterNeedsInfo(filter)
+	sampler := newDirSampler(filter.MaxFilesPerDir)
+	mtimes := newMtimePruner(root, filter, nil)
+
+	// The first error from a callback stops the walk.
+	var firstErr error
+	var errOnce sync.Once
+	fail := func(err error) {
+		errOnce.Do(func() {
+			firstErr = err
+			cancel()
+		})
+	}
+
+	type dirTask struct {
+		path string
+		d    fs.DirEntry
+	}
+	tasks := make(chan dirTask, queueSize(opts.QueueSize, workers))
+	var workerWg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		workerWg.Add(1)
+		go func() {
+			defer workerWg.Done()
+			for task := range tasks {
+				if ctx.Err() != nil {
+					continue
+				}
+				err := fn(ctx, task.path, task.d)
+				if err != nil && !errors.Is(err, filepath.SkipDir) {
+					if errors.Is(err, filepath.SkipAll) {
+						cancel()
+						continue
+					}
+					fail(fmt.Errorf("path %q: %w", task.path, err))
+				}
+			}
+		}()
+	}
+
+	// Enumeration shares a limiter with other walks, letting go of it for
+	// directory callbacks and while the workers are too busy to take files
+	gate := newEnumGate(ctx, opts.limiter())
+	send := func(task dirTask) bool {
+		select {
+		case tasks <- task:
+			return true
+		default:
+		}
+		sent := false
+		gate.pause(func() {
+			select {
+			case tasks <- task:
+				sent = true
+			case <-ctx.Done():
+			}
+		})
+		return sent
+	}
+
+	walkTree := walkDirTree
+	if opts.TraversalOrder == BreadthFirst {
+		walkTree = func(dir string, fn fs.WalkDirFunc) error {
+			return walkBreadthFirst(dir, fn, nil)
+		}
+	}
+
+	fallback := statFallback{forced: opts.ForceStatFallback}
+	var visit fs.WalkDirFunc
+	visit = func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if opts.ErrorHandling == ErrorHandlingStop {
+				return err
+			}
+			return nil
+		}
+		if ctx.Err() != nil {
+			return filepath.SkipAll
+		}
+
+		// Entries of unknown type are stat'ed before the type is trusted; a
+		// directory listed as something else is walked from here
+		d, moved, err := fallback.resolve(path, d)
+		if err != nil {
+			if opts.ErrorHandling == ErrorHandlingStop {
+				return err
+			}
+			return nil
+		}
+		if moved {
+			return walkListedDir(walkTree, path, visit)
+		}
+
+		if d.Type()&fs.ModeSymlink != 0 && opts.SymlinkHandling == SymlinkIgnore {
+			return nil
+		}
+
+		// Depth filtering; the root is delivered unless disabled
+		depth := depthOf(root, path)
+		if depth == 0 && !opts.includeRoot() {
+			return nil
+		}
+		if filter.MaxDepth > 0 && depth > filter.MaxDepth {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if d.IsDir() && dirExcluded(path, root, filter) {
+			return filepath.SkipDir
+		}
+		if d.IsDir() && mtimes != nil {
+			if info, err := d.Info(); err == nil && mtimes.prunes(path, info) {
+				return filepath.SkipDir
+			}
+		}
+		if mtimes.withholds(filepath.Dir(path)) {
+			return nil
+		}
+		if depth > 0 && filter.MinDepth > 0 && depth < filter.MinDepth {
+			return nil
+		}
+
+		if d.IsDir() {
+			gate.pause(func() { err = fn(ctx, path, d) })
+			if err != nil {
+				if errors.Is(err, filepath.SkipDir) || errors.Is(err, filepath.SkipAll) {
+					return err
+				}
+				fail(fmt.Errorf("path %q: %w", path, err))
+				return filepath.SkipAll
+			}
+			return nil
+		}
+
+		if filePatternRejects(path, root, filter) || !entryPassesFilter(path, d, filter) {
+			return nil
+		}
+		if needInfo {
+			info, err := d.Info()
+			if err != nil {
+				if opts.ErrorHandling == ErrorHandlingStop {
+					return err
+				}
+				return nil
+			}
+			if !filePassesFilter(path, info, filter, opts.SymlinkHandling) {
+				return nil
+			}
+			d = infoDirEntry{DirEntry: d, info: info}
+		}
+		if !sampler.take(filepath.Dir(path)) {
+			return nil
+		}
+
+		if !send(dirTask{path: path, d: d}) {
+			return filepath.SkipAll
+		}
+		return nil
+	}
+	err = walkTree(root, visit)
+	gate.done()
+
+	close(tasks)
+	workerWg.Wait()
+
+	if firstErr != nil {
+		return firstErr
+	}
+	if err != nil {
+		return err
+	}
+	// Report cancellation by the caller, not by SkipAll or a callback error
+	if opts.Context != nil && opts.Context.Err() != nil {
+		return stopError(opts.Context, opts.Context.Err())
+	}
+	return nil
+}
+
+// filterNeedsInfo reports whether filter uses criteria that require a stat.
+func filterNeedsInfo(filter FilterOptions) bool {
+	return filter.MinSize > 0 || filter.MaxSize > 0 ||
+		!filter.ModifiedAfter.IsZero() || !filter.ModifiedBefore.IsZero() ||
+		!filter.AccessedAfter.IsZero() || !filter.AccessedBefore.IsZero() ||
+		!filter.CreatedAfter.IsZero() || !filter.CreatedBefore.IsZero() ||
+		hasPermissionFilter(filter) ||
+		filter.OwnerUID > 0 || filter.OwnerGID > 0 ||
+		filter.OwnerName != "" || filter.GroupName != "" ||
+		filter.IncludeEmptyFiles ||
+		filter.RequireFlags != 0 || filter.ExcludeFlags != 0
+}
+
+// entryPassesFilter applies the name, extension and type criteria of filter
+// to a directory entry without statting it.
+func entryPassesFilter(path string, d fs.DirEntry, filter FilterOptions) bool {
+	name := d.Name()
+	if filter.Pattern != "" && !isPathPattern(filter.Pattern) {
+		if matched, err := filepath.Match(filter.Pattern, name); err != nil || !matched {
+			return false
+		}
+	}
+	for _, pattern := range filter.ExcludePattern {
+		if matched, err := filepath.Match(pattern, name); err == nil && matched {
+			return false
+		}
+	}
+	if filter.SkipJunkFiles && !d.IsDir() && isJunk(name) {
+		return false
+	}
+
+	if len(filter.IncludeTypes) > 0 {
+		ext := filepath.Ext(path)
+		matched := false
+		for _, includeType := range filter.IncludeTypes {
+			if includeType == ext {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return false
+		}
+	}
+
+	if len(filter.FileTypes) > 0 && !fileTypeMatches(d.Type(), filter.FileTypes) {
+		return false
+	}
+	return !filter.OnlyBrokenSymlinks || isBrokenSymlink(path, d.Type())
+}
+
+// fileTypeMatches reports whether mode is one of the named file types
+// (file, dir, symlink, pipe, socket, device, char).
+func fileTypeMatches(mode fs.FileMode, fileTypes []string) bool {
+	for _, fileType := range fileTypes {
+		switch fileType {
+		case "file":
+			if mode.IsRegular() {
+				return true
+			}
+		case "dir":
+			if mode.IsDir() {
+				return true
+			}
+		case "symlink":
+			if mode&os.ModeSymlink != 0 {
+				return true
+			}
+		case "pipe":
+			if mode&os.ModeNamedPipe != 0 {
+				return true
+			}
+		case "socket":
+			if mode&os.ModeSocket != 0 {
+				return true
+			}
+		case "device":
+			if mode&os.ModeDevice != 0 {
+				return true
+			}
+		case "char":
+			if mode&os.ModeCharDevice != 0 {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// isBrokenSymlink reports whether the entry at path, of type mode, is a
+// symbolic link whose target, or a link on the way to it, does not exist.
+func isBrokenSymlink(path string, mode fs.FileMode) bool {
+	if mode&os.ModeSymlink == 0 {
+		return false
+	}
+	_, err := os.Stat(path)
+	return errors.Is(err, fs.ErrNotExist)
+}
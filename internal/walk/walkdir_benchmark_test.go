@@ -1,6 +1,8 @@
 package stride
 
 import (
+	"context"
+	"io/fs"
 	"os"
 	"path/filepath"
 	"testing"
@@ -58,3 +60,32 @@ func createTestDirectoryStructure(b *testing.B, root string, depth, filesPerDir
 		createTestDirectoryStructure(b, subdir, depth-1, filesPerDir)
 	}
 }
+
+// BenchmarkWalkDirVsWalk compares the DirEntry-based WalkDir with the
+// FileInfo-based walk on the large fixture using a name-pattern filter.
+func BenchmarkWalkDirVsWalk(b *testing.B) {
+	tempDir := setupLargeTestDir(b)
+	opts := WalkOptions{
+		Filter:          FilterOptions{Pattern: "*.go"},
+		SymlinkHandling: SymlinkIgnore,
+		NumWorkers:      8,
+	}
+
+	b.Run("WalkLimitWithOptions", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			_ = WalkLimitWithOptions(context.Background(), tempDir, func(path string, info os.FileInfo, err error) error {
+				return nil
+			}, opts)
+		}
+	})
+
+	b.Run("WalkDir", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			_ = WalkDir(tempDir, func(ctx context.Context, path string, d fs.DirEntry) error {
+				return nil
+			}, opts)
+		}
+	})
+}
@@ -0,0 +1,165 @@
+package stride
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// createWalkDirFixture builds a nested tree of files with mixed extensions.
+func createWalkDirFixture(t *testing.T) string {
+	t.Helper()
+	root := t.TempDir()
+	for _, name := range []string{
+		"a.go", "b.txt", "c.md",
+		"dir1/d.go", "dir1/e.txt",
+		"dir1/sub/f.go", "dir1/sub/g.json",
+		"dir2/h.txt", "dir2/deep/er/i.go",
+	} {
+		path := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("Failed to create directory: %v", err)
+		}
+		if err := os.WriteFile(path, []byte(name), 0644); err != nil {
+			t.Fatalf("Failed to create file: %v", err)
+		}
+	}
+	return root
+}
+
+func TestWalkDirMatchesWalk(t *testing.T) {
+	root := createWalkDirFixture(t)
+
+	tests := []struct {
+		name   string
+		filter FilterOptions
+	}{
+		{name: "No filter"},
+		{name: "Pattern", filter: FilterOptions{Pattern: "*.go"}},
+		{name: "Extensions", filter: FilterOptions{IncludeTypes: []string{".txt", ".json"}}},
+		{name: "Exclude pattern", filter: FilterOptions{ExcludePattern: []string{"*.txt"}}},
+		{name: "Max depth", filter: FilterOptions{MaxDepth: 1}},
+		{name: "Min depth", filter: FilterOptions{MinDepth: 2}},
+		{name: "Excluded dir", filter: FilterOptions{ExcludeDir: []string{"sub"}, Pattern: "*.go"}},
+		{name: "File types", filter: FilterOptions{FileTypes: []string{"file"}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := WalkOptions{Filter: tt.filter, SymlinkHandling: SymlinkIgnore, NumWorkers: 4}
+
+			var mu sync.Mutex
+			var want, got []string
+			record := func(list *[]string, path string, isDir bool) {
+				rel, _ := filepath.Rel(root, path)
+				if isDir {
+					rel += "/"
+				}
+				mu.Lock()
+				*list = append(*list, filepath.ToSlash(rel))
+				mu.Unlock()
+			}
+
+			err := WalkLimitWithOptions(context.Background(), root, func(path string, info os.FileInfo, err error) error {
+				if err != nil {
+					return err
+				}
+				record(&want, path, info.IsDir())
+				return nil
+			}, opts)
+			if err != nil {
+				t.Fatalf("WalkLimitWithOptions failed: %v", err)
+			}
+
+			err = WalkDir(root, func(ctx context.Context, path string, d fs.DirEntry) error {
+				record(&got, path, d.IsDir())
+				return nil
+			}, opts)
+			if err != nil {
+				t.Fatalf("WalkDir failed: %v", err)
+			}
+
+			sort.Strings(want)
+			sort.Strings(got)
+			if strings.Join(got, ",") != strings.Join(want, ",") {
+				t.Errorf("Visited sets differ:\nWalkDir: %v\nWalk:    %v", got, want)
+			}
+		})
+	}
+}
+
+func TestWalkDirInfoFilters(t *testing.T) {
+	root := createWalkDirFixture(t)
+	big := filepath.Join(root, "dir1", "big.go")
+	if err := os.WriteFile(big, make([]byte, 4096), 0644); err != nil {
+		t.Fatalf("Failed to create file: %v", err)
+	}
+
+	var got []string
+	var mu sync.Mutex
+	opts := WalkOptions{Filter: FilterOptions{Pattern: "*.go", MinSize: 1024}}
+	err := WalkDir(root, func(ctx context.Context, path string, d fs.DirEntry) error {
+		if d.IsDir() {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		mu.Lock()
+		got = append(got, info.Name())
+		mu.Unlock()
+		return nil
+	}, opts)
+	if err != nil {
+		t.Fatalf("WalkDir failed: %v", err)
+	}
+	if len(got) != 1 || got[0] != "big.go" {
+		t.Errorf("Expected only big.go, got %v", got)
+	}
+}
+
+func TestWalkDirSkipAndErrors(t *testing.T) {
+	root := createWalkDirFixture(t)
+
+	t.Run("SkipDir", func(t *testing.T) {
+		var mu sync.Mutex
+		var got []string
+		err := WalkDir(root, func(ctx context.Context, path string, d fs.DirEntry) error {
+			if d.IsDir() && d.Name() == "dir1" {
+				return filepath.SkipDir
+			}
+			mu.Lock()
+			got = append(got, path)
+			mu.Unlock()
+			return nil
+		}, WalkOptions{})
+		if err != nil {
+			t.Fatalf("WalkDir failed: %v", err)
+		}
+		for _, path := range got {
+			if strings.Contains(path, "dir1") {
+				t.Errorf("Expected dir1 to be skipped, visited %s", path)
+			}
+		}
+	})
+
+	t.Run("Error stops walk", func(t *testing.T) {
+		errBoom := errors.New("boom")
+		err := WalkDir(root, func(ctx context.Context, path string, d fs.DirEntry) error {
+			if d.Name() == "e.txt" {
+				return errBoom
+			}
+			return nil
+		}, WalkOptions{})
+		if !errors.Is(err, errBoom) {
+			t.Errorf("Expected boom error, got %v", err)
+		}
+	})
+}
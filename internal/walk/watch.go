@@ -9,15 +9,21 @@ package stride
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"io"
+	"io/fs"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/TFMV/blink/pkg/blink"
 	"github.com/fsnotify/fsnotify"
+	"go.uber.org/zap"
 )
 
 // WatchEvent represents a filesystem event type
@@ -41,20 +47,119 @@ type WatchOptions struct {
 	// If empty, all events are watched
 	Events []WatchEvent
 
-	// Whether to watch subdirectories recursively
+	// Whether to watch subdirectories recursively. Directories created in
+	// or moved into the tree are watched as they appear, the entries a
+	// moved one brings along being reported as created, and directories
+	// deleted or moved away stop being watched
 	Recursive bool
 
-	// Pattern to match files (e.g., "*.go")
+	// Pattern to match files (e.g., "*.go" or "src/**/*.go"). Patterns
+	// containing '/' match the path relative to root; see MatchPattern
 	Pattern string
 
-	// Pattern to ignore files
+	// Pattern to ignore files (e.g., "*.tmp" or "vendor/**")
 	IgnorePattern string
 
+	// Whether to ignore files matching CommonJunkPatterns, such as editor
+	// swap files and .DS_Store
+	SkipJunkFiles bool
+
 	// Whether to include hidden files and directories
 	IncludeHidden bool
 
 	// Timeout duration (0 means no timeout)
 	Timeout time.Duration
+
+	// IdleTimeout ends the watch with ErrWatchIdle once no event has been
+	// delivered to the handler for this long (0 means no idle timeout).
+	// Events that the filters drop do not count. It can be combined with
+	// Timeout, whichever runs out first ending the watch
+	IdleTimeout time.Duration
+
+	// WaitForRoot is how long a root that does not exist yet is waited for
+	// before the watch fails; see WalkOptions.WaitForRoot. Timeout and
+	// IdleTimeout count from the moment it appears
+	WaitForRoot time.Duration
+
+	// Debounce holds the events of each path until none has followed for
+	// this long and delivers them as one, with the latest state of the file
+	// and the most significant of the events: a create followed by modifies
+	// stays a create, a create followed by a delete or rename is dropped,
+	// and a delete followed by a create is a modify. Errors are delivered
+	// at once, and events of files gone before they could be read are held
+	// without their size. Events still held when the watch stops are
+	// delivered then (0 delivers every event at once)
+	Debounce time.Duration
+
+	// Whether the entries present when watching starts, such as those of a
+	// root that WaitForRoot saw appear, are delivered as create events
+	// before any change, all of them with Recursive and those directly in
+	// the root otherwise
+	ProcessExisting bool
+
+	// Destination for handler output (default os.Stdout)
+	Output io.Writer
+
+	// Destination for the standard error of commands run by WatchWithExec
+	// (default os.Stderr)
+	ErrOutput io.Writer
+
+	// Whether commands run by WatchWithExec get STRIDE_* variables
+	// describing the event (default true)
+	ExecEnv *bool
+
+	// Whether each line written by commands run by WatchWithExec is
+	// prefixed with the path of the event
+	ExecPrefixOutput bool
+
+	// How long commands run by WatchWithExec that are still running when
+	// the watch stops are given to exit after SIGTERM before they are
+	// killed; 0 kills them at once. Watch returns once they have exited
+	ExecGracePeriod time.Duration
+
+	// Number of events buffered between the platform watcher and the
+	// handler (default DefaultWatchQueueSize)
+	QueueSize int
+
+	// What to do when the queue is full. SlowConsumerBlock stops reading
+	// the platform watcher until the handler catches up, which can overflow
+	// its buffer instead; SlowConsumerDropOldest discards the oldest queued
+	// event. Either way lost events are reported as ErrWatchOverflow
+	QueuePolicy SlowConsumerPolicy
+
+	// Counters updated while watching, if set
+	Stats *WatchStats
+
+	// Whether modify events are delivered only when the file's content may
+	// have changed, judged by comparing its size with a snapshot taken at
+	// the previous event. Delivered create, modify and chmod events carry
+	// Metadata["change"], ChangeContent or ChangeMetadata
+	ContentChangeOnly bool
+
+	// Whether ContentChangeOnly also hashes the first 64 KiB of files whose
+	// size is unchanged, so that same-size rewrites count as content changes
+	ContentHashCheck bool
+
+	// Number of file snapshots kept for ContentChangeOnly, least recently
+	// seen evicted first (default DefaultWatchSnapshotCacheSize)
+	SnapshotCacheSize int
+
+	// How changes are detected (default WatchBackendAuto)
+	Backend WatchBackend
+
+	// How often WatchBackendPoll walks the tree (default
+	// DefaultPollInterval)
+	PollInterval time.Duration
+
+	// Digest attached to create and modify events of regular files as
+	// Metadata["hash"] (default HashNone). Files are hashed on a separate
+	// pool of workers, and a file that cannot be read gets
+	// Metadata["hash_error"] instead
+	HashAlgorithm HashAlgorithm
+
+	// Size above which files are not hashed, their events getting
+	// Metadata["hash"] HashSkippedSize (default DefaultWatchMaxHashSize)
+	MaxHashSize int64
 }
 
 // WatchMessage contains information about a filesystem event
@@ -79,20 +184,26 @@ type WatchResult struct {
 type WatchHandler func(ctx context.Context, result WatchResult) error
 
 // defaultWatchHandler returns a default handler that prints events
-func defaultWatchHandler() WatchHandler {
+func defaultWatchHandler(out io.Writer) WatchHandler {
 	return func(ctx context.Context, result WatchResult) error {
 		if result.Error != nil {
 			return result.Error
 		}
-		fmt.Printf("%s: %s\n", strings.ToUpper(string(result.Message.Event)), result.Message.Path)
-		return nil
+		_, err := fmt.Fprintf(out, "%s: %s\n", strings.ToUpper(string(result.Message.Event)), result.Message.Path)
+		return err
 	}
 }
 
-// Watch monitors a directory for filesystem changes
+// ErrWatchIdle is returned by Watch when WatchOptions.IdleTimeout passed
+// without an event being delivered.
+var ErrWatchIdle = errors.New("stride: watch idle timeout")
+
+// Watch monitors a directory for filesystem changes, detected as selected by
+// opts.Backend. It returns nil when ctx is done or Timeout runs out, and
+// ErrWatchIdle when IdleTimeout runs out.
 func Watch(ctx context.Context, root string, opts WatchOptions, handler WatchHandler) error {
 	if handler == nil {
-		handler = defaultWatchHandler()
+		handler = defaultWatchHandler(newOutputWriter(opts.Output))
 	}
 
 	// Create a context if not provided
@@ -100,6 +211,36 @@ func Watch(ctx context.Context, root string, opts WatchOptions, handler WatchHan
 		ctx = context.Background()
 	}
 
+	root, err := normalizeRoot(root)
+	if err != nil {
+		return err
+	}
+	if err := waitForRoot(ctx, root, opts.WaitForRoot); err != nil {
+		return err
+	}
+
+	// Hash files off the dispatcher, the handler getting events in order
+	// once their digest is attached
+	hasher, err := newWatchHasher(opts, handler)
+	if err != nil {
+		return err
+	}
+	if hasher != nil {
+		defer hasher.close()
+		handler = hasher.handle
+	}
+
+	// Coalesce the events of each path ahead of hashing, so that only the
+	// final state is hashed
+	stats := opts.Stats
+	if stats == nil {
+		stats = &WatchStats{}
+	}
+	if debouncer := newWatchDebouncer(opts, handler, stats); debouncer != nil {
+		defer debouncer.close()
+		handler = debouncer.handle
+	}
+
 	// Create a context with timeout if specified
 	if opts.Timeout > 0 {
 		var cancel context.CancelFunc
@@ -107,29 +248,59 @@ func Watch(ctx context.Context, root string, opts WatchOptions, handler WatchHan
 		defer cancel()
 	}
 
+	// The idle timer is reset by the dispatcher for every delivered event
+	// and stopped once it has exited
+	var idle *time.Timer
+	if opts.IdleTimeout > 0 {
+		var cancel context.CancelCauseFunc
+		ctx, cancel = context.WithCancelCause(ctx)
+		defer cancel(nil)
+		idle = time.AfterFunc(opts.IdleTimeout, func() { cancel(ErrWatchIdle) })
+		defer idle.Stop()
+	}
+
+	// Notifications may miss changes made by other hosts of a network
+	// filesystem, so those are polled unless notifications were asked for
+	backend := opts.Backend
+	if backend == WatchBackendAuto && DetectRemoteFS(root) {
+		backend = WatchBackendPoll
+	}
+
 	// Create a watcher based on whether we need recursive watching
 	var watcher *blink.RecursiveWatcher
 	var fsWatcher *fsnotify.Watcher
-	var err error
+	var poller *watchPoller
 
-	if opts.Recursive {
-		// Use the recursive watcher from blink
-		watcher, err = blink.NewRecursiveWatcher(root)
+	if backend != WatchBackendPoll {
+		watcher, fsWatcher, err = newNotifyWatcher(root, opts.Recursive)
+		if err != nil && backend == WatchBackendNotify {
+			return err
+		}
 		if err != nil {
-			return fmt.Errorf("error creating recursive watcher: %w", err)
+			defaultLogger(LogLevelWarn).Warn("change notifications unavailable, polling instead", zap.String("root", root), zap.Error(err))
+			backend = WatchBackendPoll
 		}
-		defer watcher.Close()
-	} else {
-		// Use a regular fsnotify watcher for non-recursive watching
-		fsWatcher, err = fsnotify.NewWatcher()
+		if watcher != nil {
+			defer watcher.Close()
+		}
+		if fsWatcher != nil {
+			defer fsWatcher.Close()
+		}
+	}
+	if backend == WatchBackendPoll {
+		poller, err = newWatchPoller(ctx, root, opts)
 		if err != nil {
-			return fmt.Errorf("error creating watcher: %w", err)
+			return fmt.Errorf("error polling directory %s: %w", root, err)
 		}
-		defer fsWatcher.Close()
+	}
 
-		// Add the root directory to the watcher
-		if err := fsWatcher.Add(root); err != nil {
-			return fmt.Errorf("error watching directory %s: %w", root, err)
+	// List what is already there once the watch is registered, so that
+	// nothing created meanwhile is missed
+	var existing []string
+	if opts.ProcessExisting {
+		existing, err = existingEntries(ctx, root, opts.Recursive)
+		if err != nil {
+			return fmt.Errorf("error listing directory %s: %w", root, err)
 		}
 	}
 
@@ -159,137 +330,254 @@ func Watch(ctx context.Context, root string, opts WatchOptions, handler WatchHan
 		eventMap[fsnotify.Chmod] = true
 	}
 
+	// Directories moved into or out of a recursively watched tree bring
+	// their subdirectories with them
+	var dirs *watchedDirs
+	if watcher != nil {
+		dirs = newWatchedDirs(watcher.Watcher)
+	}
+
+	// Read events into a bounded queue so that a slow handler does not stop
+	// the platform watcher from being drained
+	queue := newWatchQueue(opts.QueueSize, opts.QueuePolicy, stats)
+
+	// Snapshots telling content changes from metadata changes
+	var classifier *changeClassifier
+	if opts.ContentChangeOnly {
+		classifier = newChangeClassifier(opts.SnapshotCacheSize, opts.ContentHashCheck)
+	}
+
+	// dispatch filters an event and passes it to the handler
+	dispatch := func(event fsnotify.Event, existing bool) {
+		if classifier != nil && (event.Has(fsnotify.Remove) || event.Has(fsnotify.Rename)) {
+			classifier.forget(event.Name)
+		}
+
+		// Check if we should process this event
+		var eventType WatchEvent
+		shouldProcess := false
+
+		if event.Has(fsnotify.Create) && eventMap[fsnotify.Create] {
+			shouldProcess = true
+			eventType = EventCreate
+		} else if event.Has(fsnotify.Write) && eventMap[fsnotify.Write] {
+			shouldProcess = true
+			eventType = EventModify
+		} else if event.Has(fsnotify.Remove) && eventMap[fsnotify.Remove] {
+			shouldProcess = true
+			eventType = EventDelete
+		} else if event.Has(fsnotify.Rename) && eventMap[fsnotify.Rename] {
+			shouldProcess = true
+			eventType = EventRename
+		} else if event.Has(fsnotify.Chmod) && eventMap[fsnotify.Chmod] {
+			shouldProcess = true
+			eventType = EventChmod
+		}
+
+		if shouldProcess {
+			// Get file info
+			var fileInfo os.FileInfo
+			var err error
+			isDir := false
+
+			if !event.Has(fsnotify.Remove) {
+				// With Debounce, the event of a file already gone is held
+				// without its info, for the removal that follows to cancel
+				fileInfo, err = os.Stat(event.Name)
+				if err != nil && (opts.Debounce <= 0 || !errors.Is(err, fs.ErrNotExist)) {
+					// Report the error but continue
+					handler(ctx, WatchResult{
+						Error: fmt.Errorf("error getting file info for %s: %w", event.Name, err),
+					})
+					return
+				}
+				isDir = fileInfo != nil && fileInfo.IsDir()
+
+				// If using non-recursive watcher and a directory is created, we need to add it manually
+				if !opts.Recursive && isDir && event.Has(fsnotify.Create) && !existing && fsWatcher != nil {
+					if err := fsWatcher.Add(event.Name); err != nil {
+						// Report the error but continue
+						handler(ctx, WatchResult{
+							Error: fmt.Errorf("error watching new directory %s: %w", event.Name, err),
+						})
+					}
+				}
+			}
+
+			// Match patterns against the path relative to the watch root
+			rel := relSlashPath(root, event.Name)
+			if opts.Pattern != "" {
+				matched, err := MatchPattern(opts.Pattern, rel)
+				if err != nil {
+					// Report the error but continue
+					handler(ctx, WatchResult{
+						Error: fmt.Errorf("error matching pattern: %w", err),
+					})
+					return
+				}
+				if !matched {
+					return
+				}
+			}
+
+			// Check if the file should be ignored
+			if opts.IgnorePattern != "" {
+				matched, err := MatchPattern(opts.IgnorePattern, rel)
+				if err != nil {
+					// Report the error but continue
+					handler(ctx, WatchResult{
+						Error: fmt.Errorf("error matching ignore pattern: %w", err),
+					})
+					return
+				}
+				if matched {
+					return
+				}
+			}
+
+			// Skip editor and file manager droppings
+			if opts.SkipJunkFiles && !isDir && isJunk(filepath.Base(event.Name)) {
+				return
+			}
+
+			// Skip hidden files if not included
+			if !opts.IncludeHidden && isHidden(event.Name) {
+				return
+			}
+
+			// Skip modify events that left the content alone
+			var change string
+			if classifier != nil && fileInfo != nil && !isDir {
+				change = classifier.classify(event.Name, fileInfo)
+				if change == ChangeMetadata && eventType == EventModify {
+					atomic.AddInt64(&stats.Suppressed, 1)
+					return
+				}
+			}
+
+			// Create a message for the event
+			msg := WatchMessage{
+				Path:     event.Name,
+				Name:     filepath.Base(event.Name),
+				Dir:      filepath.Dir(event.Name),
+				Time:     time.Now(),
+				Event:    eventType,
+				IsDir:    isDir,
+				Metadata: make(map[string]string),
+			}
+
+			if fileInfo != nil {
+				msg.Size = fileInfo.Size()
+				msg.Time = fileInfo.ModTime()
+			}
+			if change != "" {
+				msg.Metadata["change"] = change
+			}
+
+			// Process the event
+			if idle != nil {
+				idle.Reset(opts.IdleTimeout)
+			}
+			if err := handler(ctx, WatchResult{Message: msg}); err != nil {
+				// If the handler returns an error, report it
+				handler(ctx, WatchResult{
+					Error: fmt.Errorf("error handling event: %w", err),
+				})
+			}
+		}
+	}
+
+	// track keeps the recursive watch on the directories in the tree before
+	// dispatching an event, then dispatches the entries already present in
+	// a directory that appeared as if they had been created
+	track := func(event fsnotify.Event, existing bool) {
+		if dirs == nil || existing {
+			dispatch(event, existing)
+			return
+		}
+		if event.Has(fsnotify.Remove) || event.Has(fsnotify.Rename) {
+			dirs.remove(event.Name)
+		}
+		var found []string
+		if event.Has(fsnotify.Create) {
+			var err error
+			if found, err = dirs.addTree(event.Name); err != nil {
+				// Report the error but continue
+				handler(ctx, WatchResult{
+					Error: fmt.Errorf("error watching new directory %s: %w", event.Name, err),
+				})
+			}
+		}
+		dispatch(event, existing)
+		for _, path := range found {
+			dispatch(fsnotify.Event{Name: path, Op: fsnotify.Create}, true)
+		}
+	}
+
 	// Create a WaitGroup to wait for all goroutines to finish
 	var wg sync.WaitGroup
-	wg.Add(1)
+	wg.Add(2)
 
 	// Start watching for events
 	go func() {
 		defer wg.Done()
+		defer close(queue.ch)
+		for _, path := range existing {
+			item := watchItem{event: fsnotify.Event{Name: path, Op: fsnotify.Create}, existing: true}
+			if !queue.push(ctx, item) {
+				return
+			}
+		}
+		if poller != nil {
+			poller.run(ctx, stats, func(item watchItem) bool { return queue.push(ctx, item) })
+			return
+		}
 		for {
+			var item watchItem
 			select {
 			case event, ok := <-getEventsChannel(watcher, fsWatcher):
 				if !ok {
 					return
 				}
+				atomic.AddInt64(&stats.Events, 1)
+				item.event = event
 
-				// Check if we should process this event
-				var eventType WatchEvent
-				shouldProcess := false
-
-				if event.Has(fsnotify.Create) && eventMap[fsnotify.Create] {
-					shouldProcess = true
-					eventType = EventCreate
-				} else if event.Has(fsnotify.Write) && eventMap[fsnotify.Write] {
-					shouldProcess = true
-					eventType = EventModify
-				} else if event.Has(fsnotify.Remove) && eventMap[fsnotify.Remove] {
-					shouldProcess = true
-					eventType = EventDelete
-				} else if event.Has(fsnotify.Rename) && eventMap[fsnotify.Rename] {
-					shouldProcess = true
-					eventType = EventRename
-				} else if event.Has(fsnotify.Chmod) && eventMap[fsnotify.Chmod] {
-					shouldProcess = true
-					eventType = EventChmod
+			case err, ok := <-getErrorsChannel(watcher, fsWatcher):
+				if !ok {
+					return
 				}
+				item.err = err
 
-				if shouldProcess {
-					// Get file info
-					var fileInfo os.FileInfo
-					var err error
-					isDir := false
-
-					if !event.Has(fsnotify.Remove) {
-						fileInfo, err = os.Stat(event.Name)
-						if err != nil {
-							// Report the error but continue
-							handler(ctx, WatchResult{
-								Error: fmt.Errorf("error getting file info for %s: %w", event.Name, err),
-							})
-							continue
-						}
-						isDir = fileInfo.IsDir()
-
-						// If using non-recursive watcher and a directory is created, we need to add it manually
-						if !opts.Recursive && isDir && event.Has(fsnotify.Create) && fsWatcher != nil {
-							if err := fsWatcher.Add(event.Name); err != nil {
-								// Report the error but continue
-								handler(ctx, WatchResult{
-									Error: fmt.Errorf("error watching new directory %s: %w", event.Name, err),
-								})
-							}
-						}
-					}
-
-					// Check if the file matches the pattern
-					if opts.Pattern != "" {
-						matched, err := filepath.Match(opts.Pattern, filepath.Base(event.Name))
-						if err != nil {
-							// Report the error but continue
-							handler(ctx, WatchResult{
-								Error: fmt.Errorf("error matching pattern: %w", err),
-							})
-							continue
-						}
-						if !matched {
-							continue
-						}
-					}
-
-					// Check if the file should be ignored
-					if opts.IgnorePattern != "" {
-						matched, err := filepath.Match(opts.IgnorePattern, filepath.Base(event.Name))
-						if err != nil {
-							// Report the error but continue
-							handler(ctx, WatchResult{
-								Error: fmt.Errorf("error matching ignore pattern: %w", err),
-							})
-							continue
-						}
-						if matched {
-							continue
-						}
-					}
-
-					// Skip hidden files if not included
-					if !opts.IncludeHidden && isHidden(event.Name) {
-						continue
-					}
-
-					// Create a message for the event
-					msg := WatchMessage{
-						Path:     event.Name,
-						Name:     filepath.Base(event.Name),
-						Dir:      filepath.Dir(event.Name),
-						Time:     time.Now(),
-						Event:    eventType,
-						IsDir:    isDir,
-						Metadata: make(map[string]string),
-					}
+			case <-ctx.Done():
+				return
+			}
+			if !queue.push(ctx, item) {
+				return
+			}
+		}
+	}()
 
-					if fileInfo != nil {
-						msg.Size = fileInfo.Size()
-						msg.Time = fileInfo.ModTime()
-					}
+	// Dispatch queued events to the handler
+	go func() {
+		defer wg.Done()
+		for {
+			select {
+			case item, ok := <-queue.ch:
+				if !ok {
+					return
+				}
 
-					// Process the event
-					if err := handler(ctx, WatchResult{Message: msg}); err != nil {
-						// If the handler returns an error, report it
-						handler(ctx, WatchResult{
-							Error: fmt.Errorf("error handling event: %w", err),
-						})
-					}
+				// Report events lost from the queue before the ones after them
+				if n := queue.takeDropped(); n > 0 {
+					handler(ctx, WatchResult{Error: droppedError(n, defaultLogger(LogLevelWarn))})
 				}
 
-			case err, ok := <-getErrorsChannel(watcher, fsWatcher):
-				if !ok {
-					return
+				if item.err != nil {
+					// Report watcher errors
+					handler(ctx, WatchResult{Error: watcherError(item.err, stats)})
+					continue
 				}
-				// Report watcher errors
-				handler(ctx, WatchResult{
-					Error: fmt.Errorf("watcher error: %w", err),
-				})
+				track(item.event, item.existing)
 
 			case <-ctx.Done():
 				return
@@ -303,9 +591,67 @@ func Watch(ctx context.Context, root string, opts WatchOptions, handler WatchHan
 	// Wait for all goroutines to finish
 	wg.Wait()
 
+	if errors.Is(context.Cause(ctx), ErrWatchIdle) {
+		return ErrWatchIdle
+	}
 	return nil
 }
 
+// existingEntries returns the paths of the entries below root, those
+// directly in it unless recursive, in lexical order. Entries that vanish or
+// cannot be read while they are listed are left out.
+func existingEntries(ctx context.Context, root string, recursive bool) ([]string, error) {
+	includeRoot := false
+	opts := WalkOptions{
+		IncludeRoot:     &includeRoot,
+		SymlinkHandling: SymlinkReport,
+		Logger:          zap.NewNop(),
+	}
+	if !recursive {
+		opts.Filter.MaxDepth = 1
+	}
+	var mu sync.Mutex
+	var paths []string
+	err := WalkLimitWithOptions(ctx, root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return nil
+		}
+		mu.Lock()
+		paths = append(paths, path)
+		mu.Unlock()
+		return nil
+	}, opts)
+	sort.Strings(paths)
+	return paths, err
+}
+
+// newNotifyWatcher registers a watch on root with the platform's change
+// notifications: a recursive watcher if recursive is set, a plain one
+// otherwise.
+func newNotifyWatcher(root string, recursive bool) (*blink.RecursiveWatcher, *fsnotify.Watcher, error) {
+	if recursive {
+		// Use the recursive watcher from blink
+		watcher, err := blink.NewRecursiveWatcher(root)
+		if err != nil {
+			return nil, nil, fmt.Errorf("error creating recursive watcher: %w", err)
+		}
+		return watcher, nil, nil
+	}
+
+	// Use a regular fsnotify watcher for non-recursive watching
+	fsWatcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, nil, fmt.Errorf("error creating watcher: %w", err)
+	}
+
+	// Add the root directory to the watcher
+	if err := fsWatcher.Add(root); err != nil {
+		fsWatcher.Close()
+		return nil, nil, fmt.Errorf("error watching directory %s: %w", root, err)
+	}
+	return nil, fsWatcher, nil
+}
+
 // getEventsChannel returns the appropriate events channel based on which watcher is being used
 func getEventsChannel(recursiveWatcher *blink.RecursiveWatcher, fsWatcher *fsnotify.Watcher) <-chan fsnotify.Event {
 	if recursiveWatcher != nil {
@@ -322,62 +668,47 @@ func getErrorsChannel(recursiveWatcher *blink.RecursiveWatcher, fsWatcher *fsnot
 	return fsWatcher.Errors
 }
 
-// WatchWithExec watches for filesystem changes and executes a command for each event
+// WatchWithExec watches for filesystem changes and executes a command for each event.
+// An invalid template is reported before watching starts. The command runs
+// through the shell, sh -c or cmd /C on Windows, so it may use quoting,
+// redirections and pipes. Every placeholder, {} as well as {""}, is quoted
+// for it, so paths with spaces stay one argument and names like $(cmd) are
+// never run; placeholders need no quotes of their own. Templates remain
+// supported, but unless opts.ExecEnv is false the command can also read
+// the event from its environment, like with entr or watchman:
+// STRIDE_PATH, STRIDE_BASE, STRIDE_DIR, STRIDE_EVENT, STRIDE_SIZE,
+// STRIDE_TIME, STRIDE_MTIME and STRIDE_IS_DIR.
 func WatchWithExec(ctx context.Context, root string, opts WatchOptions, cmdTemplate string) error {
+	t, err := ParseTemplate(cmdTemplate)
+	if err != nil {
+		return err
+	}
+	env := newCommandEnv(execEnvEnabled(opts.ExecEnv))
+	out := newExecOutput(opts.Output, opts.ErrOutput, opts.ExecPrefixOutput)
 	return Watch(ctx, root, opts, func(ctx context.Context, result WatchResult) error {
 		if result.Error != nil {
 			return result.Error
 		}
 
-		// Replace {event} placeholder with the event type
-		cmd := strings.ReplaceAll(cmdTemplate, "{event}", string(result.Message.Event))
-
-		// Format the command using the message
-		formattedCmd := formatCommand(cmd, FindMessage{
-			Path:     result.Message.Path,
-			Name:     result.Message.Name,
-			Dir:      result.Message.Dir,
-			Size:     result.Message.Size,
-			Time:     result.Message.Time,
-			IsDir:    result.Message.IsDir,
-			Metadata: result.Message.Metadata,
-		})
-
-		// Execute the command
-		return executeCommand(ctx, formattedCmd, FindMessage{
-			Path:     result.Message.Path,
-			Name:     result.Message.Name,
-			Dir:      result.Message.Dir,
-			Size:     result.Message.Size,
-			Time:     result.Message.Time,
-			IsDir:    result.Message.IsDir,
-			Metadata: result.Message.Metadata,
-		})
+		// Execute the command with the placeholders replaced
+		return executeCommand(ctx, t.command(watchFindMessage(result.Message), result.Message.Event), env.forWatch(result.Message), result.Message.Path, out, opts.ExecGracePeriod)
 	})
 }
 
-// WatchWithFormat watches for filesystem changes and formats output for each event
+// WatchWithFormat watches for filesystem changes and formats output for each event.
+// An invalid template is reported before watching starts.
 func WatchWithFormat(ctx context.Context, root string, opts WatchOptions, formatTemplate string) error {
+	t, err := ParseTemplate(formatTemplate)
+	if err != nil {
+		return err
+	}
+	out := newOutputWriter(opts.Output)
 	return Watch(ctx, root, opts, func(ctx context.Context, result WatchResult) error {
 		if result.Error != nil {
 			return result.Error
 		}
 
-		// Replace {event} placeholder with the event type
-		format := strings.ReplaceAll(formatTemplate, "{event}", string(result.Message.Event))
-
-		// Format the output using the message
-		output := formatCommand(format, FindMessage{
-			Path:     result.Message.Path,
-			Name:     result.Message.Name,
-			Dir:      result.Message.Dir,
-			Size:     result.Message.Size,
-			Time:     result.Message.Time,
-			IsDir:    result.Message.IsDir,
-			Metadata: result.Message.Metadata,
-		})
-
-		fmt.Println(output)
-		return nil
+		_, err := fmt.Fprint(out, t.line(t.RenderWatch(result.Message)))
+		return err
 	})
 }
@@ -2,13 +2,19 @@ package stride
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"io/fs"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
+
+	"github.com/TFMV/stride/walk/walktest"
 )
 
 func TestWatch(t *testing.T) {
@@ -695,3 +701,299 @@ func TestWatchWithHiddenFiles(t *testing.T) {
 		t.Errorf("Exclude watcher did not receive event for normal file")
 	}
 }
+
+func TestWatchPathPatterns(t *testing.T) {
+	tests := []struct {
+		name     string
+		opts     WatchOptions
+		expected []string
+	}{
+		{
+			name:     "Doublestar pattern",
+			opts:     WatchOptions{Pattern: "src/**/*.go"},
+			expected: []string{"src/a/b/x.go", "src/main.go"},
+		},
+		{
+			name:     "Ignore directory tree",
+			opts:     WatchOptions{Pattern: "*.go", IgnorePattern: "vendor/**"},
+			expected: []string{"main.go", "src/a/b/x.go", "src/main.go"},
+		},
+		{
+			name:     "Base name pattern",
+			opts:     WatchOptions{Pattern: "*.txt"},
+			expected: []string{"notes.txt", "src/a/b/notes.txt", "vendor/pkg/notes.txt"},
+		},
+	}
+
+	files := []string{"main.go", "notes.txt", "src/main.go", "src/a/b/x.go", "src/a/b/notes.txt", "vendor/pkg/y.go", "vendor/pkg/notes.txt"}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpDir := t.TempDir()
+			for _, dir := range []string{"src/a/b", "vendor/pkg"} {
+				if err := os.MkdirAll(filepath.Join(tmpDir, filepath.FromSlash(dir)), 0755); err != nil {
+					t.Fatalf("Failed to create directory: %v", err)
+				}
+			}
+
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+
+			var mu sync.Mutex
+			received := make(map[string]bool)
+			watchDone := make(chan struct{})
+			go func() {
+				defer close(watchDone)
+				opts := tt.opts
+				opts.Recursive = true
+				opts.Events = []WatchEvent{EventCreate}
+				Watch(ctx, tmpDir, opts, func(ctx context.Context, result WatchResult) error {
+					if result.Error == nil && !result.Message.IsDir {
+						rel, _ := filepath.Rel(tmpDir, result.Message.Path)
+						mu.Lock()
+						received[filepath.ToSlash(rel)] = true
+						mu.Unlock()
+					}
+					return nil
+				})
+			}()
+
+			// Give the watcher a moment to initialize
+			time.Sleep(200 * time.Millisecond)
+
+			for _, name := range files {
+				if err := os.WriteFile(filepath.Join(tmpDir, filepath.FromSlash(name)), []byte("x"), 0644); err != nil {
+					t.Fatalf("Failed to create test file: %v", err)
+				}
+			}
+
+			// Wait for the expected events, then a little longer for stray ones
+			deadline := time.Now().Add(3 * time.Second)
+			for time.Now().Before(deadline) {
+				mu.Lock()
+				n := len(received)
+				mu.Unlock()
+				if n >= len(tt.expected) {
+					break
+				}
+				time.Sleep(50 * time.Millisecond)
+			}
+			time.Sleep(300 * time.Millisecond)
+			cancel()
+			<-watchDone
+
+			mu.Lock()
+			defer mu.Unlock()
+			var got []string
+			for name := range received {
+				got = append(got, name)
+			}
+			sort.Strings(got)
+			if strings.Join(got, ",") != strings.Join(tt.expected, ",") {
+				t.Errorf("Expected events for %v, got %v", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestWatchIdleTimeout(t *testing.T) {
+	tmpDir := t.TempDir()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	const idle = 300 * time.Millisecond
+	opts := WatchOptions{
+		Events:      []WatchEvent{EventCreate},
+		IdleTimeout: idle,
+		Timeout:     8 * time.Second,
+	}
+	var delivered int64
+	watchErr := make(chan error, 1)
+	go func() {
+		watchErr <- Watch(ctx, tmpDir, opts, func(ctx context.Context, result WatchResult) error {
+			if result.Error == nil {
+				atomic.AddInt64(&delivered, 1)
+			}
+			return nil
+		})
+	}()
+	time.Sleep(100 * time.Millisecond)
+
+	// Writing every 100ms keeps the watch alive well past the idle timeout
+	for i := 0; i < 12; i++ {
+		if err := os.WriteFile(filepath.Join(tmpDir, fmt.Sprintf("file%d.txt", i)), []byte("x"), 0644); err != nil {
+			t.Fatalf("Failed to create file: %v", err)
+		}
+		select {
+		case err := <-watchErr:
+			t.Fatalf("Expected the watch to stay alive while files are written, got %v after %d files", err, i)
+		case <-time.After(100 * time.Millisecond):
+		}
+	}
+	stopped := time.Now()
+
+	// Once the writes stop, the watch ends within the idle timeout and a margin
+	select {
+	case err := <-watchErr:
+		if !errors.Is(err, ErrWatchIdle) {
+			t.Errorf("Expected ErrWatchIdle, got %v", err)
+		}
+		if elapsed := time.Since(stopped); elapsed > idle+700*time.Millisecond {
+			t.Errorf("Expected the watch to end within %v of the last write, took %v", idle, elapsed)
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatal("Expected the watch to end after the idle timeout")
+	}
+	if atomic.LoadInt64(&delivered) == 0 {
+		t.Error("Expected events to be delivered")
+	}
+}
+
+func TestWatchWaitForRoot(t *testing.T) {
+	t.Run("appears", func(t *testing.T) {
+		root := filepath.Join(t.TempDir(), "later")
+		created := createRootLater(t, root, 200*time.Millisecond, "existing.txt")
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		events := make(chan WatchMessage, 10)
+		opts := WatchOptions{
+			Events:          []WatchEvent{EventCreate},
+			WaitForRoot:     5 * time.Second,
+			ProcessExisting: true,
+		}
+		watchErr := make(chan error, 1)
+		go func() {
+			watchErr <- Watch(ctx, root, opts, func(ctx context.Context, result WatchResult) error {
+				if result.Error == nil {
+					events <- result.Message
+				}
+				return nil
+			})
+		}()
+		if err := <-created; err != nil {
+			t.Fatalf("Failed to create the root: %v", err)
+		}
+
+		// The file already there is reported first, then the new one
+		for _, name := range []string{"existing.txt", "new.txt"} {
+			select {
+			case msg := <-events:
+				if msg.Name != name || msg.Event != EventCreate {
+					t.Errorf("Expected a create event for %s, got %s for %s", name, msg.Event, msg.Name)
+				}
+			case err := <-watchErr:
+				t.Fatalf("Expected the watch to run once the root appeared, got %v", err)
+			case <-time.After(5 * time.Second):
+				t.Fatalf("Expected a create event for %s", name)
+			}
+			if name == "existing.txt" {
+				if err := os.WriteFile(filepath.Join(root, "new.txt"), []byte("x"), 0644); err != nil {
+					t.Fatalf("Failed to create file: %v", err)
+				}
+			}
+		}
+		cancel()
+		if err := <-watchErr; err != nil {
+			t.Errorf("Expected the watch to end without error, got %v", err)
+		}
+	})
+
+	t.Run("never appears", func(t *testing.T) {
+		root := filepath.Join(t.TempDir(), "never")
+		const wait = 300 * time.Millisecond
+		start := time.Now()
+		err := Watch(context.Background(), root, WatchOptions{WaitForRoot: wait}, func(ctx context.Context, result WatchResult) error {
+			t.Errorf("Expected no result, got %+v", result)
+			return nil
+		})
+		if !errors.Is(err, fs.ErrNotExist) {
+			t.Fatalf("Expected an error matching fs.ErrNotExist, got %v", err)
+		}
+		if elapsed := time.Since(start); elapsed < wait {
+			t.Errorf("Expected the watch to wait %v, it returned after %v", wait, elapsed)
+		}
+	})
+}
+
+func TestWatchRecursiveMoves(t *testing.T) {
+	root := walktest.Tree{
+		"sub/inner/": walktest.Dir{},
+	}.Build(t)
+	outside := walktest.Tree{
+		"in/a.txt":      walktest.File{Content: "a"},
+		"in/deep/b.txt": walktest.File{Content: "b"},
+	}.Build(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	events := make(chan WatchMessage, 50)
+	watchErr := make(chan error, 1)
+	go func() {
+		watchErr <- Watch(ctx, root, WatchOptions{Recursive: true, Events: []WatchEvent{EventCreate}}, func(ctx context.Context, result WatchResult) error {
+			// A stale watch on the moved directory reports its new files
+			// under the old path, which no longer exists
+			if result.Error != nil {
+				t.Errorf("Unexpected watch error: %v", result.Error)
+				return nil
+			}
+			events <- result.Message
+			return nil
+		})
+	}()
+	time.Sleep(200 * time.Millisecond)
+
+	// expect waits for create events for the given paths, relative to root,
+	// and fails on any other
+	expect := func(paths ...string) {
+		t.Helper()
+		want := make(map[string]bool)
+		for _, p := range paths {
+			want[p] = true
+		}
+		for len(want) > 0 {
+			select {
+			case msg := <-events:
+				rel := relSlashPath(root, msg.Path)
+				if !want[rel] {
+					t.Errorf("Unexpected %s event for %s", msg.Event, rel)
+					continue
+				}
+				delete(want, rel)
+			case <-time.After(3 * time.Second):
+				t.Fatalf("Expected create events for %v", want)
+			}
+		}
+	}
+
+	// mv subdir elsewhere: nothing beneath it is reported any more, under
+	// its new path or its old one
+	moved := filepath.Join(outside, "sub")
+	if err := os.Rename(filepath.Join(root, "sub"), moved); err != nil {
+		t.Fatalf("Failed to move directory: %v", err)
+	}
+	time.Sleep(200 * time.Millisecond)
+	if err := os.WriteFile(filepath.Join(moved, "inner", "gone.txt"), []byte("x"), 0644); err != nil {
+		t.Fatalf("Failed to create file: %v", err)
+	}
+
+	// mv elsewhere/subdir into the watched tree: what it holds is reported
+	// as created, and changes beneath it are seen
+	if err := os.Rename(filepath.Join(outside, "in"), filepath.Join(root, "in")); err != nil {
+		t.Fatalf("Failed to move directory: %v", err)
+	}
+	expect("in", "in/a.txt", "in/deep", "in/deep/b.txt")
+	if err := os.WriteFile(filepath.Join(root, "in", "deep", "c.txt"), []byte("c"), 0644); err != nil {
+		t.Fatalf("Failed to create file: %v", err)
+	}
+	expect("in/deep/c.txt")
+
+	cancel()
+	if err := <-watchErr; err != nil {
+		t.Errorf("Expected the watch to end without error, got %v", err)
+	}
+	for len(events) > 0 {
+		msg := <-events
+		t.Errorf("Unexpected %s event for %s", msg.Event, msg.Path)
+	}
+}
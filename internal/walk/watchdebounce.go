@@ -0,0 +1,168 @@
+package stride
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// debounceRank orders events by significance when WatchOptions.Debounce
+// coalesces them: the more significant event of a path is the one kept.
+var debounceRank = map[WatchEvent]int{
+	EventChmod:  1,
+	EventModify: 2,
+	EventRename: 3,
+	EventDelete: 4,
+	EventCreate: 5,
+}
+
+// coalesceEvents merges next, a later event for the same path, into the
+// pending event prev, keeping the state of next and the more significant
+// of their events. A create followed by a delete or rename cancels out,
+// reported as false, and a delete or rename followed by a create, the file
+// having been replaced, is a modify.
+func coalesceEvents(prev, next WatchMessage) (WatchMessage, bool) {
+	merged := next
+	gone := next.Event == EventDelete || next.Event == EventRename
+	switch {
+	case prev.Event == EventCreate && gone:
+		return WatchMessage{}, false
+	case (prev.Event == EventDelete || prev.Event == EventRename) && next.Event == EventCreate:
+		merged.Event = EventModify
+	case debounceRank[prev.Event] > debounceRank[next.Event]:
+		merged.Event = prev.Event
+	}
+
+	// A content change within the window is not undone by a later touch
+	if prev.Metadata["change"] == ChangeContent && merged.Metadata["change"] != "" {
+		merged.Metadata["change"] = ChangeContent
+	}
+	return merged, true
+}
+
+// pendingEvent is the coalesced event of a path waiting for its window to
+// pass.
+type pendingEvent struct {
+	ctx   context.Context
+	msg   WatchMessage
+	timer *time.Timer
+}
+
+// debounceJob is a result ready for delivery.
+type debounceJob struct {
+	ctx    context.Context
+	result WatchResult
+}
+
+// watchDebouncer sits between the dispatcher and the handler, holding the
+// events of each path until none has followed for the debounce window and
+// delivering them coalesced into one. Errors are delivered at once.
+// Results are delivered to the handler one at a time.
+type watchDebouncer struct {
+	window  time.Duration
+	handler WatchHandler
+	stats   *WatchStats
+
+	mu      sync.Mutex
+	pending map[string]*pendingEvent
+	closed  bool
+	ready   chan debounceJob // To the deliverer
+	done    chan struct{}
+}
+
+// newWatchDebouncer creates a debouncer delivering to handler, or returns
+// nil if opts asks for no debouncing.
+func newWatchDebouncer(opts WatchOptions, handler WatchHandler, stats *WatchStats) *watchDebouncer {
+	if opts.Debounce <= 0 {
+		return nil
+	}
+	queueSize := opts.QueueSize
+	if queueSize <= 0 {
+		queueSize = DefaultWatchQueueSize
+	}
+	d := &watchDebouncer{
+		window:  opts.Debounce,
+		handler: handler,
+		stats:   stats,
+		pending: make(map[string]*pendingEvent),
+		ready:   make(chan debounceJob, queueSize),
+		done:    make(chan struct{}),
+	}
+	go d.deliver()
+	return d
+}
+
+// handle starts the window of the event of result, or merges it into the
+// event already waiting for its path and starts the window again. Errors
+// returned by the handler are reported to it when the result is delivered,
+// so handle itself always returns nil.
+func (d *watchDebouncer) handle(ctx context.Context, result WatchResult) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if result.Error != nil {
+		d.ready <- debounceJob{ctx: ctx, result: result}
+		return nil
+	}
+
+	msg := result.Message
+	p := d.pending[msg.Path]
+	if p == nil {
+		p = &pendingEvent{ctx: ctx, msg: msg}
+		p.timer = time.AfterFunc(d.window, func() { d.flush(msg.Path, p) })
+		d.pending[msg.Path] = p
+		return nil
+	}
+	merged, keep := coalesceEvents(p.msg, msg)
+	if !keep {
+		p.timer.Stop()
+		delete(d.pending, msg.Path)
+		atomic.AddInt64(&d.stats.Coalesced, 2)
+		return nil
+	}
+	p.msg = merged
+	p.timer.Reset(d.window)
+	atomic.AddInt64(&d.stats.Coalesced, 1)
+	return nil
+}
+
+// flush queues the event p of path for delivery once its window has
+// passed, unless it was cancelled or already delivered.
+func (d *watchDebouncer) flush(path string, p *pendingEvent) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.closed || d.pending[path] != p {
+		return
+	}
+	delete(d.pending, path)
+	d.ready <- debounceJob{ctx: p.ctx, result: WatchResult{Message: p.msg}}
+}
+
+// deliver passes ready results to the handler in turn.
+func (d *watchDebouncer) deliver() {
+	defer close(d.done)
+	for job := range d.ready {
+		if err := d.handler(job.ctx, job.result); err != nil && job.result.Error == nil {
+			// If the handler returns an error, report it
+			d.handler(job.ctx, WatchResult{
+				Error: fmt.Errorf("error handling event: %w", err),
+			})
+		}
+	}
+}
+
+// close delivers the events still waiting for their window at once, then
+// the results already queued. handle must not be called afterwards.
+func (d *watchDebouncer) close() {
+	d.mu.Lock()
+	d.closed = true
+	for path, p := range d.pending {
+		p.timer.Stop()
+		delete(d.pending, path)
+		d.ready <- debounceJob{ctx: p.ctx, result: WatchResult{Message: p.msg}}
+	}
+	close(d.ready)
+	d.mu.Unlock()
+	<-d.done
+}
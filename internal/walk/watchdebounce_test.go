@@ -0,0 +1,175 @@
+package stride
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestCoalesceEvents(t *testing.T) {
+	tests := []struct {
+		prev, next WatchEvent
+		want       WatchEvent // Empty if the two cancel out
+	}{
+		{EventModify, EventModify, EventModify},
+		{EventCreate, EventModify, EventCreate},
+		{EventCreate, EventChmod, EventCreate},
+		{EventCreate, EventDelete, ""},
+		{EventCreate, EventRename, ""},
+		{EventDelete, EventCreate, EventModify},
+		{EventRename, EventCreate, EventModify},
+		{EventModify, EventDelete, EventDelete},
+		{EventChmod, EventModify, EventModify},
+		{EventModify, EventChmod, EventModify},
+	}
+	for _, tc := range tests {
+		prev := WatchMessage{Event: tc.prev, Size: 1, Metadata: map[string]string{}}
+		next := WatchMessage{Event: tc.next, Size: 2, Metadata: map[string]string{}}
+		merged, keep := coalesceEvents(prev, next)
+		if tc.want == "" {
+			if keep {
+				t.Errorf("Expected %s then %s to cancel out, got %s", tc.prev, tc.next, merged.Event)
+			}
+			continue
+		}
+		if !keep || merged.Event != tc.want {
+			t.Errorf("Expected %s then %s to be %s, got %s (kept %v)", tc.prev, tc.next, tc.want, merged.Event, keep)
+		}
+		if merged.Size != 2 {
+			t.Errorf("Expected the latest size for %s then %s, got %d", tc.prev, tc.next, merged.Size)
+		}
+	}
+
+	// A touch after a write still reports a content change
+	prev := WatchMessage{Event: EventModify, Metadata: map[string]string{"change": ChangeContent}}
+	next := WatchMessage{Event: EventModify, Metadata: map[string]string{"change": ChangeMetadata}}
+	if merged, _ := coalesceEvents(prev, next); merged.Metadata["change"] != ChangeContent {
+		t.Errorf("Expected %s, got %s", ChangeContent, merged.Metadata["change"])
+	}
+}
+
+func TestWatchDebounce(t *testing.T) {
+	tmpDir := t.TempDir()
+	saved := filepath.Join(tmpDir, "saved.txt")
+	if err := os.WriteFile(saved, []byte("v0"), 0644); err != nil {
+		t.Fatalf("Failed to create file: %v", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	const window = 300 * time.Millisecond
+	var mu sync.Mutex
+	var delivered []WatchMessage
+	stats := &WatchStats{}
+	opts := WatchOptions{Debounce: window, Stats: stats}
+	watchErr := make(chan error, 1)
+	go func() {
+		watchErr <- Watch(ctx, tmpDir, opts, func(ctx context.Context, result WatchResult) error {
+			if result.Error != nil {
+				t.Errorf("Unexpected watch error: %v", result.Error)
+				return nil
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			delivered = append(delivered, result.Message)
+			return nil
+		})
+	}()
+	time.Sleep(200 * time.Millisecond)
+
+	// An editor saving several times in quick succession
+	for i := 1; i <= 4; i++ {
+		if err := os.WriteFile(saved, []byte(strings.Repeat("v", i*10)), 0644); err != nil {
+			t.Fatalf("Failed to write file: %v", err)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// A file created and written, and one created and removed at once
+	created := filepath.Join(tmpDir, "created.txt")
+	if err := os.WriteFile(created, []byte("new"), 0644); err != nil {
+		t.Fatalf("Failed to create file: %v", err)
+	}
+	if err := os.WriteFile(created, []byte("newer"), 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+	transient := filepath.Join(tmpDir, "transient.txt")
+	if err := os.WriteFile(transient, []byte("x"), 0644); err != nil {
+		t.Fatalf("Failed to create file: %v", err)
+	}
+	if err := os.Remove(transient); err != nil {
+		t.Fatalf("Failed to remove file: %v", err)
+	}
+
+	time.Sleep(3 * window)
+	cancel()
+	if err := <-watchErr; err != nil {
+		t.Fatalf("Watch failed: %v", err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	byName := make(map[string][]WatchMessage)
+	for _, msg := range delivered {
+		byName[msg.Name] = append(byName[msg.Name], msg)
+	}
+	if got := byName["saved.txt"]; len(got) != 1 {
+		t.Errorf("Expected one event for the rapid writes, got %d: %+v", len(got), got)
+	} else if got[0].Event != EventModify || got[0].Size != 40 {
+		t.Errorf("Expected a modify with the final size 40, got %s with size %d", got[0].Event, got[0].Size)
+	}
+	if got := byName["created.txt"]; len(got) != 1 || got[0].Event != EventCreate || got[0].Size != 5 {
+		t.Errorf("Expected one create with the final size 5, got %+v", got)
+	}
+	if got := byName["transient.txt"]; len(got) != 0 {
+		t.Errorf("Expected a create and delete to cancel out, got %+v", got)
+	}
+	if stats.Coalesced == 0 {
+		t.Error("Expected coalesced events to be counted")
+	}
+}
+
+func TestWatchDebounceFlushOnStop(t *testing.T) {
+	tmpDir := t.TempDir()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// The window outlasts the watch, so the event is delivered as it stops
+	delivered := make(chan WatchMessage, 1)
+	watchErr := make(chan error, 1)
+	go func() {
+		watchErr <- Watch(ctx, tmpDir, WatchOptions{Debounce: time.Hour, Events: []WatchEvent{EventCreate}}, func(ctx context.Context, result WatchResult) error {
+			if result.Error == nil {
+				delivered <- result.Message
+			}
+			return nil
+		})
+	}()
+	time.Sleep(200 * time.Millisecond)
+	if err := os.WriteFile(filepath.Join(tmpDir, "late.txt"), []byte("x"), 0644); err != nil {
+		t.Fatalf("Failed to create file: %v", err)
+	}
+	time.Sleep(200 * time.Millisecond)
+	select {
+	case msg := <-delivered:
+		t.Fatalf("Expected the event to be held, got %s for %s", msg.Event, msg.Name)
+	default:
+	}
+
+	cancel()
+	if err := <-watchErr; err != nil {
+		t.Fatalf("Watch failed: %v", err)
+	}
+	select {
+	case msg := <-delivered:
+		if msg.Name != "late.txt" || msg.Event != EventCreate {
+			t.Errorf("Expected a create for late.txt, got %s for %s", msg.Event, msg.Name)
+		}
+	default:
+		t.Error("Expected the held event to be delivered when the watch stopped")
+	}
+}
@@ -0,0 +1,89 @@
+package stride
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// watchedDirs tracks the directories a recursive watch has registered with
+// the platform watcher. Directories removed or renamed away are dropped with
+// everything beneath them, whose watches would otherwise go on reporting
+// events under paths that no longer exist, and directories that appear, by
+// being created or moved into the tree, are added with their subdirectories.
+// It is only used from the goroutine dispatching events.
+type watchedDirs struct {
+	watcher *fsnotify.Watcher
+	dirs    map[string]struct{}
+}
+
+// newWatchedDirs tracks the directories watcher already watches.
+func newWatchedDirs(watcher *fsnotify.Watcher) *watchedDirs {
+	w := &watchedDirs{watcher: watcher, dirs: make(map[string]struct{})}
+	for _, dir := range watcher.WatchList() {
+		w.dirs[filepath.Clean(dir)] = struct{}{}
+	}
+	return w
+}
+
+// addTree watches the directory at path, if it is one, and every directory
+// beneath it, and returns the paths of the entries beneath it in lexical
+// order. No event reported those that were there before the directory was
+// watched, while those created since may be reported by an event as well.
+// Entries that cannot be read are left out, as is the subtree of a
+// directory that cannot be watched, which is reported in the error.
+func (w *watchedDirs) addTree(path string) ([]string, error) {
+	path = filepath.Clean(path)
+	info, err := os.Lstat(path)
+	if err != nil || !info.IsDir() {
+		return nil, nil
+	}
+	var entries []string
+	var errs []error
+	filepath.WalkDir(path, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		if p != path {
+			entries = append(entries, p)
+		}
+		if !d.IsDir() {
+			return nil
+		}
+		if _, ok := w.dirs[p]; ok {
+			return nil
+		}
+		if err := w.watcher.Add(p); err != nil {
+			errs = append(errs, err)
+			return filepath.SkipDir
+		}
+		w.dirs[p] = struct{}{}
+		return nil
+	})
+	sort.Strings(entries)
+	return entries, errors.Join(errs...)
+}
+
+// remove stops watching the directory at path and every directory beneath
+// it. Paths that are not watched are ignored, as nothing beneath them is.
+func (w *watchedDirs) remove(path string) {
+	path = filepath.Clean(path)
+	if _, ok := w.dirs[path]; !ok {
+		return
+	}
+	for dir := range w.dirs {
+		if dir != path && !isWithinDir(path, dir) {
+			continue
+		}
+		delete(w.dirs, dir)
+
+		// The platform watcher drops the watches of deleted directories,
+		// and of a watched directory moved away, by itself, so an error
+		// means there was nothing left to remove
+		w.watcher.Remove(dir)
+	}
+}
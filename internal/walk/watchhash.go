@@ -0,0 +1,193 @@
+package stride
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"sync"
+
+	"github.com/cespare/xxhash/v2"
+)
+
+// HashAlgorithm selects the digest WatchOptions.HashAlgorithm attaches to
+// events.
+type HashAlgorithm string
+
+// Hash algorithms
+const (
+	HashNone     HashAlgorithm = "none"
+	HashSHA256   HashAlgorithm = "sha256"
+	HashXXHash64 HashAlgorithm = "xxhash64"
+)
+
+// DefaultWatchMaxHashSize is the size above which files are not hashed when
+// WatchOptions.MaxHashSize is zero.
+const DefaultWatchMaxHashSize = 64 << 20
+
+// HashSkippedSize is the "hash" metadata of events for files larger than
+// WatchOptions.MaxHashSize.
+const HashSkippedSize = "skipped:size"
+
+// watchHashWorkers is the number of files hashed at the same time.
+const watchHashWorkers = 4
+
+// newHashFunc returns the constructor of the digests of algo, or nil for
+// HashNone.
+func newHashFunc(algo HashAlgorithm) (func() hash.Hash, error) {
+	switch algo {
+	case "", HashNone:
+		return nil, nil
+	case HashSHA256:
+		return sha256.New, nil
+	case HashXXHash64:
+		return func() hash.Hash { return xxhash.New() }, nil
+	default:
+		return nil, fmt.Errorf("unknown hash algorithm %q (must be none, sha256 or xxhash64)", algo)
+	}
+}
+
+// hashJob is a result waiting to be delivered, with done closed once its
+// digest is attached if it needs one.
+type hashJob struct {
+	ctx    context.Context
+	result WatchResult
+	done   chan struct{}
+}
+
+// watchHasher sits between the dispatcher and the handler, hashing the
+// files of create and modify events on a pool of workers so that the
+// dispatcher never waits for a read. Results are still delivered to the
+// handler one at a time and in the order they were dispatched.
+type watchHasher struct {
+	newHash func() hash.Hash
+	maxSize int64
+	handler WatchHandler
+
+	jobs    chan *hashJob // To the workers
+	ordered chan *hashJob // To the deliverer, in dispatch order
+	workers sync.WaitGroup
+	done    chan struct{}
+}
+
+// newWatchHasher creates a hasher delivering to handler, or returns nil if
+// opts asks for no hashing.
+func newWatchHasher(opts WatchOptions, handler WatchHandler) (*watchHasher, error) {
+	newHash, err := newHashFunc(opts.HashAlgorithm)
+	if err != nil || newHash == nil {
+		return nil, err
+	}
+	maxSize := opts.MaxHashSize
+	if maxSize <= 0 {
+		maxSize = DefaultWatchMaxHashSize
+	}
+	queueSize := opts.QueueSize
+	if queueSize <= 0 {
+		queueSize = DefaultWatchQueueSize
+	}
+	h := &watchHasher{
+		newHash: newHash,
+		maxSize: maxSize,
+		handler: handler,
+		jobs:    make(chan *hashJob, watchHashWorkers),
+		ordered: make(chan *hashJob, queueSize),
+		done:    make(chan struct{}),
+	}
+	h.workers.Add(watchHashWorkers)
+	for i := 0; i < watchHashWorkers; i++ {
+		go h.work()
+	}
+	go h.deliver()
+	return h, nil
+}
+
+// handle queues result for delivery, handing its file to the workers first
+// if it needs hashing. Errors returned by the handler are reported to it
+// when the result is delivered, so handle itself always returns nil.
+func (h *watchHasher) handle(ctx context.Context, result WatchResult) error {
+	job := &hashJob{ctx: ctx, result: result}
+	msg := result.Message
+	if result.Error == nil && !msg.IsDir && (msg.Event == EventCreate || msg.Event == EventModify) {
+		if msg.Size > h.maxSize {
+			msg.Metadata["hash"] = HashSkippedSize
+		} else {
+			job.done = make(chan struct{})
+		}
+	}
+	h.ordered <- job
+	if job.done != nil {
+		h.jobs <- job
+	}
+	return nil
+}
+
+// work hashes the files of jobs until the hasher is closed.
+func (h *watchHasher) work() {
+	defer h.workers.Done()
+	for job := range h.jobs {
+		msg := job.result.Message
+		digest, err := h.hashFile(msg.Path)
+		switch {
+		case err != nil:
+			msg.Metadata["hash_error"] = err.Error()
+		case digest != "":
+			msg.Metadata["hash"] = digest
+		}
+		close(job.done)
+	}
+}
+
+// hashFile returns the digest of the file at path, HashSkippedSize if it
+// grew past the limit since its event, or "" if it is not a regular file.
+func (h *watchHasher) hashFile(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return "", err
+	}
+	if !info.Mode().IsRegular() {
+		return "", nil
+	}
+	if info.Size() > h.maxSize {
+		return HashSkippedSize, nil
+	}
+	digest := h.newHash()
+	if _, err := io.Copy(digest, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(digest.Sum(nil)), nil
+}
+
+// deliver passes queued results to the handler in order, waiting for each
+// digest in turn.
+func (h *watchHasher) deliver() {
+	defer close(h.done)
+	for job := range h.ordered {
+		if job.done != nil {
+			<-job.done
+		}
+		if err := h.handler(job.ctx, job.result); err != nil && job.result.Error == nil {
+			// If the handler returns an error, report it
+			h.handler(job.ctx, WatchResult{
+				Error: fmt.Errorf("error handling event: %w", err),
+			})
+		}
+	}
+}
+
+// close delivers the results already queued and stops the workers. handle
+// must not be called afterwards.
+func (h *watchHasher) close() {
+	close(h.ordered)
+	close(h.jobs)
+	h.workers.Wait()
+	<-h.done
+}
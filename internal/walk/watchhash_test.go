@@ -0,0 +1,141 @@
+package stride
+
+import (
+	"context"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestWatchHash(t *testing.T) {
+	dir, staging := t.TempDir(), t.TempDir()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var mu sync.Mutex
+	events := make(map[string][]WatchMessage)
+	opts := WatchOptions{
+		HashAlgorithm: HashSHA256,
+		MaxHashSize:   100,
+	}
+	finished := make(chan struct{})
+	go func() {
+		defer close(finished)
+		Watch(ctx, dir, opts, func(ctx context.Context, result WatchResult) error {
+			if result.Error == nil {
+				mu.Lock()
+				events[result.Message.Name] = append(events[result.Message.Name], result.Message)
+				mu.Unlock()
+			}
+			return nil
+		})
+	}()
+	time.Sleep(200 * time.Millisecond)
+
+	// Files are moved in whole, so their create events see all their content
+	for name, content := range map[string]string{
+		"small.txt": "hello world",
+		"large.txt": strings.Repeat("x", 200),
+	} {
+		if err := os.WriteFile(filepath.Join(staging, name), []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to create file: %v", err)
+		}
+		if err := os.Rename(filepath.Join(staging, name), filepath.Join(dir, name)); err != nil {
+			t.Fatalf("Failed to move file: %v", err)
+		}
+	}
+	if err := os.Mkdir(filepath.Join(dir, "sub"), 0755); err != nil {
+		t.Fatalf("Failed to create directory: %v", err)
+	}
+
+	// waitFor returns the first event of name of type event
+	waitFor := func(name string, event WatchEvent) WatchMessage {
+		t.Helper()
+		deadline := time.Now().Add(5 * time.Second)
+		for time.Now().Before(deadline) {
+			mu.Lock()
+			for _, msg := range events[name] {
+				if msg.Event == event {
+					mu.Unlock()
+					return msg
+				}
+			}
+			mu.Unlock()
+			time.Sleep(20 * time.Millisecond)
+		}
+		t.Fatalf("Expected a %s event for %s", event, name)
+		return WatchMessage{}
+	}
+
+	expected := "b94d27b9934d3e08a52e52d7da7dabfac484efe37a5380ee9088f7ace2efcde9"
+	if got := waitFor("small.txt", EventCreate).Metadata["hash"]; got != expected {
+		t.Errorf("Expected hash %s, got %q", expected, got)
+	}
+	if got := waitFor("large.txt", EventCreate).Metadata["hash"]; got != HashSkippedSize {
+		t.Errorf("Expected hash %s, got %q", HashSkippedSize, got)
+	}
+
+	// Directories and deletes are left alone
+	if err := os.Remove(filepath.Join(dir, "small.txt")); err != nil {
+		t.Fatalf("Failed to remove file: %v", err)
+	}
+	for _, msg := range []WatchMessage{waitFor("sub", EventCreate), waitFor("small.txt", EventDelete)} {
+		if _, ok := msg.Metadata["hash"]; ok {
+			t.Errorf("Expected no hash for the %s event of %s, got %q", msg.Event, msg.Name, msg.Metadata["hash"])
+		}
+	}
+
+	cancel()
+	<-finished
+}
+
+func TestWatchHashUnreadable(t *testing.T) {
+	// The file is gone by the time it is hashed
+	path := filepath.Join(t.TempDir(), "gone.txt")
+	var got WatchResult
+	h, err := newWatchHasher(WatchOptions{HashAlgorithm: HashXXHash64}, func(ctx context.Context, result WatchResult) error {
+		got = result
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Failed to create hasher: %v", err)
+	}
+	msg := WatchMessage{Path: path, Event: EventModify, Size: 1, Metadata: make(map[string]string)}
+	h.handle(context.Background(), WatchResult{Message: msg})
+	h.close()
+
+	// The event is still delivered, with the error attached
+	if got.Message.Path != path || got.Message.Metadata["hash_error"] == "" {
+		t.Errorf("Expected the event with a hash error, got %+v", got)
+	}
+}
+
+func TestHashAlgorithms(t *testing.T) {
+	tests := []struct {
+		algo     HashAlgorithm
+		expected string
+	}{
+		{HashSHA256, "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"},
+		{HashXXHash64, "ef46db3751d8e999"},
+	}
+	for _, tt := range tests {
+		newHash, err := newHashFunc(tt.algo)
+		if err != nil {
+			t.Fatalf("Failed to get %s: %v", tt.algo, err)
+		}
+		if got := hex.EncodeToString(newHash().Sum(nil)); got != tt.expected {
+			t.Errorf("Expected the %s digest of nothing to be %s, got %s", tt.algo, tt.expected, got)
+		}
+	}
+
+	if newHash, err := newHashFunc(HashNone); err != nil || newHash != nil {
+		t.Errorf("Expected no hashing for %s, got an error %v", HashNone, err)
+	}
+	if err := Watch(context.Background(), t.TempDir(), WatchOptions{HashAlgorithm: "md5"}, nil); err == nil {
+		t.Error("Expected an error for an unknown algorithm")
+	}
+}
@@ -0,0 +1,72 @@
+package stride
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"time"
+)
+
+// watchJSONEvent is one line written by WatchWithJSON.
+type watchJSONEvent struct {
+	Event      WatchEvent `json:"event"`
+	Path       string     `json:"path"`
+	Base       string     `json:"base"`
+	Dir        string     `json:"dir"`
+	Size       int64      `json:"size"`
+	Mtime      time.Time  `json:"mtime"`
+	IsDir      bool       `json:"is_dir"`
+	ReceivedAt time.Time  `json:"received_at"`
+	Hash       string     `json:"hash,omitempty"`
+	HashError  string     `json:"hash_error,omitempty"`
+}
+
+// jsonError is the line written by WatchWithJSON and FindWithJSON for an
+// error result.
+type jsonError struct {
+	Error string `json:"error"`
+}
+
+// WatchWithJSON watches for filesystem changes and writes each event to w as
+// a JSON object on its own line, with the fields event, path, base, dir,
+// size, mtime, is_dir and received_at, plus hash or hash_error under
+// WatchOptions.HashAlgorithm. Errors are written as
+// {"error": "..."} objects so consumers see them in the same stream.
+//
+// Each line reaches w in a single write as soon as the event arrives, and
+// writes are serialized. If w is nil, opts.Output is used.
+func WatchWithJSON(ctx context.Context, root string, opts WatchOptions, w io.Writer) error {
+	if w == nil {
+		w = opts.Output
+	}
+	out := newOutputWriter(w)
+	return Watch(ctx, root, opts, func(ctx context.Context, result WatchResult) error {
+		if result.Error != nil {
+			return writeJSONLine(out, jsonError{Error: result.Error.Error()})
+		}
+
+		msg := result.Message
+		return writeJSONLine(out, watchJSONEvent{
+			Event:      msg.Event,
+			Path:       msg.Path,
+			Base:       msg.Name,
+			Dir:        msg.Dir,
+			Size:       msg.Size,
+			Mtime:      msg.Time,
+			IsDir:      msg.IsDir,
+			ReceivedAt: time.Now(),
+			Hash:       msg.Metadata["hash"],
+			HashError:  msg.Metadata["hash_error"],
+		})
+	})
+}
+
+// writeJSONLine writes v to w as one newline-terminated line.
+func writeJSONLine(w io.Writer, v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(append(data, '\n'))
+	return err
+}
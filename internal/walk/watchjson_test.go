@@ -0,0 +1,102 @@
+package stride
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWatchWithJSON(t *testing.T) {
+	tmpDir := t.TempDir()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	pr, pw := io.Pipe()
+	done := make(chan error, 1)
+	go func() {
+		opts := WatchOptions{Events: []WatchEvent{EventCreate, EventDelete}}
+		done <- WatchWithJSON(ctx, tmpDir, opts, pw)
+		pw.Close()
+	}()
+
+	// Give the watcher a moment to initialize
+	time.Sleep(200 * time.Millisecond)
+
+	file := filepath.Join(tmpDir, "test.txt")
+	if err := os.WriteFile(file, []byte("test"), 0644); err != nil {
+		t.Fatalf("Failed to create file: %v", err)
+	}
+	lines := bufio.NewScanner(pr)
+	var events []map[string]interface{}
+	for len(events) < 2 && lines.Scan() {
+		var event map[string]interface{}
+		if err := json.Unmarshal(lines.Bytes(), &event); err != nil {
+			t.Fatalf("Failed to parse %q: %v", lines.Text(), err)
+		}
+		events = append(events, event)
+		if len(events) == 1 {
+			if err := os.Remove(file); err != nil {
+				t.Fatalf("Failed to remove file: %v", err)
+			}
+		}
+	}
+	cancel()
+	go io.Copy(io.Discard, pr)
+	if err := <-done; err != nil {
+		t.Fatalf("WatchWithJSON failed: %v", err)
+	}
+
+	if len(events) != 2 {
+		t.Fatalf("Expected 2 events, got %v", events)
+	}
+	for i, want := range []WatchEvent{EventCreate, EventDelete} {
+		event := events[i]
+		if event["event"] != string(want) || event["path"] != file || event["base"] != "test.txt" || event["dir"] != tmpDir {
+			t.Errorf("Event %d: expected %s of %s, got %v", i, want, file, event)
+		}
+		for _, field := range []string{"size", "mtime", "is_dir", "received_at"} {
+			if _, ok := event[field]; !ok {
+				t.Errorf("Event %d: missing field %q in %v", i, field, event)
+			}
+		}
+	}
+}
+
+func TestWatchWithJSONErrors(t *testing.T) {
+	tmpDir := t.TempDir()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	// A malformed pattern fails for every event, which must be reported
+	pr, pw := io.Pipe()
+	done := make(chan error, 1)
+	go func() {
+		done <- WatchWithJSON(ctx, tmpDir, WatchOptions{Pattern: "["}, pw)
+		pw.Close()
+	}()
+	time.Sleep(200 * time.Millisecond)
+
+	if err := os.WriteFile(filepath.Join(tmpDir, "test.txt"), []byte("test"), 0644); err != nil {
+		t.Fatalf("Failed to create file: %v", err)
+	}
+	lines := bufio.NewScanner(pr)
+	if !lines.Scan() {
+		t.Fatal("Expected an error line")
+	}
+	var line map[string]string
+	if err := json.Unmarshal(lines.Bytes(), &line); err != nil {
+		t.Fatalf("Failed to parse %q: %v", lines.Text(), err)
+	}
+	if !strings.HasPrefix(line["error"], "error matching pattern") {
+		t.Errorf("Expected a pattern error, got %q", lines.Text())
+	}
+	cancel()
+	go io.Copy(io.Discard, pr)
+	<-done
+}
@@ -0,0 +1,185 @@
+package stride
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+	"unique"
+
+	"github.com/fsnotify/fsnotify"
+	"go.uber.org/zap"
+)
+
+// DefaultPollInterval is how often the poll backend walks the watched tree
+// when WatchOptions.PollInterval is not set.
+const DefaultPollInterval = 2 * time.Second
+
+// WatchBackend selects how Watch learns about changes.
+type WatchBackend int
+
+const (
+	// WatchBackendAuto uses the platform's change notifications, unless
+	// DetectRemoteFS reports the root to be on a network filesystem or the
+	// watch cannot be registered, in which case it polls.
+	WatchBackendAuto WatchBackend = iota
+	// WatchBackendNotify uses the platform's change notifications only,
+	// failing if the watch cannot be registered.
+	WatchBackendNotify
+	// WatchBackendPoll walks the tree every PollInterval and reports the
+	// differences from the previous walk. It sees changes that
+	// notifications miss, such as those made by other hosts on network
+	// filesystems, but changes undone between two walks go unnoticed, and
+	// renames are reported as a delete and a create.
+	WatchBackendPoll
+)
+
+// pollKey identifies an entry of a pollSnapshot. The directory and name are
+// interned, so the many entries of a directory, and the walks after the
+// first, share their strings.
+type pollKey struct {
+	dir, name unique.Handle[string]
+}
+
+func newPollKey(path string) pollKey {
+	return pollKey{dir: unique.Make(filepath.Dir(path)), name: unique.Make(filepath.Base(path))}
+}
+
+func (k pollKey) path() string {
+	return filepath.Join(k.dir.Value(), k.name.Value())
+}
+
+// pollEntry is what a pollSnapshot records of an entry to tell whether it
+// changed.
+type pollEntry struct {
+	size    int64
+	modTime int64 // Unix nanoseconds
+	mode    os.FileMode
+}
+
+// pollSnapshot records the entries beneath a watched root.
+type pollSnapshot map[pollKey]pollEntry
+
+// watchPoller is the poll backend of Watch.
+type watchPoller struct {
+	root     string
+	interval time.Duration
+	walkOpts WalkOptions
+	snapshot pollSnapshot
+}
+
+// newWatchPoller returns the poll backend for root, having taken the first
+// snapshot, against which the first poll is compared.
+func newWatchPoller(ctx context.Context, root string, opts WatchOptions) (*watchPoller, error) {
+	interval := opts.PollInterval
+	if interval <= 0 {
+		interval = DefaultPollInterval
+	}
+	includeRoot := false
+	p := &watchPoller{
+		root:     root,
+		interval: interval,
+		walkOpts: WalkOptions{
+			IncludeRoot:     &includeRoot,
+			SymlinkHandling: SymlinkReport,
+			Logger:          zap.NewNop(),
+		},
+	}
+	if !opts.Recursive {
+		p.walkOpts.Filter.MaxDepth = 1
+	}
+	var err error
+	p.snapshot, err = p.scan(ctx)
+	return p, err
+}
+
+// scan walks the tree and returns its snapshot. Entries that vanish or
+// cannot be read during the walk are left out.
+func (p *watchPoller) scan(ctx context.Context) (pollSnapshot, error) {
+	var mu sync.Mutex
+	snap := make(pollSnapshot, len(p.snapshot))
+	err := WalkLimitWithOptions(ctx, p.root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return nil
+		}
+		entry := pollEntry{size: info.Size(), modTime: info.ModTime().UnixNano(), mode: info.Mode()}
+		key := newPollKey(path)
+		mu.Lock()
+		snap[key] = entry
+		mu.Unlock()
+		return nil
+	}, p.walkOpts)
+	return snap, err
+}
+
+// run polls until ctx is done, passing the changes found and the errors of
+// failed walks to emit, until it returns false.
+func (p *watchPoller) run(ctx context.Context, stats *WatchStats, emit func(watchItem) bool) {
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+
+		snap, err := p.scan(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+		if err != nil {
+			// Keep the previous snapshot rather than report everything gone
+			if !emit(watchItem{err: err}) {
+				return
+			}
+			continue
+		}
+		events := diffSnapshots(p.snapshot, snap)
+		p.snapshot = snap
+		for _, event := range events {
+			atomic.AddInt64(&stats.Events, 1)
+			if !emit(watchItem{event: event}) {
+				return
+			}
+		}
+	}
+}
+
+// diffSnapshots returns the events turning old into cur: creates, writes and
+// chmods in path order, so a new directory comes before its entries, then
+// removes in reverse path order, so a removed directory comes after them.
+// Directories are never reported written, as their times change with their
+// entries.
+func diffSnapshots(old, cur pollSnapshot) []fsnotify.Event {
+	var changed, removed []fsnotify.Event
+	for key, entry := range cur {
+		prev, ok := old[key]
+		var op fsnotify.Op
+		switch {
+		case !ok:
+			op = fsnotify.Create
+		case entry.mode.Type() != prev.mode.Type():
+			// Replaced by an entry of another type
+			op = fsnotify.Create
+		case !entry.mode.IsDir() && (entry.size != prev.size || entry.modTime != prev.modTime):
+			op = fsnotify.Write
+		case entry.mode != prev.mode:
+			op = fsnotify.Chmod
+		default:
+			continue
+		}
+		changed = append(changed, fsnotify.Event{Name: key.path(), Op: op})
+	}
+	for key := range old {
+		if _, ok := cur[key]; !ok {
+			removed = append(removed, fsnotify.Event{Name: key.path(), Op: fsnotify.Remove})
+		}
+	}
+	sort.Slice(changed, func(i, j int) bool { return changed[i].Name < changed[j].Name })
+	sort.Slice(removed, func(i, j int) bool { return removed[i].Name > removed[j].Name })
+	return append(changed, removed...)
+}
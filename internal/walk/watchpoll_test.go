@@ -0,0 +1,142 @@
+package stride
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"testing"
+	"time"
+)
+
+func TestWatchPoll(t *testing.T) {
+	dir := t.TempDir()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	events := make(chan string, 100)
+	finished := make(chan error, 1)
+	opts := WatchOptions{Recursive: true, Backend: WatchBackendPoll, PollInterval: 20 * time.Millisecond}
+	go func() {
+		finished <- Watch(ctx, dir, opts, func(ctx context.Context, result WatchResult) error {
+			if result.Error != nil {
+				events <- "error " + result.Error.Error()
+				return nil
+			}
+			events <- string(result.Message.Event) + " " + relSlashPath(dir, result.Message.Path)
+			return nil
+		})
+	}()
+	time.Sleep(100 * time.Millisecond)
+
+	path := filepath.Join(dir, "a.txt")
+	steps := []struct {
+		name     string
+		mutate   func() error
+		expected []string
+	}{
+		{"create", func() error { return os.WriteFile(path, []byte("a"), 0644) }, []string{"create a.txt"}},
+		{"modify", func() error { return appendByte(path) }, []string{"modify a.txt"}},
+		{"chmod", func() error { return os.Chmod(path, 0600) }, []string{"chmod a.txt"}},
+		{"create tree", func() error {
+			if err := os.Mkdir(filepath.Join(dir, "d"), 0755); err != nil {
+				return err
+			}
+			return os.WriteFile(filepath.Join(dir, "d", "b.txt"), []byte("b"), 0644)
+		}, []string{"create d", "create d/b.txt"}},
+		{"delete tree", func() error { return os.RemoveAll(filepath.Join(dir, "d")) }, []string{"delete d/b.txt", "delete d"}},
+		{"delete", func() error { return os.Remove(path) }, []string{"delete a.txt"}},
+	}
+	for _, step := range steps {
+		if step.name == "chmod" && runtime.GOOS == "windows" {
+			continue
+		}
+		if err := step.mutate(); err != nil {
+			t.Fatalf("Failed to %s: %v", step.name, err)
+		}
+		var got []string
+		for len(got) < len(step.expected) {
+			select {
+			case event := <-events:
+				got = append(got, event)
+			case <-time.After(5 * time.Second):
+				t.Fatalf("Expected %v after %s, got %v", step.expected, step.name, got)
+			}
+		}
+		if !reflect.DeepEqual(got, step.expected) {
+			t.Errorf("Expected %v after %s, got %v", step.expected, step.name, got)
+		}
+	}
+
+	// Nothing is reported while nothing changes
+	select {
+	case event := <-events:
+		t.Errorf("Expected no more events, got %s", event)
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	cancel()
+	if err := <-finished; err != nil {
+		t.Errorf("Expected the watch to end without error, got %v", err)
+	}
+}
+
+func TestDiffSnapshots(t *testing.T) {
+	file := pollEntry{size: 1, modTime: 1, mode: 0644}
+	dir := pollEntry{modTime: 1, mode: os.ModeDir | 0755}
+	old := pollSnapshot{
+		newPollKey("r/d"):      dir,
+		newPollKey("r/d/f"):    file,
+		newPollKey("r/link"):   file,
+		newPollKey("r/same"):   file,
+		newPollKey("r/gone/x"): file,
+		newPollKey("r/gone"):   dir,
+	}
+	touched := dir
+	touched.modTime = 2
+	link := file
+	link.mode = os.ModeSymlink | 0777
+	cur := pollSnapshot{
+		newPollKey("r/d"):    touched,
+		newPollKey("r/d/f"):  pollEntry{size: 2, modTime: 1, mode: 0644},
+		newPollKey("r/link"): link,
+		newPollKey("r/same"): file,
+		newPollKey("r/new"):  file,
+	}
+
+	// The touched directory is not reported, and the file replaced by a link
+	// is created anew
+	var got []string
+	for _, event := range diffSnapshots(old, cur) {
+		got = append(got, event.Op.String()+" "+filepath.ToSlash(event.Name))
+	}
+	expected := []string{"WRITE r/d/f", "CREATE r/link", "CREATE r/new", "REMOVE r/gone/x", "REMOVE r/gone"}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("Expected %v, got %v", expected, got)
+	}
+}
+
+func TestDetectRemoteFS(t *testing.T) {
+	tests := []struct {
+		fsType   string
+		err      error
+		expected bool
+	}{
+		{"nfs", nil, true},
+		{"smbfs", nil, true},
+		{"NFS", nil, true},
+		{"ext4", nil, false},
+		{"apfs", nil, false},
+		{"", errors.New("unsupported"), false},
+	}
+	for _, tc := range tests {
+		fakeStatFS(t, func(path string) (FSInfo, error) {
+			return FSInfo{FSType: tc.fsType}, tc.err
+		})
+		if got := DetectRemoteFS(t.TempDir()); got != tc.expected {
+			t.Errorf("Expected %v for %q, got %v", tc.expected, tc.fsType, got)
+		}
+	}
+}
@@ -0,0 +1,117 @@
+package stride
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
+
+	"github.com/fsnotify/fsnotify"
+	"go.uber.org/zap"
+)
+
+// ErrWatchOverflow is reported, wrapped in a WatchResult error, when events
+// were lost: either the platform watcher's buffer overflowed or Watch
+// discarded queued events under SlowConsumerDropOldest. Handlers that keep
+// state derived from events should rescan the tree when they see it.
+var ErrWatchOverflow = errors.New("stride: watch events were lost")
+
+// DefaultWatchQueueSize is the number of events Watch buffers between the
+// platform watcher and the handler when WatchOptions.QueueSize is zero.
+const DefaultWatchQueueSize = 1024
+
+// WatchStats holds watch counters that are updated atomically while Watch
+// runs.
+type WatchStats struct {
+	Events        int64 // Events read from the platform watcher
+	DroppedEvents int64 // Queued events discarded under SlowConsumerDropOldest
+	Overflows     int64 // Overflows reported by the platform watcher
+	Suppressed    int64 // Modify events suppressed by ContentChangeOnly
+	Coalesced     int64 // Events merged into another, or cancelled out, by Debounce
+}
+
+// watchItem is an event or an error read from the platform watcher.
+type watchItem struct {
+	event    fsnotify.Event
+	err      error
+	existing bool // A create event for an entry present at the start
+}
+
+// watchQueue buffers items between the goroutine reading the platform
+// watcher and the one calling the handler, so that a slow handler does not
+// stop the watcher from being read.
+type watchQueue struct {
+	ch     chan watchItem
+	policy SlowConsumerPolicy
+	stats  *WatchStats
+
+	// unreported counts drops not yet reported to the handler
+	unreported int64
+}
+
+// newWatchQueue creates a queue of size items, or DefaultWatchQueueSize
+// if size is not positive.
+func newWatchQueue(size int, policy SlowConsumerPolicy, stats *WatchStats) *watchQueue {
+	if size <= 0 {
+		size = DefaultWatchQueueSize
+	}
+	return &watchQueue{
+		ch:     make(chan watchItem, size),
+		policy: policy,
+		stats:  stats,
+	}
+}
+
+// push queues item according to the queue's policy. It returns false if
+// ctx is done before the item could be queued.
+func (q *watchQueue) push(ctx context.Context, item watchItem) bool {
+	if q.policy == SlowConsumerBlock {
+		select {
+		case q.ch <- item:
+			return true
+		case <-ctx.Done():
+			return false
+		}
+	}
+
+	for {
+		select {
+		case q.ch <- item:
+			return true
+		case <-ctx.Done():
+			return false
+		default:
+		}
+
+		// Queue is full: discard the oldest item and try again
+		select {
+		case <-q.ch:
+			atomic.AddInt64(&q.stats.DroppedEvents, 1)
+			atomic.AddInt64(&q.unreported, 1)
+		default:
+		}
+	}
+}
+
+// takeDropped returns the number of items dropped since the last call.
+func (q *watchQueue) takeDropped() int64 {
+	return atomic.SwapInt64(&q.unreported, 0)
+}
+
+// watcherError wraps an error read from the platform watcher, marking
+// overflows with ErrWatchOverflow.
+func watcherError(err error, stats *WatchStats) error {
+	if errors.Is(err, fsnotify.ErrEventOverflow) {
+		atomic.AddInt64(&stats.Overflows, 1)
+		return fmt.Errorf("watcher error: %w: %w", ErrWatchOverflow, err)
+	}
+	return fmt.Errorf("watcher error: %w", err)
+}
+
+// droppedError reports n events discarded from the queue, logging a warning.
+func droppedError(n int64, logger *zap.Logger) error {
+	logger.Warn("Watch queue full, dropped oldest events",
+		zap.Int64("dropped", n),
+	)
+	return fmt.Errorf("%w: dropped %d queued events", ErrWatchOverflow, n)
+}
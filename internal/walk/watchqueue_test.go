@@ -0,0 +1,120 @@
+package stride
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// slowWatch watches dir with a handler that takes delay per event, creates
+// count files once the watch is running, and returns the created paths seen
+// by the handler and the overflow errors it received once done reports true
+// or timeout passes.
+func slowWatch(t *testing.T, opts WatchOptions, delay time.Duration, count int, timeout time.Duration, done func(seen int) bool) (map[string]bool, int) {
+	t.Helper()
+	dir := t.TempDir()
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	var mu sync.Mutex
+	seen := make(map[string]bool)
+	overflows := 0
+	finished := make(chan struct{})
+	opts.Events = []WatchEvent{EventCreate}
+	go func() {
+		defer close(finished)
+		Watch(ctx, dir, opts, func(ctx context.Context, result WatchResult) error {
+			if result.Error != nil {
+				if errors.Is(result.Error, ErrWatchOverflow) {
+					mu.Lock()
+					overflows++
+					mu.Unlock()
+				}
+				return nil
+			}
+			time.Sleep(delay)
+			mu.Lock()
+			seen[result.Message.Path] = true
+			if done(len(seen)) {
+				cancel()
+			}
+			mu.Unlock()
+			return nil
+		})
+	}()
+	time.Sleep(200 * time.Millisecond)
+
+	for i := 0; i < count; i++ {
+		if err := os.WriteFile(filepath.Join(dir, fmt.Sprintf("%03d.txt", i)), nil, 0644); err != nil {
+			t.Fatalf("Failed to create file: %v", err)
+		}
+	}
+	<-finished
+
+	mu.Lock()
+	defer mu.Unlock()
+	return seen, overflows
+}
+
+func TestWatchQueueDropOldest(t *testing.T) {
+	var stats WatchStats
+	opts := WatchOptions{QueueSize: 1, QueuePolicy: SlowConsumerDropOldest, Stats: &stats}
+
+	// Stop once the queue has had time to drain
+	seen, overflows := slowWatch(t, opts, 20*time.Millisecond, 100, 3*time.Second, func(int) bool {
+		return false
+	})
+
+	if stats.DroppedEvents == 0 {
+		t.Errorf("Expected dropped events, got %+v", stats)
+	}
+	if len(seen) == 100 {
+		t.Error("Expected some creations to be missed")
+	}
+	if overflows == 0 {
+		t.Error("Expected the handler to receive ErrWatchOverflow")
+	}
+}
+
+func TestWatchQueueBlock(t *testing.T) {
+	var stats WatchStats
+	opts := WatchOptions{QueueSize: 2, QueuePolicy: SlowConsumerBlock, Stats: &stats}
+
+	seen, overflows := slowWatch(t, opts, 2*time.Millisecond, 100, 20*time.Second, func(seen int) bool {
+		return seen == 100
+	})
+
+	if len(seen) != 100 {
+		t.Errorf("Expected 100 creations, got %d", len(seen))
+	}
+	if stats.DroppedEvents != 0 || overflows != 0 {
+		t.Errorf("Expected no lost events, got %+v and %d overflows", stats, overflows)
+	}
+	if stats.Events < 100 {
+		t.Errorf("Expected at least 100 events read, got %d", stats.Events)
+	}
+}
+
+func TestWatcherError(t *testing.T) {
+	var stats WatchStats
+
+	err := watcherError(fsnotify.ErrEventOverflow, &stats)
+	if !errors.Is(err, ErrWatchOverflow) || !errors.Is(err, fsnotify.ErrEventOverflow) {
+		t.Errorf("Expected an overflow error, got %v", err)
+	}
+	if stats.Overflows != 1 {
+		t.Errorf("Expected 1 overflow, got %d", stats.Overflows)
+	}
+
+	err = watcherError(errors.New("boom"), &stats)
+	if errors.Is(err, ErrWatchOverflow) || stats.Overflows != 1 {
+		t.Errorf("Expected an ordinary error, got %v", err)
+	}
+}
@@ -0,0 +1,156 @@
+package stride
+
+import (
+	"container/list"
+	"hash/fnv"
+	"io"
+	"os"
+	"time"
+)
+
+// DefaultWatchSnapshotCacheSize is the number of file snapshots kept for
+// WatchOptions.ContentChangeOnly when WatchOptions.SnapshotCacheSize is zero.
+const DefaultWatchSnapshotCacheSize = 4096
+
+// contentSampleSize is the number of leading bytes hashed by
+// WatchOptions.ContentHashCheck.
+const contentSampleSize = 64 * 1024
+
+// Values of the "change" metadata of events delivered under
+// WatchOptions.ContentChangeOnly
+const (
+	ChangeContent  = "content"
+	ChangeMetadata = "metadata"
+)
+
+// fileSnapshot is what the watcher last saw of a file.
+type fileSnapshot struct {
+	size   int64
+	mtime  time.Time
+	hash   uint64
+	hashed bool
+}
+
+// snapshotCache holds the snapshots of the most recently seen files,
+// evicting the least recently seen beyond max.
+type snapshotCache struct {
+	max     int
+	order   *list.List // of *snapshotEntry, most recent first
+	entries map[string]*list.Element
+}
+
+type snapshotEntry struct {
+	path string
+	snap fileSnapshot
+}
+
+// newSnapshotCache creates a cache of max snapshots, or
+// DefaultWatchSnapshotCacheSize if max is not positive.
+func newSnapshotCache(max int) *snapshotCache {
+	if max <= 0 {
+		max = DefaultWatchSnapshotCacheSize
+	}
+	return &snapshotCache{max: max, order: list.New(), entries: make(map[string]*list.Element)}
+}
+
+// get returns the snapshot of path, marking it as recently seen.
+func (c *snapshotCache) get(path string) (fileSnapshot, bool) {
+	e, ok := c.entries[path]
+	if !ok {
+		return fileSnapshot{}, false
+	}
+	c.order.MoveToFront(e)
+	return e.Value.(*snapshotEntry).snap, true
+}
+
+// put records the snapshot of path, evicting the least recently seen
+// snapshot if the cache is full.
+func (c *snapshotCache) put(path string, snap fileSnapshot) {
+	if e, ok := c.entries[path]; ok {
+		e.Value.(*snapshotEntry).snap = snap
+		c.order.MoveToFront(e)
+		return
+	}
+	c.entries[path] = c.order.PushFront(&snapshotEntry{path: path, snap: snap})
+	if c.order.Len() > c.max {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*snapshotEntry).path)
+	}
+}
+
+// remove forgets the snapshot of path.
+func (c *snapshotCache) remove(path string) {
+	if e, ok := c.entries[path]; ok {
+		c.order.Remove(e)
+		delete(c.entries, path)
+	}
+}
+
+// changeClassifier tells content changes from metadata-only changes by
+// comparing each file with its previous snapshot. It is not safe for
+// concurrent use.
+type changeClassifier struct {
+	cache *snapshotCache
+	hash  bool
+}
+
+// newChangeClassifier creates a classifier keeping up to cacheSize
+// snapshots, which also hashes the start of files whose size is unchanged
+// if hash is set.
+func newChangeClassifier(cacheSize int, hash bool) *changeClassifier {
+	return &changeClassifier{cache: newSnapshotCache(cacheSize), hash: hash}
+}
+
+// classify records the snapshot of the file at path, described by info, and
+// returns ChangeContent if its content may have changed since the previous
+// snapshot, or ChangeMetadata if only its metadata did. Files without a
+// previous snapshot count as changed.
+func (c *changeClassifier) classify(path string, info os.FileInfo) string {
+	cur := fileSnapshot{size: info.Size(), mtime: info.ModTime()}
+	prev, ok := c.cache.get(path)
+
+	change := ChangeContent
+	switch {
+	case !ok || prev.size != cur.size:
+	case prev.mtime.Equal(cur.mtime):
+		// Nothing the content depends on has moved
+		cur = prev
+		change = ChangeMetadata
+	case c.hash:
+		cur.hash, cur.hashed = sampleHash(path)
+		if prev.hashed && cur.hashed && prev.hash == cur.hash {
+			change = ChangeMetadata
+		}
+	default:
+		change = ChangeMetadata
+	}
+
+	// Hash new snapshots too, so the next same-size change can be compared
+	if c.hash && change == ChangeContent && !cur.hashed {
+		cur.hash, cur.hashed = sampleHash(path)
+	}
+	c.cache.put(path, cur)
+	return change
+}
+
+// forget drops the snapshot of path, which was deleted or renamed.
+func (c *changeClassifier) forget(path string) {
+	c.cache.remove(path)
+}
+
+// sampleHash hashes the first contentSampleSize bytes of the file at path.
+// It returns false if the file cannot be read.
+func sampleHash(path string) (uint64, bool) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, false
+	}
+	defer f.Close()
+
+	h := fnv.New64a()
+	if _, err := io.CopyN(h, f, contentSampleSize); err != nil && err != io.EOF {
+		return 0, false
+	}
+	return h.Sum64(), true
+}
@@ -0,0 +1,200 @@
+package stride
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestSnapshotCacheEviction(t *testing.T) {
+	c := newSnapshotCache(3)
+	for i := 0; i < 10; i++ {
+		c.put(fmt.Sprintf("f%d", i), fileSnapshot{size: int64(i)})
+		if i == 5 {
+			// Seeing f3 again keeps it over f4 and f5
+			c.get("f3")
+		}
+	}
+	if len(c.entries) != 3 || c.order.Len() != 3 {
+		t.Fatalf("Expected 3 snapshots, got %d", len(c.entries))
+	}
+	for _, path := range []string{"f7", "f8", "f9"} {
+		if _, ok := c.get(path); !ok {
+			t.Errorf("Expected %s to be cached", path)
+		}
+	}
+
+	c.remove("f8")
+	c.remove("missing")
+	if _, ok := c.get("f8"); ok || len(c.entries) != 2 {
+		t.Errorf("Expected f8 to be forgotten, got %d snapshots", len(c.entries))
+	}
+}
+
+func TestChangeClassifier(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "data.bin")
+	if err := os.WriteFile(path, []byte("aaaa"), 0644); err != nil {
+		t.Fatalf("Failed to create file: %v", err)
+	}
+	later := time.Now().Add(time.Hour)
+
+	steps := []struct {
+		name   string
+		change func() error
+		want   string // without and with the content hash
+		hashed string
+	}{
+		{"first sight", func() error { return nil }, ChangeContent, ChangeContent},
+		{"unchanged", func() error { return nil }, ChangeMetadata, ChangeMetadata},
+		{"touch", func() error { return os.Chtimes(path, later, later) }, ChangeMetadata, ChangeMetadata},
+		{"append", func() error { return appendByte(path) }, ChangeContent, ChangeContent},
+		{"same-size rewrite", func() error {
+			if err := os.WriteFile(path, []byte("bbbbb"), 0644); err != nil {
+				return err
+			}
+			later = later.Add(time.Hour)
+			return os.Chtimes(path, later, later)
+		}, ChangeMetadata, ChangeContent},
+	}
+
+	for _, hash := range []bool{false, true} {
+		if err := os.WriteFile(path, []byte("aaaa"), 0644); err != nil {
+			t.Fatalf("Failed to reset file: %v", err)
+		}
+		c := newChangeClassifier(0, hash)
+		for _, step := range steps {
+			if err := step.change(); err != nil {
+				t.Fatalf("%s failed: %v", step.name, err)
+			}
+			info, err := os.Stat(path)
+			if err != nil {
+				t.Fatalf("Failed to stat: %v", err)
+			}
+			want := step.want
+			if hash {
+				want = step.hashed
+			}
+			if got := c.classify(path, info); got != want {
+				t.Errorf("%s (hash %v): expected %s, got %s", step.name, hash, want, got)
+			}
+		}
+
+		// A forgotten file is new again
+		c.forget(path)
+		info, _ := os.Stat(path)
+		if got := c.classify(path, info); got != ChangeContent {
+			t.Errorf("Expected a forgotten file to count as changed, got %s", got)
+		}
+	}
+}
+
+func appendByte(path string) error {
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write([]byte("x")); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func TestWatchContentChangeOnly(t *testing.T) {
+	dir := t.TempDir()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var mu sync.Mutex
+	var events []WatchMessage
+	var stats WatchStats
+	opts := WatchOptions{
+		Events:            []WatchEvent{EventCreate, EventModify},
+		ContentChangeOnly: true,
+		ContentHashCheck:  true,
+		SnapshotCacheSize: 2,
+		Stats:             &stats,
+	}
+	finished := make(chan struct{})
+	go func() {
+		defer close(finished)
+		Watch(ctx, dir, opts, func(ctx context.Context, result WatchResult) error {
+			if result.Error == nil {
+				mu.Lock()
+				events = append(events, result.Message)
+				mu.Unlock()
+			}
+			return nil
+		})
+	}()
+	time.Sleep(200 * time.Millisecond)
+
+	// modifies returns the modify events received since the last call
+	seen := 0
+	modifies := func() []WatchMessage {
+		time.Sleep(300 * time.Millisecond)
+		mu.Lock()
+		defer mu.Unlock()
+		var got []WatchMessage
+		for _, msg := range events[seen:] {
+			if msg.Event == EventModify {
+				got = append(got, msg)
+			}
+		}
+		seen = len(events)
+		return got
+	}
+
+	// More files than snapshots, so that the cache evicts
+	path := filepath.Join(dir, "watched.txt")
+	for i := 0; i < 5; i++ {
+		if err := os.WriteFile(filepath.Join(dir, fmt.Sprintf("other%d.txt", i)), []byte("x"), 0644); err != nil {
+			t.Fatalf("Failed to create file: %v", err)
+		}
+	}
+	if err := os.WriteFile(path, []byte("hello"), 0644); err != nil {
+		t.Fatalf("Failed to create file: %v", err)
+	}
+	modifies()
+
+	// Touching the file, or rewriting it with the same bytes, is suppressed
+	later := time.Now().Add(time.Hour)
+	if err := os.Chtimes(path, later, later); err != nil {
+		t.Fatalf("Failed to touch file: %v", err)
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatalf("Failed to open file: %v", err)
+	}
+	if _, err := f.WriteAt([]byte("hello"), 0); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+	f.Close()
+	if got := modifies(); len(got) != 0 {
+		t.Errorf("Expected the touch to be suppressed, got %+v", got)
+	}
+
+	// Appending a byte is delivered
+	if err := appendByte(path); err != nil {
+		t.Fatalf("Failed to append: %v", err)
+	}
+	got := modifies()
+	if len(got) == 0 {
+		t.Fatal("Expected the append to be delivered")
+	}
+	for _, msg := range got {
+		if msg.Path != path || msg.Metadata["change"] != ChangeContent {
+			t.Errorf("Expected a content change of %s, got %+v", path, msg)
+		}
+	}
+
+	cancel()
+	<-finished
+	if stats.Suppressed == 0 {
+		t.Errorf("Expected suppressed events, got %+v", stats)
+	}
+}
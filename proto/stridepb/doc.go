@@ -0,0 +1,6 @@
+// Package stridepb holds the Go code generated from the protobuf schema of
+// the entries stride streams to other processes, proto/entry.proto. See
+// walk.NewProtoStreamWriter and walk.ReadProtoStream.
+package stridepb
+
+//go:generate protoc -I .. --go_out=. --go_opt=paths=source_relative ../entry.proto
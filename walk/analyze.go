@@ -0,0 +1,93 @@
+package walk
+
+import (
+	"io"
+
+	internal "github.com/TFMV/stride/internal/walk"
+)
+
+type (
+	// Analyzer analyzes a filesystem tree; see NewAnalyzer.
+	Analyzer = internal.Analyzer
+
+	// AnalyzeResult holds the results of a filesystem analysis.
+	AnalyzeResult = internal.AnalyzeResult
+
+	// AnalyzeMeta describes the analysis an analyzer report holds.
+	AnalyzeMeta = internal.AnalyzeMeta
+
+	// Analysis result parts
+	DuplicateSet     = internal.DuplicateSet
+	LanguageStats    = internal.LanguageStats
+	StorageReport    = internal.StorageReport
+	TypeStats        = internal.TypeStats
+	DirStat          = internal.DirStat
+	ContentPattern   = internal.ContentPattern
+	AdvancedAnalysis = internal.AdvancedAnalysis
+	DuplicateGroup   = internal.DuplicateGroup
+	CodebaseGraph    = internal.CodebaseGraph
+	DependencyInfo   = internal.DependencyInfo
+	FileInfo         = internal.FileInfo
+	AgeBucket        = internal.AgeBucket
+	AgeBuckets       = internal.AgeBuckets
+
+	// AnalyzeProgress is a snapshot of a running analysis, passed to the
+	// callback set with Analyzer.SetProgressCallback.
+	AnalyzeProgress = internal.AnalyzeProgress
+
+	// AnalyzePhase identifies a stage of Analyzer.Analyze.
+	AnalyzePhase = internal.AnalyzePhase
+
+	// DedupStrategy selects how Analyzer.PerformDeduplicationWithStrategy
+	// replaces duplicates, and DedupReport describes what it did.
+	DedupStrategy = internal.DedupStrategy
+	DedupReport   = internal.DedupReport
+)
+
+// Phases of Analyzer.Analyze
+const (
+	PhaseWalking        = internal.PhaseWalking
+	PhaseHashing        = internal.PhaseHashing
+	PhaseNearDuplicates = internal.PhaseNearDuplicates
+	PhaseDependencies   = internal.PhaseDependencies
+)
+
+// Deduplication strategies
+const (
+	DedupSymlink  = internal.DedupSymlink
+	DedupHardlink = internal.DedupHardlink
+	DedupReflink  = internal.DedupReflink
+)
+
+// Analyzer defaults
+const (
+	DefaultMaxAnalyzedFileSize = internal.DefaultMaxAnalyzedFileSize
+	DefaultDirStatsDepth       = internal.DefaultDirStatsDepth
+	DefaultDirStatsTopK        = internal.DefaultDirStatsTopK
+)
+
+// ErrReflinkUnsupported is returned by DedupReflink deduplication where the
+// platform or filesystem cannot clone files.
+var ErrReflinkUnsupported = internal.ErrReflinkUnsupported
+
+// AnalyzeSchemaVersion is the schema version of analyzer reports written by
+// WriteAnalyzeReport.
+const AnalyzeSchemaVersion = internal.AnalyzeSchemaVersion
+
+// NewAnalyzer creates an analyzer; enable the analyses wanted, such as with
+// EnableDuplicateDetection, before calling Analyze.
+func NewAnalyzer() *Analyzer {
+	return internal.NewAnalyzer()
+}
+
+// WriteAnalyzeReport writes result to w as a JSON analyzer report.
+func WriteAnalyzeReport(w io.Writer, meta AnalyzeMeta, result *AnalyzeResult) error {
+	return internal.WriteAnalyzeReport(w, meta, result)
+}
+
+// ParseAnalyzeReport reads an analyzer report, such as the output of
+// stride analyze --output=json, rejecting reports with a newer schema
+// version.
+func ParseAnalyzeReport(r io.Reader) (*AnalyzeResult, AnalyzeMeta, error) {
+	return internal.ParseAnalyzeReport(r)
+}
@@ -35,5 +35,8 @@
 //
 //	// Format output for each event
 //	err := walk.WatchWithFormat(context.Background(), "/path/to/watch", opts, "{event}: {base} at {time}")
+//
+//	// Stream events as newline-delimited JSON
+//	err := walk.WatchWithJSON(context.Background(), "/path/to/watch", opts, os.Stdout)
 
 package walk
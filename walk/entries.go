@@ -0,0 +1,28 @@
+package walk
+
+import (
+	"context"
+	"iter"
+
+	internal "github.com/TFMV/stride/internal/walk"
+)
+
+// Entry is a file or directory produced by Entries.
+type Entry = internal.Entry
+
+// Entries returns an iterator over the tree rooted at root, walked as by
+// WalkLimitWithOptions with opts. Breaking out of a range loop over it
+// cancels the walk:
+//
+//	for entry, err := range walk.Entries(ctx, "/data", opts) {
+//		if err != nil {
+//			return err
+//		}
+//		if entry.Info.Size() > 1<<30 {
+//			fmt.Println("found", entry.RelPath)
+//			break
+//		}
+//	}
+func Entries(ctx context.Context, root string, opts WalkOptions) iter.Seq2[Entry, error] {
+	return internal.Entries(ctx, root, opts)
+}
@@ -0,0 +1,69 @@
+package walk_test
+
+import (
+	"context"
+	"io"
+	"regexp"
+
+	internal "github.com/TFMV/stride/internal/walk"
+	"github.com/TFMV/stride/walk"
+)
+
+// The Find and Analyzer APIs are aliases of the internal ones, so values pass
+// between the packages without conversion. This file only has to compile.
+
+// Find
+var (
+	_ internal.FindOptions = walk.FindOptions{}
+	_ internal.FindMessage = walk.FindMessage{}
+	_ internal.FindResult  = walk.FindResult{}
+	_ internal.FindHandler = walk.FindHandler(nil)
+
+	_ func(context.Context, string, walk.FindOptions, walk.FindHandler) error = walk.Find
+	_ func(context.Context, string, walk.FindOptions, string) error           = walk.FindWithExec
+	_ func(context.Context, string, walk.FindOptions) error                   = walk.FindWithExecRoutes
+	_ func(context.Context, string, walk.FindOptions, string) error           = walk.FindWithFormat
+	_ func(context.Context, string, walk.FindOptions, io.Writer) error        = walk.FindWithJSON
+	_ func(map[string]string) (map[string]*regexp.Regexp, error)              = walk.CompileRegexMap
+	_ func() walk.FindOptions                                                 = walk.NewFindOptions
+)
+
+// Analyzer
+var (
+	_ *internal.Analyzer        = walk.NewAnalyzer()
+	_ *internal.AnalyzeResult   = (*walk.AnalyzeResult)(nil)
+	_ internal.AnalyzeMeta      = walk.AnalyzeMeta{}
+	_ internal.DuplicateSet     = walk.DuplicateSet{}
+	_ internal.LanguageStats    = walk.LanguageStats{}
+	_ internal.StorageReport    = walk.StorageReport{}
+	_ internal.TypeStats        = walk.TypeStats{}
+	_ internal.FileInfo         = walk.FileInfo{}
+	_ internal.DirStat          = walk.DirStat{}
+	_ internal.AgeBucket        = walk.AgeBucket{}
+	_ internal.AgeBuckets       = walk.AgeBuckets{}
+	_ internal.SecurityIssue    = walk.SecurityIssue{}
+	_ internal.ContentPattern   = walk.ContentPattern{}
+	_ internal.AdvancedAnalysis = walk.AdvancedAnalysis{}
+	_ internal.DuplicateGroup   = walk.DuplicateGroup{}
+	_ internal.CodebaseGraph    = walk.CodebaseGraph{}
+	_ internal.DependencyInfo   = walk.DependencyInfo{}
+	_ internal.AnalyzeProgress  = walk.AnalyzeProgress{}
+	_ internal.DedupReport      = walk.DedupReport{}
+
+	_ internal.AnalyzePhase  = walk.PhaseWalking
+	_ internal.AnalyzePhase  = walk.PhaseHashing
+	_ internal.AnalyzePhase  = walk.PhaseNearDuplicates
+	_ internal.AnalyzePhase  = walk.PhaseDependencies
+	_ internal.DedupStrategy = walk.DedupSymlink
+	_ internal.DedupStrategy = walk.DedupHardlink
+	_ internal.DedupStrategy = walk.DedupReflink
+
+	_ int64  = walk.DefaultMaxAnalyzedFileSize
+	_ int    = walk.DefaultDirStatsDepth
+	_ int    = walk.DefaultDirStatsTopK
+	_ string = walk.AnalyzeSchemaVersion
+	_ error  = walk.ErrReflinkUnsupported
+
+	_ func(io.Writer, walk.AnalyzeMeta, *walk.AnalyzeResult) error   = walk.WriteAnalyzeReport
+	_ func(io.Reader) (*walk.AnalyzeResult, walk.AnalyzeMeta, error) = walk.ParseAnalyzeReport
+)
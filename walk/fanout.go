@@ -0,0 +1,16 @@
+package walk
+
+import (
+	"context"
+
+	internal "github.com/TFMV/stride/internal/walk"
+)
+
+// DirFanoutInfo holds the number of direct entries of a directory.
+type DirFanoutInfo = internal.DirFanoutInfo
+
+// DirFanout walks the tree rooted at root and returns the directories with
+// more than threshold direct entries, those with the most entries first.
+func DirFanout(ctx context.Context, root string, threshold int, opts WalkOptions) ([]DirFanoutInfo, error) {
+	return internal.DirFanout(ctx, root, threshold, opts)
+}
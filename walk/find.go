@@ -11,155 +11,79 @@ package walk
 
 import (
 	"context"
+	"io"
 	"regexp"
-	"time"
 
 	internal "github.com/TFMV/stride/internal/walk"
 )
 
-// FindMessage holds information about a file found during traversal
-type FindMessage struct {
-	Path      string            // Full path to the file
-	Name      string            // Base name of the file
-	Dir       string            // Directory containing the file
-	Size      int64             // Size in bytes
-	Time      time.Time         // Modification time
-	IsDir     bool              // Whether the entry is a directory
-	Metadata  map[string]string // File metadata
-	Tags      map[string]string // File tags
-	VersionID string            // Version identifier (if applicable)
-}
+// HashListMode defines how files found in a hash list are treated.
+type HashListMode = internal.HashListMode
 
-// FindOptions defines the criteria for finding files
-type FindOptions struct {
-	// Pattern matching options
-	NamePattern   string         // Match by file name (supports wildcards)
-	PathPattern   string         // Match by path (supports wildcards)
-	IgnorePattern string         // Skip paths matching this pattern
-	RegexPattern  *regexp.Regexp // Match by regular expression
-
-	// Time-based filtering
-	OlderThan time.Duration // Files older than this duration
-	NewerThan time.Duration // Files newer than this duration
-
-	// Size-based filtering
-	LargerSize  int64 // Files larger than this size (bytes)
-	SmallerSize int64 // Files smaller than this size (bytes)
-
-	// Metadata and tag filtering
-	MatchMeta map[string]*regexp.Regexp // Metadata key-value patterns to match
-	MatchTags map[string]*regexp.Regexp // Tag key-value patterns to match
-
-	// Execution options
-	ExecCmd     string // Command to execute for each match
-	PrintFormat string // Format string for output
-
-	// Traversal options
-	MaxDepth       uint // Maximum directory depth to traverse
-	FollowSymlinks bool // Whether to follow symbolic links
-	IncludeHidden  bool // Whether to include hidden files
-	WithVersions   bool // Whether to include file versions
-
-	// Watch options
-	Watch       bool     // Whether to watch for changes
-	WatchEvents []string // Events to watch for (create, modify, delete)
-}
+// Hash list modes
+const (
+	HashListMatch   = internal.HashListMatch
+	HashListExclude = internal.HashListExclude
+)
 
-// FindResult represents a file that matched the find criteria
-type FindResult struct {
-	Message FindMessage
-	Error   error
-}
+// DefaultContentMaxLines is the number of matching lines kept in
+// FindMessage.MatchedLines by default.
+const DefaultContentMaxLines = internal.DefaultContentMaxLines
 
-// FindHandler is a function that processes each found file
-type FindHandler func(ctx context.Context, result FindResult) error
-
-// convertToInternalFindMessage converts a public FindMessage to an internal one
-func convertToInternalFindMessage(msg FindMessage) internal.FindMessage {
-	return internal.FindMessage{
-		Path:      msg.Path,
-		Name:      msg.Name,
-		Dir:       msg.Dir,
-		Size:      msg.Size,
-		Time:      msg.Time,
-		IsDir:     msg.IsDir,
-		Metadata:  msg.Metadata,
-		Tags:      msg.Tags,
-		VersionID: msg.VersionID,
-	}
-}
+// ExecRoute sends the matches of FindWithExecRoutes whose base name
+// matches a glob to a command.
+type ExecRoute = internal.ExecRoute
 
-// convertFromInternalFindMessage converts an internal FindMessage to a public one
-func convertFromInternalFindMessage(msg internal.FindMessage) FindMessage {
-	return FindMessage{
-		Path:      msg.Path,
-		Name:      msg.Name,
-		Dir:       msg.Dir,
-		Size:      msg.Size,
-		Time:      msg.Time,
-		IsDir:     msg.IsDir,
-		Metadata:  msg.Metadata,
-		Tags:      msg.Tags,
-		VersionID: msg.VersionID,
-	}
-}
+// RetentionPolicy describes which files matching a pattern should be kept.
+type RetentionPolicy = internal.RetentionPolicy
 
-// convertToInternalFindOptions converts public FindOptions to internal ones
-func convertToInternalFindOptions(opts FindOptions) internal.FindOptions {
-	return internal.FindOptions{
-		NamePattern:    opts.NamePattern,
-		PathPattern:    opts.PathPattern,
-		IgnorePattern:  opts.IgnorePattern,
-		RegexPattern:   opts.RegexPattern,
-		OlderThan:      opts.OlderThan,
-		NewerThan:      opts.NewerThan,
-		LargerSize:     opts.LargerSize,
-		SmallerSize:    opts.SmallerSize,
-		MatchMeta:      opts.MatchMeta,
-		MatchTags:      opts.MatchTags,
-		ExecCmd:        opts.ExecCmd,
-		PrintFormat:    opts.PrintFormat,
-		MaxDepth:       opts.MaxDepth,
-		FollowSymlinks: opts.FollowSymlinks,
-		IncludeHidden:  opts.IncludeHidden,
-		WithVersions:   opts.WithVersions,
-		Watch:          opts.Watch,
-		WatchEvents:    opts.WatchEvents,
-	}
-}
+// RetentionAction is invoked for each file a retention policy does not keep.
+type RetentionAction = internal.RetentionAction
 
-// convertToInternalFindHandler converts a public FindHandler to an internal one
-func convertToInternalFindHandler(handler FindHandler) internal.FindHandler {
-	if handler == nil {
-		return nil
-	}
+type (
+	// FindMessage holds information about a file found during traversal.
+	FindMessage = internal.FindMessage
 
-	return func(ctx context.Context, result internal.FindResult) error {
-		return handler(ctx, FindResult{
-			Message: convertFromInternalFindMessage(result.Message),
-			Error:   result.Error,
-		})
-	}
-}
+	// FindOptions defines the criteria for finding files.
+	FindOptions = internal.FindOptions
+
+	// FindResult represents a file that matched the find criteria.
+	FindResult = internal.FindResult
+
+	// FindHandler is a function that processes each found file.
+	FindHandler = internal.FindHandler
+)
 
 // Find searches for files matching the given criteria and processes them with the handler
 func Find(ctx context.Context, root string, opts FindOptions, handler FindHandler) error {
-	internalOpts := convertToInternalFindOptions(opts)
-	internalHandler := convertToInternalFindHandler(handler)
-
-	return internal.Find(ctx, root, internalOpts, internalHandler)
+	return internal.Find(ctx, root, opts, handler)
 }
 
 // FindWithExec searches for files and executes a command for each match
 func FindWithExec(ctx context.Context, root string, opts FindOptions, cmdTemplate string) error {
-	internalOpts := convertToInternalFindOptions(opts)
-	return internal.FindWithExec(ctx, root, internalOpts, cmdTemplate)
+	return internal.FindWithExec(ctx, root, opts, cmdTemplate)
+}
+
+// FindWithExecRoutes searches for files once and executes for each match
+// the command of the first route matching it, or opts.DefaultCmd
+func FindWithExecRoutes(ctx context.Context, root string, opts FindOptions) error {
+	return internal.FindWithExecRoutes(ctx, root, opts)
 }
 
 // FindWithFormat searches for files and formats output according to a template
 func FindWithFormat(ctx context.Context, root string, opts FindOptions, formatTemplate string) error {
-	internalOpts := convertToInternalFindOptions(opts)
-	return internal.FindWithFormat(ctx, root, internalOpts, formatTemplate)
+	return internal.FindWithFormat(ctx, root, opts, formatTemplate)
+}
+
+// FindWithJSON searches for files and writes each match to w as a line of
+// JSON
+func FindWithJSON(ctx context.Context, root string, opts FindOptions, w io.Writer) error {
+	return internal.FindWithJSON(ctx, root, opts, w)
+}
+
+// ApplyRetention calls action for every file under root that policy does not keep
+func ApplyRetention(ctx context.Context, root string, policy RetentionPolicy, action RetentionAction) error {
+	return internal.ApplyRetention(ctx, root, policy, action)
 }
 
 // CompileRegexMap compiles a map of key-value regex patterns
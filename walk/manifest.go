@@ -0,0 +1,61 @@
+package walk
+
+import (
+	"context"
+	"io"
+
+	internal "github.com/TFMV/stride/internal/walk"
+)
+
+type (
+	// ManifestOptions configures WriteManifest and VerifyManifest.
+	ManifestOptions = internal.ManifestOptions
+
+	// ManifestEntry is one line of a manifest.
+	ManifestEntry = internal.ManifestEntry
+
+	// ManifestType is the kind of entry recorded in a manifest.
+	ManifestType = internal.ManifestType
+
+	// ManifestStatus classifies an entry when verifying a manifest.
+	ManifestStatus = internal.ManifestStatus
+
+	// ManifestChange is an entry of the tree that does not match its manifest.
+	ManifestChange = internal.ManifestChange
+
+	// VerifyReport is the result of VerifyManifest.
+	VerifyReport = internal.VerifyReport
+)
+
+// Manifest entry types
+const (
+	ManifestFile  = internal.ManifestFile
+	ManifestDir   = internal.ManifestDir
+	ManifestLink  = internal.ManifestLink
+	ManifestOther = internal.ManifestOther
+)
+
+// Manifest verification statuses
+const (
+	ManifestOK       = internal.ManifestOK
+	ManifestModified = internal.ManifestModified
+	ManifestMissing  = internal.ManifestMissing
+	ManifestExtra    = internal.ManifestExtra
+)
+
+// WriteManifest writes a sorted manifest of every entry beneath root to w,
+// with the size, mode, modification time and SHA-256 digest of each file.
+func WriteManifest(ctx context.Context, root string, w io.Writer, opts ManifestOptions) error {
+	return internal.WriteManifest(ctx, root, w, opts)
+}
+
+// VerifyManifest compares root with a manifest read from r and reports the
+// entries that were modified, removed or added.
+func VerifyManifest(ctx context.Context, root string, r io.Reader, opts ManifestOptions) (VerifyReport, error) {
+	return internal.VerifyManifest(ctx, root, r, opts)
+}
+
+// ReadManifest parses a manifest written by WriteManifest.
+func ReadManifest(r io.Reader) ([]ManifestEntry, error) {
+	return internal.ReadManifest(r)
+}
@@ -0,0 +1,105 @@
+package walk
+
+import (
+	"context"
+	"os"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// LogConfig configures LoggingMiddleware.
+type LogConfig struct {
+	IncludeDirs bool          // Log directories as well as files
+	Level       zapcore.Level // Level of the entry logged for each path; the zero value is zap.InfoLevel
+}
+
+// LoggingMiddleware creates a middleware that logs each file before passing
+// it on, and directories too if cfg.IncludeDirs is set. Errors returned by
+// the rest of the chain are logged at error level for every entry, logged or
+// not. A nil logger logs nothing.
+func LoggingMiddleware(logger *zap.Logger, cfg LogConfig) MiddlewareFunc {
+	if logger == nil {
+		logger = zap.NewNop()
+	}
+	return func(next WalkFunc) WalkFunc {
+		return func(ctx context.Context, path string, info os.FileInfo) error {
+			if cfg.IncludeDirs || !info.IsDir() {
+				if ce := logger.Check(cfg.Level, "Processing file"); ce != nil {
+					ce.Write(
+						zap.String("path", path),
+						zap.Int64("size", info.Size()),
+						zap.Time("modified", info.ModTime()),
+						zap.Bool("dir", info.IsDir()),
+					)
+				}
+			}
+			err := next(ctx, path, info)
+			if err != nil {
+				logger.Error("Error processing file",
+					zap.String("path", path),
+					zap.Error(err),
+				)
+			}
+			return err
+		}
+	}
+}
+
+// TimingConfig configures TimingMiddleware.
+type TimingConfig struct {
+	Threshold time.Duration                      // Files taking longer than this are slow
+	OnSlow    func(path string, d time.Duration) // Called with each slow file and the time it took
+}
+
+// TimingMiddleware creates a middleware that times the rest of the chain for
+// each file and calls cfg.OnSlow for the files that take longer than
+// cfg.Threshold. Directories are passed on untimed, and without OnSlow the
+// middleware passes everything on untimed. OnSlow may be called from several
+// goroutines at once.
+func TimingMiddleware(cfg TimingConfig) MiddlewareFunc {
+	return func(next WalkFunc) WalkFunc {
+		if cfg.OnSlow == nil {
+			return next
+		}
+		return func(ctx context.Context, path string, info os.FileInfo) error {
+			if info.IsDir() {
+				return next(ctx, path, info)
+			}
+			start := time.Now()
+			err := next(ctx, path, info)
+			if d := time.Since(start); d > cfg.Threshold {
+				cfg.OnSlow(path, d)
+			}
+			return err
+		}
+	}
+}
+
+// MetricsMiddleware creates a middleware that reports, for each file, the
+// seconds the rest of the chain took to histogram and a count of 1 to
+// counter, whatever error it returned, so that any metrics library can be
+// fed. Directories are passed on unmeasured. Either function may be nil, and
+// both may be called from several goroutines at once.
+func MetricsMiddleware(histogram func(seconds float64), counter func(delta int64)) MiddlewareFunc {
+	return func(next WalkFunc) WalkFunc {
+		if histogram == nil && counter == nil {
+			return next
+		}
+		return func(ctx context.Context, path string, info os.FileInfo) error {
+			if info.IsDir() {
+				return next(ctx, path, info)
+			}
+			start := time.Now()
+			err := next(ctx, path, info)
+			if histogram != nil {
+				histogram(time.Since(start).Seconds())
+			}
+			if counter != nil {
+				counter(1)
+			}
+			return err
+		}
+	}
+}
@@ -0,0 +1,232 @@
+package walk
+
+import (
+	"context"
+	"errors"
+	"os"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+// fakeInfo is the os.FileInfo of an entry that does not exist.
+type fakeInfo struct {
+	name string
+	dir  bool
+}
+
+func (f fakeInfo) Name() string       { return f.name }
+func (f fakeInfo) Size() int64        { return 42 }
+func (f fakeInfo) Mode() os.FileMode  { return 0644 }
+func (f fakeInfo) ModTime() time.Time { return time.Time{} }
+func (f fakeInfo) IsDir() bool        { return f.dir }
+func (f fakeInfo) Sys() interface{}   { return nil }
+
+var (
+	fileInfo = fakeInfo{name: "a.txt"}
+	dirInfo  = fakeInfo{name: "dir", dir: true}
+)
+
+// chain applies middleware to next the way WalkWithOptions does, the first
+// in the list outermost.
+func chain(next WalkFunc, middleware ...MiddlewareFunc) WalkFunc {
+	for i := len(middleware) - 1; i >= 0; i-- {
+		next = middleware[i](next)
+	}
+	return next
+}
+
+// fakeNext returns a WalkFunc that records its calls, sleeps for delay and
+// returns err.
+func fakeNext(calls *[]string, delay time.Duration, err error) WalkFunc {
+	return func(ctx context.Context, path string, info os.FileInfo) error {
+		*calls = append(*calls, "next "+path)
+		time.Sleep(delay)
+		return err
+	}
+}
+
+func TestLoggingMiddleware(t *testing.T) {
+	failure := errors.New("failure")
+	tests := []struct {
+		name     string
+		cfg      LogConfig
+		info     os.FileInfo
+		err      error
+		expected []string // Messages logged, with their level
+	}{
+		{"File", LogConfig{}, fileInfo, nil, []string{"info Processing file"}},
+		{"Debug level", LogConfig{Level: zap.DebugLevel}, fileInfo, nil, []string{"debug Processing file"}},
+		{"Directory skipped", LogConfig{}, dirInfo, nil, nil},
+		{"Directory included", LogConfig{IncludeDirs: true}, dirInfo, nil, []string{"info Processing file"}},
+		{"Error", LogConfig{}, fileInfo, failure, []string{"info Processing file", "error Error processing file"}},
+		{"Directory error", LogConfig{}, dirInfo, failure, []string{"error Error processing file"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			core, logs := observer.New(zap.DebugLevel)
+			var calls []string
+			fn := LoggingMiddleware(zap.New(core), tt.cfg)(fakeNext(&calls, 0, tt.err))
+			if err := fn(context.Background(), "/p", tt.info); err != tt.err {
+				t.Errorf("Expected error %v, got %v", tt.err, err)
+			}
+			if len(calls) != 1 {
+				t.Errorf("Expected next to be called once, got %q", calls)
+			}
+
+			var got []string
+			for _, entry := range logs.All() {
+				got = append(got, entry.Level.String()+" "+entry.Message)
+				if entry.ContextMap()["path"] != "/p" {
+					t.Errorf("Expected the path to be logged, got %v", entry.ContextMap())
+				}
+			}
+			if !reflect.DeepEqual(got, tt.expected) {
+				t.Errorf("Expected %q, got %q", tt.expected, got)
+			}
+		})
+	}
+
+	// A nil logger logs nothing
+	var calls []string
+	if err := LoggingMiddleware(nil, LogConfig{})(fakeNext(&calls, 0, nil))(context.Background(), "/p", fileInfo); err != nil || len(calls) != 1 {
+		t.Errorf("Expected a nil logger to pass the call on, got %v and %q", err, calls)
+	}
+}
+
+func TestTimingMiddleware(t *testing.T) {
+	tests := []struct {
+		name  string
+		info  os.FileInfo
+		delay time.Duration
+		slow  bool
+	}{
+		{"Fast file", fileInfo, 0, false},
+		{"Slow file", fileInfo, 30 * time.Millisecond, true},
+		{"Slow directory", dirInfo, 30 * time.Millisecond, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var calls []string
+			var slow []time.Duration
+			fn := TimingMiddleware(TimingConfig{
+				Threshold: 20 * time.Millisecond,
+				OnSlow: func(path string, d time.Duration) {
+					calls = append(calls, "slow "+path)
+					slow = append(slow, d)
+				},
+			})(fakeNext(&calls, tt.delay, nil))
+			if err := fn(context.Background(), "/p", tt.info); err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+
+			expected := []string{"next /p"}
+			if tt.slow {
+				expected = append(expected, "slow /p")
+			}
+			if !reflect.DeepEqual(calls, expected) {
+				t.Errorf("Expected %q, got %q", expected, calls)
+			}
+			if tt.slow && slow[0] < tt.delay {
+				t.Errorf("Expected a duration of at least %v, got %v", tt.delay, slow[0])
+			}
+		})
+	}
+
+	// Without OnSlow, next is returned as is
+	var calls []string
+	if err := TimingMiddleware(TimingConfig{})(fakeNext(&calls, 0, nil))(context.Background(), "/p", fileInfo); err != nil || len(calls) != 1 {
+		t.Errorf("Expected the call to be passed on, got %v and %q", err, calls)
+	}
+}
+
+func TestMetricsMiddleware(t *testing.T) {
+	var mu sync.Mutex
+	var observed []float64
+	var count int64
+	histogram := func(seconds float64) {
+		mu.Lock()
+		observed = append(observed, seconds)
+		mu.Unlock()
+	}
+	counter := func(delta int64) {
+		mu.Lock()
+		count += delta
+		mu.Unlock()
+	}
+
+	failure := errors.New("failure")
+	var calls []string
+	fn := MetricsMiddleware(histogram, counter)(fakeNext(&calls, 10*time.Millisecond, nil))
+	for _, info := range []os.FileInfo{fileInfo, dirInfo, fileInfo} {
+		if err := fn(context.Background(), "/p", info); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	}
+	fn = MetricsMiddleware(histogram, counter)(fakeNext(&calls, 0, failure))
+	if err := fn(context.Background(), "/p", fileInfo); err != failure {
+		t.Errorf("Expected the error of next, got %v", err)
+	}
+
+	// Files are measured, failed or not; directories are not
+	if len(calls) != 4 {
+		t.Errorf("Expected next to be called for every entry, got %q", calls)
+	}
+	if count != 3 || len(observed) != 3 {
+		t.Fatalf("Expected 3 files counted and observed, got %d and %v", count, observed)
+	}
+	if observed[0] < 0.01 || observed[1] < 0.01 {
+		t.Errorf("Expected latencies of at least 10ms, got %v", observed)
+	}
+
+	// Either function may be nil
+	fn = MetricsMiddleware(nil, counter)(fakeNext(&calls, 0, nil))
+	if err := fn(context.Background(), "/p", fileInfo); err != nil || count != 4 {
+		t.Errorf("Expected the file to be counted without a histogram, got %v and %d", err, count)
+	}
+	fn = MetricsMiddleware(nil, nil)(fakeNext(&calls, 0, nil))
+	if err := fn(context.Background(), "/p", fileInfo); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+}
+
+func TestMiddlewareOrder(t *testing.T) {
+	core, logs := observer.New(zap.DebugLevel)
+	var calls []string
+	record := func(name string) MiddlewareFunc {
+		return func(next WalkFunc) WalkFunc {
+			return func(ctx context.Context, path string, info os.FileInfo) error {
+				calls = append(calls, name)
+				return next(ctx, path, info)
+			}
+		}
+	}
+
+	fn := chain(fakeNext(&calls, 25*time.Millisecond, nil),
+		record("first"),
+		LoggingMiddleware(zap.New(core), LogConfig{}),
+		record("second"),
+		TimingMiddleware(TimingConfig{
+			Threshold: 10 * time.Millisecond,
+			OnSlow:    func(path string, d time.Duration) { calls = append(calls, "slow "+path) },
+		}),
+		MetricsMiddleware(nil, func(delta int64) { calls = append(calls, "counted") }),
+	)
+	if err := fn(context.Background(), "/p", fileInfo); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	expected := []string{"first", "second", "next /p", "counted", "slow /p"}
+	if !reflect.DeepEqual(calls, expected) {
+		t.Errorf("Expected %q, got %q", expected, calls)
+	}
+	if logs.Len() != 1 {
+		t.Errorf("Expected one log entry, got %d", logs.Len())
+	}
+}
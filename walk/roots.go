@@ -0,0 +1,27 @@
+package walk
+
+import (
+	"context"
+	"os"
+
+	internal "github.com/TFMV/stride/internal/walk"
+)
+
+// WalkRoots walks several roots as one walk. Overlapping roots are walked
+// once, up to opts.RootParallelism roots are walked at once, and their
+// callbacks share opts.NumWorkers.
+func WalkRoots(ctx context.Context, roots []string, walkFn func(path string, info os.FileInfo, err error) error, opts WalkOptions) error {
+	return internal.WalkRoots(ctx, roots, walkFn, opts)
+}
+
+// WalkRootsStats is like WalkRoots but also returns the combined statistics
+// of the roots.
+func WalkRootsStats(ctx context.Context, roots []string, walkFn func(path string, info os.FileInfo, err error) error, opts WalkOptions) (Stats, error) {
+	return internal.WalkRootsStats(ctx, roots, walkFn, opts)
+}
+
+// CanonicalRoots returns the distinct roots of roots in a canonical order,
+// dropping those beneath another root and reporting them to collapsed.
+func CanonicalRoots(roots []string, collapsed func(inner, outer string)) ([]string, error) {
+	return internal.CanonicalRoots(roots, collapsed)
+}
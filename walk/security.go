@@ -0,0 +1,29 @@
+package walk
+
+import (
+	"context"
+
+	internal "github.com/TFMV/stride/internal/walk"
+)
+
+type (
+	// SecurityIssue is a security concern found in a file.
+	SecurityIssue = internal.SecurityIssue
+
+	// SecurityScanOptions configures SecurityScan.
+	SecurityScanOptions = internal.SecurityScanOptions
+)
+
+// Security rules applied by SecurityScan
+const (
+	RuleWorldWritable       = internal.RuleWorldWritable
+	RuleSetuid              = internal.RuleSetuid
+	RuleSetgid              = internal.RuleSetgid
+	RuleSuspiciousExtension = internal.RuleSuspiciousExtension
+)
+
+// SecurityScan applies the security rules to the files beneath root,
+// passing each finding to onFinding as soon as it is found.
+func SecurityScan(ctx context.Context, root string, opts SecurityScanOptions, onFinding func(SecurityIssue) error) error {
+	return internal.SecurityScan(ctx, root, opts, onFinding)
+}
@@ -0,0 +1,64 @@
+package walk
+
+import (
+	internal "github.com/TFMV/stride/internal/walk"
+)
+
+// TemplateOptions configures ParseTemplateWithOptions.
+type TemplateOptions = internal.TemplateOptions
+
+// Template is a parsed find or watch output template, as accepted by
+// FindWithFormat, FindWithExec, WatchWithFormat and WatchWithExec.
+//
+// The placeholders are {} (the path), {base}, {dir}, {size}, {time},
+// {owner}, {group}, {sha256}, {version}, {event}, {target}, the target
+// of a symbolic link, {acl}, the extended ACL of a found file (see
+// FindOptions.LoadACLs), and {matches}, the lines of a found file matching
+// FindOptions.ContentPattern, one per line; each may be quoted, as in
+// {"base"}, to substitute a Go-quoted string. {{ and }} produce literal
+// braces. Placeholders whose value is not available are output unchanged.
+//
+// Templates containing find -printf directives, such as "%p %s\n", use
+// that syntax instead: %p, %f, %h, %s, %m, %M, %u, %g, %TY, %Tm, %Td, %TH,
+// %TM, %TS, %y and %%, with the escapes \n, \t and \\. As with find,
+// their lines end only where the template says \n.
+//
+// A Template is safe for concurrent use.
+type Template struct {
+	t *internal.Template
+}
+
+// ParseTemplate parses tpl, rejecting unknown placeholders.
+func ParseTemplate(tpl string) (*Template, error) {
+	return ParseTemplateWithOptions(tpl, TemplateOptions{})
+}
+
+// ParseTemplateWithOptions parses tpl with the given options.
+func ParseTemplateWithOptions(tpl string, opts TemplateOptions) (*Template, error) {
+	t, err := internal.ParseTemplateWithOptions(tpl, opts)
+	if err != nil {
+		return nil, err
+	}
+	return &Template{t: t}, nil
+}
+
+// String returns the template as written.
+func (t *Template) String() string {
+	return t.t.String()
+}
+
+// Render substitutes the placeholders with values from msg.
+func (t *Template) Render(msg FindMessage) string {
+	return t.t.Render(msg)
+}
+
+// RenderLine is Render followed by a newline, unless the template uses
+// find -printf directives, whose lines end where the template says \n.
+func (t *Template) RenderLine(msg FindMessage) string {
+	return t.t.RenderLine(msg)
+}
+
+// RenderWatch substitutes the placeholders with values from a watch event.
+func (t *Template) RenderWatch(msg WatchMessage) string {
+	return t.t.RenderWatch(msg)
+}
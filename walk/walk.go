@@ -7,11 +7,11 @@ package walk
 
 import (
 	"context"
+	"io"
 	"os"
-	"time"
 
 	internal "github.com/TFMV/stride/internal/walk"
-	"go.uber.org/zap"
+	"github.com/TFMV/stride/proto/stridepb"
 )
 
 // Re-export all the types and constants from the internal package
@@ -28,6 +28,9 @@ type (
 	// WalkFunc defines the signature for file processing callbacks.
 	WalkFunc = internal.WalkFunc
 
+	// WalkDirFunc defines the signature for WalkDir callbacks.
+	WalkDirFunc = internal.WalkDirFunc
+
 	// AdvancedWalkFunc includes statistics for each callback.
 	AdvancedWalkFunc = internal.AdvancedWalkFunc
 
@@ -40,15 +43,27 @@ type (
 	// MiddlewareFunc defines a middleware function for extensibility.
 	MiddlewareFunc = internal.MiddlewareFunc
 
+	// PathTransformFunc rewrites or vetoes the paths passed to the walk callback.
+	PathTransformFunc = internal.PathTransformFunc
+
 	// ErrorHandling defines how errors are handled during traversal.
 	ErrorHandling = internal.ErrorHandling
 
 	// SymlinkHandling defines how symbolic links are processed.
 	SymlinkHandling = internal.SymlinkHandling
 
+	// TraversalOrder defines the order in which a walk enumerates the tree.
+	TraversalOrder = internal.TraversalOrder
+
 	// LogLevel defines the verbosity of logging.
 	LogLevel = internal.LogLevel
 
+	// IncrementalMode selects how incremental walks treat unchanged directories.
+	IncrementalMode = internal.IncrementalMode
+
+	// CacheStrictness selects how incremental walks detect unchanged directories.
+	CacheStrictness = internal.CacheStrictness
+
 	// MemoryLimit sets memory usage boundaries for the traversal.
 	MemoryLimit = internal.MemoryLimit
 
@@ -61,19 +76,120 @@ type (
 	WatchMessage = internal.WatchMessage
 	WatchResult  = internal.WatchResult
 	WatchHandler = internal.WatchHandler
+	WatchStats   = internal.WatchStats
+	WatchBackend = internal.WatchBackend
+
+	// HashAlgorithm selects the digest attached to watch events
+	HashAlgorithm = internal.HashAlgorithm
+
+	// Watch broadcast types
+	WatchBroadcaster   = internal.WatchBroadcaster
+	WatchSubscription  = internal.WatchSubscription
+	SlowConsumerPolicy = internal.SlowConsumerPolicy
+
+	// Walk budgets
+	Budget      = internal.Budget
+	BudgetError = internal.BudgetError
+
+	// TerminationReason tells why a walk stopped early.
+	TerminationReason = internal.TerminationReason
+
+	// Summary describes a completed walk, with the errors it met.
+	Summary   = internal.Summary
+	WalkError = internal.WalkError
+
+	// WalkErrors is the error of a walk that met several distinct errors.
+	WalkErrors = internal.WalkErrors
+
+	// FindSummary describes a completed search.
+	FindSummary = internal.FindSummary
+
+	// Find explanations
+	FindDecision    = internal.FindDecision
+	PredicateResult = internal.PredicateResult
+
+	// FSInfo describes the filesystem holding the walk root.
+	FSInfo = internal.FSInfo
+
+	// Extremes lists the entries of a walk that stood out.
+	Extremes     = internal.Extremes
+	ExtremeEntry = internal.ExtremeEntry
+
+	// Post-children callbacks
+	ChildStats       = internal.ChildStats
+	PostChildrenFunc = internal.PostChildrenFunc
+
+	// TransientRetry retries filesystem calls after transient errors.
+	TransientRetry = internal.TransientRetry
+
+	// Cleaning files selected by a filter
+	CleanOptions = internal.CleanOptions
+	CleanAction  = internal.CleanAction
+	CleanReport  = internal.CleanReport
+
+	// ProtoStreamWriter streams walked entries as length-prefixed protobuf messages.
+	ProtoStreamWriter = internal.ProtoStreamWriter
+
+	// FileFlags is a set of platform file flags and attributes.
+	FileFlags = internal.FileFlags
+
+	// Limiter bounds the enumeration of concurrent walks.
+	Limiter = internal.Limiter
+
+	// DirConfig narrows a walk's options for the subtree of a directory.
+	DirConfig = internal.DirConfig
+
+	// PrefetchOptions hints to the OS which files are about to be read.
+	PrefetchOptions = internal.PrefetchOptions
+
+	// UnicodeIssue describes a name that is not in NFC form.
+	UnicodeIssue = internal.UnicodeIssue
 )
 
 // Re-export all the constants
 const (
+	// DefaultExtremesK is the length of the Extremes lists by default.
+	DefaultExtremesK = internal.DefaultExtremesK
+
+	// DefaultDirConfigName is the file holding a directory's DirConfig.
+	DefaultDirConfigName = internal.DefaultDirConfigName
+
+	// DefaultProgressInterval is how often WalkLimitWithProgress reports
+	// progress, and walks with options when WalkOptions.ProgressInterval is
+	// not set.
+	DefaultProgressInterval = internal.DefaultProgressInterval
+
+	// Kinds of UnicodeIssue
+	UnicodeNotNFC    = internal.UnicodeNotNFC
+	UnicodeCollision = internal.UnicodeCollision
+
+	// Defaults of PrefetchOptions
+	DefaultPrefetchWindow      = internal.DefaultPrefetchWindow
+	DefaultPrefetchMaxFileSize = internal.DefaultPrefetchMaxFileSize
+
 	// Error handling modes
 	ContinueOnError = internal.ContinueOnError
 	StopOnError     = internal.StopOnError
 	SkipOnError     = internal.SkipOnError
 
 	// Symlink handling modes
-	SymlinkFollow = internal.SymlinkFollow
-	SymlinkIgnore = internal.SymlinkIgnore
-	SymlinkReport = internal.SymlinkReport
+	SymlinkFollow         = internal.SymlinkFollow
+	SymlinkIgnore         = internal.SymlinkIgnore
+	SymlinkReport         = internal.SymlinkReport
+	SymlinkFollowInternal = internal.SymlinkFollowInternal
+
+	// Traversal orders
+	DepthFirst   = internal.DepthFirst
+	BreadthFirst = internal.BreadthFirst
+
+	// Incremental walk modes
+	IncrementalReplay = internal.IncrementalReplay
+	IncrementalPrune  = internal.IncrementalPrune
+
+	// Incremental walk strictness
+	CacheStrictCount = internal.CacheStrictCount
+	CacheStrictMtime = internal.CacheStrictMtime
+	CacheStrictRacy  = internal.CacheStrictRacy
 
 	// Log levels
 	LogLevelError = internal.LogLevelError
@@ -92,10 +208,119 @@ const (
 	EventDelete = internal.EventDelete
 	EventRename = internal.EventRename
 	EventChmod  = internal.EventChmod
+
+	// Slow consumer policies
+	SlowConsumerBlock      = internal.SlowConsumerBlock
+	SlowConsumerDropOldest = internal.SlowConsumerDropOldest
+
+	// Watch backends
+	WatchBackendAuto   = internal.WatchBackendAuto
+	WatchBackendNotify = internal.WatchBackendNotify
+	WatchBackendPoll   = internal.WatchBackendPoll
+
+	// Hash algorithms for WatchOptions.HashAlgorithm
+	HashNone     = internal.HashNone
+	HashSHA256   = internal.HashSHA256
+	HashXXHash64 = internal.HashXXHash64
+
+	// File flags
+	FlagImmutable  = internal.FlagImmutable
+	FlagAppendOnly = internal.FlagAppendOnly
+	FlagNoDump     = internal.FlagNoDump
+	FlagReadOnly   = internal.FlagReadOnly
+	FlagHidden     = internal.FlagHidden
+	FlagSystem     = internal.FlagSystem
+
+	// Walk budgets
+	BudgetDuration = internal.BudgetDuration
+	BudgetFiles    = internal.BudgetFiles
+
+	// Reasons a walk stopped early, in Stats.TerminationReason
+	TerminationCompleted   = internal.TerminationCompleted
+	TerminationCanceled    = internal.TerminationCanceled
+	TerminationDeadline    = internal.TerminationDeadline
+	TerminationBudget      = internal.TerminationBudget
+	TerminationMemoryLimit = internal.TerminationMemoryLimit
+	TerminationSkipAll     = internal.TerminationSkipAll
+
+	// DefaultWatchQueueSize is the watch queue size used when
+	// WatchOptions.QueueSize is zero.
+	DefaultWatchQueueSize = internal.DefaultWatchQueueSize
+
+	// DefaultWatchSnapshotCacheSize is the number of file snapshots kept
+	// for WatchOptions.ContentChangeOnly when SnapshotCacheSize is zero.
+	DefaultWatchSnapshotCacheSize = internal.DefaultWatchSnapshotCacheSize
+
+	// DefaultPollInterval is how often WatchBackendPoll walks the tree when
+	// WatchOptions.PollInterval is zero.
+	DefaultPollInterval = internal.DefaultPollInterval
+
+	// DefaultWatchMaxHashSize is the size above which files are not hashed
+	// when WatchOptions.MaxHashSize is zero.
+	DefaultWatchMaxHashSize = internal.DefaultWatchMaxHashSize
+
+	// HashSkippedSize is the Metadata["hash"] of events for files too large
+	// to hash.
+	HashSkippedSize = internal.HashSkippedSize
+
+	// DefaultCleanMaxDelete is the number of matches above which CleanFiles
+	// refuses to act when CleanOptions.MaxDelete is zero.
+	DefaultCleanMaxDelete = internal.DefaultCleanMaxDelete
+
+	// DefaultCleanJournalName is the name of the journal kept in
+	// CleanOptions.QuarantineDir when CleanOptions.Journal is empty.
+	DefaultCleanJournalName = internal.DefaultCleanJournalName
+
+	// Actions recorded in a clean journal
+	CleanActionDryRun     = internal.CleanActionDryRun
+	CleanActionDelete     = internal.CleanActionDelete
+	CleanActionQuarantine = internal.CleanActionQuarantine
+	CleanActionRestore    = internal.CleanActionRestore
+
+	// Values of Metadata["change"] under WatchOptions.ContentChangeOnly
+	ChangeContent  = internal.ChangeContent
+	ChangeMetadata = internal.ChangeMetadata
+)
+
+// ErrOutsideRoot is reported, wrapped, for symlinks that SymlinkFollowInternal
+// does not follow because they leave the walk root.
+var ErrOutsideRoot = internal.ErrOutsideRoot
+
+// ErrBudgetExceeded is reported, wrapped in a *BudgetError, when a walk stops
+// early because WalkOptions.MaxDuration or WalkOptions.MaxFiles ran out.
+var ErrBudgetExceeded = internal.ErrBudgetExceeded
+
+// Errors reported when a walk is canceled, its context's deadline passes,
+// the heap outgrows WalkOptions.MemoryLimits.HardLimit or the callback
+// returns filepath.SkipAll. The first two also match the context's error.
+var (
+	ErrCanceledByUser = internal.ErrCanceledByUser
+	ErrDeadline       = internal.ErrDeadline
+	ErrMemoryLimit    = internal.ErrMemoryLimit
+	ErrSkipAll        = internal.ErrSkipAll
 )
 
+// ErrWatchOverflow is reported, wrapped in a WatchResult error, when watch
+// events were lost and handlers should rescan.
+var ErrWatchOverflow = internal.ErrWatchOverflow
+
+// ErrWatchIdle is returned by Watch when WatchOptions.IdleTimeout passed
+// without an event being delivered.
+var ErrWatchIdle = internal.ErrWatchIdle
+
+// ErrCleanLimit is returned, wrapped, by CleanFiles when the filter matches
+// more files than CleanOptions.MaxDelete allows.
+var ErrCleanLimit = internal.ErrCleanLimit
+
+// ErrCleanJournalRequired is returned by CleanFiles when it is to delete
+// files without CleanOptions.Journal.
+var ErrCleanJournalRequired = internal.ErrCleanJournalRequired
+
 // Walk traverses the file tree rooted at root, calling walkFn for each file or directory.
-// It's similar to filepath.Walk but with better error handling.
+// It's similar to filepath.Walk but with better error handling. Like
+// filepath.Walk, it visits a root that is not a directory as a single entry,
+// and fails with an error matching fs.ErrNotExist if the root does not exist;
+// every other entry point, Find included, does the same.
 func Walk(root string, walkFn func(path string, info os.FileInfo, err error) error) error {
 	return internal.Walk(root, walkFn)
 }
@@ -110,6 +335,12 @@ func WalkLimitWithProgress(ctx context.Context, root string, walkFn func(path st
 	return internal.WalkLimitWithProgress(ctx, root, walkFn, limit, progressFn)
 }
 
+// WalkLimitWithProgressAndOptions is like WalkLimitWithProgress, but errors
+// returned by walkFn are counted and handled as errorHandling directs.
+func WalkLimitWithProgressAndOptions(ctx context.Context, root string, walkFn func(path string, info os.FileInfo, err error) error, limit int, progressFn ProgressFn, errorHandling ErrorHandling) error {
+	return internal.WalkLimitWithProgressAndOptions(ctx, root, walkFn, limit, progressFn, errorHandling)
+}
+
 // WalkLimitWithFilter traverses the file tree with filtering options.
 func WalkLimitWithFilter(ctx context.Context, root string, walkFn func(path string, info os.FileInfo, err error) error, limit int, filter FilterOptions) error {
 	return internal.WalkLimitWithFilter(ctx, root, walkFn, limit, filter)
@@ -120,11 +351,55 @@ func WalkLimitWithOptions(ctx context.Context, root string, walkFn func(path str
 	return internal.WalkLimitWithOptions(ctx, root, walkFn, opts)
 }
 
+// WalkLimitWithOptionsStats is like WalkLimitWithOptions but also returns the
+// final traversal statistics.
+func WalkLimitWithOptionsStats(ctx context.Context, root string, walkFn func(path string, info os.FileInfo, err error) error, opts WalkOptions) (Stats, error) {
+	return internal.WalkLimitWithOptionsStats(ctx, root, walkFn, opts)
+}
+
+// WalkDir traverses the file tree passing fs.DirEntry values, which only stat
+// the entry when Info is called.
+func WalkDir(root string, fn WalkDirFunc, opts WalkOptions) error {
+	return internal.WalkDir(root, fn, opts)
+}
+
 // WalkWithOptions traverses the file tree with the enhanced context-aware API.
 func WalkWithOptions(root string, walkFn WalkFunc, options WalkOptions) error {
 	return internal.WalkWithOptions(root, walkFn, options)
 }
 
+// WalkWithOptionsAndStats is like WalkWithOptions but also returns the final
+// traversal statistics.
+func WalkWithOptionsAndStats(root string, walkFn WalkFunc, options WalkOptions) (Stats, error) {
+	return internal.WalkWithOptionsAndStats(root, walkFn, options)
+}
+
+// WalkWithSummary is like WalkWithOptionsAndStats, but returns a Summary
+// holding the final statistics, every error met along with its path, and
+// the start and end times of the walk:
+//
+//	summary, err := walk.WalkWithSummary(root, fn, walk.WalkOptions{})
+//	for _, e := range summary.Errors {
+//		log.Printf("%s: %v (skipped: %v)", e.Path, e.Err, e.Skipped)
+//	}
+func WalkWithSummary(root string, walkFn WalkFunc, options WalkOptions) (Summary, error) {
+	return internal.WalkWithSummary(root, walkFn, options)
+}
+
+// Map runs fn on the worker pool for every file under root passing
+// opts.Filter and returns the values it computed, ordered by path if
+// opts.SortResults is set. Errors of fn follow the error handling mode of
+// opts.
+func Map[T any](ctx context.Context, root string, fn func(ctx context.Context, path string, info os.FileInfo) (T, error), opts WalkOptions) ([]T, error) {
+	return internal.Map(ctx, root, fn, opts)
+}
+
+// Reduce is like Map, but combines the values with the associative merge
+// function as they are computed instead of collecting them.
+func Reduce[T any](ctx context.Context, root string, fn func(ctx context.Context, path string, info os.FileInfo) (T, error), merge func(a, b T) T, opts WalkOptions) (T, error) {
+	return internal.Reduce(ctx, root, fn, merge, opts)
+}
+
 // WalkWithAdvancedOptions traverses the file tree with statistics access.
 func WalkWithAdvancedOptions(root string, walkFn AdvancedWalkFunc, options WalkOptions) error {
 	return internal.WalkWithAdvancedOptions(root, walkFn, options)
@@ -143,6 +418,64 @@ func NewFilterOptions() FilterOptions {
 	}
 }
 
+// ParseSymbolicMode parses a permission mode given in octal ("0644") or as
+// chmod-style symbolic clauses ("u=rw,go=r").
+func ParseSymbolicMode(s string) (os.FileMode, error) {
+	return internal.ParseSymbolicMode(s)
+}
+
+// ParseFileFlags parses a comma-separated list of file flag names:
+// immutable, appendonly, nodump, readonly, hidden and system.
+func ParseFileFlags(s string) (FileFlags, error) {
+	return internal.ParseFileFlags(s)
+}
+
+// NewLimiter returns a Limiter allowing maxConcurrentOps operations at once
+// across the walks sharing it through WalkOptions.SharedLimiter, or nil,
+// imposing no limit, if maxConcurrentOps is not positive.
+func NewLimiter(maxConcurrentOps int) *Limiter {
+	return internal.NewLimiter(maxConcurrentOps)
+}
+
+// SetGlobalLimiter bounds the operations in flight across all walks in the
+// process that do not set WalkOptions.SharedLimiter. A maxConcurrentOps of 0
+// removes the limit.
+func SetGlobalLimiter(maxConcurrentOps int) {
+	internal.SetGlobalLimiter(maxConcurrentOps)
+}
+
+// MatchPattern reports whether a slash-separated path relative to a walk or
+// watch root matches pattern, with ** matching any number of segments.
+func MatchPattern(pattern, rel string) (bool, error) {
+	return internal.MatchPattern(pattern, rel)
+}
+
+// EvaluateFilter reports whether filter includes the file at path, described
+// by info, and names the criteria it fails. Criteria that depend on the walk
+// root are not evaluated.
+func EvaluateFilter(path string, info os.FileInfo, filter FilterOptions) (bool, []string) {
+	return internal.EvaluateFilter(path, info, filter)
+}
+
+// ReadIncludePaths reads path prefixes for FilterOptions.IncludePaths, one
+// per line, skipping blank lines and '#' comments.
+func ReadIncludePaths(r io.Reader) ([]string, error) {
+	return internal.ReadIncludePaths(r)
+}
+
+// LoadIncludePaths reads the path prefixes in the file at path; see
+// ReadIncludePaths.
+func LoadIncludePaths(path string) ([]string, error) {
+	return internal.LoadIncludePaths(path)
+}
+
+// CommonJunkPatterns returns the base-name patterns of the editor swap,
+// backup and lock files and file manager metadata that SkipJunkFiles leaves
+// out.
+func CommonJunkPatterns() []string {
+	return internal.CommonJunkPatterns()
+}
+
 // NewWalkOptions creates a new WalkOptions with default values.
 func NewWalkOptions() WalkOptions {
 	return WalkOptions{
@@ -155,51 +488,17 @@ func NewWalkOptions() WalkOptions {
 	}
 }
 
-// LoggingMiddleware creates a middleware that logs file processing.
-func LoggingMiddleware(logger *zap.Logger) MiddlewareFunc {
-	return func(next WalkFunc) WalkFunc {
-		return func(ctx context.Context, path string, info os.FileInfo) error {
-			if !info.IsDir() {
-				logger.Debug("Processing file",
-					zap.String("path", path),
-					zap.Int64("size", info.Size()),
-					zap.Time("modified", info.ModTime()),
-				)
-			}
-			err := next(ctx, path, info)
-			if err != nil {
-				logger.Error("Error processing file",
-					zap.String("path", path),
-					zap.Error(err),
-				)
-			}
-			return err
-		}
-	}
-}
-
-// TimingMiddleware creates a middleware that measures processing time.
-func TimingMiddleware(threshold time.Duration) MiddlewareFunc {
-	return func(next WalkFunc) WalkFunc {
-		return func(ctx context.Context, path string, info os.FileInfo) error {
-			start := time.Now()
-			err := next(ctx, path, info)
-			duration := time.Since(start)
-
-			if duration > threshold {
-				// You could log this or handle it in some other way
-			}
-
-			return err
-		}
-	}
-}
-
 // Watch monitors a directory for filesystem changes
 func Watch(ctx context.Context, root string, opts WatchOptions, handler WatchHandler) error {
 	return internal.Watch(ctx, root, opts, handler)
 }
 
+// NewWatchBroadcaster creates a broadcaster that fans a watch stream out to
+// independent subscribers.
+func NewWatchBroadcaster() *WatchBroadcaster {
+	return internal.NewWatchBroadcaster()
+}
+
 // WatchWithExec watches for filesystem changes and executes a command for each event
 func WatchWithExec(ctx context.Context, root string, opts WatchOptions, cmdTemplate string) error {
 	return internal.WatchWithExec(ctx, root, opts, cmdTemplate)
@@ -209,3 +508,52 @@ func WatchWithExec(ctx context.Context, root string, opts WatchOptions, cmdTempl
 func WatchWithFormat(ctx context.Context, root string, opts WatchOptions, formatTemplate string) error {
 	return internal.WatchWithFormat(ctx, root, opts, formatTemplate)
 }
+
+// DetectRemoteFS reports whether path appears to be on a network filesystem,
+// where change notifications may miss changes made by other hosts
+func DetectRemoteFS(path string) bool {
+	return internal.DetectRemoteFS(path)
+}
+
+// WatchWithJSON watches for filesystem changes and writes each event to w as
+// a line of JSON
+func WatchWithJSON(ctx context.Context, root string, opts WatchOptions, w io.Writer) error {
+	return internal.WatchWithJSON(ctx, root, opts, w)
+}
+
+// CleanFiles deletes the files beneath root selected by filter, or moves
+// them to opts.QuarantineDir, after checking them against opts.MaxDelete
+func CleanFiles(ctx context.Context, root string, filter FilterOptions, opts CleanOptions) (CleanReport, error) {
+	return internal.CleanFiles(ctx, root, filter, opts)
+}
+
+// UndoClean moves the files quarantined by CleanFiles, as recorded in its
+// journal, back to where they were
+func UndoClean(ctx context.Context, journal string, onAction func(CleanAction)) (CleanReport, error) {
+	return internal.UndoClean(ctx, journal, onAction)
+}
+
+// NewProtoStreamWriter creates a writer streaming entries to w as
+// stridepb.Entry messages, each preceded by its length as a varint
+func NewProtoStreamWriter(w io.Writer) *ProtoStreamWriter {
+	return internal.NewProtoStreamWriter(w)
+}
+
+// ReadProtoStream reads the entries written by a ProtoStreamWriter from r,
+// passing each to fn
+func ReadProtoStream(r io.Reader, fn func(*stridepb.Entry) error) error {
+	return internal.ReadProtoStream(r, fn)
+}
+
+// StopReason returns the reason a walk that returned err stopped early, or
+// TerminationCompleted if it was not stopped
+func StopReason(err error) TerminationReason {
+	return internal.StopReason(err)
+}
+
+// OpenInRoot opens name, relative to the root of the walk with
+// WalkOptions.ConfineToRoot that passed ctx to the callback, without leaving
+// the root even if entries are swapped for symbolic links
+func OpenInRoot(ctx context.Context, name string) (*os.File, error) {
+	return internal.OpenInRoot(ctx, name)
+}
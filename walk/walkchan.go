@@ -0,0 +1,31 @@
+package walk
+
+import (
+	"context"
+
+	internal "github.com/TFMV/stride/internal/walk"
+)
+
+// WalkResult is an entry, or an error, produced by WalkChan.
+type WalkResult = internal.WalkResult
+
+// WalkChan walks the tree rooted at root, as by WalkLimitWithOptions with
+// opts, and sends its entries on the returned channel, which is closed once
+// the walk is done. At most opts.BufferSize results wait to be received.
+// A consumer that stops early must cancel ctx:
+//
+//	ctx, cancel := context.WithCancel(ctx)
+//	defer cancel()
+//	results, err := walk.WalkChan(ctx, "/data", opts)
+//	if err != nil {
+//		return err
+//	}
+//	for result := range results {
+//		if result.Error != nil {
+//			return result.Error
+//		}
+//		fmt.Println(result.Path)
+//	}
+func WalkChan(ctx context.Context, root string, opts WalkOptions) (<-chan WalkResult, error) {
+	return internal.WalkChan(ctx, root, opts)
+}
@@ -0,0 +1,13 @@
+//go:build !darwin && !linux
+
+package walktest
+
+import (
+	"errors"
+	"fmt"
+)
+
+// mkfifo reports that named pipes are not supported.
+func mkfifo(path string) error {
+	return fmt.Errorf("FIFOs: %w", errors.ErrUnsupported)
+}
@@ -0,0 +1,10 @@
+//go:build darwin || linux
+
+package walktest
+
+import "syscall"
+
+// mkfifo creates a named pipe at path.
+func mkfifo(path string) error {
+	return syscall.Mkfifo(path, 0644)
+}
@@ -0,0 +1,343 @@
+// Package walktest builds fixture trees for tests of code that walks the
+// filesystem, so that walks, finds and watches can be tested against a
+// deterministic tree instead of hand-written WriteFile, Chtimes and Symlink
+// loops.
+//
+// A Tree maps slash-separated paths, relative to the root, to the entries to
+// create there:
+//
+//	old := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+//	root := walktest.Tree{
+//		"dir1/file.txt": walktest.File{Size: 2048, ModTime: old, Mode: 0644},
+//		"dir2/":         walktest.Dir{Mode: 0755},
+//		"link":          walktest.Symlink{Target: "dir1"},
+//		"secret":        walktest.Unreadable{Entry: walktest.File{Content: "x"}},
+//	}.Build(t)
+//
+// Parent directories are created as needed with mode 0755. Modes are set
+// exactly, regardless of the umask, and directory modification times are
+// set after their contents are created, so they hold.
+package walktest
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Entry is something Tree can create: a File, Dir, Symlink, Hardlink, FIFO
+// or Unreadable entry.
+type Entry interface {
+	create(root, path string) error
+}
+
+// File is a regular file.
+type File struct {
+	Content string      // Content of the file
+	Size    int64       // Size of the file, filled with 'x', if Content is empty
+	ModTime time.Time   // Access and modification time, if set
+	Mode    os.FileMode // Permissions (default 0644)
+}
+
+// Dir is a directory. Paths ending in '/' must be directories.
+type Dir struct {
+	ModTime time.Time   // Access and modification time, if set
+	Mode    os.FileMode // Permissions (default 0755)
+}
+
+// Symlink is a symbolic link to Target, which is used as given: relative
+// targets are resolved from the directory holding the link, and need not
+// exist or lie within the tree.
+type Symlink struct {
+	Target string
+}
+
+// Hardlink is another name for the file at Target, a slash-separated path
+// relative to the root, which must be created by the same Tree.
+type Hardlink struct {
+	Target string
+}
+
+// FIFO is a named pipe. Build skips the test on platforms without them.
+type FIFO struct {
+	Mode os.FileMode // Permissions (default 0644)
+}
+
+// Unreadable is Entry with all permissions removed once the tree is built,
+// so that walks fail to read it. The permissions are restored when the test
+// ends, so that the tree can be removed. Permissions do not stop the
+// superuser; use SkipIfPrivileged in tests that rely on them.
+type Unreadable struct {
+	Entry Entry
+}
+
+// Tree maps slash-separated paths relative to the root to entries.
+type Tree map[string]Entry
+
+// Build creates the tree in a new temporary directory and returns the
+// directory, removed when the test ends. It fails the test if the tree
+// cannot be created, and skips it if the tree needs something the platform
+// lacks, such as FIFOs.
+func (tr Tree) Build(tb testing.TB) string {
+	tb.Helper()
+	root := tb.TempDir()
+	restore, err := tr.Create(root)
+	tb.Cleanup(restore)
+	if errors.Is(err, errors.ErrUnsupported) {
+		tb.Skipf("walktest: %v", err)
+	}
+	if err != nil {
+		tb.Fatalf("walktest: %v", err)
+	}
+	return root
+}
+
+// Create creates the tree in the existing directory root. It returns a
+// function restoring the permissions of Unreadable entries, which must be
+// called before the tree can be removed, even if Create fails.
+//
+// Create reports paths that are empty, leave the root or are given twice,
+// and entries below a path that is not a directory, before creating
+// anything.
+func (tr Tree) Create(root string) (restore func(), err error) {
+	restore = func() {}
+	entries, paths, err := tr.entries()
+	if err != nil {
+		return restore, err
+	}
+
+	// Create entries parents first, hard links once their targets exist
+	var links []string
+	for _, p := range paths {
+		entry := entries[p]
+		if _, ok := unwrap(entry).(Hardlink); ok {
+			links = append(links, p)
+			continue
+		}
+		if err := createEntry(root, p, entry); err != nil {
+			return restore, err
+		}
+	}
+	for _, p := range links {
+		if err := createEntry(root, p, entries[p]); err != nil {
+			return restore, err
+		}
+	}
+
+	// Set directory times once their contents exist, children first
+	for i := len(paths) - 1; i >= 0; i-- {
+		if d, ok := unwrap(entries[paths[i]]).(Dir); ok && !d.ModTime.IsZero() {
+			if err := setTime(root, paths[i], d.ModTime); err != nil {
+				return restore, err
+			}
+		}
+	}
+
+	// Lock unreadable entries, children first, and unlock them parents first
+	var locked []string
+	restore = func() {
+		for i := len(locked) - 1; i >= 0; i-- {
+			p := locked[i]
+			os.Chmod(filepath.Join(root, filepath.FromSlash(p)), entryMode(unwrap(entries[p])))
+		}
+	}
+	for i := len(paths) - 1; i >= 0; i-- {
+		p := paths[i]
+		if _, ok := entries[p].(Unreadable); !ok {
+			continue
+		}
+		if err := os.Chmod(filepath.Join(root, filepath.FromSlash(p)), 0); err != nil {
+			return restore, fmt.Errorf("%s: %w", p, err)
+		}
+		locked = append(locked, p)
+	}
+	return restore, nil
+}
+
+// entries validates the tree and returns its entries keyed by cleaned path,
+// and the paths sorted so that parents come before their children.
+func (tr Tree) entries() (map[string]Entry, []string, error) {
+	entries := make(map[string]Entry, len(tr))
+	for key, entry := range tr {
+		if entry == nil || unwrap(entry) == nil {
+			return nil, nil, fmt.Errorf("%s: no entry", key)
+		}
+		if u, ok := entry.(Unreadable); ok {
+			switch u.Entry.(type) {
+			case File, Dir, FIFO:
+			default:
+				return nil, nil, fmt.Errorf("%s: only a File, Dir or FIFO can be Unreadable", key)
+			}
+		}
+		p, err := cleanPath(key)
+		if err != nil {
+			return nil, nil, err
+		}
+		if _, ok := unwrap(entry).(Dir); !ok && strings.HasSuffix(key, "/") {
+			return nil, nil, fmt.Errorf("%s: only a Dir can end in '/'", key)
+		}
+		if _, dup := entries[p]; dup {
+			return nil, nil, fmt.Errorf("%s: given more than once", p)
+		}
+		entries[p] = entry
+	}
+
+	paths := make([]string, 0, len(entries))
+	for p := range entries {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+
+	for _, p := range paths {
+		// Only directories may have entries beneath them
+		for dir := path.Dir(p); dir != "."; dir = path.Dir(dir) {
+			if entry, ok := entries[dir]; ok {
+				if _, isDir := unwrap(entry).(Dir); !isDir {
+					return nil, nil, fmt.Errorf("%s: conflicts with %s, which is not a Dir", p, dir)
+				}
+			}
+		}
+
+		// Hard links need a file of the tree to link to
+		if l, ok := unwrap(entries[p]).(Hardlink); ok {
+			target, err := cleanPath(l.Target)
+			if err != nil {
+				return nil, nil, fmt.Errorf("%s: hardlink target %w", p, err)
+			}
+			if _, isFile := unwrap(entries[target]).(File); !isFile {
+				return nil, nil, fmt.Errorf("%s: hardlink target %s is not a File of the tree", p, target)
+			}
+		}
+	}
+	return entries, paths, nil
+}
+
+// cleanPath cleans a slash-separated path relative to the root, rejecting
+// paths that are empty or leave the root.
+func cleanPath(p string) (string, error) {
+	clean := path.Clean(p)
+	switch {
+	case p == "" || clean == ".":
+		return "", fmt.Errorf("%q: the root is not an entry", p)
+	case path.IsAbs(p) || filepath.IsAbs(p) || clean == ".." || strings.HasPrefix(clean, "../"):
+		return "", fmt.Errorf("%s: escapes the root", p)
+	}
+	return clean, nil
+}
+
+// unwrap returns the entry inside an Unreadable entry.
+func unwrap(entry Entry) Entry {
+	if u, ok := entry.(Unreadable); ok {
+		return u.Entry
+	}
+	return entry
+}
+
+// entryMode returns the permissions entry is created with.
+func entryMode(entry Entry) os.FileMode {
+	switch e := entry.(type) {
+	case File:
+		return orDefault(e.Mode, 0644)
+	case Dir:
+		return orDefault(e.Mode, 0755)
+	case FIFO:
+		return orDefault(e.Mode, 0644)
+	}
+	return 0644
+}
+
+func orDefault(mode, def os.FileMode) os.FileMode {
+	if mode == 0 {
+		return def
+	}
+	return mode.Perm()
+}
+
+// createEntry creates the entry at the slash-separated path p below root.
+func createEntry(root, p string, entry Entry) error {
+	full := filepath.Join(root, filepath.FromSlash(p))
+	if err := os.MkdirAll(filepath.Dir(full), 0755); err != nil {
+		return fmt.Errorf("%s: %w", p, err)
+	}
+	if err := unwrap(entry).create(root, full); err != nil {
+		return fmt.Errorf("%s: %w", p, err)
+	}
+	return nil
+}
+
+func (f File) create(root, path string) error {
+	content := []byte(f.Content)
+	if f.Content == "" {
+		content = []byte(strings.Repeat("x", int(f.Size)))
+	} else if f.Size != 0 && f.Size != int64(len(content)) {
+		return fmt.Errorf("size %d does not match the content", f.Size)
+	}
+	if err := os.WriteFile(path, content, 0644); err != nil {
+		return err
+	}
+	if err := os.Chmod(path, entryMode(f)); err != nil {
+		return err
+	}
+	if !f.ModTime.IsZero() {
+		return os.Chtimes(path, f.ModTime, f.ModTime)
+	}
+	return nil
+}
+
+func (d Dir) create(root, path string) error {
+	if err := os.Mkdir(path, 0755); err != nil && !os.IsExist(err) {
+		return err
+	}
+	return os.Chmod(path, entryMode(d))
+}
+
+func (s Symlink) create(root, path string) error {
+	return os.Symlink(s.Target, path)
+}
+
+func (l Hardlink) create(root, path string) error {
+	return os.Link(filepath.Join(root, filepath.FromSlash(l.Target)), path)
+}
+
+func (f FIFO) create(root, path string) error {
+	if err := mkfifo(path); err != nil {
+		return err
+	}
+	return os.Chmod(path, entryMode(f))
+}
+
+func (u Unreadable) create(root, path string) error {
+	return u.Entry.create(root, path)
+}
+
+// setTime sets the access and modification times of the entry at p.
+func setTime(root, p string, t time.Time) error {
+	if err := os.Chtimes(filepath.Join(root, filepath.FromSlash(p)), t, t); err != nil {
+		return fmt.Errorf("%s: %w", p, err)
+	}
+	return nil
+}
+
+// Touch sets the access and modification times of path to t, failing the
+// test if it cannot.
+func Touch(tb testing.TB, path string, t time.Time) {
+	tb.Helper()
+	if err := os.Chtimes(path, t, t); err != nil {
+		tb.Fatalf("walktest: %v", err)
+	}
+}
+
+// SkipIfPrivileged skips the test when permissions do not restrict it, as
+// for the superuser, so that Unreadable entries would still be read.
+func SkipIfPrivileged(tb testing.TB) {
+	tb.Helper()
+	if os.Geteuid() == 0 {
+		tb.Skip("walktest: permissions do not apply to the superuser")
+	}
+}
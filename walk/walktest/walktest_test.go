@@ -0,0 +1,184 @@
+package walktest
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestBuild(t *testing.T) {
+	old := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	root := Tree{
+		"dir1/file.txt":  File{Size: 2048, ModTime: old, Mode: 0600},
+		"dir1/hello.txt": File{Content: "hello"},
+		"dir2/":          Dir{Mode: 0700, ModTime: old},
+		"dir2/sub/a.txt": File{},
+		"link":           Symlink{Target: "dir1"},
+		"hard.txt":       Hardlink{Target: "dir1/hello.txt"},
+	}.Build(t)
+
+	tests := []struct {
+		path  string
+		mode  os.FileMode
+		size  int64
+		mtime time.Time
+	}{
+		{"dir1/file.txt", 0600, 2048, old},
+		{"dir1/hello.txt", 0644, 5, time.Time{}},
+		{"dir2", os.ModeDir | 0700, -1, old},
+		{"dir2/sub", os.ModeDir | 0755, -1, time.Time{}},
+		{"dir2/sub/a.txt", 0644, 0, time.Time{}},
+		{"link", os.ModeSymlink, -1, time.Time{}},
+		{"hard.txt", 0644, 5, time.Time{}},
+	}
+	for _, tt := range tests {
+		info, err := os.Lstat(filepath.Join(root, filepath.FromSlash(tt.path)))
+		if err != nil {
+			t.Errorf("Failed to stat %s: %v", tt.path, err)
+			continue
+		}
+		mode := info.Mode()
+		if tt.mode == os.ModeSymlink {
+			mode &= os.ModeType
+		}
+		if mode != tt.mode {
+			t.Errorf("Expected %s to have mode %v, got %v", tt.path, tt.mode, mode)
+		}
+		if tt.size >= 0 && info.Size() != tt.size {
+			t.Errorf("Expected %s to have %d bytes, got %d", tt.path, tt.size, info.Size())
+		}
+		if !tt.mtime.IsZero() && !info.ModTime().Equal(tt.mtime) {
+			t.Errorf("Expected %s to be modified at %v, got %v", tt.path, tt.mtime, info.ModTime())
+		}
+	}
+
+	if target, err := os.Readlink(filepath.Join(root, "link")); err != nil || target != "dir1" {
+		t.Errorf("Expected link to point to dir1, got %q (%v)", target, err)
+	}
+	a, _ := os.Stat(filepath.Join(root, "hard.txt"))
+	b, _ := os.Stat(filepath.Join(root, "dir1", "hello.txt"))
+	if a == nil || b == nil || !os.SameFile(a, b) {
+		t.Error("Expected hard.txt to be a hard link to dir1/hello.txt")
+	}
+}
+
+func TestBuildErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		tree Tree
+		want string
+	}{
+		{"empty path", Tree{"": File{}}, "the root is not an entry"},
+		{"root", Tree{"./": Dir{}}, "the root is not an entry"},
+		{"parent", Tree{"../x.txt": File{}}, "escapes the root"},
+		{"parent after clean", Tree{"a/../../x.txt": File{}}, "escapes the root"},
+		{"absolute", Tree{"/etc/x.txt": File{}}, "escapes the root"},
+		{"duplicate", Tree{"a/b": File{}, "a/./b": File{}}, "given more than once"},
+		{"dir twice", Tree{"a": Dir{}, "a/": Dir{}}, "given more than once"},
+		{"below a file", Tree{"a": File{}, "a/b.txt": File{}}, "conflicts with a"},
+		{"below a symlink", Tree{"a": Symlink{Target: "."}, "a/b/c.txt": File{}}, "conflicts with a"},
+		{"file with slash", Tree{"a/": File{}}, "only a Dir"},
+		{"nil entry", Tree{"a": nil}, "no entry"},
+		{"size mismatch", Tree{"a": File{Content: "abc", Size: 10}}, "does not match"},
+		{"hardlink outside", Tree{"a": Hardlink{Target: "../b"}}, "escapes the root"},
+		{"hardlink missing", Tree{"a": Hardlink{Target: "b"}}, "not a File of the tree"},
+		{"hardlink to dir", Tree{"a": Hardlink{Target: "b"}, "b/": Dir{}}, "not a File of the tree"},
+		{"unreadable symlink", Tree{"a": Unreadable{Entry: Symlink{Target: "b"}}}, "only a File, Dir or FIFO"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			root := t.TempDir()
+			restore, err := tt.tree.Create(root)
+			restore()
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("Expected an error containing %q, got %v", tt.want, err)
+			}
+		})
+	}
+}
+
+func TestBuildValidatesFirst(t *testing.T) {
+	root := t.TempDir()
+	restore, err := Tree{"a.txt": File{}, "b/../../c": File{}}.Create(root)
+	restore()
+	if err == nil {
+		t.Fatal("Expected an error")
+	}
+	if entries, _ := os.ReadDir(root); len(entries) != 0 {
+		t.Errorf("Expected nothing to be created, got %d entries", len(entries))
+	}
+}
+
+func TestUnreadable(t *testing.T) {
+	root := t.TempDir()
+	restore, err := Tree{
+		"locked/":       Unreadable{Entry: Dir{}},
+		"locked/in.txt": File{Content: "in"},
+		"secret.txt":    Unreadable{Entry: File{Content: "secret"}},
+	}.Create(root)
+	defer restore()
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	locked, secret := filepath.Join(root, "locked"), filepath.Join(root, "secret.txt")
+
+	for _, path := range []string{locked, secret} {
+		if perm := permOf(t, path); perm != 0 {
+			t.Errorf("Expected %s to have no permissions, got %v", path, perm)
+		}
+	}
+	if os.Geteuid() != 0 {
+		if _, err := os.ReadFile(secret); !os.IsPermission(err) {
+			t.Errorf("Expected secret.txt to be unreadable, got %v", err)
+		}
+		if _, err := os.ReadDir(locked); !os.IsPermission(err) {
+			t.Errorf("Expected locked to be unreadable, got %v", err)
+		}
+	}
+
+	// Restoring the permissions lets the tree be removed
+	restore()
+	if perm := permOf(t, locked); perm != 0755 {
+		t.Errorf("Expected locked to be restored to 0755, got %v", perm)
+	}
+	if perm := permOf(t, secret); perm != 0644 {
+		t.Errorf("Expected secret.txt to be restored to 0644, got %v", perm)
+	}
+}
+
+// permOf returns the permissions of path.
+func permOf(t *testing.T, path string) os.FileMode {
+	t.Helper()
+	info, err := os.Lstat(path)
+	if err != nil {
+		t.Fatalf("Failed to stat: %v", err)
+	}
+	return info.Mode().Perm()
+}
+
+func TestFIFO(t *testing.T) {
+	root := Tree{"pipe": FIFO{}}.Build(t)
+	info, err := os.Lstat(filepath.Join(root, "pipe"))
+	if err != nil {
+		t.Fatalf("Failed to stat: %v", err)
+	}
+	if info.Mode()&os.ModeNamedPipe == 0 {
+		t.Errorf("Expected a named pipe, got %v", info.Mode())
+	}
+}
+
+func TestTouch(t *testing.T) {
+	root := Tree{"a.txt": File{}}.Build(t)
+	path := filepath.Join(root, "a.txt")
+	when := time.Date(2001, 2, 3, 4, 5, 6, 0, time.UTC)
+	Touch(t, path, when)
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("Failed to stat: %v", err)
+	}
+	if !info.ModTime().Equal(when) {
+		t.Errorf("Expected a.txt to be modified at %v, got %v", when, info.ModTime())
+	}
+}